@@ -0,0 +1,296 @@
+// Command orderctl administers the order service. By default it talks to
+// the service's API; with -direct it connects to Postgres, Redis and
+// RabbitMQ itself, using the service's environment, for break-glass work
+// when the API is down. migrate, invalidate-cache, drain-outbox, retention
+// and seed are direct only.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"order-service/internal/app"
+	"order-service/internal/audit"
+	"order-service/internal/productstub"
+	"order-service/internal/repository"
+	"order-service/internal/seed"
+	"order-service/internal/service"
+	"os"
+	"strconv"
+	"time"
+)
+
+const usage = `Usage: orderctl [flags] <command> [args]
+
+Commands:
+  create -product ID [-quantity N] [-customer ID]   create a test order
+  get ID                                            show an order
+  replay ID                                         publish the order's event again
+  migrate                                           create or update tables (direct)
+  invalidate-cache PRODUCT_ID...                    drop cached product order lists (direct)
+  drain-outbox                                      publish pending outbox messages now (direct)
+  retention [-dry-run]                              apply RETENTION_RULES now (direct)
+  seed [-orders N] [-customers N] [-days N]         insert demo orders; needs SEED_ENABLED=true (direct)
+       [-fixtures FILE] [-tenant ID] [-seed N]
+
+Flags:
+`
+
+func main() {
+	log.SetFlags(0)
+	apiURL := flag.String("api", getEnv("ORDERCTL_API_URL", "http://localhost:8080"), "order service base URL")
+	token := flag.String("token", os.Getenv("ADMIN_API_TOKEN"), "admin API token")
+	direct := flag.Bool("direct", false, "connect to the database and brokers instead of the API")
+	wait := flag.Duration("wait", 5*time.Second, "how long to wait for dependencies with -direct")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cmd, args := flag.Arg(0), flag.Args()[1:]
+	if !apiCommands[cmd] && !directOnly[cmd] {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	var err error
+	if *direct || directOnly[cmd] {
+		err = runDirect(ctx, *wait, cmd, args)
+	} else {
+		err = runAPI(&apiClient{baseURL: *apiURL, token: *token}, cmd, args)
+	}
+	if errors.Is(err, errUsage) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("orderctl %s: %v", cmd, err)
+	}
+}
+
+var errUsage = errors.New("invalid usage")
+
+var apiCommands = map[string]bool{"create": true, "get": true, "replay": true}
+
+var directOnly = map[string]bool{"migrate": true, "invalidate-cache": true, "drain-outbox": true, "retention": true, "seed": true}
+
+func runAPI(c *apiClient, cmd string, args []string) error {
+	switch cmd {
+	case "create":
+		req, err := parseCreate(args)
+		if err != nil {
+			return err
+		}
+		return c.do("POST", "/orders", req)
+	case "get":
+		id, err := singleArg(args)
+		if err != nil {
+			return err
+		}
+		return c.do("GET", "/orders/"+id, nil)
+	case "replay":
+		id, err := singleArg(args)
+		if err != nil {
+			return err
+		}
+		return c.do("POST", "/admin/orders/"+id+"/replay", nil)
+	}
+	return errUsage
+}
+
+func runDirect(ctx context.Context, wait time.Duration, cmd string, args []string) error {
+	var seedCfg seed.Config
+	if cmd == "seed" {
+		// Parse and load fixtures before connecting, so mistakes fail fast.
+		var err error
+		if seedCfg, err = parseSeed(args); err != nil {
+			return err
+		}
+	}
+	a, err := app.New(ctx, app.WithStartupWait(wait))
+	if err != nil {
+		return err
+	}
+	defer a.Stop(ctx)
+
+	switch cmd {
+	case "migrate":
+		// app.New has already migrated.
+		log.Println("Migrations applied")
+		return nil
+	case "create":
+		req, err := parseCreate(args)
+		if err != nil {
+			return err
+		}
+		order, err := a.Orders.CreateOrder(ctx, req)
+		if err != nil {
+			return err
+		}
+		return printJSON(order)
+	case "get":
+		id, err := singleArg(args)
+		if err != nil {
+			return err
+		}
+		order, err := a.Orders.GetOrder(ctx, id)
+		if err != nil {
+			return err
+		}
+		return printJSON(order)
+	case "replay":
+		id, err := singleArg(args)
+		if err != nil {
+			return err
+		}
+		pattern, err := a.Orders.ReplayEvent(ctx, id)
+		if err != nil {
+			return err
+		}
+		recordAudit(ctx, a, audit.ActionOrderReplay, id, map[string]string{"pattern": pattern})
+		log.Printf("Published %s for order %s", pattern, id)
+		return nil
+	case "invalidate-cache":
+		if len(args) == 0 {
+			return errUsage
+		}
+		cache := repository.NewOrderCache(a.CacheRedis)
+		for _, productID := range args {
+			if err := cache.Delete(cache.GetCacheKeyForProduct(productID)); err != nil {
+				return err
+			}
+			recordAudit(ctx, a, audit.ActionCacheInvalidate, productID, nil)
+		}
+		log.Printf("Invalidated %d cache keys", len(args))
+		return nil
+	case "drain-outbox":
+		if a.Rabbit.IsClosed() {
+			return errors.New("RabbitMQ is not reachable")
+		}
+		sent, err := a.DrainOutbox(ctx)
+		recordAudit(ctx, a, audit.ActionOutboxDrain, "", map[string]int{"published": sent})
+		log.Printf("Published %d outbox messages", sent)
+		return err
+	case "retention":
+		fs := flag.NewFlagSet("retention", flag.ContinueOnError)
+		dryRun := fs.Bool("dry-run", false, "only report what would change")
+		if err := fs.Parse(args); err != nil {
+			return errUsage
+		}
+		report, err := a.Retention.Run(ctx, *dryRun)
+		return errors.Join(printJSON(report), err)
+	case "seed":
+		orders, err := seed.Generate(seedCfg, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := a.Repo.CreateBatch(orders); err != nil {
+			return err
+		}
+		counts := seed.Count(orders)
+		recordAudit(ctx, a, audit.ActionOrderSeed, seedCfg.TenantID, counts)
+		log.Printf("Inserted %d demo orders (seed %d)", len(orders), seedCfg.Seed)
+		return printJSON(counts)
+	}
+	return errUsage
+}
+
+// parseSeed reads the seed flags. Seeding writes demo data into whatever
+// database the environment points at, so it is refused unless
+// SEED_ENABLED=true.
+func parseSeed(args []string) (seed.Config, error) {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	cfg := seed.Config{
+		ShippingFee: getEnvFloat("SHIPPING_FEE", 0),
+		TaxRate:     getEnvFloat("TAX_RATE", 0),
+	}
+	fs.IntVar(&cfg.Orders, "orders", 1000, "number of orders")
+	fs.IntVar(&cfg.Customers, "customers", 100, "number of customers")
+	fs.IntVar(&cfg.Days, "days", 30, "spread orders over this many days before now")
+	fixtures := fs.String("fixtures", "cmd/stub-product-service/fixtures.json", "products to order, in the stub product-service format")
+	fs.StringVar(&cfg.TenantID, "tenant", "", "tenant of the orders")
+	fs.Int64Var(&cfg.Seed, "seed", 0, "random seed to generate the same orders again; 0 picks one")
+	if err := fs.Parse(args); err != nil {
+		return cfg, errUsage
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	if os.Getenv("SEED_ENABLED") != "true" {
+		return cfg, errors.New("seeding is disabled; set SEED_ENABLED=true on development databases only")
+	}
+
+	var err error
+	if cfg.Products, err = productstub.LoadFile(*fixtures); err != nil {
+		return cfg, err
+	}
+	if cfg.DiscountCodes, err = service.ParseDiscountCodes(os.Getenv("DISCOUNT_CODES")); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// recordAudit records a direct command as done by the local user. Like the
+// API, a failure to record does not fail the command.
+func recordAudit(ctx context.Context, a *app.App, action, target string, after interface{}) {
+	err := a.Audit.Record(ctx, &audit.Entry{
+		Actor:  "orderctl:" + getEnv("USER", "unknown"),
+		Action: action,
+		Target: target,
+		After:  after,
+	})
+	if err != nil {
+		log.Printf("Failed to record audit entry: %v", err)
+	}
+}
+
+func parseCreate(args []string) (service.CreateOrderRequest, error) {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	var req service.CreateOrderRequest
+	fs.StringVar(&req.ProductID, "product", "", "product ID")
+	fs.IntVar(&req.Quantity, "quantity", 1, "quantity")
+	fs.StringVar(&req.CustomerID, "customer", "", "customer ID")
+	if err := fs.Parse(args); err != nil {
+		return req, errUsage
+	}
+	if req.ProductID == "" {
+		return req, fmt.Errorf("%w: -product is required", errUsage)
+	}
+	return req, nil
+}
+
+func singleArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errUsage
+	}
+	return args[0], nil
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	v, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
@@ -2,69 +2,73 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"flag"
 	"log"
-	"net/http"
-	"order-service/internal/handler"
-	"order-service/internal/repository"
-	"order-service/internal/service"
+	"log/slog"
+	"order-service/internal/app"
+	"order-service/internal/podinfo"
 	"os"
-
-	"github.com/gin-gonic/gin"
-	"github.com/go-redis/redis/v8"
-	"github.com/streadway/amqp"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		os.Getenv("DATABASE_HOST"),
-		os.Getenv("DATABASE_USER"),
-		os.Getenv("DATABASE_PASSWORD"),
-		os.Getenv("DATABASE_NAME"),
-		os.Getenv("DATABASE_PORT"),
-	)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	mode := flag.String("mode", "all", "what to run: api (HTTP API), worker (consumers, outbox relay and scheduled jobs), all, or migrate (migrate the database and exit)")
+	addr := flag.String("addr", ":8080", "listen address; worker mode only serves /metrics and /readyz")
+	skipMigrations := flag.Bool("skip-migrations", false, "do not migrate the database; wait until another instance or a migration job has")
+	flag.Parse()
+	if *mode != "api" && *mode != "worker" && *mode != "all" && *mode != "migrate" {
+		log.Fatalf("Unknown mode %q, expected api, worker, all or migrate", *mode)
 	}
-	db.AutoMigrate(&repository.Order{})
-
-	redisAddr := fmt.Sprintf("%s:%s", os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT"))
-	rdb := redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
-	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+	if *mode == "migrate" && *skipMigrations {
+		log.Fatal("--skip-migrations cannot be used in migrate mode")
 	}
 
-	rabbitURL := os.Getenv("RABBITMQ_URL")
-	conn, err := amqp.Dial(rabbitURL)
-	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %v", err)
-	}
-	defer conn.Close()
+	logLevel := new(slog.LevelVar)
+	instance := podinfo.FromEnv()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: logLevel,
+	})).With(instance.LogAttrs()...))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	ch, err := conn.Channel()
+	opts := []app.Option{app.WithLogLevel(logLevel), app.WithInstance(instance)}
+	if *skipMigrations {
+		opts = append(opts, app.WithSkipMigrations())
+	}
+	a, err := app.New(ctx, opts...)
 	if err != nil {
-		log.Fatalf("Failed to open a channel: %v", err)
+		log.Fatalf("Failed to initialize: %v", err)
 	}
-	defer ch.Close()
-
-	productServiceURL := os.Getenv("PRODUCT_SERVICE_URL")
-	repo := repository.NewOrderRepository(db)
-	cache := repository.NewOrderCache(rdb)
-	publisher := service.NewRabbitMQPublisher(ch)
-	orderService := service.NewOrderService(repo, cache, publisher, productServiceURL)
-	orderHandler := handler.NewOrderHandler(orderService)
-
-	router := gin.Default()
-	router.POST("/orders", orderHandler.CreateOrder)
-	router.GET("/orders/product/:productId", orderHandler.GetOrdersByProductID)
-
-	log.Println("Order service is running on :8080")
-	if err := http.ListenAndServe(":8080", router); err != nil {
+	if *mode == "migrate" {
+		// New migrated the database.
+		if err := a.Stop(context.Background()); err != nil {
+			log.Printf("Failed to close connections: %v", err)
+		}
+		log.Println("Database migrated")
+		return
+	}
+	if addr := os.Getenv("PROBE_ADDR"); addr != "" {
+		a.AddProbeServer(addr)
+	}
+	if *mode == "worker" {
+		a.AddOpsServer(*addr)
+	} else if err := a.AddHTTPServer(*addr); err != nil {
+		log.Fatalf("Failed to configure HTTP server: %v", err)
+	}
+	if *mode != "api" {
+		a.AddConsumers()
+		a.AddWorkers()
+	}
+	if err := a.Start(ctx); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
-}
\ No newline at end of file
+	log.Printf("Order service is running in %s mode on %s", *mode, *addr)
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+	if err := a.Terminate(); err != nil {
+		log.Printf("Shutdown incomplete: %v", err)
+	}
+}
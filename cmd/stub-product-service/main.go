@@ -0,0 +1,35 @@
+// Command stub-product-service stands in for product-service during local
+// development and end-to-end tests. It serves the products of a fixtures
+// file, which can be changed while it runs with PUT and DELETE
+// /products/{id}.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"order-service/internal/productstub"
+)
+
+func main() {
+	addr := flag.String("addr", ":8081", "listen address")
+	fixtures := flag.String("fixtures", "cmd/stub-product-service/fixtures.json", "JSON array of products to serve; empty starts with no products")
+	flag.Parse()
+
+	var products []productstub.Product
+	if *fixtures != "" {
+		var err error
+		if products, err = productstub.LoadFile(*fixtures); err != nil {
+			log.Fatalf("Failed to load fixtures: %v", err)
+		}
+	}
+	catalog, err := productstub.NewCatalog(products)
+	if err != nil {
+		log.Fatalf("Failed to load fixtures: %v", err)
+	}
+
+	log.Printf("Stub product-service serving %d products on %s", len(products), *addr)
+	if err := http.ListenAndServe(*addr, catalog.Handler()); err != nil {
+		log.Fatal(err)
+	}
+}
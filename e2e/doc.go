@@ -0,0 +1,7 @@
+// Package e2e holds end-to-end tests that boot the real server binary
+// against Postgres, Redis and RabbitMQ and a stub product-service, and
+// drive it over HTTP. They only build with the e2e tag:
+//
+//	docker compose --profile e2e up -d
+//	go test -tags e2e ./e2e/...
+package e2e
@@ -0,0 +1,187 @@
+// Package acceptance evaluates configurable business rules an order must
+// pass before it is accepted, such as a maximum order value or the regions
+// a tenant delivers to.
+package acceptance
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Rule kinds.
+const (
+	// KindMaxOrderValue refuses orders whose total exceeds Max. With
+	// Currency set only orders priced in that currency are checked.
+	KindMaxOrderValue = "max_order_value"
+	// KindAllowedCategories only accepts products in one of Values.
+	KindAllowedCategories = "allowed_categories"
+	// KindAllowedRegions only accepts orders shipped to one of Values.
+	KindAllowedRegions = "allowed_regions"
+	// KindBlockedRegions refuses orders shipped to any of Values.
+	KindBlockedRegions = "blocked_regions"
+)
+
+// Rule is one acceptance condition. Tenants limits it to those tenants;
+// empty applies it to every order.
+type Rule struct {
+	Name     string   `json:"name"`
+	Kind     string   `json:"kind"`
+	Max      float64  `json:"max,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+	Values   []string `json:"values,omitempty"`
+	Tenants  []string `json:"tenants,omitempty"`
+}
+
+// Order is what the rules look at.
+type Order struct {
+	TenantID  string
+	ProductID string
+	Category  string
+	Region    string
+	Total     float64
+	Currency  string
+}
+
+// Result is the outcome of one rule. Rules that do not apply to the order
+// pass with Applied false.
+type Result struct {
+	Rule    string `json:"rule"`
+	Kind    string `json:"kind"`
+	Applied bool   `json:"applied"`
+	Passed  bool   `json:"passed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Evaluation is the outcome of every rule, in configuration order.
+type Evaluation struct {
+	Accepted bool     `json:"accepted"`
+	Results  []Result `json:"results"`
+}
+
+// Failed returns the first failing result, or nil.
+func (e Evaluation) Failed() *Result {
+	for i := range e.Results {
+		if !e.Results[i].Passed {
+			return &e.Results[i]
+		}
+	}
+	return nil
+}
+
+// Parse reads a JSON array of rules.
+func Parse(data string) ([]Rule, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var rules []Rule
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse acceptance rules: %w", err)
+	}
+	return rules, Validate(rules)
+}
+
+// Validate checks that every rule has a unique name, a known kind and the
+// settings its kind needs.
+func Validate(rules []Rule) error {
+	seen := map[string]bool{}
+	for i, r := range rules {
+		if r.Name == "" {
+			return fmt.Errorf("acceptance rule %d has no name", i)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("acceptance rule %s is defined twice", r.Name)
+		}
+		seen[r.Name] = true
+		switch r.Kind {
+		case KindMaxOrderValue:
+			if r.Max <= 0 {
+				return fmt.Errorf("acceptance rule %s needs a positive max", r.Name)
+			}
+		case KindAllowedCategories, KindAllowedRegions, KindBlockedRegions:
+			if len(r.Values) == 0 {
+				return fmt.Errorf("acceptance rule %s needs values", r.Name)
+			}
+		default:
+			return fmt.Errorf("acceptance rule %s has unknown kind %q", r.Name, r.Kind)
+		}
+	}
+	return nil
+}
+
+// Engine holds the current rules. Rules can be replaced while orders are
+// being evaluated.
+type Engine struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+func NewEngine(rules []Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// SetRules replaces the rules; callers validate them first.
+func (e *Engine) SetRules(rules []Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+// Evaluate runs every rule against the order.
+func (e *Engine) Evaluate(o Order) Evaluation {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	ev := Evaluation{Accepted: true, Results: make([]Result, 0, len(rules))}
+	for _, r := range rules {
+		res := r.evaluate(o)
+		if !res.Passed {
+			ev.Accepted = false
+		}
+		ev.Results = append(ev.Results, res)
+	}
+	return ev
+}
+
+func (r Rule) evaluate(o Order) Result {
+	res := Result{Rule: r.Name, Kind: r.Kind, Passed: true}
+	if len(r.Tenants) > 0 && !slices.Contains(r.Tenants, o.TenantID) {
+		res.Reason = "not applicable to tenant"
+		return res
+	}
+	res.Applied = true
+
+	switch r.Kind {
+	case KindMaxOrderValue:
+		if r.Currency != "" && !strings.EqualFold(r.Currency, o.Currency) {
+			res.Applied = false
+			res.Reason = "not applicable to " + o.Currency
+		} else if o.Total > r.Max {
+			res.Passed = false
+			res.Reason = fmt.Sprintf("order total %.2f exceeds %.2f", o.Total, r.Max)
+		}
+	case KindAllowedCategories:
+		if !containsFold(r.Values, o.Category) {
+			res.Passed = false
+			res.Reason = fmt.Sprintf("product category %q is not allowed", o.Category)
+		}
+	case KindAllowedRegions:
+		if !containsFold(r.Values, o.Region) {
+			res.Passed = false
+			res.Reason = fmt.Sprintf("region %q is not served", o.Region)
+		}
+	case KindBlockedRegions:
+		if o.Region != "" && containsFold(r.Values, o.Region) {
+			res.Passed = false
+			res.Reason = fmt.Sprintf("region %q is restricted", o.Region)
+		}
+	}
+	return res
+}
+
+func containsFold(values []string, v string) bool {
+	return v != "" && slices.ContainsFunc(values, func(s string) bool { return strings.EqualFold(s, v) })
+}
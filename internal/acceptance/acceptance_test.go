@@ -0,0 +1,68 @@
+package acceptance
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	rules, err := Parse(`[
+		{"name": "cap", "kind": "max_order_value", "max": 1000, "currency": "IDR"},
+		{"name": "food-only", "kind": "allowed_categories", "values": ["food"], "tenants": ["acme"]},
+		{"name": "java", "kind": "allowed_regions", "values": ["JK", "JB"], "tenants": ["acme"]},
+		{"name": "no-papua", "kind": "blocked_regions", "values": ["PA"]}
+	]`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	e := NewEngine(rules)
+
+	tests := []struct {
+		name   string
+		order  Order
+		failed string
+	}{
+		{"accepted", Order{TenantID: "acme", Category: "Food", Region: "jk", Total: 500, Currency: "IDR"}, ""},
+		{"too expensive", Order{Total: 1500, Currency: "IDR"}, "cap"},
+		{"cap in another currency", Order{Total: 1500, Currency: "USD"}, ""},
+		{"wrong category", Order{TenantID: "acme", Category: "toys", Region: "JK"}, "food-only"},
+		{"region outside java", Order{TenantID: "acme", Category: "food", Region: "BA"}, "java"},
+		{"no region", Order{TenantID: "acme", Category: "food"}, "java"},
+		{"other tenant", Order{TenantID: "other", Category: "toys"}, ""},
+		{"blocked region", Order{Region: "PA"}, "no-papua"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := e.Evaluate(tt.order)
+			if len(ev.Results) != len(rules) {
+				t.Fatalf("Expected a result per rule, got %+v", ev.Results)
+			}
+			failed := ev.Failed()
+			switch {
+			case tt.failed == "" && failed != nil:
+				t.Errorf("Expected the order to be accepted, %s failed: %s", failed.Rule, failed.Reason)
+			case tt.failed != "" && (failed == nil || failed.Rule != tt.failed):
+				t.Errorf("Expected %s to fail, got %+v", tt.failed, ev.Results)
+			}
+			if ev.Accepted != (failed == nil) {
+				t.Errorf("Expected Accepted to match the results, got %+v", ev)
+			}
+		})
+	}
+
+	e.SetRules(nil)
+	if ev := e.Evaluate(Order{Region: "PA"}); !ev.Accepted {
+		t.Errorf("Expected no rules to accept everything, got %+v", ev)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, data := range []string{
+		`[{"kind": "max_order_value", "max": 1}]`,
+		`[{"name": "a", "kind": "max_order_value"}]`,
+		`[{"name": "a", "kind": "allowed_regions"}]`,
+		`[{"name": "a", "kind": "max_order_value", "max": 1}, {"name": "a", "kind": "max_order_value", "max": 2}]`,
+		`[{"name": "a", "kind": "min_order_value", "max": 1}]`,
+	} {
+		if _, err := Parse(data); err == nil {
+			t.Errorf("Expected an error for %s", data)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Package adminui serves a read-only HTML dashboard for on-call triage.
+package adminui
+
+import (
+	"context"
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+	"order-service/internal/repository"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed templates/*.html
+var templates embed.FS
+
+var page = template.Must(template.New("dashboard.html").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04:05") },
+}).ParseFS(templates, "templates/dashboard.html"))
+
+const (
+	recentOrders = 50
+	statusWindow = 24 * time.Hour
+)
+
+type IOrderReader interface {
+	Search(ctx context.Context, filter repository.OrderFilter, limit, offset int) ([]repository.Order, error)
+	CountByStatus(ctx context.Context, since time.Time) (map[string]int64, error)
+}
+
+type IOutboxCounter interface {
+	Count(ctx context.Context) (int64, error)
+}
+
+type IQueueInspector interface {
+	QueueDepth(name string) (messages, consumers int, err error)
+}
+
+// Dashboard renders recent orders, order counts per status, the outbox
+// backlog and the depth of the queues the service consumes.
+type Dashboard struct {
+	orders IOrderReader
+	outbox IOutboxCounter
+	queues IQueueInspector
+	names  []string
+}
+
+func New(orders IOrderReader, outbox IOutboxCounter, queues IQueueInspector, queueNames []string) *Dashboard {
+	return &Dashboard{orders: orders, outbox: outbox, queues: queues, names: queueNames}
+}
+
+type statusCount struct {
+	Status string
+	Count  int64
+}
+
+type queueStatus struct {
+	Name      string
+	Messages  int
+	Consumers int
+	Error     string
+}
+
+type view struct {
+	GeneratedAt time.Time
+	Orders      []repository.Order
+	Statuses    []statusCount
+	Outbox      int64
+	Queues      []queueStatus
+	Errors      []string
+}
+
+// Show answers GET /admin/ui. A failing section is reported on the page
+// instead of failing the whole page.
+func (d *Dashboard) Show(c *gin.Context) {
+	ctx := c.Request.Context()
+	v := view{GeneratedAt: time.Now()}
+	var err error
+
+	if v.Orders, err = d.orders.Search(ctx, repository.OrderFilter{}, recentOrders, 0); err != nil {
+		v.Errors = append(v.Errors, "recent orders: "+err.Error())
+	}
+	counts, err := d.orders.CountByStatus(ctx, v.GeneratedAt.Add(-statusWindow))
+	if err != nil {
+		v.Errors = append(v.Errors, "status counts: "+err.Error())
+	}
+	for status, n := range counts {
+		v.Statuses = append(v.Statuses, statusCount{Status: status, Count: n})
+	}
+	sort.Slice(v.Statuses, func(i, j int) bool { return v.Statuses[i].Status < v.Statuses[j].Status })
+	if v.Outbox, err = d.outbox.Count(ctx); err != nil {
+		v.Errors = append(v.Errors, "outbox: "+err.Error())
+	}
+	for _, name := range d.names {
+		q := queueStatus{Name: name}
+		if q.Messages, q.Consumers, err = d.queues.QueueDepth(name); err != nil {
+			q.Error = err.Error()
+		}
+		v.Queues = append(v.Queues, q)
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := page.Execute(c.Writer, v); err != nil {
+		log.Printf("Failed to render admin dashboard: %v", err)
+	}
+}
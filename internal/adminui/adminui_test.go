@@ -0,0 +1,100 @@
+package adminui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"order-service/internal/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeOrders struct {
+	orders    []repository.Order
+	counts    map[string]int64
+	searchErr error
+	since     time.Time
+}
+
+func (f *fakeOrders) Search(_ context.Context, _ repository.OrderFilter, _, _ int) ([]repository.Order, error) {
+	return f.orders, f.searchErr
+}
+
+func (f *fakeOrders) CountByStatus(_ context.Context, since time.Time) (map[string]int64, error) {
+	f.since = since
+	return f.counts, nil
+}
+
+type fakeOutbox struct{ pending int64 }
+
+func (f fakeOutbox) Count(context.Context) (int64, error) { return f.pending, nil }
+
+type fakeBroker map[string][2]int
+
+func (f fakeBroker) QueueDepth(name string) (int, int, error) {
+	depth, ok := f[name]
+	if !ok {
+		return 0, 0, errors.New("queue not found")
+	}
+	return depth[0], depth[1], nil
+}
+
+func render(t *testing.T, d *Dashboard) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin/ui", d.Show)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/ui", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("Expected an HTML page, got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	return w.Body.String()
+}
+
+func TestShow(t *testing.T) {
+	t.Run("renders every section", func(t *testing.T) {
+		orders := &fakeOrders{
+			orders: []repository.Order{{
+				ID: "o1", ProductID: "p1", CustomerID: "<c1>", Quantity: 2, TotalPrice: 20.5, Currency: "USD",
+				Status: repository.StatusOnHold, HoldReason: "fraud score", CreatedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+			}},
+			counts: map[string]int64{repository.StatusPaid: 7, repository.StatusPending: 3},
+		}
+		body := render(t, New(orders, fakeOutbox{pending: 12}, fakeBroker{"stock": {4, 2}}, []string{"stock", "payments"}))
+
+		for _, want := range []string{
+			`<td>2026-10-01 12:00:00</td><td>o1</td><td>p1</td><td>&lt;c1&gt;</td><td class="num">2</td><td class="num">20.50 USD</td><td>ON_HOLD</td><td>fraud score</td>`,
+			`<tr><td>PAID</td><td class="num">7</td></tr>`,
+			`<tr><td class="num">12</td></tr>`,
+			`<tr><td>stock</td><td class="num">4</td><td class="num">2</td></tr>`,
+			`<tr><td>payments</td><td colspan="2" class="error">queue not found</td></tr>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected the page to contain %s, got %s", want, body)
+			}
+		}
+		if strings.Index(body, ">PAID<") > strings.Index(body, ">PENDING<") {
+			t.Error("Expected statuses in alphabetical order")
+		}
+		if since := time.Since(orders.since); since < statusWindow || since > statusWindow+time.Minute {
+			t.Errorf("Expected counts over the last %s, got since %s", statusWindow, orders.since)
+		}
+	})
+
+	t.Run("a failing section is reported on the page", func(t *testing.T) {
+		orders := &fakeOrders{searchErr: errors.New("database is down")}
+		body := render(t, New(orders, fakeOutbox{}, fakeBroker{}, nil))
+
+		if !strings.Contains(body, `<p class="error">recent orders: database is down</p>`) {
+			t.Errorf("Expected the error on the page, got %s", body)
+		}
+		if strings.Count(body, `<td colspan="8">No orders</td>`) != 1 {
+			t.Errorf("Expected an empty orders table, got %s", body)
+		}
+	})
+}
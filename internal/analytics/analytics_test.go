@@ -0,0 +1,127 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+type fakeStore struct {
+	puts map[string]string
+	err  error
+}
+
+func (s *fakeStore) Put(_ context.Context, key string, body []byte, _ string) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.puts == nil {
+		s.puts = map[string]string{}
+	}
+	s.puts[key] = string(body)
+	return nil
+}
+
+func TestSpool(t *testing.T) {
+	db, mock := mockDB(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery(quoted(`INSERT INTO "analytics_events"`)).
+		WithArgs(sqlmock.AnyArg(), "order.created", `{"id":"o1"}`, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"seq"}).AddRow(1))
+	mock.ExpectCommit()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return Spool(tx, "order.created", map[string]string{"id": "o1"})
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExportPending(t *testing.T) {
+	occurred := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	eventRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"seq", "id", "type", "payload", "occurred_at"}).
+			AddRow(3, "e3", "order.created", `{"ID":"o1"}`, occurred).
+			AddRow(4, "e4", "order.activated", `{"ID":"o2"}`, occurred)
+	}
+
+	t.Run("uploads the batch and moves the checkpoint", func(t *testing.T) {
+		db, mock := mockDB(t)
+		store := &fakeStore{}
+		e := NewExporter(db, store, ExporterConfig{Prefix: "orders/", BatchSize: 10})
+
+		mock.ExpectQuery(quoted(`SELECT * FROM "analytics_checkpoints"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "seq"}).AddRow(checkpointName, 2))
+		mock.ExpectQuery(quoted(`SELECT * FROM "analytics_events" WHERE seq >`)).
+			WithArgs(int64(2), sqlmock.AnyArg(), 10).
+			WillReturnRows(eventRows())
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`INSERT INTO "analytics_checkpoints"`)).
+			WithArgs(checkpointName, int64(4), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := e.ExportPending(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		body, ok := store.puts["orders/dt=2026-10-01/events-00000000000000000003-00000000000000000004.ndjson"]
+		if !ok {
+			t.Fatalf("Expected the batch under its bounds, got %v", store.puts)
+		}
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		if len(lines) != 2 || !strings.Contains(lines[0], `"data":{"ID":"o1"}`) {
+			t.Errorf("Expected one line per event with its data, got %q", body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("a failed upload keeps the checkpoint", func(t *testing.T) {
+		db, mock := mockDB(t)
+		e := NewExporter(db, &fakeStore{err: errors.New("unavailable")}, ExporterConfig{BatchSize: 10})
+
+		mock.ExpectQuery(quoted(`SELECT * FROM "analytics_checkpoints"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"name", "seq"}))
+		mock.ExpectQuery(quoted(`SELECT * FROM "analytics_events" WHERE seq >`)).
+			WithArgs(int64(0), sqlmock.AnyArg(), 10).
+			WillReturnRows(eventRows())
+
+		if err := e.ExportPending(context.Background()); err == nil {
+			t.Fatal("Expected the upload error")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package analytics
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event is a spooled analytics event waiting to be exported. Seq orders
+// events for checkpointing.
+type Event struct {
+	Seq        int64     `gorm:"primaryKey;autoIncrement" json:"-"`
+	ID         string    `gorm:"type:uuid;not null;uniqueIndex" json:"id"`
+	Type       string    `gorm:"not null" json:"type"`
+	Payload    string    `gorm:"type:jsonb;not null" json:"-"`
+	OccurredAt time.Time `gorm:"not null;index" json:"occurredAt"`
+}
+
+func (Event) TableName() string { return "analytics_events" }
+
+// MarshalJSON inlines the payload so exported lines are plain JSON objects.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type alias Event
+	return json.Marshal(struct {
+		alias
+		Data json.RawMessage `json:"data"`
+	}{alias(e), json.RawMessage(e.Payload)})
+}
+
+// Checkpoint records how far an exporter has shipped the event log.
+type Checkpoint struct {
+	Name      string `gorm:"primaryKey"`
+	Seq       int64  `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func (Checkpoint) TableName() string { return "analytics_checkpoints" }
+
+// Spool adds an event in tx, the transaction that stores the change it
+// reports, so the event is exported if and only if the change is committed.
+func Spool(tx *gorm.DB, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		Payload:    string(payload),
+		OccurredAt: time.Now().UTC(),
+	}).Error
+}
@@ -0,0 +1,144 @@
+package analytics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const checkpointName = "warehouse"
+
+// commitLag keeps the exporter away from the tail of the log, where events
+// with a lower Seq may still be committing in other transactions.
+const commitLag = 5 * time.Second
+
+type ExporterConfig struct {
+	Prefix    string
+	BatchSize int
+	Interval  time.Duration
+	Gzip      bool
+}
+
+// Exporter ships spooled events to an ObjectStore as newline-delimited JSON
+// files. The checkpoint only moves forward after a successful upload, so a
+// crash in between re-uploads the same batch under the same key.
+type Exporter struct {
+	db    *gorm.DB
+	store ObjectStore
+	cfg   ExporterConfig
+}
+
+func NewExporter(db *gorm.DB, store ObjectStore, cfg ExporterConfig) *Exporter {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	return &Exporter{db: db, store: store, cfg: cfg}
+}
+
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.ExportPending(ctx); err != nil {
+				log.Printf("Analytics export failed: %v", err)
+			}
+		}
+	}
+}
+
+// ExportPending uploads batches until the log is drained.
+func (e *Exporter) ExportPending(ctx context.Context) error {
+	for {
+		n, err := e.exportBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if n < e.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+func (e *Exporter) exportBatch(ctx context.Context) (int, error) {
+	db := e.db.WithContext(ctx)
+
+	var cp Checkpoint
+	err := db.Where("name = ?", checkpointName).First(&cp).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	var events []Event
+	err = db.Where("seq > ? AND occurred_at < ?", cp.Seq, time.Now().UTC().Add(-commitLag)).
+		Order("seq").
+		Limit(e.cfg.BatchSize).
+		Find(&events).Error
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+
+	body, contentType, err := e.encode(events)
+	if err != nil {
+		return 0, err
+	}
+	first, last := events[0], events[len(events)-1]
+	if err := e.store.Put(ctx, e.objectKey(first, last), body, contentType); err != nil {
+		return 0, fmt.Errorf("failed to upload batch: %w", err)
+	}
+
+	err = db.Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&Checkpoint{Name: checkpointName, Seq: last.Seq, UpdatedAt: time.Now()}).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return len(events), nil
+}
+
+func (e *Exporter) encode(events []Event) ([]byte, string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return nil, "", err
+		}
+	}
+	if !e.cfg.Gzip {
+		return buf.Bytes(), "application/x-ndjson", nil
+	}
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return gz.Bytes(), "application/gzip", nil
+}
+
+// objectKey is derived from the batch bounds so retries overwrite the same
+// object instead of duplicating it.
+func (e *Exporter) objectKey(first, last Event) string {
+	ext := ".ndjson"
+	if e.cfg.Gzip {
+		ext += ".gz"
+	}
+	return fmt.Sprintf("%sdt=%s/events-%020d-%020d%s",
+		e.cfg.Prefix, first.OccurredAt.Format("2006-01-02"), first.Seq, last.Seq, ext)
+}
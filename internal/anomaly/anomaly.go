@@ -0,0 +1,235 @@
+// Package anomaly flags products whose order rate jumps far above its
+// recent level, as bot attacks or pricing mistakes do. Orders are counted
+// per product in fixed buckets in Redis; the current bucket is compared
+// with the buckets before it.
+package anomaly
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"order-service/internal/metrics"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const DetectedPattern = "order.anomaly_detected"
+
+const (
+	rateKeyPrefix = "anomaly:rate:"
+	activeKey     = "anomaly:active"
+)
+
+// Reasons an order rate is flagged.
+const (
+	ReasonZScore    = "zscore"
+	ReasonThreshold = "threshold"
+)
+
+// Config tunes the detection.
+type Config struct {
+	// Bucket is how long orders are counted together.
+	Bucket time.Duration
+	// History is how many buckets before the current one it is compared
+	// with.
+	History int
+	// ZScore is how many standard deviations above the mean of the
+	// history the current bucket must be.
+	ZScore float64
+	// MinOrders is the fewest orders in a bucket that can be flagged, so
+	// quiet products are not flagged for a handful of orders.
+	MinOrders int64
+	// MaxOrders flags any bucket with at least this many orders whatever
+	// the history. 0 disables it.
+	MaxOrders int64
+	// Cooldown is how long a flagged product stays flagged and is not
+	// announced again.
+	Cooldown time.Duration
+}
+
+// DefaultConfig compares minutes with the half hour before them.
+var DefaultConfig = Config{Bucket: time.Minute, History: 30, ZScore: 4, MinOrders: 20, Cooldown: 15 * time.Minute}
+
+// Anomaly is a product whose order rate was flagged.
+type Anomaly struct {
+	ProductID  string    `json:"productId"`
+	Reason     string    `json:"reason"`
+	Orders     int64     `json:"orders"`
+	Mean       float64   `json:"mean"`
+	StdDev     float64   `json:"stdDev"`
+	ZScore     float64   `json:"zScore"`
+	Bucket     string    `json:"bucket"`
+	DetectedAt time.Time `json:"detectedAt"`
+	Until      time.Time `json:"until"`
+}
+
+type IPublisher interface {
+	Publish(pattern string, data interface{}) error
+}
+
+// flagScript stores the anomaly ARGV[2] for ARGV[1] in the hash KEYS[1]
+// unless the product is already flagged until after ARGV[3], the current
+// time in milliseconds. ARGV[4] is when the new flag ends.
+var flagScript = redis.NewScript(`
+local until = redis.call("HGET", KEYS[1], ARGV[1] .. ":until")
+if until and tonumber(until) > tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2], ARGV[1] .. ":until", ARGV[4])
+return 1
+`)
+
+// Detector counts orders and flags anomalous rates.
+type Detector struct {
+	client    *redis.Client
+	cfg       Config
+	publisher IPublisher
+	now       func() time.Time
+}
+
+func New(client *redis.Client, cfg Config, publisher IPublisher) *Detector {
+	return &Detector{client: client, cfg: cfg, publisher: publisher, now: time.Now}
+}
+
+func rateKey(productID string, bucket int64) string {
+	return rateKeyPrefix + productID + ":" + strconv.FormatInt(bucket, 10)
+}
+
+// Record counts an order of productID and flags the product if its rate
+// is anomalous. A newly flagged product is published as
+// order.anomaly_detected.
+func (d *Detector) Record(ctx context.Context, productID string) error {
+	now := d.now().UTC()
+	bucket := now.UnixNano() / int64(d.cfg.Bucket)
+	key := rateKey(productID, bucket)
+	pipe := d.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.PExpire(ctx, key, time.Duration(d.cfg.History+2)*d.cfg.Bucket)
+	var previous *redis.SliceCmd
+	if d.cfg.History > 0 {
+		keys := make([]string, d.cfg.History)
+		for i := range keys {
+			keys[i] = rateKey(productID, bucket-int64(i)-1)
+		}
+		previous = pipe.MGet(ctx, keys...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	history := make([]int64, d.cfg.History)
+	if previous != nil {
+		for i, v := range previous.Val() {
+			if s, ok := v.(string); ok {
+				history[i], _ = strconv.ParseInt(s, 10, 64)
+			}
+		}
+	}
+	a, ok := d.cfg.evaluate(incr.Val(), history)
+	if !ok {
+		return nil
+	}
+	a.ProductID, a.DetectedAt, a.Until = productID, now, now.Add(d.cfg.Cooldown)
+	return d.flag(ctx, a)
+}
+
+// evaluate decides whether orders in the current bucket are anomalous
+// after history. The standard deviation is at least 1 so a product that
+// never sold is not flagged for any order at all.
+func (c Config) evaluate(orders int64, history []int64) (Anomaly, bool) {
+	var sum float64
+	for _, n := range history {
+		sum += float64(n)
+	}
+	a := Anomaly{Orders: orders, Bucket: c.Bucket.String()}
+	if len(history) > 0 {
+		a.Mean = sum / float64(len(history))
+		var sq float64
+		for _, n := range history {
+			sq += (float64(n) - a.Mean) * (float64(n) - a.Mean)
+		}
+		a.StdDev = math.Sqrt(sq / float64(len(history)))
+	}
+	a.ZScore = (float64(orders) - a.Mean) / math.Max(a.StdDev, 1)
+	a.Mean, a.StdDev, a.ZScore = round(a.Mean), round(a.StdDev), round(a.ZScore)
+	switch {
+	case c.MaxOrders > 0 && orders >= c.MaxOrders:
+		a.Reason = ReasonThreshold
+	case orders >= c.MinOrders && a.ZScore >= c.ZScore:
+		a.Reason = ReasonZScore
+	default:
+		return a, false
+	}
+	return a, true
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func (d *Detector) flag(ctx context.Context, a Anomaly) error {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	flagged, err := flagScript.Run(ctx, d.client, []string{activeKey}, a.ProductID, b, a.DetectedAt.UnixMilli(), a.Until.UnixMilli()).Int()
+	if err != nil || flagged == 0 {
+		return err
+	}
+	metrics.OrderAnomalies.WithLabelValues(a.Reason).Inc()
+	log.Printf("Order rate of product %s is anomalous: %d orders in %s, mean %.2f, z-score %.2f", a.ProductID, a.Orders, a.Bucket, a.Mean, a.ZScore)
+	if err := d.publisher.Publish(DetectedPattern, detectedData(a)); err != nil {
+		log.Printf("Failed to publish %s event for product %s: %v", DetectedPattern, a.ProductID, err)
+	}
+	return nil
+}
+
+func detectedData(a Anomaly) map[string]interface{} {
+	return map[string]interface{}{
+		"productId":  a.ProductID,
+		"reason":     a.Reason,
+		"orders":     a.Orders,
+		"bucket":     a.Bucket,
+		"mean":       a.Mean,
+		"stdDev":     a.StdDev,
+		"zScore":     a.ZScore,
+		"detectedAt": a.DetectedAt,
+	}
+}
+
+// Active lists the products flagged now, most recently flagged first.
+// Flags that ended are removed.
+func (d *Detector) Active(ctx context.Context) ([]Anomaly, error) {
+	fields, err := d.client.HGetAll(ctx, activeKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := d.now()
+	active := []Anomaly{}
+	var ended []string
+	for field, v := range fields {
+		if _, ok := fields[field+":until"]; !ok {
+			continue
+		}
+		var a Anomaly
+		if err := json.Unmarshal([]byte(v), &a); err != nil {
+			return nil, err
+		}
+		if !a.Until.After(now) {
+			ended = append(ended, field, field+":until")
+			continue
+		}
+		active = append(active, a)
+	}
+	if len(ended) > 0 {
+		if err := d.client.HDel(ctx, activeKey, ended...).Err(); err != nil {
+			log.Printf("Failed to remove ended order anomalies: %v", err)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].DetectedAt.After(active[j].DetectedAt) })
+	return active, nil
+}
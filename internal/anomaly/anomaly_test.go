@@ -0,0 +1,63 @@
+package anomaly
+
+import (
+	"order-service/internal/golden"
+	"order-service/internal/schema"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	cfg := Config{Bucket: time.Minute, ZScore: 4, MinOrders: 20, MaxOrders: 500}
+	steady := []int64{10, 12, 9, 11, 10, 8, 12, 10}
+
+	cases := []struct {
+		name    string
+		orders  int64
+		history []int64
+		reason  string
+	}{
+		{"normal", 12, steady, ""},
+		{"spike", 60, steady, ReasonZScore},
+		{"quiet product", 5, []int64{0, 0, 0, 0}, ""},
+		{"first burst", 25, []int64{0, 0, 0, 0}, ReasonZScore},
+		{"busy product", 1100, []int64{1000, 900, 1050, 950}, ReasonThreshold},
+		{"no history", 19, nil, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, ok := cfg.evaluate(tc.orders, tc.history)
+			if ok != (tc.reason != "") || a.Reason != tc.reason {
+				t.Errorf("Expected reason %q, got %q (flagged %t, %+v)", tc.reason, a.Reason, ok, a)
+			}
+		})
+	}
+
+	a, _ := cfg.evaluate(60, steady)
+	if a.Mean != 10.25 || a.StdDev != 1.3 || a.ZScore != 38.3 || a.Bucket != "1m0s" {
+		t.Errorf("Unexpected statistics %+v", a)
+	}
+}
+
+func TestDetectedPayload(t *testing.T) {
+	a := Anomaly{
+		ProductID:  "p1",
+		Reason:     ReasonZScore,
+		Orders:     60,
+		Mean:       10.25,
+		StdDev:     1.3,
+		ZScore:     38.3,
+		Bucket:     "1m0s",
+		DetectedAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	data := detectedData(a)
+	golden.JSON(t, "events/"+DetectedPattern, data)
+
+	schemas, err := schema.Load()
+	if err != nil {
+		t.Fatalf("Expected schemas to load, got %v", err)
+	}
+	if err := schemas.ValidateValue(DetectedPattern, data); err != nil {
+		t.Errorf("Expected the payload to match its schema, got %v", err)
+	}
+}
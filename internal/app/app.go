@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"order-service/internal/backoff"
+	"order-service/internal/supervisor"
+)
+
+// Hook is a lifecycle step run by App.Start and undone by App.Stop.
+// Either function may be nil.
+type Hook struct {
+	Name  string
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+type lifecycle struct {
+	hooks   []Hook
+	started int
+
+	runCtx     context.Context
+	cancel     context.CancelFunc
+	supervisor *supervisor.Supervisor
+}
+
+// Append adds a hook. Hooks start in the order they were added and stop in
+// reverse.
+func (l *lifecycle) Append(h Hook) {
+	l.hooks = append(l.hooks, h)
+}
+
+// Go runs fn in the background from Start until Stop, which cancels its
+// context and waits for it to return. If fn returns before that or panics,
+// it is restarted with backoff.
+func (l *lifecycle) Go(name string, fn func(ctx context.Context)) {
+	l.Supervise(name, func(ctx context.Context) error {
+		fn(ctx)
+		return nil
+	})
+}
+
+// Supervise is Go for functions that return why they stopped.
+func (l *lifecycle) Supervise(name string, fn func(ctx context.Context) error) {
+	l.Append(Hook{
+		Name:  name,
+		Start: func(context.Context) error { l.spawn(name, fn); return nil },
+	})
+}
+
+// GoOnce runs fn in the background from Start until it succeeds. Errors and
+// panics restart it with backoff; Stop cancels it.
+func (l *lifecycle) GoOnce(name string, fn func(ctx context.Context) error) {
+	l.Append(Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			l.Supervisor().GoOnce(l.runCtx, name, fn)
+			return nil
+		},
+	})
+}
+
+// spawn starts a supervised goroutine right away. It may only be called
+// while the lifecycle is running, e.g. from a Start hook.
+func (l *lifecycle) spawn(name string, fn func(ctx context.Context) error) {
+	l.Supervisor().Go(l.runCtx, name, fn)
+}
+
+// Supervisor returns the supervisor of the background goroutines.
+func (l *lifecycle) Supervisor() *supervisor.Supervisor {
+	if l.supervisor == nil {
+		l.supervisor = supervisor.New(backoff.Policy{}, 0)
+	}
+	return l.supervisor
+}
+
+// Start runs every hook. If one fails, the hooks already started are
+// stopped again and the error is returned.
+func (l *lifecycle) Start(ctx context.Context) error {
+	l.runCtx, l.cancel = context.WithCancel(context.Background())
+	for _, h := range l.hooks {
+		if h.Start != nil {
+			if err := h.Start(ctx); err != nil {
+				stopErr := l.Stop(ctx)
+				return errors.Join(fmt.Errorf("failed to start %s: %w", h.Name, err), stopErr)
+			}
+		}
+		l.started++
+	}
+	return nil
+}
+
+// Stop runs the Stop functions of the started hooks in reverse order, then
+// cancels background goroutines and waits for them until ctx is done.
+func (l *lifecycle) Stop(ctx context.Context) error {
+	var errs []error
+	for l.started > 0 {
+		l.started--
+		h := l.hooks[l.started]
+		if h.Stop == nil {
+			continue
+		}
+		if err := h.Stop(ctx); err != nil {
+			log.Printf("Failed to stop %s: %v", h.Name, err)
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", h.Name, err))
+		}
+	}
+	if l.cancel == nil {
+		return errors.Join(errs...)
+	}
+	l.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		l.Supervisor().Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background tasks did not stop: %w", ctx.Err()))
+	}
+	return errors.Join(errs...)
+}
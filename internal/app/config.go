@@ -0,0 +1,483 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"order-service/internal/acceptance"
+	"order-service/internal/analytics"
+	"order-service/internal/anomaly"
+	"order-service/internal/broker"
+	"order-service/internal/currency"
+	"order-service/internal/degrade"
+	"order-service/internal/discovery"
+	"order-service/internal/errreport"
+	"order-service/internal/featureflags"
+	"order-service/internal/leader"
+	"order-service/internal/limits"
+	"order-service/internal/middleware"
+	"order-service/internal/quota"
+	"order-service/internal/repository"
+	"order-service/internal/runtimeconfig"
+	"order-service/internal/secrets"
+	"order-service/internal/signing"
+	"order-service/internal/tlsconfig"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// newDegradationController probes the service's dependencies. Postgres is
+// critical; Redis, RabbitMQ and product-service only switch on degraded
+// modes. Accepting orders without product-service is opt-in. Cache shards
+// are only reported: the ring already routes around shards that are down.
+func newDegradationController(db *gorm.DB, rdb *redis.Client, cacheShards map[string]*redis.Client, rabbit *broker.Connection, productClient *http.Client, productEndpoints *discovery.Balancer) *degrade.Controller {
+	var productModes []string
+	if os.Getenv("ACCEPT_ORDERS_WITHOUT_PRODUCT_SERVICE") == "true" {
+		productModes = []string{degrade.ModePendingValidation}
+	}
+	productHealthPath := getEnv("PRODUCT_SERVICE_HEALTH_PATH", "/health")
+
+	deps := []degrade.Dependency{
+		{
+			Name:     "postgres",
+			Critical: true,
+			Check: func(ctx context.Context) error {
+				sqlDB, err := db.DB()
+				if err != nil {
+					return err
+				}
+				return sqlDB.PingContext(ctx)
+			},
+		},
+		degrade.Dependency{
+			Name:  "redis",
+			Modes: []string{degrade.ModeCacheBypass},
+			Check: func(ctx context.Context) error { return rdb.Ping(ctx).Err() },
+		},
+		degrade.Dependency{
+			Name:  "rabbitmq",
+			Modes: []string{degrade.ModeOutboxOnly},
+			Check: func(ctx context.Context) error {
+				if rabbit.IsClosed() {
+					return broker.ErrNotConnected
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "product-service",
+			Modes: productModes,
+			Check: func(ctx context.Context) error {
+				return productEndpoints.Probe(ctx, productClient, productHealthPath)
+			},
+		},
+	}
+	names := make([]string, 0, len(cacheShards))
+	for name := range cacheShards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		shard := cacheShards[name]
+		deps = append(deps, degrade.Dependency{
+			Name:  "redis-cache-" + name,
+			Check: func(ctx context.Context) error { return shard.Ping(ctx).Err() },
+		})
+	}
+	return degrade.New(getEnvDuration("DEPENDENCY_CHECK_TIMEOUT", 2*time.Second), deps...)
+}
+
+// parseCacheShards reads cache shard addresses given as host:port or
+// name=host:port. An address without a name is named after itself.
+func parseCacheShards(addrs []string) (map[string]string, error) {
+	named := make(map[string]string, len(addrs))
+	for _, addr := range addrs {
+		name, hostPort, ok := strings.Cut(addr, "=")
+		if !ok {
+			hostPort = addr
+		}
+		name, hostPort = strings.TrimSpace(name), strings.TrimSpace(hostPort)
+		if name == "" {
+			return nil, fmt.Errorf("shard %q has no name", addr)
+		}
+		if host, port, err := net.SplitHostPort(hostPort); err != nil || host == "" || port == "" || strings.Contains(host, "=") {
+			return nil, fmt.Errorf("shard %q is not host:port", addr)
+		}
+		if _, dup := named[name]; dup {
+			return nil, fmt.Errorf("shard %q is listed twice", name)
+		}
+		named[name] = hostPort
+	}
+	return named, nil
+}
+
+// newCacheRing shards the order cache across addrs, see parseCacheShards,
+// with consistent hashing. The ring pings every shard each
+// ORDER_CACHE_HEARTBEAT and moves keys off shards that stop answering. The
+// shard clients are returned by name for health checks.
+func newCacheRing(addrs []string, password string) (*redis.Ring, map[string]*redis.Client, error) {
+	named, err := parseCacheShards(addrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	shards := make(map[string]*redis.Client, len(named))
+	ring := redis.NewRing(&redis.RingOptions{
+		Addrs:              named,
+		Password:           password,
+		HeartbeatFrequency: getEnvDuration("ORDER_CACHE_HEARTBEAT", 500*time.Millisecond),
+		NewClient: func(name string, opt *redis.Options) *redis.Client {
+			client := redis.NewClient(opt)
+			shards[name] = client
+			return client
+		},
+	})
+	return ring, shards, nil
+}
+
+// loadRuntimeConfig reads the reloadable settings from the environment.
+// The feature flag file is read again on every reload.
+func loadRuntimeConfig() (runtimeconfig.Config, error) {
+	cfg := runtimeconfig.Config{
+		LogLevel: os.Getenv("LOG_LEVEL"),
+		CacheTTL: runtimeconfig.Duration(getEnvDuration("ORDER_CACHE_TTL", repository.DefaultCacheTTL)),
+	}
+	if _, err := runtimeconfig.ParseLevel(cfg.LogLevel); err != nil {
+		log.Printf("Invalid LOG_LEVEL %q, using info", cfg.LogLevel)
+		cfg.LogLevel = "info"
+	}
+
+	var err error
+	cfg.PurchaseLimits, err = limits.Parse(os.Getenv("PURCHASE_LIMITS"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.AcceptanceRules, err = acceptance.Parse(os.Getenv("ACCEPTANCE_RULES"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.RequestQuotas, err = quota.Parse(os.Getenv("REQUEST_QUOTAS"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.ErrorReportClasses, err = errreport.ParseClasses(os.Getenv("ERROR_REPORT_CLASSES"))
+	if err != nil {
+		return cfg, err
+	}
+	switch getEnv("FEATURE_FLAGS_PROVIDER", "env") {
+	case "env":
+		cfg.FeatureFlags, err = featureflags.ParseEnv(os.Getenv("FEATURE_FLAGS"))
+	case "file":
+		cfg.FeatureFlags, err = featureflags.LoadFile(os.Getenv("FEATURE_FLAGS_FILE"))
+	}
+	return cfg, err
+}
+
+// newProductClient returns the HTTP client for product-service. With
+// PRODUCT_SERVICE_TLS_CERT and _KEY set it presents a client certificate;
+// the returned ClientCerts must be watched to pick up rotated files. With
+// PRODUCT_SERVICE_SIGNING_KEYS set it signs its requests.
+func newProductClient() (*http.Client, *tlsconfig.ClientCerts, error) {
+	var transport http.RoundTripper = http.DefaultTransport
+	var certs *tlsconfig.ClientCerts
+	certFile, keyFile := os.Getenv("PRODUCT_SERVICE_TLS_CERT"), os.Getenv("PRODUCT_SERVICE_TLS_KEY")
+	if certFile != "" || keyFile != "" {
+		var err error
+		certs, err = tlsconfig.NewClientCerts(tlsconfig.Files{
+			CertFile:   certFile,
+			KeyFile:    keyFile,
+			CAFile:     os.Getenv("PRODUCT_SERVICE_TLS_CA"),
+			ServerName: os.Getenv("PRODUCT_SERVICE_TLS_SERVER_NAME"),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		tlsTransport := http.DefaultTransport.(*http.Transport).Clone()
+		tlsTransport.TLSClientConfig = certs.TLSConfig()
+		transport = tlsTransport
+	}
+	transport, err := signedTransport("PRODUCT_SERVICE", transport)
+	if err != nil {
+		return nil, nil, err
+	}
+	if transport == http.DefaultTransport {
+		return http.DefaultClient, nil, nil
+	}
+	return &http.Client{Transport: transport}, certs, nil
+}
+
+// newProductEndpoints resolves the product-service instances as set by
+// PRODUCT_SERVICE_DISCOVERY: "static" takes the comma-separated
+// PRODUCT_SERVICE_URL, "srv" the DNS SRV records of
+// PRODUCT_SERVICE_SRV_NAME and "consul" the instances of
+// PRODUCT_SERVICE_CONSUL_NAME that pass their checks in Consul.
+func newProductEndpoints() (*discovery.Balancer, error) {
+	scheme := getEnv("PRODUCT_SERVICE_SCHEME", "http")
+	var resolver discovery.Resolver
+	switch mode := getEnv("PRODUCT_SERVICE_DISCOVERY", "static"); mode {
+	case "static":
+		resolver = discovery.Static(getEnvList("PRODUCT_SERVICE_URL"))
+	case "srv":
+		name := os.Getenv("PRODUCT_SERVICE_SRV_NAME")
+		if name == "" {
+			return nil, errors.New("PRODUCT_SERVICE_SRV_NAME is required for SRV discovery")
+		}
+		resolver = discovery.NewSRV(name, scheme)
+	case "consul":
+		resolver = discovery.NewConsul(getEnv("CONSUL_ADDR", "http://127.0.0.1:8500"), getEnv("PRODUCT_SERVICE_CONSUL_NAME", "product-service"), scheme, nil)
+	default:
+		return nil, fmt.Errorf("unknown PRODUCT_SERVICE_DISCOVERY %q", mode)
+	}
+	return discovery.NewBalancer("product-service", resolver,
+		getEnvInt("PRODUCT_SERVICE_MAX_FAILS", 3),
+		getEnvDuration("PRODUCT_SERVICE_EVICTION", 30*time.Second)), nil
+}
+
+// defaultLeaderJobs are the scheduled jobs that run on the leader only:
+// reconciliations and bulk rewrites that would repeat or race on several
+// replicas.
+var defaultLeaderJobs = []string{
+	"payment-reconciliation",
+	"inventory-reconcile",
+	"top-products-reconcile",
+	"revenue-adjustments",
+	"retention",
+}
+
+// newElector returns the elector for the jobs in LEADER_JOBS, holding a
+// Redis key or, with LEADER_ELECTION=kubernetes, a Lease in the pod's
+// namespace. With LEADER_ELECTION=none every replica runs every job and
+// the elector is nil.
+func newElector(rdb *redis.Client, holder string) (*leader.Elector, error) {
+	name := getEnv("LEADER_ELECTION_NAME", "order-service-jobs")
+	var lock leader.Lock
+	switch mode := getEnv("LEADER_ELECTION", "redis"); mode {
+	case "none":
+		return nil, nil
+	case "redis":
+		lock = leader.NewRedisLock(rdb, name)
+	case "kubernetes":
+		lease, err := leader.NewInClusterLease(os.Getenv("LEADER_ELECTION_NAMESPACE"), name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure the leader lease: %w", err)
+		}
+		lock = lease
+	default:
+		return nil, fmt.Errorf("unknown LEADER_ELECTION %q", mode)
+	}
+	return leader.NewElector(name, holder, lock, getEnvDuration("LEADER_ELECTION_TTL", 15*time.Second)), nil
+}
+
+// anomalyConfig reads the ORDER_ANOMALY_* settings of the order rate
+// detector over anomaly.DefaultConfig.
+func anomalyConfig() (anomaly.Config, error) {
+	def := anomaly.DefaultConfig
+	cfg := anomaly.Config{
+		Bucket:    getEnvDuration("ORDER_ANOMALY_BUCKET", def.Bucket),
+		History:   getEnvInt("ORDER_ANOMALY_HISTORY", def.History),
+		ZScore:    getEnvFloat("ORDER_ANOMALY_ZSCORE", def.ZScore),
+		MinOrders: int64(getEnvInt("ORDER_ANOMALY_MIN_ORDERS", int(def.MinOrders))),
+		MaxOrders: int64(getEnvInt("ORDER_ANOMALY_MAX_ORDERS", int(def.MaxOrders))),
+		Cooldown:  getEnvDuration("ORDER_ANOMALY_COOLDOWN", def.Cooldown),
+	}
+	if cfg.Bucket <= 0 || cfg.History <= 0 || cfg.Cooldown <= 0 {
+		return cfg, fmt.Errorf("ORDER_ANOMALY_BUCKET, ORDER_ANOMALY_HISTORY and ORDER_ANOMALY_COOLDOWN must be positive")
+	}
+	return cfg, nil
+}
+
+// signedTransport signs the requests sent through base when
+// <prefix>_SIGNING_KEYS is set, so each upstream has its own keys and
+// clock-skew tolerance (<prefix>_SIGNING_MAX_SKEW).
+func signedTransport(prefix string, base http.RoundTripper) (http.RoundTripper, error) {
+	spec := os.Getenv(prefix + "_SIGNING_KEYS")
+	if spec == "" {
+		return base, nil
+	}
+	keys, err := signing.ParseKeys(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s_SIGNING_KEYS: %w", prefix, err)
+	}
+	return signing.NewTransport(base, keys, getEnvDuration(prefix+"_SIGNING_MAX_SKEW", 30*time.Second)), nil
+}
+
+// newErrorReporter reports to Sentry when SENTRY_DSN is set and only logs
+// otherwise.
+func newErrorReporter() (errreport.Reporter, error) {
+	dsn := os.Getenv("SENTRY_DSN")
+	if dsn == "" {
+		return errreport.Nop{}, nil
+	}
+	return errreport.NewSentry(dsn, os.Getenv("SENTRY_ENVIRONMENT"), os.Getenv("SENTRY_RELEASE"))
+}
+
+func newSecretsProvider(ctx context.Context) (secrets.Provider, error) {
+	switch os.Getenv("SECRETS_PROVIDER") {
+	case "":
+		return nil, nil
+	case "vault":
+		return secrets.NewVaultProvider(getEnv("VAULT_ADDR", "http://127.0.0.1:8200"), os.Getenv("VAULT_TOKEN"), getEnv("VAULT_MOUNT", "secret")), nil
+	case "aws":
+		return secrets.NewAWSProvider(ctx, os.Getenv("SECRETS_MANAGER_ENDPOINT"))
+	default:
+		return nil, fmt.Errorf("unknown secrets provider %q", os.Getenv("SECRETS_PROVIDER"))
+	}
+}
+
+// readSecret reads the secret named by the env var nameVar. It returns nil
+// when either the variable or a secrets provider is not configured.
+func (a *App) readSecret(ctx context.Context, nameVar string) (map[string]string, error) {
+	name := os.Getenv(nameVar)
+	if a.secrets == nil || name == "" {
+		return nil, nil
+	}
+	secret, err := a.secrets.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", nameVar, err)
+	}
+	return secret, nil
+}
+
+// webhookProviders reads WEBHOOK_PROVIDERS. Keys of the secret named by
+// WEBHOOK_SECRET are provider names and replace those providers' secrets.
+func (a *App) webhookProviders(ctx context.Context) (map[string]middleware.WebhookProvider, error) {
+	providers, err := middleware.ParseWebhookProviders(os.Getenv("WEBHOOK_PROVIDERS"))
+	if err != nil {
+		return nil, err
+	}
+	secret, err := a.readSecret(ctx, "WEBHOOK_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range secret {
+		p := providers[name]
+		p.Secret = value
+		providers[name] = p
+	}
+	return providers, nil
+}
+
+func (a *App) secretsInterval() time.Duration {
+	return getEnvDuration("SECRETS_REFRESH_INTERVAL", 5*time.Minute)
+}
+
+// withCredentials puts the username and password from secret into the
+// userinfo of rawURL.
+func withCredentials(rawURL string, secret map[string]string) (string, error) {
+	if secret == nil {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	user := u.User.Username()
+	if v, ok := secret["username"]; ok {
+		user = v
+	}
+	password, _ := u.User.Password()
+	if v, ok := secret["password"]; ok {
+		password = v
+	}
+	u.User = url.UserPassword(user, password)
+	return u.String(), nil
+}
+
+// newFeatureFlags also returns the provider's refresh loop for providers
+// that poll, or nil. rules are used by the env and file providers.
+func newFeatureFlags(rdb *redis.Client, rules map[string]featureflags.Rule) (*featureflags.Client, func(ctx context.Context), error) {
+	switch getEnv("FEATURE_FLAGS_PROVIDER", "env") {
+	case "env", "file":
+		return featureflags.New(featureflags.NewStaticProvider(rules)), nil, nil
+	case "redis":
+		ttl := getEnvDuration("FEATURE_FLAGS_REFRESH_INTERVAL", 10*time.Second)
+		return featureflags.New(featureflags.NewRedisProvider(rdb, ttl)), nil, nil
+	case "unleash":
+		provider := featureflags.NewUnleashProvider(os.Getenv("UNLEASH_URL"), os.Getenv("UNLEASH_API_TOKEN"), "order-service")
+		interval := getEnvDuration("FEATURE_FLAGS_REFRESH_INTERVAL", 10*time.Second)
+		refresh := func(ctx context.Context) { provider.Run(ctx, interval) }
+		return featureflags.New(provider), refresh, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown feature flag provider %q", os.Getenv("FEATURE_FLAGS_PROVIDER"))
+	}
+}
+
+func newExchangeRates() (currency.RateProvider, error) {
+	switch getEnv("EXCHANGE_RATE_PROVIDER", "static") {
+	case "static":
+		return currency.ParseRates(os.Getenv("EXCHANGE_RATES"))
+	case "http":
+		provider := currency.NewHTTPProvider(os.Getenv("EXCHANGE_RATE_URL"), os.Getenv("EXCHANGE_RATE_API_KEY"))
+		return currency.NewCachedProvider(provider, getEnvDuration("EXCHANGE_RATE_CACHE_TTL", time.Hour)), nil
+	default:
+		return nil, fmt.Errorf("unknown exchange rate provider %q", os.Getenv("EXCHANGE_RATE_PROVIDER"))
+	}
+}
+
+func newAnalyticsStore() (analytics.ObjectStore, error) {
+	switch os.Getenv("ANALYTICS_EXPORT_STORE") {
+	case "s3", "gcs":
+		return analytics.NewS3Store(context.Background(), os.Getenv("ANALYTICS_EXPORT_BUCKET"), os.Getenv("ANALYTICS_EXPORT_ENDPOINT"))
+	case "file", "":
+		return analytics.NewFileStore(getEnv("ANALYTICS_EXPORT_DIR", "./analytics-export")), nil
+	default:
+		return nil, fmt.Errorf("unknown analytics store %q", os.Getenv("ANALYTICS_EXPORT_STORE"))
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getEnvList splits a comma-separated variable, dropping empty entries.
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+		log.Printf("Invalid duration for %s: %q, using %s", key, v, fallback)
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+		log.Printf("Invalid integer for %s: %q, using %d", key, v, fallback)
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+		log.Printf("Invalid number for %s: %q, using %v", key, v, fallback)
+	}
+	return fallback
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"order-service/internal/backoff"
+	"order-service/internal/secrets"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// invalidPassword is the SQLSTATE Postgres answers a login with stale
+// credentials with.
+const invalidPassword = "28P01"
+
+type dbCredentials struct {
+	user     string
+	password string
+}
+
+// database owns the Postgres pool. Connections are opened with the latest
+// credentials, so after a rotation new connections log in with the new
+// password while the pool's idle connections are closed and replaced.
+type database struct {
+	sqlDB   *sql.DB
+	maxIdle int
+	creds   atomic.Pointer[dbCredentials]
+
+	// secret re-reads DATABASE_SECRET; nil when credentials come from the
+	// environment.
+	secret func(ctx context.Context) (map[string]string, error)
+	mu     sync.Mutex
+	last   map[string]string
+}
+
+// openDatabase connects to Postgres with DATABASE_* settings, overridden
+// by the keys of DATABASE_SECRET (username, password, host, port, dbname)
+// when a secrets provider is configured.
+func (a *App) openDatabase(ctx context.Context) error {
+	settings := map[string]string{
+		"host":     os.Getenv("DATABASE_HOST"),
+		"port":     os.Getenv("DATABASE_PORT"),
+		"dbname":   os.Getenv("DATABASE_NAME"),
+		"username": os.Getenv("DATABASE_USER"),
+		"password": os.Getenv("DATABASE_PASSWORD"),
+	}
+	d := &database{maxIdle: getEnvInt("DB_MAX_IDLE_CONNS", 2)}
+	secretName := os.Getenv("DATABASE_SECRET")
+	if a.secrets != nil && secretName != "" {
+		d.secret = func(ctx context.Context) (map[string]string, error) { return a.secrets.Get(ctx, secretName) }
+	}
+
+	err := backoff.Retry(ctx, "Postgres", a.retry, a.maxWait, func(ctx context.Context) error {
+		if d.secret != nil && d.last == nil {
+			secret, err := d.secret(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to read database secret: %w", err)
+			}
+			d.last = secret
+			maps.Copy(settings, secret)
+		}
+		d.creds.Store(&dbCredentials{user: settings["username"], password: settings["password"]})
+
+		cfg, err := pgx.ParseConfig(fmt.Sprintf("host=%s port=%s dbname=%s sslmode=disable",
+			settings["host"], settings["port"], settings["dbname"]))
+		if err != nil {
+			return err
+		}
+		connector := stdlib.GetConnector(*cfg, stdlib.OptionBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+			creds := d.creds.Load()
+			cc.User, cc.Password = creds.user, creds.password
+			return nil
+		}))
+		d.sqlDB = sql.OpenDB(&rotatingConnector{Connector: connector, db: d})
+		d.sqlDB.SetMaxIdleConns(d.maxIdle)
+		d.sqlDB.SetConnMaxLifetime(getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute))
+
+		a.DB, err = gorm.Open(postgres.New(postgres.Config{Conn: d.sqlDB}), &gorm.Config{TranslateError: true})
+		if err != nil {
+			d.sqlDB.Close()
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if d.secret != nil {
+		initial := d.last
+		a.Go("database-secret", func(ctx context.Context) {
+			secrets.Watch(ctx, a.secrets, secretName, a.secretsInterval(), initial, d.update)
+		})
+	}
+	return nil
+}
+
+func (d *database) refresh(ctx context.Context) error {
+	secret, err := d.secret(ctx)
+	if err != nil {
+		return err
+	}
+	d.update(secret)
+	return nil
+}
+
+// update switches new connections to the credentials in secret if they
+// changed and drops the idle connections logged in with the old ones.
+// Connections in use are replaced once DB_CONN_MAX_LIFETIME passes.
+func (d *database) update(secret map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if maps.Equal(secret, d.last) {
+		return
+	}
+	d.last = secret
+	creds := *d.creds.Load()
+	if v, ok := secret["username"]; ok {
+		creds.user = v
+	}
+	if v, ok := secret["password"]; ok {
+		creds.password = v
+	}
+	d.creds.Store(&creds)
+
+	d.sqlDB.SetMaxIdleConns(0)
+	d.sqlDB.SetMaxIdleConns(d.maxIdle)
+	log.Printf("Database credentials rotated, recycling idle connections")
+}
+
+// rotatingConnector re-reads the secret once when Postgres rejects the
+// password, so a rotation is picked up before the next scheduled refresh.
+type rotatingConnector struct {
+	driver.Connector
+	db *database
+}
+
+func (c *rotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	var pgErr *pgconn.PgError
+	if err == nil || c.db.secret == nil || !errors.As(err, &pgErr) || pgErr.Code != invalidPassword {
+		return conn, err
+	}
+	if refreshErr := c.db.refresh(ctx); refreshErr != nil {
+		return nil, errors.Join(err, refreshErr)
+	}
+	return c.Connector.Connect(ctx)
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"order-service/internal/adminui"
+	"order-service/internal/handler"
+	"order-service/internal/i18n"
+	"order-service/internal/importer"
+	"order-service/internal/metrics"
+	"order-service/internal/middleware"
+	"order-service/internal/tenant"
+	"order-service/internal/timezone"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Router builds the HTTP API on top of the app's services.
+func (a *App) Router() (*gin.Engine, error) {
+	zones, err := timezone.NewResolver(os.Getenv("DEFAULT_TIMEZONE"), os.Getenv("TENANT_TIMEZONES"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure timezones: %w", err)
+	}
+	webhookProviders, err := a.webhookProviders(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure webhooks: %w", err)
+	}
+
+	binding.EnableDecoderDisallowUnknownFields = getEnv("STRICT_JSON", "true") == "true"
+
+	orderHandler := handler.NewOrderHandler(a.OrderAPI, a.Audit)
+	orderHandler.SetEncoder(a.encoder)
+	shareSigner := middleware.NewShareSigner(os.Getenv("SHARE_LINK_SECRET"))
+	orderHandler.SetShareLinks(shareSigner, os.Getenv("SHARE_LINK_BASE_URL"),
+		getEnvDuration("SHARE_LINK_TTL", 24*time.Hour), getEnvDuration("SHARE_LINK_MAX_TTL", 7*24*time.Hour))
+	blocklistHandler := handler.NewBlocklistHandler(a.Blocklist, a.Audit)
+	geoRestrictionHandler := handler.NewGeoRestrictionHandler(a.GeoRules, a.Audit)
+	jobHandler := handler.NewJobHandler(a.Jobs)
+	importHandler := handler.NewImportHandler(importer.New(a.Jobs, a.Orders, getEnvInt("IMPORT_BATCH_SIZE", 100), os.Getenv("IMPORT_DIR")), a.Audit)
+
+	router := gin.New()
+	if err := trustProxies(router, getEnvList("TRUSTED_PROXIES")); err != nil {
+		return nil, err
+	}
+	router.Use(gin.Logger(), middleware.Problems(getEnv("PROBLEM_TYPE_BASE", "urn:order-service:problem:")), middleware.Recovery(a.reporter))
+	router.Use(tenant.Middleware(os.Getenv("DEFAULT_TENANT_ID")))
+	router.Use(i18n.Middleware())
+	router.Use(timezone.Middleware(zones))
+	maxJSONDepth := getEnvInt("MAX_JSON_DEPTH", 10)
+	bodyLimits := middleware.BodyLimits(int64(getEnvInt("MAX_BODY_BYTES", 1<<20)), maxJSONDepth)
+	quotas := a.Quotas.Middleware()
+	ordersTimeout := middleware.Timeout(getEnvDuration("ORDERS_REQUEST_TIMEOUT", 10*time.Second))
+	adminToken := os.Getenv("ADMIN_API_TOKEN")
+	orders := router.Group("/orders", middleware.AdminIdentity(adminToken), compression("ORDERS"), quotas, a.loadShedder.Middleware(), bodyLimits, ordersTimeout)
+	// Shared order links carry their own authorization in the token.
+	router.GET("/shared/orders/:token", a.loadShedder.Middleware(), middleware.SharedOrder(shareSigner), orderHandler.GetSharedOrder)
+	subscriptionHandler := handler.NewSubscriptionHandler(a.Subscriptions)
+	subscriptions := router.Group("/subscriptions", compression("SUBSCRIPTIONS"), quotas, bodyLimits)
+	subscriptions.POST("", subscriptionHandler.Create)
+	subscriptions.GET("", subscriptionHandler.List)
+	subscriptions.GET("/:id", subscriptionHandler.Get)
+	subscriptions.PATCH("/:id", subscriptionHandler.Update)
+	subscriptions.DELETE("/:id", subscriptionHandler.Cancel)
+	templateHandler := handler.NewTemplateHandler(a.Templates, a.OrderAPI)
+	templates := router.Group("/order-templates", middleware.AdminIdentity(adminToken), compression("TEMPLATES"), quotas, a.loadShedder.Middleware(), bodyLimits, ordersTimeout)
+	templates.POST("", templateHandler.Create)
+	templates.GET("", templateHandler.List)
+	templates.GET("/:id", templateHandler.Get)
+	templates.DELETE("/:id", templateHandler.Delete)
+	templates.POST("/:id/orders", templateHandler.PlaceOrder)
+	inventoryHandler := handler.NewInventoryHandler(a.Inventory)
+	inventory := router.Group("/inventory", compression("INVENTORY"), quotas, a.loadShedder.Middleware(), bodyLimits, ordersTimeout)
+	inventory.POST("/reservations", inventoryHandler.Reserve)
+	inventory.GET("/reservations/:orderId", inventoryHandler.Get)
+	inventory.PATCH("/reservations/:orderId", inventoryHandler.Extend)
+	inventory.DELETE("/reservations/:orderId", inventoryHandler.Release)
+	inventory.GET("/products/:productId", inventoryHandler.Commitment)
+	statsHandler := handler.NewStatsHandler(a.Stats, a.Leaderboard)
+	statsCompression := compression("STATS")
+	router.GET("/products/:productId/order-stats", statsCompression, quotas, statsHandler.ProductOrderStats)
+	router.GET("/reports/top-products", statsCompression, quotas, statsHandler.TopProducts)
+	router.GET("/customers/:id/ltv", statsCompression, quotas, statsHandler.CustomerLTV)
+	schemaHandler := handler.NewSchemaHandler(a.Schemas)
+	router.GET("/schemas", schemaHandler.List)
+	router.GET("/schemas/:pattern", schemaHandler.Get)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	a.probeRoutes(router)
+	router.POST("/webhooks/:provider",
+		bodyLimits,
+		middleware.VerifyWebhook(webhookProviders, middleware.NewRedisNonceStore(a.Redis), getEnvDuration("WEBHOOK_TOLERANCE", 5*time.Minute)),
+		handler.NewWebhookHandler(handler.NewEventHandler(a.OrderAPI)).Receive,
+	)
+
+	adminAuth := middleware.AdminAuth(adminToken)
+	admin := router.Group("/admin",
+		adminAuth,
+		compression("ADMIN"),
+		bodyLimits,
+		middleware.Timeout(getEnvDuration("ADMIN_REQUEST_TIMEOUT", 30*time.Second)),
+	)
+	orderHandler.RegisterRoutes(orders, admin)
+	savedSearchHandler := handler.NewSavedSearchHandler(a.SavedSearches, orderHandler)
+	admin.POST("/saved-searches", savedSearchHandler.Create)
+	admin.GET("/saved-searches", savedSearchHandler.List)
+	admin.GET("/saved-searches/:id", savedSearchHandler.Get)
+	admin.DELETE("/saved-searches/:id", savedSearchHandler.Delete)
+	admin.GET("/saved-searches/:id/orders", savedSearchHandler.Run)
+	admin.GET("/blocklist", blocklistHandler.List)
+	admin.POST("/blocklist", blocklistHandler.Add)
+	admin.DELETE("/blocklist/:id", blocklistHandler.Remove)
+	admin.GET("/geo-restrictions", geoRestrictionHandler.List)
+	admin.POST("/geo-restrictions", geoRestrictionHandler.Add)
+	admin.PUT("/geo-restrictions/:id", geoRestrictionHandler.Update)
+	admin.DELETE("/geo-restrictions/:id", geoRestrictionHandler.Remove)
+	admin.GET("/jobs/:id", jobHandler.Get)
+	admin.GET("/config", handler.NewConfigHandler(a.Config).Get)
+	admin.GET("/audit", handler.NewAuditHandler(a.Audit).List)
+	revenueHandler := handler.NewRevenueHandler(a.Revenue, a.Audit)
+	admin.GET("/revenue/:period", revenueHandler.Report)
+	admin.POST("/revenue/:period/close", revenueHandler.Close)
+	admin.GET("/consumers", handler.NewConsumerHandler(a.consumers).List)
+	quarantineHandler := handler.NewQuarantineHandler(a.Quarantine, a.eventHandlers(), a.Audit)
+	admin.GET("/quarantine", quarantineHandler.List)
+	admin.GET("/quarantine/:id", quarantineHandler.Get)
+	admin.POST("/quarantine/:id/reprocess", quarantineHandler.Reprocess)
+	admin.POST("/quarantine/:id/discard", quarantineHandler.Discard)
+	admin.GET("/sla/breaches", handler.NewSLAHandler(a.SLA).Breaches)
+	admin.GET("/anomalies", handler.NewAnomalyHandler(a.Anomalies).List)
+	admin.GET("/reconciliation", handler.NewReconciliationHandler(a.Discrepancies).Discrepancies)
+	admin.GET("/usage", handler.NewUsageHandler(a.Quotas).Report)
+	admin.GET("/billing/reconciliation", handler.NewBillingHandler(a.Billing).Reconciliation)
+
+	// Uploads get their own size limit and no request timeout; the import
+	// itself runs in the background.
+	uploads := router.Group("/admin", adminAuth,
+		middleware.BodyLimits(int64(getEnvInt("IMPORT_MAX_BYTES", 100<<20)), maxJSONDepth),
+	)
+	uploads.POST("/orders/import", importHandler.Create)
+
+	// The dashboard is opened in a browser, which can send basic auth but
+	// not a bearer token.
+	dashboard := adminui.New(a.Repo, a.Outbox, a.Rabbit, a.consumerQueues())
+	router.GET("/admin/ui",
+		middleware.AdminUIAuth(os.Getenv("ADMIN_API_TOKEN")),
+		middleware.Timeout(getEnvDuration("ADMIN_REQUEST_TIMEOUT", 30*time.Second)),
+		dashboard.Show,
+	)
+	return router, nil
+}
+
+// compression compresses the responses of a route group. The minimum size
+// is <group>_COMPRESSION_MIN_BYTES, else COMPRESSION_MIN_BYTES; negative
+// turns compression off for the group.
+func compression(group string) gin.HandlerFunc {
+	minSize := getEnvInt("COMPRESSION_MIN_BYTES", 1024)
+	types := getEnvList("COMPRESSION_TYPES")
+	if len(types) == 0 {
+		types = middleware.DefaultCompressionTypes
+	}
+	return middleware.Compress(middleware.Compression{
+		MinSize: getEnvInt(group+"_COMPRESSION_MIN_BYTES", minSize),
+		Types:   types,
+	})
+}
+
+// AddHTTPServer serves the API on addr.
+func (a *App) AddHTTPServer(addr string) error {
+	router, err := a.Router()
+	if err != nil {
+		return err
+	}
+	a.serve("http-server", addr, router)
+	return nil
+}
+
+// trustProxies makes c.ClientIP(), which the blocklist and the audit log
+// rely on, read X-Forwarded-For and X-Real-IP only on requests from
+// proxies, IPs or CIDRs; otherwise it is the peer address, so clients
+// cannot choose their own IP.
+func trustProxies(router *gin.Engine, proxies []string) error {
+	if err := router.SetTrustedProxies(proxies); err != nil {
+		return fmt.Errorf("failed to configure trusted proxies: %w", err)
+	}
+	return nil
+}
+
+// AddOpsServer serves only /metrics and the probes, for deployments that
+// run workers without the API.
+func (a *App) AddOpsServer(addr string) {
+	router := gin.New()
+	router.Use(middleware.Recovery(a.reporter))
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	a.probeRoutes(router)
+	a.serve("ops-server", addr, router)
+}
+
+// AddProbeServer serves the probes and the preStop hook on a port of their
+// own, which stays out of the Service and any ingress. Add it before the
+// other servers: it stops last, so readiness keeps reporting the drain.
+func (a *App) AddProbeServer(addr string) {
+	router := gin.New()
+	router.Use(middleware.Recovery(a.reporter))
+	a.probeRoutes(router)
+	router.GET(getEnv("PROBE_PRESTOP_PATH", "/prestop"), func(c *gin.Context) {
+		a.Drain(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+	a.serve("probe-server", addr, router)
+}
+
+// probeRoutes adds the liveness and readiness probes at
+// PROBE_LIVENESS_PATH and PROBE_READINESS_PATH.
+func (a *App) probeRoutes(router gin.IRoutes) {
+	health := handler.NewHealthHandler(a.Degradation, a.Supervisor())
+	health.SetInstance(a.instance.ID)
+	health.SetDraining(a.draining.Load)
+	health.SetLeadership(a.leadership)
+	router.GET(getEnv("PROBE_LIVENESS_PATH", "/livez"), health.Live)
+	router.GET(getEnv("PROBE_READINESS_PATH", "/readyz"), health.Ready)
+}
+
+// serve opens the listener on Start so a taken port fails startup; Stop
+// waits for in-flight requests.
+func (a *App) serve(name, addr string, h http.Handler) {
+	srv := &http.Server{Addr: addr, Handler: h}
+	a.Append(Hook{
+		Name: name,
+		Start: func(context.Context) error {
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Printf("%s stopped: %v", name, err)
+				}
+			}()
+			return nil
+		},
+		Stop: srv.Shutdown,
+	})
+}
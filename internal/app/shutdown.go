@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// shutdownMargin is kept out of the termination grace period for closing
+// connections after the lifecycle stopped.
+const shutdownMargin = time.Second
+
+type shutdownTimings struct {
+	drain   time.Duration // how long the instance stays up, not ready, before stopping
+	timeout time.Duration // how long stopping may take
+}
+
+// planShutdown fits draining and stopping into the termination grace
+// period, when known, so Kubernetes does not kill the instance while it
+// still finishes requests or events. Stopping is shortened first, then
+// draining; each is logged.
+func planShutdown(drain, timeout, grace time.Duration) shutdownTimings {
+	t := shutdownTimings{drain: drain, timeout: timeout}
+	if grace <= 0 {
+		return t
+	}
+	budget := grace - shutdownMargin
+	if t.drain+t.timeout <= budget {
+		return t
+	}
+	minTimeout := min(time.Second, t.timeout)
+	if t.drain+minTimeout > budget {
+		t.drain = max(budget-minTimeout, 0)
+		log.Printf("SHUTDOWN_DRAIN_DELAY %s does not fit TERMINATION_GRACE_PERIOD %s, draining for %s", drain, grace, t.drain)
+	}
+	t.timeout = max(budget-t.drain, 0)
+	log.Printf("SHUTDOWN_TIMEOUT %s does not fit TERMINATION_GRACE_PERIOD %s, stopping within %s", timeout, grace, t.timeout)
+	return t
+}
+
+// Drain takes the instance out of rotation: readiness fails from now on,
+// while requests are still served. It returns once SHUTDOWN_DRAIN_DELAY has
+// passed since the first call, giving load balancers time to notice, or
+// when ctx is done.
+func (a *App) Drain(ctx context.Context) {
+	a.drainOnce.Do(func() {
+		a.draining.Store(true)
+		a.drainUntil = time.Now().Add(a.shutdown.drain)
+		log.Printf("Draining %s for %s", a.instance.ID, a.shutdown.drain)
+	})
+	wait := time.NewTimer(time.Until(a.drainUntil))
+	defer wait.Stop()
+	select {
+	case <-wait.C:
+	case <-ctx.Done():
+	}
+}
+
+// Terminate drains the instance, unless a preStop hook already did, then
+// stops it within SHUTDOWN_TIMEOUT: in-flight requests finish and
+// consumers stop taking messages before connections are closed.
+func (a *App) Terminate() error {
+	a.Drain(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdown.timeout)
+	defer cancel()
+	return a.Stop(ctx)
+}
@@ -0,0 +1,773 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"order-service/internal/acceptance"
+	"order-service/internal/analytics"
+	"order-service/internal/anomaly"
+	"order-service/internal/audit"
+	"order-service/internal/backoff"
+	"order-service/internal/balance"
+	"order-service/internal/billing"
+	"order-service/internal/blocklist"
+	"order-service/internal/broker"
+	"order-service/internal/cart"
+	"order-service/internal/consumer"
+	"order-service/internal/dedupe"
+	"order-service/internal/degrade"
+	"order-service/internal/errreport"
+	"order-service/internal/eventorder"
+	"order-service/internal/events"
+	"order-service/internal/experiment"
+	"order-service/internal/featureflags"
+	"order-service/internal/fraud"
+	"order-service/internal/georestrict"
+	"order-service/internal/inventory"
+	"order-service/internal/jobs"
+	"order-service/internal/jsonenc"
+	"order-service/internal/leader"
+	"order-service/internal/leaderboard"
+	"order-service/internal/limits"
+	"order-service/internal/logging"
+	"order-service/internal/middleware"
+	"order-service/internal/migrate"
+	"order-service/internal/ordertemplate"
+	"order-service/internal/outbox"
+	"order-service/internal/payment"
+	"order-service/internal/podinfo"
+	"order-service/internal/quarantine"
+	"order-service/internal/quota"
+	"order-service/internal/reconciliation"
+	"order-service/internal/repository"
+	"order-service/internal/retention"
+	"order-service/internal/revenue"
+	"order-service/internal/rounding"
+	"order-service/internal/runtimeconfig"
+	"order-service/internal/savedsearch"
+	"order-service/internal/scheduler"
+	"order-service/internal/schema"
+	"order-service/internal/search"
+	"order-service/internal/secrets"
+	"order-service/internal/service"
+	"order-service/internal/sla"
+	"order-service/internal/stats"
+	"order-service/internal/subscription"
+	"order-service/internal/supervisor"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// App is the order service's object graph. New builds the core every mode
+// needs: connections, stores and the OrderService. Entrypoints then add the
+// parts they run (AddHTTPServer, AddConsumers, AddWorkers) and call Start.
+type App struct {
+	lifecycle
+
+	DB            *gorm.DB
+	Redis         *redis.Client
+	CacheRedis    redis.UniversalClient // Redis, or a ring of cache shards
+	Rabbit        *broker.Connection
+	Degradation   *degrade.Controller
+	Repo          *repository.OrderRepository
+	Cache         repository.IOrderCache
+	Outbox        *outbox.Store
+	Orders        *service.OrderService
+	OrderAPI      service.IOrderService // Orders behind logging, metrics, tracing and authorization
+	Blocklist     *blocklist.Store
+	GeoRules      *georestrict.Store
+	Jobs          *jobs.Store
+	Audit         *audit.Store
+	Retention     *retention.Enforcer
+	Stats         *stats.Store
+	Leaderboard   *leaderboard.Leaderboard
+	Revenue       *revenue.Store
+	SLA           *sla.Monitor
+	Anomalies     *anomaly.Detector
+	Subscriptions *subscription.Store
+	Templates     *ordertemplate.Store
+	SavedSearches *savedsearch.Store
+	Inventory     *inventory.Ledger
+	Discrepancies *reconciliation.Store
+	Quarantine    *quarantine.Store
+	Quotas        *quota.Quotas
+	Billing       *billing.Meter
+	Schemas       *schema.Registry
+	Events        *events.Bus // domain events of Orders, for modules that follow orders
+	Config        *runtimeconfig.Manager
+
+	secrets         secrets.Provider
+	retry           backoff.Policy
+	maxWait         time.Duration
+	skipMigrations  bool
+	relay           *outbox.Relay
+	rabbitPublisher *service.RabbitMQPublisher
+	eventDedupe     *dedupe.Store
+	deduper         *service.DedupingPublisher
+	sequenceTTL     time.Duration // how long event numbers are kept, 0 when events are not numbered
+	batcher         *service.BatchingPublisher
+	cacheRing       *redis.Ring
+	cacheShards     map[string]*redis.Client
+	outboxOnly      func() bool
+	sched           *scheduler.Scheduler
+	consumers       *consumer.Registry
+	indexer         *search.Indexer
+	exporter        *analytics.Exporter
+	paymentsEnabled bool
+	maxInstallments int
+
+	// encoder encodes cached order lists, order list responses and
+	// events; see JSON_ENCODER.
+	encoder jsonenc.Encoder
+	// reporter receives recovered panics and classified errors; see
+	// SENTRY_DSN and ERROR_REPORT_CLASSES.
+	reporter *errreport.Filter
+
+	// instance identifies this instance in logs, events and probes; see
+	// POD_NAME and INSTANCE_ID.
+	instance podinfo.Info
+	// shutdown is how long Terminate drains and stops; see
+	// SHUTDOWN_DRAIN_DELAY, SHUTDOWN_TIMEOUT and TERMINATION_GRACE_PERIOD.
+	shutdown   shutdownTimings
+	draining   atomic.Bool
+	drainOnce  sync.Once
+	drainUntil time.Time
+	// elector picks the replica that runs the jobs in leaderJobs; nil
+	// with LEADER_ELECTION=none. It campaigns once AddWorkers ran.
+	elector    *leader.Elector
+	leaderJobs map[string]bool
+	electing   bool
+
+	logLevel    *slog.LevelVar
+	orderCache  *repository.OrderCache
+	orderCounts *repository.OrderCounts
+	limiter     *limits.Limiter
+	acceptance  *acceptance.Engine
+	flags       *featureflags.Client
+	loadShedder *middleware.LoadShedder
+}
+
+type Option func(*App)
+
+// WithStartupWait overrides STARTUP_MAX_WAIT, e.g. for tools that should
+// fail fast.
+func WithStartupWait(d time.Duration) Option {
+	return func(a *App) {
+		a.maxWait = d
+	}
+}
+
+// WithSkipMigrations leaves migrating to another instance or a migration
+// job: New waits until the schema this build expects has been applied.
+func WithSkipMigrations() Option {
+	return func(a *App) {
+		a.skipMigrations = true
+	}
+}
+
+// WithLogLevel lets the configuration set, and reloads change, the level
+// of the logger that uses v.
+func WithLogLevel(v *slog.LevelVar) Option {
+	return func(a *App) {
+		a.logLevel = v
+	}
+}
+
+// WithInstance overrides the identity read from the environment.
+func WithInstance(info podinfo.Info) Option {
+	return func(a *App) {
+		a.instance = info
+	}
+}
+
+// New connects to the dependencies and wires the core. Postgres must come
+// up within STARTUP_MAX_WAIT; Redis and RabbitMQ may stay down, in which
+// case the app starts degraded.
+func New(ctx context.Context, opts ...Option) (*App, error) {
+	a := &App{
+		retry: backoff.Policy{
+			Initial: getEnvDuration("STARTUP_RETRY_INITIAL", 500*time.Millisecond),
+			Max:     getEnvDuration("STARTUP_RETRY_MAX", 10*time.Second),
+		},
+		maxWait: getEnvDuration("STARTUP_MAX_WAIT", time.Minute),
+		sched:   scheduler.New(),
+		lifecycle: lifecycle{
+			supervisor: supervisor.New(backoff.Policy{
+				Initial: getEnvDuration("SUPERVISOR_RESTART_INITIAL", time.Second),
+				Max:     getEnvDuration("SUPERVISOR_RESTART_MAX", time.Minute),
+			}, getEnvInt("SUPERVISOR_MAX_FAILURES", 5)),
+		},
+		consumers: consumer.NewRegistry(),
+		Events:    events.NewBus(),
+		instance:  podinfo.FromEnv(),
+		shutdown: planShutdown(
+			getEnvDuration("SHUTDOWN_DRAIN_DELAY", 0),
+			getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+			getEnvDuration("TERMINATION_GRACE_PERIOD", 0),
+		),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.Config = runtimeconfig.New(loadRuntimeConfig, os.Getenv("RUNTIME_CONFIG_FILE"))
+	if err := a.Config.Reload(); err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if a.logLevel != nil {
+		level, _ := runtimeconfig.ParseLevel(a.Config.Current().LogLevel)
+		a.logLevel.Set(level)
+	}
+	reporter, err := newErrorReporter()
+	if err != nil {
+		return nil, err
+	}
+	a.reporter = errreport.NewFilter(reporter, a.Config.Current().ErrorReportClasses)
+	a.Supervisor().SetReporter(a.reporter)
+	a.Events.SetReporter(a.reporter)
+	if err := a.connect(ctx); err != nil {
+		return nil, err
+	}
+
+	productEndpoints, err := newProductEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	if err := productEndpoints.Refresh(ctx); err != nil {
+		log.Printf("Failed to discover product-service, retrying in the background: %v", err)
+	}
+	a.sched.Add("product-service-discovery", getEnvDuration("PRODUCT_SERVICE_DISCOVERY_INTERVAL", 30*time.Second), productEndpoints.Refresh)
+	productClient, productCerts, err := newProductClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure product-service TLS: %w", err)
+	}
+	if productCerts != nil {
+		interval := getEnvDuration("TLS_RELOAD_INTERVAL", time.Minute)
+		a.Go("product-service-certs", func(ctx context.Context) { productCerts.Watch(ctx, interval) })
+	}
+	a.Degradation = newDegradationController(a.DB, a.Redis, a.cacheShards, a.Rabbit, productClient, productEndpoints)
+	a.Degradation.Probe(ctx)
+	a.sched.Add("dependency-check", getEnvDuration("DEPENDENCY_CHECK_INTERVAL", 10*time.Second), func(ctx context.Context) error {
+		a.Degradation.Probe(ctx)
+		return nil
+	})
+
+	if a.encoder, err = jsonenc.ByName(getEnv("JSON_ENCODER", jsonenc.NameFast)); err != nil {
+		return nil, fmt.Errorf("invalid JSON_ENCODER: %w", err)
+	}
+	a.Repo = repository.NewOrderRepository(a.DB, getEnvInt("DB_BATCH_SIZE", 100))
+	a.orderCache = repository.NewOrderCache(a.CacheRedis)
+	a.orderCache.SetCompressionThreshold(getEnvInt("CACHE_COMPRESSION_THRESHOLD", repository.DefaultCompressionThreshold))
+	a.orderCache.SetEncoder(a.encoder)
+	a.Cache = repository.NewBypassableCache(a.orderCache, func() bool {
+		return a.Degradation.Active(degrade.ModeCacheBypass)
+	})
+	a.orderCounts = repository.NewOrderCounts(a.CacheRedis)
+	a.orderCounts.SetTTL(getEnvDuration("ORDER_COUNT_TTL", repository.DefaultCountTTL))
+	a.orderCounts.SetBypass(func() bool { return a.Degradation.Active(degrade.ModeCacheBypass) })
+	a.rabbitPublisher = service.NewRabbitMQPublisher(a.Rabbit)
+	a.rabbitPublisher.SetInstance(a.instance.ID)
+	a.rabbitPublisher.SetEncoder(a.encoder)
+	a.Outbox = outbox.NewStore(a.DB)
+	a.outboxOnly = func() bool { return a.Degradation.Active(degrade.ModeOutboxOnly) }
+	// rabbit publishes to the broker, dropping events published before
+	// unless EVENT_DEDUPE_TTL is 0.
+	var rabbit interface {
+		service.IPublisher
+		service.IBatchPublisher
+	} = a.rabbitPublisher
+	a.eventDedupe = dedupe.NewStore(a.Redis, a.DB)
+	if ttl := getEnvDuration("EVENT_DEDUPE_TTL", 24*time.Hour); ttl > 0 {
+		a.deduper = service.NewDedupingPublisher(a.rabbitPublisher, a.eventDedupe, ttl)
+		rabbit = a.deduper
+	}
+	var sink service.IPublisher = rabbit
+	if size := getEnvInt("EVENT_BATCH_SIZE", 0); size > 1 {
+		a.batcher = service.NewBatchingPublisher(rabbit, a.Outbox, size,
+			getEnvDuration("EVENT_BATCH_LINGER", 50*time.Millisecond), getEnvList("EVENT_BATCH_SYNC_PATTERNS"))
+		sink = a.batcher
+	}
+	var publisher service.IPublisher = service.NewOutboxPublisher(sink, a.Outbox, a.outboxOnly)
+	if size := getEnvInt("EVENT_BUFFER_SIZE", 0); size > 0 {
+		buffered := service.NewBufferedPublisher(publisher, a.Outbox, size)
+		a.Go("event-publisher", buffered.Run)
+		publisher = buffered
+	}
+	if a.Schemas, err = schema.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load event schemas: %w", err)
+	}
+	publisher = service.NewValidatingPublisher(publisher, a.Schemas)
+	if a.sequenceTTL = getEnvDuration("EVENT_SEQUENCE_TTL", 30*24*time.Hour); a.sequenceTTL > 0 {
+		publisher = service.NewSequencingPublisher(publisher, eventorder.NewSequencer(a.Redis, a.sequenceTTL))
+	}
+	a.relay = outbox.NewRelay(a.Outbox, rabbit, getEnvInt("OUTBOX_RELAY_BATCH_SIZE", 100), a.outboxOnly)
+	a.Blocklist = blocklist.NewStore(a.DB, a.Redis, slog.Default())
+	a.GeoRules = georestrict.NewStore(a.DB, slog.Default())
+	if err := a.GeoRules.Load(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load geo restrictions: %w", err)
+	}
+	// Rules changed on other instances apply once they are loaded again.
+	a.sched.Add("geo-restrictions-refresh", getEnvDuration("GEO_RESTRICTIONS_REFRESH_INTERVAL", 30*time.Second), a.GeoRules.Load)
+	a.Jobs = jobs.NewStore(a.DB)
+	a.Jobs.SetReporter(a.reporter)
+	a.Audit = audit.NewStore(a.DB)
+	a.Stats = stats.NewStore(a.DB)
+	// A customer's cached LTV is held back for two rollups after an order,
+	// until the aggregates count it.
+	ltvCache := stats.NewLTVCache(a.CacheRedis, getEnvDuration("CUSTOMER_LTV_CACHE_TTL", time.Hour), 2*getEnvDuration("ORDER_STATS_INTERVAL", time.Minute))
+	ltvCache.SetBypass(func() bool { return a.Degradation.Active(degrade.ModeCacheBypass) })
+	a.Stats.SetLTVCache(ltvCache)
+	a.Events.Subscribe("customer-ltv", func(ctx context.Context, ev events.Event) error {
+		if ev.Order.CustomerID == "" {
+			return nil
+		}
+		return ltvCache.Invalidate(ctx, ev.Order.CustomerID)
+	}, events.OrderPlaced)
+	a.Quotas = quota.New(a.Redis, a.DB, a.Config.Current().RequestQuotas)
+	a.Leaderboard = leaderboard.New(a.Redis)
+	a.Events.Subscribe("leaderboard", func(ctx context.Context, ev events.Event) error {
+		return a.Leaderboard.Record(ctx, ev.Order.ProductID, ev.Order.Quantity)
+	}, events.OrderPlaced)
+	// New orders are counted into the cached counts; any other change
+	// may move an order between statuses, so the counts are reloaded.
+	a.Events.Subscribe("order-counts", func(ctx context.Context, ev events.Event) error {
+		if ev.Name == events.OrderCreated {
+			return a.orderCounts.Increment(ctx, ev.Order.ProductID, ev.Order.Status)
+		}
+		return a.orderCounts.Delete(ctx, ev.Order.ProductID)
+	}, events.OrderCreated, events.OrderUpdated, events.OrderValidated, events.OrderActivated)
+	a.Revenue = revenue.NewStore(a.DB)
+	thresholds, err := sla.ParseThresholds(os.Getenv("ORDER_SLAS"))
+	if err != nil {
+		return nil, err
+	}
+	a.Subscriptions = subscription.NewStore(a.DB)
+	a.Templates = ordertemplate.NewStore(a.DB)
+	a.SavedSearches = savedsearch.NewStore(a.DB)
+	a.Inventory = inventory.NewLedger(a.DB, publisher)
+	a.Discrepancies = reconciliation.NewStore(a.DB)
+	a.Quarantine = quarantine.NewStore(a.DB)
+	a.SLA = sla.NewMonitor(a.DB, thresholds, publisher, getEnvInt("SLA_CHECK_BATCH_SIZE", 100))
+	anomalies, err := anomalyConfig()
+	if err != nil {
+		return nil, err
+	}
+	a.Anomalies = anomaly.New(a.Redis, anomalies, publisher)
+	a.Events.Subscribe("anomaly-detection", func(ctx context.Context, ev events.Event) error {
+		return a.Anomalies.Record(ctx, ev.Order.ProductID)
+	}, events.OrderCreated)
+	a.Billing, err = billing.NewMeter(a.DB, getEnvDuration("BILLING_USAGE_PERIOD", time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	retentionRules, err := retention.ParseRules(os.Getenv("RETENTION_RULES"))
+	if err != nil {
+		return nil, err
+	}
+	a.Retention = retention.NewEnforcer(a.DB, retentionRules, a.Audit, getEnvInt("RETENTION_BATCH_SIZE", 500))
+
+	serviceOpts, err := a.serviceOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logger := logging.New(slog.Default(), getEnvFloat("LOG_DEBUG_SAMPLE_RATE", 1))
+	serviceOpts = append(serviceOpts,
+		service.WithLogger(logger),
+		service.WithReporter(a.reporter),
+		service.WithProductClient(productClient),
+		service.WithProductEndpoints(productEndpoints),
+		service.WithInventoryLedger(a.Inventory),
+		service.WithOrderCounts(a.orderCounts),
+		service.WithProductCache(getEnvDuration("PRODUCT_CACHE_TTL", 0), getEnvDuration("PRODUCT_NOT_FOUND_CACHE_TTL", 0)),
+	)
+	if a.deduper != nil {
+		serviceOpts = append(serviceOpts, service.WithEventDeduper(a.deduper))
+	}
+	a.Orders = service.NewOrderService(a.Repo, a.Cache, publisher, "", serviceOpts...)
+	a.OrderAPI = service.Decorate(a.Orders,
+		service.TracingInterceptor(logger),
+		service.LoggingInterceptor(logger),
+		service.MetricsInterceptor(),
+		service.AuthorizationInterceptor(service.AdminOperations...),
+	)
+
+	a.applyConfig(a.Config.Current())
+	a.Config.OnChange(a.applyConfig)
+	a.watchConfig()
+
+	if a.elector, err = newElector(a.Redis, a.instance.ID); err != nil {
+		return nil, err
+	}
+	jobs := getEnvList("LEADER_JOBS")
+	if len(jobs) == 0 {
+		jobs = defaultLeaderJobs
+	}
+	a.leaderJobs = make(map[string]bool, len(jobs))
+	for _, job := range jobs {
+		a.leaderJobs[job] = true
+	}
+
+	a.Append(Hook{
+		Name: "scheduler",
+		Start: func(context.Context) error {
+			for _, job := range a.sched.Jobs() {
+				if a.elector != nil && a.leaderJobs[job.Name] {
+					job.Run = a.elector.Guard(job.Run)
+				}
+				a.spawn("job:"+job.Name, func(ctx context.Context) error {
+					scheduler.Loop(ctx, job)
+					return nil
+				})
+			}
+			return nil
+		},
+	})
+	return a, nil
+}
+
+// Stop stops the hooks and background tasks, then closes the connections.
+func (a *App) Stop(ctx context.Context) error {
+	return errors.Join(a.lifecycle.Stop(ctx), a.close())
+}
+
+func (a *App) connect(ctx context.Context) error {
+	var err error
+	a.secrets, err = newSecretsProvider(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to configure secrets provider: %w", err)
+	}
+	if err := a.openDatabase(ctx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	instrumentation := repository.NewQueryInstrumentation(
+		getEnvDuration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+		getEnvFloat("DB_QUERY_LOG_SAMPLE_RATE", 0),
+		slog.Default(),
+	)
+	a.loadShedder = middleware.NewLoadShedder(middleware.LoadShedConfig{
+		MaxInFlight: getEnvInt("LOAD_SHED_MAX_IN_FLIGHT", 0),
+		DBLatency:   getEnvDuration("LOAD_SHED_DB_LATENCY", 0),
+		RetryAfter:  getEnvDuration("LOAD_SHED_RETRY_AFTER", time.Second),
+	})
+	instrumentation.OnQuery(a.loadShedder.ObserveDBLatency)
+	if err := a.DB.Use(instrumentation); err != nil {
+		return fmt.Errorf("failed to register query instrumentation: %w", err)
+	}
+	if err := a.migrate(ctx, models()...); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	redisPassword := os.Getenv("REDIS_PASSWORD")
+	if secret, err := a.readSecret(ctx, "REDIS_SECRET"); err != nil {
+		return err
+	} else if v, ok := secret["password"]; ok {
+		redisPassword = v
+	}
+	a.Redis = redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", os.Getenv("REDIS_HOST"), os.Getenv("REDIS_PORT")),
+		Password: redisPassword,
+	})
+	err = backoff.Retry(ctx, "Redis", a.retry, a.maxWait, func(ctx context.Context) error {
+		return a.Redis.Ping(ctx).Err()
+	})
+	if err != nil {
+		log.Printf("Starting without Redis, cache is bypassed: %v", err)
+	}
+	a.CacheRedis = a.Redis
+	if addrs := getEnvList("ORDER_CACHE_REDIS_ADDRS"); len(addrs) > 0 {
+		a.cacheRing, a.cacheShards, err = newCacheRing(addrs, redisPassword)
+		if err != nil {
+			return fmt.Errorf("invalid ORDER_CACHE_REDIS_ADDRS: %w", err)
+		}
+		a.CacheRedis = a.cacheRing
+	}
+
+	rabbitSecret, err := a.readSecret(ctx, "RABBITMQ_SECRET")
+	if err != nil {
+		return err
+	}
+	rabbitURL, err := withCredentials(os.Getenv("RABBITMQ_URL"), rabbitSecret)
+	if err != nil {
+		return fmt.Errorf("invalid RABBITMQ_URL: %w", err)
+	}
+	a.Rabbit = broker.New(rabbitURL)
+	if rabbitSecret != nil {
+		a.Go("rabbitmq-secret", func(ctx context.Context) {
+			secrets.Watch(ctx, a.secrets, os.Getenv("RABBITMQ_SECRET"), a.secretsInterval(), rabbitSecret, func(secret map[string]string) {
+				url, err := withCredentials(os.Getenv("RABBITMQ_URL"), secret)
+				if err != nil {
+					log.Printf("Ignoring rotated RabbitMQ credentials: %v", err)
+					return
+				}
+				// Takes effect on the next reconnect; the open connection
+				// stays authenticated.
+				a.Rabbit.SetURL(url)
+			})
+		})
+	}
+	err = backoff.Retry(ctx, "RabbitMQ", a.retry, a.maxWait, func(ctx context.Context) error {
+		return a.Rabbit.Connect()
+	})
+	if err != nil {
+		log.Printf("Starting without RabbitMQ, events go to the outbox: %v", err)
+	}
+	a.Go("rabbitmq-reconnect", func(ctx context.Context) { a.Rabbit.Run(ctx, a.retry) })
+	return nil
+}
+
+// applyConfig pushes reloadable settings into the components that use
+// them.
+func (a *App) applyConfig(cfg runtimeconfig.Config) {
+	if a.logLevel != nil {
+		level, _ := runtimeconfig.ParseLevel(cfg.LogLevel)
+		a.logLevel.Set(level)
+	}
+	a.limiter.SetConfig(cfg.PurchaseLimits)
+	a.Quotas.SetConfig(cfg.RequestQuotas)
+	a.acceptance.SetRules(cfg.AcceptanceRules)
+	a.orderCache.SetTTL(time.Duration(cfg.CacheTTL))
+	a.reporter.SetClasses(cfg.ErrorReportClasses)
+	if cfg.FeatureFlags != nil && !a.flags.SetRules(cfg.FeatureFlags) {
+		log.Printf("Ignoring featureFlags from configuration, flags are managed by the %s provider", os.Getenv("FEATURE_FLAGS_PROVIDER"))
+	}
+}
+
+// watchConfig reloads the configuration on SIGHUP, when the config file
+// changes and, if RUNTIME_CONFIG_RELOAD_CHANNEL is set, on a message on
+// that Redis channel.
+func (a *App) watchConfig() {
+	a.Go("config-sighup", a.Config.WatchSignal)
+	if os.Getenv("RUNTIME_CONFIG_FILE") != "" {
+		interval := getEnvDuration("RUNTIME_CONFIG_WATCH_INTERVAL", 10*time.Second)
+		a.Go("config-file-watch", func(ctx context.Context) { a.Config.WatchFile(ctx, interval) })
+	}
+	if channel := os.Getenv("RUNTIME_CONFIG_RELOAD_CHANNEL"); channel != "" {
+		a.Go("config-subscribe", func(ctx context.Context) { a.Config.Subscribe(ctx, a.Redis, channel) })
+	}
+}
+
+// Migrate creates or updates the service's tables, unless another
+// instance already did. See package migrate.
+func Migrate(ctx context.Context, db *gorm.DB) error {
+	return migrate.Run(ctx, db, models()...)
+}
+
+// models are the tables of the service.
+func models() []interface{} {
+	return []interface{}{
+		&repository.Order{}, &repository.Installment{}, &repository.Tender{}, &repository.OrderTag{}, &repository.OrderRevision{},
+		&repository.CartCheckout{},
+		&blocklist.Entry{}, &georestrict.Rule{}, &jobs.Job{}, &outbox.Message{}, &audit.Entry{},
+		&stats.DailyProductStats{}, &stats.DailyCustomerStats{}, &stats.Watermark{}, &revenue.ClosedPeriod{}, &revenue.Adjustment{},
+		&subscription.Subscription{}, &ordertemplate.Template{}, &savedsearch.Search{},
+		&inventory.Hold{}, &inventory.Level{}, &reconciliation.Discrepancy{},
+		&quarantine.Message{}, &quota.DailyUsage{}, &billing.Period{}, &billing.Usage{},
+		&dedupe.Entry{},
+	}
+}
+
+// migrate brings the tables of models up to date or, with
+// WithSkipMigrations, waits up to STARTUP_MAX_WAIT for another instance
+// or a migration job to do it.
+func (a *App) migrate(ctx context.Context, models ...interface{}) error {
+	if !a.skipMigrations {
+		return migrate.Run(ctx, a.DB, models...)
+	}
+	return backoff.Retry(ctx, "the migrated database schema", a.retry, a.maxWait, func(ctx context.Context) error {
+		return migrate.Check(ctx, a.DB, models...)
+	})
+}
+
+// DrainOutbox publishes pending outbox messages once and returns how many
+// were sent.
+func (a *App) DrainOutbox(ctx context.Context) (int, error) {
+	return a.relay.Drain(ctx)
+}
+
+func (a *App) close() error {
+	var errs []error
+	if a.batcher != nil {
+		a.batcher.Flush()
+	}
+	if a.reporter != nil {
+		a.reporter.Flush(5 * time.Second)
+	}
+	if err := a.Rabbit.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := a.Redis.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if a.cacheRing != nil {
+		if err := a.cacheRing.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if sqlDB, err := a.DB.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// analyticsEvent spools the warehouse event of an order change. Orders
+// validated after product-service came back are created as far as the
+// warehouse is concerned; rejected ones never were.
+func analyticsEvent(tx *gorm.DB, change string, order *repository.Order) error {
+	eventType := "order.created"
+	switch change {
+	case repository.ChangeValidated, repository.ChangeActivated:
+		if order.Status == repository.StatusRejected {
+			return nil
+		}
+		if change == repository.ChangeActivated {
+			eventType = "order.activated"
+		}
+	}
+	data := *order
+	data.PaymentClientSecret = ""
+	return analytics.Spool(tx, eventType, &data)
+}
+
+func (a *App) serviceOptions(ctx context.Context) ([]service.Option, error) {
+	cfg := a.Config.Current()
+	var refreshFlags func(ctx context.Context)
+	var err error
+	a.flags, refreshFlags, err = newFeatureFlags(a.Redis, cfg.FeatureFlags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure feature flags: %w", err)
+	}
+	if refreshFlags != nil {
+		a.Go("feature-flags", refreshFlags)
+	}
+	a.limiter = limits.NewLimiter(a.Redis, cfg.PurchaseLimits)
+	a.acceptance = acceptance.NewEngine(cfg.AcceptanceRules)
+	opts := []service.Option{
+		service.WithDegradation(a.Degradation),
+		service.WithFeatureFlags(a.flags),
+		service.WithShippingFee(getEnvFloat("SHIPPING_FEE", 0)),
+		service.WithBlocklist(a.Blocklist),
+		service.WithGeoRestrictions(a.GeoRules),
+		service.WithPurchaseLimiter(a.limiter),
+		service.WithAcceptanceRules(a.acceptance),
+		service.WithEventBus(a.Events),
+		service.WithSubscriptions(a.Subscriptions, backoff.Policy{
+			Initial: getEnvDuration("SUBSCRIPTION_RETRY_INITIAL", time.Hour),
+			Max:     getEnvDuration("SUBSCRIPTION_RETRY_MAX", 24*time.Hour),
+		}, getEnvInt("SUBSCRIPTION_MAX_PAYMENT_ATTEMPTS", 3)),
+		service.WithReservations(getEnvDuration("ORDER_RESERVATION_TTL", 15*time.Minute)),
+		service.WithScheduling(getEnvDuration("SCHEDULED_ORDER_LEAD_TIME", 24*time.Hour), getEnvDuration("SCHEDULED_ORDER_MAX_AHEAD", 90*24*time.Hour)),
+	}
+
+	discounts, err := service.ParseDiscountCodes(os.Getenv("DISCOUNT_CODES"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure discount codes: %w", err)
+	}
+	tenantPricing, err := service.ParseTenantPricing(os.Getenv("TENANT_PRICING_RULES"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure tenant pricing: %w", err)
+	}
+	opts = append(opts,
+		service.WithTaxRate(getEnvFloat("TAX_RATE", 0)),
+		service.WithDiscountCodes(discounts),
+		service.WithTenantPricing(tenantPricing),
+	)
+
+	rates, err := newExchangeRates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure exchange rates: %w", err)
+	}
+	opts = append(opts, service.WithCurrency(getEnv("DEFAULT_CURRENCY", "IDR"), rates))
+
+	roundingPolicy, err := rounding.Parse(os.Getenv("ROUNDING_POLICY"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure rounding policy: %w", err)
+	}
+	opts = append(opts, service.WithRoundingPolicy(roundingPolicy))
+
+	priceFormat := getEnv("PRODUCT_PRICE_FORMAT", service.PriceFormatAny)
+	if !slices.Contains(service.PriceFormats, priceFormat) {
+		return nil, fmt.Errorf("invalid PRODUCT_PRICE_FORMAT %q, expected one of %s", priceFormat, strings.Join(service.PriceFormats, ", "))
+	}
+	opts = append(opts, service.WithPriceFormat(priceFormat))
+
+	if cartURL := os.Getenv("CART_SERVICE_URL"); cartURL != "" {
+		opts = append(opts, service.WithCartClient(cart.NewHTTPClient(cartURL)))
+	}
+
+	if balanceURL := os.Getenv("BALANCE_SERVICE_URL"); balanceURL != "" {
+		opts = append(opts, service.WithBalanceClient(balance.NewHTTPClient(balanceURL)))
+	}
+
+	opts = append(opts, service.WithBackorders(os.Getenv("BACKORDERS_ENABLED") == "true"))
+	a.maxInstallments = getEnvInt("MAX_INSTALLMENTS", 0)
+	opts = append(opts, service.WithInstallments(a.maxInstallments))
+
+	if paymentURL := os.Getenv("PAYMENT_SERVICE_URL"); paymentURL != "" {
+		a.paymentsEnabled = true
+		opts = append(opts,
+			service.WithPaymentGateway(
+				payment.NewHTTPGateway(paymentURL),
+				getEnvDuration("PAYMENT_INTENT_TTL", 30*time.Minute),
+			),
+			service.WithPaymentReconciliation(a.Discrepancies, getEnvDuration("PAYMENT_RECONCILE_LOOKBACK", 48*time.Hour)),
+		)
+	}
+
+	if fraudURL := os.Getenv("FRAUD_SERVICE_URL"); fraudURL != "" {
+		opts = append(opts, service.WithFraudChecker(
+			fraud.NewHTTPChecker(fraudURL),
+			getEnvDuration("FRAUD_CHECK_TIMEOUT", 2*time.Second),
+			getEnv("FRAUD_CHECK_FAIL_MODE", "open") == "open",
+		))
+	}
+
+	if def := os.Getenv("PRICING_EXPERIMENT"); def != "" {
+		exp, err := experiment.Parse(def)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure pricing experiment: %w", err)
+		}
+		opts = append(opts, service.WithPricingExperiment(exp))
+	}
+
+	if opensearchURL := os.Getenv("OPENSEARCH_URL"); opensearchURL != "" {
+		searchClient := search.NewClient(opensearchURL, getEnv("OPENSEARCH_INDEX", "orders"))
+		if err := searchClient.EnsureIndex(ctx); err != nil {
+			log.Printf("Failed to ensure search index, searches will fall back to Postgres: %v", err)
+		}
+		a.indexer = search.NewIndexer(searchClient, getEnvInt("OPENSEARCH_INDEX_QUEUE_SIZE", 10000))
+		a.Go("search-indexer", a.indexer.Run)
+		opts = append(opts,
+			service.WithSearchIndex(search.NewFallbackSearcher(search.NewRepository(searchClient), a.Repo)),
+		)
+		a.indexer.Subscribe(a.Events)
+	}
+
+	if os.Getenv("ANALYTICS_EXPORT_ENABLED") == "true" {
+		if err := a.migrate(ctx, &analytics.Event{}, &analytics.Checkpoint{}); err != nil {
+			return nil, fmt.Errorf("failed to migrate analytics tables: %w", err)
+		}
+		store, err := newAnalyticsStore()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure analytics store: %w", err)
+		}
+		a.exporter = analytics.NewExporter(a.DB, store, analytics.ExporterConfig{
+			Prefix:    os.Getenv("ANALYTICS_EXPORT_PREFIX"),
+			BatchSize: getEnvInt("ANALYTICS_EXPORT_BATCH_SIZE", 1000),
+			Interval:  getEnvDuration("ANALYTICS_EXPORT_INTERVAL", time.Minute),
+			Gzip:      os.Getenv("ANALYTICS_EXPORT_GZIP") == "true",
+		})
+		a.Repo.OnChange(analyticsEvent)
+	}
+	return opts, nil
+}
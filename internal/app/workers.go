@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"log"
+	"order-service/internal/consumer"
+	"order-service/internal/eventorder"
+	"order-service/internal/handler"
+	"order-service/internal/leader"
+	"os"
+	"time"
+)
+
+// AddConsumers subscribes to the queues the service reacts to and samples
+// their lag. Messages that keep failing are quarantined, and numbered
+// events older than one already handled for their order are skipped.
+func (a *App) AddConsumers() {
+	handlers := a.eventHandlers()
+	quarantined := consumer.WithQuarantine(a.Quarantine, handler.ClassifyEventError)
+	validated := consumer.WithValidator(a.Schemas)
+	reported := consumer.WithReporter(a.reporter)
+	opts := []consumer.Option{quarantined, validated, reported}
+	if a.sequenceTTL > 0 {
+		opts = append(opts, consumer.WithOrdering(eventorder.NewTracker(a.Redis, "order-service", a.sequenceTTL)))
+	}
+
+	stockConsumer := consumer.New(a.Rabbit, stockReplenishedQueue(), handlers[stockReplenishedQueue()], a.retry, opts...)
+	a.consumers.Add(stockConsumer)
+	a.Supervise("stock-replenished-consumer", stockConsumer.Run)
+	if a.maxInstallments > 1 {
+		installmentConsumer := consumer.New(a.Rabbit, installmentPaidQueue(), handlers[installmentPaidQueue()], a.retry, opts...)
+		a.consumers.Add(installmentConsumer)
+		a.Supervise("installment-paid-consumer", installmentConsumer.Run)
+	}
+	a.sched.Add("consumer-lag", getEnvDuration("CONSUMER_LAG_INTERVAL", 15*time.Second), func(ctx context.Context) error {
+		a.consumers.Sample(a.Rabbit)
+		return nil
+	})
+}
+
+// eventHandlers maps the queues the service may consume to their handlers.
+func (a *App) eventHandlers() map[string]consumer.HandlerFunc {
+	eventHandler := handler.NewEventHandler(a.OrderAPI)
+	return map[string]consumer.HandlerFunc{
+		stockReplenishedQueue(): eventHandler.StockReplenished,
+		installmentPaidQueue():  eventHandler.InstallmentPaid,
+	}
+}
+
+// consumerQueues lists the queues AddConsumers subscribes to.
+func (a *App) consumerQueues() []string {
+	queues := []string{stockReplenishedQueue()}
+	if a.maxInstallments > 1 {
+		queues = append(queues, installmentPaidQueue())
+	}
+	return queues
+}
+
+func stockReplenishedQueue() string {
+	return getEnv("STOCK_REPLENISHED_QUEUE", "product.stock_replenished")
+}
+
+func installmentPaidQueue() string {
+	return getEnv("INSTALLMENT_PAID_QUEUE", "payment.installment_paid")
+}
+
+// AddWorkers schedules the periodic jobs, one sched.Add each under the
+// name LEADER_JOBS refers to; the jobs listed there run on the elected
+// leader only. It also starts the leader election, the analytics export
+// and, when enabled, the one-off order stats backfill and search reindex.
+func (a *App) AddWorkers() {
+	if a.paymentsEnabled {
+		a.sched.Add("expire-payments", getEnvDuration("PAYMENT_EXPIRY_INTERVAL", time.Minute), func(ctx context.Context) error {
+			_, err := a.Orders.ExpirePayments(ctx)
+			return err
+		})
+		a.sched.Add("payment-reconciliation", getEnvDuration("PAYMENT_RECONCILE_INTERVAL", 24*time.Hour), func(ctx context.Context) error {
+			_, err := a.Orders.ReconcilePayments(ctx)
+			return err
+		})
+	}
+	a.sched.Add("outbox-relay", getEnvDuration("OUTBOX_RELAY_INTERVAL", 5*time.Second), func(ctx context.Context) error {
+		_, err := a.DrainOutbox(ctx)
+		return err
+	})
+	a.sched.Add("event-dedupe-purge", getEnvDuration("EVENT_DEDUPE_PURGE_INTERVAL", time.Hour), func(ctx context.Context) error {
+		_, err := a.eventDedupe.Purge(ctx, time.Now())
+		return err
+	})
+	a.sched.Add("validate-pending-orders", getEnvDuration("DEPENDENCY_CHECK_INTERVAL", 10*time.Second), func(ctx context.Context) error {
+		_, err := a.Orders.ValidatePendingOrders(ctx)
+		return err
+	})
+	a.sched.Add("expire-reservations", getEnvDuration("RESERVATION_EXPIRY_INTERVAL", 30*time.Second), func(ctx context.Context) error {
+		_, err := a.Orders.ExpireReservations(ctx)
+		return err
+	})
+	a.sched.Add("inventory-reconcile", getEnvDuration("INVENTORY_RECONCILE_INTERVAL", 5*time.Minute), func(ctx context.Context) error {
+		_, err := a.Inventory.Reconcile(ctx, a.Orders.ProductStock)
+		return err
+	})
+	a.sched.Add("activate-scheduled-orders", getEnvDuration("SCHEDULED_ORDER_INTERVAL", time.Minute), func(ctx context.Context) error {
+		_, err := a.Orders.ActivateScheduledOrders(ctx)
+		return err
+	})
+	a.sched.Add("subscription-orders", getEnvDuration("SUBSCRIPTION_INTERVAL", time.Minute), func(ctx context.Context) error {
+		_, err := a.Orders.GenerateSubscriptionOrders(ctx)
+		return err
+	})
+	lookback := getEnvDuration("ORDER_STATS_LOOKBACK", 7*24*time.Hour)
+	a.sched.Add("order-stats-rollup", getEnvDuration("ORDER_STATS_INTERVAL", time.Minute), func(ctx context.Context) error {
+		_, err := a.Stats.Rollup(ctx, time.Now().Add(-lookback))
+		return err
+	})
+	if a.SLA.Enabled() {
+		a.sched.Add("sla-check", getEnvDuration("SLA_CHECK_INTERVAL", time.Minute), func(ctx context.Context) error {
+			_, err := a.SLA.Check(ctx)
+			return err
+		})
+	}
+	a.sched.Add("revenue-adjustments", getEnvDuration("REVENUE_ADJUSTMENT_INTERVAL", 24*time.Hour), func(ctx context.Context) error {
+		_, err := a.Revenue.PostAdjustments(ctx)
+		return err
+	})
+	a.sched.Add("quota-flush", getEnvDuration("QUOTA_FLUSH_INTERVAL", time.Minute), func(ctx context.Context) error {
+		_, err := a.Quotas.Flush(ctx)
+		return err
+	})
+	a.sched.Add("billing-usage", getEnvDuration("BILLING_USAGE_INTERVAL", 5*time.Minute), func(ctx context.Context) error {
+		_, err := a.Billing.Publish(ctx)
+		return err
+	})
+	a.sched.Add("top-products-reconcile", getEnvDuration("TOP_PRODUCTS_RECONCILE_INTERVAL", time.Hour), func(ctx context.Context) error {
+		return a.Leaderboard.Reconcile(ctx, a.DB)
+	})
+	if os.Getenv("ORDER_STATS_BACKFILL_ON_START") == "true" {
+		a.GoOnce("order-stats-backfill", func(ctx context.Context) error {
+			_, err := a.Stats.Rebuild(ctx, time.Time{})
+			return err
+		})
+	}
+	if os.Getenv("RETENTION_RULES") != "" {
+		dryRun := os.Getenv("RETENTION_DRY_RUN") == "true"
+		a.sched.Add("retention", getEnvDuration("RETENTION_INTERVAL", 24*time.Hour), func(ctx context.Context) error {
+			report, err := a.Retention.Run(ctx, dryRun)
+			for _, res := range report.Results {
+				log.Printf("Retention %s (tenant %q, statuses %v, older than %s): matched %d, changed %d, dry run %t",
+					res.Rule.Action, res.Rule.Tenant, res.Rule.Statuses, res.Cutoff.Format(time.DateOnly), res.Matched, res.Affected, dryRun)
+			}
+			return err
+		})
+	}
+
+	if a.elector != nil {
+		a.electing = true
+		a.Go("leader-election", a.elector.Run)
+	}
+	if a.exporter != nil {
+		a.Go("analytics-exporter", a.exporter.Run)
+	}
+	if a.indexer != nil && os.Getenv("OPENSEARCH_REINDEX_ON_START") == "true" {
+		a.GoOnce("search-reindex", func(ctx context.Context) error {
+			return a.indexer.Reindex(ctx, a.Repo)
+		})
+	}
+}
+
+// leadership reports the election this instance takes part in, if any.
+func (a *App) leadership() *leader.Status {
+	if !a.electing {
+		return nil
+	}
+	status := a.elector.Status()
+	return &status
+}
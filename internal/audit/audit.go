@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Actions recorded for admin mutations.
+const (
+	ActionOrderApprove        = "order.approve"
+	ActionOrderReject         = "order.reject"
+	ActionOrderReplay         = "order.replay"
+	ActionOrderForceStatus    = "order.force_status"
+	ActionOrderTag            = "order.tag"
+	ActionOrderUntag          = "order.untag"
+	ActionOrderImport         = "order.import"
+	ActionOrderSeed           = "order.seed"
+	ActionBlocklistAdd        = "blocklist.add"
+	ActionBlocklistRemove     = "blocklist.remove"
+	ActionGeoRuleAdd          = "geo_restriction.add"
+	ActionGeoRuleUpdate       = "geo_restriction.update"
+	ActionGeoRuleRemove       = "geo_restriction.remove"
+	ActionCacheInvalidate     = "cache.invalidate"
+	ActionOutboxDrain         = "outbox.drain"
+	ActionRevenueClose        = "revenue.close"
+	ActionRetentionPurge      = "retention.purge"
+	ActionRetentionAnonymize  = "retention.anonymize"
+	ActionQuarantineReprocess = "quarantine.reprocess"
+	ActionQuarantineDiscard   = "quarantine.discard"
+)
+
+// Entry records one admin mutation. Before and After are snapshots of the
+// target around the change; either is empty when the action creates or
+// removes the target, or has no state to show. Reason is why the admin
+// did it, for actions that ask for one.
+type Entry struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	Actor     string      `gorm:"not null;index" json:"actor"`
+	Action    string      `gorm:"not null;index" json:"action"`
+	Target    string      `gorm:"index" json:"target"`
+	Before    interface{} `gorm:"type:jsonb;serializer:json" json:"before,omitempty"`
+	After     interface{} `gorm:"type:jsonb;serializer:json" json:"after,omitempty"`
+	IP        string      `json:"ip,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	CreatedAt time.Time   `gorm:"index" json:"createdAt"`
+}
+
+func (Entry) TableName() string { return "admin_audit" }
+
+// Filter narrows List. Zero fields match everything.
+type Filter struct {
+	Actor  string
+	Action string
+	Target string
+	From   time.Time
+	To     time.Time
+}
+
+// Store appends entries to the admin_audit table. Entries are never
+// updated or deleted by the service.
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Record(ctx context.Context, e *Entry) error {
+	e.ID = 0
+	return s.db.WithContext(ctx).Create(e).Error
+}
+
+// List returns matching entries, newest first.
+func (s *Store) List(ctx context.Context, f Filter, limit, offset int) ([]Entry, error) {
+	q := s.db.WithContext(ctx).Model(&Entry{})
+	if f.Actor != "" {
+		q = q.Where("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.Target != "" {
+		q = q.Where("target = ?", f.Target)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("created_at <= ?", f.To)
+	}
+	var entries []Entry
+	err := q.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&entries).Error
+	return entries, err
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the admin performing a request.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the admin stored by WithActor, or "".
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
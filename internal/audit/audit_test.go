@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+func TestList(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2026, 10, 1, h, 0, 0, 0, time.UTC) }
+
+	t.Run("without filters, newest first", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mock.ExpectQuery(quoted(`SELECT * FROM "admin_audit" ORDER BY created_at DESC, id DESC LIMIT $1`)).
+			WithArgs(50).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "actor", "action", "created_at"}).
+				AddRow(2, "bob", ActionOrderReject, at(12)).
+				AddRow(1, "alice", ActionOrderApprove, at(9)))
+
+		entries, err := NewStore(db).List(context.Background(), Filter{}, 50, 0)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(entries) != 2 || entries[0].ID != 2 || entries[1].Actor != "alice" {
+			t.Errorf("Expected the entries in the order of the query, got %+v", entries)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("every filter with a page", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mock.ExpectQuery(quoted(`SELECT * FROM "admin_audit" WHERE actor = $1 AND action = $2 AND target = $3 AND created_at >= $4 AND created_at <= $5 ORDER BY created_at DESC, id DESC LIMIT $6 OFFSET $7`)).
+			WithArgs("alice", ActionBlocklistAdd, "7", at(0), at(23), 10, 20).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		entries, err := NewStore(db).List(context.Background(), Filter{
+			Actor:  "alice",
+			Action: ActionBlocklistAdd,
+			Target: "7",
+			From:   at(0),
+			To:     at(23),
+		}, 10, 20)
+		if err != nil || len(entries) != 0 {
+			t.Errorf("Expected no entries, got %+v, %v", entries, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("an open range", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mock.ExpectQuery(quoted(`SELECT * FROM "admin_audit" WHERE created_at >= $1 ORDER BY created_at DESC, id DESC LIMIT $2`)).
+			WithArgs(at(6), 5).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		if _, err := NewStore(db).List(context.Background(), Filter{From: at(6)}, 5, 0); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
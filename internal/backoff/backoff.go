@@ -0,0 +1,57 @@
+package backoff
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Policy doubles the delay after every failed attempt, starting at Initial
+// and capped at Max.
+type Policy struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Delay returns how long to wait before retry number attempt (0-based).
+func (p Policy) Delay(attempt int) time.Duration {
+	d := p.Initial
+	if d <= 0 {
+		d = 500 * time.Millisecond
+	}
+	for i := 0; i < attempt; i++ {
+		d *= 2
+		if p.Max > 0 && d >= p.Max {
+			return p.Max
+		}
+	}
+	return d
+}
+
+// Retry calls fn until it succeeds, ctx is cancelled or maxWait has passed
+// since the first attempt. It returns the last error of fn when it gives
+// up.
+func Retry(ctx context.Context, name string, p Policy, maxWait time.Duration, fn func(ctx context.Context) error) error {
+	deadline := time.Now().Add(maxWait)
+	for attempt := 0; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			if attempt > 0 {
+				log.Printf("Connected to %s after %d retries", name, attempt)
+			}
+			return nil
+		}
+
+		delay := p.Delay(attempt)
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("%s unavailable after %s: %w", name, maxWait, err)
+		}
+		log.Printf("Waiting for %s, retrying in %s: %v", name, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	p := Policy{Initial: 100 * time.Millisecond, Max: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for attempt, w := range want {
+		if got := p.Delay(attempt); got != w {
+			t.Errorf("Expected %s for attempt %d, got %s", w, attempt, got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	p := Policy{Initial: time.Millisecond, Max: 5 * time.Millisecond}
+
+	t.Run("succeeds after failures", func(t *testing.T) {
+		calls := 0
+		err := Retry(context.Background(), "test", p, time.Second, func(ctx context.Context) error {
+			calls++
+			if calls < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("Expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("gives up after max wait", func(t *testing.T) {
+		down := errors.New("down")
+		err := Retry(context.Background(), "test", p, 20*time.Millisecond, func(ctx context.Context) error {
+			return down
+		})
+		if !errors.Is(err, down) {
+			t.Errorf("Expected the last error, got %v", err)
+		}
+	})
+}
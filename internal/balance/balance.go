@@ -0,0 +1,94 @@
+package balance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	KindGiftCard    = "gift_card"
+	KindStoreCredit = "store_credit"
+)
+
+var ErrBalanceNotFound = errors.New("balance not found")
+
+// Redemption is an amount taken from a gift card or store credit balance.
+// It can be reversed if the order is not placed after all.
+type Redemption struct {
+	ID     string  `json:"id"`
+	Amount float64 `json:"amount"`
+}
+
+// HTTPClient redeems balances through balance-service.
+type HTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewHTTPClient(baseURL string) *HTTPClient {
+	return &HTTPClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Redeem takes up to maxAmount from the balance identified by kind and
+// reference (a gift card code or a customer ID). The redeemed amount is
+// lower when the balance does not cover maxAmount.
+func (c *HTTPClient) Redeem(ctx context.Context, kind, reference, orderID string, maxAmount float64) (*Redemption, error) {
+	var r Redemption
+	err := c.post(ctx, "/redemptions", map[string]interface{}{
+		"kind":      kind,
+		"reference": reference,
+		"orderId":   orderID,
+		"maxAmount": maxAmount,
+	}, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Reverse returns a redeemed amount to its balance.
+func (c *HTTPClient) Reverse(ctx context.Context, redemptionID string) error {
+	return c.post(ctx, "/redemptions/"+url.PathEscape(redemptionID)+"/reverse", map[string]interface{}{}, nil)
+}
+
+func (c *HTTPClient) post(ctx context.Context, path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call balance service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrBalanceNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("balance service returned status: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode balance response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+// Package billing publishes the order volume of every tenant to the billing
+// pipeline: each closed period, one usage.orders_created event per tenant
+// with the number and value of the orders created in it. What was
+// published is kept so it can be reconciled against the orders.
+package billing
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"order-service/internal/outbox"
+	"order-service/internal/repository"
+	"order-service/internal/rounding"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const UsagePattern = "usage.orders_created"
+
+var round = rounding.HalfUp{Places: 2}.Round
+
+// Period is a published period.
+type Period struct {
+	Start       time.Time `gorm:"primaryKey"`
+	End         time.Time `gorm:"not null"`
+	Tenants     int       `gorm:"not null"`
+	PublishedAt time.Time
+}
+
+func (Period) TableName() string { return "billing_usage_periods" }
+
+// Usage is the published volume of a tenant's orders in one currency.
+type Usage struct {
+	PeriodStart time.Time `gorm:"primaryKey"`
+	TenantID    string    `gorm:"primaryKey"`
+	Currency    string    `gorm:"primaryKey"`
+	Orders      int64     `gorm:"not null"`
+	Revenue     float64   `gorm:"not null"`
+}
+
+func (Usage) TableName() string { return "billing_usage" }
+
+// Meter publishes usage for periods of a fixed length, aligned to UTC
+// midnight.
+type Meter struct {
+	db     *gorm.DB
+	period time.Duration
+	now    func() time.Time
+}
+
+// NewMeter publishes usage every period, which must divide a day.
+func NewMeter(db *gorm.DB, period time.Duration) (*Meter, error) {
+	if period <= 0 || (24*time.Hour)%period != 0 {
+		return nil, fmt.Errorf("billing usage period %s must divide a day", period)
+	}
+	return &Meter{db: db, period: period, now: time.Now}, nil
+}
+
+// Publish publishes the usage of every closed period after the last
+// published one and returns how many periods it published. On the first
+// run only the last closed period is published.
+func (m *Meter) Publish(ctx context.Context) (int, error) {
+	current := m.now().UTC().Truncate(m.period)
+	var last Period
+	err := m.db.WithContext(ctx).Order("start DESC").Take(&last).Error
+	next := current.Add(-m.period)
+	switch {
+	case err == nil:
+		next = last.Start.Add(m.period)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+
+	published := 0
+	for start := next; start.Before(current); start = start.Add(m.period) {
+		ok, err := m.publishPeriod(ctx, start)
+		if err != nil {
+			return published, err
+		}
+		if ok {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// publishPeriod records one period and spools its events to the outbox in
+// a transaction, so the events go out exactly when the period is claimed;
+// the relay may repeat an event, never drop one, and the pipeline drops
+// repeats by UsageID. It reports false if another instance published the
+// period first.
+func (m *Meter) publishPeriod(ctx context.Context, start time.Time) (bool, error) {
+	end := start.Add(m.period)
+	var events []UsageEvent
+	err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rows, err := volume(tx, start, end, "")
+		if err != nil {
+			return err
+		}
+		// Claim the period; a concurrent claim waits for this transaction
+		// and then finds the row.
+		pending := usageEvents(rows, start, end)
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Period{
+			Start: start, End: end, Tenants: len(pending), PublishedAt: time.Now().UTC(),
+		})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 500).Error; err != nil {
+				return err
+			}
+		}
+		for _, ev := range pending {
+			if err := outbox.Spool(tx, UsagePattern, ev); err != nil {
+				return fmt.Errorf("failed to spool usage of tenant %q for %s: %w", ev.TenantID, start.Format(time.RFC3339), err)
+			}
+		}
+		events = pending
+		return nil
+	})
+	if err != nil || events == nil {
+		return false, err
+	}
+	log.Printf("Published billing usage of %s for %d tenants", start.Format(time.RFC3339), len(events))
+	return true, nil
+}
+
+// volume counts the orders created in [start, end) by tenant and
+// currency, optionally for one tenant.
+func volume(db *gorm.DB, start, end time.Time, tenantID string) ([]Usage, error) {
+	q := db.Model(&repository.Order{}).
+		Select("tenant_id, currency, COUNT(*) AS orders, COALESCE(SUM(total_price), 0) AS revenue").
+		Where("created_at >= ? AND created_at < ?", start, end)
+	if tenantID != "" {
+		q = q.Where("tenant_id = ?", tenantID)
+	}
+	var rows []Usage
+	if err := q.Group("tenant_id, currency").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].PeriodStart = start
+		rows[i].Revenue = round(rows[i].Revenue)
+	}
+	return rows, nil
+}
+
+// Amount is an amount of money in one currency.
+type Amount struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+// UsageEvent is the payload of usage.orders_created. UsageID is the same
+// every time a tenant's period is published, for the pipeline to drop
+// duplicates.
+type UsageEvent struct {
+	UsageID     string    `json:"usageId"`
+	TenantID    string    `json:"tenantId"`
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	Orders      int64     `json:"orders"`
+	Revenue     []Amount  `json:"revenue"`
+}
+
+// usageEvents turns rows into one event per tenant, sorted by tenant.
+func usageEvents(rows []Usage, start, end time.Time) []UsageEvent {
+	byTenant := map[string]*UsageEvent{}
+	for _, r := range rows {
+		ev, ok := byTenant[r.TenantID]
+		if !ok {
+			ev = &UsageEvent{
+				UsageID:     r.TenantID + "/" + start.Format(time.RFC3339),
+				TenantID:    r.TenantID,
+				PeriodStart: start,
+				PeriodEnd:   end,
+				Revenue:     []Amount{},
+			}
+			byTenant[r.TenantID] = ev
+		}
+		ev.Orders += r.Orders
+		ev.Revenue = append(ev.Revenue, Amount{Currency: r.Currency, Amount: r.Revenue})
+	}
+	events := make([]UsageEvent, 0, len(byTenant))
+	for _, ev := range byTenant {
+		slices.SortFunc(ev.Revenue, func(a, b Amount) int { return cmp.Compare(a.Currency, b.Currency) })
+		events = append(events, *ev)
+	}
+	slices.SortFunc(events, func(a, b UsageEvent) int { return cmp.Compare(a.TenantID, b.TenantID) })
+	return events
+}
+
+// Comparison is a tenant's published usage for a period next to a recount
+// of its orders. They differ when orders were created with an earlier
+// timestamp after the period was published, e.g. by an import, or
+// deleted by retention since.
+type Comparison struct {
+	TenantID    string    `json:"tenantId"`
+	PeriodStart time.Time `json:"periodStart"`
+	PeriodEnd   time.Time `json:"periodEnd"`
+	Published   Volume    `json:"published"`
+	Actual      Volume    `json:"actual"`
+	Match       bool      `json:"match"`
+}
+
+// Volume is a number of orders and their value by currency.
+type Volume struct {
+	Orders  int64    `json:"orders"`
+	Revenue []Amount `json:"revenue"`
+}
+
+// Reconcile compares the usage published for the periods that started in
+// [from, to) with the orders now in the database, optionally for one
+// tenant, sorted by period and tenant.
+func (m *Meter) Reconcile(ctx context.Context, from, to time.Time, tenantID string) ([]Comparison, error) {
+	db := m.db.WithContext(ctx)
+	var periods []Period
+	if err := db.Where("start >= ? AND start < ?", from, to).Order("start").Find(&periods).Error; err != nil {
+		return nil, err
+	}
+	out := []Comparison{}
+	for _, p := range periods {
+		q := db.Where("period_start = ?", p.Start)
+		if tenantID != "" {
+			q = q.Where("tenant_id = ?", tenantID)
+		}
+		var published []Usage
+		if err := q.Find(&published).Error; err != nil {
+			return nil, err
+		}
+		actual, err := volume(db, p.Start, p.End, tenantID)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, compare(p, published, actual)...)
+	}
+	return out, nil
+}
+
+func compare(p Period, published, actual []Usage) []Comparison {
+	byTenant := map[string]*Comparison{}
+	add := func(rows []Usage, pick func(*Comparison) *Volume) {
+		for _, r := range rows {
+			c, ok := byTenant[r.TenantID]
+			if !ok {
+				c = &Comparison{
+					TenantID: r.TenantID, PeriodStart: p.Start, PeriodEnd: p.End,
+					Published: Volume{Revenue: []Amount{}}, Actual: Volume{Revenue: []Amount{}},
+				}
+				byTenant[r.TenantID] = c
+			}
+			v := pick(c)
+			v.Orders += r.Orders
+			v.Revenue = append(v.Revenue, Amount{Currency: r.Currency, Amount: r.Revenue})
+		}
+	}
+	add(published, func(c *Comparison) *Volume { return &c.Published })
+	add(actual, func(c *Comparison) *Volume { return &c.Actual })
+
+	out := make([]Comparison, 0, len(byTenant))
+	for _, c := range byTenant {
+		for _, v := range []*Volume{&c.Published, &c.Actual} {
+			slices.SortFunc(v.Revenue, func(a, b Amount) int { return cmp.Compare(a.Currency, b.Currency) })
+		}
+		c.Match = c.Published.Orders == c.Actual.Orders && slices.Equal(c.Published.Revenue, c.Actual.Revenue)
+		out = append(out, *c)
+	}
+	slices.SortFunc(out, func(a, b Comparison) int { return cmp.Compare(a.TenantID, b.TenantID) })
+	return out
+}
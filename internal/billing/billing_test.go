@@ -0,0 +1,175 @@
+package billing
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var (
+	start = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	end   = start.Add(time.Hour)
+)
+
+func TestNewMeterRequiresPeriodDividingADay(t *testing.T) {
+	for _, period := range []time.Duration{time.Hour, 15 * time.Minute, 24 * time.Hour} {
+		if _, err := NewMeter(nil, period); err != nil {
+			t.Errorf("Expected a period of %s to be accepted, got %v", period, err)
+		}
+	}
+	for _, period := range []time.Duration{0, -time.Hour, 7 * time.Hour, 48 * time.Hour} {
+		if _, err := NewMeter(nil, period); err == nil {
+			t.Errorf("Expected a period of %s to be rejected", period)
+		}
+	}
+}
+
+func TestUsageEvents(t *testing.T) {
+	rows := []Usage{
+		{PeriodStart: start, TenantID: "globex", Currency: "USD", Orders: 2, Revenue: 40},
+		{PeriodStart: start, TenantID: "acme", Currency: "USD", Orders: 1, Revenue: 10.5},
+		{PeriodStart: start, TenantID: "acme", Currency: "IDR", Orders: 3, Revenue: 150000},
+	}
+
+	events := usageEvents(rows, start, end)
+	if len(events) != 2 || events[0].TenantID != "acme" || events[1].TenantID != "globex" {
+		t.Fatalf("Expected one event per tenant sorted by tenant, got %+v", events)
+	}
+	acme := events[0]
+	if acme.UsageID != "acme/2026-03-01T10:00:00Z" || !acme.PeriodStart.Equal(start) || !acme.PeriodEnd.Equal(end) {
+		t.Errorf("Unexpected identity of the event: %+v", acme)
+	}
+	if acme.Orders != 4 {
+		t.Errorf("Expected 4 orders over both currencies, got %d", acme.Orders)
+	}
+	want := []Amount{{"IDR", 150000}, {"USD", 10.5}}
+	if len(acme.Revenue) != 2 || acme.Revenue[0] != want[0] || acme.Revenue[1] != want[1] {
+		t.Errorf("Expected revenue %v, got %v", want, acme.Revenue)
+	}
+
+	if events := usageEvents(nil, start, end); len(events) != 0 {
+		t.Errorf("Expected no events without orders, got %+v", events)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	p := Period{Start: start, End: end}
+	published := []Usage{
+		{TenantID: "acme", Currency: "USD", Orders: 2, Revenue: 20},
+		{TenantID: "acme", Currency: "IDR", Orders: 1, Revenue: 50000},
+		{TenantID: "globex", Currency: "USD", Orders: 1, Revenue: 5},
+	}
+	actual := []Usage{
+		{TenantID: "acme", Currency: "IDR", Orders: 1, Revenue: 50000},
+		{TenantID: "acme", Currency: "USD", Orders: 2, Revenue: 20},
+		{TenantID: "globex", Currency: "USD", Orders: 2, Revenue: 12},
+		{TenantID: "initech", Currency: "EUR", Orders: 1, Revenue: 8},
+	}
+
+	got := compare(p, published, actual)
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 tenants, got %+v", got)
+	}
+	if got[0].TenantID != "acme" || !got[0].Match {
+		t.Errorf("Expected acme to match regardless of row order, got %+v", got[0])
+	}
+	if got[1].TenantID != "globex" || got[1].Match || got[1].Published.Orders != 1 || got[1].Actual.Orders != 2 {
+		t.Errorf("Expected globex to mismatch, got %+v", got[1])
+	}
+	if got[2].TenantID != "initech" || got[2].Match || got[2].Published.Orders != 0 || len(got[2].Published.Revenue) != 0 {
+		t.Errorf("Expected initech's unpublished orders to mismatch, got %+v", got[2])
+	}
+	if !got[2].PeriodStart.Equal(start) || !got[2].PeriodEnd.Equal(end) {
+		t.Errorf("Expected the period on every comparison, got %+v", got[2])
+	}
+}
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+func TestPublishPeriodSpoolsInClaim(t *testing.T) {
+	volumeRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"tenant_id", "currency", "orders", "revenue"}).
+			AddRow("acme", "USD", 2, 20.0).
+			AddRow("globex", "USD", 1, 5.0)
+	}
+
+	t.Run("claimed", func(t *testing.T) {
+		db, mock := mockDB(t)
+		m, _ := NewMeter(db, time.Hour)
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(`FROM "orders"`)).WillReturnRows(volumeRows())
+		mock.ExpectExec(quoted(`INSERT INTO "billing_usage_periods"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`INSERT INTO "billing_usage"`)).WillReturnResult(sqlmock.NewResult(0, 2))
+		for i := 1; i <= 2; i++ {
+			mock.ExpectQuery(quoted(`INSERT INTO "outbox_messages"`)).WithArgs(UsagePattern, sqlmock.AnyArg(), "", 0, sqlmock.AnyArg()).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(i))
+		}
+		mock.ExpectCommit()
+
+		ok, err := m.publishPeriod(context.Background(), start)
+		if err != nil || !ok {
+			t.Fatalf("Expected the period to be published, got %v, %v", ok, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("claimed by another instance", func(t *testing.T) {
+		db, mock := mockDB(t)
+		m, _ := NewMeter(db, time.Hour)
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(`FROM "orders"`)).WillReturnRows(volumeRows())
+		mock.ExpectExec(quoted(`INSERT INTO "billing_usage_periods"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		ok, err := m.publishPeriod(context.Background(), start)
+		if err != nil || ok {
+			t.Fatalf("Expected the period to be left alone, got %v, %v", ok, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("spool failure releases the claim", func(t *testing.T) {
+		db, mock := mockDB(t)
+		m, _ := NewMeter(db, time.Hour)
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(`FROM "orders"`)).WillReturnRows(volumeRows())
+		mock.ExpectExec(quoted(`INSERT INTO "billing_usage_periods"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`INSERT INTO "billing_usage"`)).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(quoted(`INSERT INTO "outbox_messages"`)).WillReturnError(errors.New("connection reset"))
+		mock.ExpectRollback()
+
+		if ok, err := m.publishPeriod(context.Background(), start); err == nil || ok {
+			t.Fatalf("Expected an error, got %v, %v", ok, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,264 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+const (
+	KindCustomer = "customer"
+	KindIP       = "ip"
+	KindProduct  = "product"
+
+	ActionBlock = "block"
+	ActionAllow = "allow"
+)
+
+const (
+	cacheKey         = "blocklist"
+	cacheLoadedField = "_loaded"
+	// versionKey is bumped with every cached change, so a reload that read
+	// Postgres before the change does not overwrite it.
+	versionKey = "blocklist:version"
+)
+
+var (
+	ErrInvalidEntry  = errors.New("invalid blocklist entry")
+	ErrEntryNotFound = errors.New("blocklist entry not found")
+	ErrDuplicate     = errors.New("blocklist entry already exists")
+)
+
+// Entry blocks or allows a single customer ID, client IP or product ID.
+// Allow entries are only meaningful for customers, where they skip fraud
+// screening.
+type Entry struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Kind      string    `gorm:"not null;uniqueIndex:idx_blocklist_kind_value" json:"kind"`
+	Value     string    `gorm:"not null;uniqueIndex:idx_blocklist_kind_value" json:"value"`
+	Action    string    `gorm:"not null" json:"action"`
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (Entry) TableName() string { return "blocklist_entries" }
+
+func (e Entry) validate() error {
+	if !slices.Contains([]string{KindCustomer, KindIP, KindProduct}, e.Kind) {
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidEntry, e.Kind)
+	}
+	if !slices.Contains([]string{ActionBlock, ActionAllow}, e.Action) {
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidEntry, e.Action)
+	}
+	if e.Action == ActionAllow && e.Kind != KindCustomer {
+		return fmt.Errorf("%w: only customers can be allowlisted", ErrInvalidEntry)
+	}
+	if e.Value == "" {
+		return fmt.Errorf("%w: value is required", ErrInvalidEntry)
+	}
+	return nil
+}
+
+// Decision is the result of checking an order against the lists.
+type Decision struct {
+	// BlockedBy is the kind of the first matching block entry, or "".
+	BlockedBy       string
+	CustomerAllowed bool
+}
+
+// Store keeps entries in Postgres and mirrors them into a Redis hash
+// (field "kind:value" -> action) that is consulted on every order. Changes
+// to the hash bump versionKey in the same transaction; a reload watches it
+// and is dropped if an entry changed while it was reading Postgres.
+type Store struct {
+	db     *gorm.DB
+	client *redis.Client
+	logger *slog.Logger
+}
+
+func NewStore(db *gorm.DB, client *redis.Client, logger *slog.Logger) *Store {
+	return &Store{db: db, client: client, logger: logger}
+}
+
+func (s *Store) List(ctx context.Context) ([]Entry, error) {
+	var entries []Entry
+	err := s.db.WithContext(ctx).Order("kind, value").Find(&entries).Error
+	return entries, err
+}
+
+func (s *Store) Add(ctx context.Context, actor string, e *Entry) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	e.ID = 0
+	e.CreatedBy = actor
+	if err := s.db.WithContext(ctx).Create(e).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	if err := s.cache(ctx, func(p redis.Pipeliner) { p.HSet(ctx, cacheKey, field(e.Kind, e.Value), e.Action) }); err != nil {
+		s.invalidate(ctx)
+	}
+	s.logger.Info("audit", "actor", actor, "action", "blocklist.add",
+		"kind", e.Kind, "value", e.Value, "listAction", e.Action, "reason", e.Reason)
+	return nil
+}
+
+func (s *Store) Remove(ctx context.Context, actor string, id uint) (*Entry, error) {
+	var e Entry
+	err := s.db.WithContext(ctx).First(&e, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEntryNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Delete(&e).Error; err != nil {
+		return nil, err
+	}
+	if err := s.cache(ctx, func(p redis.Pipeliner) { p.HDel(ctx, cacheKey, field(e.Kind, e.Value)) }); err != nil {
+		s.invalidate(ctx)
+	}
+	s.logger.Info("audit", "actor", actor, "action", "blocklist.remove",
+		"kind", e.Kind, "value", e.Value, "listAction", e.Action)
+	return &e, nil
+}
+
+// Check looks the order's customer, IP and product up in the cached lists,
+// loading the cache from Postgres first if needed. If Redis is unavailable
+// it queries Postgres directly.
+func (s *Store) Check(ctx context.Context, customerID, ip, productID string) (Decision, error) {
+	keys := []struct{ kind, value string }{
+		{KindCustomer, customerID},
+		{KindIP, ip},
+		{KindProduct, productID},
+	}
+
+	fields := make([]string, len(keys))
+	for i, k := range keys {
+		fields[i] = field(k.kind, k.value)
+	}
+
+	actions, err := s.cachedActions(ctx, fields)
+	if err != nil {
+		actions, err = s.dbActions(ctx, customerID, ip, productID)
+		if err != nil {
+			return Decision{}, err
+		}
+	}
+
+	var d Decision
+	for i, k := range keys {
+		if k.value == "" {
+			continue
+		}
+		switch actions[i] {
+		case ActionBlock:
+			if d.BlockedBy == "" {
+				d.BlockedBy = k.kind
+			}
+		case ActionAllow:
+			if k.kind == KindCustomer {
+				d.CustomerAllowed = true
+			}
+		}
+	}
+	return d, nil
+}
+
+func (s *Store) cachedActions(ctx context.Context, fields []string) ([]string, error) {
+	loaded, err := s.client.HExists(ctx, cacheKey, cacheLoadedField).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !loaded {
+		if err := s.loadCache(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	vals, err := s.client.HMGet(ctx, cacheKey, fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	actions := make([]string, len(vals))
+	for i, v := range vals {
+		actions[i], _ = v.(string)
+	}
+	return actions, nil
+}
+
+// loadCache replaces the hash with the entries in Postgres. It fails with
+// redis.TxFailedErr if an entry was added or removed since it started
+// reading, and Check then falls back to Postgres until the next reload.
+func (s *Store) loadCache(ctx context.Context) error {
+	return s.client.Watch(ctx, func(tx *redis.Tx) error {
+		entries, err := s.List(ctx)
+		if err != nil {
+			return err
+		}
+		values := map[string]interface{}{cacheLoadedField: "1"}
+		for _, e := range entries {
+			values[field(e.Kind, e.Value)] = e.Action
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Del(ctx, cacheKey)
+			p.HSet(ctx, cacheKey, values)
+			return nil
+		})
+		return err
+	}, versionKey)
+}
+
+// cache applies change to the hash and bumps versionKey atomically.
+func (s *Store) cache(ctx context.Context, change func(p redis.Pipeliner)) error {
+	_, err := s.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Incr(ctx, versionKey)
+		change(p)
+		return nil
+	})
+	return err
+}
+
+func (s *Store) dbActions(ctx context.Context, customerID, ip, productID string) ([]string, error) {
+	var entries []Entry
+	err := s.db.WithContext(ctx).
+		Where("(kind = ? AND value = ?) OR (kind = ? AND value = ?) OR (kind = ? AND value = ?)",
+			KindCustomer, customerID, KindIP, ip, KindProduct, productID).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	actions := make([]string, 3)
+	for _, e := range entries {
+		switch e.Kind {
+		case KindCustomer:
+			actions[0] = e.Action
+		case KindIP:
+			actions[1] = e.Action
+		case KindProduct:
+			actions[2] = e.Action
+		}
+	}
+	return actions, nil
+}
+
+// invalidate drops the cached lists so the next Check reloads them from
+// Postgres.
+func (s *Store) invalidate(ctx context.Context) {
+	if err := s.client.Del(ctx, cacheKey).Err(); err != nil {
+		s.logger.Error("failed to invalidate blocklist cache", "error", err)
+	}
+}
+
+func field(kind, value string) string {
+	return kind + ":" + value
+}
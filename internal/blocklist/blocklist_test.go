@@ -0,0 +1,225 @@
+package blocklist
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+func newStore(t *testing.T) (*Store, sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+	db, mock := mockDB(t)
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	return NewStore(db, client, slog.New(slog.NewTextHandler(io.Discard, nil))), mock, mr
+}
+
+func entryRows(entries ...Entry) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "kind", "value", "action"})
+	for i, e := range entries {
+		rows.AddRow(i+1, e.Kind, e.Value, e.Action)
+	}
+	return rows
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		entry Entry
+		ok    bool
+	}{
+		{Entry{Kind: KindCustomer, Value: "c1", Action: ActionBlock}, true},
+		{Entry{Kind: KindCustomer, Value: "c1", Action: ActionAllow}, true},
+		{Entry{Kind: KindIP, Value: "10.0.0.1", Action: ActionBlock}, true},
+		{Entry{Kind: KindProduct, Value: "p1", Action: ActionBlock}, true},
+		{Entry{Kind: "email", Value: "a@b.c", Action: ActionBlock}, false},
+		{Entry{Kind: KindCustomer, Value: "c1", Action: "review"}, false},
+		{Entry{Kind: KindIP, Value: "10.0.0.1", Action: ActionAllow}, false},
+		{Entry{Kind: KindProduct, Value: "p1", Action: ActionAllow}, false},
+		{Entry{Kind: KindCustomer, Action: ActionBlock}, false},
+	} {
+		err := tt.entry.validate()
+		if tt.ok && err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", tt.entry, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidEntry) {
+			t.Errorf("Expected ErrInvalidEntry for %+v, got %v", tt.entry, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("precedence", func(t *testing.T) {
+		store, _, mr := newStore(t)
+		mr.HSet(cacheKey, cacheLoadedField, "1",
+			"customer:vip", ActionAllow,
+			"customer:fraud", ActionBlock,
+			"ip:10.0.0.1", ActionBlock,
+			"product:p9", ActionBlock,
+			"ip:", ActionBlock)
+
+		for _, tt := range []struct {
+			name                  string
+			customer, ip, product string
+			blockedBy             string
+			customerAllowed       bool
+		}{
+			{"nothing listed", "c1", "10.0.0.2", "p1", "", false},
+			{"an allowed customer", "vip", "10.0.0.2", "p1", "", true},
+			{"the customer block comes first", "fraud", "10.0.0.1", "p9", KindCustomer, false},
+			{"an IP block comes before a product block", "c1", "10.0.0.1", "p9", KindIP, false},
+			{"allowing a customer does not lift other blocks", "vip", "10.0.0.2", "p9", KindProduct, true},
+			{"empty values are not looked up", "c1", "", "p1", "", false},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				d, err := store.Check(ctx, tt.customer, tt.ip, tt.product)
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if d.BlockedBy != tt.blockedBy || d.CustomerAllowed != tt.customerAllowed {
+					t.Errorf("Expected blocked by %q, allowed %v, got %+v", tt.blockedBy, tt.customerAllowed, d)
+				}
+			})
+		}
+	})
+
+	t.Run("loads the cache from Postgres once", func(t *testing.T) {
+		store, mock, mr := newStore(t)
+		mock.ExpectQuery(quoted(`SELECT * FROM "blocklist_entries" ORDER BY kind, value`)).
+			WillReturnRows(entryRows(Entry{Kind: KindProduct, Value: "p9", Action: ActionBlock}))
+
+		for i := 0; i < 2; i++ {
+			d, err := store.Check(ctx, "c1", "10.0.0.1", "p9")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if d.BlockedBy != KindProduct {
+				t.Errorf("Expected the product to be blocked, got %+v", d)
+			}
+		}
+		if got := mr.HGet(cacheKey, "product:p9"); got != ActionBlock {
+			t.Errorf("Expected the entry to be cached, got %q", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("a change during the reload keeps the stale list out of the cache", func(t *testing.T) {
+		store, mock, mr := newStore(t)
+		// An entry removed between reading Postgres and writing the hash.
+		store.db.Callback().Query().After("gorm:query").Register("test:remove", func(*gorm.DB) {
+			mr.Incr(versionKey, 1)
+		})
+		mock.ExpectQuery(quoted(`SELECT * FROM "blocklist_entries" ORDER BY kind, value`)).
+			WillReturnRows(entryRows(Entry{Kind: KindProduct, Value: "p9", Action: ActionBlock}))
+		mock.ExpectQuery(quoted(`SELECT * FROM "blocklist_entries" WHERE`)).
+			WithArgs(KindCustomer, "c1", KindIP, "10.0.0.1", KindProduct, "p9").
+			WillReturnRows(entryRows())
+
+		d, err := store.Check(ctx, "c1", "10.0.0.1", "p9")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if d.BlockedBy != "" {
+			t.Errorf("Expected Postgres to decide, got %+v", d)
+		}
+		if mr.Exists(cacheKey) {
+			t.Error("Expected the stale list not to be cached")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("falls back to Postgres when Redis fails", func(t *testing.T) {
+		store, mock, mr := newStore(t)
+		mr.Close()
+		mock.ExpectQuery(quoted(`SELECT * FROM "blocklist_entries" WHERE`)).
+			WithArgs(KindCustomer, "vip", KindIP, "10.0.0.1", KindProduct, "p1").
+			WillReturnRows(entryRows(
+				Entry{Kind: KindCustomer, Value: "vip", Action: ActionAllow},
+				Entry{Kind: KindIP, Value: "10.0.0.1", Action: ActionBlock}))
+
+		d, err := store.Check(ctx, "vip", "10.0.0.1", "p1")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if d.BlockedBy != KindIP || !d.CustomerAllowed {
+			t.Errorf("Expected the IP block and customer allow from Postgres, got %+v", d)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestAddRemove(t *testing.T) {
+	ctx := context.Background()
+	store, mock, mr := newStore(t)
+	mr.HSet(cacheKey, cacheLoadedField, "1")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(quoted(`INSERT INTO "blocklist_entries"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	if err := store.Add(ctx, "admin", &Entry{Kind: KindIP, Value: "10.0.0.1", Action: ActionBlock}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := mr.HGet(cacheKey, "ip:10.0.0.1"); got != ActionBlock {
+		t.Errorf("Expected the entry to be cached, got %q", got)
+	}
+
+	mock.ExpectQuery(quoted(`SELECT * FROM "blocklist_entries" WHERE "blocklist_entries"."id" = $1`)).
+		WithArgs(7, 1).
+		WillReturnRows(entryRows(Entry{Kind: KindIP, Value: "10.0.0.1", Action: ActionBlock}))
+	mock.ExpectBegin()
+	mock.ExpectExec(quoted(`DELETE FROM "blocklist_entries"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if _, err := store.Remove(ctx, "admin", 7); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if mr.HGet(cacheKey, "ip:10.0.0.1") != "" {
+		t.Error("Expected the entry to leave the cache")
+	}
+	if got, _ := mr.Get(versionKey); got != "2" {
+		t.Errorf("Expected both changes to bump the version, got %q", got)
+	}
+
+	if err := store.Add(ctx, "admin", &Entry{Kind: KindIP, Value: "10.0.0.1", Action: ActionAllow}); !errors.Is(err, ErrInvalidEntry) {
+		t.Errorf("Expected ErrInvalidEntry, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
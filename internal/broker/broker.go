@@ -0,0 +1,163 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"order-service/internal/backoff"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var ErrNotConnected = errors.New("not connected to RabbitMQ")
+
+// Connection keeps a RabbitMQ connection open, redialing in the background
+// when it drops, so the service can start and keep serving while the broker
+// is away.
+type Connection struct {
+	url string
+
+	mu   sync.Mutex
+	conn *amqp.Connection
+	ch   *amqp.Channel
+}
+
+func New(url string) *Connection {
+	return &Connection{url: url}
+}
+
+// SetURL changes the URL used from the next dial on, e.g. after the
+// credentials were rotated.
+func (c *Connection) SetURL(url string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.url = url
+}
+
+// Connect dials the broker unless already connected. The dial happens
+// outside the lock so publishers fail fast instead of waiting on it.
+func (c *Connection) Connect() error {
+	c.mu.Lock()
+	url := c.url
+	closed := c.conn == nil || c.conn.IsClosed()
+	c.mu.Unlock()
+	if !closed {
+		return nil
+	}
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil && !c.conn.IsClosed() {
+		conn.Close()
+		return nil
+	}
+	c.conn, c.ch = conn, nil
+	return nil
+}
+
+// Run redials whenever the connection is lost until ctx is cancelled.
+func (c *Connection) Run(ctx context.Context, p backoff.Policy) {
+	attempt := 0
+	for {
+		if err := c.Connect(); err != nil {
+			delay := p.Delay(attempt)
+			attempt++
+			log.Printf("Failed to reconnect to RabbitMQ, retrying in %s: %v", delay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			continue
+		}
+		if attempt > 0 {
+			log.Printf("Reconnected to RabbitMQ")
+		}
+		attempt = 0
+
+		c.mu.Lock()
+		closed := c.conn.NotifyClose(make(chan *amqp.Error, 1))
+		c.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-closed:
+			log.Printf("RabbitMQ connection lost: %v", err)
+		}
+	}
+}
+
+func (c *Connection) IsClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn == nil || c.conn.IsClosed()
+}
+
+// Channel returns the shared channel used for publishing, opening a new one
+// after the previous one closed.
+func (c *Connection) Channel() (*amqp.Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil, ErrNotConnected
+	}
+	if c.ch != nil {
+		return c.ch, nil
+	}
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	closed := ch.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		<-closed
+		c.mu.Lock()
+		if c.ch == ch {
+			c.ch = nil
+		}
+		c.mu.Unlock()
+	}()
+	c.ch = ch
+	return ch, nil
+}
+
+// NewChannel opens a dedicated channel, e.g. for a consumer.
+func (c *Connection) NewChannel() (*amqp.Channel, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil || c.conn.IsClosed() {
+		return nil, ErrNotConnected
+	}
+	return c.conn.Channel()
+}
+
+// QueueDepth returns the number of ready messages and consumers of an
+// existing queue.
+func (c *Connection) QueueDepth(name string) (messages, consumers int, err error) {
+	ch, err := c.NewChannel()
+	if err != nil {
+		return 0, 0, err
+	}
+	// Inspecting a missing queue closes the channel, so it gets its own.
+	defer ch.Close()
+	q, err := ch.QueueInspect(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return q.Messages, q.Consumers, nil
+}
+
+func (c *Connection) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Close()
+}
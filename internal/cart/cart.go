@@ -0,0 +1,63 @@
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrCartNotFound = errors.New("cart not found")
+
+type Item struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+type Cart struct {
+	ID         string `json:"id"`
+	CustomerID string `json:"customerId"`
+	Items      []Item `json:"items"`
+}
+
+// HTTPClient reads carts from cart-service via GET {baseURL}/carts/{id}.
+type HTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewHTTPClient(baseURL string) *HTTPClient {
+	return &HTTPClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (c *HTTPClient) GetCart(ctx context.Context, cartID string) (*Cart, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/carts/"+url.PathEscape(cartID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call cart service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrCartNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cart service returned status: %s", resp.Status)
+	}
+
+	var cart Cart
+	if err := json.NewDecoder(resp.Body).Decode(&cart); err != nil {
+		return nil, fmt.Errorf("failed to decode cart: %w", err)
+	}
+	return &cart, nil
+}
@@ -0,0 +1,43 @@
+package cart
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetCart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/carts/cart-1":
+			w.Write([]byte(`{"id":"cart-1","customerId":"c1","items":[{"productId":"p1","quantity":2}]}`))
+		case "/carts/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/carts/garbled":
+			w.Write([]byte(`{"id":`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := NewHTTPClient(server.URL + "/")
+
+	c, err := client.GetCart(context.Background(), "cart-1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if c.CustomerID != "c1" || len(c.Items) != 1 || c.Items[0] != (Item{ProductID: "p1", Quantity: 2}) {
+		t.Errorf("Expected cart-1 with one line, got %+v", c)
+	}
+
+	if _, err := client.GetCart(context.Background(), "missing"); !errors.Is(err, ErrCartNotFound) {
+		t.Errorf("Expected ErrCartNotFound, got %v", err)
+	}
+	for _, id := range []string{"broken", "garbled"} {
+		if _, err := client.GetCart(context.Background(), id); err == nil || errors.Is(err, ErrCartNotFound) {
+			t.Errorf("Expected an error for cart %s, got %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Classes of failures, recorded with quarantined messages.
+const (
+	// ClassSchema messages cannot be decoded and never will be.
+	ClassSchema = "schema"
+	// ClassBusiness messages were decoded but rejected by a business rule.
+	ClassBusiness = "business"
+	// ClassTransient messages failed for another reason, e.g. a dependency
+	// being down.
+	ClassTransient = "transient"
+)
+
+// ErrSchema is wrapped by handlers for events that do not have the shape
+// they expect.
+var ErrSchema = errors.New("malformed event")
+
+// Classifier returns the class of a handling error.
+type Classifier func(err error) string
+
+// Classify tells schema errors from transient ones. Services that know
+// their business errors wrap it in their own Classifier.
+func Classify(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.Is(err, ErrSchema) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ClassSchema
+	}
+	return ClassTransient
+}
+
+// IQuarantine keeps messages that cannot be handled for reprocessing once
+// the cause is fixed.
+type IQuarantine interface {
+	Add(ctx context.Context, queue string, body []byte, class string, cause error) error
+}
+
+// Handle decodes a message body and runs handler on its data. Decoding
+// errors wrap ErrSchema.
+func Handle(ctx context.Context, handler HandlerFunc, body []byte) error {
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return fmt.Errorf("%w: %v", ErrSchema, err)
+	}
+	return handler(ctx, env.Data)
+}
@@ -0,0 +1,39 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	var syntaxErr error = &json.SyntaxError{}
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: no productId", ErrSchema), ClassSchema},
+		{fmt.Errorf("decode: %w", syntaxErr), ClassSchema},
+		{errors.New("connection refused"), ClassTransient},
+	} {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("Classify(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestHandle(t *testing.T) {
+	var got string
+	handler := func(ctx context.Context, data json.RawMessage) error {
+		got = string(data)
+		return nil
+	}
+	if err := Handle(context.Background(), handler, []byte(`{"pattern":"p","data":{"a":1}}`)); err != nil || got != `{"a":1}` {
+		t.Errorf("Expected the handler to get the data, got %s, %v", got, err)
+	}
+	if err := Handle(context.Background(), handler, []byte(`not json`)); !errors.Is(err, ErrSchema) {
+		t.Errorf("Expected ErrSchema, got %v", err)
+	}
+}
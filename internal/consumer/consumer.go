@@ -0,0 +1,343 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"order-service/internal/backoff"
+	"order-service/internal/errreport"
+	"order-service/internal/metrics"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// HandlerFunc processes the data of one event.
+type HandlerFunc func(ctx context.Context, data json.RawMessage) error
+
+// envelope is the {pattern, data} wrapper used by every service on the bus.
+// Events numbered within an order also carry its key and their sequence.
+type envelope struct {
+	Pattern  string          `json:"pattern"`
+	Data     json.RawMessage `json:"data"`
+	Key      string          `json:"key,omitempty"`
+	Sequence int64           `json:"sequence,omitempty"`
+}
+
+// ChannelOpener opens channels on the current broker connection.
+type ChannelOpener interface {
+	NewChannel() (*amqp.Channel, error)
+}
+
+// Consumer states.
+const (
+	StateStarting   = "starting"
+	StateConsuming  = "consuming"
+	StateRestarting = "restarting"
+	StateStopped    = "stopped"
+)
+
+// Consumer reads events from a single RabbitMQ queue. Failed messages are
+// requeued once and dropped on the second failure, or quarantined when a
+// quarantine is configured. Messages that cannot be decoded are not
+// retried.
+type Consumer struct {
+	channels   ChannelOpener
+	queue      string
+	handler    HandlerFunc
+	retry      backoff.Policy
+	quarantine IQuarantine
+	classify   Classifier
+	validator  Validator
+	reporter   errreport.Reporter
+	tracker    OrderTracker
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+type Option func(*Consumer)
+
+// WithQuarantine stores messages that keep failing in q, classified by
+// classify, instead of dropping them.
+func WithQuarantine(q IQuarantine, classify Classifier) Option {
+	return func(c *Consumer) {
+		c.quarantine = q
+		c.classify = classify
+	}
+}
+
+// Validator checks event payloads against the schema of their pattern.
+type Validator interface {
+	Validate(pattern string, data []byte) error
+}
+
+// WithValidator checks every event against its schema before it is
+// handled. Events that do not match are treated as malformed.
+func WithValidator(v Validator) Option {
+	return func(c *Consumer) { c.validator = v }
+}
+
+// WithReporter reports handlers that panic, and the messages the consumer
+// gives up on, to r.
+func WithReporter(r errreport.Reporter) Option {
+	return func(c *Consumer) { c.reporter = r }
+}
+
+// OrderTracker remembers the newest sequence handled per key.
+type OrderTracker interface {
+	Last(ctx context.Context, key string) (int64, error)
+	Advance(ctx context.Context, key string, sequence int64) error
+}
+
+// WithOrdering skips numbered events that are not newer than the newest
+// one handled for their key, e.g. order.created relayed from the outbox
+// after order.paid was handled, so handlers never apply an older state
+// over a newer one. Skipped events are acked. Events without a number are
+// always handled.
+func WithOrdering(t OrderTracker) Option {
+	return func(c *Consumer) { c.tracker = t }
+}
+
+func New(channels ChannelOpener, queue string, handler HandlerFunc, retry backoff.Policy, opts ...Option) *Consumer {
+	c := &Consumer{
+		channels: channels,
+		queue:    queue,
+		handler:  handler,
+		retry:    retry,
+		classify: Classify,
+		stats:    Stats{Queue: queue, State: StateStarting},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Run consumes until ctx is cancelled. When the channel closes, e.g.
+// because the connection dropped, it reopens it with backoff.
+func (c *Consumer) Run(ctx context.Context) error {
+	defer c.update(func(s *Stats) { s.State = StateStopped })
+	for attempt := 0; ; attempt++ {
+		consumed, err := c.consume(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if consumed {
+			attempt = 0
+		}
+		delay := c.retry.Delay(attempt)
+		log.Printf("Consumer for %s stopped, restarting in %s: %v", c.queue, delay, err)
+		c.update(func(s *Stats) {
+			s.State = StateRestarting
+			s.LastError = err.Error()
+			s.Restarts++
+		})
+		metrics.ConsumerRestarts.WithLabelValues(c.queue).Inc()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// consume reads from one channel until it closes. consumed reports whether
+// the subscription was established.
+func (c *Consumer) consume(ctx context.Context) (consumed bool, err error) {
+	ch, err := c.channels.NewChannel()
+	if err != nil {
+		return false, err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclare(c.queue, false, false, false, false, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to declare queue %s: %w", c.queue, err)
+	}
+	msgs, err := ch.Consume(q.Name, "", false, false, false, false, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume from %s: %w", c.queue, err)
+	}
+	c.update(func(s *Stats) {
+		now := time.Now()
+		s.State = StateConsuming
+		s.ConsumingSince = &now
+		s.LastDeliveryTag = 0
+	})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return true, ctx.Err()
+		case msg, ok := <-msgs:
+			if !ok {
+				return true, fmt.Errorf("delivery channel for %s closed", c.queue)
+			}
+			c.handle(ctx, msg)
+		}
+	}
+}
+
+func (c *Consumer) handle(ctx context.Context, msg amqp.Delivery) {
+	start := time.Now()
+	if msg.Redelivered {
+		metrics.ConsumerRetries.WithLabelValues(c.queue).Inc()
+	}
+
+	env, err := c.decode(msg.Body)
+	if err != nil {
+		if c.quarantined(ctx, msg, ClassSchema, err) {
+			msg.Nack(false, false)
+			c.processed(msg, start, "quarantined", err)
+			c.poisoned(ctx, msg, ClassSchema, "quarantined", err)
+			return
+		}
+		log.Printf("Dropping malformed message on %s: %v", c.queue, err)
+		msg.Nack(false, false)
+		c.processed(msg, start, "malformed", err)
+		c.poisoned(ctx, msg, ClassSchema, "malformed", err)
+		return
+	}
+
+	if c.stale(ctx, env) {
+		log.Printf("Skipping %s event %d of %s on %s, a newer one was handled", env.Pattern, env.Sequence, env.Key, c.queue)
+		msg.Ack(false)
+		c.processed(msg, start, "stale", nil)
+		return
+	}
+
+	if err := c.call(ctx, env.Data); err != nil {
+		log.Printf("Failed to handle %s event (redelivered=%t): %v", c.queue, msg.Redelivered, err)
+		class := c.classify(err)
+		outcome := "requeued"
+		if msg.Redelivered || class == ClassSchema {
+			outcome = "dropped"
+			if c.quarantined(ctx, msg, class, err) {
+				outcome = "quarantined"
+			}
+		}
+		msg.Nack(false, outcome == "requeued")
+		c.processed(msg, start, outcome, err)
+		if outcome != "requeued" {
+			c.poisoned(ctx, msg, class, outcome, err)
+		}
+		return
+	}
+	c.advance(ctx, env)
+	msg.Ack(false)
+	c.processed(msg, start, "acked", nil)
+}
+
+// stale reports whether a newer event of the envelope's key was handled.
+// When the tracker fails the event is handled.
+func (c *Consumer) stale(ctx context.Context, env envelope) bool {
+	if c.tracker == nil || env.Key == "" {
+		return false
+	}
+	last, err := c.tracker.Last(ctx, env.Key)
+	if err != nil {
+		log.Printf("Failed to check the order of %s event on %s: %v", env.Pattern, c.queue, err)
+		return false
+	}
+	return env.Sequence <= last
+}
+
+func (c *Consumer) advance(ctx context.Context, env envelope) {
+	if c.tracker == nil || env.Key == "" {
+		return
+	}
+	if err := c.tracker.Advance(ctx, env.Key, env.Sequence); err != nil {
+		log.Printf("Failed to record %s event %d of %s on %s: %v", env.Pattern, env.Sequence, env.Key, c.queue, err)
+	}
+}
+
+// call runs the handler and turns a panic into an error, so one bad
+// message does not take the consumer down.
+func (c *Consumer) call(ctx context.Context, data json.RawMessage) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			r := errreport.Recovered(ctx, c.reporter, v, "consumer", map[string]string{"queue": c.queue})
+			err = fmt.Errorf("%w (incident %s)", r.Err, r.IncidentID)
+		}
+	}()
+	return c.handler(ctx, data)
+}
+
+// decode unwraps the envelope and validates the event. Errors wrap
+// ErrSchema.
+func (c *Consumer) decode(body []byte) (envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		return env, fmt.Errorf("%w: %v", ErrSchema, err)
+	}
+	if c.validator == nil {
+		return env, nil
+	}
+	pattern := env.Pattern
+	if pattern == "" {
+		pattern = c.queue
+	}
+	if err := c.validator.Validate(pattern, env.Data); err != nil {
+		metrics.EventsRejected.WithLabelValues(pattern, "incoming").Inc()
+		return env, fmt.Errorf("%w: %v", ErrSchema, err)
+	}
+	return env, nil
+}
+
+// quarantined stores a message that will not be retried and reports
+// whether it was stored.
+func (c *Consumer) quarantined(ctx context.Context, msg amqp.Delivery, class string, cause error) bool {
+	if c.quarantine == nil {
+		return false
+	}
+	if err := c.quarantine.Add(context.WithoutCancel(ctx), c.queue, msg.Body, class, cause); err != nil {
+		log.Printf("Failed to quarantine message on %s, dropping it: %v", c.queue, err)
+		return false
+	}
+	log.Printf("Quarantined %s message on %s: %v", class, c.queue, cause)
+	metrics.ConsumerQuarantined.WithLabelValues(c.queue, class).Inc()
+	return true
+}
+
+// poisoned reports a message that was dropped or quarantined. Reports are
+// grouped by queue and class.
+func (c *Consumer) poisoned(ctx context.Context, msg amqp.Delivery, class, outcome string, err error) {
+	errreport.Capture(ctx, c.reporter, err, "consumer", errreport.ClassPoison, map[string]string{
+		"queue":      c.queue,
+		"reason":     class,
+		"outcome":    outcome,
+		"message_id": msg.MessageId,
+	})
+}
+
+// processed records the outcome of a message in the stats and metrics.
+func (c *Consumer) processed(msg amqp.Delivery, start time.Time, outcome string, err error) {
+	now := time.Now()
+	metrics.ConsumerMessages.WithLabelValues(c.queue, outcome).Inc()
+	metrics.ConsumerDuration.WithLabelValues(c.queue).Observe(now.Sub(start).Seconds())
+	c.update(func(s *Stats) {
+		s.Processed++
+		if err != nil {
+			s.Failed++
+			s.LastError = err.Error()
+		}
+		s.LastDeliveryTag = msg.DeliveryTag
+		s.LastProcessedAt = &now
+	})
+}
+
+func (c *Consumer) update(fn func(*Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.stats)
+}
+
+// Stats returns a snapshot of the consumer's state.
+func (c *Consumer) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
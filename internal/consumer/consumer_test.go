@@ -0,0 +1,78 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"order-service/internal/backoff"
+	"order-service/internal/errreport"
+)
+
+type fakeReporter struct {
+	reports []errreport.Report
+}
+
+func (f *fakeReporter) Report(_ context.Context, r errreport.Report) {
+	f.reports = append(f.reports, r)
+}
+
+func TestCallRecoversPanics(t *testing.T) {
+	reporter := &fakeReporter{}
+	handler := func(ctx context.Context, data json.RawMessage) error { panic("boom") }
+	c := New(nil, "stock.replenished", handler, backoff.Policy{}, WithReporter(reporter))
+
+	err := c.call(context.Background(), json.RawMessage(`{}`))
+	var p *errreport.PanicError
+	if !errors.As(err, &p) {
+		t.Fatalf("Expected a panic error, got %v", err)
+	}
+	if c.classify(err) != ClassTransient {
+		t.Errorf("Expected a panic to be retried like a transient error, got %s", c.classify(err))
+	}
+	if len(reporter.reports) != 1 || reporter.reports[0].Tags["queue"] != "stock.replenished" {
+		t.Errorf("Expected a report for the queue, got %+v", reporter.reports)
+	}
+}
+
+// memoryTracker keeps the newest sequence per key in memory.
+type memoryTracker map[string]int64
+
+func (m memoryTracker) Last(_ context.Context, key string) (int64, error) {
+	return m[key], nil
+}
+
+func (m memoryTracker) Advance(_ context.Context, key string, sequence int64) error {
+	if sequence > m[key] {
+		m[key] = sequence
+	}
+	return nil
+}
+
+func TestOrdering(t *testing.T) {
+	tracker := memoryTracker{}
+	c := New(nil, "order.paid", nil, backoff.Policy{}, WithOrdering(tracker))
+	ctx := context.Background()
+
+	paid := envelope{Pattern: "order.paid", Key: "o1", Sequence: 2}
+	if c.stale(ctx, paid) {
+		t.Fatal("Expected the first event of o1 to be handled")
+	}
+	c.advance(ctx, paid)
+	if !c.stale(ctx, paid) {
+		t.Error("Expected a redelivery of a handled event to be stale")
+	}
+	if !c.stale(ctx, envelope{Pattern: "order.created", Key: "o1", Sequence: 1}) {
+		t.Error("Expected an older event of o1 to be stale")
+	}
+	if c.stale(ctx, envelope{Pattern: "order.cancelled", Key: "o1", Sequence: 4}) {
+		t.Error("Expected a newer event of o1 to be handled")
+	}
+	if c.stale(ctx, envelope{Pattern: "order.created", Key: "o2", Sequence: 1}) {
+		t.Error("Expected events of other orders to be handled")
+	}
+	if c.stale(ctx, envelope{Pattern: "order.paid"}) {
+		t.Error("Expected events without a number to be handled")
+	}
+}
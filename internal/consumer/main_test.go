@@ -0,0 +1,12 @@
+package consumer
+
+import (
+	"testing"
+
+	"go.uber.org/goleak"
+)
+
+// TestMain fails the tests of the package if they leave goroutines running.
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
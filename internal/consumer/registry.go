@@ -0,0 +1,118 @@
+package consumer
+
+import (
+	"log"
+	"order-service/internal/metrics"
+	"sync"
+	"time"
+)
+
+// Stats is what a consumer did so far. Delivery tags count per channel, so
+// LastDeliveryTag starts over whenever the consumer reopens its channel.
+type Stats struct {
+	Queue           string     `json:"queue"`
+	State           string     `json:"state"`
+	ConsumingSince  *time.Time `json:"consumingSince,omitempty"`
+	Processed       int64      `json:"processed"`
+	Failed          int64      `json:"failed"`
+	Restarts        int64      `json:"restarts"`
+	LastDeliveryTag uint64     `json:"lastDeliveryTag"`
+	LastProcessedAt *time.Time `json:"lastProcessedAt,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	// Backlog is the queue's ready messages and Rate the messages processed
+	// per second between the last two samples; LagSeconds estimates how
+	// long the backlog takes to drain at that rate.
+	Backlog    int        `json:"backlog"`
+	Consumers  int        `json:"consumers"`
+	Rate       float64    `json:"rate"`
+	LagSeconds float64    `json:"lagSeconds"`
+	SampledAt  *time.Time `json:"sampledAt,omitempty"`
+}
+
+type IQueueInspector interface {
+	QueueDepth(name string) (messages, consumers int, err error)
+}
+
+// sample is the processed count at a point in time, to derive the rate
+// from.
+type sample struct {
+	processed int64
+	at        time.Time
+}
+
+// Registry keeps the consumers of the process so their state can be shown
+// and their queues sampled for lag.
+type Registry struct {
+	mu        sync.Mutex
+	consumers []*Consumer
+	samples   map[string]sample
+	backlog   map[string]Stats
+}
+
+func NewRegistry() *Registry {
+	return &Registry{samples: map[string]sample{}, backlog: map[string]Stats{}}
+}
+
+func (r *Registry) Add(c *Consumer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.consumers = append(r.consumers, c)
+}
+
+// Stats returns the state of every consumer with its last lag sample.
+func (r *Registry) Stats() []Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := make([]Stats, 0, len(r.consumers))
+	for _, c := range r.consumers {
+		s := c.Stats()
+		if b, ok := r.backlog[c.queue]; ok {
+			s.Backlog, s.Consumers, s.Rate, s.LagSeconds, s.SampledAt = b.Backlog, b.Consumers, b.Rate, b.LagSeconds, b.SampledAt
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// Sample reads the depth of every consumed queue and updates the lag
+// metrics. Queues that cannot be inspected keep their last sample.
+func (r *Registry) Sample(queues IQueueInspector) {
+	r.mu.Lock()
+	registered := append([]*Consumer(nil), r.consumers...)
+	r.mu.Unlock()
+
+	for _, c := range registered {
+		messages, consumers, err := queues.QueueDepth(c.queue)
+		if err != nil {
+			log.Printf("Failed to inspect queue %s: %v", c.queue, err)
+			continue
+		}
+		now := time.Now()
+		processed := c.Stats().Processed
+
+		r.mu.Lock()
+		b := Stats{Backlog: messages, Consumers: consumers, SampledAt: &now}
+		if prev, ok := r.samples[c.queue]; ok && now.After(prev.at) {
+			b.Rate = float64(processed-prev.processed) / now.Sub(prev.at).Seconds()
+		}
+		b.LagSeconds = lagSeconds(messages, b.Rate)
+		r.samples[c.queue] = sample{processed: processed, at: now}
+		r.backlog[c.queue] = b
+		r.mu.Unlock()
+
+		metrics.ConsumerLagMessages.WithLabelValues(c.queue).Set(float64(messages))
+		metrics.ConsumerLagSeconds.WithLabelValues(c.queue).Set(b.LagSeconds)
+	}
+}
+
+// lagSeconds estimates how long backlog messages take at rate per second:
+// 0 without a backlog and -1 when nothing is being processed.
+func lagSeconds(backlog int, rate float64) float64 {
+	switch {
+	case backlog == 0:
+		return 0
+	case rate <= 0:
+		return -1
+	}
+	return float64(backlog) / rate
+}
@@ -0,0 +1,52 @@
+package consumer
+
+import (
+	"errors"
+	"order-service/internal/backoff"
+	"testing"
+)
+
+type fakeQueues map[string]int
+
+func (f fakeQueues) QueueDepth(name string) (int, int, error) {
+	messages, ok := f[name]
+	if !ok {
+		return 0, 0, errors.New("no such queue")
+	}
+	return messages, 1, nil
+}
+
+func TestRegistrySample(t *testing.T) {
+	r := NewRegistry()
+	c := New(nil, "orders", nil, backoff.Policy{})
+	r.Add(c)
+	r.Add(New(nil, "missing", nil, backoff.Policy{}))
+
+	r.Sample(fakeQueues{"orders": 10})
+	stats := r.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 consumers, got %d", len(stats))
+	}
+	if s := stats[0]; s.Backlog != 10 || s.LagSeconds != -1 || s.State != StateStarting {
+		t.Errorf("Expected a backlog of 10 with unknown lag, got %+v", s)
+	}
+	if s := stats[1]; s.SampledAt != nil {
+		t.Errorf("Expected no sample for a queue that cannot be inspected, got %+v", s)
+	}
+}
+
+func TestLagSeconds(t *testing.T) {
+	for _, tt := range []struct {
+		backlog int
+		rate    float64
+		want    float64
+	}{
+		{0, 0, 0},
+		{10, 0, -1},
+		{10, 2, 5},
+	} {
+		if got := lagSeconds(tt.backlog, tt.rate); got != tt.want {
+			t.Errorf("lagSeconds(%d, %v) = %v, want %v", tt.backlog, tt.rate, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnsupportedPair = errors.New("no exchange rate for currency pair")
+
+// RateProvider returns how many units of to one unit of from is worth.
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+// StaticProvider serves rates configured at startup. A pair that is only
+// configured the other way round is served as its inverse.
+type StaticProvider struct {
+	rates map[string]float64
+}
+
+var _ RateProvider = &StaticProvider{}
+
+// ParseRates reads a JSON object of "FROM/TO" pairs to rates, e.g.
+// {"USD/IDR": 15500, "EUR/IDR": 16800}.
+func ParseRates(data string) (*StaticProvider, error) {
+	rates := map[string]float64{}
+	if data == "" {
+		return &StaticProvider{rates: rates}, nil
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse exchange rates: %w", err)
+	}
+	for pair, rate := range raw {
+		from, to, ok := strings.Cut(pair, "/")
+		if !ok || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q", pair)
+		}
+		rates[pairKey(from, to)] = rate
+	}
+	return &StaticProvider{rates: rates}, nil
+}
+
+func (p *StaticProvider) Rate(ctx context.Context, from, to string) (float64, error) {
+	if rate, ok := p.rates[pairKey(from, to)]; ok {
+		return rate, nil
+	}
+	if rate, ok := p.rates[pairKey(to, from)]; ok {
+		return 1 / rate, nil
+	}
+	return 0, fmt.Errorf("%w %s/%s", ErrUnsupportedPair, from, to)
+}
+
+// CachedProvider remembers rates from another provider for ttl.
+type CachedProvider struct {
+	next RateProvider
+	ttl  time.Duration
+
+	mu    sync.Mutex
+	rates map[string]cachedRate
+}
+
+type cachedRate struct {
+	rate      float64
+	fetchedAt time.Time
+}
+
+var _ RateProvider = &CachedProvider{}
+
+func NewCachedProvider(next RateProvider, ttl time.Duration) *CachedProvider {
+	return &CachedProvider{next: next, ttl: ttl, rates: map[string]cachedRate{}}
+}
+
+func (p *CachedProvider) Rate(ctx context.Context, from, to string) (float64, error) {
+	key := pairKey(from, to)
+	p.mu.Lock()
+	cached, ok := p.rates[key]
+	p.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < p.ttl {
+		return cached.rate, nil
+	}
+
+	rate, err := p.next.Rate(ctx, from, to)
+	if err != nil {
+		if ok && !errors.Is(err, ErrUnsupportedPair) {
+			// Keep serving the last known rate while the provider is down.
+			return cached.rate, nil
+		}
+		return 0, err
+	}
+	p.mu.Lock()
+	p.rates[key] = cachedRate{rate: rate, fetchedAt: time.Now()}
+	p.mu.Unlock()
+	return rate, nil
+}
+
+// Normalize upper-cases an ISO 4217 code.
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func pairKey(from, to string) string {
+	return Normalize(from) + "/" + Normalize(to)
+}
@@ -0,0 +1,55 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStaticProvider(t *testing.T) {
+	p, err := ParseRates(`{"usd/idr": 16000}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	ctx := context.Background()
+
+	if rate, _ := p.Rate(ctx, "USD", "IDR"); rate != 16000 {
+		t.Errorf("Expected 16000, got %v", rate)
+	}
+	if rate, _ := p.Rate(ctx, "IDR", "usd"); rate != 1.0/16000 {
+		t.Errorf("Expected inverse rate, got %v", rate)
+	}
+	if _, err := p.Rate(ctx, "EUR", "IDR"); !errors.Is(err, ErrUnsupportedPair) {
+		t.Errorf("Expected ErrUnsupportedPair, got %v", err)
+	}
+}
+
+type flakyProvider struct {
+	rate  float64
+	err   error
+	calls int
+}
+
+func (p *flakyProvider) Rate(ctx context.Context, from, to string) (float64, error) {
+	p.calls++
+	return p.rate, p.err
+}
+
+func TestCachedProvider(t *testing.T) {
+	next := &flakyProvider{rate: 2}
+	p := NewCachedProvider(next, time.Hour)
+	ctx := context.Background()
+
+	p.Rate(ctx, "A", "B")
+	p.Rate(ctx, "A", "B")
+	if next.calls != 1 {
+		t.Errorf("Expected one upstream call, got %d", next.calls)
+	}
+
+	p.ttl = 0
+	next.err = errors.New("timeout")
+	if rate, err := p.Rate(ctx, "A", "B"); err != nil || rate != 2 {
+		t.Errorf("Expected stale rate 2 while provider is down, got %v, %v", rate, err)
+	}
+}
@@ -0,0 +1,65 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPProvider fetches rates from an exchange-rate API via
+// GET {baseURL}/rates?from=USD&to=IDR, answering {"rate": 15500}. Wrap it in
+// a CachedProvider to avoid a request per order.
+type HTTPProvider struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+var _ RateProvider = &HTTPProvider{}
+
+func NewHTTPProvider(baseURL, apiKey string) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (p *HTTPProvider) Rate(ctx context.Context, from, to string) (float64, error) {
+	q := url.Values{"from": {Normalize(from)}, "to": {Normalize(to)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/rates?"+q.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to call exchange rate service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, fmt.Errorf("%w %s/%s", ErrUnsupportedPair, from, to)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("exchange rate service returned status: %s", resp.Status)
+	}
+
+	var body struct {
+		Rate float64 `json:"rate"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return 0, fmt.Errorf("failed to decode exchange rate: %w", err)
+	}
+	if body.Rate <= 0 {
+		return 0, fmt.Errorf("exchange rate service returned invalid rate %v", body.Rate)
+	}
+	return body.Rate, nil
+}
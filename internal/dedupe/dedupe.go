@@ -0,0 +1,118 @@
+// Package dedupe remembers which events were already handled, so the same
+// logical event does not trigger its side effects twice when it is
+// published or delivered again. Claims live in Redis with a TTL; while
+// Redis is unavailable they are made in Postgres instead.
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Entry is a claim made in Postgres. It counts until ExpiresAt.
+type Entry struct {
+	Key       string    `gorm:"primaryKey"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+func (Entry) TableName() string { return "processed_events" }
+
+// Claimer remembers keys, such as event IDs or webhook nonces, to tell a
+// first delivery from a repeated one.
+type Claimer interface {
+	// Claim records key for ttl. It reports false when key was already
+	// claimed within its ttl, meaning it was handled before.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release forgets key, so it is handled again the next time it comes.
+	Release(ctx context.Context, key string) error
+}
+
+// backend records keys in one store.
+type backend interface {
+	// claim records key from now for ttl. It reports false when key is
+	// already recorded and has not expired.
+	claim(ctx context.Context, key string, now time.Time, ttl time.Duration) (bool, error)
+	release(ctx context.Context, key string) error
+}
+
+// Store claims event keys, in Redis and, when Redis fails, in Postgres.
+// A key claimed in one of them is not seen by the other, so an event
+// handled just before an outage can be handled once more during it.
+type Store struct {
+	primary  backend
+	fallback backend
+	db       *gorm.DB
+	now      func() time.Time
+}
+
+var _ Claimer = &Store{}
+
+func NewStore(client *redis.Client, db *gorm.DB) *Store {
+	return &Store{primary: redisClaimer{client: client}, fallback: dbClaimer{db: db}, db: db, now: time.Now}
+}
+
+// Claim claims key in Redis, or in Postgres when Redis fails.
+func (s *Store) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := s.now()
+	ok, err := s.primary.claim(ctx, key, now, ttl)
+	if err == nil {
+		return ok, nil
+	}
+	ok, fallbackErr := s.fallback.claim(ctx, key, now, ttl)
+	if fallbackErr != nil {
+		return false, fmt.Errorf("failed to claim %s: %w", key, errors.Join(err, fallbackErr))
+	}
+	return ok, nil
+}
+
+// Release forgets key in both backends.
+func (s *Store) Release(ctx context.Context, key string) error {
+	return errors.Join(s.primary.release(ctx, key), s.fallback.release(ctx, key))
+}
+
+// Purge deletes the Postgres claims that expired before now and returns
+// how many there were. Redis expires its claims itself.
+func (s *Store) Purge(ctx context.Context, now time.Time) (int64, error) {
+	res := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&Entry{})
+	return res.RowsAffected, res.Error
+}
+
+type redisClaimer struct {
+	client *redis.Client
+}
+
+func redisKey(key string) string { return "dedupe:" + key }
+
+func (c redisClaimer) claim(ctx context.Context, key string, _ time.Time, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, redisKey(key), 1, ttl).Result()
+}
+
+func (c redisClaimer) release(ctx context.Context, key string) error {
+	return c.client.Del(ctx, redisKey(key)).Err()
+}
+
+type dbClaimer struct {
+	db *gorm.DB
+}
+
+// claim inserts the key, or takes over an expired claim of it.
+func (c dbClaimer) claim(ctx context.Context, key string, now time.Time, ttl time.Duration) (bool, error) {
+	res := c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "processed_events.expires_at <= ?", Vars: []any{now}},
+		}},
+	}).Create(&Entry{Key: key, ExpiresAt: now.Add(ttl)})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (c dbClaimer) release(ctx context.Context, key string) error {
+	return c.db.WithContext(ctx).Delete(&Entry{Key: key}).Error
+}
@@ -0,0 +1,80 @@
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClaimer keeps claims in memory like the Redis and Postgres
+// backends do.
+type fakeClaimer struct {
+	expires map[string]time.Time
+	err     error
+}
+
+func newFakeClaimer() *fakeClaimer {
+	return &fakeClaimer{expires: map[string]time.Time{}}
+}
+
+func (f *fakeClaimer) claim(_ context.Context, key string, now time.Time, ttl time.Duration) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	if exp, ok := f.expires[key]; ok && now.Before(exp) {
+		return false, nil
+	}
+	f.expires[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (f *fakeClaimer) release(_ context.Context, key string) error {
+	if f.err != nil {
+		return f.err
+	}
+	delete(f.expires, key)
+	return nil
+}
+
+func TestClaim(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	primary, fallback := newFakeClaimer(), newFakeClaimer()
+	s := &Store{primary: primary, fallback: fallback, now: func() time.Time { return now }}
+	ctx := context.Background()
+
+	if ok, err := s.Claim(ctx, "e1", time.Hour); err != nil || !ok {
+		t.Fatalf("Expected the first claim to succeed, got %t, %v", ok, err)
+	}
+	if ok, _ := s.Claim(ctx, "e1", time.Hour); ok {
+		t.Error("Expected a second claim to fail")
+	}
+	now = now.Add(time.Hour)
+	if ok, _ := s.Claim(ctx, "e1", time.Hour); !ok {
+		t.Error("Expected the claim to be taken over once expired")
+	}
+	if _, ok := fallback.expires["e1"]; ok {
+		t.Error("Expected Postgres not to be used while Redis works")
+	}
+
+	primary.err = errors.New("redis down")
+	if ok, err := s.Claim(ctx, "e2", time.Hour); err != nil || !ok {
+		t.Fatalf("Expected the claim to fall back to Postgres, got %t, %v", ok, err)
+	}
+	if ok, _ := s.Claim(ctx, "e2", time.Hour); ok {
+		t.Error("Expected Postgres to know the claim")
+	}
+
+	fallback.err = errors.New("postgres down")
+	if _, err := s.Claim(ctx, "e3", time.Hour); err == nil {
+		t.Error("Expected an error when both backends fail")
+	}
+
+	primary.err, fallback.err = nil, nil
+	if err := s.Release(ctx, "e1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ok, _ := s.Claim(ctx, "e1", time.Hour); !ok {
+		t.Error("Expected a released key to be claimable")
+	}
+}
@@ -0,0 +1,205 @@
+package degrade
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/internal/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Modes switched on while a dependency is down.
+const (
+	// ModeCacheBypass serves reads from the database instead of Redis.
+	ModeCacheBypass = "cache_bypass"
+	// ModeOutboxOnly spools events in the outbox instead of publishing
+	// them to RabbitMQ.
+	ModeOutboxOnly = "outbox_only"
+	// ModePendingValidation accepts orders as PENDING_VALIDATION without
+	// asking product-service.
+	ModePendingValidation = "pending_validation"
+)
+
+// Check returns an error when the dependency is unavailable.
+type Check func(ctx context.Context) error
+
+// Dependency is a service the order service relies on. Critical
+// dependencies fail readiness when down; the others switch on Modes so the
+// service keeps working without them.
+type Dependency struct {
+	Name     string
+	Check    Check
+	Critical bool
+	Modes    []string
+}
+
+type Status struct {
+	Name     string    `json:"name"`
+	Up       bool      `json:"up"`
+	Critical bool      `json:"critical"`
+	Error    string    `json:"error,omitempty"`
+	Since    time.Time `json:"since"`
+}
+
+// Report is the controller's view of every dependency.
+type Report struct {
+	Ready        bool     `json:"ready"`
+	Dependencies []Status `json:"dependencies"`
+	Modes        []string `json:"modes"`
+}
+
+// Controller probes dependencies and turns degraded modes on and off as
+// they go down and come back. Dependencies count as up until a probe says
+// otherwise.
+type Controller struct {
+	deps    []Dependency
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	status map[string]*Status
+}
+
+func New(timeout time.Duration, deps ...Dependency) *Controller {
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	c := &Controller{deps: deps, timeout: timeout, status: make(map[string]*Status)}
+	now := time.Now().UTC()
+	for _, d := range deps {
+		c.status[d.Name] = &Status{Name: d.Name, Up: true, Critical: d.Critical, Since: now}
+		metrics.DependencyUp.WithLabelValues(d.Name).Set(1)
+	}
+	return c
+}
+
+// Probe checks every dependency concurrently and updates the active modes.
+func (c *Controller) Probe(ctx context.Context) {
+	errs := make([]error, len(c.deps))
+	var wg sync.WaitGroup
+	for i, d := range c.deps {
+		wg.Add(1)
+		go func(i int, d Dependency) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			errs[i] = d.Check(ctx)
+		}(i, d)
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UTC()
+	for i, d := range c.deps {
+		st := c.status[d.Name]
+		up := errs[i] == nil
+		if up != st.Up {
+			st.Since = now
+			if up {
+				log.Printf("Dependency %s recovered", d.Name)
+			} else {
+				log.Printf("Dependency %s is down: %v", d.Name, errs[i])
+			}
+		}
+		st.Up = up
+		st.Error = ""
+		if !up {
+			st.Error = errs[i].Error()
+		}
+		metrics.DependencyUp.WithLabelValues(d.Name).Set(gauge(up))
+	}
+	for _, mode := range c.modes() {
+		metrics.DegradedMode.WithLabelValues(mode).Set(gauge(c.activeLocked(mode)))
+	}
+}
+
+// Active reports whether a degraded mode is on.
+func (c *Controller) Active(mode string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.activeLocked(mode)
+}
+
+func (c *Controller) activeLocked(mode string) bool {
+	for _, d := range c.deps {
+		if c.status[d.Name].Up {
+			continue
+		}
+		for _, m := range d.Modes {
+			if m == mode {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modes lists every mode some dependency can switch on.
+func (c *Controller) modes() []string {
+	seen := make(map[string]bool)
+	var modes []string
+	for _, d := range c.deps {
+		for _, m := range d.Modes {
+			if !seen[m] {
+				seen[m] = true
+				modes = append(modes, m)
+			}
+		}
+	}
+	sort.Strings(modes)
+	return modes
+}
+
+// Report is not ready when a critical dependency is down. Degraded
+// dependencies only show up in Modes.
+func (c *Controller) Report() Report {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r := Report{Ready: true, Dependencies: []Status{}, Modes: []string{}}
+	for _, d := range c.deps {
+		st := *c.status[d.Name]
+		if !st.Up && st.Critical {
+			r.Ready = false
+		}
+		r.Dependencies = append(r.Dependencies, st)
+	}
+	for _, mode := range c.modes() {
+		if c.activeLocked(mode) {
+			r.Modes = append(r.Modes, mode)
+		}
+	}
+	return r
+}
+
+func gauge(on bool) float64 {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+// HTTPCheck treats a service as down when url cannot be reached or answers
+// with a 5xx status. A nil client means http.DefaultClient.
+func HTTPCheck(client *http.Client, url string) Check {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("health check returned status: %s", resp.Status)
+		}
+		return nil
+	}
+}
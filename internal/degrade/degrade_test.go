@@ -0,0 +1,80 @@
+package degrade
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestControllerModes(t *testing.T) {
+	redisDown := false
+	dbDown := false
+	c := New(time.Second,
+		Dependency{Name: "postgres", Critical: true, Check: func(ctx context.Context) error {
+			if dbDown {
+				return errors.New("down")
+			}
+			return nil
+		}},
+		Dependency{Name: "redis", Modes: []string{ModeCacheBypass}, Check: func(ctx context.Context) error {
+			if redisDown {
+				return errors.New("down")
+			}
+			return nil
+		}},
+	)
+
+	c.Probe(context.Background())
+	if c.Active(ModeCacheBypass) {
+		t.Error("Expected cache bypass to be off while redis is up")
+	}
+
+	redisDown = true
+	c.Probe(context.Background())
+	if !c.Active(ModeCacheBypass) {
+		t.Error("Expected cache bypass to be on while redis is down")
+	}
+	r := c.Report()
+	if !r.Ready {
+		t.Error("Expected to stay ready with a non-critical dependency down")
+	}
+	if len(r.Modes) != 1 || r.Modes[0] != ModeCacheBypass {
+		t.Errorf("Expected modes [%s], got %v", ModeCacheBypass, r.Modes)
+	}
+
+	dbDown = true
+	c.Probe(context.Background())
+	if c.Report().Ready {
+		t.Error("Expected not ready with a critical dependency down")
+	}
+
+	redisDown, dbDown = false, false
+	c.Probe(context.Background())
+	if c.Active(ModeCacheBypass) || !c.Report().Ready {
+		t.Error("Expected modes off and ready after recovery")
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	check := HTTPCheck(nil, server.URL+"/health")
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	status = http.StatusNotFound
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Expected a 404 to count as up, got %v", err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := check(context.Background()); err == nil {
+		t.Error("Expected an error for a 503, got nil")
+	}
+}
@@ -0,0 +1,165 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type endpoint struct {
+	url          string
+	fails        int
+	evictedUntil time.Time
+}
+
+// Balancer hands out the endpoints of a service round-robin. An endpoint
+// that fails maxFails times in a row is evicted for cooldown; one success
+// takes it back. When every endpoint is evicted they are all used again,
+// since a guess beats refusing every request.
+type Balancer struct {
+	name     string
+	resolver Resolver
+	maxFails int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	next      int
+}
+
+func NewBalancer(name string, resolver Resolver, maxFails int, cooldown time.Duration) *Balancer {
+	if maxFails < 1 {
+		maxFails = 1
+	}
+	return &Balancer{name: name, resolver: resolver, maxFails: maxFails, cooldown: cooldown, now: time.Now}
+}
+
+// Refresh resolves the endpoints again. Endpoints that are still listed
+// keep their failures. If resolving fails or lists nothing, the current
+// endpoints are kept.
+func (b *Balancer) Refresh(ctx context.Context) error {
+	urls, err := b.resolver.Resolve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", b.name, err)
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("failed to resolve %s: %w", b.name, ErrNoEndpoints)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	known := make(map[string]*endpoint, len(b.endpoints))
+	for _, e := range b.endpoints {
+		known[e.url] = e
+	}
+	endpoints := make([]*endpoint, 0, len(urls))
+	for _, u := range urls {
+		if e, ok := known[u]; ok {
+			endpoints = append(endpoints, e)
+		} else {
+			endpoints = append(endpoints, &endpoint{url: u})
+		}
+	}
+	b.endpoints = endpoints
+	return nil
+}
+
+// Pick returns the base URL of the next endpoint to use.
+func (b *Balancer) Pick() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(b.endpoints)
+	if n == 0 {
+		return "", ErrNoEndpoints
+	}
+	now := b.now()
+	for i := 0; i < n; i++ {
+		e := b.endpoints[(b.next+i)%n]
+		if !now.Before(e.evictedUntil) {
+			b.next = (b.next + i + 1) % n
+			return e.url, nil
+		}
+	}
+	e := b.endpoints[b.next%n]
+	b.next = (b.next + 1) % n
+	return e.url, nil
+}
+
+// Report records the outcome of a request to an endpoint: err is nil when
+// the endpoint answered sanely.
+func (b *Balancer) Report(url string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range b.endpoints {
+		if e.url != url {
+			continue
+		}
+		if err == nil {
+			e.fails, e.evictedUntil = 0, time.Time{}
+			return
+		}
+		e.fails++
+		if e.fails >= b.maxFails {
+			e.fails = 0
+			e.evictedUntil = b.now().Add(b.cooldown)
+			log.Printf("Evicting %s endpoint %s for %s: %v", b.name, url, b.cooldown, err)
+		}
+		return
+	}
+}
+
+// Probe checks path on every endpoint and reports the outcomes. It fails
+// when no endpoint is healthy, so it can serve as the health check of the
+// service as a whole.
+func (b *Balancer) Probe(ctx context.Context, client *http.Client, path string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	b.mu.Lock()
+	urls := make([]string, len(b.endpoints))
+	for i, e := range b.endpoints {
+		urls[i] = e.url
+	}
+	b.mu.Unlock()
+	if len(urls) == 0 {
+		return ErrNoEndpoints
+	}
+
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			errs[i] = probe(ctx, client, u+path)
+			b.Report(u, errs[i])
+		}(i, u)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no healthy %s endpoint: %w", b.name, errs[0])
+}
+
+func probe(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("health check returned status: %s", resp.Status)
+	}
+	return nil
+}
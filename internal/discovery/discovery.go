@@ -0,0 +1,119 @@
+// Package discovery finds the instances of an upstream service and spreads
+// requests across them. A Resolver lists the base URLs of the instances,
+// from configuration, DNS SRV records or Consul; a Balancer hands them out
+// in turn and sets aside instances that keep failing until they recover.
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var ErrNoEndpoints = errors.New("no endpoints available")
+
+// Resolver lists the base URLs of the instances of a service.
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// Static is a fixed list of base URLs.
+type Static []string
+
+func (s Static) Resolve(context.Context) ([]string, error) {
+	urls := make([]string, 0, len(s))
+	for _, u := range s {
+		if u = strings.TrimRight(strings.TrimSpace(u), "/"); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls, nil
+}
+
+// SRV resolves the instances from the DNS SRV records of a name such as
+// _http._tcp.product-service.default.svc.cluster.local.
+type SRV struct {
+	name     string
+	scheme   string
+	resolver *net.Resolver
+}
+
+func NewSRV(name, scheme string) *SRV {
+	return &SRV{name: name, scheme: scheme, resolver: net.DefaultResolver}
+}
+
+func (s *SRV) Resolve(ctx context.Context) ([]string, error) {
+	_, records, err := s.resolver.LookupSRV(ctx, "", "", s.name)
+	if err != nil {
+		return nil, err
+	}
+	urls := make([]string, 0, len(records))
+	for _, r := range records {
+		host := strings.TrimSuffix(r.Target, ".")
+		urls = append(urls, s.scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(r.Port))))
+	}
+	return urls, nil
+}
+
+// Consul resolves the instances of a service that pass their Consul
+// health checks, through the agent's HTTP API.
+type Consul struct {
+	addr    string
+	service string
+	scheme  string
+	client  *http.Client
+}
+
+func NewConsul(addr, service, scheme string, client *http.Client) *Consul {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Consul{addr: strings.TrimRight(addr, "/"), service: service, scheme: scheme, client: client}
+}
+
+type consulEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+func (c *Consul) Resolve(ctx context.Context) ([]string, error) {
+	endpoint := fmt.Sprintf("%s/v1/health/service/%s?passing=true", c.addr, url.PathEscape(c.service))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call consul: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul returned status: %s", resp.Status)
+	}
+
+	var entries []consulEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode consul response: %w", err)
+	}
+	urls := make([]string, 0, len(entries))
+	for _, e := range entries {
+		// The service address is empty when it is the node's.
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		urls = append(urls, c.scheme+"://"+net.JoinHostPort(host, strconv.Itoa(e.Service.Port)))
+	}
+	return urls, nil
+}
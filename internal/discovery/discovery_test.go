@@ -0,0 +1,145 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatic(t *testing.T) {
+	urls, err := Static{"http://a:8081/", " ", "http://b:8081"}.Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := []string{"http://a:8081", "http://b:8081"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("Expected %v, got %v", want, urls)
+	}
+}
+
+func TestConsul(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/product-service" || r.URL.Query().Get("passing") != "true" {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 8081}},
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "10.1.0.2", "Port": 9000}}
+		]`))
+	}))
+	defer server.Close()
+
+	urls, err := NewConsul(server.URL, "product-service", "http", nil).Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := []string{"http://10.0.0.1:8081", "http://10.1.0.2:9000"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("Expected %v, got %v", want, urls)
+	}
+}
+
+type resolverFunc func() ([]string, error)
+
+func (f resolverFunc) Resolve(context.Context) ([]string, error) { return f() }
+
+func TestBalancer(t *testing.T) {
+	urls := []string{"http://a", "http://b", "http://c"}
+	b := NewBalancer("test", resolverFunc(func() ([]string, error) { return urls, nil }), 2, time.Minute)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := b.Pick(); !errors.Is(err, ErrNoEndpoints) {
+		t.Errorf("Expected ErrNoEndpoints before resolving, got %v", err)
+	}
+	if err := b.Refresh(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	picks := func(n int) []string {
+		var got []string
+		for i := 0; i < n; i++ {
+			u, _ := b.Pick()
+			got = append(got, u)
+		}
+		return got
+	}
+	if got := picks(4); !reflect.DeepEqual(got, []string{"http://a", "http://b", "http://c", "http://a"}) {
+		t.Errorf("Expected round-robin, got %v", got)
+	}
+
+	fail := errors.New("connection refused")
+	b.Report("http://b", fail)
+	if got := picks(3); !reflect.DeepEqual(got, []string{"http://b", "http://c", "http://a"}) {
+		t.Errorf("Expected one failure to keep the endpoint, got %v", got)
+	}
+	b.Report("http://b", fail)
+	if got := picks(3); !reflect.DeepEqual(got, []string{"http://c", "http://a", "http://c"}) {
+		t.Errorf("Expected b to be evicted, got %v", got)
+	}
+
+	// Endpoints still listed keep their state.
+	urls = []string{"http://b", "http://c"}
+	if err := b.Refresh(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := picks(2); !reflect.DeepEqual(got, []string{"http://c", "http://c"}) {
+		t.Errorf("Expected b to stay evicted after a refresh, got %v", got)
+	}
+
+	now = now.Add(time.Minute)
+	if got := picks(2); !reflect.DeepEqual(got, []string{"http://b", "http://c"}) {
+		t.Errorf("Expected b back after the cooldown, got %v", got)
+	}
+
+	b.Report("http://b", fail)
+	b.Report("http://b", fail)
+	b.Report("http://c", fail)
+	b.Report("http://c", fail)
+	if u, err := b.Pick(); err != nil || u == "" {
+		t.Errorf("Expected an endpoint when all are evicted, got %q, %v", u, err)
+	}
+
+	urls = nil
+	if err := b.Refresh(ctx); !errors.Is(err, ErrNoEndpoints) {
+		t.Errorf("Expected ErrNoEndpoints, got %v", err)
+	}
+	if u, _ := b.Pick(); u == "" {
+		t.Error("Expected the endpoints to be kept when resolving lists nothing")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	urls := []string{healthy.URL, broken.URL}
+	b := NewBalancer("test", Static(urls), 1, time.Minute)
+	ctx := context.Background()
+	b.Refresh(ctx)
+
+	if err := b.Probe(ctx, nil, "/health"); err != nil {
+		t.Fatalf("Expected the service to be healthy with one endpoint up, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if u, _ := b.Pick(); u != healthy.URL {
+			t.Errorf("Expected the failing endpoint to be evicted, got %s", u)
+		}
+	}
+
+	b = NewBalancer("test", Static{broken.URL}, 1, time.Minute)
+	b.Refresh(ctx)
+	if err := b.Probe(ctx, nil, "/health"); err == nil {
+		t.Error("Expected an error when no endpoint is healthy")
+	}
+}
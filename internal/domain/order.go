@@ -0,0 +1,271 @@
+// Package domain holds the order rules that need no I/O: pricing, stock
+// checks and the status lifecycle. It imports nothing outside the standard
+// library; the service layer loads state, runs these rules and stores the
+// result.
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	StatusPending     = "PENDING"
+	StatusOnHold      = "ON_HOLD"
+	StatusRejected    = "REJECTED"
+	StatusBackordered = "BACKORDERED"
+	// StatusAwaitingPayment orders have an uncaptured payment intent and
+	// become PENDING once it is captured.
+	StatusAwaitingPayment = "AWAITING_PAYMENT"
+	StatusPaymentExpired  = "PAYMENT_EXPIRED"
+	// StatusPaid is reached by installment orders once every installment
+	// has been paid.
+	StatusPaid = "PAID"
+	// StatusPendingValidation orders were accepted while product-service
+	// was down. They are priced and validated once it is back.
+	StatusPendingValidation = "PENDING_VALIDATION"
+	// StatusScheduled orders are priced when placed and activated, priced
+	// and checked again at their ProcessAt time.
+	StatusScheduled = "SCHEDULED"
+	// StatusCancelled orders were cancelled by the customer before they
+	// were activated.
+	StatusCancelled = "CANCELLED"
+	// StatusReserved orders hold their stock without payment until
+	// ReservedUntil and become PENDING once the customer confirms them.
+	StatusReserved           = "RESERVED"
+	StatusReservationExpired = "RESERVATION_EXPIRED"
+)
+
+// Statuses are all order statuses.
+var Statuses = []string{
+	StatusPending, StatusOnHold, StatusRejected, StatusBackordered,
+	StatusAwaitingPayment, StatusPaymentExpired, StatusPaid,
+	StatusPendingValidation, StatusScheduled, StatusCancelled,
+	StatusReserved, StatusReservationExpired,
+}
+
+// transitions lists the statuses each status may move to. Statuses
+// without an entry are final.
+var transitions = map[string][]string{
+	StatusPending:           {StatusBackordered, StatusOnHold, StatusAwaitingPayment, StatusPaid, StatusReserved},
+	StatusBackordered:       {StatusPending, StatusOnHold},
+	StatusOnHold:            {StatusPending, StatusRejected},
+	StatusAwaitingPayment:   {StatusPending, StatusPaymentExpired},
+	StatusPendingValidation: {StatusPending, StatusRejected},
+	StatusScheduled:         {StatusPending, StatusRejected, StatusCancelled},
+	StatusReserved:          {StatusPending, StatusReservationExpired},
+}
+
+// CanTransition reports whether an order may move from one status to
+// another.
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// ErrInvalidTransition is wrapped by every TransitionError.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// TransitionError is returned when an order is asked to move to a status
+// its current status does not lead to.
+type TransitionError struct {
+	From, To string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error { return ErrInvalidTransition }
+
+// Order is the order aggregate: the state its rules depend on and the
+// operations that change it. It knows nothing about storage, events or
+// other services.
+type Order struct {
+	ID               string
+	ProductID        string
+	CustomerID       string
+	Quantity         int
+	Status           string
+	HoldReason       string
+	Pricing          Pricing
+	PaymentExpiresAt time.Time
+	// ProcessAt is when a scheduled order is activated.
+	ProcessAt time.Time
+	// ReservedUntil is when a reserved order's stock is released.
+	ReservedUntil time.Time
+}
+
+// NewOrder starts an order in PENDING.
+func NewOrder(id, productID, customerID string, quantity int) (*Order, error) {
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+	return &Order{ID: id, ProductID: productID, CustomerID: customerID, Quantity: quantity, Status: StatusPending}, nil
+}
+
+func (o *Order) moveTo(status string) error {
+	if !CanTransition(o.Status, status) {
+		return &TransitionError{From: o.Status, To: status}
+	}
+	o.Status = status
+	return nil
+}
+
+// Backorder parks the order until stock covers it; see CheckStock.
+func (o *Order) Backorder() error {
+	return o.moveTo(StatusBackordered)
+}
+
+// Hold puts the order aside for manual review.
+func (o *Order) Hold(reason string) error {
+	if err := o.moveTo(StatusOnHold); err != nil {
+		return err
+	}
+	o.HoldReason = reason
+	return nil
+}
+
+// Approve releases a held order.
+func (o *Order) Approve() error {
+	if o.Status != StatusOnHold {
+		return &TransitionError{From: o.Status, To: StatusPending}
+	}
+	return o.moveTo(StatusPending)
+}
+
+// Reject refuses a held order, or one that failed validation.
+func (o *Order) Reject(reason string) error {
+	if err := o.moveTo(StatusRejected); err != nil {
+		return err
+	}
+	if reason != "" {
+		o.HoldReason = reason
+	}
+	return nil
+}
+
+// AwaitPayment marks the order as waiting for a payment intent that
+// expires at expiresAt.
+func (o *Order) AwaitPayment(expiresAt time.Time) error {
+	if err := o.moveTo(StatusAwaitingPayment); err != nil {
+		return err
+	}
+	o.PaymentExpiresAt = expiresAt
+	return nil
+}
+
+// ErrPaymentExpired is returned when a payment is confirmed after its
+// intent expired.
+var ErrPaymentExpired = errors.New("payment intent has expired")
+
+// CanConfirmPayment reports whether the order's payment may be captured
+// at now.
+func (o *Order) CanConfirmPayment(now time.Time) error {
+	if o.Status != StatusAwaitingPayment {
+		return &TransitionError{From: o.Status, To: StatusPending}
+	}
+	if now.After(o.PaymentExpiresAt) {
+		return ErrPaymentExpired
+	}
+	return nil
+}
+
+// ConfirmPayment releases an order whose payment was captured. Whether
+// the capture was still allowed is CanConfirmPayment's call.
+func (o *Order) ConfirmPayment() error {
+	if o.Status != StatusAwaitingPayment {
+		return &TransitionError{From: o.Status, To: StatusPending}
+	}
+	return o.moveTo(StatusPending)
+}
+
+// ExpirePayment gives up on an order that was not paid in time.
+func (o *Order) ExpirePayment() error {
+	return o.moveTo(StatusPaymentExpired)
+}
+
+// FulfilBackorder releases a backorder once stock covers it.
+func (o *Order) FulfilBackorder() error {
+	if o.Status != StatusBackordered {
+		return &TransitionError{From: o.Status, To: StatusPending}
+	}
+	return o.moveTo(StatusPending)
+}
+
+// Validate prices an order accepted without validation and releases it.
+func (o *Order) Validate(pricing Pricing) error {
+	if o.Status != StatusPendingValidation {
+		return &TransitionError{From: o.Status, To: StatusPending}
+	}
+	o.Pricing = pricing
+	return o.moveTo(StatusPending)
+}
+
+// ErrNotScheduled is returned when a scheduling operation is applied to an
+// order that is not scheduled.
+var ErrNotScheduled = errors.New("order is not scheduled")
+
+// ErrScheduleInPast is returned for a processing time that has passed.
+var ErrScheduleInPast = errors.New("processing time must be in the future")
+
+// Reschedule moves a scheduled order's processing time to processAt.
+func (o *Order) Reschedule(processAt, now time.Time) error {
+	if o.Status != StatusScheduled {
+		return ErrNotScheduled
+	}
+	if !processAt.After(now) {
+		return ErrScheduleInPast
+	}
+	o.ProcessAt = processAt
+	return nil
+}
+
+// Activate releases a scheduled order once it has been priced again.
+func (o *Order) Activate(pricing Pricing) error {
+	if o.Status != StatusScheduled {
+		return &TransitionError{From: o.Status, To: StatusPending}
+	}
+	o.Pricing = pricing
+	return o.moveTo(StatusPending)
+}
+
+// Cancel withdraws a scheduled order before it is activated.
+func (o *Order) Cancel() error {
+	if o.Status != StatusScheduled {
+		return ErrNotScheduled
+	}
+	return o.moveTo(StatusCancelled)
+}
+
+// ErrReservationExpired is returned when a reservation is confirmed after
+// it ran out.
+var ErrReservationExpired = errors.New("reservation has expired")
+
+// Reserve holds a new order's stock until until without taking payment.
+func (o *Order) Reserve(until time.Time) error {
+	if err := o.moveTo(StatusReserved); err != nil {
+		return err
+	}
+	o.ReservedUntil = until
+	return nil
+}
+
+// ConfirmReservation releases a reserved order into the normal flow if its
+// reservation still runs at now.
+func (o *Order) ConfirmReservation(now time.Time) error {
+	if o.Status != StatusReserved {
+		return &TransitionError{From: o.Status, To: StatusPending}
+	}
+	if now.After(o.ReservedUntil) {
+		return ErrReservationExpired
+	}
+	return o.moveTo(StatusPending)
+}
+
+// ExpireReservation gives up on a reservation that was not confirmed in
+// time.
+func (o *Order) ExpireReservation() error {
+	return o.moveTo(StatusReservationExpired)
+}
@@ -0,0 +1,279 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		op     func(*Order) error
+		want   string
+	}{
+		{"backorder", StatusPending, (*Order).Backorder, StatusBackordered},
+		{"hold pending", StatusPending, func(o *Order) error { return o.Hold("suspicious") }, StatusOnHold},
+		{"hold backorder", StatusBackordered, func(o *Order) error { return o.Hold("suspicious") }, StatusOnHold},
+		{"approve", StatusOnHold, (*Order).Approve, StatusPending},
+		{"reject held", StatusOnHold, func(o *Order) error { return o.Reject("") }, StatusRejected},
+		{"reject unvalidated", StatusPendingValidation, func(o *Order) error { return o.Reject("no stock") }, StatusRejected},
+		{"await payment", StatusPending, func(o *Order) error { return o.AwaitPayment(time.Now()) }, StatusAwaitingPayment},
+		{"confirm payment", StatusAwaitingPayment, (*Order).ConfirmPayment, StatusPending},
+		{"expire payment", StatusAwaitingPayment, (*Order).ExpirePayment, StatusPaymentExpired},
+		{"fulfil backorder", StatusBackordered, (*Order).FulfilBackorder, StatusPending},
+		{"validate", StatusPendingValidation, func(o *Order) error { return o.Validate(Pricing{Total: 10}) }, StatusPending},
+		{"activate", StatusScheduled, func(o *Order) error { return o.Activate(Pricing{Total: 10}) }, StatusPending},
+		{"reject scheduled", StatusScheduled, func(o *Order) error { return o.Reject("no stock") }, StatusRejected},
+		{"cancel", StatusScheduled, (*Order).Cancel, StatusCancelled},
+		{"reserve", StatusPending, func(o *Order) error { return o.Reserve(time.Now().Add(time.Minute)) }, StatusReserved},
+		{"expire reservation", StatusReserved, (*Order).ExpireReservation, StatusReservationExpired},
+
+		{"approve pending", StatusPending, (*Order).Approve, ""},
+		{"reject pending", StatusPending, func(o *Order) error { return o.Reject("") }, ""},
+		{"hold rejected", StatusRejected, func(o *Order) error { return o.Hold("") }, ""},
+		{"confirm pending", StatusPending, (*Order).ConfirmPayment, ""},
+		{"expire pending", StatusPending, (*Order).ExpirePayment, ""},
+		{"fulfil held", StatusOnHold, (*Order).FulfilBackorder, ""},
+		{"validate pending", StatusPending, func(o *Order) error { return o.Validate(Pricing{}) }, ""},
+		{"activate pending", StatusPending, func(o *Order) error { return o.Activate(Pricing{}) }, ""},
+		{"confirm unreserved", StatusPending, func(o *Order) error { return o.ConfirmReservation(time.Now()) }, ""},
+		{"reserve held", StatusOnHold, func(o *Order) error { return o.Reserve(time.Now()) }, ""},
+		{"anything from paid", StatusPaid, (*Order).Backorder, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Order{Status: tt.status}
+			err := tt.op(o)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Errorf("Expected ErrInvalidTransition, got %v", err)
+				}
+				if o.Status != tt.status {
+					t.Errorf("Expected status to stay %s, got %s", tt.status, o.Status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if o.Status != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, o.Status)
+			}
+		})
+	}
+}
+
+func TestStatusesCoverTransitions(t *testing.T) {
+	for from, tos := range transitions {
+		for _, status := range append(tos, from) {
+			if !slices.Contains(Statuses, status) {
+				t.Errorf("Expected %s in Statuses", status)
+			}
+		}
+	}
+}
+
+func TestNewOrder(t *testing.T) {
+	o, err := NewOrder("o1", "p1", "c1", 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if o.Status != StatusPending {
+		t.Errorf("Expected %s, got %s", StatusPending, o.Status)
+	}
+	if _, err := NewOrder("o2", "p1", "c1", 0); err == nil {
+		t.Error("Expected an error for a zero quantity")
+	}
+}
+
+func TestHoldAndReject(t *testing.T) {
+	o := &Order{Status: StatusPending}
+	if err := o.Hold("velocity"); err != nil || o.HoldReason != "velocity" {
+		t.Fatalf("Expected hold reason to be set, got %q, %v", o.HoldReason, err)
+	}
+	if err := o.Reject(""); err != nil || o.HoldReason != "velocity" {
+		t.Errorf("Expected rejection without a reason to keep the hold reason, got %q, %v", o.HoldReason, err)
+	}
+}
+
+func TestCanConfirmPayment(t *testing.T) {
+	now := time.Now()
+	o := &Order{Status: StatusAwaitingPayment, PaymentExpiresAt: now.Add(time.Minute)}
+	if err := o.CanConfirmPayment(now); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := o.CanConfirmPayment(now.Add(2 * time.Minute)); !errors.Is(err, ErrPaymentExpired) {
+		t.Errorf("Expected ErrPaymentExpired, got %v", err)
+	}
+	o.Status = StatusPending
+	if err := o.CanConfirmPayment(now); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Expected ErrInvalidTransition, got %v", err)
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	now := time.Now()
+	o := &Order{Status: StatusScheduled, ProcessAt: now.Add(time.Hour)}
+	if err := o.Reschedule(now.Add(2*time.Hour), now); err != nil || !o.ProcessAt.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("Expected the processing time to move, got %v, %v", o.ProcessAt, err)
+	}
+	if err := o.Reschedule(now.Add(-time.Minute), now); !errors.Is(err, ErrScheduleInPast) {
+		t.Errorf("Expected ErrScheduleInPast, got %v", err)
+	}
+	o.Status = StatusPending
+	if err := o.Reschedule(now.Add(time.Hour), now); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("Expected ErrNotScheduled, got %v", err)
+	}
+	if err := o.Cancel(); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("Expected ErrNotScheduled, got %v", err)
+	}
+}
+
+func TestConfirmReservation(t *testing.T) {
+	now := time.Now()
+	o := &Order{Status: StatusReserved, ReservedUntil: now.Add(-time.Second)}
+	if err := o.ConfirmReservation(now); !errors.Is(err, ErrReservationExpired) || o.Status != StatusReserved {
+		t.Errorf("Expected ErrReservationExpired and a RESERVED order, got %v, %s", err, o.Status)
+	}
+	o.ReservedUntil = now.Add(time.Minute)
+	if err := o.ConfirmReservation(now); err != nil || o.Status != StatusPending {
+		t.Errorf("Expected a PENDING order, got %v, %s", err, o.Status)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	codes := map[string]Discount{"TEN": {Percent: 10, Amount: 5}, "ALL": {Amount: 1000}}
+	tests := []struct {
+		name  string
+		rules PricingRules
+		calc  Calculation
+		want  Pricing
+	}{
+		{
+			name:  "tax on subtotal, shipping untaxed",
+			rules: PricingRules{ShippingFee: 5, TaxRate: 0.1},
+			calc:  Calculation{UnitPrice: 50, Quantity: 2},
+			want:  Pricing{Subtotal: 100, Tax: 10, ShippingFee: 5, Total: 115},
+		},
+		{
+			name:  "percent then amount",
+			rules: PricingRules{TaxRate: 0.1, DiscountCodes: codes},
+			calc:  Calculation{UnitPrice: 100, Quantity: 1, DiscountCode: "ten"},
+			want:  Pricing{Subtotal: 100, Discount: 15, Tax: 8.5, Total: 93.5},
+		},
+		{
+			name:  "discount capped at subtotal",
+			rules: PricingRules{ShippingFee: 3, TaxRate: 0.1, DiscountCodes: codes},
+			calc:  Calculation{UnitPrice: 20, Quantity: 1, DiscountCode: "ALL"},
+			want:  Pricing{Subtotal: 20, Discount: 20, ShippingFee: 3, Total: 3},
+		},
+		{
+			name:  "bulk discount on top of code",
+			rules: PricingRules{DiscountCodes: codes, BulkDiscounts: []BulkDiscount{{MinQuantity: 10, Percent: 5}}},
+			calc:  Calculation{UnitPrice: 10, Quantity: 10, DiscountCode: "TEN"},
+			want:  Pricing{Subtotal: 100, Discount: 20, Total: 80},
+		},
+		{
+			name:  "bulk discount below minimum",
+			rules: PricingRules{BulkDiscounts: []BulkDiscount{{MinQuantity: 10, Percent: 5}}},
+			calc:  Calculation{UnitPrice: 10, Quantity: 9},
+			want:  Pricing{Subtotal: 90, Total: 90},
+		},
+		{
+			name:  "free shipping over threshold",
+			rules: PricingRules{ShippingFee: 5, FreeShippingOver: 50},
+			calc:  Calculation{UnitPrice: 50, Quantity: 1},
+			want:  Pricing{Subtotal: 50, Total: 50},
+		},
+		{
+			name: "adjusted before rounding",
+			rules: PricingRules{ShippingFee: 5, Adjust: []PricingStep{StepFunc(func(c *Calculation) error {
+				c.Subtotal *= 0.999
+				c.Variant = "cheaper"
+				return nil
+			})}},
+			calc: Calculation{UnitPrice: 10, Quantity: 1, Round: round},
+			want: Pricing{Subtotal: 9.99, ShippingFee: 5, Total: 14.99},
+		},
+		{
+			name:  "cart line shares shipping and fixed discount",
+			rules: PricingRules{ShippingFee: 10, DiscountCodes: codes},
+			calc:  Calculation{UnitPrice: 25, Quantity: 1, DiscountCode: "TEN", Round: round, Cart: &CartLine{Subtotals: []float64{75, 25}, Index: 1}},
+			want:  Pricing{Subtotal: 25, Discount: 3.75, ShippingFee: 2.5, Total: 23.75},
+		},
+		{
+			name:  "cart free shipping by cart subtotal",
+			rules: PricingRules{ShippingFee: 5, FreeShippingOver: 50},
+			calc:  Calculation{UnitPrice: 10, Quantity: 1, Cart: &CartLine{Subtotals: []float64{10, 40}}},
+			want:  Pricing{Subtotal: 10, Total: 10},
+		},
+		{
+			name:  "rounded",
+			rules: PricingRules{TaxRate: 0.11},
+			calc:  Calculation{UnitPrice: 9.999, Quantity: 1, Round: round},
+			want:  Pricing{Subtotal: 10, Tax: 1.1, Total: 11.1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.calc
+			if err := NewPipeline(tt.rules).Price(&c); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if c.Pricing != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, c.Pricing)
+			}
+		})
+	}
+
+	c := Calculation{UnitPrice: 10, Quantity: 1, DiscountCode: "NOPE"}
+	if err := NewPipeline(PricingRules{DiscountCodes: codes}).Price(&c); !errors.Is(err, ErrUnknownDiscountCode) {
+		t.Errorf("Expected ErrUnknownDiscountCode, got %v", err)
+	}
+}
+
+func TestCartLineShares(t *testing.T) {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	subtotals := []float64{10, 10, 10}
+	var shipping, discount float64
+	for i := range subtotals {
+		c := Calculation{UnitPrice: 10, Quantity: 1, DiscountCode: "OFF", Round: round, Cart: &CartLine{Subtotals: subtotals, Index: i}}
+		rules := PricingRules{ShippingFee: 10, DiscountCodes: map[string]Discount{"OFF": {Amount: 1}}}
+		if err := NewPipeline(rules).Price(&c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		shipping += c.ShippingFee
+		discount += c.Discount
+	}
+	if round(shipping) != 10 || round(discount) != 1 {
+		t.Errorf("Expected the lines to add up to the cart's shipping 10 and discount 1, got %v and %v", shipping, discount)
+	}
+}
+
+func TestCheckStock(t *testing.T) {
+	tests := []struct {
+		name               string
+		available, request int
+		allowed            bool
+		backorder          bool
+		err                error
+	}{
+		{"in stock", 5, 5, false, false, nil},
+		{"short, no backorders", 4, 5, false, false, ErrInsufficientStock},
+		{"short, backorder", 4, 5, true, true, nil},
+		{"in stock with backorders allowed", 5, 1, true, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backorder, err := CheckStock(tt.available, tt.request, tt.allowed)
+			if backorder != tt.backorder || !errors.Is(err, tt.err) {
+				t.Errorf("CheckStock(%d, %d, %v) = %v, %v, want %v, %v", tt.available, tt.request, tt.allowed, backorder, err, tt.backorder, tt.err)
+			}
+		})
+	}
+}
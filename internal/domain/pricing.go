@@ -0,0 +1,267 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// Discount is a discount code definition. Percent is applied first, then
+// the fixed Amount.
+type Discount struct {
+	Percent float64 `json:"percent,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+}
+
+// Pricing is the price breakdown of an order.
+type Pricing struct {
+	Subtotal    float64
+	Discount    float64
+	Tax         float64
+	ShippingFee float64
+	Total       float64
+}
+
+// Calculation is an order price being worked out by a PricingEngine.
+// Steps read the order fields and build up the embedded Pricing.
+type Calculation struct {
+	TenantID   string
+	CustomerID string
+	ProductID  string
+	Quantity   int
+	UnitPrice  float64
+	// DiscountCode is the code as given; DiscountCodes replaces it with the
+	// code it matched.
+	DiscountCode string
+	// Round rounds money in the order's currency; nil leaves amounts
+	// unrounded.
+	Round func(float64) float64
+	// Experiment and Variant are set by a step that prices the order as
+	// part of an experiment.
+	Experiment string
+	Variant    string
+	// Cart is set when the order is one line of a cart.
+	Cart *CartLine
+	Pricing
+}
+
+func (c *Calculation) round(v float64) float64 {
+	if c.Round == nil {
+		return v
+	}
+	return c.Round(v)
+}
+
+// CartLine is a calculation's place in a cart whose lines are priced one
+// by one. The shipping fee and a discount code's fixed Amount are charged
+// once per cart and split across the lines in proportion to Subtotals,
+// the lines' unit prices times quantities.
+type CartLine struct {
+	Subtotals []float64
+	Index     int
+}
+
+func (l *CartLine) subtotal() float64 {
+	var total float64
+	for _, s := range l.Subtotals {
+		total += s
+	}
+	return total
+}
+
+// share is the line's part of amount. Every part but the last is rounded
+// and the last line takes the rest, so the parts add up to amount.
+func (c *Calculation) share(amount float64) float64 {
+	l := c.Cart
+	if l == nil || len(l.Subtotals) < 2 {
+		return amount
+	}
+	total := l.subtotal()
+	part := func(i int) float64 {
+		if total <= 0 {
+			return c.round(amount / float64(len(l.Subtotals)))
+		}
+		return c.round(amount * l.Subtotals[i] / total)
+	}
+	if l.Index < len(l.Subtotals)-1 {
+		return part(l.Index)
+	}
+	rest := amount
+	for i := 0; i < len(l.Subtotals)-1; i++ {
+		rest -= part(i)
+	}
+	return c.round(rest)
+}
+
+// PricingEngine computes the Pricing of a Calculation.
+type PricingEngine interface {
+	Price(c *Calculation) error
+}
+
+// PricingStep is one stage of a Pipeline.
+type PricingStep interface {
+	Apply(c *Calculation) error
+}
+
+// StepFunc adapts a function to PricingStep.
+type StepFunc func(c *Calculation) error
+
+func (f StepFunc) Apply(c *Calculation) error { return f(c) }
+
+// Pipeline is a PricingEngine that runs its steps in order.
+type Pipeline []PricingStep
+
+func (p Pipeline) Price(c *Calculation) error {
+	for _, step := range p {
+		if err := step.Apply(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BasePrice sets the subtotal to unit price times quantity.
+func BasePrice() PricingStep {
+	return StepFunc(func(c *Calculation) error {
+		c.Subtotal = c.UnitPrice * float64(c.Quantity)
+		return nil
+	})
+}
+
+// Shipping charges a flat shipping fee, once per cart.
+func Shipping(fee float64) PricingStep {
+	return StepFunc(func(c *Calculation) error {
+		c.ShippingFee = c.share(fee)
+		return nil
+	})
+}
+
+// RoundAmounts rounds the subtotal and shipping fee. Steps after it round
+// what they compute themselves.
+func RoundAmounts() PricingStep {
+	return StepFunc(func(c *Calculation) error {
+		c.Subtotal = c.round(c.Subtotal)
+		c.ShippingFee = c.round(c.ShippingFee)
+		return nil
+	})
+}
+
+// ErrUnknownDiscountCode is returned for a discount code that is not
+// defined.
+var ErrUnknownDiscountCode = errors.New("unknown discount code")
+
+// DiscountCodes applies the discount of the order's code. Codes are matched
+// case-insensitively; the keys of codes must be upper case. The fixed
+// Amount is taken off a cart once.
+func DiscountCodes(codes map[string]Discount) PricingStep {
+	return StepFunc(func(c *Calculation) error {
+		if c.DiscountCode == "" {
+			return nil
+		}
+		code := strings.ToUpper(c.DiscountCode)
+		d, ok := codes[code]
+		if !ok {
+			return ErrUnknownDiscountCode
+		}
+		c.DiscountCode = code
+		c.addDiscount(c.Subtotal*d.Percent/100 + c.share(d.Amount))
+		return nil
+	})
+}
+
+// BulkDiscount takes Percent off the subtotal of orders of at least
+// MinQuantity units, on top of any discount code.
+type BulkDiscount struct {
+	MinQuantity int     `json:"minQuantity"`
+	Percent     float64 `json:"percent"`
+}
+
+func (b BulkDiscount) Apply(c *Calculation) error {
+	if c.Quantity >= b.MinQuantity {
+		c.addDiscount(c.Subtotal * b.Percent / 100)
+	}
+	return nil
+}
+
+// addDiscount adds to the discount, which never exceeds the subtotal.
+func (c *Calculation) addDiscount(amount float64) {
+	c.Discount = c.round(math.Min(c.Discount+amount, c.Subtotal))
+}
+
+// FreeShippingOver waives shipping when the discounted subtotal reaches
+// threshold. For a cart line it is the cart's subtotal before discounts,
+// which every line agrees on.
+func FreeShippingOver(threshold float64) PricingStep {
+	return StepFunc(func(c *Calculation) error {
+		subtotal := c.Subtotal - c.Discount
+		if c.Cart != nil {
+			subtotal = c.Cart.subtotal()
+		}
+		if subtotal >= threshold {
+			c.ShippingFee = 0
+		}
+		return nil
+	})
+}
+
+// Tax charges rate on the discounted subtotal; shipping is not taxed.
+func Tax(rate float64) PricingStep {
+	return StepFunc(func(c *Calculation) error {
+		c.Tax = c.round((c.Subtotal - c.Discount) * rate)
+		return nil
+	})
+}
+
+// Total adds up the breakdown.
+func Total() PricingStep {
+	return StepFunc(func(c *Calculation) error {
+		c.Total = c.round(c.Subtotal - c.Discount + c.Tax + c.ShippingFee)
+		return nil
+	})
+}
+
+// PricingRules configure NewPipeline.
+type PricingRules struct {
+	ShippingFee   float64
+	TaxRate       float64
+	DiscountCodes map[string]Discount
+	BulkDiscounts []BulkDiscount
+	// FreeShippingOver waives shipping from this discounted subtotal on;
+	// zero never waives it.
+	FreeShippingOver float64
+	// Adjust runs on the unrounded subtotal and shipping fee, e.g. to price
+	// an experiment variant.
+	Adjust []PricingStep
+}
+
+// NewPipeline returns the standard pipeline: base price and shipping,
+// adjustments, rounding, discount code, bulk discounts, free shipping, tax
+// and total.
+func NewPipeline(r PricingRules) Pipeline {
+	p := Pipeline{BasePrice(), Shipping(r.ShippingFee)}
+	p = append(p, r.Adjust...)
+	p = append(p, RoundAmounts(), DiscountCodes(r.DiscountCodes))
+	for _, b := range r.BulkDiscounts {
+		p = append(p, b)
+	}
+	if r.FreeShippingOver > 0 {
+		p = append(p, FreeShippingOver(r.FreeShippingOver))
+	}
+	return append(p, Tax(r.TaxRate), Total())
+}
+
+// ErrInsufficientStock is returned when an order does not fit the stock
+// and may not be backordered.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// CheckStock reports whether requested units must be backordered given
+// the units available.
+func CheckStock(available, requested int, backorderAllowed bool) (backorder bool, err error) {
+	if requested <= available {
+		return false, nil
+	}
+	if !backorderAllowed {
+		return false, ErrInsufficientStock
+	}
+	return true, nil
+}
@@ -0,0 +1,164 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+// pricingCase is a random but valid order to price: prices, fees and
+// discounts are never negative and rates stay within their ranges.
+type pricingCase struct {
+	Rules PricingRules
+	Calc  Calculation
+}
+
+func (pricingCase) Generate(r *rand.Rand, _ int) reflect.Value {
+	money := func(max float64) float64 { return math.Round(r.Float64()*max*1000) / 1000 }
+	codes := map[string]Discount{
+		"PERCENT": {Percent: money(100)},
+		"AMOUNT":  {Amount: money(1000)},
+		"BOTH":    {Percent: money(100), Amount: money(1000)},
+	}
+	c := pricingCase{
+		Rules: PricingRules{
+			ShippingFee:   money(50),
+			TaxRate:       money(0.3),
+			DiscountCodes: codes,
+		},
+		Calc: Calculation{
+			UnitPrice: money(500),
+			Quantity:  1 + r.Intn(200),
+			Round:     func(v float64) float64 { return math.Round(v*100) / 100 },
+		},
+	}
+	if r.Intn(2) == 0 {
+		c.Rules.BulkDiscounts = []BulkDiscount{{MinQuantity: 1 + r.Intn(100), Percent: money(50)}}
+	}
+	if r.Intn(3) == 0 {
+		c.Rules.FreeShippingOver = money(5000)
+	}
+	if r.Intn(2) == 0 {
+		names := []string{"PERCENT", "AMOUNT", "BOTH"}
+		c.Calc.DiscountCode = names[r.Intn(len(names))]
+	}
+	return reflect.ValueOf(c)
+}
+
+func TestPricingProperties(t *testing.T) {
+	property := func(pc pricingCase) bool {
+		c := pc.Calc
+		if err := NewPipeline(pc.Rules).Price(&c); err != nil {
+			t.Logf("Expected no error, got %v", err)
+			return false
+		}
+		p := c.Pricing
+		if p.Total < 0 || p.Subtotal < 0 || p.Discount < 0 || p.Tax < 0 || p.ShippingFee < 0 {
+			t.Logf("Expected no negative amounts, got %+v", p)
+			return false
+		}
+		if p.Discount > p.Subtotal {
+			t.Logf("Expected discount within subtotal, got %+v", p)
+			return false
+		}
+		if sum := p.Subtotal - p.Discount + p.Tax + p.ShippingFee; math.Abs(sum-p.Total) > 0.001 {
+			t.Logf("Expected total %v to add up to %v, got %+v", p.Total, sum, p)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 5000}); err != nil {
+		t.Error(err)
+	}
+}
+
+// operation is one thing that can happen to an order.
+type operation struct {
+	name  string
+	apply func(o *Order, now time.Time) error
+}
+
+var operations = []operation{
+	{"Backorder", func(o *Order, _ time.Time) error { return o.Backorder() }},
+	{"Hold", func(o *Order, _ time.Time) error { return o.Hold("review") }},
+	{"Approve", func(o *Order, _ time.Time) error { return o.Approve() }},
+	{"Reject", func(o *Order, _ time.Time) error { return o.Reject("refused") }},
+	{"AwaitPayment", func(o *Order, now time.Time) error { return o.AwaitPayment(now.Add(time.Minute)) }},
+	{"ConfirmPayment", func(o *Order, now time.Time) error {
+		if err := o.CanConfirmPayment(now); err != nil {
+			return err
+		}
+		return o.ConfirmPayment()
+	}},
+	{"ExpirePayment", func(o *Order, _ time.Time) error { return o.ExpirePayment() }},
+	{"FulfilBackorder", func(o *Order, _ time.Time) error { return o.FulfilBackorder() }},
+	{"Validate", func(o *Order, _ time.Time) error { return o.Validate(Pricing{Total: 10}) }},
+	{"Reschedule", func(o *Order, now time.Time) error { return o.Reschedule(now.Add(time.Hour), now) }},
+	{"Activate", func(o *Order, _ time.Time) error { return o.Activate(Pricing{Total: 10}) }},
+	{"Cancel", func(o *Order, _ time.Time) error { return o.Cancel() }},
+	{"Reserve", func(o *Order, now time.Time) error { return o.Reserve(now.Add(time.Minute)) }},
+	{"ConfirmReservation", func(o *Order, now time.Time) error { return o.ConfirmReservation(now) }},
+	{"ExpireReservation", func(o *Order, _ time.Time) error { return o.ExpireReservation() }},
+}
+
+// startStatuses are the statuses an order can be created in.
+var startStatuses = []string{StatusPending, StatusPendingValidation, StatusScheduled}
+
+func TestTransitionProperties(t *testing.T) {
+	known := map[string]bool{StatusPaid: true, StatusCancelled: true}
+	for from, tos := range transitions {
+		known[from] = true
+		for _, to := range tos {
+			known[to] = true
+			if to == from {
+				t.Errorf("Expected no transition from %s to itself", from)
+			}
+		}
+	}
+
+	// steps are operation indexes and the minutes that pass before each.
+	property := func(start uint8, steps []uint16) bool {
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		o := &Order{ID: "o1", Quantity: 1, Status: startStatuses[int(start)%len(startStatuses)]}
+		for _, step := range steps {
+			op := operations[int(step)%len(operations)]
+			now = now.Add(time.Duration(step/uint16(len(operations))%3) * time.Minute)
+			from := o.Status
+			err := op.apply(o, now)
+			switch {
+			case err != nil:
+				if o.Status != from {
+					t.Logf("%s failed but moved the order from %s to %s", op.name, from, o.Status)
+					return false
+				}
+				if !errors.Is(err, ErrInvalidTransition) && !errors.Is(err, ErrNotScheduled) &&
+					!errors.Is(err, ErrPaymentExpired) && !errors.Is(err, ErrReservationExpired) {
+					t.Logf("%s from %s returned unexpected error %v", op.name, from, err)
+					return false
+				}
+			case o.Status != from && !CanTransition(from, o.Status):
+				t.Logf("%s moved the order from %s to %s", op.name, from, o.Status)
+				return false
+			case o.Status == from && op.name != "Reschedule":
+				t.Logf("%s succeeded without moving the order from %s", op.name, from)
+				return false
+			}
+			if !known[o.Status] {
+				t.Logf("%s moved the order to unknown status %s", op.name, o.Status)
+				return false
+			}
+			if _, ok := transitions[from]; !ok && o.Status != from {
+				t.Logf("%s moved the order out of final status %s", op.name, from)
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 5000}); err != nil {
+		t.Error(err)
+	}
+}
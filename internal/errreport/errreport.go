@@ -0,0 +1,275 @@
+// Package errreport sends errors and recovered panics to an error
+// tracker. Handlers, consumers and jobs hand their panics to Recovered,
+// which logs the stack and reports it under an incident ID that is also
+// shown to the caller, so a support request can be matched with the
+// report. Errors worth tracking that are handled anyway, such as a failed
+// upstream call, go through Capture with their class.
+package errreport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"order-service/internal/logging"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/google/uuid"
+)
+
+// Classes of reports.
+const (
+	// ClassPanic: a recovered panic.
+	ClassPanic = "panic"
+	// ClassUpstream: a call to another service, such as product-service
+	// or the payment provider, failed.
+	ClassUpstream = "upstream"
+	// ClassPublish: an event could not be published or spooled to the
+	// outbox, so it is lost.
+	ClassPublish = "publish"
+	// ClassPoison: a consumer dropped or quarantined a message it could
+	// not handle.
+	ClassPoison = "poison"
+)
+
+// Classes are all classes of reports.
+var Classes = []string{ClassPanic, ClassUpstream, ClassPublish, ClassPoison}
+
+// Report is one error or recovered panic.
+type Report struct {
+	IncidentID string
+	Err        error
+	Class      string
+	Panic      bool
+	// Stack is the stack of a recovered panic.
+	Stack []byte
+	// Source is the subsystem it happened in: "http", "consumer", "job",
+	// "event" or "goroutine".
+	Source string
+	// Tags identify where in the source it happened, e.g. the route or
+	// the queue, and what it happened to, e.g. the order. The trace of the
+	// context is added to them.
+	Tags map[string]string
+}
+
+// fingerprintTags are the tags that, with the class and source, group
+// reports into one issue. The error message is left out as it usually
+// carries IDs.
+var fingerprintTags = map[string][]string{
+	ClassUpstream: {"upstream"},
+	ClassPublish:  {"pattern"},
+	ClassPoison:   {"queue", "reason"},
+}
+
+// Fingerprint groups r with the reports of the same problem. Panics are
+// grouped by their stack, which the tracker does by default, so their
+// fingerprint is nil.
+func Fingerprint(r Report) []string {
+	keys, ok := fingerprintTags[r.Class]
+	if !ok {
+		return nil
+	}
+	fp := []string{r.Class, r.Source}
+	for _, k := range keys {
+		fp = append(fp, r.Tags[k])
+	}
+	return fp
+}
+
+// Reporter sends reports to an error tracker. Report must not block the
+// caller for long.
+type Reporter interface {
+	Report(ctx context.Context, r Report)
+}
+
+// Nop drops every report, for deployments without an error tracker.
+type Nop struct{}
+
+func (Nop) Report(context.Context, Report) {}
+
+// PanicError is the error of a recovered panic.
+type PanicError struct {
+	Value any
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recovered logs a value recovered from a panic with its stack and reports
+// it under a new incident ID. Call it from the deferred function that
+// recovered, so the stack still shows where the panic happened. reporter
+// may be nil.
+func Recovered(ctx context.Context, reporter Reporter, v any, source string, tags map[string]string) Report {
+	r := newReport(ctx, &PanicError{Value: v}, source, ClassPanic, tags)
+	r.Panic = true
+	r.Stack = debug.Stack()
+	attrs := []slog.Attr{
+		slog.String("incident_id", r.IncidentID),
+		slog.String("source", source),
+		slog.Any("error", r.Err),
+		slog.String("stack", string(r.Stack)),
+	}
+	for _, k := range slices.Sorted(maps.Keys(r.Tags)) {
+		attrs = append(attrs, slog.String(k, r.Tags[k]))
+	}
+	slog.Default().LogAttrs(ctx, slog.LevelError, "panic recovered", attrs...)
+	if reporter != nil {
+		reporter.Report(ctx, r)
+	}
+	return r
+}
+
+// Capture reports err, which the caller has already logged, as a class of
+// error under a new incident ID. reporter may be nil.
+func Capture(ctx context.Context, reporter Reporter, err error, source, class string, tags map[string]string) Report {
+	r := newReport(ctx, err, source, class, tags)
+	if reporter != nil {
+		reporter.Report(ctx, r)
+	}
+	return r
+}
+
+// newReport adds the string fields of the context's log entries, such as
+// trace_id, to tags.
+func newReport(ctx context.Context, err error, source, class string, tags map[string]string) Report {
+	r := Report{
+		IncidentID: uuid.New().String(),
+		Err:        err,
+		Class:      class,
+		Source:     source,
+		Tags:       tags,
+	}
+	if fields := logging.Fields(ctx); len(fields) > 0 {
+		r.Tags = maps.Clone(tags)
+		if r.Tags == nil {
+			r.Tags = make(map[string]string, len(fields))
+		}
+		for _, f := range fields {
+			if _, ok := r.Tags[f.Key]; !ok && f.Value.Kind() == slog.KindString {
+				r.Tags[f.Key] = f.Value.String()
+			}
+		}
+	}
+	return r
+}
+
+// Filter passes on the reports of the enabled classes. Classes can be
+// changed while reports are sent.
+type Filter struct {
+	next    Reporter
+	enabled atomic.Pointer[map[string]bool]
+}
+
+// NewFilter passes on the reports of the given classes to next.
+func NewFilter(next Reporter, classes []string) *Filter {
+	f := &Filter{next: next}
+	f.SetClasses(classes)
+	return f
+}
+
+// SetClasses replaces the enabled classes.
+func (f *Filter) SetClasses(classes []string) {
+	enabled := make(map[string]bool, len(classes))
+	for _, c := range classes {
+		enabled[c] = true
+	}
+	f.enabled.Store(&enabled)
+}
+
+func (f *Filter) Report(ctx context.Context, r Report) {
+	if (*f.enabled.Load())[r.Class] {
+		f.next.Report(ctx, r)
+	}
+}
+
+// Flush waits up to timeout for the reports queued by the reporter, if it
+// queues them like Sentry does.
+func (f *Filter) Flush(timeout time.Duration) bool {
+	if fl, ok := f.next.(interface{ Flush(time.Duration) bool }); ok {
+		return fl.Flush(timeout)
+	}
+	return true
+}
+
+// ParseClasses reads a comma-separated list of classes. Empty means all
+// classes and "none" none.
+func ParseClasses(v string) ([]string, error) {
+	v = strings.TrimSpace(v)
+	switch v {
+	case "":
+		return slices.Clone(Classes), nil
+	case "none":
+		return []string{}, nil
+	}
+	var classes []string
+	for _, c := range strings.Split(v, ",") {
+		classes = append(classes, strings.TrimSpace(c))
+	}
+	return classes, ValidateClasses(classes)
+}
+
+// ValidateClasses rejects unknown classes.
+func ValidateClasses(classes []string) error {
+	for _, c := range classes {
+		if !slices.Contains(Classes, c) {
+			return fmt.Errorf("unknown error report class %q", c)
+		}
+	}
+	return nil
+}
+
+// Sentry reports to Sentry.
+type Sentry struct {
+	hub *sentry.Hub
+}
+
+// NewSentry reports to the project of dsn. environment and release are
+// attached to every event and may be empty.
+func NewSentry(dsn, environment, release string) (*Sentry, error) {
+	return newSentry(sentry.ClientOptions{Dsn: dsn, Environment: environment, Release: release})
+}
+
+func newSentry(opts sentry.ClientOptions) (*Sentry, error) {
+	client, err := sentry.NewClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry configuration: %w", err)
+	}
+	return &Sentry{hub: sentry.NewHub(client, sentry.NewScope())}, nil
+}
+
+func (s *Sentry) Report(ctx context.Context, r Report) {
+	s.hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("source", r.Source)
+		scope.SetTag("class", r.Class)
+		scope.SetTag("incident_id", r.IncidentID)
+		scope.SetTags(r.Tags)
+		if fp := Fingerprint(r); fp != nil {
+			scope.SetFingerprint(fp)
+		}
+		level := sentry.LevelError
+		if r.Panic {
+			level = sentry.LevelFatal
+		}
+		scope.SetLevel(level)
+		s.hub.CaptureException(r.Err)
+	})
+}
+
+// Flush waits up to timeout for queued reports to be sent and reports
+// whether they were.
+func (s *Sentry) Flush(timeout time.Duration) bool {
+	return s.hub.Flush(timeout)
+}
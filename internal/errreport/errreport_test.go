@@ -0,0 +1,155 @@
+package errreport
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"order-service/internal/logging"
+
+	"github.com/getsentry/sentry-go"
+)
+
+type fakeReporter struct {
+	reports []Report
+}
+
+func (f *fakeReporter) Report(_ context.Context, r Report) {
+	f.reports = append(f.reports, r)
+}
+
+func TestRecovered(t *testing.T) {
+	reporter := &fakeReporter{}
+	cause := errors.New("nil map")
+	var r Report
+	func() {
+		defer func() {
+			r = Recovered(context.Background(), reporter, recover(), "consumer", map[string]string{"queue": "stock"})
+		}()
+		panic(cause)
+	}()
+
+	if r.IncidentID == "" || !r.Panic || r.Source != "consumer" || len(r.Stack) == 0 {
+		t.Errorf("Expected a panic report with an incident ID and a stack, got %+v", r)
+	}
+	if !errors.Is(r.Err, cause) || r.Err.Error() != "panic: nil map" {
+		t.Errorf("Expected the error to wrap the panic value, got %v", r.Err)
+	}
+	if len(reporter.reports) != 1 || reporter.reports[0].IncidentID != r.IncidentID {
+		t.Errorf("Expected the report to be sent, got %+v", reporter.reports)
+	}
+
+	// A nil reporter only logs.
+	Recovered(context.Background(), nil, "boom", "job", nil)
+}
+
+func TestSentry(t *testing.T) {
+	transport := &sentry.MockTransport{}
+	s, err := newSentry(sentry.ClientOptions{Dsn: "https://key@sentry.example.com/1", Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Report(context.Background(), Report{
+		IncidentID: "i1",
+		Err:        &PanicError{Value: "boom"},
+		Panic:      true,
+		Source:     "http",
+		Tags:       map[string]string{"route": "/orders/:id"},
+	})
+	s.Flush(0)
+
+	events := transport.Events()
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events))
+	}
+	ev := events[0]
+	if len(ev.Fingerprint) != 0 {
+		t.Errorf("Expected panics to be grouped by stack, got fingerprint %v", ev.Fingerprint)
+	}
+	if ev.Level != sentry.LevelFatal || ev.Tags["incident_id"] != "i1" || ev.Tags["source"] != "http" || ev.Tags["route"] != "/orders/:id" {
+		t.Errorf("Expected a fatal event tagged with the incident, got level %s and tags %v", ev.Level, ev.Tags)
+	}
+
+	s.Report(context.Background(), Report{
+		Err:    errors.New("connection refused"),
+		Class:  ClassUpstream,
+		Source: "service",
+		Tags:   map[string]string{"upstream": "payment"},
+	})
+	events = transport.Events()
+	if len(events) != 2 || !slices.Equal(events[1].Fingerprint, []string{"upstream", "service", "payment"}) {
+		t.Errorf("Expected the upstream report to be fingerprinted by upstream, got %d events", len(events))
+	}
+
+	if _, err := NewSentry("not a dsn", "", ""); err == nil {
+		t.Error("Expected an invalid DSN to be rejected")
+	}
+}
+
+func TestCapture(t *testing.T) {
+	reporter := &fakeReporter{}
+	ctx := logging.WithFields(context.Background(), slog.String("trace_id", "t1"), slog.Int("attempt", 2))
+	tags := map[string]string{"upstream": "product-service"}
+
+	r := Capture(ctx, reporter, errors.New("connection refused"), "service", ClassUpstream, tags)
+	if r.Tags["trace_id"] != "t1" || r.Tags["upstream"] != "product-service" {
+		t.Errorf("Expected the trace to be added to the tags, got %v", r.Tags)
+	}
+	if _, ok := r.Tags["attempt"]; ok {
+		t.Errorf("Expected only string fields to become tags, got %v", r.Tags)
+	}
+	if len(tags) != 1 {
+		t.Errorf("Expected the caller's tags to be left alone, got %v", tags)
+	}
+	if len(reporter.reports) != 1 || reporter.reports[0].Class != ClassUpstream {
+		t.Errorf("Expected an upstream report, got %+v", reporter.reports)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	for _, tt := range []struct {
+		r    Report
+		want []string
+	}{
+		{Report{Class: ClassPanic, Source: "http"}, nil},
+		{Report{Class: ClassUpstream, Source: "service", Tags: map[string]string{"upstream": "payment", "order_id": "o1"}}, []string{"upstream", "service", "payment"}},
+		{Report{Class: ClassPublish, Source: "service", Tags: map[string]string{"pattern": "order.created"}}, []string{"publish", "service", "order.created"}},
+		{Report{Class: ClassPoison, Source: "consumer", Tags: map[string]string{"queue": "stock", "reason": "schema", "message_id": "m1"}}, []string{"poison", "consumer", "stock", "schema"}},
+	} {
+		if got := Fingerprint(tt.r); !slices.Equal(got, tt.want) {
+			t.Errorf("Fingerprint(%s) = %v, want %v", tt.r.Class, got, tt.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	next := &fakeReporter{}
+	f := NewFilter(next, []string{ClassPanic})
+	f.Report(context.Background(), Report{Class: ClassPanic})
+	f.Report(context.Background(), Report{Class: ClassUpstream})
+	if len(next.reports) != 1 {
+		t.Errorf("Expected only the panic to pass, got %+v", next.reports)
+	}
+
+	f.SetClasses(nil)
+	f.Report(context.Background(), Report{Class: ClassPanic})
+	if len(next.reports) != 1 {
+		t.Errorf("Expected nothing to pass with no classes, got %+v", next.reports)
+	}
+
+	if classes, err := ParseClasses(""); err != nil || !slices.Equal(classes, Classes) {
+		t.Errorf("Expected all classes by default, got %v, %v", classes, err)
+	}
+	if classes, err := ParseClasses("none"); err != nil || len(classes) != 0 {
+		t.Errorf("Expected no classes, got %v, %v", classes, err)
+	}
+	if classes, err := ParseClasses("panic, poison"); err != nil || !slices.Equal(classes, []string{ClassPanic, ClassPoison}) {
+		t.Errorf("Expected panic and poison, got %v, %v", classes, err)
+	}
+	if _, err := ParseClasses("panic,timeouts"); err == nil {
+		t.Error("Expected an unknown class to be rejected")
+	}
+}
@@ -0,0 +1,105 @@
+// Package eventorder numbers the events of each order, so consumers can
+// tell when events of one order arrive out of order: through the outbox,
+// through different queues, or from different instances. Publishers
+// number events with a Sequencer; the number travels with the event as
+// Sequenced data and ends up in the envelope. Consumers keep the newest
+// number they handled per key in a Tracker and skip older events.
+package eventorder
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Sequenced is event data numbered within its key, usually an order ID.
+// It encodes as the data alone, so schemas, payloads and event IDs do not
+// change; the envelope carries the key and sequence.
+type Sequenced struct {
+	Key      string
+	Sequence int64
+	Data     interface{}
+}
+
+func (s Sequenced) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Data)
+}
+
+// Unwrap returns the key, sequence and data of event data. Data that is
+// not Sequenced has no key.
+func Unwrap(data interface{}) (key string, sequence int64, inner interface{}) {
+	if s, ok := data.(Sequenced); ok {
+		return s.Key, s.Sequence, s.Data
+	}
+	return "", 0, data
+}
+
+// Sequencer hands out increasing numbers per key from Redis, shared by
+// every instance. A key idle for longer than ttl starts over at 1.
+type Sequencer struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewSequencer(client *redis.Client, ttl time.Duration) *Sequencer {
+	return &Sequencer{client: client, ttl: ttl}
+}
+
+// Next returns the next number of key.
+func (s *Sequencer) Next(ctx context.Context, key string) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, "eventorder:seq:"+key)
+		pipe.Expire(ctx, "eventorder:seq:"+key, s.ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// advanceScript raises the stored sequence of a key to ARGV[1] and
+// refreshes its TTL.
+var advanceScript = redis.NewScript(`
+local last = tonumber(redis.call("GET", KEYS[1]) or "0")
+if tonumber(ARGV[1]) > last then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+else
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Tracker remembers, per key, the newest sequence a consumer handled.
+// Instances consuming the same events should share a prefix.
+type Tracker struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewTracker(client *redis.Client, prefix string, ttl time.Duration) *Tracker {
+	return &Tracker{client: client, prefix: prefix, ttl: ttl}
+}
+
+func (t *Tracker) redisKey(key string) string {
+	return "eventorder:" + t.prefix + ":" + key
+}
+
+// Last returns the newest sequence handled for key, or 0.
+func (t *Tracker) Last(ctx context.Context, key string) (int64, error) {
+	n, err := t.client.Get(ctx, t.redisKey(key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Advance records that sequence was handled for key. Older sequences do
+// not lower the one recorded.
+func (t *Tracker) Advance(ctx context.Context, key string, sequence int64) error {
+	return advanceScript.Run(ctx, t.client, []string{t.redisKey(key)}, sequence, t.ttl.Milliseconds()).Err()
+}
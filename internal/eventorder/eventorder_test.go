@@ -0,0 +1,27 @@
+package eventorder
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSequenced(t *testing.T) {
+	data := map[string]interface{}{"orderId": "o1"}
+	s := Sequenced{Key: "o1", Sequence: 3, Data: data}
+
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(encoded) != `{"orderId":"o1"}` {
+		t.Errorf("Expected the data alone, got %s", encoded)
+	}
+
+	key, sequence, inner := Unwrap(s)
+	if key != "o1" || sequence != 3 || inner.(map[string]interface{})["orderId"] != "o1" {
+		t.Errorf("Unexpected unwrapped %q, %d, %v", key, sequence, inner)
+	}
+	if key, sequence, _ := Unwrap(data); key != "" || sequence != 0 {
+		t.Errorf("Expected plain data to have no number, got %q, %d", key, sequence)
+	}
+}
@@ -0,0 +1,109 @@
+// Package events is an in-process bus for domain events. OrderService emits
+// what happened to an order and the modules that follow orders (the search
+// projection, the sales leaderboard) subscribe to it, so adding one does
+// not touch the order flow. Modules whose records must not be lost, such
+// as the analytics export, use repository hooks instead.
+//
+// These events never leave the process; integration events for other
+// services still go through the publisher.
+package events
+
+import (
+	"context"
+	"log"
+	"order-service/internal/errreport"
+	"order-service/internal/metrics"
+	"order-service/internal/repository"
+	"sync"
+	"time"
+)
+
+// Domain events.
+const (
+	// OrderCreated: a new order was stored.
+	OrderCreated = "order.created"
+	// OrderUpdated: a stored order changed.
+	OrderUpdated = "order.updated"
+	// OrderValidated: an order accepted while product-service was down
+	// was validated and accepted.
+	OrderValidated = "order.validated"
+	// OrderActivated: a scheduled order was priced and accepted.
+	OrderActivated = "order.activated"
+	// OrderPlaced: order.created was published for an order, so it takes
+	// stock and counts as sold.
+	OrderPlaced = "order.placed"
+)
+
+// Event is something that happened to an order. Order is a copy taken when
+// the event was emitted.
+type Event struct {
+	Name       string
+	Order      repository.Order
+	OccurredAt time.Time
+}
+
+// Handler reacts to an event.
+type Handler func(ctx context.Context, ev Event) error
+
+type subscription struct {
+	subscriber string
+	handle     Handler
+}
+
+// Bus delivers events to their subscribers synchronously, in the order they
+// subscribed. A failing subscriber is logged and does not stop the others
+// or the emitter.
+type Bus struct {
+	mu       sync.RWMutex
+	subs     map[string][]subscription
+	reporter errreport.Reporter
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: map[string][]subscription{}}
+}
+
+// SetReporter reports subscribers that panic to r.
+func (b *Bus) SetReporter(r errreport.Reporter) {
+	b.reporter = r
+}
+
+// Subscribe registers handle, under the subscriber's name, for the given
+// events.
+func (b *Bus) Subscribe(subscriber string, handle Handler, names ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, name := range names {
+		b.subs[name] = append(b.subs[name], subscription{subscriber: subscriber, handle: handle})
+	}
+}
+
+// Emit delivers an event about order to every subscriber of name.
+func (b *Bus) Emit(ctx context.Context, name string, order *repository.Order) {
+	b.mu.RLock()
+	subs := b.subs[name]
+	b.mu.RUnlock()
+	if len(subs) == 0 {
+		return
+	}
+
+	ev := Event{Name: name, Order: *order, OccurredAt: time.Now().UTC()}
+	for _, sub := range subs {
+		if err := b.deliver(ctx, sub, ev); err != nil {
+			metrics.DomainEventFailures.WithLabelValues(name, sub.subscriber).Inc()
+			log.Printf("Subscriber %s failed to handle %s for order %s: %v", sub.subscriber, name, order.ID, err)
+		}
+	}
+}
+
+func (b *Bus) deliver(ctx context.Context, sub subscription, ev Event) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = errreport.Recovered(ctx, b.reporter, v, "event", map[string]string{
+				"event":      ev.Name,
+				"subscriber": sub.subscriber,
+			}).Err
+		}
+	}()
+	return sub.handle(ctx, ev)
+}
@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"order-service/internal/repository"
+	"reflect"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	var got []string
+	record := func(subscriber string) Handler {
+		return func(_ context.Context, ev Event) error {
+			got = append(got, subscriber+":"+ev.Name+":"+ev.Order.ID)
+			return nil
+		}
+	}
+	bus.Subscribe("first", record("first"), OrderCreated, OrderUpdated)
+	bus.Subscribe("failing", func(context.Context, Event) error { return errors.New("down") }, OrderCreated)
+	bus.Subscribe("panicking", func(context.Context, Event) error { panic("boom") }, OrderCreated)
+	bus.Subscribe("last", record("last"), OrderCreated)
+
+	order := &repository.Order{ID: "o1"}
+	bus.Emit(context.Background(), OrderCreated, order)
+	bus.Emit(context.Background(), OrderUpdated, order)
+	bus.Emit(context.Background(), OrderPlaced, order)
+
+	want := []string{"first:order.created:o1", "last:order.created:o1", "first:order.updated:o1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected deliveries %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,106 @@
+package experiment
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// Pricing holds the order amounts an experiment strategy may adjust.
+type Pricing struct {
+	Subtotal    float64
+	ShippingFee float64
+}
+
+// Strategy applies the treatment of a variant to the pricing of an order.
+type Strategy interface {
+	Adjust(variant string, p *Pricing)
+}
+
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+	// ShippingFee, when set, replaces the default shipping fee for this
+	// variant. Used by ShippingFeeStrategy.
+	ShippingFee *float64 `json:"shippingFee,omitempty"`
+}
+
+type Experiment struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+	Strategy Strategy  `json:"-"`
+}
+
+// Parse reads an experiment definition from JSON. Its strategy defaults to
+// overriding the shipping fee per variant.
+func Parse(data string) (*Experiment, error) {
+	var e Experiment
+	if err := json.Unmarshal([]byte(data), &e); err != nil {
+		return nil, fmt.Errorf("failed to parse experiment: %w", err)
+	}
+	if e.Name == "" || len(e.Variants) == 0 {
+		return nil, errors.New("experiment needs a name and at least one variant")
+	}
+	total := 0
+	for _, v := range e.Variants {
+		if v.Weight < 0 {
+			return nil, fmt.Errorf("variant %s has a negative weight", v.Name)
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return nil, errors.New("experiment variants have no weight")
+	}
+	e.Strategy = NewShippingFeeStrategy(e.Variants)
+	return &e, nil
+}
+
+// Assign picks a variant for the customer. The same customer always lands
+// in the same variant for a given experiment.
+func (e *Experiment) Assign(customerID string) string {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Weight
+	}
+	h := fnv.New32a()
+	h.Write([]byte(e.Name + ":" + customerID))
+	point := int(h.Sum32() % uint32(total))
+	for _, v := range e.Variants {
+		if point < v.Weight {
+			return v.Name
+		}
+		point -= v.Weight
+	}
+	return e.Variants[len(e.Variants)-1].Name
+}
+
+func (e *Experiment) Apply(variant string, p *Pricing) {
+	if e.Strategy != nil {
+		e.Strategy.Adjust(variant, p)
+	}
+}
+
+// ShippingFeeStrategy sets a fixed shipping fee per variant. Variants
+// without a fee keep the default.
+type ShippingFeeStrategy struct {
+	fees map[string]float64
+}
+
+var _ Strategy = &ShippingFeeStrategy{}
+
+func NewShippingFeeStrategy(variants []Variant) *ShippingFeeStrategy {
+	fees := map[string]float64{}
+	for _, v := range variants {
+		if v.ShippingFee != nil {
+			fees[v.Name] = *v.ShippingFee
+		}
+	}
+	return &ShippingFeeStrategy{fees: fees}
+}
+
+func (s *ShippingFeeStrategy) Adjust(variant string, p *Pricing) {
+	if fee, ok := s.fees[variant]; ok {
+		p.ShippingFee = fee
+	}
+}
@@ -0,0 +1,59 @@
+package experiment
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAssignIsDeterministic(t *testing.T) {
+	e, err := Parse(`{"name":"free-shipping","variants":[
+		{"name":"control","weight":50,"shippingFee":5},
+		{"name":"free","weight":50,"shippingFee":0}]}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		customer := fmt.Sprintf("customer-%d", i)
+		v := e.Assign(customer)
+		if v != e.Assign(customer) {
+			t.Fatalf("Expected %s to keep its variant", customer)
+		}
+		counts[v]++
+	}
+	if counts["control"] < 400 || counts["free"] < 400 {
+		t.Errorf("Expected a roughly even split, got %v", counts)
+	}
+}
+
+func TestShippingFeeStrategy(t *testing.T) {
+	e, err := Parse(`{"name":"exp","variants":[{"name":"a","weight":1,"shippingFee":0},{"name":"b","weight":1}]}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	p := Pricing{Subtotal: 100, ShippingFee: 7}
+	e.Apply("a", &p)
+	if p.ShippingFee != 0 {
+		t.Errorf("Expected variant a to waive shipping, got %f", p.ShippingFee)
+	}
+
+	p = Pricing{Subtotal: 100, ShippingFee: 7}
+	e.Apply("b", &p)
+	if p.ShippingFee != 7 {
+		t.Errorf("Expected variant b to keep the default fee, got %f", p.ShippingFee)
+	}
+}
+
+func TestParseRejectsInvalidExperiments(t *testing.T) {
+	for _, def := range []string{
+		`{"variants":[{"name":"a","weight":1}]}`,
+		`{"name":"x","variants":[]}`,
+		`{"name":"x","variants":[{"name":"a","weight":0}]}`,
+	} {
+		if _, err := Parse(def); err == nil {
+			t.Errorf("Expected %s to be rejected", def)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package featureflags
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"slices"
+
+	"order-service/internal/tenant"
+)
+
+// Flag names used across the service.
+const (
+	OpenSearchSearch = "opensearch-search"
+	ProductShadow    = "product-shadow"
+)
+
+// EvalContext carries the attributes a flag can be targeted on.
+type EvalContext struct {
+	TenantID string
+	UserID   string
+}
+
+type Provider interface {
+	IsEnabled(ctx context.Context, flag string, ec EvalContext) (bool, error)
+}
+
+// Rule is the flag definition shared by the env, file and Redis providers.
+// Tenants restricts the flag to the listed tenants, Rollout (1-99) enables
+// it for a stable percentage of users or tenants; 0 means everyone.
+type Rule struct {
+	Enabled bool     `json:"enabled"`
+	Tenants []string `json:"tenants,omitempty"`
+	Rollout int      `json:"rollout,omitempty"`
+}
+
+func (r Rule) evaluate(flag string, ec EvalContext) bool {
+	if !r.Enabled {
+		return false
+	}
+	if len(r.Tenants) > 0 && !slices.Contains(r.Tenants, ec.TenantID) {
+		return false
+	}
+	if r.Rollout > 0 && r.Rollout < 100 {
+		key := ec.UserID
+		if key == "" {
+			key = ec.TenantID
+		}
+		return bucket(flag, key) < r.Rollout
+	}
+	return true
+}
+
+// bucket maps a flag/key pair to 0-99 so a given key keeps its variant as
+// the rollout percentage grows.
+func bucket(flag, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag + ":" + key))
+	return int(h.Sum32() % 100)
+}
+
+// Client evaluates flags against the tenant of the request context. A nil
+// Client reports every flag as disabled.
+type Client struct {
+	provider Provider
+}
+
+func New(provider Provider) *Client {
+	return &Client{provider: provider}
+}
+
+// SetRules replaces the rules of a provider that holds them locally. It
+// reports false for providers backed by Redis or Unleash, whose rules are
+// managed there.
+func (c *Client) SetRules(rules map[string]Rule) bool {
+	if c == nil {
+		return false
+	}
+	p, ok := c.provider.(interface{ SetRules(map[string]Rule) })
+	if ok {
+		p.SetRules(rules)
+	}
+	return ok
+}
+
+func (c *Client) Enabled(ctx context.Context, flag string) bool {
+	return c.EnabledFor(ctx, flag, EvalContext{TenantID: tenant.FromContext(ctx)})
+}
+
+// EnabledFor evaluates flag for an explicit context. Provider errors are
+// logged and treated as disabled.
+func (c *Client) EnabledFor(ctx context.Context, flag string, ec EvalContext) bool {
+	if c == nil || c.provider == nil {
+		return false
+	}
+	on, err := c.provider.IsEnabled(ctx, flag, ec)
+	if err != nil {
+		log.Printf("Feature flag %s evaluation failed: %v", flag, err)
+		return false
+	}
+	return on
+}
@@ -0,0 +1,66 @@
+package featureflags
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStaticProvider(t *testing.T) {
+	rules, err := ParseEnv("opensearch-search=true, disabled=false")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	rules["tenant-only"] = Rule{Enabled: true, Tenants: []string{"acme"}}
+	client := New(NewStaticProvider(rules))
+	ctx := context.Background()
+
+	if !client.EnabledFor(ctx, "opensearch-search", EvalContext{}) {
+		t.Error("Expected opensearch-search to be enabled")
+	}
+	if client.EnabledFor(ctx, "disabled", EvalContext{}) {
+		t.Error("Expected disabled flag to be off")
+	}
+	if client.EnabledFor(ctx, "unknown", EvalContext{}) {
+		t.Error("Expected unknown flag to be off")
+	}
+	if !client.EnabledFor(ctx, "tenant-only", EvalContext{TenantID: "acme"}) {
+		t.Error("Expected tenant-only to be enabled for acme")
+	}
+	if client.EnabledFor(ctx, "tenant-only", EvalContext{TenantID: "other"}) {
+		t.Error("Expected tenant-only to be disabled for other tenants")
+	}
+}
+
+func TestRolloutIsStable(t *testing.T) {
+	rule := Rule{Enabled: true, Rollout: 30}
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		ec := EvalContext{UserID: string(rune('a'+i%26)) + string(rune(i))}
+		first := rule.evaluate("flag", ec)
+		if first != rule.evaluate("flag", ec) {
+			t.Fatal("Expected rollout to be deterministic per user")
+		}
+		if first {
+			enabled++
+		}
+	}
+	if enabled < 200 || enabled > 400 {
+		t.Errorf("Expected roughly 30%% of users enabled, got %d/1000", enabled)
+	}
+}
+
+func TestNilClient(t *testing.T) {
+	var client *Client
+	if client.Enabled(context.Background(), "anything") {
+		t.Error("Expected nil client to report flags as disabled")
+	}
+}
+
+func TestParseEnvRejectsInvalidValues(t *testing.T) {
+	if _, err := ParseEnv("flag=maybe"); err == nil {
+		t.Error("Expected an error for a non-boolean value")
+	}
+	if _, err := ParseEnv("flag"); err == nil {
+		t.Error("Expected an error for a missing value")
+	}
+}
@@ -0,0 +1,70 @@
+package featureflags
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const redisFlagsKey = "featureflags"
+
+// RedisProvider reads rules from the "featureflags" hash (field = flag name,
+// value = JSON Rule), caching them locally for ttl so flags can be flipped
+// at runtime without a Redis round trip per evaluation.
+type RedisProvider struct {
+	client *redis.Client
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	rules    map[string]Rule
+	loadedAt time.Time
+}
+
+var _ Provider = &RedisProvider{}
+
+func NewRedisProvider(client *redis.Client, ttl time.Duration) *RedisProvider {
+	return &RedisProvider{client: client, ttl: ttl}
+}
+
+func (p *RedisProvider) IsEnabled(ctx context.Context, flag string, ec EvalContext) (bool, error) {
+	rules, err := p.load(ctx)
+	if err != nil {
+		return false, err
+	}
+	rule, ok := rules[flag]
+	if !ok {
+		return false, nil
+	}
+	return rule.evaluate(flag, ec), nil
+}
+
+func (p *RedisProvider) load(ctx context.Context) (map[string]Rule, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rules != nil && time.Since(p.loadedAt) < p.ttl {
+		return p.rules, nil
+	}
+
+	raw, err := p.client.HGetAll(ctx, redisFlagsKey).Result()
+	if err != nil {
+		if p.rules != nil {
+			// Keep serving the last known state while Redis is unavailable.
+			return p.rules, nil
+		}
+		return nil, err
+	}
+
+	rules := make(map[string]Rule, len(raw))
+	for name, v := range raw {
+		var rule Rule
+		if err := json.Unmarshal([]byte(v), &rule); err != nil {
+			continue
+		}
+		rules[name] = rule
+	}
+	p.rules, p.loadedAt = rules, time.Now()
+	return rules, nil
+}
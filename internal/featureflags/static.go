@@ -0,0 +1,75 @@
+package featureflags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// StaticProvider serves flags loaded at startup or set by a configuration
+// reload.
+type StaticProvider struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+}
+
+var _ Provider = &StaticProvider{}
+
+func NewStaticProvider(rules map[string]Rule) *StaticProvider {
+	return &StaticProvider{rules: rules}
+}
+
+// ParseEnv reads a comma separated list of name=bool pairs, e.g.
+// "opensearch-search=true,other=false".
+func ParseEnv(v string) (map[string]Rule, error) {
+	rules := map[string]Rule{}
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid feature flag %q, expected name=bool", pair)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for feature flag %s: %w", name, err)
+		}
+		rules[strings.TrimSpace(name)] = Rule{Enabled: enabled}
+	}
+	return rules, nil
+}
+
+// LoadFile reads a JSON object mapping flag names to rules.
+func LoadFile(path string) (map[string]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := map[string]Rule{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse feature flag file: %w", err)
+	}
+	return rules, nil
+}
+
+func (p *StaticProvider) SetRules(rules map[string]Rule) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = rules
+}
+
+func (p *StaticProvider) IsEnabled(ctx context.Context, flag string, ec EvalContext) (bool, error) {
+	p.mu.RLock()
+	rule, ok := p.rules[flag]
+	p.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	return rule.evaluate(flag, ec), nil
+}
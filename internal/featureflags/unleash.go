@@ -0,0 +1,156 @@
+package featureflags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnleashProvider polls the Unleash client API (or any compatible server
+// such as Unleash Edge or GitLab feature flags) and evaluates the default,
+// userWithId and flexibleRollout strategies locally.
+type UnleashProvider struct {
+	url        string
+	token      string
+	appName    string
+	httpClient *http.Client
+
+	mu       sync.RWMutex
+	features map[string]unleashFeature
+}
+
+var _ Provider = &UnleashProvider{}
+
+type unleashFeature struct {
+	Name       string            `json:"name"`
+	Enabled    bool              `json:"enabled"`
+	Strategies []unleashStrategy `json:"strategies"`
+}
+
+type unleashStrategy struct {
+	Name       string            `json:"name"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+func NewUnleashProvider(url, token, appName string) *UnleashProvider {
+	return &UnleashProvider{
+		url:        strings.TrimRight(url, "/"),
+		token:      token,
+		appName:    appName,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+		features:   map[string]unleashFeature{},
+	}
+}
+
+// Run refreshes the feature set every interval until ctx is cancelled.
+func (p *UnleashProvider) Run(ctx context.Context, interval time.Duration) {
+	if err := p.Refresh(ctx); err != nil {
+		log.Printf("Failed to fetch Unleash features: %v", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Refresh(ctx); err != nil {
+				log.Printf("Failed to fetch Unleash features: %v", err)
+			}
+		}
+	}
+}
+
+func (p *UnleashProvider) Refresh(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"/api/client/features", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", p.token)
+	req.Header.Set("UNLEASH-APPNAME", p.appName)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unleash returned status: %s", resp.Status)
+	}
+
+	var body struct {
+		Features []unleashFeature `json:"features"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode unleash features: %w", err)
+	}
+
+	features := make(map[string]unleashFeature, len(body.Features))
+	for _, f := range body.Features {
+		features[f.Name] = f
+	}
+	p.mu.Lock()
+	p.features = features
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *UnleashProvider) IsEnabled(ctx context.Context, flag string, ec EvalContext) (bool, error) {
+	p.mu.RLock()
+	f, ok := p.features[flag]
+	p.mu.RUnlock()
+	if !ok || !f.Enabled {
+		return false, nil
+	}
+	if len(f.Strategies) == 0 {
+		return true, nil
+	}
+	for _, s := range f.Strategies {
+		if s.matches(flag, ec) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s unleashStrategy) matches(flag string, ec EvalContext) bool {
+	switch s.Name {
+	case "default":
+		return true
+	case "userWithId":
+		return ec.UserID != "" && slices.Contains(splitList(s.Parameters["userIds"]), ec.UserID)
+	case "tenantWithId":
+		return ec.TenantID != "" && slices.Contains(splitList(s.Parameters["tenantIds"]), ec.TenantID)
+	case "flexibleRollout":
+		rollout, err := strconv.Atoi(s.Parameters["rollout"])
+		if err != nil {
+			return false
+		}
+		key := ec.UserID
+		if s.Parameters["stickiness"] == "tenantId" || key == "" {
+			key = ec.TenantID
+		}
+		group := s.Parameters["groupId"]
+		if group == "" {
+			group = flag
+		}
+		return bucket(group, key) < rollout
+	default:
+		return false
+	}
+}
+
+func splitList(v string) []string {
+	parts := strings.Split(v, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
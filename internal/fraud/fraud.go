@@ -0,0 +1,67 @@
+package fraud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"order-service/internal/repository"
+)
+
+// Verdict is the outcome of screening an order.
+type Verdict struct {
+	Suspicious bool    `json:"suspicious"`
+	Score      float64 `json:"score"`
+	Reason     string  `json:"reason"`
+}
+
+// HTTPChecker screens orders with an external fraud scoring service via
+// POST {baseURL}/check.
+type HTTPChecker struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewHTTPChecker(baseURL string) *HTTPChecker {
+	return &HTTPChecker{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{},
+	}
+}
+
+func (c *HTTPChecker) Check(ctx context.Context, order *repository.Order) (Verdict, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"orderId":    order.ID,
+		"customerId": order.CustomerID,
+		"productId":  order.ProductID,
+		"quantity":   order.Quantity,
+		"totalPrice": order.TotalPrice,
+	})
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/check", bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("failed to call fraud service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("fraud service returned status: %s", resp.Status)
+	}
+
+	var v Verdict
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return Verdict{}, fmt.Errorf("failed to decode fraud verdict: %w", err)
+	}
+	return v, nil
+}
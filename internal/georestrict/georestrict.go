@@ -0,0 +1,270 @@
+// Package georestrict limits where orders may be shipped. A rule lists the
+// countries, and optionally the regions, a product category or a tenant
+// ships to; an order must satisfy every rule that applies to it.
+package georestrict
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rule scopes.
+const (
+	ScopeCategory = "category"
+	ScopeTenant   = "tenant"
+)
+
+// Reasons an order is refused.
+const (
+	// ReasonAddressRequired: the order has no country, or no region while
+	// the rule lists regions.
+	ReasonAddressRequired = "address_required"
+	ReasonCountry         = "country_not_allowed"
+	ReasonRegion          = "region_not_allowed"
+)
+
+var (
+	ErrInvalidRule  = errors.New("invalid geo restriction")
+	ErrRuleNotFound = errors.New("geo restriction not found")
+	ErrDuplicate    = errors.New("geo restriction already exists")
+)
+
+// Rule only lets orders of Target, a product category or a tenant ID, ship
+// to Countries, ISO 3166-1 alpha-2 codes. With Regions set the shipping
+// region must be one of them too.
+type Rule struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Scope     string    `gorm:"not null;uniqueIndex:idx_geo_restrictions_scope_target" json:"scope"`
+	Target    string    `gorm:"not null;uniqueIndex:idx_geo_restrictions_scope_target" json:"target"`
+	Countries []string  `gorm:"type:jsonb;serializer:json;not null" json:"countries"`
+	Regions   []string  `gorm:"type:jsonb;serializer:json" json:"regions,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (Rule) TableName() string { return "geo_restrictions" }
+
+// normalize trims the rule's values and upper-cases its countries.
+func (r *Rule) normalize() {
+	r.Scope = strings.TrimSpace(r.Scope)
+	r.Target = strings.TrimSpace(r.Target)
+	for i, c := range r.Countries {
+		r.Countries[i] = strings.ToUpper(strings.TrimSpace(c))
+	}
+	for i, region := range r.Regions {
+		r.Regions[i] = strings.TrimSpace(region)
+	}
+}
+
+func (r Rule) validate() error {
+	if r.Scope != ScopeCategory && r.Scope != ScopeTenant {
+		return fmt.Errorf("%w: unknown scope %q", ErrInvalidRule, r.Scope)
+	}
+	if r.Target == "" {
+		return fmt.Errorf("%w: target is required", ErrInvalidRule)
+	}
+	if len(r.Countries) == 0 {
+		return fmt.Errorf("%w: at least one country is required", ErrInvalidRule)
+	}
+	for _, c := range r.Countries {
+		if !isCountryCode(c) {
+			return fmt.Errorf("%w: %q is not an ISO 3166-1 alpha-2 country code", ErrInvalidRule, c)
+		}
+	}
+	if slices.Contains(r.Regions, "") {
+		return fmt.Errorf("%w: regions cannot be empty", ErrInvalidRule)
+	}
+	return nil
+}
+
+func isCountryCode(c string) bool {
+	return len(c) == 2 && c[0] >= 'A' && c[0] <= 'Z' && c[1] >= 'A' && c[1] <= 'Z'
+}
+
+// Violation is why an order may not be shipped where it asks to be.
+type Violation struct {
+	Reason string
+	Rule   Rule
+}
+
+func (v *Violation) Error() string {
+	target := v.Rule.Scope + " " + v.Rule.Target
+	var msg string
+	switch v.Reason {
+	case ReasonAddressRequired:
+		msg = fmt.Sprintf("%s only ships to listed countries and regions, the shipping address is incomplete", target)
+	case ReasonRegion:
+		msg = fmt.Sprintf("%s only ships to regions %s", target, strings.Join(v.Rule.Regions, ", "))
+	default:
+		msg = fmt.Sprintf("%s only ships to %s", target, strings.Join(v.Rule.Countries, ", "))
+	}
+	if v.Rule.Reason != "" {
+		msg += ": " + v.Rule.Reason
+	}
+	return msg
+}
+
+// Order is what the rules look at.
+type Order struct {
+	TenantID string
+	Category string
+	Country  string
+	Region   string
+}
+
+// Store keeps rules in Postgres and checks orders against a copy held in
+// memory. Changes made through the store apply at once; changes made by
+// other instances once Load runs again.
+type Store struct {
+	db     *gorm.DB
+	logger *slog.Logger
+
+	mu    sync.RWMutex
+	rules map[string]Rule
+}
+
+func NewStore(db *gorm.DB, logger *slog.Logger) *Store {
+	return &Store{db: db, logger: logger, rules: map[string]Rule{}}
+}
+
+func (s *Store) List(ctx context.Context) ([]Rule, error) {
+	var rules []Rule
+	err := s.db.WithContext(ctx).Order("scope, target").Find(&rules).Error
+	return rules, err
+}
+
+// Load replaces the rules held in memory with the stored ones.
+func (s *Store) Load(ctx context.Context) error {
+	rules, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]Rule, len(rules))
+	for _, r := range rules {
+		byKey[key(r.Scope, r.Target)] = r
+	}
+	s.mu.Lock()
+	s.rules = byKey
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Store) Add(ctx context.Context, actor string, r *Rule) error {
+	r.normalize()
+	if err := r.validate(); err != nil {
+		return err
+	}
+	r.ID = 0
+	r.CreatedBy = actor
+	if err := s.db.WithContext(ctx).Create(r).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	s.set(*r)
+	s.logger.Info("audit", "actor", actor, "action", "geo_restriction.add",
+		"scope", r.Scope, "target", r.Target, "countries", r.Countries, "regions", r.Regions)
+	return nil
+}
+
+// Update replaces the countries, regions and reason of the rule id and
+// returns the rule as it was before. Scope and target cannot be changed.
+func (s *Store) Update(ctx context.Context, actor string, id uint, r *Rule) (*Rule, error) {
+	var before Rule
+	err := s.db.WithContext(ctx).First(&before, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRuleNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	updated := before
+	updated.Countries, updated.Regions, updated.Reason = r.Countries, r.Regions, r.Reason
+	updated.normalize()
+	if err := updated.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(&updated).Error; err != nil {
+		return nil, err
+	}
+	*r = updated
+	s.set(updated)
+	s.logger.Info("audit", "actor", actor, "action", "geo_restriction.update",
+		"scope", r.Scope, "target", r.Target, "countries", r.Countries, "regions", r.Regions)
+	return &before, nil
+}
+
+func (s *Store) Remove(ctx context.Context, actor string, id uint) (*Rule, error) {
+	var r Rule
+	err := s.db.WithContext(ctx).First(&r, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRuleNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Delete(&r).Error; err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	delete(s.rules, key(r.Scope, r.Target))
+	s.mu.Unlock()
+	s.logger.Info("audit", "actor", actor, "action", "geo_restriction.remove",
+		"scope", r.Scope, "target", r.Target)
+	return &r, nil
+}
+
+func (s *Store) set(r Rule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[key(r.Scope, r.Target)] = r
+}
+
+// Check returns why the order may not be shipped to its address, or nil.
+// The category rule is checked before the tenant rule.
+func (s *Store) Check(o Order) *Violation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range []string{key(ScopeCategory, o.Category), key(ScopeTenant, o.TenantID)} {
+		r, ok := s.rules[k]
+		if !ok {
+			continue
+		}
+		if v := r.check(o); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func (r Rule) check(o Order) *Violation {
+	country := strings.ToUpper(strings.TrimSpace(o.Country))
+	region := strings.TrimSpace(o.Region)
+	switch {
+	case country == "" || (len(r.Regions) > 0 && region == ""):
+		return &Violation{Reason: ReasonAddressRequired, Rule: r}
+	case !slices.Contains(r.Countries, country):
+		return &Violation{Reason: ReasonCountry, Rule: r}
+	case len(r.Regions) > 0 && !slices.ContainsFunc(r.Regions, func(s string) bool { return strings.EqualFold(s, region) }):
+		return &Violation{Reason: ReasonRegion, Rule: r}
+	}
+	return nil
+}
+
+// key is empty for a rule without a target, so orders without a category
+// or tenant never match one.
+func key(scope, target string) string {
+	if target == "" {
+		return ""
+	}
+	return scope + ":" + target
+}
@@ -0,0 +1,75 @@
+package georestrict
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{"category", Rule{Scope: ScopeCategory, Target: "alcohol", Countries: []string{" id ", "sg"}}, true},
+		{"tenant with regions", Rule{Scope: ScopeTenant, Target: "acme", Countries: []string{"ID"}, Regions: []string{"ID-JK"}}, true},
+		{"unknown scope", Rule{Scope: "product", Target: "p1", Countries: []string{"ID"}}, false},
+		{"no target", Rule{Scope: ScopeCategory, Countries: []string{"ID"}}, false},
+		{"no countries", Rule{Scope: ScopeCategory, Target: "alcohol"}, false},
+		{"country name", Rule{Scope: ScopeCategory, Target: "alcohol", Countries: []string{"Indonesia"}}, false},
+		{"empty region", Rule{Scope: ScopeCategory, Target: "alcohol", Countries: []string{"ID"}, Regions: []string{" "}}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.rule.normalize()
+			err := tc.rule.validate()
+			if tc.valid && err != nil {
+				t.Errorf("Expected the rule to be valid, got %v", err)
+			}
+			if !tc.valid && !errors.Is(err, ErrInvalidRule) {
+				t.Errorf("Expected ErrInvalidRule, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s := &Store{rules: map[string]Rule{}}
+	for _, r := range []Rule{
+		{Scope: ScopeCategory, Target: "alcohol", Countries: []string{"ID", "SG"}},
+		{Scope: ScopeTenant, Target: "acme", Countries: []string{"ID"}, Regions: []string{"ID-JK", "ID-JB"}},
+	} {
+		s.set(r)
+	}
+
+	cases := []struct {
+		name  string
+		order Order
+		want  string
+		scope string
+	}{
+		{"unrestricted", Order{Category: "books"}, "", ""},
+		{"allowed country", Order{Category: "alcohol", Country: "sg"}, "", ""},
+		{"no country", Order{Category: "alcohol"}, ReasonAddressRequired, ScopeCategory},
+		{"other country", Order{Category: "alcohol", Country: "US"}, ReasonCountry, ScopeCategory},
+		{"tenant region", Order{TenantID: "acme", Country: "ID", Region: "id-jk"}, "", ""},
+		{"tenant without region", Order{TenantID: "acme", Country: "ID"}, ReasonAddressRequired, ScopeTenant},
+		{"tenant other region", Order{TenantID: "acme", Country: "ID", Region: "ID-BA"}, ReasonRegion, ScopeTenant},
+		{"category passes, tenant fails", Order{TenantID: "acme", Category: "alcohol", Country: "SG", Region: "SG-01"}, ReasonCountry, ScopeTenant},
+		{"both fail", Order{TenantID: "acme", Category: "alcohol", Country: "US"}, ReasonCountry, ScopeCategory},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := s.Check(tc.order)
+			if tc.want == "" {
+				if v != nil {
+					t.Errorf("Expected no violation, got %v", v)
+				}
+				return
+			}
+			if v == nil || v.Reason != tc.want || v.Rule.Scope != tc.scope {
+				t.Errorf("Expected %s by the %s rule, got %+v", tc.want, tc.scope, v)
+			}
+		})
+	}
+}
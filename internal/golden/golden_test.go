@@ -0,0 +1,21 @@
+package golden
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	want := "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3,\n  \"d\": 4,\n  \"e\": 5,\n  \"f\": 6\n}\n"
+	got := "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 30,\n  \"d\": 4,\n  \"e\": 5,\n  \"f\": 6\n}\n"
+	diff := Diff(want, got)
+	expected := "    \"a\": 1,\n    \"b\": 2,\n-   \"c\": 3,\n+   \"c\": 30,\n    \"d\": 4,\n    \"e\": 5,\n"
+	if diff != expected {
+		t.Errorf("Expected diff\n%s\ngot\n%s", expected, diff)
+	}
+	if diff := Diff(want, want); diff != "" {
+		t.Errorf("Expected no diff for equal input, got\n%s", diff)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	JSON(t, "example", map[string]interface{}{"orderId": "order-1", "quantity": 2, "note": "<a&b>"})
+	JSON(t, "example", []byte(`{"note":"<a&b>","orderId":"order-1","quantity":2}`))
+}
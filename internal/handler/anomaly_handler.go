@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+	"order-service/internal/anomaly"
+	"order-service/internal/timezone"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnomalyHandler struct {
+	detector *anomaly.Detector
+}
+
+func NewAnomalyHandler(detector *anomaly.Detector) *AnomalyHandler {
+	return &AnomalyHandler{detector: detector}
+}
+
+// List answers GET /admin/anomalies with the products whose order rate is
+// flagged now.
+func (h *AnomalyHandler) List(c *gin.Context) {
+	anomalies, err := h.detector.Active(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	loc := timezone.FromContext(c.Request.Context())
+	for i := range anomalies {
+		anomalies[i].DetectedAt = anomalies[i].DetectedAt.In(loc)
+		anomalies[i].Until = anomalies[i].Until.In(loc)
+	}
+	c.JSON(http.StatusOK, anomalies)
+}
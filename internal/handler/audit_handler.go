@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/middleware"
+	"order-service/internal/timezone"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// IAuditLog records admin mutations.
+type IAuditLog interface {
+	Record(ctx context.Context, e *audit.Entry) error
+}
+
+// recordAudit stores an audit entry for the current admin request. A
+// failure to record is logged but does not undo the action.
+func recordAudit(c *gin.Context, auditLog IAuditLog, action, target string, before, after interface{}) {
+	recordEntry(c, auditLog, &audit.Entry{Action: action, Target: target, Before: before, After: after})
+}
+
+// recordEntry records e with the actor and IP of the request.
+func recordEntry(c *gin.Context, auditLog IAuditLog, e *audit.Entry) {
+	if auditLog == nil {
+		return
+	}
+	e.Actor = middleware.Actor(c)
+	e.IP = c.ClientIP()
+	if err := auditLog.Record(c.Request.Context(), e); err != nil {
+		log.Printf("Failed to record audit entry %s %s by %s: %v", e.Action, e.Target, e.Actor, err)
+	}
+}
+
+type AuditHandler struct {
+	store *audit.Store
+}
+
+func NewAuditHandler(store *audit.Store) *AuditHandler {
+	return &AuditHandler{store: store}
+}
+
+// List answers GET /admin/audit, filtered by actor, action, target and a
+// from/to range.
+func (h *AuditHandler) List(c *gin.Context) {
+	filter := audit.Filter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		Target: c.Query("target"),
+	}
+	loc := timezone.FromContext(c.Request.Context())
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from", loc, false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.To, err = parseTimeQuery(c, "to", loc, true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAuditLimit)))
+	if err != nil || limit <= 0 || limit > maxAuditLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	entries, err := h.store.List(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	for i := range entries {
+		entries[i].CreatedAt = entries[i].CreatedAt.In(loc)
+	}
+	c.JSON(http.StatusOK, entries)
+}
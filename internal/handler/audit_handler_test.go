@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/middleware"
+	"order-service/internal/timezone"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func newAuditRouter(t *testing.T, db *gorm.DB) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	zones, err := timezone.NewResolver("UTC", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	router := gin.New()
+	router.Use(timezone.Middleware(zones))
+	router.GET("/admin/audit", middleware.AdminAuth(testAdminToken), NewAuditHandler(audit.NewStore(db)).List)
+	return router
+}
+
+func TestAuditListRejectsBadParameters(t *testing.T) {
+	db, mock := mockDB(t)
+	router := newAuditRouter(t, db)
+
+	for _, query := range []string{
+		"limit=0",
+		"limit=501",
+		"limit=ten",
+		"offset=-1",
+		"offset=first",
+		"from=yesterday",
+		"to=2026-13-01",
+	} {
+		if w := serve(router, http.MethodGet, "/admin/audit?"+query, "", adminHeader); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", query, w.Code)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditList(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Skip(err)
+	}
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("passes filters and page to the store", func(t *testing.T) {
+		db, mock := mockDB(t)
+		router := newAuditRouter(t, db)
+		mock.ExpectQuery(quoted(`SELECT * FROM "admin_audit" WHERE actor = $1 AND action = $2 AND target = $3 AND created_at >= $4 AND created_at <= $5 ORDER BY created_at DESC, id DESC LIMIT $6 OFFSET $7`)).
+			WithArgs("alice", audit.ActionOrderApprove, "order-1",
+				time.Date(2026, 10, 1, 0, 0, 0, 0, jakarta).UTC(),
+				time.Date(2026, 10, 3, 0, 0, 0, 0, jakarta).UTC(),
+				10, 30).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "actor", "action", "target", "created_at"}).
+				AddRow(1, "alice", audit.ActionOrderApprove, "order-1", created))
+
+		w := serve(router, http.MethodGet,
+			"/admin/audit?actor=alice&action=order.approve&target=order-1&from=2026-10-01&to=2026-10-02&limit=10&offset=30&tz=Asia/Jakarta", "", adminHeader)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+		}
+		var entries []struct {
+			ID        uint   `json:"id"`
+			CreatedAt string `json:"createdAt"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+			t.Fatalf("Expected a JSON array, got %s", w.Body)
+		}
+		if len(entries) != 1 || entries[0].CreatedAt != "2026-10-01T19:00:00+07:00" {
+			t.Errorf("Expected the entry in the requested timezone, got %+v", entries)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("defaults to the first 50 and an empty array", func(t *testing.T) {
+		db, mock := mockDB(t)
+		router := newAuditRouter(t, db)
+		mock.ExpectQuery(quoted(`SELECT * FROM "admin_audit" ORDER BY created_at DESC, id DESC LIMIT $1`)).
+			WithArgs(defaultAuditLimit).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		w := serve(router, http.MethodGet, "/admin/audit", "", adminHeader)
+		if w.Code != http.StatusOK || w.Body.String() != "[]" {
+			t.Errorf("Expected 200 with [], got %d: %s", w.Code, w.Body)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("requires the admin token", func(t *testing.T) {
+		db, _ := mockDB(t)
+		if w := serve(newAuditRouter(t, db), http.MethodGet, "/admin/audit", "", nil); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/internal/billing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBillingDays caps the period of a billing reconciliation; every period
+// in it is recounted from the orders.
+const maxBillingDays = 31
+
+type BillingHandler struct {
+	meter *billing.Meter
+}
+
+func NewBillingHandler(meter *billing.Meter) *BillingHandler {
+	return &BillingHandler{meter: meter}
+}
+
+// Reconciliation answers GET /admin/billing/reconciliation with the usage
+// published for the periods between the UTC days from and to next to a
+// recount of the orders, optionally for ?tenantId=. The period defaults to
+// yesterday and today; mismatched=true leaves out the tenants that match.
+func (h *BillingHandler) Reconciliation(c *gin.Context) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -1), today
+	for key, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		v := c.Query(key)
+		if v == "" {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a YYYY-MM-DD date", key)})
+			return
+		}
+		*dst = day
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+	if to.Sub(from) >= maxBillingDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("the period must not be longer than %d days", maxBillingDays)})
+		return
+	}
+
+	comparisons, err := h.meter.Reconcile(c.Request.Context(), from, to.AddDate(0, 0, 1), c.Query("tenantId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	mismatches := 0
+	out := comparisons[:0]
+	for _, cmp := range comparisons {
+		if !cmp.Match {
+			mismatches++
+		} else if c.Query("mismatched") == "true" {
+			continue
+		}
+		out = append(out, cmp)
+	}
+	c.JSON(http.StatusOK, gin.H{"mismatches": mismatches, "comparisons": out})
+}
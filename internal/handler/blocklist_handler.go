@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/blocklist"
+	"order-service/internal/middleware"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BlocklistHandler struct {
+	store    *blocklist.Store
+	auditLog IAuditLog
+}
+
+func NewBlocklistHandler(store *blocklist.Store, auditLog IAuditLog) *BlocklistHandler {
+	return &BlocklistHandler{store: store, auditLog: auditLog}
+}
+
+func (h *BlocklistHandler) List(c *gin.Context) {
+	entries, err := h.store.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entries == nil {
+		entries = []blocklist.Entry{}
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func (h *BlocklistHandler) Add(c *gin.Context) {
+	var entry blocklist.Entry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.store.Add(c.Request.Context(), middleware.Actor(c), &entry)
+	switch {
+	case errors.Is(err, blocklist.ErrInvalidEntry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, blocklist.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		recordAudit(c, h.auditLog, audit.ActionBlocklistAdd, strconv.FormatUint(uint64(entry.ID), 10), nil, entry)
+		c.JSON(http.StatusCreated, entry)
+	}
+}
+
+func (h *BlocklistHandler) Remove(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	entry, err := h.store.Remove(c.Request.Context(), middleware.Actor(c), uint(id))
+	switch {
+	case errors.Is(err, blocklist.ErrEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		recordAudit(c, h.auditLog, audit.ActionBlocklistRemove, c.Param("id"), entry, nil)
+		c.JSON(http.StatusOK, entry)
+	}
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+	"order-service/internal/runtimeconfig"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConfigHandler struct {
+	config *runtimeconfig.Manager
+}
+
+func NewConfigHandler(m *runtimeconfig.Manager) *ConfigHandler {
+	return &ConfigHandler{config: m}
+}
+
+// Get shows the reloadable configuration currently in effect.
+func (h *ConfigHandler) Get(c *gin.Context) {
+	c.JSON(http.StatusOK, h.config.Snapshot())
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"net/http"
+	"order-service/internal/consumer"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ConsumerHandler struct {
+	registry *consumer.Registry
+}
+
+func NewConsumerHandler(registry *consumer.Registry) *ConsumerHandler {
+	return &ConsumerHandler{registry: registry}
+}
+
+// List answers GET /admin/consumers with the state of the consumers this
+// process runs.
+func (h *ConsumerHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, h.registry.Stats())
+}
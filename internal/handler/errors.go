@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"order-service/internal/i18n"
+	"order-service/internal/middleware"
+	"order-service/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+var statusByCode = map[string]int{
+	service.CodeQuantityOutOfRange:      http.StatusUnprocessableEntity,
+	service.CodePurchaseLimitExceeded:   http.StatusUnprocessableEntity,
+	service.CodeOrderNotFound:           http.StatusNotFound,
+	service.CodeOrderNotOnHold:          http.StatusConflict,
+	service.CodeCustomerBlocked:         http.StatusForbidden,
+	service.CodeIPBlocked:               http.StatusForbidden,
+	service.CodeProductBlocked:          http.StatusForbidden,
+	service.CodeInvalidDiscountCode:     http.StatusUnprocessableEntity,
+	service.CodeCartNotFound:            http.StatusNotFound,
+	service.CodeCartEmpty:               http.StatusUnprocessableEntity,
+	service.CodeCartCheckedOut:          http.StatusConflict,
+	service.CodeOrderNotAwaitingPayment: http.StatusConflict,
+	service.CodePaymentDeclined:         http.StatusPaymentRequired,
+	service.CodePaymentExpired:          http.StatusGone,
+	service.CodeInvalidInstallments:     http.StatusUnprocessableEntity,
+	service.CodeInvalidGiftCard:         http.StatusUnprocessableEntity,
+	service.CodeTenderNotAvailable:      http.StatusUnprocessableEntity,
+	service.CodeUnsupportedCurrency:     http.StatusUnprocessableEntity,
+	service.CodeNothingToReplay:         http.StatusConflict,
+	service.CodeRevisionNotFound:        http.StatusNotFound,
+	service.CodeForbidden:               http.StatusForbidden,
+	service.CodeOrderNotAccepted:        http.StatusUnprocessableEntity,
+	service.CodeInvalidSchedule:         http.StatusUnprocessableEntity,
+	service.CodeOrderNotScheduled:       http.StatusConflict,
+	service.CodeReservationNotAvailable: http.StatusUnprocessableEntity,
+	service.CodeReservationExpired:      http.StatusGone,
+	service.CodeInvalidOrderID:          http.StatusBadRequest,
+	service.CodeOrderIDConflict:         http.StatusConflict,
+	service.CodeReasonRequired:          http.StatusBadRequest,
+	service.CodeUnknownStatus:           http.StatusUnprocessableEntity,
+	service.CodeOrderStatusConflict:     http.StatusConflict,
+	service.CodeInvalidTag:              http.StatusUnprocessableEntity,
+	service.CodeAddressRequired:         http.StatusUnprocessableEntity,
+	service.CodeCountryNotAllowed:       http.StatusUnprocessableEntity,
+	service.CodeRegionNotAllowed:        http.StatusUnprocessableEntity,
+}
+
+// codeInternal is the message catalog key for errors without a code.
+const codeInternal = "INTERNAL_ERROR"
+
+// writeError maps service errors to a response. Business rule violations
+// carry their code, timeouts get a 504; anything else is an internal error. message is the
+// code's text in the request language, error stays the untranslated
+// detail.
+func writeError(c *gin.Context, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		middleware.TimeoutError(c)
+		return
+	}
+	ctx := c.Request.Context()
+	var svcErr *service.Error
+	if errors.As(err, &svcErr) {
+		status, ok := statusByCode[svcErr.Code]
+		if !ok {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   err.Error(),
+			"code":    svcErr.Code,
+			"message": i18n.T(ctx, svcErr.Code, err.Error()),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   err.Error(),
+		"code":    codeInternal,
+		"message": i18n.T(ctx, codeInternal, err.Error()),
+	})
+}
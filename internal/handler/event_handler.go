@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/internal/consumer"
+	"order-service/internal/service"
+)
+
+// EventHandler handles events consumed from the message bus.
+type EventHandler struct {
+	service service.IOrderService
+}
+
+func NewEventHandler(s service.IOrderService) *EventHandler {
+	return &EventHandler{service: s}
+}
+
+// ClassifyEventError classifies the errors of the event handlers for the
+// quarantine: errors of the order service's business rules are business
+// errors.
+func ClassifyEventError(err error) string {
+	var svcErr *service.Error
+	if errors.As(err, &svcErr) {
+		return consumer.ClassBusiness
+	}
+	return consumer.Classify(err)
+}
+
+type stockReplenishedEvent struct {
+	ProductID string `json:"productId"`
+}
+
+func (h *EventHandler) StockReplenished(ctx context.Context, data json.RawMessage) error {
+	var ev stockReplenishedEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return fmt.Errorf("%w: invalid stock replenished event: %v", consumer.ErrSchema, err)
+	}
+	if ev.ProductID == "" {
+		return fmt.Errorf("%w: stock replenished event without productId", consumer.ErrSchema)
+	}
+	_, err := h.service.ConfirmBackorders(ctx, ev.ProductID)
+	return err
+}
+
+type installmentPaidEvent struct {
+	OrderID   string `json:"orderId"`
+	Sequence  int    `json:"sequence"`
+	PaymentID string `json:"paymentId"`
+}
+
+func (h *EventHandler) InstallmentPaid(ctx context.Context, data json.RawMessage) error {
+	var ev installmentPaidEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return fmt.Errorf("%w: invalid installment paid event: %v", consumer.ErrSchema, err)
+	}
+	if ev.OrderID == "" || ev.Sequence < 1 {
+		return fmt.Errorf("%w: installment paid event without orderId or sequence", consumer.ErrSchema)
+	}
+	return h.service.RecordInstallmentPayment(ctx, ev.OrderID, ev.Sequence, ev.PaymentID)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/georestrict"
+	"order-service/internal/middleware"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GeoRestrictionHandler struct {
+	store    *georestrict.Store
+	auditLog IAuditLog
+}
+
+func NewGeoRestrictionHandler(store *georestrict.Store, auditLog IAuditLog) *GeoRestrictionHandler {
+	return &GeoRestrictionHandler{store: store, auditLog: auditLog}
+}
+
+func (h *GeoRestrictionHandler) List(c *gin.Context) {
+	rules, err := h.store.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rules == nil {
+		rules = []georestrict.Rule{}
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *GeoRestrictionHandler) Add(c *gin.Context) {
+	var rule georestrict.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.store.Add(c.Request.Context(), middleware.Actor(c), &rule); err != nil {
+		writeGeoRestrictionError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionGeoRuleAdd, strconv.FormatUint(uint64(rule.ID), 10), nil, rule)
+	c.JSON(http.StatusCreated, rule)
+}
+
+// Update replaces a rule's countries, regions and reason.
+func (h *GeoRestrictionHandler) Update(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var rule georestrict.Rule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.store.Update(c.Request.Context(), middleware.Actor(c), uint(id), &rule)
+	if err != nil {
+		writeGeoRestrictionError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionGeoRuleUpdate, c.Param("id"), before, rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *GeoRestrictionHandler) Remove(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	rule, err := h.store.Remove(c.Request.Context(), middleware.Actor(c), uint(id))
+	if err != nil {
+		writeGeoRestrictionError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionGeoRuleRemove, c.Param("id"), rule, nil)
+	c.JSON(http.StatusOK, rule)
+}
+
+func writeGeoRestrictionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, georestrict.ErrInvalidRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, georestrict.ErrRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, georestrict.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"order-service/internal/degrade"
+	"order-service/internal/leader"
+	"order-service/internal/supervisor"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	controller *degrade.Controller
+	supervisor *supervisor.Supervisor
+	instance   string
+	draining   func() bool
+	leadership func() *leader.Status
+}
+
+// NewHealthHandler reports the dependencies of c and, if sup is not nil,
+// the goroutines it supervises.
+func NewHealthHandler(c *degrade.Controller, sup *supervisor.Supervisor) *HealthHandler {
+	return &HealthHandler{controller: c, supervisor: sup}
+}
+
+// SetInstance names the instance in the probe responses.
+func (h *HealthHandler) SetInstance(id string) {
+	h.instance = id
+}
+
+// SetDraining makes the instance report not ready while draining returns
+// true, so it is taken out of rotation before it shuts down.
+func (h *HealthHandler) SetDraining(draining func() bool) {
+	h.draining = draining
+}
+
+// SetLeadership adds the leader election the instance takes part in to the
+// readiness report. Leadership does not affect readiness.
+func (h *HealthHandler) SetLeadership(leadership func() *leader.Status) {
+	h.leadership = leadership
+}
+
+type readiness struct {
+	degrade.Report
+	Instance   string              `json:"instance,omitempty"`
+	Draining   bool                `json:"draining,omitempty"`
+	Leadership *leader.Status      `json:"leadership,omitempty"`
+	Goroutines []supervisor.Status `json:"goroutines"`
+}
+
+// Ready answers 503 when a critical dependency is down, a supervised
+// goroutine is crash looping or the instance is draining. Degraded
+// dependencies are listed with the modes they switched on but keep the
+// instance in rotation.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	r := readiness{Report: h.controller.Report(), Instance: h.instance, Goroutines: []supervisor.Status{}}
+	if h.supervisor != nil {
+		r.Goroutines = h.supervisor.Statuses()
+		r.Ready = r.Ready && h.supervisor.Healthy()
+	}
+	if h.leadership != nil {
+		r.Leadership = h.leadership()
+	}
+	if h.draining != nil && h.draining() {
+		r.Draining, r.Ready = true, false
+	}
+	status := http.StatusOK
+	if !r.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, r)
+}
+
+// Live answers 200 as long as the process serves requests. It does not
+// look at dependencies: restarting the instance would not bring them back.
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "instance": h.instance})
+}
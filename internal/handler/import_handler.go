@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/importer"
+	"order-service/internal/middleware"
+	"order-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// importFormField is the multipart field carrying the CSV file.
+const importFormField = "file"
+
+type ImportHandler struct {
+	importer *importer.Importer
+	auditLog IAuditLog
+}
+
+func NewImportHandler(im *importer.Importer, auditLog IAuditLog) *ImportHandler {
+	return &ImportHandler{importer: im, auditLog: auditLog}
+}
+
+// Create streams the uploaded CSV to the importer without buffering the
+// whole multipart form, and answers 202 with the job to poll at
+// GET /admin/jobs/:id.
+func (h *ImportHandler) Create(c *gin.Context) {
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expected a multipart/form-data upload"})
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			writeUploadError(c, err)
+			return
+		}
+		if part.FormName() != importFormField {
+			part.Close()
+			continue
+		}
+
+		ctx := c.Request.Context()
+		job, err := h.importer.Start(ctx, part, middleware.Actor(c), tenant.FromContext(ctx))
+		part.Close()
+		switch {
+		case errors.Is(err, importer.ErrUploadFailed):
+			writeUploadError(c, err)
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		default:
+			recordAudit(c, h.auditLog, audit.ActionOrderImport, job.ID, nil, job)
+			c.JSON(http.StatusAccepted, job)
+		}
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "missing file field"})
+}
+
+func writeUploadError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
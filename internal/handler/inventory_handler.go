@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"order-service/internal/inventory"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InventoryHandler struct {
+	ledger *inventory.Ledger
+}
+
+func NewInventoryHandler(ledger *inventory.Ledger) *InventoryHandler {
+	return &InventoryHandler{ledger: ledger}
+}
+
+func (h *InventoryHandler) Reserve(c *gin.Context) {
+	var hold inventory.Hold
+	if err := c.ShouldBindJSON(&hold); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.ledger.Reserve(c.Request.Context(), &hold); err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, hold)
+}
+
+func (h *InventoryHandler) Get(c *gin.Context) {
+	hold, err := h.ledger.Get(c.Request.Context(), c.Param("orderId"))
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
+
+type extendHoldRequest struct {
+	ExpiresAt time.Time `json:"expiresAt" binding:"required"`
+}
+
+func (h *InventoryHandler) Extend(c *gin.Context) {
+	var req extendHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hold, err := h.ledger.Extend(c.Request.Context(), c.Param("orderId"), req.ExpiresAt)
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
+
+func (h *InventoryHandler) Release(c *gin.Context) {
+	hold, err := h.ledger.Release(c.Request.Context(), c.Param("orderId"))
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, hold)
+}
+
+// Commitment answers how much of a product is held without asking
+// product-service.
+func (h *InventoryHandler) Commitment(c *gin.Context) {
+	commitment, err := h.ledger.Commitment(c.Request.Context(), c.Param("productId"))
+	if err != nil {
+		writeInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, commitment)
+}
+
+func writeInventoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, inventory.ErrInvalidHold):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, inventory.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"order-service/internal/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JobHandler struct {
+	store *jobs.Store
+}
+
+func NewJobHandler(store *jobs.Store) *JobHandler {
+	return &JobHandler{store: store}
+}
+
+func (h *JobHandler) Get(c *gin.Context) {
+	job, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, job)
+	}
+}
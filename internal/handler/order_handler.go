@@ -1,18 +1,72 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"order-service/internal/audit"
+	"order-service/internal/i18n"
+	"order-service/internal/jsonenc"
+	"order-service/internal/repository"
 	"order-service/internal/service"
+	"order-service/internal/tenant"
+	"order-service/internal/timezone"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type OrderHandler struct {
-	service *service.OrderService
+	service  service.IOrderService
+	auditLog IAuditLog
+	encoder  jsonenc.Encoder
+	share    *shareLinks
 }
 
-func NewOrderHandler(s *service.OrderService) *OrderHandler {
-	return &OrderHandler{service: s}
+func NewOrderHandler(s service.IOrderService, auditLog IAuditLog) *OrderHandler {
+	return &OrderHandler{service: s, auditLog: auditLog, encoder: jsonenc.Fast{}}
+}
+
+// SetEncoder changes how order lists are encoded.
+func (h *OrderHandler) SetEncoder(enc jsonenc.Encoder) {
+	h.encoder = enc
+}
+
+// RegisterRoutes adds the order API to orders, usually /orders, and the
+// order admin endpoints to admin, which must be behind
+// middleware.AdminAuth.
+func (h *OrderHandler) RegisterRoutes(orders, admin gin.IRoutes) {
+	orders.POST("", h.CreateOrder)
+	orders.POST("/bulk", h.CreateOrdersBulk)
+	orders.POST("/quote", h.QuoteOrder)
+	orders.POST("/from-cart", h.CheckoutCart)
+	orders.GET("/search", h.SearchOrders)
+	orders.GET("/:id", h.GetOrder)
+	orders.HEAD("/:id", h.OrderExists)
+	orders.GET("/:id/full", h.GetOrderSnapshot)
+	orders.POST("/:id/confirm", h.ConfirmOrder)
+	orders.POST("/:id/reorder", h.ReorderOrder)
+	orders.POST("/:id/reschedule", h.RescheduleOrder)
+	orders.POST("/:id/cancel", h.CancelOrder)
+	orders.POST("/:id/share", h.ShareOrder)
+	orders.GET("/:id/revisions", h.GetRevisions)
+	orders.GET("/:id/revisions/:n/diff", h.GetRevisionDiff)
+	orders.GET("/product/:productId", h.GetOrdersByProductID)
+	orders.GET("/product/:productId/count", h.CountOrdersByProduct)
+
+	admin.POST("/orders/explain", h.ExplainOrder)
+	admin.POST("/orders/:id/approve", h.ApproveOrder)
+	admin.POST("/orders/:id/reject", h.RejectOrder)
+	admin.POST("/orders/:id/force-status", h.ForceStatus)
+	admin.POST("/orders/:id/tags", h.AddTags)
+	admin.DELETE("/orders/:id/tags/:tag", h.RemoveTag)
+	admin.POST("/orders/:id/replay", h.ReplayEvent)
 }
 
 func (h *OrderHandler) CreateOrder(c *gin.Context) {
@@ -21,28 +75,567 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.ClientIP = c.ClientIP()
+	req.TenantID = tenant.FromContext(c.Request.Context())
 
-	order, err := h.service.CreateOrder(req)
+	order, err := h.service.CreateOrder(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, order)
+	c.JSON(http.StatusCreated, withLinks(c, order))
+}
+
+func (h *OrderHandler) QuoteOrder(c *gin.Context) {
+	var req service.CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ClientIP = c.ClientIP()
+	req.TenantID = tenant.FromContext(c.Request.Context())
+
+	quote, err := h.service.QuoteOrder(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
+// ExplainOrder shows how the acceptance rules judge an order without
+// storing it.
+func (h *OrderHandler) ExplainOrder(c *gin.Context) {
+	var req service.CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ClientIP = c.ClientIP()
+	req.TenantID = tenant.FromContext(c.Request.Context())
+
+	explanation, err := h.service.ExplainOrder(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, explanation)
+}
+
+func (h *OrderHandler) CheckoutCart(c *gin.Context) {
+	var req service.CheckoutCartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ClientIP = c.ClientIP()
+	req.TenantID = tenant.FromContext(c.Request.Context())
+
+	result, err := h.service.CheckoutCart(c.Request.Context(), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, result)
+}
+
+func (h *OrderHandler) CreateOrdersBulk(c *gin.Context) {
+	var reqs []service.CreateOrderRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > service.MaxBulkOrders {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("expected between 1 and %d orders", service.MaxBulkOrders)})
+		return
+	}
+	for i := range reqs {
+		reqs[i].ClientIP = c.ClientIP()
+		reqs[i].TenantID = tenant.FromContext(c.Request.Context())
+	}
+
+	orders, err := h.service.CreateOrders(c.Request.Context(), reqs)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, orders)
+}
+
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	order, err := h.service.GetOrder(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	order.StatusLabel = i18n.StatusLabel(c.Request.Context(), order.Status)
+	order.CreatedAt = order.CreatedAt.In(timezone.FromContext(c.Request.Context()))
+	order.Links = buildOrderLinks(c, &order.Order)
+	c.JSON(http.StatusOK, order)
+}
+
+// OrderExists answers 200 if the order exists and 404 if not, without a
+// body, for callers that only need to know.
+func (h *OrderHandler) OrderExists(c *gin.Context) {
+	exists, err := h.service.OrderExists(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// GetOrderSnapshot answers with the order and its items, payment and
+// timeline in one response, for support tools.
+func (h *OrderHandler) GetOrderSnapshot(c *gin.Context) {
+	snapshot, err := h.service.GetOrderSnapshot(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	loc := timezone.FromContext(ctx)
+	order := snapshot.Order
+	order.StatusLabel = i18n.StatusLabel(ctx, order.Status)
+	order.CreatedAt = order.CreatedAt.In(loc)
+	order.Links = buildOrderLinks(c, &order.Order)
+	for i := range snapshot.Timeline {
+		snapshot.Timeline[i].At = snapshot.Timeline[i].At.In(loc)
+	}
+	c.JSON(http.StatusOK, snapshot)
+}
+
+func (h *OrderHandler) GetRevisions(c *gin.Context) {
+	revisions, err := h.service.GetRevisions(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if revisions == nil {
+		revisions = []repository.OrderRevision{}
+	}
+	loc := timezone.FromContext(c.Request.Context())
+	for i := range revisions {
+		revisions[i].CreatedAt = revisions[i].CreatedAt.In(loc)
+		revisions[i].Snapshot.CreatedAt = revisions[i].Snapshot.CreatedAt.In(loc)
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+func (h *OrderHandler) GetRevisionDiff(c *gin.Context) {
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "revision must be a positive integer"})
+		return
+	}
+	diff, err := h.service.GetRevisionDiff(c.Request.Context(), c.Param("id"), n)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	diff.CreatedAt = diff.CreatedAt.In(timezone.FromContext(c.Request.Context()))
+	c.JSON(http.StatusOK, diff)
+}
+
+func (h *OrderHandler) ConfirmOrder(c *gin.Context) {
+	order, err := h.service.ConfirmOrder(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, withLinks(c, order))
+}
+
+// ReorderOrder places a fresh order like a previous one. The body is
+// optional.
+func (h *OrderHandler) ReorderOrder(c *gin.Context) {
+	var req service.ReorderRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.ClientIP = c.ClientIP()
+
+	order, err := h.service.ReorderOrder(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, withLinks(c, order))
+}
+
+// RescheduleOrder moves a scheduled order to another time.
+func (h *OrderHandler) RescheduleOrder(c *gin.Context) {
+	var req service.ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	order, err := h.service.RescheduleOrder(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, withLinks(c, order))
+}
+
+// CancelOrder cancels a scheduled order before it is activated.
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	order, err := h.service.CancelOrder(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, withLinks(c, order))
+}
+
+func (h *OrderHandler) ApproveOrder(c *gin.Context) {
+	id := c.Param("id")
+	before := h.snapshot(c.Request.Context(), id)
+	order, err := h.service.ApproveOrder(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionOrderApprove, id, before, order)
+	c.JSON(http.StatusOK, withLinks(c, order))
+}
+
+func (h *OrderHandler) RejectOrder(c *gin.Context) {
+	id := c.Param("id")
+	before := h.snapshot(c.Request.Context(), id)
+	order, err := h.service.RejectOrder(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionOrderReject, id, before, order)
+	c.JSON(http.StatusOK, withLinks(c, order))
+}
+
+// ForceStatus moves an order to any status, for data fixes. The reason is
+// kept in the audit log and the published event.
+func (h *OrderHandler) ForceStatus(c *gin.Context) {
+	var req service.ForceStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	id := c.Param("id")
+	before := h.snapshot(c.Request.Context(), id)
+	order, err := h.service.ForceOrderStatus(c.Request.Context(), id, req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	recordEntry(c, h.auditLog, &audit.Entry{
+		Action: audit.ActionOrderForceStatus,
+		Target: id,
+		Before: before,
+		After:  order,
+		Reason: strings.TrimSpace(req.Reason),
+	})
+	c.JSON(http.StatusOK, withLinks(c, order))
+}
+
+// AddTags adds tags to an order; RemoveTag removes one. Tags group orders
+// for campaigns and incident handling.
+func (h *OrderHandler) AddTags(c *gin.Context) {
+	var req service.TagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	id := c.Param("id")
+	before := h.snapshot(c.Request.Context(), id)
+	order, err := h.service.AddOrderTags(c.Request.Context(), id, req.Tags)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionOrderTag, id, before, order)
+	c.JSON(http.StatusOK, withLinks(c, order))
+}
+
+func (h *OrderHandler) RemoveTag(c *gin.Context) {
+	id := c.Param("id")
+	before := h.snapshot(c.Request.Context(), id)
+	order, err := h.service.RemoveOrderTag(c.Request.Context(), id, c.Param("tag"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionOrderUntag, id, before, order)
+	c.JSON(http.StatusOK, withLinks(c, order))
+}
+
+func (h *OrderHandler) ReplayEvent(c *gin.Context) {
+	id := c.Param("id")
+	pattern, err := h.service.ReplayEvent(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionOrderReplay, id, nil, gin.H{"pattern": pattern})
+	c.JSON(http.StatusOK, gin.H{"orderId": id, "pattern": pattern})
+}
+
+// snapshot returns the order as it is before an admin change, or nil when
+// it cannot be read; the change itself reports that error.
+func (h *OrderHandler) snapshot(ctx context.Context, id string) interface{} {
+	if h.auditLog == nil {
+		return nil
+	}
+	order, err := h.service.GetOrder(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return order.Order
 }
 
 func (h *OrderHandler) GetOrdersByProductID(c *gin.Context) {
 	productID := c.Param("productId")
-	orders, err := h.service.GetOrdersByProductID(productID)
+	tags, err := parseTags(c.QueryArray("tag"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if wantsNDJSON(c) {
+		h.streamOrders(c, repository.OrderFilter{ProductID: productID, Tags: tags}, 0, 0, nil)
+		return
+	}
+	orders, err := h.service.GetOrdersByProductID(c.Request.Context(), productID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	// The list is the product's cached one, so tags are filtered here.
+	if len(tags) > 0 {
+		var tagged []repository.Order
+		for _, o := range orders {
+			if o.HasTags(tags) {
+				tagged = append(tagged, o)
+			}
+		}
+		orders = tagged
+	}
 
 	if len(orders) == 0 {
 		c.JSON(http.StatusOK, []service.CreateOrderRequest{})
 		return
 	}
 
-	c.JSON(http.StatusOK, orders)
-}
\ No newline at end of file
+	h.writeOrders(c, orders)
+}
+
+// OrderCount is the answer of GET /orders/product/:productId/count.
+type OrderCount struct {
+	ProductID string `json:"productId"`
+	Status    string `json:"status,omitempty"`
+	Count     int64  `json:"count"`
+}
+
+// CountOrdersByProduct counts the product's orders, only those in the
+// status query parameter if it is set.
+func (h *OrderHandler) CountOrdersByProduct(c *gin.Context) {
+	productID, status := c.Param("productId"), c.Query("status")
+	count, err := h.service.CountOrdersByProduct(c.Request.Context(), productID, status)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, OrderCount{ProductID: productID, Status: status, Count: count})
+}
+
+// writeOrders responds with a list of orders, the largest responses of the
+// order API, using the handler's encoder.
+func (h *OrderHandler) writeOrders(c *gin.Context, orders []repository.Order) {
+	body, err := h.encoder.Append(nil, repository.OrderList(orders))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// ndjson is the media type of streamed order lists: one order per line.
+const ndjson = "application/x-ndjson"
+
+// streamFlushLines is how many lines of a stream are written between
+// flushes.
+const streamFlushLines = 100
+
+// errStreamDone ends a stream once it reached its limit.
+var errStreamDone = errors.New("stream done")
+
+// wantsNDJSON reports whether the client prefers a stream of orders over a
+// JSON array.
+func wantsNDJSON(c *gin.Context) bool {
+	return c.NegotiateFormat(binding.MIMEJSON, ndjson) == ndjson
+}
+
+// streamOrders writes the orders matching filter as NDJSON while they are
+// read from the database, so memory use does not grow with their number.
+// The first offset orders are skipped and at most limit written, if it is
+// not 0. Times are shown in loc unless it is nil. An error after the first
+// line can no longer change the status, so it ends the stream with a line
+// holding only the error.
+func (h *OrderHandler) streamOrders(c *gin.Context, filter repository.OrderFilter, limit, offset int, loc *time.Location) {
+	var line []byte
+	written, skipped := 0, 0
+	err := h.service.StreamOrders(c.Request.Context(), filter, func(order repository.Order) error {
+		if skipped < offset {
+			skipped++
+			return nil
+		}
+		if loc != nil {
+			order.CreatedAt = order.CreatedAt.In(loc)
+		}
+		var err error
+		if line, err = h.encoder.Append(line[:0], &order); err != nil {
+			return err
+		}
+		if written == 0 {
+			c.Header("Content-Type", ndjson+"; charset=utf-8")
+		}
+		if _, err := c.Writer.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		written++
+		if written%streamFlushLines == 0 {
+			c.Writer.Flush()
+		}
+		if written == limit {
+			return errStreamDone
+		}
+		return nil
+	})
+	switch {
+	case err == nil || errors.Is(err, errStreamDone):
+		if written == 0 {
+			c.Data(http.StatusOK, ndjson+"; charset=utf-8", nil)
+		}
+	case written == 0:
+		writeError(c, err)
+	default:
+		body, _ := json.Marshal(gin.H{"error": err.Error()})
+		c.Writer.Write(append(body, '\n'))
+	}
+}
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 200
+)
+
+func (h *OrderHandler) SearchOrders(c *gin.Context) {
+	h.searchOrders(c, c.Request.URL.Query())
+}
+
+// searchOrders answers c with the orders matching the search parameters in
+// query, which need not be the request's own.
+func (h *OrderHandler) searchOrders(c *gin.Context, query url.Values) {
+	filter := repository.OrderFilter{
+		ProductID: query.Get("productId"),
+		Status:    query.Get("status"),
+	}
+	var err error
+	if filter.Tags, err = parseTags(query["tag"]); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	loc := timezone.FromContext(c.Request.Context())
+	if filter.CreatedFrom, err = parseBoundary("from", query.Get("from"), loc, false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.CreatedTo, err = parseBoundary("to", query.Get("to"), loc, true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	offset, err := strconv.Atoi(valueOr(query, "offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+	// Streams are not held in memory, so they are not capped and by
+	// default return every match.
+	if wantsNDJSON(c) {
+		limit, err := strconv.Atoi(valueOr(query, "limit", "0"))
+		if err != nil || limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a non-negative integer"})
+			return
+		}
+		h.streamOrders(c, filter, limit, offset, loc)
+		return
+	}
+	limit, err := strconv.Atoi(valueOr(query, "limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit <= 0 || limit > maxSearchLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit)})
+		return
+	}
+
+	orders, err := h.service.SearchOrders(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if orders == nil {
+		orders = []repository.Order{}
+	}
+	for i := range orders {
+		orders[i].CreatedAt = orders[i].CreatedAt.In(loc)
+	}
+
+	h.writeOrders(c, orders)
+}
+
+// valueOr returns the query parameter key, or def if it is missing.
+func valueOr(query url.Values, key, def string) string {
+	if v, ok := query[key]; ok && len(v) > 0 {
+		return v[0]
+	}
+	return def
+}
+
+// parseTags reads the tag filter of a list: orders must have every tag
+// given, either as repeated tag parameters or comma-separated.
+func parseTags(params []string) ([]string, error) {
+	var values []string
+	for _, v := range params {
+		values = append(values, strings.Split(v, ",")...)
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return service.ParseTags(values)
+}
+
+// parseTimeQuery reads a date-range boundary in the request's timezone. A
+// plain date in "to" includes that whole day.
+func parseTimeQuery(c *gin.Context, key string, loc *time.Location, end bool) (time.Time, error) {
+	return parseBoundary(key, c.Query(key), loc, end)
+}
+
+func parseBoundary(key, v string, loc *time.Location, end bool) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := timezone.ParseBoundary(v, loc, end)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", key)
+	}
+	return t, nil
+}
@@ -0,0 +1,827 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"order-service/internal/audit"
+	"order-service/internal/golden"
+	"order-service/internal/i18n"
+	"order-service/internal/middleware"
+	"order-service/internal/repository"
+	"order-service/internal/service"
+	"order-service/internal/tenant"
+	"order-service/internal/timezone"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testAdminToken = "test-token"
+
+// testCreatedAt is when testOrder was created.
+var testCreatedAt = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// testOrder is the order mockOrderService answers with.
+func testOrder() *repository.Order {
+	changed := testCreatedAt
+	return &repository.Order{
+		ID:              "order-1",
+		ProductID:       "p1",
+		CustomerID:      "customer-1",
+		Quantity:        2,
+		Status:          repository.StatusPending,
+		Subtotal:        100,
+		TaxAmount:       10,
+		ShippingFee:     5,
+		TotalPrice:      115,
+		Currency:        "IDR",
+		CreatedAt:       testCreatedAt,
+		StatusChangedAt: &changed,
+	}
+}
+
+// mockOrderService answers every call with testOrder, or with err when it
+// is set, and records what it was called with.
+type mockOrderService struct {
+	err    error
+	calls  []string
+	req    service.CreateOrderRequest
+	reqs   []service.CreateOrderRequest
+	cart   service.CheckoutCartRequest
+	id     string
+	n      int
+	filter repository.OrderFilter
+	limit  int
+	offset int
+	// streamed is how many copies of testOrder StreamOrders yields, one if
+	// 0; streamErr is what it returns after them.
+	streamed  int
+	streamErr error
+	// status replaces the status of testOrder if set.
+	status string
+	tags   []string
+	// missing makes OrderExists report that the order does not exist.
+	missing bool
+}
+
+var _ service.IOrderService = &mockOrderService{}
+
+func (m *mockOrderService) call(op, id string) (*repository.Order, error) {
+	m.calls = append(m.calls, op)
+	m.id = id
+	if m.err != nil {
+		return nil, m.err
+	}
+	order := testOrder()
+	if m.status != "" {
+		order.Status = m.status
+	}
+	return order, nil
+}
+
+func (m *mockOrderService) CreateOrder(_ context.Context, req service.CreateOrderRequest) (*repository.Order, error) {
+	m.req = req
+	return m.call("CreateOrder", "")
+}
+
+func (m *mockOrderService) CreateOrders(_ context.Context, reqs []service.CreateOrderRequest) ([]repository.Order, error) {
+	m.reqs = reqs
+	order, err := m.call("CreateOrders", "")
+	if err != nil {
+		return nil, err
+	}
+	return []repository.Order{*order}, nil
+}
+
+func (m *mockOrderService) QuoteOrder(_ context.Context, req service.CreateOrderRequest) (*service.Quote, error) {
+	m.req = req
+	if _, err := m.call("QuoteOrder", ""); err != nil {
+		return nil, err
+	}
+	return testQuote(req), nil
+}
+
+func testQuote(req service.CreateOrderRequest) *service.Quote {
+	return &service.Quote{ProductID: req.ProductID, Quantity: req.Quantity, UnitPrice: 50, Subtotal: 100, Tax: 10, ShippingFee: 5, Total: 115, Currency: "IDR"}
+}
+
+func (m *mockOrderService) ExplainOrder(_ context.Context, req service.CreateOrderRequest) (*service.OrderExplanation, error) {
+	m.req = req
+	if _, err := m.call("ExplainOrder", ""); err != nil {
+		return nil, err
+	}
+	return &service.OrderExplanation{Quote: testQuote(req)}, nil
+}
+
+func (m *mockOrderService) CheckoutCart(_ context.Context, req service.CheckoutCartRequest) (*service.CheckoutResult, error) {
+	m.cart = req
+	order, err := m.call("CheckoutCart", "")
+	if err != nil {
+		return nil, err
+	}
+	return &service.CheckoutResult{CartID: req.CartID, Orders: []repository.Order{*order}, Total: order.TotalPrice}, nil
+}
+
+func (m *mockOrderService) ReorderOrder(_ context.Context, id string, req service.ReorderRequest) (*repository.Order, error) {
+	m.req = service.CreateOrderRequest{Quantity: req.Quantity, ClientIP: req.ClientIP}
+	return m.call("ReorderOrder", id)
+}
+
+func (m *mockOrderService) ConfirmOrder(_ context.Context, id string) (*repository.Order, error) {
+	return m.call("ConfirmOrder", id)
+}
+
+func (m *mockOrderService) RescheduleOrder(_ context.Context, id string, _ service.ScheduleRequest) (*repository.Order, error) {
+	return m.call("RescheduleOrder", id)
+}
+
+func (m *mockOrderService) CancelOrder(_ context.Context, id string) (*repository.Order, error) {
+	return m.call("CancelOrder", id)
+}
+
+func (m *mockOrderService) GetOrder(_ context.Context, id string) (*service.OrderDetail, error) {
+	order, err := m.call("GetOrder", id)
+	if err != nil {
+		return nil, err
+	}
+	return &service.OrderDetail{Order: *order}, nil
+}
+
+func (m *mockOrderService) GetOrderSnapshot(_ context.Context, id string) (*service.OrderSnapshot, error) {
+	order, err := m.call("GetOrderSnapshot", id)
+	if err != nil {
+		return nil, err
+	}
+	return &service.OrderSnapshot{
+		Order: &service.OrderDetail{Order: *order},
+		Items: []service.OrderItem{{ProductID: order.ProductID, Quantity: order.Quantity, UnitPrice: 50, Subtotal: order.Subtotal}},
+		Payment: service.PaymentSummary{
+			TotalPrice: order.TotalPrice, RemainingAmount: order.TotalPrice,
+			Tenders: []repository.Tender{}, Installments: []repository.Installment{},
+		},
+		Timeline: []service.TimelineEntry{{Number: 1, At: testCreatedAt, Status: order.Status, Changes: []service.FieldChange{
+			{Field: "Status", To: order.Status},
+		}}},
+	}, nil
+}
+
+func (m *mockOrderService) CountOrdersByProduct(_ context.Context, productID, status string) (int64, error) {
+	m.filter = repository.OrderFilter{ProductID: productID, Status: status}
+	if _, err := m.call("CountOrdersByProduct", productID); err != nil {
+		return 0, err
+	}
+	return 3, nil
+}
+
+func (m *mockOrderService) OrderExists(_ context.Context, id string) (bool, error) {
+	if _, err := m.call("OrderExists", id); err != nil {
+		return false, err
+	}
+	return !m.missing, nil
+}
+
+func (m *mockOrderService) GetOrdersByProductID(_ context.Context, productID string) ([]repository.Order, error) {
+	order, err := m.call("GetOrdersByProductID", productID)
+	if err != nil {
+		return nil, err
+	}
+	return []repository.Order{*order}, nil
+}
+
+func (m *mockOrderService) SearchOrders(_ context.Context, filter repository.OrderFilter, limit, offset int) ([]repository.Order, error) {
+	m.filter, m.limit, m.offset = filter, limit, offset
+	order, err := m.call("SearchOrders", "")
+	if err != nil {
+		return nil, err
+	}
+	return []repository.Order{*order}, nil
+}
+
+func (m *mockOrderService) StreamOrders(_ context.Context, filter repository.OrderFilter, fn func(repository.Order) error) error {
+	m.filter = filter
+	order, err := m.call("StreamOrders", "")
+	if err != nil {
+		return err
+	}
+	for i := range max(m.streamed, 1) {
+		o := *order
+		o.ID = fmt.Sprintf("order-%d", i+1)
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return m.streamErr
+}
+
+func (m *mockOrderService) GetRevisions(_ context.Context, id string) ([]repository.OrderRevision, error) {
+	order, err := m.call("GetRevisions", id)
+	if err != nil {
+		return nil, err
+	}
+	return []repository.OrderRevision{{OrderID: id, Number: 1, Snapshot: *order, CreatedAt: testCreatedAt}}, nil
+}
+
+func (m *mockOrderService) GetRevisionDiff(_ context.Context, id string, n int) (*service.RevisionDiff, error) {
+	m.n = n
+	if _, err := m.call("GetRevisionDiff", id); err != nil {
+		return nil, err
+	}
+	return &service.RevisionDiff{OrderID: id, Number: n, CreatedAt: testCreatedAt, Changes: []service.FieldChange{
+		{Field: "Status", From: repository.StatusOnHold, To: repository.StatusPending},
+	}}, nil
+}
+
+func (m *mockOrderService) ApproveOrder(_ context.Context, id string) (*repository.Order, error) {
+	return m.call("ApproveOrder", id)
+}
+
+func (m *mockOrderService) RejectOrder(_ context.Context, id string) (*repository.Order, error) {
+	return m.call("RejectOrder", id)
+}
+
+func (m *mockOrderService) ForceOrderStatus(_ context.Context, id string, _ service.ForceStatusRequest) (*repository.Order, error) {
+	return m.call("ForceOrderStatus", id)
+}
+
+func (m *mockOrderService) AddOrderTags(_ context.Context, id string, tags []string) (*repository.Order, error) {
+	m.tags = tags
+	return m.call("AddOrderTags", id)
+}
+
+func (m *mockOrderService) RemoveOrderTag(_ context.Context, id, tag string) (*repository.Order, error) {
+	m.tags = []string{tag}
+	return m.call("RemoveOrderTag", id)
+}
+
+func (m *mockOrderService) ReplayEvent(_ context.Context, id string) (string, error) {
+	if _, err := m.call("ReplayEvent", id); err != nil {
+		return "", err
+	}
+	return "order.created", nil
+}
+
+func (m *mockOrderService) ConfirmBackorders(_ context.Context, productID string) (int, error) {
+	_, err := m.call("ConfirmBackorders", productID)
+	return 0, err
+}
+
+func (m *mockOrderService) RecordInstallmentPayment(_ context.Context, orderID string, _ int, _ string) error {
+	_, err := m.call("RecordInstallmentPayment", orderID)
+	return err
+}
+
+type mockAuditLog struct {
+	entries []audit.Entry
+}
+
+func (m *mockAuditLog) Record(_ context.Context, e *audit.Entry) error {
+	m.entries = append(m.entries, *e)
+	return nil
+}
+
+// newOrderRouter serves the order routes like the app does, with the
+// admin ones behind testAdminToken.
+func newOrderRouter(t *testing.T, svc service.IOrderService, auditLog IAuditLog) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	zones, err := timezone.NewResolver("UTC", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	router := gin.New()
+	router.Use(tenant.Middleware(""), i18n.Middleware(), timezone.Middleware(zones))
+	NewOrderHandler(svc, auditLog).RegisterRoutes(router.Group("/orders", middleware.AdminIdentity(testAdminToken)), router.Group("/admin", middleware.AdminAuth(testAdminToken)))
+	return router
+}
+
+func serve(router *gin.Engine, method, path, body string, header map[string]string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, path, nil)
+	} else {
+		req = httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+var adminHeader = map[string]string{"Authorization": "Bearer " + testAdminToken}
+
+type routeCase struct {
+	method, path, body string
+	admin              bool
+	op                 string
+	status             int
+}
+
+// orderRoutes has a successful request for every order route.
+var orderRoutes = []routeCase{
+	{http.MethodPost, "/orders", `{"productId":"p1","quantity":2}`, false, "CreateOrder", http.StatusCreated},
+	{http.MethodPost, "/orders/bulk", `[{"productId":"p1","quantity":2}]`, false, "CreateOrders", http.StatusCreated},
+	{http.MethodPost, "/orders/quote", `{"productId":"p1","quantity":2}`, false, "QuoteOrder", http.StatusOK},
+	{http.MethodPost, "/orders/from-cart", `{"cartId":"cart-1"}`, false, "CheckoutCart", http.StatusCreated},
+	{http.MethodGet, "/orders/search?status=PENDING", "", false, "SearchOrders", http.StatusOK},
+	{http.MethodGet, "/orders/order-1", "", false, "GetOrder", http.StatusOK},
+	{http.MethodGet, "/orders/order-1/full", "", false, "GetOrderSnapshot", http.StatusOK},
+	{http.MethodPost, "/orders/order-1/confirm", "", false, "ConfirmOrder", http.StatusOK},
+	{http.MethodPost, "/orders/order-1/reorder", "", false, "ReorderOrder", http.StatusCreated},
+	{http.MethodPost, "/orders/order-1/reschedule", `{"processAt":"2030-01-02T03:04:05Z"}`, false, "RescheduleOrder", http.StatusOK},
+	{http.MethodPost, "/orders/order-1/cancel", "", false, "CancelOrder", http.StatusOK},
+	{http.MethodGet, "/orders/order-1/revisions", "", false, "GetRevisions", http.StatusOK},
+	{http.MethodGet, "/orders/order-1/revisions/2/diff", "", false, "GetRevisionDiff", http.StatusOK},
+	{http.MethodGet, "/orders/product/p1", "", false, "GetOrdersByProductID", http.StatusOK},
+	{http.MethodGet, "/orders/product/p1/count?status=PAID", "", false, "CountOrdersByProduct", http.StatusOK},
+	{http.MethodPost, "/admin/orders/explain", `{"productId":"p1","quantity":2}`, true, "ExplainOrder", http.StatusOK},
+	{http.MethodPost, "/admin/orders/order-1/approve", "", true, "ApproveOrder", http.StatusOK},
+	{http.MethodPost, "/admin/orders/order-1/reject", "", true, "RejectOrder", http.StatusOK},
+	{http.MethodPost, "/admin/orders/order-1/force-status", `{"status":"PAID","reason":"paid by bank transfer"}`, true, "ForceOrderStatus", http.StatusOK},
+	{http.MethodPost, "/admin/orders/order-1/tags", `{"tags":["flash-sale"]}`, true, "AddOrderTags", http.StatusOK},
+	{http.MethodDelete, "/admin/orders/order-1/tags/flash-sale", "", true, "RemoveOrderTag", http.StatusOK},
+	{http.MethodPost, "/admin/orders/order-1/replay", "", true, "ReplayEvent", http.StatusOK},
+}
+
+func TestOrderRoutes(t *testing.T) {
+	for _, tc := range orderRoutes {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			svc := &mockOrderService{}
+			var header map[string]string
+			if tc.admin {
+				header = adminHeader
+			}
+			w := serve(newOrderRouter(t, svc, nil), tc.method, tc.path, tc.body, header)
+			if w.Code != tc.status {
+				t.Fatalf("Expected %d, got %d: %s", tc.status, w.Code, w.Body)
+			}
+			if len(svc.calls) != 1 || svc.calls[0] != tc.op {
+				t.Errorf("Expected one call to %s, got %v", tc.op, svc.calls)
+			}
+			if !json.Valid(w.Body.Bytes()) {
+				t.Errorf("Expected a JSON body, got %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestOrderRoutesPassRequestDetails(t *testing.T) {
+	svc := &mockOrderService{}
+	router := newOrderRouter(t, svc, nil)
+
+	serve(router, http.MethodPost, "/orders", `{"productId":"p1","quantity":2}`, map[string]string{tenant.Header: "acme"})
+	if svc.req.ProductID != "p1" || svc.req.Quantity != 2 || svc.req.TenantID != "acme" || svc.req.ClientIP == "" {
+		t.Errorf("Unexpected create request %+v", svc.req)
+	}
+
+	serve(router, http.MethodPost, "/orders/bulk", `[{"productId":"p1","quantity":1},{"productId":"p2","quantity":3}]`, map[string]string{tenant.Header: "acme"})
+	if len(svc.reqs) != 2 || svc.reqs[1].ProductID != "p2" || svc.reqs[1].TenantID != "acme" {
+		t.Errorf("Unexpected bulk requests %+v", svc.reqs)
+	}
+
+	serve(router, http.MethodPost, "/orders/from-cart", `{"cartId":"cart-1"}`, map[string]string{tenant.Header: "acme"})
+	if svc.cart.CartID != "cart-1" || svc.cart.TenantID != "acme" {
+		t.Errorf("Unexpected checkout request %+v", svc.cart)
+	}
+
+	serve(router, http.MethodPost, "/orders/order-9/reorder", `{"quantity":4}`, nil)
+	if svc.id != "order-9" || svc.req.Quantity != 4 {
+		t.Errorf("Expected a reorder of order-9 with quantity 4, got %s %+v", svc.id, svc.req)
+	}
+
+	serve(router, http.MethodGet, "/orders/order-1/revisions/3/diff", "", nil)
+	if svc.n != 3 {
+		t.Errorf("Expected revision 3, got %d", svc.n)
+	}
+
+	serve(router, http.MethodGet, "/orders/search?productId=p1&status=PENDING&from=2024-03-01&limit=10&offset=20", "", nil)
+	if svc.filter.ProductID != "p1" || svc.filter.Status != "PENDING" || svc.limit != 10 || svc.offset != 20 {
+		t.Errorf("Unexpected search %+v limit %d offset %d", svc.filter, svc.limit, svc.offset)
+	}
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !svc.filter.CreatedFrom.Equal(want) {
+		t.Errorf("Expected from %v, got %v", want, svc.filter.CreatedFrom)
+	}
+
+	serve(router, http.MethodGet, "/orders/search?tag=Flash-Sale&tag=vip,incident-42", "", nil)
+	if want := []string{"flash-sale", "vip", "incident-42"}; !slices.Equal(svc.filter.Tags, want) {
+		t.Errorf("Expected tags %v, got %v", want, svc.filter.Tags)
+	}
+
+	serve(router, http.MethodGet, "/orders/product/p2/count?status=PAID", "", nil)
+	if svc.filter.ProductID != "p2" || svc.filter.Status != "PAID" {
+		t.Errorf("Unexpected count %+v", svc.filter)
+	}
+
+	serve(router, http.MethodPost, "/admin/orders/order-1/tags", `{"tags":["vip","flash-sale"]}`, adminHeader)
+	if want := []string{"vip", "flash-sale"}; !slices.Equal(svc.tags, want) {
+		t.Errorf("Expected tags %v, got %v", want, svc.tags)
+	}
+}
+
+func TestOrdersByProductFilterTags(t *testing.T) {
+	router := newOrderRouter(t, &mockOrderService{}, nil)
+
+	if w := serve(router, http.MethodGet, "/orders/product/p1?tag=vip", "", nil); w.Body.String() != "[]" {
+		t.Errorf("Expected no untagged orders, got %s", w.Body)
+	}
+	if w := serve(router, http.MethodGet, "/orders/product/p1?tag=two%20words", "", nil); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for an invalid tag, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestOrderExists(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		svc    *mockOrderService
+		status int
+	}{
+		{"exists", &mockOrderService{}, http.StatusOK},
+		{"missing", &mockOrderService{missing: true}, http.StatusNotFound},
+		{"internal", &mockOrderService{err: errors.New("database is down")}, http.StatusInternalServerError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(newOrderRouter(t, tc.svc, nil), http.MethodHead, "/orders/order-1", "", nil)
+			if w.Code != tc.status {
+				t.Errorf("Expected %d, got %d", tc.status, w.Code)
+			}
+			if len(tc.svc.calls) != 1 || tc.svc.calls[0] != "OrderExists" || tc.svc.id != "order-1" {
+				t.Errorf("Expected one existence check of order-1, got %v for %q", tc.svc.calls, tc.svc.id)
+			}
+		})
+	}
+}
+
+func TestOrderRoutesRejectBadRequests(t *testing.T) {
+	cases := []struct {
+		name, method, path, body string
+	}{
+		{"malformed create", http.MethodPost, "/orders", `{"productId":`},
+		{"wrong type", http.MethodPost, "/orders", `{"productId":"p1","quantity":"two"}`},
+		{"bulk not an array", http.MethodPost, "/orders/bulk", `{"productId":"p1"}`},
+		{"empty bulk", http.MethodPost, "/orders/bulk", `[]`},
+		{"too many bulk", http.MethodPost, "/orders/bulk", "[" + strings.Repeat(`{"productId":"p1","quantity":1},`, service.MaxBulkOrders) + `{"productId":"p1","quantity":1}]`},
+		{"malformed quote", http.MethodPost, "/orders/quote", `nope`},
+		{"cart without id", http.MethodPost, "/orders/from-cart", `{}`},
+		{"malformed reorder", http.MethodPost, "/orders/order-1/reorder", `{"quantity":`},
+		{"malformed reschedule", http.MethodPost, "/orders/order-1/reschedule", `{"processAt":"tomorrow"}`},
+		{"revision not a number", http.MethodGet, "/orders/order-1/revisions/latest/diff", ""},
+		{"revision zero", http.MethodGet, "/orders/order-1/revisions/0/diff", ""},
+		{"search limit too high", http.MethodGet, "/orders/search?limit=1000", ""},
+		{"search negative offset", http.MethodGet, "/orders/search?offset=-1", ""},
+		{"search bad date", http.MethodGet, "/orders/search?from=yesterday", ""},
+		{"unknown timezone", http.MethodGet, "/orders/order-1?tz=Mars/Olympus", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &mockOrderService{}
+			w := serve(newOrderRouter(t, svc, nil), tc.method, tc.path, tc.body, nil)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d: %s", w.Code, w.Body)
+			}
+			if len(svc.calls) != 0 {
+				t.Errorf("Expected the service not to be called, got %v", svc.calls)
+			}
+		})
+	}
+
+	t.Run("malformed explain", func(t *testing.T) {
+		svc := &mockOrderService{}
+		w := serve(newOrderRouter(t, svc, nil), http.MethodPost, "/admin/orders/explain", `{`, adminHeader)
+		if w.Code != http.StatusBadRequest || len(svc.calls) != 0 {
+			t.Errorf("Expected 400 without a service call, got %d and %v", w.Code, svc.calls)
+		}
+	})
+}
+
+func TestOrderAdminRoutesRequireToken(t *testing.T) {
+	headers := map[string]map[string]string{
+		"no token":    nil,
+		"wrong token": {"Authorization": "Bearer wrong"},
+		"not bearer":  {"Authorization": testAdminToken},
+	}
+	for _, tc := range orderRoutes {
+		if !tc.admin {
+			continue
+		}
+		for name, header := range headers {
+			t.Run(name+" "+tc.path, func(t *testing.T) {
+				svc := &mockOrderService{}
+				w := serve(newOrderRouter(t, svc, nil), tc.method, tc.path, tc.body, header)
+				if w.Code != http.StatusUnauthorized {
+					t.Errorf("Expected 401, got %d", w.Code)
+				}
+				if len(svc.calls) != 0 {
+					t.Errorf("Expected the service not to be called, got %v", svc.calls)
+				}
+			})
+		}
+	}
+}
+
+func TestOrderRoutesMapErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", &service.Error{Code: service.CodeOrderNotFound, Message: "order not found"}, http.StatusNotFound, service.CodeOrderNotFound},
+		{"conflict", &service.Error{Code: service.CodeOrderNotOnHold, Message: "order is not on hold"}, http.StatusConflict, service.CodeOrderNotOnHold},
+		{"forbidden", &service.Error{Code: service.CodeForbidden, Message: "forbidden"}, http.StatusForbidden, service.CodeForbidden},
+		{"payment declined", &service.Error{Code: service.CodePaymentDeclined, Message: "declined"}, http.StatusPaymentRequired, service.CodePaymentDeclined},
+		{"wrapped", fmt.Errorf("order 1: %w", &service.Error{Code: service.CodeQuantityOutOfRange, Message: "too many"}), http.StatusUnprocessableEntity, service.CodeQuantityOutOfRange},
+		{"unmapped code", &service.Error{Code: "SOMETHING_NEW", Message: "new rule"}, http.StatusBadRequest, "SOMETHING_NEW"},
+		{"internal", errors.New("database is down"), http.StatusInternalServerError, codeInternal},
+		{"timeout", context.DeadlineExceeded, http.StatusGatewayTimeout, ""},
+	}
+	for _, route := range orderRoutes {
+		if route.op == "GetOrdersByProductID" {
+			continue
+		}
+		var header map[string]string
+		if route.admin {
+			header = adminHeader
+		}
+		for _, tc := range cases {
+			t.Run(tc.name+" "+route.method+" "+route.path, func(t *testing.T) {
+				w := serve(newOrderRouter(t, &mockOrderService{err: tc.err}, nil), route.method, route.path, route.body, header)
+				if w.Code != tc.status {
+					t.Fatalf("Expected %d, got %d: %s", tc.status, w.Code, w.Body)
+				}
+				if tc.code == "" {
+					return
+				}
+				var body map[string]string
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatalf("Expected a JSON error, got %s", w.Body)
+				}
+				if body["code"] != tc.code || body["error"] != tc.err.Error() || body["message"] == "" {
+					t.Errorf("Unexpected error body %v", body)
+				}
+			})
+		}
+	}
+
+	t.Run("orders by product", func(t *testing.T) {
+		w := serve(newOrderRouter(t, &mockOrderService{err: errors.New("database is down")}, nil), http.MethodGet, "/orders/product/p1", "", nil)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected 500, got %d", w.Code)
+		}
+	})
+}
+
+func TestOrderErrorsAreTranslated(t *testing.T) {
+	svc := &mockOrderService{err: &service.Error{Code: service.CodeOrderNotFound, Message: "order not found"}}
+	w := serve(newOrderRouter(t, svc, nil), http.MethodGet, "/orders/order-1", "", map[string]string{"Accept-Language": "id"})
+	var body map[string]string
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body["message"] != "Pesanan tidak ditemukan." || body["error"] != "order not found" {
+		t.Errorf("Unexpected error body %v", body)
+	}
+}
+
+func TestOrderAdminRoutesAreAudited(t *testing.T) {
+	svc := &mockOrderService{}
+	auditLog := &mockAuditLog{}
+	router := newOrderRouter(t, svc, auditLog)
+
+	header := map[string]string{"Authorization": "Bearer " + testAdminToken, middleware.ActorHeader: "alice"}
+	serve(router, http.MethodPost, "/admin/orders/order-1/approve", "", header)
+	serve(router, http.MethodPost, "/admin/orders/order-1/replay", "", header)
+	serve(router, http.MethodPost, "/admin/orders/order-1/force-status", `{"status":"PAID","reason":" paid by bank transfer "}`, header)
+
+	if len(auditLog.entries) != 3 {
+		t.Fatalf("Expected 3 audit entries, got %d", len(auditLog.entries))
+	}
+	approve := auditLog.entries[0]
+	if approve.Action != audit.ActionOrderApprove || approve.Actor != "alice" || approve.Target != "order-1" || approve.Before == nil {
+		t.Errorf("Unexpected approve entry %+v", approve)
+	}
+	if auditLog.entries[1].Action != audit.ActionOrderReplay {
+		t.Errorf("Expected a replay entry, got %s", auditLog.entries[1].Action)
+	}
+	if force := auditLog.entries[2]; force.Action != audit.ActionOrderForceStatus || force.Reason != "paid by bank transfer" {
+		t.Errorf("Unexpected force-status entry %+v", force)
+	}
+
+	auditLog.entries = nil
+	svc.err = &service.Error{Code: service.CodeOrderNotOnHold, Message: "order is not on hold"}
+	serve(router, http.MethodPost, "/admin/orders/order-1/reject", "", header)
+	if len(auditLog.entries) != 0 {
+		t.Errorf("Expected failed changes not to be audited, got %+v", auditLog.entries)
+	}
+}
+
+// response is what the golden files of the order API hold.
+type response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// TestOrderResponses pins the status and body of every order route and of
+// the error responses. A failure here is a change to the public API.
+func TestOrderResponses(t *testing.T) {
+	for _, tc := range orderRoutes {
+		t.Run(tc.op, func(t *testing.T) {
+			var header map[string]string
+			if tc.admin {
+				header = adminHeader
+			}
+			w := serve(newOrderRouter(t, &mockOrderService{}, nil), tc.method, tc.path, tc.body, header)
+			golden.JSON(t, "responses/"+tc.op, response{Status: w.Code, Body: w.Body.Bytes()})
+		})
+	}
+
+	t.Run("GetOrder localized", func(t *testing.T) {
+		w := serve(newOrderRouter(t, &mockOrderService{}, nil), http.MethodGet, "/orders/order-1?tz=Asia/Jakarta", "", map[string]string{"Accept-Language": "id"})
+		golden.JSON(t, "responses/GetOrder.localized", response{Status: w.Code, Body: w.Body.Bytes()})
+	})
+
+	errorCases := []struct {
+		name, method, path, body string
+		header                   map[string]string
+		err                      error
+	}{
+		{"not_found", http.MethodGet, "/orders/order-1", "", nil, &service.Error{Code: service.CodeOrderNotFound, Message: "order not found"}},
+		{"not_found.localized", http.MethodGet, "/orders/order-1", "", map[string]string{"Accept-Language": "id"}, &service.Error{Code: service.CodeOrderNotFound, Message: "order not found"}},
+		{"internal", http.MethodPost, "/orders", `{"productId":"p1","quantity":2}`, nil, errors.New("database is down")},
+		{"timeout", http.MethodPost, "/orders", `{"productId":"p1","quantity":2}`, nil, context.DeadlineExceeded},
+		{"bad_request", http.MethodPost, "/orders", `{"productId":"p1","quantity":"two"}`, nil, nil},
+		{"unauthorized", http.MethodPost, "/admin/orders/order-1/approve", "", nil, nil},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serve(newOrderRouter(t, &mockOrderService{err: tc.err}, nil), tc.method, tc.path, tc.body, tc.header)
+			golden.JSON(t, "errors/"+tc.name, response{Status: w.Code, Body: w.Body.Bytes()})
+		})
+	}
+}
+
+func TestOrderLinks(t *testing.T) {
+	tests := []struct {
+		status string
+		admin  bool
+		want   []string
+	}{
+		{repository.StatusPending, false, []string{"reorder", "self", "timeline"}},
+		{repository.StatusScheduled, false, []string{"cancel", "reorder", "reschedule", "self", "timeline"}},
+		{repository.StatusReserved, false, []string{"confirm", "reorder", "self", "timeline"}},
+		{repository.StatusAwaitingPayment, false, []string{"confirm", "reorder", "self", "timeline"}},
+		{repository.StatusOnHold, false, []string{"reorder", "self", "timeline"}},
+		{repository.StatusOnHold, true, []string{"approve", "reject", "reorder", "self", "timeline"}},
+		{repository.StatusCancelled, true, []string{"reorder", "self", "timeline"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s admin=%t", tt.status, tt.admin), func(t *testing.T) {
+			var header map[string]string
+			if tt.admin {
+				header = adminHeader
+			}
+			w := serve(newOrderRouter(t, &mockOrderService{status: tt.status}, nil), http.MethodGet, "/orders/order-1", "", header)
+			var body struct {
+				Links map[string]struct{ Href, Method string } `json:"_links"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Expected a JSON body, got %v", err)
+			}
+			if got := slices.Sorted(maps.Keys(body.Links)); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected links %v, got %v", tt.want, got)
+			}
+			if self := body.Links["self"]; self.Href != "/orders/order-1" || self.Method != http.MethodGet {
+				t.Errorf("Expected self to be GET /orders/order-1, got %+v", self)
+			}
+		})
+	}
+}
+
+func TestShareOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signer := middleware.NewShareSigner("s3cret")
+	h := NewOrderHandler(&mockOrderService{}, nil)
+	h.SetShareLinks(signer, "https://orders.example.com/", time.Hour, 24*time.Hour)
+	router := gin.New()
+	router.Use(i18n.Middleware())
+	router.POST("/orders/:id/share", h.ShareOrder)
+	router.GET("/shared/orders/:token", middleware.SharedOrder(signer), h.GetSharedOrder)
+
+	w := serve(router, http.MethodPost, "/orders/order-1/share", `{"ttl":"2h"}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var link ShareResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+		t.Fatalf("Expected a JSON body, got %v", err)
+	}
+	path, ok := strings.CutPrefix(link.URL, "https://orders.example.com/shared/orders/")
+	if !ok || path != link.Token {
+		t.Errorf("Unexpected link %+v", link)
+	}
+	if ttl := time.Until(link.ExpiresAt); ttl < time.Hour || ttl > 2*time.Hour {
+		t.Errorf("Expected the link to last 2h, got %v", ttl)
+	}
+
+	w = serve(router, http.MethodGet, "/shared/orders/"+link.Token, "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var shared map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &shared); err != nil {
+		t.Fatalf("Expected a JSON body, got %v", err)
+	}
+	if shared["id"] != "order-1" || shared["totalPrice"] != 115.0 || shared["statusLabel"] != "Pending" {
+		t.Errorf("Unexpected shared order %v", shared)
+	}
+	if _, ok := shared["customerId"]; ok {
+		t.Errorf("Expected no customer in a shared order, got %v", shared)
+	}
+
+	if w := serve(router, http.MethodPost, "/orders/order-1/share", `{"ttl":"720h"}`, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a ttl above the maximum, got %d", w.Code)
+	}
+	if w := serve(newOrderRouter(t, &mockOrderService{}, nil), http.MethodPost, "/orders/order-1/share", "", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without share links configured, got %d", w.Code)
+	}
+}
+
+func TestOrderListsStreamNDJSON(t *testing.T) {
+	ndjsonHeader := map[string]string{"Accept": "application/x-ndjson"}
+	lines := func(t *testing.T, w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/x-ndjson") {
+			t.Fatalf("Expected an NDJSON response, got %q: %s", got, w.Body)
+		}
+		out := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+		for _, line := range out {
+			if !json.Valid([]byte(line)) {
+				t.Errorf("Expected a JSON object per line, got %q", line)
+			}
+		}
+		return out
+	}
+
+	t.Run("product", func(t *testing.T) {
+		svc := &mockOrderService{streamed: 3}
+		w := serve(newOrderRouter(t, svc, nil), http.MethodGet, "/orders/product/p1", "", ndjsonHeader)
+		if w.Code != http.StatusOK || len(lines(t, w)) != 3 {
+			t.Errorf("Expected 3 lines, got %d: %s", w.Code, w.Body)
+		}
+		if len(svc.calls) != 1 || svc.calls[0] != "StreamOrders" || svc.filter.ProductID != "p1" {
+			t.Errorf("Expected a stream of p1, got %v %+v", svc.calls, svc.filter)
+		}
+	})
+
+	t.Run("search with offset and limit", func(t *testing.T) {
+		svc := &mockOrderService{streamed: 10}
+		w := serve(newOrderRouter(t, svc, nil), http.MethodGet, "/orders/search?status=PENDING&offset=2&limit=5", "", ndjsonHeader)
+		got := lines(t, w)
+		if len(got) != 5 || !strings.Contains(got[0], `"ID":"order-3"`) {
+			t.Errorf("Expected orders 3 to 7, got %v", got)
+		}
+		if svc.filter.Status != "PENDING" {
+			t.Errorf("Expected the filter to be passed on, got %+v", svc.filter)
+		}
+	})
+
+	t.Run("search is not capped", func(t *testing.T) {
+		svc := &mockOrderService{streamed: maxSearchLimit + 1}
+		w := serve(newOrderRouter(t, svc, nil), http.MethodGet, "/orders/search", "", ndjsonHeader)
+		if got := lines(t, w); len(got) != maxSearchLimit+1 {
+			t.Errorf("Expected every match, got %d lines", len(got))
+		}
+	})
+
+	t.Run("error before the first line", func(t *testing.T) {
+		svc := &mockOrderService{err: errors.New("database down")}
+		w := serve(newOrderRouter(t, svc, nil), http.MethodGet, "/orders/product/p1", "", ndjsonHeader)
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected 500, got %d", w.Code)
+		}
+	})
+
+	t.Run("error after the first line", func(t *testing.T) {
+		svc := &mockOrderService{streamed: 2, streamErr: errors.New("connection reset")}
+		w := serve(newOrderRouter(t, svc, nil), http.MethodGet, "/orders/product/p1", "", ndjsonHeader)
+		got := lines(t, w)
+		if w.Code != http.StatusOK || len(got) != 3 || got[2] != `{"error":"connection reset"}` {
+			t.Errorf("Expected the stream to end with the error, got %v", got)
+		}
+	})
+
+	t.Run("JSON unless asked", func(t *testing.T) {
+		svc := &mockOrderService{}
+		w := serve(newOrderRouter(t, svc, nil), http.MethodGet, "/orders/product/p1", "", map[string]string{"Accept": "application/json, */*"})
+		if len(svc.calls) != 1 || svc.calls[0] != "GetOrdersByProductID" || !strings.HasPrefix(w.Body.String(), "[") {
+			t.Errorf("Expected a JSON array, got %v %s", svc.calls, w.Body)
+		}
+	})
+}
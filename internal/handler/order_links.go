@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/domain"
+	"order-service/internal/links"
+	"order-service/internal/repository"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderLinks are the relations offered with an order. The order's
+// revision history serves as its timeline. Refunds and invoices are not
+// handled by this service, so there are no links for them.
+var orderLinks = links.NewBuilder(func(o *repository.Order) string { return o.ID },
+	links.Relation[*repository.Order]{Name: "self", Path: "/orders/{id}"},
+	links.Relation[*repository.Order]{Name: "timeline", Path: "/orders/{id}/revisions"},
+	links.Relation[*repository.Order]{Name: "confirm", Method: http.MethodPost, Path: "/orders/{id}/confirm",
+		When: statusIn(repository.StatusReserved, repository.StatusAwaitingPayment)},
+	links.Relation[*repository.Order]{Name: "reschedule", Method: http.MethodPost, Path: "/orders/{id}/reschedule",
+		When: statusIn(repository.StatusScheduled)},
+	links.Relation[*repository.Order]{Name: "cancel", Method: http.MethodPost, Path: "/orders/{id}/cancel",
+		When: func(o *repository.Order) bool { return domain.CanTransition(o.Status, domain.StatusCancelled) }},
+	links.Relation[*repository.Order]{Name: "reorder", Method: http.MethodPost, Path: "/orders/{id}/reorder"},
+	links.Relation[*repository.Order]{Name: "approve", Method: http.MethodPost, Path: "/admin/orders/{id}/approve",
+		Admin: true, When: statusIn(repository.StatusOnHold)},
+	links.Relation[*repository.Order]{Name: "reject", Method: http.MethodPost, Path: "/admin/orders/{id}/reject",
+		Admin: true, When: statusIn(repository.StatusOnHold)},
+)
+
+func statusIn(statuses ...string) func(*repository.Order) bool {
+	return func(o *repository.Order) bool { return slices.Contains(statuses, o.Status) }
+}
+
+// orderResponse is an order with the links of what the caller may do
+// with it next.
+type orderResponse struct {
+	*repository.Order
+	Links links.Links `json:"_links"`
+}
+
+// withLinks adds the links of order for the caller of c. Callers
+// authenticated as an admin also get the admin actions.
+func withLinks(c *gin.Context, order *repository.Order) orderResponse {
+	return orderResponse{Order: order, Links: buildOrderLinks(c, order)}
+}
+
+func buildOrderLinks(c *gin.Context, order *repository.Order) links.Links {
+	return orderLinks.Build(order, audit.ActorFromContext(c.Request.Context()) != "")
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"order-service/internal/i18n"
+	"order-service/internal/middleware"
+	"order-service/internal/timezone"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shareLinks are the settings of shared order links.
+type shareLinks struct {
+	signer     *middleware.ShareSigner
+	baseURL    string
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// SetShareLinks enables POST /orders/:id/share. Links are baseURL followed
+// by /shared/orders/<token>, or only the path when baseURL is empty. They
+// last defaultTTL unless the request asks for another time up to maxTTL.
+func (h *OrderHandler) SetShareLinks(signer *middleware.ShareSigner, baseURL string, defaultTTL, maxTTL time.Duration) {
+	h.share = &shareLinks{signer: signer, baseURL: strings.TrimSuffix(baseURL, "/"), defaultTTL: defaultTTL, maxTTL: maxTTL}
+}
+
+// ShareRequest asks for a shared link lasting TTL, a Go duration such as
+// "2h". It is optional.
+type ShareRequest struct {
+	TTL string `json:"ttl,omitempty"`
+}
+
+// ShareResponse is a link to an order that anyone holding it can open
+// until ExpiresAt.
+type ShareResponse struct {
+	URL       string    `json:"url"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ShareOrder creates a time-limited link to a read-only view of an order,
+// e.g. to send in a customer-support chat. The link is signed, not stored,
+// so it cannot be revoked before it expires.
+func (h *OrderHandler) ShareOrder(c *gin.Context) {
+	if h.share == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": middleware.ErrSharingDisabled.Error()})
+		return
+	}
+	var req ShareRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	ttl := h.share.defaultTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > h.share.maxTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ttl must be a duration between 1s and %s", h.share.maxTTL)})
+			return
+		}
+		ttl = d
+	}
+
+	order, err := h.service.GetOrder(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+	token, err := h.share.signer.Sign(order.ID, expires)
+	if errors.Is(err, middleware.ErrSharingDisabled) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	} else if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, ShareResponse{
+		URL:       h.share.baseURL + "/shared/orders/" + url.PathEscape(token),
+		Token:     token,
+		ExpiresAt: expires.In(timezone.FromContext(c.Request.Context())),
+	})
+}
+
+// sharedOrder is what a shared link shows of an order: its state and
+// amounts, without customer, payment or internal details.
+type sharedOrder struct {
+	ID              string     `json:"id"`
+	ProductID       string     `json:"productId"`
+	Quantity        int        `json:"quantity"`
+	Status          string     `json:"status"`
+	StatusLabel     string     `json:"statusLabel"`
+	Subtotal        float64    `json:"subtotal"`
+	DiscountAmount  float64    `json:"discountAmount"`
+	TaxAmount       float64    `json:"taxAmount"`
+	ShippingFee     float64    `json:"shippingFee"`
+	TotalPrice      float64    `json:"totalPrice"`
+	Currency        string     `json:"currency"`
+	DeliverAt       *time.Time `json:"deliverAt,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	StatusChangedAt *time.Time `json:"statusChangedAt,omitempty"`
+}
+
+// GetSharedOrder answers a shared link, after middleware.SharedOrder
+// checked its token.
+func (h *OrderHandler) GetSharedOrder(c *gin.Context) {
+	order, err := h.service.GetOrder(c.Request.Context(), middleware.SharedOrderID(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	ctx := c.Request.Context()
+	c.Header("Cache-Control", "private, no-store")
+	c.JSON(http.StatusOK, sharedOrder{
+		ID:              order.ID,
+		ProductID:       order.ProductID,
+		Quantity:        order.Quantity,
+		Status:          order.Status,
+		StatusLabel:     i18n.StatusLabel(ctx, order.Status),
+		Subtotal:        order.Subtotal,
+		DiscountAmount:  order.DiscountAmount,
+		TaxAmount:       order.TaxAmount,
+		ShippingFee:     order.ShippingFee,
+		TotalPrice:      order.TotalPrice,
+		Currency:        order.Currency,
+		DeliverAt:       order.DeliverAt,
+		CreatedAt:       order.CreatedAt.In(timezone.FromContext(ctx)),
+		StatusChangedAt: order.StatusChangedAt,
+	})
+}
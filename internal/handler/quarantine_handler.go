@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/consumer"
+	"order-service/internal/quarantine"
+	"order-service/internal/timezone"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultQuarantineLimit = 50
+	maxQuarantineLimit     = 500
+)
+
+type QuarantineHandler struct {
+	store    *quarantine.Store
+	handlers map[string]consumer.HandlerFunc
+	auditLog IAuditLog
+}
+
+// NewQuarantineHandler reprocesses messages with handlers, the event
+// handler of each consumed queue.
+func NewQuarantineHandler(store *quarantine.Store, handlers map[string]consumer.HandlerFunc, auditLog IAuditLog) *QuarantineHandler {
+	return &QuarantineHandler{store: store, handlers: handlers, auditLog: auditLog}
+}
+
+// List answers GET /admin/quarantine, optionally for one queue, class or
+// status.
+func (h *QuarantineHandler) List(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultQuarantineLimit)))
+	if err != nil || limit <= 0 || limit > maxQuarantineLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxQuarantineLimit)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	filter := quarantine.Filter{Queue: c.Query("queue"), Class: c.Query("class"), Status: c.Query("status")}
+	messages, err := h.store.List(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if messages == nil {
+		messages = []quarantine.Message{}
+	}
+	loc := timezone.FromContext(c.Request.Context())
+	for i := range messages {
+		localizeQuarantined(&messages[i], loc)
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *QuarantineHandler) Get(c *gin.Context) {
+	m, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeQuarantineError(c, err)
+		return
+	}
+	localizeQuarantined(m, timezone.FromContext(c.Request.Context()))
+	c.JSON(http.StatusOK, m)
+}
+
+// Reprocess runs a quarantined message through its handler again. A
+// message that fails again stays quarantined and is returned with the
+// failure. Both outcomes are audited, as a failed attempt is counted too.
+func (h *QuarantineHandler) Reprocess(c *gin.Context) {
+	m, err := h.store.Reprocess(c.Request.Context(), c.Param("id"), h.handlers, ClassifyEventError)
+	if m != nil {
+		recordAudit(c, h.auditLog, audit.ActionQuarantineReprocess, m.ID, nil, m)
+	}
+	if m != nil && err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "message": m})
+		return
+	}
+	if err != nil {
+		writeQuarantineError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+func (h *QuarantineHandler) Discard(c *gin.Context) {
+	m, err := h.store.Discard(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeQuarantineError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionQuarantineDiscard, m.ID, nil, m)
+	c.JSON(http.StatusOK, m)
+}
+
+func localizeQuarantined(m *quarantine.Message, loc *time.Location) {
+	m.QuarantinedAt = m.QuarantinedAt.In(loc)
+	if m.ReprocessedAt != nil {
+		reprocessedAt := m.ReprocessedAt.In(loc)
+		m.ReprocessedAt = &reprocessedAt
+	}
+}
+
+func writeQuarantineError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, quarantine.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, quarantine.ErrNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, quarantine.ErrUnknownQueue):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
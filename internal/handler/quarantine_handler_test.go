@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/consumer"
+	"order-service/internal/middleware"
+	"order-service/internal/quarantine"
+	"order-service/internal/timezone"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+func newQuarantineRouter(t *testing.T, db *gorm.DB, handlers map[string]consumer.HandlerFunc, auditLog IAuditLog) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	zones, err := timezone.NewResolver("UTC", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	router := gin.New()
+	router.Use(timezone.Middleware(zones))
+	h := NewQuarantineHandler(quarantine.NewStore(db), handlers, auditLog)
+	admin := router.Group("/admin", middleware.AdminAuth(testAdminToken))
+	admin.POST("/quarantine/:id/reprocess", h.Reprocess)
+	admin.POST("/quarantine/:id/discard", h.Discard)
+	return router
+}
+
+func TestQuarantineChangesAreAudited(t *testing.T) {
+	lock := quoted(`SELECT * FROM "quarantined_messages" WHERE id = $1`)
+	rows := func(status string) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "queue", "body", "status", "quarantined_at"}).
+			AddRow("m1", "stock", `{"data":{}}`, status, time.Now())
+	}
+	header := map[string]string{"Authorization": "Bearer " + testAdminToken, middleware.ActorHeader: "alice"}
+	fail := false
+	handlers := map[string]consumer.HandlerFunc{
+		"stock": func(context.Context, json.RawMessage) error {
+			if fail {
+				return errors.New("product-service unavailable")
+			}
+			return nil
+		},
+	}
+
+	db, mock := mockDB(t)
+	auditLog := &mockAuditLog{}
+	router := newQuarantineRouter(t, db, handlers, auditLog)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WillReturnRows(rows(quarantine.StatusQuarantined))
+	mock.ExpectExec(quoted(`UPDATE "quarantined_messages"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if w := serve(router, http.MethodPost, "/admin/quarantine/m1/reprocess", "", header); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	fail = true
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WillReturnRows(rows(quarantine.StatusQuarantined))
+	mock.ExpectExec(quoted(`UPDATE "quarantined_messages"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if w := serve(router, http.MethodPost, "/admin/quarantine/m1/reprocess", "", header); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d: %s", w.Code, w.Body)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WillReturnRows(rows(quarantine.StatusQuarantined))
+	mock.ExpectExec(quoted(`UPDATE "quarantined_messages"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if w := serve(router, http.MethodPost, "/admin/quarantine/m1/discard", "", header); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WillReturnRows(rows(quarantine.StatusDiscarded))
+	mock.ExpectRollback()
+	if w := serve(router, http.MethodPost, "/admin/quarantine/m1/discard", "", header); w.Code != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d: %s", w.Code, w.Body)
+	}
+
+	want := []string{audit.ActionQuarantineReprocess, audit.ActionQuarantineReprocess, audit.ActionQuarantineDiscard}
+	if len(auditLog.entries) != len(want) {
+		t.Fatalf("Expected %d audit entries, got %+v", len(want), auditLog.entries)
+	}
+	for i, e := range auditLog.entries {
+		if e.Action != want[i] || e.Actor != "alice" || e.Target != "m1" || e.After == nil {
+			t.Errorf("Expected a %s entry by alice for m1, got %+v", want[i], e)
+		}
+	}
+	if m, ok := auditLog.entries[1].After.(*quarantine.Message); !ok || m.Status != quarantine.StatusQuarantined || m.Error == "" {
+		t.Errorf("Expected the failed attempt to be audited with its error, got %+v", auditLog.entries[1].After)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/internal/reconciliation"
+	"order-service/internal/timezone"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDiscrepancyLimit = 50
+	maxDiscrepancyLimit     = 500
+)
+
+type ReconciliationHandler struct {
+	store *reconciliation.Store
+}
+
+func NewReconciliationHandler(store *reconciliation.Store) *ReconciliationHandler {
+	return &ReconciliationHandler{store: store}
+}
+
+// Discrepancies answers GET /admin/reconciliation, optionally for one kind
+// or order. resolved=true lists the ones that were fixed instead of the
+// open ones.
+func (h *ReconciliationHandler) Discrepancies(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultDiscrepancyLimit)))
+	if err != nil || limit <= 0 || limit > maxDiscrepancyLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxDiscrepancyLimit)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	filter := reconciliation.Filter{
+		Kind:     c.Query("kind"),
+		OrderID:  c.Query("orderId"),
+		Resolved: c.Query("resolved") == "true",
+	}
+	discrepancies, err := h.store.List(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if discrepancies == nil {
+		discrepancies = []reconciliation.Discrepancy{}
+	}
+	loc := timezone.FromContext(c.Request.Context())
+	for i := range discrepancies {
+		d := &discrepancies[i]
+		d.DetectedAt, d.LastSeenAt = d.DetectedAt.In(loc), d.LastSeenAt.In(loc)
+		if d.ResolvedAt != nil {
+			resolvedAt := d.ResolvedAt.In(loc)
+			d.ResolvedAt = &resolvedAt
+		}
+	}
+	c.JSON(http.StatusOK, discrepancies)
+}
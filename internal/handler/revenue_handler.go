@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"order-service/internal/audit"
+	"order-service/internal/middleware"
+	"order-service/internal/revenue"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RevenueHandler struct {
+	store    *revenue.Store
+	auditLog IAuditLog
+}
+
+func NewRevenueHandler(store *revenue.Store, auditLog IAuditLog) *RevenueHandler {
+	return &RevenueHandler{store: store, auditLog: auditLog}
+}
+
+// Report answers GET /admin/revenue/:period as JSON, or as CSV with
+// format=csv.
+func (h *RevenueHandler) Report(c *gin.Context) {
+	report, err := h.store.Report(c.Request.Context(), c.Param("period"))
+	if err != nil {
+		writeRevenueError(c, err)
+		return
+	}
+	if c.Query("format") != "csv" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="revenue-`+report.Period+`.csv"`)
+	c.Header("Content-Type", "text/csv")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"period", "entry", "source_period", "currency", "orders", "recognized", "deferred", "cancelled"})
+	entry := "computed"
+	if report.Closed {
+		entry = "closed"
+	}
+	for _, f := range report.Figures {
+		w.Write(revenueRow(report.Period, entry, report.Period, f))
+	}
+	for _, a := range report.Adjustments {
+		w.Write(revenueRow(report.Period, "adjustment", a.Period, a.Delta()))
+	}
+	w.Flush()
+}
+
+func revenueRow(period, entry, source string, f revenue.Figures) []string {
+	amount := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	return []string{period, entry, source, f.Currency, strconv.FormatInt(f.Orders, 10),
+		amount(f.Recognized), amount(f.Deferred), amount(f.Cancelled)}
+}
+
+// Close answers POST /admin/revenue/:period/close.
+func (h *RevenueHandler) Close(c *gin.Context) {
+	period := c.Param("period")
+	closed, err := h.store.Close(c.Request.Context(), period, middleware.Actor(c))
+	if err != nil {
+		writeRevenueError(c, err)
+		return
+	}
+	recordAudit(c, h.auditLog, audit.ActionRevenueClose, period, nil, closed)
+	c.JSON(http.StatusOK, closed)
+}
+
+func writeRevenueError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, revenue.ErrInvalidPeriod):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, revenue.ErrPeriodNotOver), errors.Is(err, revenue.ErrPeriodClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
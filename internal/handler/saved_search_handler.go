@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"order-service/internal/middleware"
+	"order-service/internal/savedsearch"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SavedSearchHandler serves the named order searches of admin users. Each
+// search belongs to the actor who saved it; shared ones are visible to
+// every admin.
+type SavedSearchHandler struct {
+	store  *savedsearch.Store
+	orders *OrderHandler
+}
+
+func NewSavedSearchHandler(store *savedsearch.Store, orders *OrderHandler) *SavedSearchHandler {
+	return &SavedSearchHandler{store: store, orders: orders}
+}
+
+func (h *SavedSearchHandler) Create(c *gin.Context) {
+	var s savedsearch.Search
+	if err := c.ShouldBindJSON(&s); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	s.Owner = middleware.Actor(c)
+	s.CreatedAt = time.Time{}
+	if err := h.store.Create(c.Request.Context(), &s); err != nil {
+		writeSavedSearchError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, s)
+}
+
+func (h *SavedSearchHandler) List(c *gin.Context) {
+	searches, err := h.store.List(c.Request.Context(), middleware.Actor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if searches == nil {
+		searches = []savedsearch.Search{}
+	}
+	c.JSON(http.StatusOK, searches)
+}
+
+func (h *SavedSearchHandler) Get(c *gin.Context) {
+	s, err := h.store.Get(c.Request.Context(), c.Param("id"), middleware.Actor(c))
+	if err != nil {
+		writeSavedSearchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *SavedSearchHandler) Delete(c *gin.Context) {
+	s, err := h.store.Delete(c.Request.Context(), c.Param("id"), middleware.Actor(c))
+	if err != nil {
+		writeSavedSearchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// Run answers with the orders the search finds, like GET /orders/search
+// with its query. Parameters of the request replace saved ones, e.g. to
+// page through the results.
+func (h *SavedSearchHandler) Run(c *gin.Context) {
+	s, err := h.store.Get(c.Request.Context(), c.Param("id"), middleware.Actor(c))
+	if err != nil {
+		writeSavedSearchError(c, err)
+		return
+	}
+	query := s.Values()
+	for key, values := range c.Request.URL.Query() {
+		query[key] = values
+	}
+	h.orders.searchOrders(c, query)
+}
+
+func writeSavedSearchError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, savedsearch.ErrInvalidSearch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, savedsearch.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, savedsearch.ErrNotOwner):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, savedsearch.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
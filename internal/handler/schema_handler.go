@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"order-service/internal/schema"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SchemaHandler struct {
+	registry *schema.Registry
+}
+
+func NewSchemaHandler(registry *schema.Registry) *SchemaHandler {
+	return &SchemaHandler{registry: registry}
+}
+
+// List answers GET /schemas with the schema of every pattern that has
+// one, keyed by pattern.
+func (h *SchemaHandler) List(c *gin.Context) {
+	schemas := make(map[string]interface{})
+	for _, pattern := range h.registry.Patterns() {
+		schemas[pattern], _ = h.registry.Raw(pattern)
+	}
+	c.JSON(http.StatusOK, schemas)
+}
+
+func (h *SchemaHandler) Get(c *gin.Context) {
+	raw, ok := h.registry.Raw(c.Param("pattern"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no schema for pattern " + c.Param("pattern")})
+		return
+	}
+	c.Data(http.StatusOK, "application/schema+json", raw)
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/internal/repository"
+	"order-service/internal/sla"
+	"order-service/internal/timezone"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultBreachLimit = 50
+	maxBreachLimit     = 500
+)
+
+type SLAHandler struct {
+	monitor *sla.Monitor
+}
+
+func NewSLAHandler(monitor *sla.Monitor) *SLAHandler {
+	return &SLAHandler{monitor: monitor}
+}
+
+// Breaches answers GET /admin/sla/breaches, optionally for one status.
+func (h *SLAHandler) Breaches(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultBreachLimit)))
+	if err != nil || limit <= 0 || limit > maxBreachLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxBreachLimit)})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	orders, err := h.monitor.Breaches(c.Request.Context(), c.Query("status"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if orders == nil {
+		orders = []repository.Order{}
+	}
+	loc := timezone.FromContext(c.Request.Context())
+	for i := range orders {
+		orders[i].CreatedAt = orders[i].CreatedAt.In(loc)
+	}
+	c.JSON(http.StatusOK, orders)
+}
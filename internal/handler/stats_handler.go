@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/internal/leaderboard"
+	"order-service/internal/stats"
+	"order-service/internal/timezone"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultStatsWindow       = "30d"
+	defaultTopProductsWindow = "24h"
+	defaultTopProductsLimit  = 10
+	maxTopProductsLimit      = 100
+)
+
+type StatsHandler struct {
+	store       *stats.Store
+	leaderboard *leaderboard.Leaderboard
+}
+
+func NewStatsHandler(store *stats.Store, lb *leaderboard.Leaderboard) *StatsHandler {
+	return &StatsHandler{store: store, leaderboard: lb}
+}
+
+// ProductOrderStats answers GET /products/:productId/order-stats from the
+// daily aggregates, so it never scans the orders table.
+func (h *StatsHandler) ProductOrderStats(c *gin.Context) {
+	window := c.DefaultQuery("window", defaultStatsWindow)
+	if _, ok := stats.Windows[window]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be one of 1d, 7d, 30d, 90d, 365d, all"})
+		return
+	}
+	out, err := h.store.ForProduct(c.Request.Context(), c.Param("productId"), window)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	loc := timezone.FromContext(c.Request.Context())
+	if !out.UpdatedAt.IsZero() {
+		out.UpdatedAt = out.UpdatedAt.In(loc)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// CustomerLTV answers GET /customers/:id/ltv with the customer's lifetime
+// value from the daily aggregates.
+func (h *StatsHandler) CustomerLTV(c *gin.Context) {
+	out, err := h.store.CustomerLTV(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	loc := timezone.FromContext(c.Request.Context())
+	for _, t := range []*time.Time{out.FirstOrderAt, out.LastOrderAt, &out.UpdatedAt} {
+		if t != nil && !t.IsZero() {
+			*t = t.In(loc)
+		}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// TopProducts answers GET /reports/top-products with the products that
+// sold the most units in window, from Redis.
+func (h *StatsHandler) TopProducts(c *gin.Context) {
+	window := c.DefaultQuery("window", defaultTopProductsWindow)
+	if _, ok := leaderboard.Windows[window]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be one of 24h, 7d, 30d"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultTopProductsLimit)))
+	if err != nil || limit <= 0 || limit > maxTopProductsLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxTopProductsLimit)})
+		return
+	}
+	entries, err := h.leaderboard.Top(c.Request.Context(), window, limit)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"window": window, "products": entries})
+}
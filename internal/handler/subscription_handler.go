@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"order-service/internal/subscription"
+	"order-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SubscriptionHandler struct {
+	store *subscription.Store
+}
+
+func NewSubscriptionHandler(store *subscription.Store) *SubscriptionHandler {
+	return &SubscriptionHandler{store: store}
+}
+
+func (h *SubscriptionHandler) Create(c *gin.Context) {
+	var sub subscription.Subscription
+	if err := c.ShouldBindJSON(&sub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub.TenantID = tenant.FromContext(c.Request.Context())
+
+	if err := h.store.Create(c.Request.Context(), &sub); err != nil {
+		writeSubscriptionError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, sub)
+}
+
+func (h *SubscriptionHandler) List(c *gin.Context) {
+	customerID := c.Query("customerId")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customerId is required"})
+		return
+	}
+	subs, err := h.store.List(c.Request.Context(), customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if subs == nil {
+		subs = []subscription.Subscription{}
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+func (h *SubscriptionHandler) Get(c *gin.Context) {
+	sub, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeSubscriptionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func (h *SubscriptionHandler) Update(c *gin.Context) {
+	var changes subscription.Changes
+	if err := c.ShouldBindJSON(&changes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sub, err := h.store.Update(c.Request.Context(), c.Param("id"), changes)
+	if err != nil {
+		writeSubscriptionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func (h *SubscriptionHandler) Cancel(c *gin.Context) {
+	sub, err := h.store.Cancel(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeSubscriptionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+func writeSubscriptionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, subscription.ErrInvalidSubscription):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, subscription.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, subscription.ErrCancelled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
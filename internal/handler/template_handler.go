@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"order-service/internal/ordertemplate"
+	"order-service/internal/service"
+	"order-service/internal/tenant"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TemplateHandler struct {
+	store  *ordertemplate.Store
+	orders service.IOrderService
+}
+
+func NewTemplateHandler(store *ordertemplate.Store, orders service.IOrderService) *TemplateHandler {
+	return &TemplateHandler{store: store, orders: orders}
+}
+
+type createTemplateRequest struct {
+	ordertemplate.Template
+	// OrderID fills the template from an order: its customer, product,
+	// quantity, region, country and currency.
+	OrderID string `json:"orderId,omitempty"`
+}
+
+func (h *TemplateHandler) Create(c *gin.Context) {
+	var req createTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t := req.Template
+	t.TenantID = tenant.FromContext(c.Request.Context())
+	t.CreatedAt = time.Time{}
+	if req.OrderID != "" {
+		order, err := h.orders.GetOrder(c.Request.Context(), req.OrderID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if t.CustomerID != "" && t.CustomerID != order.CustomerID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the order belongs to another customer"})
+			return
+		}
+		t.CustomerID = order.CustomerID
+		t.ProductID = order.ProductID
+		t.Quantity = order.Quantity
+		t.Region = order.Region
+		t.Country = order.Country
+		t.Currency = order.ConvertedCurrency
+	}
+
+	if err := h.store.Create(c.Request.Context(), &t); err != nil {
+		writeTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, t)
+}
+
+func (h *TemplateHandler) List(c *gin.Context) {
+	customerID := c.Query("customerId")
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "customerId is required"})
+		return
+	}
+	templates, err := h.store.List(c.Request.Context(), customerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if templates == nil {
+		templates = []ordertemplate.Template{}
+	}
+	c.JSON(http.StatusOK, templates)
+}
+
+func (h *TemplateHandler) Get(c *gin.Context) {
+	t, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+func (h *TemplateHandler) Delete(c *gin.Context) {
+	t, err := h.store.Delete(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeTemplateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// PlaceOrder creates an order from a template, priced and checked like any
+// new order.
+func (h *TemplateHandler) PlaceOrder(c *gin.Context) {
+	t, err := h.store.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeTemplateError(c, err)
+		return
+	}
+	order, err := h.orders.CreateOrder(c.Request.Context(), service.CreateOrderRequest{
+		ProductID:    t.ProductID,
+		Quantity:     t.Quantity,
+		CustomerID:   t.CustomerID,
+		DiscountCode: t.DiscountCode,
+		Currency:     t.Currency,
+		Region:       t.Region,
+		Country:      t.Country,
+		ClientIP:     c.ClientIP(),
+		TenantID:     t.TenantID,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, withLinks(c, order))
+}
+
+func writeTemplateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ordertemplate.ErrInvalidTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ordertemplate.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, ordertemplate.ErrDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"order-service/internal/quota"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUsageDays caps the period of a usage report.
+const maxUsageDays = 366
+
+type UsageHandler struct {
+	quotas *quota.Quotas
+}
+
+func NewUsageHandler(quotas *quota.Quotas) *UsageHandler {
+	return &UsageHandler{quotas: quotas}
+}
+
+// Report answers GET /admin/usage with the requests of every subject, or
+// of ?subject= (e.g. tenant:acme), between the UTC days from and to. The
+// period defaults to the current month.
+func (h *UsageHandler) Report(c *gin.Context) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	filter := quota.Filter{
+		Subject: c.Query("subject"),
+		From:    time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC),
+		To:      today,
+	}
+	for key, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		v := c.Query(key)
+		if v == "" {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a YYYY-MM-DD date", key)})
+			return
+		}
+		*dst = day
+	}
+	if filter.To.Before(filter.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+		return
+	}
+	if filter.To.Sub(filter.From) >= maxUsageDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("the period must not be longer than %d days", maxUsageDays)})
+		return
+	}
+
+	usage, err := h.quotas.Usage(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"order-service/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type webhookEvent struct {
+	Type string          `json:"type" binding:"required"`
+	Data json.RawMessage `json:"data"`
+}
+
+// WebhookHandler receives provider webhooks verified by
+// middleware.VerifyWebhook and hands them to the same handlers as the
+// matching bus events.
+type WebhookHandler struct {
+	handlers map[string]func(ctx context.Context, data json.RawMessage) error
+}
+
+func NewWebhookHandler(events *EventHandler) *WebhookHandler {
+	return &WebhookHandler{handlers: map[string]func(context.Context, json.RawMessage) error{
+		"payment/installment.paid": events.InstallmentPaid,
+	}}
+}
+
+// Receive answers 204 once the event is handled and 202 for event types
+// the service does not act on, so providers stop retrying them.
+func (h *WebhookHandler) Receive(c *gin.Context) {
+	var ev webhookEvent
+	if err := c.ShouldBindJSON(&ev); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	provider := middleware.WebhookProviderName(c)
+	handle, ok := h.handlers[provider+"/"+ev.Type]
+	if !ok {
+		log.Printf("Ignoring %s webhook of type %s", provider, ev.Type)
+		c.Status(http.StatusAccepted)
+		return
+	}
+	if err := handle(c.Request.Context(), ev.Data); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
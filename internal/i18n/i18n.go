@@ -0,0 +1,122 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultLanguage is used when Accept-Language names nothing we have a
+// catalog for. Its catalog is also the fallback for missing keys.
+const DefaultLanguage = "en"
+
+//go:embed catalogs/*.json
+var catalogFS embed.FS
+
+// catalogs maps a language to its messages, keyed by error code or by
+// "status.<STATUS>" for order status labels.
+var catalogs = mustLoad()
+
+func mustLoad() map[string]map[string]string {
+	entries, err := catalogFS.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+	out := make(map[string]map[string]string, len(entries))
+	for _, e := range entries {
+		data, err := catalogFS.ReadFile(path.Join("catalogs", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic("invalid catalog " + e.Name() + ": " + err.Error())
+		}
+		out[strings.TrimSuffix(e.Name(), ".json")] = messages
+	}
+	return out
+}
+
+type contextKey struct{}
+
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, contextKey{}, lang)
+}
+
+// FromContext returns the language of the current request, or
+// DefaultLanguage if none was set.
+func FromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(contextKey{}).(string); ok {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// Middleware picks the response language from the Accept-Language header
+// and stores it on the request context.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := Match(c.GetHeader("Accept-Language"))
+		c.Request = c.Request.WithContext(WithLanguage(c.Request.Context(), lang))
+		c.Header("Content-Language", lang)
+		c.Next()
+	}
+}
+
+// Match returns the best supported language for an Accept-Language header
+// such as "id-ID,id;q=0.9,en;q=0.8". Region subtags fall back to their
+// base language.
+func Match(header string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if tag != "" && q > 0 {
+			candidates = append(candidates, candidate{strings.ToLower(tag), q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if _, ok := catalogs[c.tag]; ok {
+			return c.tag
+		}
+		base, _, _ := strings.Cut(c.tag, "-")
+		if _, ok := catalogs[base]; ok {
+			return base
+		}
+	}
+	return DefaultLanguage
+}
+
+// T translates key into the language of ctx, falling back to the default
+// catalog and then to fallback.
+func T(ctx context.Context, key, fallback string) string {
+	if msg, ok := catalogs[FromContext(ctx)][key]; ok {
+		return msg
+	}
+	if msg, ok := catalogs[DefaultLanguage][key]; ok {
+		return msg
+	}
+	return fallback
+}
+
+// StatusLabel is the human readable label of an order status.
+func StatusLabel(ctx context.Context, status string) string {
+	return T(ctx, "status."+status, status)
+}
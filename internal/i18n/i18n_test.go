@@ -0,0 +1,47 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"", "en"},
+		{"id", "id"},
+		{"id-ID,id;q=0.9,en;q=0.8", "id"},
+		{"fr-FR,en;q=0.5,id;q=0.7", "id"},
+		{"fr, de", "en"},
+		{"id;q=0", "en"},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.header); got != tt.want {
+			t.Errorf("Match(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	for lang, messages := range catalogs {
+		for key := range catalogs[DefaultLanguage] {
+			if _, ok := messages[key]; !ok {
+				t.Errorf("Catalog %s is missing %s", lang, key)
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	ctx := WithLanguage(context.Background(), "id")
+	if got := T(ctx, "ORDER_NOT_FOUND", ""); got != "Pesanan tidak ditemukan." {
+		t.Errorf("Unexpected translation %q", got)
+	}
+	if got := T(ctx, "UNKNOWN_CODE", "fallback"); got != "fallback" {
+		t.Errorf("Expected fallback, got %q", got)
+	}
+	if got := StatusLabel(context.Background(), "AWAITING_PAYMENT"); got != "Awaiting payment" {
+		t.Errorf("Unexpected status label %q", got)
+	}
+}
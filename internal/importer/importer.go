@@ -0,0 +1,243 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"order-service/internal/jobs"
+	"order-service/internal/repository"
+	"order-service/internal/service"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// JobType identifies import jobs in the jobs table.
+const JobType = "order-import"
+
+var ErrUploadFailed = errors.New("failed to read upload")
+
+// OrderImporter stores a batch of orders, reporting per-row failures at the
+// index of the failed request.
+type OrderImporter interface {
+	ImportOrders(ctx context.Context, reqs []service.CreateOrderRequest) ([]repository.Order, []error)
+}
+
+// Importer turns uploaded CSV files into orders in the background. The
+// upload is spooled to a temporary file first so the request can return
+// as soon as the file is received.
+type Importer struct {
+	jobs      *jobs.Store
+	orders    OrderImporter
+	batchSize int
+	dir       string
+}
+
+func New(store *jobs.Store, orders OrderImporter, batchSize int, dir string) *Importer {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Importer{jobs: store, orders: orders, batchSize: batchSize, dir: dir}
+}
+
+// Start spools src to disk and starts a job processing it. Rows are
+// created for tenantID.
+func (im *Importer) Start(ctx context.Context, src io.Reader, actor, tenantID string) (*jobs.Job, error) {
+	f, err := os.CreateTemp(im.dir, "order-import-*.csv")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("%w: %w", ErrUploadFailed, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	job, err := im.jobs.Start(ctx, JobType, actor, func(ctx context.Context, p *jobs.Progress) (string, error) {
+		defer os.Remove(f.Name())
+		return "", im.run(ctx, p, f.Name(), tenantID)
+	})
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return job, nil
+}
+
+func (im *Importer) run(ctx context.Context, p *jobs.Progress, path, tenantID string) error {
+	total, err := countRows(path)
+	if err != nil {
+		return err
+	}
+	p.SetTotal(total)
+	if err := p.Save(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return errors.New("file is empty")
+	} else if err != nil {
+		return err
+	}
+	cols, err := parseHeader(header)
+	if err != nil {
+		return err
+	}
+
+	var (
+		reqs []service.CreateOrderRequest
+		rows []int
+	)
+	flush := func() error {
+		if len(reqs) == 0 {
+			return nil
+		}
+		orders, errs := im.orders.ImportOrders(ctx, reqs)
+		p.Advance(len(orders))
+		for i, err := range errs {
+			if err != nil {
+				p.Fail(rowRef(rows[i]), err)
+			}
+		}
+		reqs, rows = reqs[:0], rows[:0]
+		return p.Save()
+	}
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return err
+			}
+			p.Fail(rowRef(parseErr.StartLine), err)
+			continue
+		}
+
+		line, _ := r.FieldPos(0)
+		req, err := cols.request(record)
+		if err != nil {
+			p.Fail(rowRef(line), err)
+			continue
+		}
+		req.TenantID = tenantID
+		reqs = append(reqs, req)
+		rows = append(rows, line)
+		if len(reqs) >= im.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// rowRef names a CSV record by its 1-based line in the file, counting the
+// header.
+func rowRef(line int) string {
+	return "row " + strconv.Itoa(line)
+}
+
+// countRows counts the data records in the file so progress can be
+// reported against a total.
+func countRows(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+	n := 0
+	for {
+		_, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return 0, err
+		}
+		n++
+	}
+	if n > 0 {
+		n-- // header
+	}
+	return n, nil
+}
+
+// columns maps the known CSV columns to their position in a record.
+type columns map[string]int
+
+const (
+	colProductID    = "productid"
+	colQuantity     = "quantity"
+	colCustomerID   = "customerid"
+	colDiscountCode = "discountcode"
+	colCurrency     = "currency"
+)
+
+func parseHeader(header []string) (columns, error) {
+	cols := make(columns)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case colProductID, colQuantity, colCustomerID, colDiscountCode, colCurrency:
+			cols[name] = i
+		default:
+			return nil, fmt.Errorf("unknown column %q", header[i])
+		}
+	}
+	for _, required := range []string{colProductID, colQuantity} {
+		if _, ok := cols[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+	return cols, nil
+}
+
+func (c columns) value(record []string, name string) string {
+	i, ok := c[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func (c columns) request(record []string) (service.CreateOrderRequest, error) {
+	req := service.CreateOrderRequest{
+		ProductID:    c.value(record, colProductID),
+		CustomerID:   c.value(record, colCustomerID),
+		DiscountCode: c.value(record, colDiscountCode),
+		Currency:     c.value(record, colCurrency),
+	}
+	if req.ProductID == "" {
+		return req, errors.New("productId is required")
+	}
+	qty, err := strconv.Atoi(c.value(record, colQuantity))
+	if err != nil || qty <= 0 {
+		return req, fmt.Errorf("invalid quantity %q", c.value(record, colQuantity))
+	}
+	req.Quantity = qty
+	return req, nil
+}
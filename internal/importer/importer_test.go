@@ -0,0 +1,52 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseHeader(t *testing.T) {
+	cols, err := parseHeader([]string{"ProductId", " quantity ", "customerId"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	req, err := cols.request([]string{"p1", "3", "c1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.ProductID != "p1" || req.Quantity != 3 || req.CustomerID != "c1" {
+		t.Errorf("Expected p1/3/c1, got %s/%d/%s", req.ProductID, req.Quantity, req.CustomerID)
+	}
+
+	if _, err := parseHeader([]string{"productId"}); err == nil {
+		t.Error("Expected an error for a missing quantity column, got nil")
+	}
+	if _, err := parseHeader([]string{"productId", "quantity", "price"}); err == nil {
+		t.Error("Expected an error for an unknown column, got nil")
+	}
+}
+
+func TestColumnsRequestRejectsInvalidQuantity(t *testing.T) {
+	cols, _ := parseHeader([]string{"productId", "quantity"})
+	for _, qty := range []string{"", "abc", "0", "-1"} {
+		if _, err := cols.request([]string{"p1", qty}); err == nil {
+			t.Errorf("Expected an error for quantity %q, got nil", qty)
+		}
+	}
+}
+
+func TestCountRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orders.csv")
+	data := "productId,quantity\np1,1\n\"p2\nwrapped\",2\np3,3\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	n, err := countRows(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Expected 3 rows, got %d", n)
+	}
+}
@@ -0,0 +1,218 @@
+// Package inventory keeps a local ledger of the stock held for orders, so
+// the service can tell how much of a product is committed without asking
+// product-service. The ledger is reconciled against product-service's
+// stock in the background.
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const OvercommittedPattern = "inventory.overcommitted"
+
+var (
+	ErrInvalidHold = errors.New("invalid inventory reservation")
+	ErrNotFound    = errors.New("inventory reservation not found")
+)
+
+// Hold is stock reserved for an order until ExpiresAt. An order holds at
+// most one reservation.
+type Hold struct {
+	OrderID   string    `gorm:"primaryKey" json:"orderId"`
+	ProductID string    `gorm:"not null;index" json:"productId"`
+	Quantity  int       `gorm:"not null" json:"quantity"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (Hold) TableName() string { return "inventory_reservations" }
+
+func (h Hold) validate(now time.Time) error {
+	if h.OrderID == "" || h.ProductID == "" {
+		return fmt.Errorf("%w: orderId and productId are required", ErrInvalidHold)
+	}
+	if h.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidHold)
+	}
+	if !h.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expiresAt must be in the future", ErrInvalidHold)
+	}
+	return nil
+}
+
+// Level is what the last reconciliation found for a product: the stock
+// product-service reported and how much of it the ledger held.
+type Level struct {
+	ProductID    string    `gorm:"primaryKey" json:"productId"`
+	Available    int       `json:"available"`
+	Committed    int       `json:"committed"`
+	ReconciledAt time.Time `json:"reconciledAt"`
+}
+
+func (Level) TableName() string { return "inventory_levels" }
+
+// Commitment is how much of a product the ledger holds now, with the stock
+// product-service reported at the last reconciliation, if any.
+type Commitment struct {
+	ProductID    string     `json:"productId"`
+	Committed    int        `json:"committed"`
+	Holds        int        `json:"holds"`
+	Available    *int       `json:"available,omitempty"`
+	ReconciledAt *time.Time `json:"reconciledAt,omitempty"`
+}
+
+type IPublisher interface {
+	Publish(pattern string, data interface{}) error
+}
+
+// StockFunc returns the stock product-service reports for a product.
+type StockFunc func(ctx context.Context, productID string) (int, error)
+
+// Ledger keeps holds in Postgres.
+type Ledger struct {
+	db        *gorm.DB
+	publisher IPublisher
+	now       func() time.Time
+}
+
+func NewLedger(db *gorm.DB, publisher IPublisher) *Ledger {
+	return &Ledger{db: db, publisher: publisher, now: time.Now}
+}
+
+// Reserve stores a hold, replacing the order's previous one.
+func (l *Ledger) Reserve(ctx context.Context, hold *Hold) error {
+	if err := hold.validate(l.now()); err != nil {
+		return err
+	}
+	hold.ExpiresAt = hold.ExpiresAt.UTC()
+	return l.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "order_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"product_id", "quantity", "expires_at", "updated_at"}),
+	}).Create(hold).Error
+}
+
+// Get returns the order's hold, expired or not.
+func (l *Ledger) Get(ctx context.Context, orderID string) (*Hold, error) {
+	var hold Hold
+	err := l.db.WithContext(ctx).First(&hold, "order_id = ?", orderID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &hold, err
+}
+
+// Extend moves the expiry of a hold that has not expired yet.
+func (l *Ledger) Extend(ctx context.Context, orderID string, until time.Time) (*Hold, error) {
+	now := l.now()
+	if !until.After(now) {
+		return nil, fmt.Errorf("%w: expiresAt must be in the future", ErrInvalidHold)
+	}
+	res := l.db.WithContext(ctx).Model(&Hold{}).
+		Where("order_id = ? AND expires_at > ?", orderID, now).
+		Updates(map[string]interface{}{"expires_at": until.UTC(), "updated_at": now.UTC()})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return l.Get(ctx, orderID)
+}
+
+// Release gives up the order's hold and returns it.
+func (l *Ledger) Release(ctx context.Context, orderID string) (*Hold, error) {
+	var holds []Hold
+	res := l.db.WithContext(ctx).Clauses(clause.Returning{}).Where("order_id = ?", orderID).Delete(&holds)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if len(holds) == 0 {
+		return nil, ErrNotFound
+	}
+	return &holds[0], nil
+}
+
+// Committed returns how much of a product unexpired holds reserve.
+func (l *Ledger) Committed(ctx context.Context, productID string) (int, error) {
+	var committed int
+	err := l.db.WithContext(ctx).Model(&Hold{}).
+		Where("product_id = ? AND expires_at > ?", productID, l.now()).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&committed).Error
+	return committed, err
+}
+
+// Commitment returns the product's committed stock and its last
+// reconciled level.
+func (l *Ledger) Commitment(ctx context.Context, productID string) (*Commitment, error) {
+	c := &Commitment{ProductID: productID}
+	err := l.db.WithContext(ctx).Model(&Hold{}).
+		Where("product_id = ? AND expires_at > ?", productID, l.now()).
+		Select("COALESCE(SUM(quantity), 0) AS committed, COUNT(*) AS holds").
+		Scan(c).Error
+	if err != nil {
+		return nil, err
+	}
+	var level Level
+	err = l.db.WithContext(ctx).First(&level, "product_id = ?", productID).Error
+	if err == nil {
+		c.Available, c.ReconciledAt = &level.Available, &level.ReconciledAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reconcile drops expired holds, then compares what the ledger holds of
+// every product with the stock stock reports for it. It stores the levels
+// and publishes inventory.overcommitted for products whose holds exceed
+// their stock. It returns how many products it reconciled.
+func (l *Ledger) Reconcile(ctx context.Context, stock StockFunc) (int, error) {
+	now := l.now()
+	if err := l.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&Hold{}).Error; err != nil {
+		return 0, err
+	}
+
+	var committed []struct {
+		ProductID string
+		Committed int
+	}
+	err := l.db.WithContext(ctx).Model(&Hold{}).
+		Select("product_id, SUM(quantity) AS committed").
+		Group("product_id").
+		Scan(&committed).Error
+	if err != nil {
+		return 0, err
+	}
+
+	reconciled := 0
+	for _, c := range committed {
+		available, err := stock(ctx, c.ProductID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return reconciled, ctx.Err()
+			}
+			log.Printf("Failed to fetch stock of product %s: %v", c.ProductID, err)
+			continue
+		}
+		level := Level{ProductID: c.ProductID, Available: available, Committed: c.Committed, ReconciledAt: now.UTC()}
+		if err := l.db.WithContext(ctx).Save(&level).Error; err != nil {
+			return reconciled, err
+		}
+		reconciled++
+		if c.Committed > available {
+			if err := l.publisher.Publish(OvercommittedPattern, level); err != nil {
+				log.Printf("Failed to publish %s for product %s: %v", OvercommittedPattern, c.ProductID, err)
+			}
+		}
+	}
+	return reconciled, nil
+}
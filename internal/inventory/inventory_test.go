@@ -0,0 +1,30 @@
+package inventory
+
+import (
+	"errors"
+	"order-service/internal/golden"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	valid := Hold{OrderID: "o1", ProductID: "p1", Quantity: 2, ExpiresAt: now.Add(time.Minute)}
+	if err := valid.validate(now); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	for name, hold := range map[string]Hold{
+		"no order":    {ProductID: "p1", Quantity: 1, ExpiresAt: now.Add(time.Minute)},
+		"no quantity": {OrderID: "o1", ProductID: "p1", ExpiresAt: now.Add(time.Minute)},
+		"expired":     {OrderID: "o1", ProductID: "p1", Quantity: 1, ExpiresAt: now},
+	} {
+		if err := hold.validate(now); !errors.Is(err, ErrInvalidHold) {
+			t.Errorf("%s: expected ErrInvalidHold, got %v", name, err)
+		}
+	}
+}
+
+func TestOvercommittedPayload(t *testing.T) {
+	level := Level{ProductID: "p1", Available: 3, Committed: 5, ReconciledAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)}
+	golden.JSON(t, "events/"+OvercommittedPattern, level)
+}
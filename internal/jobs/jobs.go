@@ -0,0 +1,201 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"order-service/internal/errreport"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	StateQueued    = "QUEUED"
+	StateRunning   = "RUNNING"
+	StateSucceeded = "SUCCEEDED"
+	StateFailed    = "FAILED"
+)
+
+// maxErrorDetails caps the item errors kept on a job. Failed still counts
+// every failure.
+const maxErrorDetails = 100
+
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrorDetail explains why one item of a job failed. Ref identifies the
+// item in terms of the job's input, e.g. a CSV row.
+type ErrorDetail struct {
+	Ref   string `json:"ref"`
+	Error string `json:"error"`
+}
+
+// Job is a long-running background task. Total is 0 until the task knows
+// how many items it will process.
+type Job struct {
+	ID             string        `gorm:"type:uuid;primaryKey" json:"id"`
+	Type           string        `gorm:"not null;index" json:"type"`
+	State          string        `gorm:"not null" json:"state"`
+	Total          int           `json:"total"`
+	Processed      int           `json:"processed"`
+	Failed         int           `json:"failed"`
+	Errors         []ErrorDetail `gorm:"type:jsonb;serializer:json" json:"errors"`
+	Error          string        `json:"error,omitempty"`
+	ResultLocation string        `json:"resultLocation,omitempty"`
+	CreatedBy      string        `json:"createdBy"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	StartedAt      *time.Time    `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time    `json:"finishedAt,omitempty"`
+}
+
+func (Job) TableName() string { return "jobs" }
+
+// Percent is the share of items processed, or 100 once the job succeeded.
+func (j Job) Percent() float64 {
+	if j.State == StateSucceeded {
+		return 100
+	}
+	if j.Total <= 0 {
+		return 0
+	}
+	p := float64(j.Processed) / float64(j.Total) * 100
+	if p > 100 {
+		p = 100
+	}
+	return float64(int(p*10)) / 10
+}
+
+// MarshalJSON adds the progress percentage.
+func (j Job) MarshalJSON() ([]byte, error) {
+	type alias Job
+	return json.Marshal(struct {
+		alias
+		Progress float64 `json:"progress"`
+	}{alias(j), j.Percent()})
+}
+
+// Func does the work of a job and reports through p. The returned string
+// is where the result can be found, if the job produces one.
+type Func func(ctx context.Context, p *Progress) (string, error)
+
+// Store keeps jobs in Postgres so their status can be polled from any
+// instance.
+type Store struct {
+	db       *gorm.DB
+	reporter errreport.Reporter
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// SetReporter reports jobs that panic to r. Such a job fails with the
+// incident ID of the report.
+func (s *Store) SetReporter(r errreport.Reporter) {
+	s.reporter = r
+}
+
+// Start records a job of the given type and runs fn in the background.
+// The job outlives ctx; ctx is only used to record it.
+func (s *Store) Start(ctx context.Context, jobType, actor string, fn Func) (*Job, error) {
+	job := &Job{
+		ID:        uuid.New().String(),
+		Type:      jobType,
+		State:     StateQueued,
+		Errors:    []ErrorDetail{},
+		CreatedBy: actor,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	run := *job
+	go s.run(&run, fn)
+	return job, nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*Job, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrJobNotFound
+	}
+	var job Job
+	err := s.db.WithContext(ctx).First(&job, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	return &job, err
+}
+
+// call runs fn and turns a panic into an error.
+func (s *Store) call(job *Job, fn Func) (location string, err error) {
+	ctx := context.Background()
+	defer func() {
+		if v := recover(); v != nil {
+			r := errreport.Recovered(ctx, s.reporter, v, "job", map[string]string{"job_id": job.ID, "job_type": job.Type})
+			err = fmt.Errorf("%w (incident %s)", r.Err, r.IncidentID)
+		}
+	}()
+	return fn(ctx, &Progress{store: s, job: job})
+}
+
+func (s *Store) run(job *Job, fn Func) {
+	started := time.Now().UTC()
+	job.State = StateRunning
+	job.StartedAt = &started
+	if err := s.update(job, "State", "StartedAt"); err != nil {
+		log.Printf("Failed to update job %s: %v", job.ID, err)
+	}
+
+	location, err := s.call(job, fn)
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	job.ResultLocation = location
+	if err != nil {
+		log.Printf("Job %s (%s) failed: %v", job.ID, job.Type, err)
+		job.State = StateFailed
+		job.Error = err.Error()
+	} else {
+		job.State = StateSucceeded
+	}
+	if err := s.update(job, "State", "Error", "ResultLocation", "FinishedAt", "Total", "Processed", "Failed", "Errors"); err != nil {
+		log.Printf("Failed to update job %s: %v", job.ID, err)
+	}
+}
+
+func (s *Store) update(job *Job, fields ...string) error {
+	return s.db.Model(job).Select(fields).Updates(job).Error
+}
+
+// Progress is a running job's view of itself. Counters are kept in memory
+// and written on Save, so jobs decide how often they hit the database.
+type Progress struct {
+	store *Store
+	job   *Job
+}
+
+func (p *Progress) SetTotal(n int) {
+	p.job.Total = n
+}
+
+// Advance marks n items as processed successfully.
+func (p *Progress) Advance(n int) {
+	p.job.Processed += n
+}
+
+// Fail marks one item as processed and failed.
+func (p *Progress) Fail(ref string, err error) {
+	p.job.Processed++
+	p.job.Failed++
+	if len(p.job.Errors) < maxErrorDetails {
+		p.job.Errors = append(p.job.Errors, ErrorDetail{Ref: ref, Error: err.Error()})
+	}
+}
+
+func (p *Progress) Save() error {
+	return p.store.update(p.job, "Total", "Processed", "Failed", "Errors")
+}
@@ -0,0 +1,56 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		job  Job
+		want float64
+	}{
+		{Job{State: StateRunning}, 0},
+		{Job{State: StateRunning, Total: 3, Processed: 1}, 33.3},
+		{Job{State: StateRunning, Total: 2, Processed: 5}, 100},
+		{Job{State: StateSucceeded}, 100},
+		{Job{State: StateFailed, Total: 4, Processed: 1}, 25},
+	}
+	for _, tt := range tests {
+		if got := tt.job.Percent(); got != tt.want {
+			t.Errorf("Expected %v, got %v for %+v", tt.want, got, tt.job)
+		}
+	}
+}
+
+func TestMarshalJSONIncludesProgress(t *testing.T) {
+	body, err := json.Marshal(Job{ID: "j1", State: StateRunning, Total: 4, Processed: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(body, &out); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if out["progress"] != 50.0 || out["id"] != "j1" {
+		t.Errorf("Expected progress 50 for j1, got %s", body)
+	}
+}
+
+func TestProgressCapsErrorDetails(t *testing.T) {
+	p := &Progress{job: &Job{}}
+	for i := 0; i < maxErrorDetails+5; i++ {
+		p.Fail("row", errors.New("bad"))
+	}
+	p.Advance(3)
+	if p.job.Failed != maxErrorDetails+5 {
+		t.Errorf("Expected %d failures, got %d", maxErrorDetails+5, p.job.Failed)
+	}
+	if len(p.job.Errors) != maxErrorDetails {
+		t.Errorf("Expected %d error details, got %d", maxErrorDetails, len(p.job.Errors))
+	}
+	if p.job.Processed != maxErrorDetails+8 {
+		t.Errorf("Expected %d processed, got %d", maxErrorDetails+8, p.job.Processed)
+	}
+}
@@ -0,0 +1,249 @@
+// Package jsonenc encodes the values on hot paths (cached order lists,
+// order list responses and event envelopes) without reflection. Fast
+// writes exactly the bytes encoding/json writes, so consumers and values
+// already in the cache cannot tell them apart; Std is encoding/json itself
+// and is kept as the fallback behind JSON_ENCODER.
+//
+// Fast encodes strings, booleans, integers, float64, time.Time, nil,
+// []string, []interface{}, map[string]interface{} and Appenders itself and
+// hands everything else to encoding/json.
+package jsonenc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Encoder appends the JSON encoding of a value to dst.
+type Encoder interface {
+	Append(dst []byte, v interface{}) ([]byte, error)
+}
+
+// Appender is implemented by types with a hand-written encoding. AppendJSON
+// appends the same bytes encoding/json would produce for the value.
+type Appender interface {
+	AppendJSON(dst []byte) ([]byte, error)
+}
+
+// Std encodes with encoding/json.
+type Std struct{}
+
+func (Std) Append(dst []byte, v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, raw...), nil
+}
+
+// Fast encodes without reflection where it can.
+type Fast struct{}
+
+func (Fast) Append(dst []byte, v interface{}) ([]byte, error) {
+	if dst == nil {
+		dst = make([]byte, 0, 256)
+	}
+	return AppendValue(dst, v)
+}
+
+// Names of the encoders, as JSON_ENCODER takes them.
+const (
+	NameFast = "fast"
+	NameStd  = "std"
+)
+
+// ByName returns the encoder called name.
+func ByName(name string) (Encoder, error) {
+	switch name {
+	case NameFast, "":
+		return Fast{}, nil
+	case NameStd:
+		return Std{}, nil
+	}
+	return nil, fmt.Errorf("unknown JSON encoder %q, expected %s or %s", name, NameFast, NameStd)
+}
+
+// AppendValue appends the encoding of v to dst.
+func AppendValue(dst []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+	case Appender:
+		return v.AppendJSON(dst)
+	case string:
+		return AppendString(dst, v), nil
+	case bool:
+		return strconv.AppendBool(dst, v), nil
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(dst, v, 10), nil
+	case float64:
+		return AppendFloat(dst, v)
+	case time.Time:
+		return AppendTime(dst, v)
+	case *time.Time:
+		if v == nil {
+			return append(dst, "null"...), nil
+		}
+		return AppendTime(dst, *v)
+	case []string:
+		if v == nil {
+			return append(dst, "null"...), nil
+		}
+		dst = append(dst, '[')
+		for i, s := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = AppendString(dst, s)
+		}
+		return append(dst, ']'), nil
+	case []interface{}:
+		if v == nil {
+			return append(dst, "null"...), nil
+		}
+		dst = append(dst, '[')
+		for i, e := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			var err error
+			if dst, err = AppendValue(dst, e); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	case map[string]interface{}:
+		return appendMap(dst, v)
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, raw...), nil
+}
+
+func appendMap(dst []byte, m map[string]interface{}) ([]byte, error) {
+	if m == nil {
+		return append(dst, "null"...), nil
+	}
+	var small [16]string
+	keys := small[:0]
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	dst = append(dst, '{')
+	for i, k := range keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = AppendString(dst, k)
+		dst = append(dst, ':')
+		var err error
+		if dst, err = AppendValue(dst, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// AppendFloat appends f the way encoding/json formats a float64.
+func AppendFloat(dst []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, &json.UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		if n := len(dst); n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+// AppendTime appends t the way time.Time.MarshalJSON formats it.
+func AppendTime(dst []byte, t time.Time) ([]byte, error) {
+	if y := t.Year(); y < 0 || y >= 10000 {
+		return nil, fmt.Errorf("json: error calling MarshalJSON for type time.Time: Time.MarshalJSON: year outside of range [0,9999]")
+	}
+	dst = append(dst, '"')
+	dst = t.AppendFormat(dst, time.RFC3339Nano)
+	return append(dst, '"'), nil
+}
+
+const hex = "0123456789abcdef"
+
+// invalidUTF8 is what encoding/json writes for a byte that is not valid
+// UTF-8: an escaped replacement character before jsonv2 and a raw one
+// since.
+var invalidUTF8 = func() string {
+	b, _ := json.Marshal("\xff")
+	return string(b[1 : len(b)-1])
+}()
+
+// AppendString appends s quoted the way encoding/json quotes it, with HTML
+// characters escaped.
+func AppendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, invalidUTF8...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are escaped for JSONP, like encoding/json does.
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
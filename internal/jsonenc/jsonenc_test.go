@@ -0,0 +1,117 @@
+package jsonenc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func TestFastMatchesStd(t *testing.T) {
+	at := time.Date(2030, 1, 2, 3, 4, 5, 600, time.FixedZone("WIB", 7*3600))
+	values := map[string]interface{}{
+		"nil":         nil,
+		"html":        `<a href="x">&amp;</a>`,
+		"control":     "tab\tnewline\nnul\x00bell\x07\b\f\r\\",
+		"invalid":     "bad \xff utf-8 \xe2\x82",
+		"separators":  "line paragraph ",
+		"unicode":     "pesanan ✓ 日本",
+		"floats":      []interface{}{0.0, math.Copysign(0, -1), 0.1, 115.5, 1e20, 1e21, 1e-6, 1e-7, -3.25e-9, 123456789.123},
+		"ints":        []interface{}{0, -1, math.MaxInt64, int64(math.MinInt64)},
+		"bools":       []interface{}{true, false},
+		"time":        at,
+		"time ptr":    &at,
+		"nil time":    (*time.Time)(nil),
+		"strings":     []string{"a", "<b>"},
+		"nil strings": []string(nil),
+		"nil map":     map[string]interface{}(nil),
+		"nested":      map[string]interface{}{"b": 1, "a": map[string]interface{}{"z<": "y"}, "c": []interface{}{nil, "x"}},
+		"fallback":    struct{ Name string }{"struct"},
+		"uint":        uint8(7),
+	}
+	for name, v := range values {
+		t.Run(name, func(t *testing.T) {
+			want, wantErr := json.Marshal(v)
+			got, err := Fast{}.Append(nil, v)
+			if err != nil || wantErr != nil {
+				t.Fatalf("Expected no errors, got %v and %v", err, wantErr)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Expected %s, got %s", want, got)
+			}
+		})
+	}
+
+	if err := quick.Check(func(s string, f float64, n int64) bool {
+		v := map[string]interface{}{s: []interface{}{s, f, n}}
+		want, _ := json.Marshal(v)
+		got, err := Fast{}.Append(nil, v)
+		return err == nil && bytes.Equal(got, want)
+	}, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFastRejectsWhatStdRejects(t *testing.T) {
+	for name, v := range map[string]interface{}{
+		"NaN":      math.NaN(),
+		"infinity": map[string]interface{}{"total": math.Inf(1)},
+		"year":     time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if _, err := json.Marshal(v); err == nil {
+			t.Fatalf("Expected encoding/json to reject %s", name)
+		}
+		if _, err := (Fast{}).Append(nil, v); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+	var unsupported *json.UnsupportedValueError
+	if _, err := (Fast{}).Append(nil, math.NaN()); !errors.As(err, &unsupported) {
+		t.Errorf("Expected an UnsupportedValueError, got %v", err)
+	}
+}
+
+func TestByName(t *testing.T) {
+	for name, want := range map[string]Encoder{"": Fast{}, NameFast: Fast{}, NameStd: Std{}} {
+		if enc, err := ByName(name); err != nil || enc != want {
+			t.Errorf("Expected %T for %q, got %T, %v", want, name, enc, err)
+		}
+	}
+	if _, err := ByName("sonic"); err == nil {
+		t.Error("Expected an error for an unknown encoder")
+	}
+}
+
+// BenchmarkEventPayload encodes an order.created envelope, the event
+// published for every order.
+func BenchmarkEventPayload(b *testing.B) {
+	event := map[string]interface{}{
+		"pattern": "order.created",
+		"data": map[string]interface{}{
+			"orderId":    "3f1c9a52-7d0b-4d1e-9a57-2c1f0f6b9e21",
+			"productId":  "p1",
+			"customerId": "customer-1",
+			"quantity":   2,
+		},
+	}
+	for _, enc := range []Encoder{Std{}, Fast{}} {
+		b.Run(encoderName(enc), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := enc.Append(nil, event); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func encoderName(enc Encoder) string {
+	if _, ok := enc.(Std); ok {
+		return NameStd
+	}
+	return NameFast
+}
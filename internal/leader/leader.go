@@ -0,0 +1,174 @@
+// Package leader elects one instance to run the background jobs that must
+// not run on several replicas at once, such as reconciliations. The
+// leader holds a lock (a Redis key or a Kubernetes Lease) that it renews
+// while it runs; when it stops renewing, another instance takes over once
+// the lock expires.
+package leader
+
+import (
+	"context"
+	"log"
+	"order-service/internal/metrics"
+	"sync"
+	"time"
+)
+
+// Lock is a lock with an expiry, held by one holder at a time.
+type Lock interface {
+	// Acquire takes the lock for holder, or extends it if holder already
+	// has it, for ttl. It returns false if someone else holds it.
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	// Release gives the lock up if holder has it.
+	Release(ctx context.Context, holder string) error
+}
+
+// Status is what an Elector knows about its election.
+type Status struct {
+	Election  string     `json:"election"`
+	Holder    string     `json:"holder"`
+	Leader    bool       `json:"leader"`
+	Since     *time.Time `json:"since,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// Elector campaigns for a lock on behalf of this instance and keeps it
+// while it is leader, renewing it three times per ttl.
+type Elector struct {
+	name   string
+	holder string
+	lock   Lock
+	ttl    time.Duration
+	renew  time.Duration
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	leading   bool
+	since     time.Time
+	renewedAt time.Time
+	lastErr   error
+	term      context.Context
+	endTerm   context.CancelFunc
+}
+
+func NewElector(name, holder string, lock Lock, ttl time.Duration) *Elector {
+	metrics.Leader.WithLabelValues(name).Set(0)
+	return &Elector{name: name, holder: holder, lock: lock, ttl: ttl, renew: ttl / 3, now: time.Now}
+}
+
+// Run campaigns until ctx is done, then releases the lock if it holds it
+// so another instance takes over right away.
+func (e *Elector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.renew)
+	defer ticker.Stop()
+	for {
+		e.campaign(ctx)
+		select {
+		case <-ctx.Done():
+			e.resign()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Elector) campaign(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, e.renew)
+	defer cancel()
+	ok, err := e.lock.Acquire(ctx, e.holder, e.ttl)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.lastErr = err
+	now := e.now()
+	switch {
+	case err != nil:
+		// The lock may still be ours; it is given up before it can have
+		// expired, so two leaders never overlap.
+		if e.leading && now.After(e.renewedAt.Add(e.ttl-e.renew)) {
+			log.Printf("Lost leadership of %s: %v", e.name, err)
+			e.step(false, now)
+		}
+	case ok:
+		e.renewedAt = now
+		if !e.leading {
+			log.Printf("Became leader of %s", e.name)
+			e.step(true, now)
+		}
+	case e.leading:
+		log.Printf("Lost leadership of %s to another instance", e.name)
+		e.step(false, now)
+	}
+}
+
+func (e *Elector) resign() {
+	e.mu.Lock()
+	leading := e.leading
+	if leading {
+		e.step(false, e.now())
+	}
+	e.mu.Unlock()
+	if !leading {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.renew)
+	defer cancel()
+	if err := e.lock.Release(ctx, e.holder); err != nil {
+		log.Printf("Failed to release leadership of %s: %v", e.name, err)
+	}
+}
+
+// step starts or ends a term. e.mu must be held.
+func (e *Elector) step(leading bool, now time.Time) {
+	e.leading = leading
+	if leading {
+		e.since = now
+		e.term, e.endTerm = context.WithCancel(context.Background())
+		metrics.Leader.WithLabelValues(e.name).Set(1)
+		metrics.LeaderTransitions.WithLabelValues(e.name, "acquired").Inc()
+		return
+	}
+	e.since = time.Time{}
+	e.endTerm()
+	e.term, e.endTerm = nil, nil
+	metrics.Leader.WithLabelValues(e.name).Set(0)
+	metrics.LeaderTransitions.WithLabelValues(e.name, "lost").Inc()
+}
+
+// Leading reports whether this instance is the leader.
+func (e *Elector) Leading() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leading
+}
+
+// Guard returns fn run only while this instance leads. The context fn gets
+// is cancelled when leadership is lost mid-run.
+func (e *Elector) Guard(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		e.mu.RLock()
+		term := e.term
+		e.mu.RUnlock()
+		if term == nil {
+			return nil
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stop := context.AfterFunc(term, cancel)
+		defer stop()
+		return fn(ctx)
+	}
+}
+
+func (e *Elector) Status() Status {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	s := Status{Election: e.name, Holder: e.holder, Leader: e.leading}
+	if e.leading {
+		since := e.since
+		s.Since = &since
+	}
+	if e.lastErr != nil {
+		s.LastError = e.lastErr.Error()
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+package leader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLock is held by whoever acquired it last until it is released or
+// taken over through holder.
+type fakeLock struct {
+	mu     sync.Mutex
+	holder string
+	err    error
+}
+
+func (l *fakeLock) Acquire(_ context.Context, holder string, _ time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return false, l.err
+	}
+	if l.holder == "" || l.holder == holder {
+		l.holder = holder
+		return true, nil
+	}
+	return false, nil
+}
+
+func (l *fakeLock) Release(_ context.Context, holder string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holder == holder {
+		l.holder = ""
+	}
+	return nil
+}
+
+func TestElector(t *testing.T) {
+	lock := &fakeLock{}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a := NewElector("test", "a", lock, 30*time.Second)
+	b := NewElector("test", "b", lock, 30*time.Second)
+	a.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if !a.Leading() || b.Leading() {
+		t.Fatalf("Expected a to lead alone, got a %t, b %t", a.Leading(), b.Leading())
+	}
+	if s := a.Status(); !s.Leader || s.Since == nil || !s.Since.Equal(now) {
+		t.Errorf("Unexpected status %+v", s)
+	}
+
+	// Errors are tolerated while the last renewal may still hold.
+	lock.err = errors.New("redis down")
+	now = now.Add(10 * time.Second)
+	a.campaign(ctx)
+	if !a.Leading() || a.Status().LastError == "" {
+		t.Errorf("Expected a to keep leading and report the error, got %+v", a.Status())
+	}
+	now = now.Add(15 * time.Second)
+	a.campaign(ctx)
+	if a.Leading() {
+		t.Error("Expected a to step down before its lock can have expired")
+	}
+
+	lock.err = nil
+	lock.holder = "b"
+	a.campaign(ctx)
+	if a.Leading() {
+		t.Error("Expected a not to lead while b holds the lock")
+	}
+
+	lock.holder = ""
+	a.campaign(ctx)
+	a.resign()
+	if a.Leading() || lock.holder != "" {
+		t.Errorf("Expected a to release the lock when it stops, held by %q", lock.holder)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	lock := &fakeLock{holder: "b"}
+	e := NewElector("test", "a", lock, 30*time.Second)
+	ctx := context.Background()
+	runs := 0
+	job := e.Guard(func(context.Context) error { runs++; return nil })
+
+	job(ctx)
+	if runs != 0 {
+		t.Error("Expected the job not to run on a follower")
+	}
+	lock.holder = ""
+	e.campaign(ctx)
+	job(ctx)
+	if runs != 1 {
+		t.Error("Expected the job to run on the leader")
+	}
+
+	// Losing leadership cancels a run in progress.
+	started := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- e.Guard(func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})(ctx)
+	}()
+	<-started
+	lock.holder = "b"
+	e.campaign(ctx)
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the run to be cancelled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the run to be cancelled when leadership was lost")
+	}
+}
+
+// fakeAPI serves one Lease like the Kubernetes API server, refusing
+// updates with a stale resourceVersion.
+type fakeAPI struct {
+	mu      sync.Mutex
+	lease   *lease
+	version int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	const base = "/apis/coordination.k8s.io/v1/namespaces/jobs/leases"
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == base+"/order-service":
+		if f.lease == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(f.lease)
+	case r.Method == http.MethodPost && r.URL.Path == base:
+		if f.lease != nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.store(w, r)
+	case r.Method == http.MethodPut && r.URL.Path == base+"/order-service":
+		var l lease
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &l)
+		if f.lease == nil || l.Metadata.ResourceVersion != f.lease.Metadata.ResourceVersion {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.lease = &l
+		f.bump()
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeAPI) store(w http.ResponseWriter, r *http.Request) {
+	var l lease
+	json.NewDecoder(r.Body).Decode(&l)
+	f.lease = &l
+	f.bump()
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (f *fakeAPI) bump() {
+	f.version++
+	f.lease.Metadata.ResourceVersion = strconv.Itoa(f.version)
+}
+
+func TestLease(t *testing.T) {
+	api := &fakeAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newLease := func() *Lease {
+		l := NewLease(server.URL, "jobs", "order-service", server.Client(), func() (string, error) { return "token", nil })
+		l.now = func() time.Time { return now }
+		return l
+	}
+	a, b := newLease(), newLease()
+	ctx := context.Background()
+
+	if ok, err := a.Acquire(ctx, "a", 15*time.Second); err != nil || !ok {
+		t.Fatalf("Expected a to create the lease, got %t, %v", ok, err)
+	}
+	if ok, _ := b.Acquire(ctx, "b", 15*time.Second); ok {
+		t.Error("Expected b not to take a lease held by a")
+	}
+	if ok, err := a.Acquire(ctx, "a", 15*time.Second); err != nil || !ok {
+		t.Errorf("Expected a to renew the lease, got %t, %v", ok, err)
+	}
+
+	now = now.Add(16 * time.Second)
+	if ok, err := b.Acquire(ctx, "b", 15*time.Second); err != nil || !ok {
+		t.Fatalf("Expected b to take over the expired lease, got %t, %v", ok, err)
+	}
+	if got := api.lease.Spec; got.holder() != "b" || *got.LeaseTransitions != 1 {
+		t.Errorf("Expected b to hold the lease after one transition, got %q, %d", got.holder(), *got.LeaseTransitions)
+	}
+
+	if err := a.Release(ctx, "a"); err != nil || api.lease.Spec.holder() != "b" {
+		t.Errorf("Expected a not to release b's lease, got %v, holder %q", err, api.lease.Spec.holder())
+	}
+	if err := b.Release(ctx, "b"); err != nil || api.lease.Spec.holder() != "" {
+		t.Errorf("Expected b to release the lease, got %v, holder %q", err, api.lease.Spec.holder())
+	}
+	if ok, _ := a.Acquire(ctx, "a", 15*time.Second); !ok {
+		t.Error("Expected a released lease to be free")
+	}
+}
@@ -0,0 +1,224 @@
+package leader
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Lease is a Lock kept in a Kubernetes coordination.k8s.io/v1 Lease,
+// through the API server's REST API. Concurrent updates are refused by the
+// API server thanks to the lease's resourceVersion.
+type Lease struct {
+	url    string
+	name   string
+	client *http.Client
+	token  func() (string, error)
+	now    func() time.Time
+}
+
+// NewLease returns a Lease named name in namespace, reached at apiURL
+// with the bearer token token returns.
+func NewLease(apiURL, namespace, name string, client *http.Client, token func() (string, error)) *Lease {
+	return &Lease{
+		url:    fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", strings.TrimRight(apiURL, "/"), namespace),
+		name:   name,
+		client: client,
+		token:  token,
+		now:    time.Now,
+	}
+}
+
+// NewInClusterLease returns a Lease reached with the pod's service
+// account. An empty namespace is the pod's own.
+func NewInClusterLease(namespace, name string) (*Lease, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster")
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates in the service account CA")
+	}
+	if namespace == "" {
+		ns, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, err
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	// The token is read on every request: the kubelet rotates it.
+	token := func() (string, error) {
+		b, err := os.ReadFile(serviceAccountDir + "/token")
+		return strings.TrimSpace(string(b)), err
+	}
+	return NewLease("https://"+net.JoinHostPort(host, port), namespace, name, &http.Client{Transport: transport}, token), nil
+}
+
+// microTime is the format of Lease timestamps.
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+type lease struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Metadata   leaseMetadata `json:"metadata"`
+	Spec       leaseSpec     `json:"spec"`
+}
+
+type leaseMetadata struct {
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+type leaseSpec struct {
+	HolderIdentity       *string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds *int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          *string `json:"acquireTime,omitempty"`
+	RenewTime            *string `json:"renewTime,omitempty"`
+	LeaseTransitions     *int    `json:"leaseTransitions,omitempty"`
+}
+
+func (s leaseSpec) holder() string {
+	if s.HolderIdentity == nil {
+		return ""
+	}
+	return *s.HolderIdentity
+}
+
+// expired reports whether the holder stopped renewing the lease.
+func (s leaseSpec) expired(now time.Time) bool {
+	if s.RenewTime == nil || s.LeaseDurationSeconds == nil {
+		return true
+	}
+	renewed, err := time.Parse(microTime, *s.RenewTime)
+	if err != nil {
+		return true
+	}
+	return now.After(renewed.Add(time.Duration(*s.LeaseDurationSeconds) * time.Second))
+}
+
+func (l *Lease) Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	current, err := l.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	now := l.now().UTC().Format(microTime)
+	seconds := max(int(ttl.Round(time.Second)/time.Second), 1)
+	if current == nil {
+		transitions := 0
+		created := lease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   leaseMetadata{Name: l.name},
+			Spec: leaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+				LeaseTransitions:     &transitions,
+			},
+		}
+		return l.write(ctx, http.MethodPost, l.url, created)
+	}
+
+	spec := current.Spec
+	if spec.holder() != holder {
+		if spec.holder() != "" && !spec.expired(l.now()) {
+			return false, nil
+		}
+		transitions := 1
+		if spec.LeaseTransitions != nil {
+			transitions = *spec.LeaseTransitions + 1
+		}
+		spec.HolderIdentity, spec.AcquireTime, spec.LeaseTransitions = &holder, &now, &transitions
+	}
+	spec.LeaseDurationSeconds, spec.RenewTime = &seconds, &now
+	current.Spec = spec
+	return l.write(ctx, http.MethodPut, l.url+"/"+l.name, *current)
+}
+
+func (l *Lease) Release(ctx context.Context, holder string) error {
+	current, err := l.get(ctx)
+	if err != nil || current == nil || current.Spec.holder() != holder {
+		return err
+	}
+	current.Spec.HolderIdentity, current.Spec.RenewTime = nil, nil
+	_, err = l.write(ctx, http.MethodPut, l.url+"/"+l.name, *current)
+	return err
+}
+
+// get returns the lease, or nil if it does not exist yet.
+func (l *Lease) get(ctx context.Context) (*lease, error) {
+	resp, err := l.do(ctx, http.MethodGet, l.url+"/"+l.name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kubernetes API returned status: %s", resp.Status)
+	}
+	var current lease
+	if err := json.NewDecoder(resp.Body).Decode(&current); err != nil {
+		return nil, fmt.Errorf("failed to decode lease: %w", err)
+	}
+	return &current, nil
+}
+
+// write creates or updates the lease. A conflict means another instance
+// wrote it first.
+func (l *Lease) write(ctx context.Context, method, url string, body lease) (bool, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return false, err
+	}
+	resp, err := l.do(ctx, method, url, b)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return false, nil
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("kubernetes API returned status: %s", resp.Status)
+	}
+	return true, nil
+}
+
+func (l *Lease) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	token, err := l.token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call kubernetes API: %w", err)
+	}
+	return resp, nil
+}
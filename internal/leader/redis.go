@@ -0,0 +1,46 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// acquireScript sets KEYS[1] to the holder ARGV[1] for ARGV[2]
+// milliseconds if it is free or already the holder's.
+var acquireScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current == false or current == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseScript deletes KEYS[1] if it belongs to the holder ARGV[1].
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLock is a Lock kept in a Redis key.
+type RedisLock struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedisLock(client *redis.Client, name string) *RedisLock {
+	return &RedisLock{client: client, key: "leader:" + name}
+}
+
+func (l *RedisLock) Acquire(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	n, err := acquireScript.Run(ctx, l.client, []string{l.key}, holder, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (l *RedisLock) Release(ctx context.Context, holder string) error {
+	return releaseScript.Run(ctx, l.client, []string{l.key}, holder).Err()
+}
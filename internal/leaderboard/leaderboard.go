@@ -0,0 +1,173 @@
+package leaderboard
+
+import (
+	"context"
+	"fmt"
+	"order-service/internal/repository"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+const keyPrefix = "top-products:"
+
+// Units sold are kept in one sorted set per UTC hour for the 24h window
+// and one per UTC day for the longer ones.
+const (
+	hourLayout = "2006010215"
+	dayLayout  = "20060102"
+	hourTTL    = 25 * time.Hour
+	dayTTL     = 31 * 24 * time.Hour
+)
+
+// Windows maps the accepted window names to their buckets: the number of
+// hourly or daily sets that are added up.
+var Windows = map[string]struct {
+	Hourly  bool
+	Buckets int
+}{
+	"24h": {Hourly: true, Buckets: 24},
+	"7d":  {Buckets: 7},
+	"30d": {Buckets: 30},
+}
+
+// SoldStatuses are the statuses whose orders count as sold.
+var SoldStatuses = []string{repository.StatusPending, repository.StatusPaid}
+
+type Entry struct {
+	ProductID string `json:"productId"`
+	Units     int64  `json:"units"`
+}
+
+// Leaderboard ranks products by units sold. Record keeps it current as
+// orders are confirmed; Reconcile rebuilds it from Postgres to repair
+// increments lost while Redis was unavailable.
+type Leaderboard struct {
+	client *redis.Client
+	now    func() time.Time
+}
+
+func New(client *redis.Client) *Leaderboard {
+	return &Leaderboard{client: client, now: time.Now}
+}
+
+func hourKey(t time.Time) string { return keyPrefix + "h:" + t.UTC().Format(hourLayout) }
+func dayKey(t time.Time) string  { return keyPrefix + "d:" + t.UTC().Format(dayLayout) }
+
+// Record adds units to productID's sales at the current time.
+func (l *Leaderboard) Record(ctx context.Context, productID string, units int) error {
+	now := l.now()
+	_, err := l.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for key, ttl := range map[string]time.Duration{hourKey(now): hourTTL, dayKey(now): dayTTL} {
+			p.ZIncrBy(ctx, key, float64(units), productID)
+			p.Expire(ctx, key, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// keys returns the sets that make up window, newest first.
+func (l *Leaderboard) keys(window string) ([]string, error) {
+	w, ok := Windows[window]
+	if !ok {
+		return nil, fmt.Errorf("unknown window %q", window)
+	}
+	now := l.now()
+	keys := make([]string, w.Buckets)
+	for i := range keys {
+		if w.Hourly {
+			keys[i] = hourKey(now.Add(-time.Duration(i) * time.Hour))
+		} else {
+			keys[i] = dayKey(now.AddDate(0, 0, -i))
+		}
+	}
+	return keys, nil
+}
+
+// Top returns the limit products with the most units sold in window.
+func (l *Leaderboard) Top(ctx context.Context, window string, limit int) ([]Entry, error) {
+	keys, err := l.keys(window)
+	if err != nil {
+		return nil, err
+	}
+	scores, err := l.client.ZUnionWithScores(ctx, redis.ZStore{Keys: keys}).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	if len(scores) > limit {
+		scores = scores[:limit]
+	}
+	entries := make([]Entry, len(scores))
+	for i, z := range scores {
+		entries[i] = Entry{ProductID: z.Member.(string), Units: int64(z.Score)}
+	}
+	return entries, nil
+}
+
+// Reconcile recomputes every set of the longest window from the orders
+// table and replaces the sets in Redis. Orders count in the bucket of
+// their creation time.
+func (l *Leaderboard) Reconcile(ctx context.Context, db *gorm.DB) error {
+	now := l.now().UTC()
+	since := now.Truncate(24*time.Hour).AddDate(0, 0, -(Windows["30d"].Buckets - 1))
+	var rows []struct {
+		ProductID string
+		Hour      time.Time
+		Units     int64
+	}
+	err := db.WithContext(ctx).Model(&repository.Order{}).
+		Select("product_id, date_trunc('hour', created_at AT TIME ZONE 'UTC') AS hour, SUM(quantity) AS units").
+		Where("created_at >= ? AND status IN ?", since, SoldStatuses).
+		Group("product_id, hour").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	// Every set of the windows is rewritten, including those that end up
+	// empty.
+	ttls := map[string]time.Duration{}
+	sets := map[string]map[string]int64{}
+	hourly, _ := l.keys("24h")
+	daily, _ := l.keys("30d")
+	for _, key := range hourly {
+		ttls[key] = hourTTL
+	}
+	for _, key := range daily {
+		ttls[key] = dayTTL
+	}
+	for _, row := range rows {
+		// The scanned timestamp has no zone; it is UTC.
+		hour := time.Date(row.Hour.Year(), row.Hour.Month(), row.Hour.Day(), row.Hour.Hour(), 0, 0, 0, time.UTC)
+		for _, key := range []string{hourKey(hour), dayKey(hour)} {
+			if _, ok := ttls[key]; !ok {
+				continue
+			}
+			if sets[key] == nil {
+				sets[key] = map[string]int64{}
+			}
+			sets[key][row.ProductID] += row.Units
+		}
+	}
+
+	_, err = l.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for key, ttl := range ttls {
+			p.Del(ctx, key)
+			if len(sets[key]) == 0 {
+				continue
+			}
+			members := make([]*redis.Z, 0, len(sets[key]))
+			for productID, n := range sets[key] {
+				members = append(members, &redis.Z{Score: float64(n), Member: productID})
+			}
+			p.ZAdd(ctx, key, members...)
+			p.Expire(ctx, key, ttl)
+		}
+		return nil
+	})
+	return err
+}
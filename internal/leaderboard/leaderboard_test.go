@@ -0,0 +1,32 @@
+package leaderboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeys(t *testing.T) {
+	l := &Leaderboard{now: func() time.Time {
+		return time.Date(2024, 3, 1, 2, 30, 0, 0, time.FixedZone("WIB", 7*3600))
+	}}
+
+	hourly, err := l.keys("24h")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(hourly) != 24 || hourly[0] != "top-products:h:2024022919" || hourly[23] != "top-products:h:2024022820" {
+		t.Errorf("Unexpected hourly keys %v", hourly)
+	}
+
+	daily, err := l.keys("7d")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(daily) != 7 || daily[0] != "top-products:d:20240229" || daily[6] != "top-products:d:20240223" {
+		t.Errorf("Unexpected daily keys %v", daily)
+	}
+
+	if _, err := l.keys("1y"); err == nil {
+		t.Error("Expected an error for an unknown window")
+	}
+}
@@ -0,0 +1,168 @@
+package limits
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrQuantityOutOfRange    = errors.New("quantity out of range")
+	ErrCustomerLimitExceeded = errors.New("customer purchase limit exceeded")
+	defaultRule              = Rule{MinQuantity: 1}
+	reserveScript            = redis.NewScript(reserveLua)
+)
+
+// Rule limits how much of a product can be bought. Zero values disable the
+// corresponding check, except that every order is for at least one unit.
+// CustomerLimit caps the units a single customer can buy within the
+// rolling Window.
+type Rule struct {
+	MinQuantity   int      `json:"minQuantity,omitempty"`
+	MaxQuantity   int      `json:"maxQuantity,omitempty"`
+	CustomerLimit int      `json:"customerLimit,omitempty"`
+	Window        Duration `json:"window,omitempty"`
+}
+
+type Config struct {
+	Default  Rule            `json:"default"`
+	Products map[string]Rule `json:"products"`
+}
+
+// Duration is a time.Duration that unmarshals from strings like "24h".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Parse reads a limits config from JSON. An empty string yields the default
+// of at least one unit per order.
+func Parse(data string) (Config, error) {
+	cfg := Config{Default: defaultRule}
+	if data == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse purchase limits: %w", err)
+	}
+	return cfg, nil
+}
+
+// Limiter enforces quantity rules and tracks per-customer purchases in
+// Redis sorted sets, one per customer and product.
+type Limiter struct {
+	client *redis.Client
+
+	mu  sync.RWMutex
+	cfg Config
+}
+
+func NewLimiter(client *redis.Client, cfg Config) *Limiter {
+	return &Limiter{client: client, cfg: cfg}
+}
+
+// SetConfig replaces the rules. Reservations already recorded count
+// against the new rules.
+func (l *Limiter) SetConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+}
+
+func (l *Limiter) rule(productID string) Rule {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if r, ok := l.cfg.Products[productID]; ok {
+		return r
+	}
+	return l.cfg.Default
+}
+
+func (l *Limiter) CheckQuantity(productID string, quantity int) error {
+	r := l.rule(productID)
+	if min := max(r.MinQuantity, 1); quantity < min {
+		return fmt.Errorf("%w: minimum is %d", ErrQuantityOutOfRange, min)
+	}
+	if r.MaxQuantity > 0 && quantity > r.MaxQuantity {
+		return fmt.Errorf("%w: maximum is %d", ErrQuantityOutOfRange, r.MaxQuantity)
+	}
+	return nil
+}
+
+// Reserve records quantity against the customer's rolling limit, failing
+// with ErrCustomerLimitExceeded if it would go over. orderID identifies the
+// reservation so it can be released if the order is not stored.
+func (l *Limiter) Reserve(ctx context.Context, productID, customerID, orderID string, quantity int) error {
+	r := l.rule(productID)
+	if r.CustomerLimit <= 0 || r.Window <= 0 || customerID == "" {
+		return nil
+	}
+	window := time.Duration(r.Window)
+	ok, err := reserveScript.Run(ctx, l.client,
+		[]string{key(productID, customerID)},
+		time.Now().UnixMilli(), window.Milliseconds(), r.CustomerLimit, quantity, member(orderID, quantity),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("failed to reserve purchase limit: %w", err)
+	}
+	if ok == 0 {
+		return fmt.Errorf("%w: at most %d units per %s", ErrCustomerLimitExceeded, r.CustomerLimit, window)
+	}
+	return nil
+}
+
+func (l *Limiter) Release(ctx context.Context, productID, customerID, orderID string, quantity int) error {
+	if customerID == "" {
+		return nil
+	}
+	return l.client.ZRem(ctx, key(productID, customerID), member(orderID, quantity)).Err()
+}
+
+func key(productID, customerID string) string {
+	return fmt.Sprintf("purchases:%s:%s", productID, customerID)
+}
+
+func member(orderID string, quantity int) string {
+	return orderID + ":" + strconv.Itoa(quantity)
+}
+
+// reserveLua drops purchases outside the window, sums the rest and adds the
+// new one only if it fits under the limit.
+const reserveLua = `
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+local qty = tonumber(ARGV[4])
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local total = 0
+for _, m in ipairs(redis.call('ZRANGE', key, 0, -1)) do
+  total = total + tonumber(string.match(m, ':(%d+)$'))
+end
+if total + qty > limit then
+  return 0
+end
+redis.call('ZADD', key, now, ARGV[5])
+redis.call('PEXPIRE', key, window)
+return 1
+`
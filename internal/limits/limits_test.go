@@ -0,0 +1,81 @@
+package limits
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestCheckQuantity(t *testing.T) {
+	cfg, err := Parse(`{"default":{"maxQuantity":10},"products":{"bulk":{"minQuantity":5},"capped":{"maxQuantity":3}}}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	l := NewLimiter(nil, cfg)
+
+	cases := []struct {
+		product  string
+		quantity int
+		ok       bool
+	}{
+		{"other", 1, true},
+		{"other", 11, false},
+		{"other", 0, false},
+		{"bulk", 4, false},
+		{"bulk", 50, true},
+		{"capped", 3, true},
+		{"capped", 0, false},
+		{"capped", -1, false},
+	}
+	for _, tc := range cases {
+		err := l.CheckQuantity(tc.product, tc.quantity)
+		if tc.ok && err != nil {
+			t.Errorf("Expected %d of %s to be allowed, got %v", tc.quantity, tc.product, err)
+		}
+		if !tc.ok && !errors.Is(err, ErrQuantityOutOfRange) {
+			t.Errorf("Expected %d of %s to be out of range, got %v", tc.quantity, tc.product, err)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	l := NewLimiter(client, Config{Default: Rule{CustomerLimit: 5, Window: Duration(time.Hour)}})
+	ctx := context.Background()
+
+	if err := l.Reserve(ctx, "p1", "c1", "o1", 3); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := l.Reserve(ctx, "p1", "c1", "o2", 3); !errors.Is(err, ErrCustomerLimitExceeded) {
+		t.Fatalf("Expected ErrCustomerLimitExceeded, got %v", err)
+	}
+	if err := l.Reserve(ctx, "p1", "c2", "o3", 5); err != nil {
+		t.Errorf("Expected another customer's limit to be separate, got %v", err)
+	}
+	if err := l.Reserve(ctx, "p1", "c1", "o2", 2); err != nil {
+		t.Errorf("Expected units up to the limit to fit, got %v", err)
+	}
+
+	if err := l.Release(ctx, "p1", "c1", "o1", 3); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := l.Reserve(ctx, "p1", "c1", "o4", 3); err != nil {
+		t.Errorf("Expected released units to be available again, got %v", err)
+	}
+	old := float64(time.Now().Add(-2 * time.Hour).UnixMilli())
+	if err := client.ZAdd(ctx, key("p1", "c3"), &redis.Z{Score: old, Member: member("o5", 5)}).Err(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := l.Reserve(ctx, "p1", "c3", "o6", 5); err != nil {
+		t.Errorf("Expected purchases outside the window not to count, got %v", err)
+	}
+	if ttl := mr.TTL(key("p1", "c1")); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("Expected the purchases to expire with the window, got TTL %v", ttl)
+	}
+}
@@ -0,0 +1,63 @@
+// Package links builds the _links object of API responses: the relations
+// a client may follow from a resource, given the resource's current state
+// and whether the caller is an admin. Clients discover the allowed actions
+// from the links instead of repeating the server's rules.
+package links
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Link is a relation a client can follow.
+type Link struct {
+	Href   string `json:"href"`
+	Method string `json:"method"`
+}
+
+// Links maps relation names, such as self or cancel, to links.
+type Links map[string]Link
+
+// Relation is a link offered for resources of type T.
+type Relation[T any] struct {
+	Name string
+	// Method defaults to GET.
+	Method string
+	// Path is the link's target; {id} is replaced with the escaped ID of
+	// the resource.
+	Path string
+	// Admin relations are only offered to admins.
+	Admin bool
+	// When reports whether the relation applies to the resource in its
+	// current state. Nil means always.
+	When func(T) bool
+}
+
+// Builder builds the links of resources of type T.
+type Builder[T any] struct {
+	id        func(T) string
+	relations []Relation[T]
+}
+
+// NewBuilder offers relations for resources identified by id.
+func NewBuilder[T any](id func(T) string, relations ...Relation[T]) *Builder[T] {
+	return &Builder[T]{id: id, relations: relations}
+}
+
+// Build returns the links of v for a caller, who may be an admin.
+func (b *Builder[T]) Build(v T, admin bool) Links {
+	id := url.PathEscape(b.id(v))
+	out := make(Links, len(b.relations))
+	for _, r := range b.relations {
+		if (r.Admin && !admin) || (r.When != nil && !r.When(v)) {
+			continue
+		}
+		method := r.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		out[r.Name] = Link{Href: strings.ReplaceAll(r.Path, "{id}", id), Method: method}
+	}
+	return out
+}
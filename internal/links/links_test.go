@@ -0,0 +1,49 @@
+package links
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type item struct {
+	ID   string
+	Open bool
+}
+
+func TestBuild(t *testing.T) {
+	b := NewBuilder(func(i item) string { return i.ID },
+		Relation[item]{Name: "self", Path: "/items/{id}"},
+		Relation[item]{Name: "close", Method: http.MethodPost, Path: "/items/{id}/close", When: func(i item) bool { return i.Open }},
+		Relation[item]{Name: "purge", Method: http.MethodDelete, Path: "/admin/items/{id}", Admin: true},
+	)
+
+	tests := []struct {
+		name  string
+		item  item
+		admin bool
+		want  Links
+	}{
+		{"closed", item{ID: "a"}, false, Links{
+			"self": {Href: "/items/a", Method: http.MethodGet},
+		}},
+		{"open", item{ID: "a", Open: true}, false, Links{
+			"self":  {Href: "/items/a", Method: http.MethodGet},
+			"close": {Href: "/items/a/close", Method: http.MethodPost},
+		}},
+		{"admin", item{ID: "a"}, true, Links{
+			"self":  {Href: "/items/a", Method: http.MethodGet},
+			"purge": {Href: "/admin/items/a", Method: http.MethodDelete},
+		}},
+		{"escaped", item{ID: "a/b c"}, false, Links{
+			"self": {Href: "/items/a%2Fb%20c", Method: http.MethodGet},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := b.Build(tt.item, tt.admin); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
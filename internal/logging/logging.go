@@ -0,0 +1,205 @@
+// Package logging is the structured logger of the request path. It sits on
+// top of slog and is built so that a request pays for a log line only when
+// the line is written:
+//
+//   - disabled levels return a nil *Entry whose methods do nothing, so no
+//     attribute is built or boxed;
+//   - entries are pooled and hand their attributes to slog.Logger.LogAttrs,
+//     which does not allocate for up to five of them;
+//   - fields every line of a request carries (trace and span IDs) are
+//     attached to the context once and formatted by the handler the first
+//     time the request logs;
+//   - debug lines are sampled, so turning on debug logging on a busy
+//     instance does not multiply its log volume.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Logger writes entries to a slog.Logger.
+type Logger struct {
+	base            *slog.Logger
+	debugSampleRate float64
+}
+
+// New returns a Logger writing to base, or to slog.Default() if base is
+// nil. Debug entries are kept with probability debugSampleRate; 1 keeps
+// them all.
+func New(base *slog.Logger, debugSampleRate float64) *Logger {
+	if base == nil {
+		base = slog.Default()
+	}
+	return &Logger{base: base, debugSampleRate: debugSampleRate}
+}
+
+// Debug starts a debug entry, or returns nil if debug is disabled or the
+// entry is not sampled.
+func (l *Logger) Debug(ctx context.Context) *Entry {
+	if l == nil || !l.base.Enabled(ctx, slog.LevelDebug) {
+		return nil
+	}
+	if l.debugSampleRate < 1 && rand.Float64() >= l.debugSampleRate {
+		return nil
+	}
+	return l.entry(ctx, slog.LevelDebug)
+}
+
+// Info starts an info entry, or returns nil if info is disabled.
+func (l *Logger) Info(ctx context.Context) *Entry {
+	return l.at(ctx, slog.LevelInfo)
+}
+
+// Warn starts a warning entry, or returns nil if warnings are disabled.
+func (l *Logger) Warn(ctx context.Context) *Entry {
+	return l.at(ctx, slog.LevelWarn)
+}
+
+// Error starts an error entry, or returns nil if errors are disabled.
+func (l *Logger) Error(ctx context.Context) *Entry {
+	return l.at(ctx, slog.LevelError)
+}
+
+func (l *Logger) at(ctx context.Context, level slog.Level) *Entry {
+	if l == nil || !l.base.Enabled(ctx, level) {
+		return nil
+	}
+	return l.entry(ctx, level)
+}
+
+func (l *Logger) entry(ctx context.Context, level slog.Level) *Entry {
+	e := entries.Get().(*Entry)
+	e.ctx = ctx
+	e.logger = l.base
+	if f, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		e.logger = f.logger(l.base)
+	}
+	e.level = level
+	return e
+}
+
+// Entry is a log line being built. A nil *Entry is a line that will not be
+// written; all methods accept it. Msg writes the entry and returns it to
+// the pool, so it must not be used afterwards.
+type Entry struct {
+	ctx    context.Context
+	logger *slog.Logger
+	level  slog.Level
+	attrs  []slog.Attr
+}
+
+var entries = sync.Pool{
+	New: func() any { return &Entry{attrs: make([]slog.Attr, 0, 8)} },
+}
+
+func (e *Entry) Str(key, value string) *Entry {
+	return e.Attr(slog.String(key, value))
+}
+
+func (e *Entry) Int(key string, value int) *Entry {
+	return e.Attr(slog.Int(key, value))
+}
+
+func (e *Entry) Dur(key string, value time.Duration) *Entry {
+	return e.Attr(slog.Duration(key, value))
+}
+
+// Err adds err under the key "error".
+func (e *Entry) Err(err error) *Entry {
+	return e.Attr(slog.Any("error", err))
+}
+
+func (e *Entry) Attr(attr slog.Attr) *Entry {
+	if e != nil {
+		e.attrs = append(e.attrs, attr)
+	}
+	return e
+}
+
+// Msg writes the entry with message msg.
+func (e *Entry) Msg(msg string) {
+	if e == nil {
+		return
+	}
+	e.logger.LogAttrs(e.ctx, e.level, msg, e.attrs...)
+	clear(e.attrs)
+	e.attrs = e.attrs[:0]
+	e.ctx, e.logger = nil, nil
+	entries.Put(e)
+}
+
+type fieldsKey struct{}
+
+// maxFields is how many fields a context carries without allocating
+// beyond the fields themselves.
+const maxFields = 4
+
+// fields are the attributes of every line logged under a context. The
+// handler formats them once, in the logger derived on first use.
+type fields struct {
+	inline [maxFields]slog.Attr
+	attrs  []slog.Attr
+
+	once    sync.Once
+	base    *slog.Logger
+	derived *slog.Logger
+}
+
+// WithFields returns a context whose entries carry attrs in addition to the
+// fields of ctx. An attribute replaces a field of ctx with the same key.
+func WithFields(ctx context.Context, attrs ...slog.Attr) context.Context {
+	f := &fields{}
+	f.attrs = f.inline[:0]
+	for _, a := range Fields(ctx) {
+		if !hasKey(attrs, a.Key) {
+			f.attrs = append(f.attrs, a)
+		}
+	}
+	f.attrs = append(f.attrs, attrs...)
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// Fields returns the fields attached to ctx. The slice must not be
+// modified.
+func Fields(ctx context.Context) []slog.Attr {
+	if f, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		return f.attrs
+	}
+	return nil
+}
+
+// Field returns the value of the field key attached to ctx.
+func Field(ctx context.Context, key string) (slog.Value, bool) {
+	for _, a := range Fields(ctx) {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return slog.Value{}, false
+}
+
+// logger returns base with the fields added. The logger for the first base
+// it is asked for is kept; every service logs to the same one.
+func (f *fields) logger(base *slog.Logger) *slog.Logger {
+	f.once.Do(func() {
+		f.base = base
+		f.derived = slog.New(base.Handler().WithAttrs(f.attrs))
+	})
+	if f.base != base {
+		return slog.New(base.Handler().WithAttrs(f.attrs))
+	}
+	return f.derived
+}
+
+func hasKey(attrs []slog.Attr, key string) bool {
+	for _, a := range attrs {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLogger(level slog.Level, sampleRate float64) (*Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return New(slog.New(handler), sampleRate), &buf
+}
+
+func TestEntry(t *testing.T) {
+	logger, buf := newTestLogger(slog.LevelInfo, 1)
+	ctx := context.Background()
+
+	logger.Debug(ctx).Str("order_id", "o1").Msg("not written")
+	logger.Warn(ctx).Str("order_id", "o1").Int("quantity", 2).Dur("duration", time.Second).Err(errors.New("boom")).Msg("written")
+
+	want := `{"level":"WARN","msg":"written","order_id":"o1","quantity":2,"duration":1000000000,"error":"boom"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("Expected %s, got %s", want, buf.String())
+	}
+}
+
+func TestDisabledEntriesDoNotAllocate(t *testing.T) {
+	logger, _ := newTestLogger(slog.LevelInfo, 1)
+	ctx := WithFields(context.Background(), slog.String("trace_id", "t1"))
+	err := errors.New("boom")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		logger.Debug(ctx).Str("order_id", "o1").Err(err).Msg("not written")
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations for a disabled entry, got %v", allocs)
+	}
+
+	var nilLogger *Logger
+	nilLogger.Error(ctx).Msg("not written")
+}
+
+func TestDebugSampling(t *testing.T) {
+	ctx := context.Background()
+
+	logger, buf := newTestLogger(slog.LevelDebug, 0)
+	for range 100 {
+		logger.Debug(ctx).Msg("sampled")
+	}
+	logger.Info(ctx).Msg("not sampled")
+	if got := strings.Count(buf.String(), "\n"); got != 1 {
+		t.Errorf("Expected only the info line with a zero sample rate, got %d lines", got)
+	}
+
+	logger, buf = newTestLogger(slog.LevelDebug, 0.5)
+	for range 1000 {
+		logger.Debug(ctx).Msg("sampled")
+	}
+	if got := strings.Count(buf.String(), "\n"); got < 400 || got > 600 {
+		t.Errorf("Expected about half of 1000 debug lines, got %d", got)
+	}
+}
+
+func TestFields(t *testing.T) {
+	logger, buf := newTestLogger(slog.LevelInfo, 1)
+	ctx := WithFields(context.Background(), slog.String("trace_id", "t1"), slog.String("span_id", "s1"))
+	child := WithFields(ctx, slog.String("span_id", "s2"))
+
+	logger.Info(ctx).Msg("parent")
+	logger.Info(child).Str("order_id", "o1").Msg("child")
+
+	var lines []map[string]string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]string
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, entry)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	if lines[0]["trace_id"] != "t1" || lines[0]["span_id"] != "s1" {
+		t.Errorf("Expected the fields of the context, got %v", lines[0])
+	}
+	if lines[1]["trace_id"] != "t1" || lines[1]["span_id"] != "s2" || lines[1]["order_id"] != "o1" {
+		t.Errorf("Expected the child to replace span_id and keep trace_id, got %v", lines[1])
+	}
+	if strings.Count(buf.String(), `"span_id"`) != 2 {
+		t.Errorf("Expected span_id once per line, got %s", buf.String())
+	}
+
+	if v, ok := Field(child, "span_id"); !ok || v.String() != "s2" {
+		t.Errorf("Expected span_id s2, got %v %v", v, ok)
+	}
+	if _, ok := Field(context.Background(), "span_id"); ok {
+		t.Error("Expected no fields on a bare context")
+	}
+}
+
+func BenchmarkEntry(b *testing.B) {
+	err := errors.New("boom")
+	for _, level := range []slog.Level{slog.LevelInfo, slog.LevelWarn} {
+		b.Run(level.String(), func(b *testing.B) {
+			logger := New(slog.New(slog.NewJSONHandler(discard{}, &slog.HandlerOptions{Level: level})), 1)
+			ctx := WithFields(context.Background(), slog.String("trace_id", "t1"), slog.String("span_id", "s1"))
+			b.ReportAllocs()
+			for b.Loop() {
+				logger.Info(ctx).Str("order_id", "o1").Str("product_id", "p1").Err(err).Msg("order")
+			}
+		})
+	}
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
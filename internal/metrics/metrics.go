@@ -0,0 +1,218 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Database metrics, populated by repository.QueryInstrumentation.
+var (
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "order_service_db_query_duration_seconds",
+		Help:    "Duration of database queries.",
+		Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation", "table"})
+
+	DBQueryRows = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "order_service_db_query_rows",
+		Help:    "Rows returned or affected by database queries.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_db_query_errors_total",
+		Help: "Database queries that returned an error.",
+	}, []string{"operation", "table"})
+
+	DBSlowQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_db_slow_queries_total",
+		Help: "Database queries slower than the configured threshold.",
+	}, []string{"operation", "table"})
+)
+
+// Dependency health, populated by degrade.Controller.
+var (
+	DependencyUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_service_dependency_up",
+		Help: "Whether a dependency passed its last health check.",
+	}, []string{"dependency"})
+
+	DegradedMode = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_service_degraded_mode",
+		Help: "Whether a degraded mode is active.",
+	}, []string{"mode"})
+)
+
+// Leader elections, populated by leader.Elector.
+var (
+	Leader = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_service_leader",
+		Help: "Whether this instance leads an election.",
+	}, []string{"election"})
+
+	LeaderTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_leader_transitions_total",
+		Help: "Times this instance became or stopped being leader, by transition (acquired, lost).",
+	}, []string{"election", "transition"})
+)
+
+// Order SLAs, populated by sla.Monitor for the statuses that have one.
+var (
+	OrdersInStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_service_orders_in_status",
+		Help: "Orders currently in a status.",
+	}, []string{"status"})
+
+	OldestInStatus = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_service_oldest_order_in_status_seconds",
+		Help: "How long the oldest order in a status has been in it.",
+	}, []string{"status"})
+
+	SLABreachedOrders = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_service_sla_breached_orders",
+		Help: "Orders currently in a status longer than its SLA.",
+	}, []string{"status"})
+
+	SLABreaches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_sla_breaches_total",
+		Help: "Orders that went over the SLA of a status.",
+	}, []string{"status"})
+)
+
+// OrderAnomalies counts products flagged by anomaly.Detector for their
+// order rate, per reason.
+var OrderAnomalies = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "order_service_order_anomalies_total",
+	Help: "Products flagged for an anomalous order rate.",
+}, []string{"reason"})
+
+// Event publishing, populated by service.BufferedPublisher.
+var (
+	EventQueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_service_event_queue_depth",
+		Help: "Events waiting in the publish buffer.",
+	})
+
+	EventQueueOverflows = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "order_service_event_queue_overflows_total",
+		Help: "Events spooled to the outbox because the publish buffer was full.",
+	})
+
+	// EventsRejected is populated by service.ValidatingPublisher and
+	// consumer.Consumer.
+	EventsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_events_rejected_total",
+		Help: "Events that did not match their schema, by pattern and direction (incoming, outgoing).",
+	}, []string{"pattern", "direction"})
+
+	// EventsSuppressed is populated by service.DedupingPublisher.
+	EventsSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_events_suppressed_total",
+		Help: "Events not published because the same event was published before, by pattern.",
+	}, []string{"pattern"})
+
+	// DomainEventFailures is populated by events.Bus.
+	DomainEventFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_domain_event_failures_total",
+		Help: "Domain events a subscriber failed to handle, by event and subscriber.",
+	}, []string{"event", "subscriber"})
+)
+
+// Event consumers, populated by consumer.Consumer and consumer.Registry.
+var (
+	ConsumerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_consumer_messages_total",
+		Help: "Messages consumed by queue and outcome (acked, requeued, dropped, malformed, quarantined, stale).",
+	}, []string{"queue", "outcome"})
+
+	ConsumerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "order_service_consumer_processing_duration_seconds",
+		Help:    "Time spent handling a consumed message.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"queue"})
+
+	ConsumerRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_consumer_retries_total",
+		Help: "Redelivered messages consumed.",
+	}, []string{"queue"})
+
+	ConsumerQuarantined = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_consumer_quarantined_total",
+		Help: "Messages quarantined by queue and failure class.",
+	}, []string{"queue", "class"})
+
+	ConsumerRestarts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_consumer_restarts_total",
+		Help: "Times a consumer reopened its channel.",
+	}, []string{"queue"})
+
+	ConsumerLagMessages = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_service_consumer_lag_messages",
+		Help: "Messages ready in a consumed queue at the last sample.",
+	}, []string{"queue"})
+
+	ConsumerLagSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "order_service_consumer_lag_seconds",
+		Help: "Estimated time to drain a consumed queue at the recent processing rate; -1 when nothing was processed.",
+	}, []string{"queue"})
+)
+
+// Supervised goroutines, populated by supervisor.Supervisor.
+var GoroutineRestarts = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "order_service_goroutine_restarts_total",
+	Help: "Restarts of supervised goroutines by name and reason (panic, exit, error).",
+}, []string{"name", "reason"})
+
+// Load shedding, populated by middleware.LoadShedder.
+var (
+	LoadShedInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_service_load_shed_in_flight_requests",
+		Help: "Requests in flight on routes guarded by load shedding.",
+	})
+
+	LoadShedDBLatency = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "order_service_load_shed_db_latency_seconds",
+		Help: "Moving average of database query duration used for load shedding.",
+	})
+
+	LoadShedRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_service_load_shed_rejected_total",
+		Help: "Requests rejected because the service was overloaded.",
+	}, []string{"priority"})
+)
+
+// Request quotas, populated by quota.Middleware.
+var QuotaRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "order_service_quota_rejected_total",
+	Help: "Requests rejected because the caller's daily quota was used up.",
+}, []string{"route"})
+
+// Shadowed product lookups, populated by the product-shadow mode of
+// service.OrderService.
+var ProductShadowComparisons = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "order_service_product_shadow_comparisons_total",
+	Help: "Product lookups sent to both product sources, by whether the answers matched.",
+}, []string{"result"})
+
+// Product prices by how product-service encoded them, populated by
+// service.OrderService.
+var ProductPriceFormats = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "order_service_product_price_format_total",
+	Help: "Product-service answers by the JSON type of their price (string, number).",
+}, []string{"format"})
+
+// Order service calls, populated by service.MetricsInterceptor.
+var (
+	OrderServiceCalls = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "order_service_call_duration_seconds",
+		Help:    "Duration of order service calls by operation and outcome.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"operation", "outcome"})
+)
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminUIAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(token string, auth func(*http.Request)) (*httptest.ResponseRecorder, string) {
+		var actor string
+		router := gin.New()
+		router.GET("/admin/ui", AdminUIAuth(token), func(c *gin.Context) {
+			if audit.ActorFromContext(c.Request.Context()) == Actor(c) {
+				actor = Actor(c)
+			}
+			c.Status(http.StatusOK)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/admin/ui", nil)
+		if auth != nil {
+			auth(req)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w, actor
+	}
+	basic := func(user, password string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(user, password) }
+	}
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		auth  func(*http.Request)
+		code  int
+		actor string
+	}{
+		{"the token as password", "secret", basic("alice", "secret"), http.StatusOK, "alice"},
+		{"no user name", "secret", basic("", "secret"), http.StatusOK, "admin"},
+		{"a wrong password", "secret", basic("alice", "guess"), http.StatusUnauthorized, ""},
+		{"no credentials", "secret", nil, http.StatusUnauthorized, ""},
+		{"a bearer token", "secret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }, http.StatusUnauthorized, ""},
+		{"no token configured", "", basic("alice", ""), http.StatusUnauthorized, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w, actor := serve(tt.token, tt.auth)
+			if w.Code != tt.code || actor != tt.actor {
+				t.Errorf("Expected %d as %q, got %d as %q", tt.code, tt.actor, w.Code, actor)
+			}
+			if tt.code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a basic auth challenge")
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimits rejects request bodies larger than maxBytes with 413. JSON
+// bodies are read up front and also rejected with 400 when they are not
+// valid JSON or nest deeper than maxDepth; other bodies are left streaming
+// and fail with *http.MaxBytesError once they pass the limit.
+func BodyLimits(maxBytes int64, maxDepth int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			abortTooLarge(c, maxBytes)
+			return
+		}
+		if !strings.HasPrefix(c.ContentType(), "application/json") {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		if int64(len(body)) > maxBytes {
+			abortTooLarge(c, maxBytes)
+			return
+		}
+		if err := checkJSONDepth(body, maxDepth); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+func abortTooLarge(c *gin.Context, maxBytes int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error": fmt.Sprintf("request body exceeds %d bytes", maxBytes),
+	})
+}
+
+// checkJSONDepth walks body token by token, failing on malformed JSON or
+// objects and arrays nested deeper than maxDepth.
+func checkJSONDepth(body []byte, maxDepth int) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			if depth > 0 {
+				return errors.New("malformed JSON: unexpected end of input")
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("malformed JSON: %w", err)
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > maxDepth {
+				return fmt.Errorf("JSON nested deeper than %d levels", maxDepth)
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
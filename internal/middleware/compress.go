@@ -0,0 +1,243 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// Compression configures Compress.
+type Compression struct {
+	// MinSize is the smallest body that is compressed; smaller ones save
+	// too little to be worth it. Negative turns compression off.
+	MinSize int
+	// Types are the media types that are compressed, e.g.
+	// application/json. A type ending in / matches a whole family, e.g.
+	// text/.
+	Types []string
+}
+
+// DefaultCompressionTypes are the media types compressed unless configured
+// otherwise.
+var DefaultCompressionTypes = []string{"application/json", "application/x-ndjson", "text/"}
+
+// Compress compresses the responses of a route group with brotli or gzip,
+// whichever the client prefers, brotli on a tie. A body is buffered until
+// it reaches cfg.MinSize, so small responses go out as they are; flushed
+// responses, such as streams, are compressed from the first flush on.
+// Responses that already have a Content-Encoding are left alone.
+func Compress(cfg Compression) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg.MinSize < 0 {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, cfg: cfg, encoding: encoding}
+		c.Writer = w
+		defer func() {
+			// Restored first, so a panic response written by Recovery goes
+			// out directly.
+			c.Writer = w.ResponseWriter
+			w.finish()
+		}()
+		c.Next()
+	}
+}
+
+// Encodings Compress can produce, in order of preference.
+var encodings = []string{"br", "gzip"}
+
+// negotiateEncoding picks the encoding of the highest quality in an
+// Accept-Encoding header, or "" if none is acceptable.
+func negotiateEncoding(header string) string {
+	quality := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		quality[strings.ToLower(strings.TrimSpace(name))] = q
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range encodings {
+		q, ok := quality[enc]
+		if !ok {
+			q, ok = quality["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+var (
+	gzipWriters   = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	brotliWriters = sync.Pool{New: func() any { return brotli.NewWriterLevel(io.Discard, 4) }}
+)
+
+// encoder is a pooled gzip or brotli writer.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// States of a compressWriter.
+const (
+	buffering = iota
+	compressing
+	passThrough
+)
+
+// compressWriter holds back the start of a body until it knows whether to
+// compress it.
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      Compression
+	encoding string
+	state    int
+	buf      bytes.Buffer
+	enc      encoder
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	switch w.state {
+	case compressing:
+		return w.enc.Write(p)
+	case passThrough:
+		return w.ResponseWriter.Write(p)
+	}
+	if w.buf.Len() == 0 && !w.compressible() {
+		w.state = passThrough
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf.Write(p)
+	if w.buf.Len() >= w.cfg.MinSize {
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers as they are; what follows, if anything,
+// is not compressed.
+func (w *compressWriter) WriteHeaderNow() {
+	if w.state == buffering && w.buf.Len() == 0 {
+		w.state = passThrough
+	}
+	if w.state == passThrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+// Written reports buffered bytes as written, so later middleware does not
+// write a second response.
+func (w *compressWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush starts compressing, however little has been written, so streamed
+// responses reach the client as they are produced.
+func (w *compressWriter) Flush() {
+	if w.state == buffering {
+		if w.compressible() {
+			w.start()
+		} else {
+			w.passThrough()
+		}
+	}
+	if w.state == compressing {
+		w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compressible decides from the status and headers the handler set.
+func (w *compressWriter) compressible() bool {
+	status := w.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, t := range w.cfg.Types {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return true
+		}
+	}
+	return false
+}
+
+// start sends the headers and the buffered bytes through an encoder.
+func (w *compressWriter) start() error {
+	h := w.Header()
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	if w.encoding == "br" {
+		w.enc = brotliWriters.Get().(*brotli.Writer)
+	} else {
+		w.enc = gzipWriters.Get().(*gzip.Writer)
+	}
+	w.enc.Reset(w.ResponseWriter)
+	w.state = compressing
+	_, err := w.enc.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// passThrough sends what was buffered as it is.
+func (w *compressWriter) passThrough() {
+	w.state = passThrough
+	if w.buf.Len() > 0 {
+		w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+}
+
+// finish sends a body that stayed below the threshold or ends the
+// compressed one.
+func (w *compressWriter) finish() {
+	switch w.state {
+	case buffering:
+		w.passThrough()
+	case compressing:
+		w.enc.Close()
+		w.enc.Reset(io.Discard)
+		if w.encoding == "br" {
+			brotliWriters.Put(w.enc)
+		} else {
+			gzipWriters.Put(w.enc)
+		}
+		w.enc = nil
+	}
+}
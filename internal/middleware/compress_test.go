@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"*;q=0.1, gzip;q=0.8", "gzip"},
+		{"identity", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("Expected %q for %q, got %q", tt.want, tt.header, got)
+		}
+	}
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var r io.Reader = w.Body
+	switch w.Header().Get("Content-Encoding") {
+	case "gzip":
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("Expected a gzip body, got %v", err)
+		}
+		r = zr
+	case "br":
+		r = brotli.NewReader(w.Body)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read body: %v", err)
+	}
+	return string(body)
+}
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	large := strings.Repeat(`{"id":"order"},`, 100)
+	router := gin.New()
+	router.Use(Compress(Compression{MinSize: 256, Types: DefaultCompressionTypes}))
+	router.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	router.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"id": "order"}) })
+	router.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+	router.GET("/empty", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Writer.WriteString(`{"id":"1"}` + "\n")
+		c.Writer.Flush()
+		c.Writer.WriteString(`{"id":"2"}` + "\n")
+	})
+
+	tests := []struct {
+		name, path, accept string
+		encoding, body     string
+		status             int
+	}{
+		{"gzip", "/large", "gzip", "gzip", large, http.StatusOK},
+		{"brotli", "/large", "gzip, br", "br", large, http.StatusOK},
+		{"not accepted", "/large", "", "", large, http.StatusOK},
+		{"below threshold", "/small", "gzip", "", `{"id":"order"}`, http.StatusOK},
+		{"other type", "/image", "gzip", "", large, http.StatusOK},
+		{"no body", "/empty", "gzip", "", "", http.StatusNoContent},
+		{"flushed", "/stream", "gzip", "gzip", `{"id":"1"}` + "\n" + `{"id":"2"}` + "\n", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected %d, got %d", tt.status, w.Code)
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tt.encoding, got)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Expected Vary: Accept-Encoding, got %q", got)
+			}
+			if got := decode(t, w); got != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, got)
+			}
+		})
+	}
+}
+
+func TestCompressDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Compress(Compression{MinSize: -1, Types: DefaultCompressionTypes}))
+	router.GET("/", func(c *gin.Context) { c.String(http.StatusOK, strings.Repeat("x", 4096)) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 4096 {
+		t.Errorf("Expected an uncompressed body, got %q with %d bytes", w.Header().Get("Content-Encoding"), w.Body.Len())
+	}
+}
+
+func TestCompressLetsRecoveryAnswerPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Recovery(nil), Compress(Compression{MinSize: 1, Types: DefaultCompressionTypes}))
+	router.GET("/", func(c *gin.Context) { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "incidentId") {
+		t.Errorf("Expected the panic response, got %d %s", w.Code, w.Body)
+	}
+}
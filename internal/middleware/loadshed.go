@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"order-service/internal/i18n"
+	"order-service/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CodeOverloaded is the error code of requests shed by LoadShedder.
+const CodeOverloaded = "OVERLOADED"
+
+// latencyWeight is the weight of a new sample in the DB latency average.
+const latencyWeight = 0.1
+
+// LoadShedConfig sets when a LoadShedder starts rejecting requests. A zero
+// limit disables that signal.
+type LoadShedConfig struct {
+	// MaxInFlight is the number of concurrent requests above which
+	// low-priority requests are rejected.
+	MaxInFlight int
+	// DBLatency is the average query duration above which low-priority
+	// requests are rejected.
+	DBLatency time.Duration
+	// RetryAfter is sent to rejected clients.
+	RetryAfter time.Duration
+}
+
+// LoadShedder rejects requests with 503 before the database tips over. The
+// load is the larger of in-flight requests and average DB latency relative
+// to their limits: at 1 low-priority requests are rejected, at 2 every
+// request is.
+type LoadShedder struct {
+	cfg      LoadShedConfig
+	inFlight atomic.Int64
+
+	mu      sync.Mutex
+	latency float64 // seconds, exponentially weighted
+}
+
+func NewLoadShedder(cfg LoadShedConfig) *LoadShedder {
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = time.Second
+	}
+	return &LoadShedder{cfg: cfg}
+}
+
+// ObserveDBLatency feeds the duration of a database query into the
+// average.
+func (s *LoadShedder) ObserveDBLatency(d time.Duration) {
+	s.mu.Lock()
+	if s.latency == 0 {
+		s.latency = d.Seconds()
+	} else {
+		s.latency += latencyWeight * (d.Seconds() - s.latency)
+	}
+	latency := s.latency
+	s.mu.Unlock()
+	metrics.LoadShedDBLatency.Set(latency)
+}
+
+// Load returns the current load; 1 is the configured limit.
+func (s *LoadShedder) Load() float64 {
+	var load float64
+	if s.cfg.MaxInFlight > 0 {
+		load = float64(s.inFlight.Load()) / float64(s.cfg.MaxInFlight)
+	}
+	if s.cfg.DBLatency > 0 {
+		s.mu.Lock()
+		latency := s.latency
+		s.mu.Unlock()
+		load = math.Max(load, latency/s.cfg.DBLatency.Seconds())
+	}
+	return load
+}
+
+// Middleware sheds requests of a route group. Reads are high priority;
+// writes, which are not idempotent and cost the database the most, are
+// rejected first.
+func (s *LoadShedder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		priority := "low"
+		limit := 1.0
+		if isRead(c.Request.Method) {
+			priority = "high"
+			limit = 2
+		}
+		if s.Load() >= limit {
+			metrics.LoadShedRejected.WithLabelValues(priority).Inc()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(s.cfg.RetryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "service overloaded",
+				"code":    CodeOverloaded,
+				"message": i18n.T(c.Request.Context(), CodeOverloaded, "service overloaded"),
+			})
+			return
+		}
+
+		metrics.LoadShedInFlight.Set(float64(s.inFlight.Add(1)))
+		defer func() { metrics.LoadShedInFlight.Set(float64(s.inFlight.Add(-1))) }()
+		c.Next()
+	}
+}
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestLoadShedderInFlight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	shedder := NewLoadShedder(LoadShedConfig{MaxInFlight: 1, RetryAfter: 2 * time.Second})
+	router := gin.New()
+	router.Use(shedder.Middleware())
+	release := make(chan struct{})
+	started := make(chan struct{})
+	router.GET("/block", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/read", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.POST("/write", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/block", nil))
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/write", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected write to be shed with 503, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Expected Retry-After 2, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/read", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected read to pass, got %d", w.Code)
+	}
+
+	close(release)
+	<-done
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/write", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected write to pass once load dropped, got %d", w.Code)
+	}
+}
+
+func TestLoadShedderDBLatency(t *testing.T) {
+	shedder := NewLoadShedder(LoadShedConfig{DBLatency: 100 * time.Millisecond})
+	if load := shedder.Load(); load != 0 {
+		t.Fatalf("Expected no load, got %f", load)
+	}
+	shedder.ObserveDBLatency(150 * time.Millisecond)
+	if load := shedder.Load(); load < 1 {
+		t.Errorf("Expected slow queries to overload, got %f", load)
+	}
+	for range 50 {
+		shedder.ObserveDBLatency(10 * time.Millisecond)
+	}
+	if load := shedder.Load(); load >= 1 {
+		t.Errorf("Expected load to recover with fast queries, got %f", load)
+	}
+}
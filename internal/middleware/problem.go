@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problems rewrites error responses as RFC 7807 problem details for
+// clients that accept application/problem+json; everyone else keeps the
+// {"error", "code", "message"} envelope. The code becomes the type, e.g.
+// ORDER_NOT_FOUND becomes typeBase + "order-not-found", the translated
+// message the title and the error the detail. Other fields of the
+// envelope, such as incidentId, are kept as extensions. Errors without a
+// code get the type about:blank.
+//
+// Register it before Recovery so panic responses are rewritten too.
+func Problems(typeBase string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept")
+		if c.NegotiateFormat(gin.MIMEJSON, ProblemContentType) != ProblemContentType {
+			c.Next()
+			return
+		}
+		w := &problemWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		w.finish(typeBase, c.Request.URL.Path)
+	}
+}
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions are added next to the standard members.
+	Extensions map[string]any `json:"-"`
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		out[k] = v
+	}
+	out["type"] = p.Type
+	out["title"] = p.Title
+	out["status"] = p.Status
+	if p.Detail != "" {
+		out["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		out["instance"] = p.Instance
+	}
+	return json.Marshal(out)
+}
+
+// ProblemType is the type URI of an error code.
+func ProblemType(typeBase, code string) string {
+	if code == "" {
+		return "about:blank"
+	}
+	return typeBase + strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
+
+// toProblem converts an error envelope. It reports false for bodies that
+// are not one.
+func toProblem(body []byte, status int, typeBase, instance string) (Problem, bool) {
+	var envelope map[string]any
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return Problem{}, false
+	}
+	detail, ok := envelope["error"].(string)
+	if !ok {
+		return Problem{}, false
+	}
+	code, _ := envelope["code"].(string)
+	title, _ := envelope["message"].(string)
+	if code == "" || title == "" {
+		title = http.StatusText(status)
+	}
+	delete(envelope, "error")
+	delete(envelope, "message")
+	return Problem{
+		Type:       ProblemType(typeBase, code),
+		Title:      title,
+		Status:     status,
+		Detail:     detail,
+		Instance:   instance,
+		Extensions: envelope,
+	}, true
+}
+
+// problemWriter holds back error responses until they are complete, so
+// they can be rewritten. Other responses pass through.
+type problemWriter struct {
+	gin.ResponseWriter
+	capturing bool
+	buf       bytes.Buffer
+}
+
+// WriteHeader labels error responses as problem details up front, while
+// the handler has not set a type yet, so Compress leaves them alone.
+func (w *problemWriter) WriteHeader(code int) {
+	if w.buf.Len() == 0 && !w.ResponseWriter.Written() {
+		w.capturing = code >= http.StatusBadRequest
+		if w.capturing && w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", ProblemContentType)
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *problemWriter) Write(p []byte) (int, error) {
+	if w.capturing {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *problemWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *problemWriter) WriteHeaderNow() {
+	if !w.capturing {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *problemWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *problemWriter) Flush() {
+	if !w.capturing {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish sends a held back response, rewritten if it is an error envelope
+// that was not compressed on the way.
+func (w *problemWriter) finish(typeBase, instance string) {
+	if !w.capturing {
+		return
+	}
+	h := w.Header()
+	body := w.buf.Bytes()
+	if h.Get("Content-Encoding") == "" {
+		if p, ok := toProblem(body, w.Status(), typeBase, instance); ok {
+			if out, err := json.Marshal(p); err == nil {
+				h.Set("Content-Type", ProblemContentType)
+				h.Del("Content-Length")
+				w.ResponseWriter.Write(out)
+				return
+			}
+		}
+	}
+	if h.Get("Content-Type") == ProblemContentType {
+		// Labelled by WriteHeader, but not an envelope after all.
+		switch {
+		case len(body) == 0:
+			h.Del("Content-Type")
+		case json.Valid(body):
+			h.Set("Content-Type", "application/json; charset=utf-8")
+		default:
+			h.Set("Content-Type", http.DetectContentType(body))
+		}
+	}
+	if len(body) == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.ResponseWriter.Write(body)
+}
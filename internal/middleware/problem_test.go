@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testProblemBase = "urn:test:problem:"
+
+func problemRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Problems(testProblemBase), Recovery(nil), Compress(Compression{MinSize: 1, Types: DefaultCompressionTypes}))
+	router.GET("/coded", func(c *gin.Context) {
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusNotFound, gin.H{"error": "order not found", "code": "ORDER_NOT_FOUND", "message": "Order not found", "orderId": "o1"})
+	})
+	router.GET("/plain", func(c *gin.Context) { c.JSON(http.StatusBadRequest, gin.H{"error": "bad limit"}) })
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+	router.GET("/text", func(c *gin.Context) { c.String(http.StatusTeapot, "short and stout") })
+	router.GET("/empty", func(c *gin.Context) { c.AbortWithStatus(http.StatusUnauthorized) })
+	router.GET("/ok", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"error": "not an error"}) })
+	return router
+}
+
+// serveProblem requests path accepting gzip.
+func serveProblem(router *gin.Engine, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProblems(t *testing.T) {
+	router := problemRouter()
+
+	t.Run("coded", func(t *testing.T) {
+		w := serveProblem(router, "/coded", ProblemContentType)
+		if w.Code != http.StatusNotFound || w.Header().Get("Content-Type") != ProblemContentType {
+			t.Fatalf("Expected a 404 problem, got %d %s", w.Code, w.Header().Get("Content-Type"))
+		}
+		if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Retry-After") != "5" {
+			t.Errorf("Expected the headers to be kept and nothing compressed, got %v", w.Header())
+		}
+		var got map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Expected JSON, got %s", w.Body)
+		}
+		want := map[string]any{
+			"type": testProblemBase + "order-not-found", "title": "Order not found", "status": float64(404),
+			"detail": "order not found", "instance": "/coded", "code": "ORDER_NOT_FOUND", "orderId": "o1",
+		}
+		if len(got) != len(want) {
+			t.Errorf("Expected %v, got %v", want, got)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("Expected %s %v, got %v", k, v, got[k])
+			}
+		}
+	})
+
+	t.Run("without code", func(t *testing.T) {
+		w := serveProblem(router, "/plain", "application/problem+json, application/json;q=0.5")
+		if body := w.Body.String(); !strings.Contains(body, `"type":"about:blank"`) || !strings.Contains(body, `"title":"Bad Request"`) {
+			t.Errorf("Expected an about:blank problem, got %s", body)
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		w := serveProblem(router, "/panic", ProblemContentType)
+		if body := w.Body.String(); w.Code != http.StatusInternalServerError || !strings.Contains(body, `"type":"`+testProblemBase+`internal-error"`) || !strings.Contains(body, "incidentId") {
+			t.Errorf("Expected an internal-error problem with an incident ID, got %d %s", w.Code, body)
+		}
+	})
+
+	t.Run("not an envelope", func(t *testing.T) {
+		w := serveProblem(router, "/text", ProblemContentType)
+		if w.Body.String() != "short and stout" || strings.HasPrefix(w.Header().Get("Content-Type"), ProblemContentType) {
+			t.Errorf("Expected the body as it was, got %s %q", w.Header().Get("Content-Type"), w.Body)
+		}
+	})
+
+	t.Run("no body", func(t *testing.T) {
+		w := serveProblem(router, "/empty", ProblemContentType)
+		if w.Code != http.StatusUnauthorized || w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+			t.Errorf("Expected an empty 401, got %d %s %q", w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		w := serveProblem(router, "/ok", ProblemContentType)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+			t.Errorf("Expected a compressed 200, got %d %v", w.Code, w.Header())
+		}
+	})
+}
+
+func TestProblemsKeepLegacyEnvelope(t *testing.T) {
+	router := problemRouter()
+	for _, accept := range []string{"", "*/*", "application/json"} {
+		w := serveProblem(router, "/plain", accept)
+		if w.Header().Get("Content-Type") != "application/json; charset=utf-8" || decode(t, w) != `{"error":"bad limit"}` {
+			t.Errorf("Expected the envelope for Accept %q, got %s %s", accept, w.Header().Get("Content-Type"), w.Body)
+		}
+		if vary := w.Header().Values("Vary"); len(vary) != 2 {
+			t.Errorf("Expected Vary on Accept and Accept-Encoding, got %v", vary)
+		}
+	}
+}
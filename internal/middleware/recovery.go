@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"order-service/internal/errreport"
+	"order-service/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CodeInternal is the error code of requests that panicked.
+const CodeInternal = "INTERNAL_ERROR"
+
+// Recovery turns a panic in a handler into a 500 carrying an incident ID.
+// The panic is logged with its stack and sent to reporter under the same
+// ID. Aborted handlers (http.ErrAbortHandler) are left to net/http.
+func Recovery(reporter errreport.Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			ctx := c.Request.Context()
+			r := errreport.Recovered(ctx, reporter, v, "http", map[string]string{
+				"method": c.Request.Method,
+				"route":  c.FullPath(),
+			})
+			c.Header("X-Incident-ID", r.IncidentID)
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":      "internal error",
+				"code":       CodeInternal,
+				"message":    i18n.T(ctx, CodeInternal, "internal error"),
+				"incidentId": r.IncidentID,
+			})
+		}()
+		c.Next()
+	}
+}
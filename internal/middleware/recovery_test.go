@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/errreport"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeReporter struct {
+	reports []errreport.Report
+}
+
+func (f *fakeReporter) Report(_ context.Context, r errreport.Report) {
+	f.reports = append(f.reports, r)
+}
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reporter := &fakeReporter{}
+	router := gin.New()
+	router.Use(Recovery(reporter))
+	router.GET("/orders/:id", func(c *gin.Context) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/o1", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", w.Code)
+	}
+	var body struct {
+		Code       string `json:"code"`
+		IncidentID string `json:"incidentId"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != CodeInternal || body.IncidentID == "" || w.Header().Get("X-Incident-ID") != body.IncidentID {
+		t.Errorf("Expected the incident ID in the body and header, got %s and %q", w.Body, w.Header().Get("X-Incident-ID"))
+	}
+
+	if len(reporter.reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(reporter.reports))
+	}
+	r := reporter.reports[0]
+	if r.IncidentID != body.IncidentID || r.Source != "http" || r.Tags["route"] != "/orders/:id" || r.Tags["method"] != http.MethodGet {
+		t.Errorf("Expected a report for the route, got %+v", r)
+	}
+}
+
+func TestRecoveryLeavesAbortedHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reporter := &fakeReporter{}
+	router := gin.New()
+	router.Use(Recovery(reporter))
+	router.GET("/stream", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("Expected http.ErrAbortHandler to be re-raised, got %v", v)
+		}
+		if len(reporter.reports) != 0 {
+			t.Errorf("Expected no report, got %d", len(reporter.reports))
+		}
+	}()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/stream", nil))
+}
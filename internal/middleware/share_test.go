@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestShareSigner(t *testing.T) {
+	signer := NewShareSigner("s3cret")
+	now := time.Unix(1_900_000_000, 0)
+	expires := now.Add(time.Hour)
+
+	for _, id := range []string{"0f8fad5b-d9cb-469f-a165-70867728950e", "legacy.order.7"} {
+		token, err := signer.Sign(id, expires)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got, err := signer.Verify(token, now); err != nil || got != id {
+			t.Errorf("Expected %s, got %q, %v", id, got, err)
+		}
+		if _, err := signer.Verify(token, expires); !errors.Is(err, ErrExpiredShareToken) {
+			t.Errorf("Expected ErrExpiredShareToken at expiry, got %v", err)
+		}
+		if _, err := NewShareSigner("other").Verify(token, now); !errors.Is(err, ErrInvalidShareToken) {
+			t.Errorf("Expected a token of another secret to be invalid, got %v", err)
+		}
+	}
+
+	token, _ := signer.Sign("order-1", expires)
+	forged, _ := NewShareSigner("s3cret").Sign("order-2", expires)
+	for name, bad := range map[string]string{
+		"empty":          "",
+		"no signature":   token[:len(token)/2],
+		"swapped":        forged[:len(forged)-10] + token[len(token)-10:],
+		"bad encoding":   "!!." + token,
+		"extra segments": token + ".x",
+	} {
+		if _, err := signer.Verify(bad, now); !errors.Is(err, ErrInvalidShareToken) {
+			t.Errorf("%s: expected ErrInvalidShareToken, got %v", name, err)
+		}
+	}
+
+	disabled := NewShareSigner("")
+	if _, err := disabled.Sign("order-1", expires); !errors.Is(err, ErrSharingDisabled) {
+		t.Errorf("Expected ErrSharingDisabled, got %v", err)
+	}
+	if _, err := disabled.Verify(token, now); !errors.Is(err, ErrInvalidShareToken) {
+		t.Errorf("Expected every token to be invalid without a secret, got %v", err)
+	}
+}
+
+func TestSharedOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	signer := NewShareSigner("s3cret")
+	router := gin.New()
+	router.GET("/shared/orders/:token", SharedOrder(signer), func(c *gin.Context) {
+		c.String(http.StatusOK, SharedOrderID(c))
+	})
+
+	valid, _ := signer.Sign("order-1", time.Now().Add(time.Hour))
+	expired, _ := signer.Sign("order-1", time.Now().Add(-time.Second))
+	cases := []struct {
+		name, token string
+		status      int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"expired", expired, http.StatusGone},
+		{"invalid", "nope", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/shared/orders/"+tc.token, nil))
+			if w.Code != tc.status {
+				t.Fatalf("Expected %d, got %d: %s", tc.status, w.Code, w.Body)
+			}
+			if tc.status == http.StatusOK && w.Body.String() != "order-1" {
+				t.Errorf("Expected order-1, got %s", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"order-service/internal/i18n"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CodeTimeout is the error code of requests cut off by Timeout.
+const CodeTimeout = "REQUEST_TIMEOUT"
+
+// Timeout gives each request of a route group d to finish. The request
+// context is cancelled at the deadline, which aborts downstream calls made
+// with it. Handlers report the resulting error through TimeoutError; if a
+// handler wrote nothing the middleware does it.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if ctx.Err() == context.DeadlineExceeded && !c.Writer.Written() {
+			TimeoutError(c)
+		}
+	}
+}
+
+// TimeoutError writes the 504 response shared by every timed out route.
+func TimeoutError(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{
+		"error":   "request timed out",
+		"code":    CodeTimeout,
+		"message": i18n.T(c.Request.Context(), CodeTimeout, "request timed out"),
+	})
+}
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/dedupe"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	DefaultSignatureHeader = "X-Signature"
+	DefaultTimestampHeader = "X-Timestamp"
+	DefaultNonceHeader     = "X-Webhook-Id"
+
+	providerKey = "webhookProvider"
+)
+
+// WebhookProvider is how one provider signs its webhooks: the hex HMAC
+// SHA-256, keyed with Secret, of "<timestamp>.<body>", where the timestamp
+// is Unix seconds. The signature may carry a "sha256=" prefix. Empty
+// header names use the defaults.
+type WebhookProvider struct {
+	Secret          string `json:"secret"`
+	SignatureHeader string `json:"signatureHeader,omitempty"`
+	TimestampHeader string `json:"timestampHeader,omitempty"`
+	NonceHeader     string `json:"nonceHeader,omitempty"`
+}
+
+// ParseWebhookProviders reads a JSON object mapping provider names to
+// their settings, e.g. {"payment":{"secret":"..."}}.
+func ParseWebhookProviders(raw string) (map[string]WebhookProvider, error) {
+	providers := map[string]WebhookProvider{}
+	if raw == "" {
+		return providers, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook providers: %w", err)
+	}
+	for name, p := range providers {
+		if p.Secret == "" {
+			return nil, fmt.Errorf("webhook provider %s has no secret", name)
+		}
+	}
+	return providers, nil
+}
+
+// SignWebhook returns the signature p expects for body sent at ts.
+func (p WebhookProvider) SignWebhook(ts time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.Secret))
+	fmt.Fprintf(mac, "%d.", ts.Unix())
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RedisNonceStore remembers webhook deliveries in Redis to detect replays.
+type RedisNonceStore struct {
+	client *redis.Client
+}
+
+var _ dedupe.Claimer = &RedisNonceStore{}
+
+func NewRedisNonceStore(client *redis.Client) *RedisNonceStore {
+	return &RedisNonceStore{client: client}
+}
+
+func (s *RedisNonceStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, "webhook:nonce:"+key, 1, ttl).Result()
+}
+
+func (s *RedisNonceStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, "webhook:nonce:"+key).Err()
+}
+
+// VerifyWebhook authenticates webhooks posted to a route with a :provider
+// parameter. Requests for unknown providers get 404, bad signatures 401,
+// timestamps further than tolerance from now 401, and deliveries already
+// seen within the window 409. A delivery is recognized by its signature,
+// which covers the timestamp and body, and by the provider's nonce header
+// if it sends one. The nonce is not signed, so it only catches a provider
+// re-signing a delivery it already sent; a captured request sent again
+// with another nonce is caught by its signature. Both are forgotten again
+// when the handler answers with a 5xx, so the provider can retry. If the
+// nonce store fails the request is let through; the timestamp window still
+// applies.
+func VerifyWebhook(providers map[string]WebhookProvider, nonces dedupe.Claimer, tolerance time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("provider")
+		p, ok := providers[name]
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown webhook provider"})
+			return
+		}
+
+		sec, err := strconv.ParseInt(c.GetHeader(headerOr(p.TimestampHeader, DefaultTimestampHeader)), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid webhook timestamp"})
+			return
+		}
+		ts := time.Unix(sec, 0)
+		if age := time.Since(ts); age > tolerance || age < -tolerance {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "webhook timestamp outside the tolerance window"})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		given := strings.TrimPrefix(c.GetHeader(headerOr(p.SignatureHeader, DefaultSignatureHeader)), "sha256=")
+		want := p.SignWebhook(ts, body)
+		if !hmac.Equal([]byte(strings.ToLower(given)), []byte(want)) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook signature"})
+			return
+		}
+
+		// The signature is claimed first, so a replay cannot claim the
+		// nonce of a delivery that is still to come.
+		keys := []string{name + ":sig:" + want}
+		if nonce := c.GetHeader(headerOr(p.NonceHeader, DefaultNonceHeader)); nonce != "" {
+			keys = append(keys, name+":"+nonce)
+		}
+		claimed, fresh := claimNonces(c.Request.Context(), nonces, keys, 2*tolerance)
+		release := func() {
+			for _, key := range claimed {
+				if err := nonces.Release(context.WithoutCancel(c.Request.Context()), key); err != nil {
+					log.Printf("Failed to release webhook nonce for %s: %v", name, err)
+				}
+			}
+		}
+		if !fresh {
+			release()
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "webhook already received"})
+			return
+		}
+
+		c.Set(providerKey, name)
+		c.Next()
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			release()
+		}
+	}
+}
+
+// claimNonces claims keys in order for ttl, stopping at the first that was
+// already claimed. It returns the keys it claimed and whether the delivery
+// is new. Store failures are logged and count as new. Past ttl the
+// timestamp check rejects the delivery anyway.
+func claimNonces(ctx context.Context, nonces dedupe.Claimer, keys []string, ttl time.Duration) ([]string, bool) {
+	var claimed []string
+	for _, key := range keys {
+		ok, err := nonces.Claim(ctx, key, ttl)
+		if err != nil {
+			log.Printf("Failed to check webhook nonce %s: %v", key, err)
+			continue
+		}
+		if !ok {
+			return claimed, false
+		}
+		claimed = append(claimed, key)
+	}
+	return claimed, true
+}
+
+// WebhookProviderName returns the provider whose webhook is being handled.
+func WebhookProviderName(c *gin.Context) string {
+	return c.GetString(providerKey)
+}
+
+func headerOr(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
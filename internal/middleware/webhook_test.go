@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type memoryNonces struct {
+	seen map[string]bool
+	err  error
+}
+
+func (m *memoryNonces) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.seen[key] {
+		return false, nil
+	}
+	m.seen[key] = true
+	return true, nil
+}
+
+func (m *memoryNonces) Release(ctx context.Context, key string) error {
+	delete(m.seen, key)
+	return nil
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := WebhookProvider{Secret: "s3cret"}
+	nonces := &memoryNonces{seen: map[string]bool{}}
+	router := gin.New()
+	router.POST("/webhooks/:provider", VerifyWebhook(map[string]WebhookProvider{"payment": provider}, nonces, 5*time.Minute),
+		func(c *gin.Context) {
+			if c.GetHeader("X-Fail") != "" {
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+			c.String(http.StatusOK, WebhookProviderName(c))
+		})
+
+	body := `{"type":"installment.paid"}`
+	fail := false
+	send := func(path string, ts time.Time, signature, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if fail {
+			req.Header.Set("X-Fail", "1")
+		}
+		req.Header.Set(DefaultTimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+		req.Header.Set(DefaultSignatureHeader, signature)
+		if id != "" {
+			req.Header.Set(DefaultNonceHeader, id)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	sign := func(ts time.Time) string { return "sha256=" + provider.SignWebhook(ts, []byte(body)) }
+	valid := sign(now)
+	if w := send("/webhooks/payment", now, valid, "evt-1"); w.Code != http.StatusOK || w.Body.String() != "payment" {
+		t.Fatalf("Expected 200 from payment, got %d %s", w.Code, w.Body)
+	}
+	if w := send("/webhooks/payment", now, valid, "evt-1"); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a replay, got %d", w.Code)
+	}
+	if w := send("/webhooks/payment", now, valid, "evt-forged"); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a replay with another delivery ID, got %d", w.Code)
+	}
+	if w := send("/webhooks/payment", now, valid, ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a replay without delivery ID, got %d", w.Code)
+	}
+	resigned := now.Add(time.Second)
+	if w := send("/webhooks/payment", resigned, sign(resigned), "evt-1"); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a delivery re-signed with the same ID, got %d", w.Code)
+	}
+	next := now.Add(2 * time.Second)
+	if w := send("/webhooks/payment", next, sign(next), "evt-2"); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for a new delivery, got %d", w.Code)
+	}
+	if w := send("/webhooks/payment", now, "sha256=00", "evt-3"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a bad signature, got %d", w.Code)
+	}
+	old := now.Add(-10 * time.Minute)
+	if w := send("/webhooks/payment", old, provider.SignWebhook(old, []byte(body)), "evt-4"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an old timestamp, got %d", w.Code)
+	}
+	if w := send("/webhooks/carrier", now, valid, "evt-5"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown provider, got %d", w.Code)
+	}
+
+	retried := now.Add(3 * time.Second)
+	fail = true
+	if w := send("/webhooks/payment", retried, sign(retried), "evt-6"); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected 500 from the failing handler, got %d", w.Code)
+	}
+	fail = false
+	if w := send("/webhooks/payment", retried, sign(retried), "evt-6"); w.Code != http.StatusOK {
+		t.Errorf("Expected a failed delivery to be retryable, got %d", w.Code)
+	}
+
+	nonces.err = errors.New("redis down")
+	if w := send("/webhooks/payment", now, valid, "evt-1"); w.Code != http.StatusOK {
+		t.Errorf("Expected the nonce check to fail open, got %d", w.Code)
+	}
+}
+
+func TestParseWebhookProviders(t *testing.T) {
+	providers, err := ParseWebhookProviders(`{"payment":{"secret":"a"},"carrier":{"secret":"b","signatureHeader":"X-Carrier-Signature"}}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if providers["carrier"].SignatureHeader != "X-Carrier-Signature" || providers["payment"].Secret != "a" {
+		t.Errorf("Unexpected providers %+v", providers)
+	}
+	if _, err := ParseWebhookProviders(`{"payment":{}}`); err == nil {
+		t.Error("Expected an error for a provider without a secret")
+	}
+}
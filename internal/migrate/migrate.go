@@ -0,0 +1,149 @@
+// Package migrate brings the database schema up to date safely when
+// several replicas start at once. Migrations run under a Postgres
+// advisory lock, so one replica migrates while the others wait, and each
+// applied schema is recorded by its fingerprint, so the replicas that
+// waited, and later restarts, find it done and skip AutoMigrate. Replicas
+// started without migrating wait for the schema they expect instead.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// lockID is the advisory lock held while migrating, shared by every
+// instance of the service.
+const lockID int64 = 0x6f72646572 // "order"
+
+var ErrPending = errors.New("database schema is not migrated")
+
+// Applied records a schema that was migrated.
+type Applied struct {
+	Fingerprint string    `gorm:"primaryKey" json:"fingerprint"`
+	Tables      string    `json:"tables"`
+	AppliedAt   time.Time `json:"appliedAt"`
+}
+
+func (Applied) TableName() string { return "schema_migrations" }
+
+// Fingerprint identifies the schema of models: their tables, columns and
+// indexes as gorm sees them. It changes whenever AutoMigrate would have
+// something to do on a database migrated to the previous schema.
+func Fingerprint(db *gorm.DB, models ...interface{}) (string, []string, error) {
+	cache := &sync.Map{}
+	var b strings.Builder
+	tables := make([]string, 0, len(models))
+	for _, model := range models {
+		s, err := schema.Parse(model, cache, db.NamingStrategy)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to parse %T: %w", model, err)
+		}
+		tables = append(tables, s.Table)
+		fmt.Fprintf(&b, "table %s\n", s.Table)
+		for _, f := range s.Fields {
+			if f.DBName == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "column %s %s %d %d %d %t %t %t %q\n",
+				f.DBName, f.DataType, f.Size, f.Precision, f.Scale, f.PrimaryKey, f.NotNull, f.Unique, f.DefaultValue)
+		}
+		indexes := s.ParseIndexes()
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+		for _, idx := range indexes {
+			fields := make([]string, len(idx.Fields))
+			for i, f := range idx.Fields {
+				fields[i] = f.DBName + " " + f.Sort + " " + f.Expression
+			}
+			fmt.Fprintf(&b, "index %s %s %s %q %q\n", idx.Name, idx.Class, idx.Type, idx.Where, fields)
+		}
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:16]), tables, nil
+}
+
+// Run migrates models unless their schema was already applied. It waits
+// for the advisory lock, so concurrent callers migrate one after the
+// other and all but the first find nothing to do.
+func Run(ctx context.Context, db *gorm.DB, models ...interface{}) error {
+	fingerprint, tables, err := Fingerprint(db, models...)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	// Advisory locks belong to a session, so lock and unlock on one
+	// connection.
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			log.Printf("Failed to release the migration lock: %v", err)
+		}
+	}()
+
+	db = db.WithContext(ctx)
+	if err := db.AutoMigrate(&Applied{}); err != nil {
+		return err
+	}
+	if done, err := applied(db, fingerprint); err != nil || done {
+		return err
+	}
+	start := time.Now()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.AutoMigrate(models...); err != nil {
+			return err
+		}
+		return tx.Create(&Applied{Fingerprint: fingerprint, Tables: strings.Join(tables, ","), AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Migrated schema %s (%d tables) in %s", fingerprint, len(tables), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// Check returns ErrPending unless the schema of models was applied, for
+// instances that leave migrating to others.
+func Check(ctx context.Context, db *gorm.DB, models ...interface{}) error {
+	fingerprint, _, err := Fingerprint(db, models...)
+	if err != nil {
+		return err
+	}
+	db = db.WithContext(ctx)
+	if !db.Migrator().HasTable(&Applied{}) {
+		return fmt.Errorf("%w: no migrations recorded", ErrPending)
+	}
+	done, err := applied(db, fingerprint)
+	if err != nil {
+		return err
+	}
+	if !done {
+		return fmt.Errorf("%w: schema %s not applied", ErrPending, fingerprint)
+	}
+	return nil
+}
+
+func applied(db *gorm.DB, fingerprint string) (bool, error) {
+	var n int64
+	err := db.Model(&Applied{}).Where("fingerprint = ?", fingerprint).Count(&n).Error
+	return n > 0, err
+}
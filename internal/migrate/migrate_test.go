@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+type widget struct {
+	ID   string `gorm:"primaryKey"`
+	Name string
+}
+
+type widgetWithColumn struct {
+	ID    string `gorm:"primaryKey"`
+	Name  string
+	Color string
+}
+
+func (widgetWithColumn) TableName() string { return "widgets" }
+
+type widgetWithIndex struct {
+	ID   string `gorm:"primaryKey"`
+	Name string `gorm:"index"`
+}
+
+func (widgetWithIndex) TableName() string { return "widgets" }
+
+func TestFingerprint(t *testing.T) {
+	db := &gorm.DB{Config: &gorm.Config{NamingStrategy: schema.NamingStrategy{}}}
+	fingerprint := func(models ...interface{}) string {
+		t.Helper()
+		f, _, err := Fingerprint(db, models...)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		return f
+	}
+
+	base := fingerprint(&widget{}, &Applied{})
+	if again := fingerprint(&widget{}, &Applied{}); again != base {
+		t.Errorf("Expected the same schema to have the same fingerprint, got %s and %s", base, again)
+	}
+	if f := fingerprint(&widgetWithColumn{}, &Applied{}); f == base {
+		t.Error("Expected a new column to change the fingerprint")
+	}
+	if f := fingerprint(&widgetWithIndex{}, &Applied{}); f == base {
+		t.Error("Expected a new index to change the fingerprint")
+	}
+	if f := fingerprint(&widget{}); f == base {
+		t.Error("Expected a dropped model to change the fingerprint")
+	}
+
+	_, tables, _ := Fingerprint(db, &widget{}, &Applied{})
+	if len(tables) != 2 || tables[0] != "widgets" || tables[1] != "schema_migrations" {
+		t.Errorf("Unexpected tables %v", tables)
+	}
+}
@@ -0,0 +1,97 @@
+// Package ordertemplate stores named orders a customer saved to place
+// again, such as "weekly coffee".
+package ordertemplate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidTemplate = errors.New("invalid order template")
+	ErrNotFound        = errors.New("order template not found")
+	ErrDuplicate       = errors.New("order template name already in use")
+)
+
+// Template is what an order placed from it is made of. Prices are not
+// saved; every order is priced and checked when it is placed.
+type Template struct {
+	ID           string    `gorm:"primaryKey" json:"id"`
+	CustomerID   string    `gorm:"not null;uniqueIndex:idx_order_templates_customer_name" json:"customerId"`
+	Name         string    `gorm:"not null;uniqueIndex:idx_order_templates_customer_name" json:"name"`
+	TenantID     string    `json:"tenantId,omitempty"`
+	ProductID    string    `gorm:"not null" json:"productId"`
+	Quantity     int       `gorm:"not null" json:"quantity"`
+	Region       string    `json:"region,omitempty"`
+	Country      string    `json:"country,omitempty"`
+	Currency     string    `json:"currency,omitempty"`
+	DiscountCode string    `json:"discountCode,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+func (Template) TableName() string { return "order_templates" }
+
+func (t Template) validate() error {
+	if t.CustomerID == "" || t.Name == "" || t.ProductID == "" {
+		return fmt.Errorf("%w: customerId, name and productId are required", ErrInvalidTemplate)
+	}
+	if t.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidTemplate)
+	}
+	return nil
+}
+
+// Store keeps templates in Postgres.
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Create(ctx context.Context, t *Template) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	t.ID = uuid.New().String()
+	if err := s.db.WithContext(ctx).Create(t).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*Template, error) {
+	var t Template
+	err := s.db.WithContext(ctx).First(&t, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &t, err
+}
+
+// List returns the customer's templates by name.
+func (s *Store) List(ctx context.Context, customerID string) ([]Template, error) {
+	var templates []Template
+	err := s.db.WithContext(ctx).Where("customer_id = ?", customerID).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+func (s *Store) Delete(ctx context.Context, id string) (*Template, error) {
+	t, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Delete(t).Error; err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,22 @@
+package ordertemplate
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Template{CustomerID: "c1", Name: "weekly coffee", ProductID: "p1", Quantity: 2}
+	if err := valid.validate(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	for name, tpl := range map[string]Template{
+		"no name":     {CustomerID: "c1", ProductID: "p1", Quantity: 1},
+		"no product":  {CustomerID: "c1", Name: "n", Quantity: 1},
+		"no quantity": {CustomerID: "c1", Name: "n", ProductID: "p1"},
+	} {
+		if err := tpl.validate(); !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s: expected ErrInvalidTemplate, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"order-service/internal/eventorder"
+
+	"gorm.io/gorm"
+)
+
+// Message is an event waiting to be published. ID orders messages so the
+// relay sends them in the order they were spooled.
+type Message struct {
+	ID      int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Pattern string `gorm:"not null" json:"pattern"`
+	Payload string `gorm:"type:jsonb;not null" json:"-"`
+	// SequenceKey and Sequence number the event within its order, see
+	// package eventorder. They are empty for events without a number.
+	SequenceKey string    `json:"sequenceKey,omitempty"`
+	Sequence    int64     `gorm:"not null;default:0" json:"sequence,omitempty"`
+	CreatedAt   time.Time `gorm:"not null" json:"createdAt"`
+}
+
+func (Message) TableName() string { return "outbox_messages" }
+
+// Data returns the event data to publish, numbered as it was spooled.
+func (m Message) Data() interface{} {
+	if m.SequenceKey == "" {
+		return json.RawMessage(m.Payload)
+	}
+	return eventorder.Sequenced{Key: m.SequenceKey, Sequence: m.Sequence, Data: json.RawMessage(m.Payload)}
+}
+
+// Store spools events in Postgres while they cannot be published.
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Add spools an event. The number of eventorder.Sequenced data is kept, so
+// the relayed event still carries it.
+func (s *Store) Add(pattern string, data interface{}) error {
+	return Spool(s.db, pattern, data)
+}
+
+// Spool adds an event in tx, so it is published if and only if the
+// changes it announces are committed.
+func Spool(tx *gorm.DB, pattern string, data interface{}) error {
+	key, sequence, data := eventorder.Unwrap(data)
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&Message{
+		Pattern:     pattern,
+		Payload:     string(payload),
+		SequenceKey: key,
+		Sequence:    sequence,
+		CreatedAt:   time.Now().UTC(),
+	}).Error
+}
+
+func (s *Store) Pending(ctx context.Context, limit int) ([]Message, error) {
+	var msgs []Message
+	err := s.db.WithContext(ctx).Order("id").Limit(limit).Find(&msgs).Error
+	return msgs, err
+}
+
+func (s *Store) Count(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.db.WithContext(ctx).Model(&Message{}).Count(&n).Error
+	return n, err
+}
+
+func (s *Store) Delete(ctx context.Context, id int64) error {
+	return s.db.WithContext(ctx).Delete(&Message{}, id).Error
+}
+
+// Publisher sends a spooled event to the broker.
+type Publisher interface {
+	Publish(pattern string, data interface{}) error
+}
+
+// Relay publishes spooled messages once the broker is reachable again.
+// A message is deleted only after it was published, so a crash in between
+// publishes it twice rather than losing it.
+type Relay struct {
+	store     *Store
+	publisher Publisher
+	batchSize int
+	paused    func() bool
+}
+
+// NewRelay returns a relay that does nothing while paused reports true.
+func NewRelay(store *Store, publisher Publisher, batchSize int, paused func() bool) *Relay {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Relay{store: store, publisher: publisher, batchSize: batchSize, paused: paused}
+}
+
+// Drain publishes pending messages in order until the outbox is empty or
+// a publish fails.
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	sent := 0
+	for {
+		if r.paused() {
+			return sent, nil
+		}
+		msgs, err := r.store.Pending(ctx, r.batchSize)
+		if err != nil || len(msgs) == 0 {
+			return sent, err
+		}
+		for _, m := range msgs {
+			if err := r.publisher.Publish(m.Pattern, m.Data()); err != nil {
+				return sent, err
+			}
+			if err := r.store.Delete(ctx, m.ID); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+		log.Printf("Relayed %d outbox messages", sent)
+	}
+}
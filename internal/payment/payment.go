@@ -0,0 +1,134 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPaymentDeclined = errors.New("payment declined")
+	ErrIntentNotFound  = errors.New("payment intent not found")
+)
+
+// Intent statuses as payment-service reports them.
+const (
+	IntentAuthorized = "AUTHORIZED"
+	IntentCaptured   = "CAPTURED"
+	IntentCancelled  = "CANCELLED"
+	IntentExpired    = "EXPIRED"
+)
+
+// Intent is a payment authorized by the customer but not captured yet.
+// ClientSecret is only returned when the intent is created; Status, Amount
+// and Currency only when it is looked up.
+type Intent struct {
+	ID           string    `json:"id"`
+	ClientSecret string    `json:"clientSecret,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Status       string    `json:"status,omitempty"`
+	Amount       float64   `json:"amount,omitempty"`
+	Currency     string    `json:"currency,omitempty"`
+}
+
+// HTTPGateway talks to payment-service's payment intent API.
+type HTTPGateway struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewHTTPGateway(baseURL string) *HTTPGateway {
+	return &HTTPGateway{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// CreateIntent authorizes amount for the order. The customer completes any
+// 3DS challenge with the returned client secret before the order is
+// confirmed.
+func (g *HTTPGateway) CreateIntent(ctx context.Context, orderID string, amount float64, currency string) (*Intent, error) {
+	var intent Intent
+	err := g.post(ctx, "/payment-intents", map[string]interface{}{
+		"orderId":  orderID,
+		"amount":   amount,
+		"currency": currency,
+	}, &intent)
+	if err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (g *HTTPGateway) Capture(ctx context.Context, intentID string) error {
+	return g.post(ctx, "/payment-intents/"+url.PathEscape(intentID)+"/capture", nil, nil)
+}
+
+func (g *HTTPGateway) Cancel(ctx context.Context, intentID string) error {
+	return g.post(ctx, "/payment-intents/"+url.PathEscape(intentID)+"/cancel", nil, nil)
+}
+
+// Refund returns the captured amount of the intent to the customer.
+func (g *HTTPGateway) Refund(ctx context.Context, intentID string) error {
+	return g.post(ctx, "/payment-intents/"+url.PathEscape(intentID)+"/refund", nil, nil)
+}
+
+// GetIntent returns the intent as payment-service has it now.
+func (g *HTTPGateway) GetIntent(ctx context.Context, intentID string) (*Intent, error) {
+	var intent Intent
+	if err := g.do(ctx, http.MethodGet, "/payment-intents/"+url.PathEscape(intentID), nil, &intent); err != nil {
+		return nil, err
+	}
+	return &intent, nil
+}
+
+func (g *HTTPGateway) post(ctx context.Context, path string, body, out interface{}) error {
+	return g.do(ctx, http.MethodPost, path, body, out)
+}
+
+func (g *HTTPGateway) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, g.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call payment service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPaymentRequired:
+		return ErrPaymentDeclined
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrIntentNotFound
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("payment service returned status: %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode payment response: %w", err)
+	}
+	return nil
+}
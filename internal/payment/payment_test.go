@@ -0,0 +1,77 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGatewayIntentActions(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/payment-intents/declined/capture":
+			w.WriteHeader(http.StatusPaymentRequired)
+		case "/payment-intents/missing/refund":
+			w.WriteHeader(http.StatusNotFound)
+		case "/payment-intents/broken/capture":
+			w.WriteHeader(http.StatusConflict)
+		}
+	}))
+	defer server.Close()
+	gateway := NewHTTPGateway(server.URL + "/")
+
+	cases := []struct {
+		name string
+		call func(ctx context.Context, intentID string) error
+		id   string
+		path string
+		want error
+	}{
+		{"capture", gateway.Capture, "pi_1", "POST /payment-intents/pi_1/capture", nil},
+		{"capture declined", gateway.Capture, "declined", "POST /payment-intents/declined/capture", ErrPaymentDeclined},
+		{"cancel", gateway.Cancel, "pi_1", "POST /payment-intents/pi_1/cancel", nil},
+		{"refund", gateway.Refund, "pi_1", "POST /payment-intents/pi_1/refund", nil},
+		{"refund unknown intent", gateway.Refund, "missing", "POST /payment-intents/missing/refund", ErrIntentNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls = nil
+			err := tc.call(context.Background(), tc.id)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("Expected %v, got %v", tc.want, err)
+			}
+			if len(calls) != 1 || calls[0] != tc.path {
+				t.Errorf("Expected %s, got %v", tc.path, calls)
+			}
+		})
+	}
+
+	t.Run("other statuses fail", func(t *testing.T) {
+		err := gateway.Capture(context.Background(), "broken")
+		if err == nil || errors.Is(err, ErrPaymentDeclined) {
+			t.Errorf("Expected a generic error, got %v", err)
+		}
+	})
+}
+
+func TestCreateIntent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/payment-intents" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"id":"pi_1","clientSecret":"secret"}`))
+	}))
+	defer server.Close()
+
+	intent, err := NewHTTPGateway(server.URL).CreateIntent(context.Background(), "order-1", 10, "IDR")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if intent.ID != "pi_1" || intent.ClientSecret != "secret" {
+		t.Errorf("Expected intent pi_1 with its secret, got %+v", intent)
+	}
+}
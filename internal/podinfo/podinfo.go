@@ -0,0 +1,51 @@
+// Package podinfo identifies the running instance. On Kubernetes the
+// downward API passes the pod's name, namespace, node and IP as
+// environment variables; elsewhere the host name stands in for the pod.
+package podinfo
+
+import "os"
+
+// Info is the identity of an instance. ID names it in logs, events and
+// readiness reports and is unique among running instances.
+type Info struct {
+	ID        string
+	PodName   string
+	Namespace string
+	NodeName  string
+	PodIP     string
+}
+
+// FromEnv reads POD_NAME, POD_NAMESPACE, NODE_NAME and POD_IP. The ID is
+// INSTANCE_ID if set, else the pod name, else the host name.
+func FromEnv() Info {
+	info := Info{
+		ID:        os.Getenv("INSTANCE_ID"),
+		PodName:   os.Getenv("POD_NAME"),
+		Namespace: os.Getenv("POD_NAMESPACE"),
+		NodeName:  os.Getenv("NODE_NAME"),
+		PodIP:     os.Getenv("POD_IP"),
+	}
+	if info.ID == "" {
+		info.ID = info.PodName
+	}
+	if info.ID == "" {
+		info.ID, _ = os.Hostname()
+	}
+	return info
+}
+
+// LogAttrs returns the fields of info that are set, as slog key-value
+// pairs for the logger of every record.
+func (info Info) LogAttrs() []any {
+	var attrs []any
+	for _, f := range []struct{ key, value string }{
+		{"instance", info.ID},
+		{"namespace", info.Namespace},
+		{"node", info.NodeName},
+	} {
+		if f.value != "" {
+			attrs = append(attrs, f.key, f.value)
+		}
+	}
+	return attrs
+}
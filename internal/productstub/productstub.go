@@ -0,0 +1,153 @@
+// Package productstub is a stand-in for product-service: it serves the
+// endpoints the order service calls from an in-memory catalog that can be
+// seeded from a JSON file or over HTTP. It is for local development and
+// tests only.
+package productstub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+var ErrInvalidProduct = errors.New("invalid product")
+
+// Product is a product as product-service returns it. Like there, the
+// price is encoded as a string.
+type Product struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	Price    float64 `json:"price,string"`
+	Qty      int     `json:"qty"`
+	Currency string  `json:"currency,omitempty"`
+	Category string  `json:"category,omitempty"`
+}
+
+func (p Product) validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("%w: id is required", ErrInvalidProduct)
+	}
+	if p.Price < 0 || p.Qty < 0 {
+		return fmt.Errorf("%w: price and qty must not be negative", ErrInvalidProduct)
+	}
+	return nil
+}
+
+// LoadFile reads products from a JSON array.
+func LoadFile(path string) ([]Product, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var products []Product
+	if err := json.Unmarshal(data, &products); err != nil {
+		return nil, fmt.Errorf("invalid fixtures %s: %w", path, err)
+	}
+	return products, nil
+}
+
+// Catalog holds the products the stub serves.
+type Catalog struct {
+	mu       sync.RWMutex
+	products map[string]Product
+}
+
+func NewCatalog(products []Product) (*Catalog, error) {
+	c := &Catalog{products: map[string]Product{}}
+	for _, p := range products {
+		if err := c.Put(p); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Put adds a product or replaces the one with the same ID.
+func (c *Catalog) Put(p Product) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.products[p.ID] = p
+	return nil
+}
+
+func (c *Catalog) Get(id string) (Product, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p, ok := c.products[id]
+	return p, ok
+}
+
+// List returns the products sorted by ID.
+func (c *Catalog) List() []Product {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	products := make([]Product, 0, len(c.products))
+	for _, p := range c.products {
+		products = append(products, p)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products
+}
+
+func (c *Catalog) Delete(id string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.products[id]
+	delete(c.products, id)
+	return ok
+}
+
+// Handler serves GET /health and GET /products/{id}, which the order
+// service calls, plus GET /products, and PUT and DELETE /products/{id} to
+// change the catalog while it runs.
+func (c *Catalog) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("GET /products", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.List())
+	})
+	mux.HandleFunc("GET /products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		p, ok := c.Get(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "product not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+	mux.HandleFunc("PUT /products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var p Product
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		p.ID = r.PathValue("id")
+		if err := c.Put(p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+	mux.HandleFunc("DELETE /products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !c.Delete(r.PathValue("id")) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "product not found"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
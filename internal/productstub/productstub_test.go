@@ -0,0 +1,72 @@
+package productstub
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCatalogHandler(t *testing.T) {
+	catalog, err := NewCatalog([]Product{{ID: "p1", Name: "One", Price: 10.5, Qty: 3}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	h := catalog.Handler()
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(http.MethodGet, "/products/p1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var raw map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &raw)
+	if raw["price"] != "10.5" || raw["qty"] != float64(3) {
+		t.Errorf("Expected the product-service format, got %s", w.Body)
+	}
+
+	if w := serve(http.MethodGet, "/products/p2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown product, got %d", w.Code)
+	}
+
+	if w := serve(http.MethodPut, "/products/p2", `{"name":"Two","price":"4","qty":1}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if p, ok := catalog.Get("p2"); !ok || p.Price != 4 || p.Qty != 1 {
+		t.Errorf("Expected p2 to be stored, got %+v", p)
+	}
+	if w := serve(http.MethodPut, "/products/p3", `{"price":"1","qty":-1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for negative stock, got %d", w.Code)
+	}
+
+	var products []Product
+	json.Unmarshal(serve(http.MethodGet, "/products", "").Body.Bytes(), &products)
+	if len(products) != 2 || products[0].ID != "p1" || products[1].ID != "p2" {
+		t.Errorf("Expected p1 and p2, got %+v", products)
+	}
+
+	if w := serve(http.MethodDelete, "/products/p1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/products/p1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected p1 to be gone, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/health", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 from /health, got %d", w.Code)
+	}
+}
+
+func TestLoadFixtures(t *testing.T) {
+	products, err := LoadFile("../../cmd/stub-product-service/fixtures.json")
+	if err != nil {
+		t.Fatalf("Expected the bundled fixtures to load, got %v", err)
+	}
+	if _, err := NewCatalog(products); err != nil {
+		t.Errorf("Expected valid fixtures, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package quarantine stores consumed messages that could not be handled,
+// with the class of their failure, so they can be reprocessed once the
+// cause is fixed.
+package quarantine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/internal/consumer"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusQuarantined = "QUARANTINED"
+	StatusReprocessed = "REPROCESSED"
+	StatusDiscarded   = "DISCARDED"
+)
+
+var (
+	ErrNotFound     = errors.New("quarantined message not found")
+	ErrNotPending   = errors.New("message was already reprocessed or discarded")
+	ErrUnknownQueue = errors.New("no handler for the message's queue")
+)
+
+// Message is a quarantined message. Attempts counts the reprocessing
+// attempts and Error is the cause of the last failure.
+type Message struct {
+	ID            string     `gorm:"primaryKey" json:"id"`
+	Queue         string     `gorm:"not null;index" json:"queue"`
+	Body          string     `gorm:"type:text;not null" json:"body"`
+	Class         string     `gorm:"not null;index" json:"class"`
+	Error         string     `json:"error"`
+	Status        string     `gorm:"not null;index" json:"status"`
+	Attempts      int        `json:"attempts"`
+	QuarantinedAt time.Time  `json:"quarantinedAt"`
+	ReprocessedAt *time.Time `json:"reprocessedAt,omitempty"`
+}
+
+func (Message) TableName() string { return "quarantined_messages" }
+
+// Filter narrows List; empty fields match everything.
+type Filter struct {
+	Queue  string
+	Class  string
+	Status string
+}
+
+// Store keeps quarantined messages in Postgres.
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Add quarantines a message. It implements consumer.IQuarantine.
+func (s *Store) Add(ctx context.Context, queue string, body []byte, class string, cause error) error {
+	return s.db.WithContext(ctx).Create(&Message{
+		ID:            uuid.New().String(),
+		Queue:         queue,
+		Body:          string(body),
+		Class:         class,
+		Error:         cause.Error(),
+		Status:        StatusQuarantined,
+		QuarantinedAt: time.Now().UTC(),
+	}).Error
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*Message, error) {
+	var m Message
+	err := s.db.WithContext(ctx).First(&m, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &m, err
+}
+
+// List returns messages, most recently quarantined first.
+func (s *Store) List(ctx context.Context, filter Filter, limit, offset int) ([]Message, error) {
+	q := s.db.WithContext(ctx).Model(&Message{})
+	if filter.Queue != "" {
+		q = q.Where("queue = ?", filter.Queue)
+	}
+	if filter.Class != "" {
+		q = q.Where("class = ?", filter.Class)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	var messages []Message
+	err := q.Order("quarantined_at DESC, id").Limit(limit).Offset(offset).Find(&messages).Error
+	return messages, err
+}
+
+// Reprocess runs a quarantined message through the handler of its queue
+// again. On success it is marked REPROCESSED; otherwise it stays
+// quarantined with the new failure, which is returned. The message stays
+// locked while its handler runs, so a concurrent Reprocess or Discard of
+// it waits and then finds it no longer pending.
+func (s *Store) Reprocess(ctx context.Context, id string, handlers map[string]consumer.HandlerFunc, classify consumer.Classifier) (*Message, error) {
+	var m *Message
+	var handleErr error
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if m, err = pending(tx, id); err != nil {
+			return err
+		}
+		handler, ok := handlers[m.Queue]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownQueue, m.Queue)
+		}
+
+		m.Attempts++
+		handleErr = consumer.Handle(ctx, handler, []byte(m.Body))
+		if handleErr == nil {
+			now := time.Now().UTC()
+			m.Status, m.ReprocessedAt = StatusReprocessed, &now
+		} else {
+			m.Class, m.Error = classify(handleErr), handleErr.Error()
+		}
+		return tx.Model(m).Select("Status", "Attempts", "Class", "Error", "ReprocessedAt").Updates(m).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, handleErr
+}
+
+// Discard gives up on a quarantined message.
+func (s *Store) Discard(ctx context.Context, id string) (*Message, error) {
+	var m *Message
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if m, err = pending(tx, id); err != nil {
+			return err
+		}
+		m.Status = StatusDiscarded
+		return tx.Model(m).Update("status", m.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// pending locks a message in tx until it commits, and fails unless the
+// message is still quarantined.
+func pending(tx *gorm.DB, id string) (*Message, error) {
+	var m Message
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&m, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if m.Status != StatusQuarantined {
+		return nil, ErrNotPending
+	}
+	return &m, nil
+}
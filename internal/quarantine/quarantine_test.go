@@ -0,0 +1,176 @@
+package quarantine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"order-service/internal/consumer"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+const lockQuery = `SELECT * FROM "quarantined_messages" WHERE id = $1 ORDER BY "quarantined_messages"."id" LIMIT $2 FOR UPDATE`
+
+func messageRows(status string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "queue", "body", "class", "error", "status", "attempts", "quarantined_at"}).
+		AddRow("m1", "stock", `{"pattern":"product.stock_replenished","data":{"productId":"p1"}}`, consumer.ClassTransient, "timeout", status, 1, time.Now())
+}
+
+func TestReprocess(t *testing.T) {
+	ctx := context.Background()
+	var handled []string
+	handlers := map[string]consumer.HandlerFunc{
+		"stock": func(_ context.Context, data json.RawMessage) error {
+			handled = append(handled, string(data))
+			return nil
+		},
+	}
+
+	t.Run("marks the message reprocessed", func(t *testing.T) {
+		db, mock := mockDB(t)
+		handled = nil
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(lockQuery)).WithArgs("m1", 1).WillReturnRows(messageRows(StatusQuarantined))
+		mock.ExpectExec(quoted(`UPDATE "quarantined_messages" SET "class"=$1,"error"=$2,"status"=$3,"attempts"=$4,"reprocessed_at"=$5 WHERE "id" = $6`)).
+			WithArgs(consumer.ClassTransient, "timeout", StatusReprocessed, 2, sqlmock.AnyArg(), "m1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		m, err := NewStore(db).Reprocess(ctx, "m1", handlers, consumer.Classify)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if m.Status != StatusReprocessed || m.Attempts != 2 || m.ReprocessedAt == nil {
+			t.Errorf("Expected a reprocessed message, got %+v", m)
+		}
+		if len(handled) != 1 || handled[0] != `{"productId":"p1"}` {
+			t.Errorf("Expected the handler to get the message's data, got %v", handled)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("a failure keeps the message quarantined", func(t *testing.T) {
+		db, mock := mockDB(t)
+		failing := map[string]consumer.HandlerFunc{
+			"stock": func(context.Context, json.RawMessage) error { return consumer.ErrSchema },
+		}
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(lockQuery)).WillReturnRows(messageRows(StatusQuarantined))
+		mock.ExpectExec(quoted(`UPDATE "quarantined_messages" SET`)).
+			WithArgs(consumer.ClassSchema, consumer.ErrSchema.Error(), StatusQuarantined, 2, nil, "m1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		m, err := NewStore(db).Reprocess(ctx, "m1", failing, consumer.Classify)
+		if !errors.Is(err, consumer.ErrSchema) {
+			t.Fatalf("Expected the handler's error, got %v", err)
+		}
+		if m == nil || m.Status != StatusQuarantined || m.Class != consumer.ClassSchema {
+			t.Errorf("Expected the message to stay quarantined as schema, got %+v", m)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("a message claimed by someone else is not run again", func(t *testing.T) {
+		db, mock := mockDB(t)
+		handled = nil
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(lockQuery)).WillReturnRows(messageRows(StatusReprocessed))
+		mock.ExpectRollback()
+
+		if _, err := NewStore(db).Reprocess(ctx, "m1", handlers, consumer.Classify); !errors.Is(err, ErrNotPending) {
+			t.Errorf("Expected ErrNotPending, got %v", err)
+		}
+		if len(handled) != 0 {
+			t.Errorf("Expected the handler not to run, got %v", handled)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("unknown message or queue", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(lockQuery)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(lockQuery)).WillReturnRows(messageRows(StatusQuarantined))
+		mock.ExpectRollback()
+
+		store := NewStore(db)
+		if _, err := store.Reprocess(ctx, "m1", handlers, consumer.Classify); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if _, err := store.Reprocess(ctx, "m1", nil, consumer.Classify); !errors.Is(err, ErrUnknownQueue) {
+			t.Errorf("Expected ErrUnknownQueue, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestDiscard(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("discards a quarantined message", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(lockQuery)).WithArgs("m1", 1).WillReturnRows(messageRows(StatusQuarantined))
+		mock.ExpectExec(quoted(`UPDATE "quarantined_messages" SET "status"=$1 WHERE "id" = $2`)).
+			WithArgs(StatusDiscarded, "m1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		m, err := NewStore(db).Discard(ctx, "m1")
+		if err != nil || m.Status != StatusDiscarded {
+			t.Errorf("Expected a discarded message, got %+v, %v", m, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("refuses a message that is no longer pending", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(quoted(lockQuery)).WillReturnRows(messageRows(StatusDiscarded))
+		mock.ExpectRollback()
+
+		if _, err := NewStore(db).Discard(ctx, "m1"); !errors.Is(err, ErrNotPending) {
+			t.Errorf("Expected ErrNotPending, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
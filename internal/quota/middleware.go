@@ -0,0 +1,54 @@
+package quota
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"order-service/internal/i18n"
+	"order-service/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CodeQuotaExceeded is the error code of requests over the caller's quota.
+const CodeQuotaExceeded = "QUOTA_EXCEEDED"
+
+// Middleware counts every request and answers 429 once the caller's quota
+// is used up. If the counters cannot be reached the request is let
+// through.
+func (q *Quotas) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+		route = c.Request.Method + " " + route
+		ctx := c.Request.Context()
+		d, err := q.Allow(ctx, q.SubjectOf(ctx, c.GetHeader(KeyHeader)), route)
+		if err != nil {
+			log.Printf("Failed to count request to %s against its quota, letting it through: %v", route, err)
+			c.Next()
+			return
+		}
+		if d.Limit > 0 {
+			c.Header("X-Quota-Limit", strconv.FormatInt(d.Limit, 10))
+			c.Header("X-Quota-Remaining", strconv.FormatInt(d.Remaining, 10))
+			c.Header("X-Quota-Reset", strconv.FormatInt(d.Reset.Unix(), 10))
+		}
+		if !d.Allowed {
+			metrics.QuotaRejected.WithLabelValues(route).Inc()
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(d.Reset).Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":   "quota exceeded",
+				"code":    CodeQuotaExceeded,
+				"message": i18n.T(ctx, CodeQuotaExceeded, "quota exceeded"),
+			})
+			return
+		}
+		c.Next()
+	}
+}
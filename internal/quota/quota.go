@@ -0,0 +1,238 @@
+// Package quota counts API requests per caller and UTC day, per route and
+// in total, and refuses requests once the caller's daily quota is used up.
+// Counters live in Redis so every instance enforces the same quota; they
+// are flushed to Postgres, where usage is reported from for billing.
+package quota
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"order-service/internal/tenant"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// KeyHeader carries the caller's API key, as passed on by the gateway.
+const KeyHeader = "X-API-Key"
+
+// Total is the route under which a caller's requests to all routes are
+// counted.
+const Total = "*"
+
+// Rule is a daily quota. Daily caps the requests to all routes, Routes the
+// requests to single routes, named like "POST /orders". Zero means no
+// limit.
+type Rule struct {
+	Daily  int64            `json:"daily,omitempty"`
+	Routes map[string]int64 `json:"routes,omitempty"`
+}
+
+// Config holds the quotas of tenants and API keys. Keys are named by their
+// ID (see KeyID) so the configuration holds no secrets. Callers without a
+// rule of their own get Default.
+type Config struct {
+	Default Rule            `json:"default"`
+	Tenants map[string]Rule `json:"tenants,omitempty"`
+	Keys    map[string]Rule `json:"keys,omitempty"`
+}
+
+// Parse reads a quota config from JSON. An empty string yields no quotas;
+// requests are still counted.
+func Parse(data string) (Config, error) {
+	var cfg Config
+	if data == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		return Config{}, fmt.Errorf("failed to parse request quotas: %w", err)
+	}
+	return cfg, Validate(cfg)
+}
+
+// Validate rejects negative quotas.
+func Validate(cfg Config) error {
+	check := func(name string, r Rule) error {
+		if r.Daily < 0 {
+			return fmt.Errorf("daily quota of %s must not be negative", name)
+		}
+		for route, n := range r.Routes {
+			if n < 0 {
+				return fmt.Errorf("quota of %s for %s must not be negative", name, route)
+			}
+		}
+		return nil
+	}
+	if err := check("default", cfg.Default); err != nil {
+		return err
+	}
+	for id, r := range cfg.Tenants {
+		if err := check("tenant "+id, r); err != nil {
+			return err
+		}
+	}
+	for id, r := range cfg.Keys {
+		if err := check("key "+id, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Subject is who requests are counted for: a tenant or an API key.
+type Subject struct {
+	Kind string
+	ID   string
+}
+
+// Kinds of subjects.
+const (
+	KindTenant    = "tenant"
+	KindKey       = "key"
+	KindAnonymous = "anonymous"
+)
+
+func (s Subject) String() string {
+	if s.Kind == KindAnonymous {
+		return s.Kind
+	}
+	return s.Kind + ":" + s.ID
+}
+
+// KeyID identifies an API key without revealing it: the first 12 hex
+// digits of its SHA-256.
+func KeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// SubjectOf counts a request for its API key if the key has a quota of its
+// own, else for its tenant. The service cannot tell a made-up key from a
+// real one, so a key without a rule would let every new value start over
+// with the default quota.
+func (q *Quotas) SubjectOf(ctx context.Context, apiKey string) Subject {
+	if apiKey != "" {
+		id := KeyID(apiKey)
+		q.mu.RLock()
+		_, known := q.cfg.Keys[id]
+		q.mu.RUnlock()
+		if known {
+			return Subject{Kind: KindKey, ID: id}
+		}
+	}
+	if id := tenant.FromContext(ctx); id != "" {
+		return Subject{Kind: KindTenant, ID: id}
+	}
+	return Subject{Kind: KindAnonymous}
+}
+
+// Decision is the outcome of counting a request.
+type Decision struct {
+	Allowed bool
+	// Limit and Remaining describe the quota that is closest to being
+	// used up; Limit is 0 if the caller has no quota.
+	Limit     int64
+	Remaining int64
+	// Reset is when the quotas start over.
+	Reset time.Time
+}
+
+// counter counts the requests of a day in a shared store.
+type counter interface {
+	// count adds a request unless it would exceed daily or routeLimit,
+	// and returns the counts including it.
+	count(ctx context.Context, day, subject, route string, daily, routeLimit int64) (allowed bool, total, routeCount int64, err error)
+	// usage returns the counts of a day by subject and route.
+	usage(ctx context.Context, day string) (map[key]Counts, error)
+}
+
+type key struct {
+	Subject string
+	Route   string
+}
+
+// Counts are the requests counted and refused for a subject and route.
+type Counts struct {
+	Requests int64 `json:"requests"`
+	Rejected int64 `json:"rejected"`
+}
+
+// Quotas enforces the configured quotas.
+type Quotas struct {
+	counter counter
+	db      *gorm.DB
+	now     func() time.Time
+
+	mu  sync.RWMutex
+	cfg Config
+}
+
+func New(client *redis.Client, db *gorm.DB, cfg Config) *Quotas {
+	return &Quotas{counter: redisCounter{client: client}, db: db, now: time.Now, cfg: cfg}
+}
+
+// SetConfig replaces the quotas. Requests already counted today count
+// against the new quotas.
+func (q *Quotas) SetConfig(cfg Config) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.cfg = cfg
+}
+
+func (q *Quotas) rule(s Subject) Rule {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	var rules map[string]Rule
+	switch s.Kind {
+	case KindTenant:
+		rules = q.cfg.Tenants
+	case KindKey:
+		rules = q.cfg.Keys
+	}
+	if r, ok := rules[s.ID]; ok {
+		return r
+	}
+	return q.cfg.Default
+}
+
+// Allow counts a request of s to route and decides whether it is within
+// the quotas. Refused requests are counted too, but not against the quota.
+func (q *Quotas) Allow(ctx context.Context, s Subject, route string) (Decision, error) {
+	now := q.now().UTC()
+	day := now.Truncate(24 * time.Hour)
+	r := q.rule(s)
+	routeLimit := r.Routes[route]
+
+	allowed, total, routeCount, err := q.counter.count(ctx, dayKey(day), s.String(), route, r.Daily, routeLimit)
+	if err != nil {
+		return Decision{Allowed: true}, err
+	}
+	d := Decision{Allowed: allowed, Reset: day.AddDate(0, 0, 1)}
+	d.Limit, d.Remaining = remaining(r.Daily, total, routeLimit, routeCount)
+	return d, nil
+}
+
+// remaining picks the quota with the fewest requests left.
+func remaining(daily, total, routeLimit, routeCount int64) (limit, left int64) {
+	left = -1
+	for _, l := range [][2]int64{{daily, total}, {routeLimit, routeCount}} {
+		if l[0] <= 0 {
+			continue
+		}
+		n := max(l[0]-l[1], 0)
+		if left < 0 || n < left {
+			limit, left = l[0], n
+		}
+	}
+	return limit, max(left, 0)
+}
+
+func dayKey(day time.Time) string {
+	return day.Format(time.DateOnly)
+}
@@ -0,0 +1,193 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"order-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeCounter counts in memory like the Redis script does.
+type fakeCounter struct {
+	requests map[key]int64
+	rejected map[key]int64
+	err      error
+}
+
+func newFakeCounter() *fakeCounter {
+	return &fakeCounter{requests: map[key]int64{}, rejected: map[key]int64{}}
+}
+
+func (f *fakeCounter) count(_ context.Context, day, subject, route string, daily, routeLimit int64) (bool, int64, int64, error) {
+	if f.err != nil {
+		return false, 0, 0, f.err
+	}
+	tk, rk := key{subject, Total}, key{subject, route}
+	if (daily > 0 && f.requests[tk] >= daily) || (routeLimit > 0 && f.requests[rk] >= routeLimit) {
+		f.rejected[tk]++
+		f.rejected[rk]++
+		return false, f.requests[tk], f.requests[rk], nil
+	}
+	f.requests[tk]++
+	f.requests[rk]++
+	return true, f.requests[tk], f.requests[rk], nil
+}
+
+func (f *fakeCounter) usage(context.Context, string) (map[key]Counts, error) {
+	out := map[key]Counts{}
+	for k, n := range f.requests {
+		out[k] = Counts{Requests: n, Rejected: f.rejected[k]}
+	}
+	return out, nil
+}
+
+func newTestQuotas(cfg Config) (*Quotas, *fakeCounter) {
+	counter := newFakeCounter()
+	return &Quotas{
+		counter: counter,
+		now:     func() time.Time { return time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC) },
+		cfg:     cfg,
+	}, counter
+}
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse(`{"default":{"daily":100},"tenants":{"acme":{"daily":1000,"routes":{"POST /orders":50}}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Default.Daily != 100 || cfg.Tenants["acme"].Routes["POST /orders"] != 50 {
+		t.Errorf("Unexpected config %+v", cfg)
+	}
+	if _, err := Parse(`{"keys":{"ab12":{"daily":-1}}}`); err == nil {
+		t.Error("Expected a negative quota to be rejected")
+	}
+	if cfg, err := Parse(""); err != nil || cfg.Default.Daily != 0 {
+		t.Errorf("Expected no quotas by default, got %+v, %v", cfg, err)
+	}
+}
+
+func TestAllow(t *testing.T) {
+	q, _ := newTestQuotas(Config{
+		Default: Rule{Daily: 3},
+		Tenants: map[string]Rule{"acme": {Daily: 10, Routes: map[string]int64{"POST /orders": 2}}},
+	})
+	ctx := context.Background()
+	acme := Subject{Kind: KindTenant, ID: "acme"}
+
+	for i, want := range []bool{true, true, false} {
+		d, err := q.Allow(ctx, acme, "POST /orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed != want {
+			t.Errorf("Expected request %d allowed=%t, got %+v", i+1, want, d)
+		}
+	}
+	d, _ := q.Allow(ctx, acme, "GET /orders")
+	if !d.Allowed || d.Limit != 10 || d.Remaining != 7 {
+		t.Errorf("Expected other routes to count against the daily quota only, got %+v", d)
+	}
+	if want := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC); !d.Reset.Equal(want) {
+		t.Errorf("Expected the quota to reset at %s, got %s", want, d.Reset)
+	}
+
+	other := Subject{Kind: KindTenant, ID: "other"}
+	for range 3 {
+		q.Allow(ctx, other, "GET /orders")
+	}
+	if d, _ := q.Allow(ctx, other, "GET /orders"); d.Allowed || d.Limit != 3 || d.Remaining != 0 {
+		t.Errorf("Expected tenants without a rule to get the default quota, got %+v", d)
+	}
+}
+
+func TestSubjectOf(t *testing.T) {
+	q, _ := newTestQuotas(Config{Keys: map[string]Rule{KeyID("secret"): {Daily: 10}}})
+	ctx := tenant.WithTenant(context.Background(), "acme")
+	if s := q.SubjectOf(ctx, ""); s.String() != "tenant:acme" {
+		t.Errorf("Expected the tenant, got %s", s)
+	}
+	if s := q.SubjectOf(ctx, "secret"); s.Kind != KindKey || s.ID != KeyID("secret") || len(s.ID) != 12 {
+		t.Errorf("Expected the API key's ID, got %s", s)
+	}
+	if s := q.SubjectOf(ctx, "made-up"); s.String() != "tenant:acme" {
+		t.Errorf("Expected a key without a rule to count for the tenant, got %s", s)
+	}
+	if s := q.SubjectOf(context.Background(), "made-up"); s.String() != "anonymous" {
+		t.Errorf("Expected anonymous, got %s", s)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	q, counter := newTestQuotas(Config{Default: Rule{Daily: 1}})
+	router := gin.New()
+	router.Use(tenant.Middleware(""))
+	router.GET("/orders", q.Middleware(), func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	requests := 0
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		req.Header.Set(tenant.Header, "acme")
+		// A fresh key on every request must not reset the quota.
+		requests++
+		req.Header.Set(KeyHeader, fmt.Sprintf("made-up-%d", requests))
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusNoContent || w.Header().Get("X-Quota-Remaining") != "0" {
+		t.Errorf("Expected the first request through with no quota left, got %d %v", w.Code, w.Header())
+	}
+	w := get()
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("Expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	var body struct{ Code string }
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.Code != CodeQuotaExceeded {
+		t.Errorf("Expected %s, got %s", CodeQuotaExceeded, w.Body)
+	}
+	if got := counter.rejected[key{"tenant:acme", "GET /orders"}]; got != 1 {
+		t.Errorf("Expected the refused request to be counted, got %d", got)
+	}
+
+	counter.err = errors.New("redis down")
+	if w := get(); w.Code != http.StatusNoContent {
+		t.Errorf("Expected requests through while Redis is down, got %d", w.Code)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	day1, day2 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	rows := []DailyUsage{
+		{Day: day2, Subject: "tenant:acme", Route: Total, Requests: 5, Rejected: 1},
+		{Day: day2, Subject: "tenant:acme", Route: "POST /orders", Requests: 5, Rejected: 1},
+		{Day: day1, Subject: "tenant:acme", Route: Total, Requests: 3},
+		{Day: day1, Subject: "tenant:acme", Route: "POST /orders", Requests: 2},
+		{Day: day1, Subject: "tenant:acme", Route: "GET /orders", Requests: 1},
+		{Day: day1, Subject: "key:ab12", Route: Total, Requests: 1},
+	}
+	usage := summarize(rows, Filter{From: day1, To: day2})
+	if len(usage) != 2 || usage[0].Subject != "key:ab12" {
+		t.Fatalf("Expected 2 subjects sorted by name, got %+v", usage)
+	}
+	acme := usage[1]
+	if acme.Requests != 8 || acme.Rejected != 1 || acme.From != "2024-03-01" || acme.To != "2024-03-02" {
+		t.Errorf("Expected the totals over the period, got %+v", acme)
+	}
+	if acme.Routes["POST /orders"] != (Counts{Requests: 7, Rejected: 1}) || acme.Routes["GET /orders"].Requests != 1 {
+		t.Errorf("Expected the requests per route, got %v", acme.Routes)
+	}
+	if len(acme.Days) != 2 || acme.Days[0].Day != "2024-03-01" || acme.Days[1].Requests != 5 {
+		t.Errorf("Expected the requests per day in order, got %+v", acme.Days)
+	}
+}
@@ -0,0 +1,94 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// retention is how long the counters of a day are kept in Redis: long
+// enough for the last flush of the day to pick them up.
+const retention = 3 * 24 * time.Hour
+
+var countScript = redis.NewScript(countLua)
+
+// countLua counts a request in the requests hash (KEYS[1]) under the
+// subject's total (ARGV[1]) and route (ARGV[2]) fields, unless the daily
+// (ARGV[3]) or route (ARGV[4]) quota is used up, in which case it is
+// counted in the rejected hash (KEYS[2]). It returns whether the request
+// was allowed and the counts of the requests hash.
+const countLua = `
+local total = tonumber(redis.call('HGET', KEYS[1], ARGV[1]) or '0')
+local route = tonumber(redis.call('HGET', KEYS[1], ARGV[2]) or '0')
+local daily = tonumber(ARGV[3])
+local routeLimit = tonumber(ARGV[4])
+if (daily > 0 and total >= daily) or (routeLimit > 0 and route >= routeLimit) then
+	redis.call('HINCRBY', KEYS[2], ARGV[1], 1)
+	redis.call('HINCRBY', KEYS[2], ARGV[2], 1)
+	redis.call('PEXPIRE', KEYS[2], ARGV[5])
+	return {0, total, route}
+end
+total = redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+route = redis.call('HINCRBY', KEYS[1], ARGV[2], 1)
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return {1, total, route}
+`
+
+// redisCounter keeps one hash of requests and one of rejected requests
+// per day, with a field per subject and route.
+type redisCounter struct {
+	client *redis.Client
+}
+
+func requestsKey(day string) string { return "quota:requests:" + day }
+func rejectedKey(day string) string { return "quota:rejected:" + day }
+
+// field joins subject and route; subjects hold no spaces and routes start
+// with the method, so the first space separates them.
+func field(subject, route string) string { return subject + " " + route }
+
+func (c redisCounter) count(ctx context.Context, day, subject, route string, daily, routeLimit int64) (bool, int64, int64, error) {
+	res, err := countScript.Run(ctx, c.client,
+		[]string{requestsKey(day), rejectedKey(day)},
+		field(subject, Total), field(subject, route), daily, routeLimit, retention.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return false, 0, 0, err
+	}
+	if len(res) != 3 {
+		return false, 0, 0, fmt.Errorf("unexpected quota script result %v", res)
+	}
+	return res[0] == 1, res[1], res[2], nil
+}
+
+func (c redisCounter) usage(ctx context.Context, day string) (map[key]Counts, error) {
+	requests, err := c.client.HGetAll(ctx, requestsKey(day)).Result()
+	if err != nil {
+		return nil, err
+	}
+	rejected, err := c.client.HGetAll(ctx, rejectedKey(day)).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[key]Counts, len(requests))
+	add := func(fields map[string]string, set func(*Counts, int64)) {
+		for f, v := range fields {
+			subject, route, ok := strings.Cut(f, " ")
+			n, err := strconv.ParseInt(v, 10, 64)
+			if !ok || err != nil {
+				continue
+			}
+			k := key{Subject: subject, Route: route}
+			counts := out[k]
+			set(&counts, n)
+			out[k] = counts
+		}
+	}
+	add(requests, func(c *Counts, n int64) { c.Requests = n })
+	add(rejected, func(c *Counts, n int64) { c.Rejected = n })
+	return out, nil
+}
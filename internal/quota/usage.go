@@ -0,0 +1,133 @@
+package quota
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DailyUsage is a subject's requests to a route, or to all routes (Total),
+// on one UTC day, as last flushed from Redis.
+type DailyUsage struct {
+	Day       time.Time `gorm:"primaryKey;type:date"`
+	Subject   string    `gorm:"primaryKey"`
+	Route     string    `gorm:"primaryKey"`
+	Requests  int64     `gorm:"not null"`
+	Rejected  int64     `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func (DailyUsage) TableName() string { return "request_usage_daily" }
+
+// Flush copies the counters of yesterday and today from Redis to Postgres
+// and returns how many rows it wrote. Counts only grow, so a flush after
+// Redis lost its counters does not lower them.
+func (q *Quotas) Flush(ctx context.Context) (int, error) {
+	today := q.now().UTC().Truncate(24 * time.Hour)
+	now := time.Now().UTC()
+	var rows []DailyUsage
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		usage, err := q.counter.usage(ctx, dayKey(day))
+		if err != nil {
+			return 0, err
+		}
+		for k, c := range usage {
+			rows = append(rows, DailyUsage{Day: day, Subject: k.Subject, Route: k.Route, Requests: c.Requests, Rejected: c.Rejected, UpdatedAt: now})
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	err := q.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "subject"}, {Name: "route"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":   gorm.Expr("GREATEST(request_usage_daily.requests, excluded.requests)"),
+			"rejected":   gorm.Expr("GREATEST(request_usage_daily.rejected, excluded.rejected)"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).CreateInBatches(rows, 500).Error
+	return len(rows), err
+}
+
+// Filter selects the usage to report. From and To are UTC days, both
+// included.
+type Filter struct {
+	Subject string
+	From    time.Time
+	To      time.Time
+}
+
+// DayCounts are a subject's requests to all routes on one day.
+type DayCounts struct {
+	Day string `json:"day"`
+	Counts
+}
+
+// Usage is a subject's requests over a period.
+type Usage struct {
+	Subject string `json:"subject"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Counts
+	Routes map[string]Counts `json:"routes"`
+	Days   []DayCounts       `json:"days"`
+	// UpdatedAt is when the counts were last flushed from Redis.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Usage sums the flushed usage of every subject, or of filter.Subject,
+// sorted by subject.
+func (q *Quotas) Usage(ctx context.Context, filter Filter) ([]Usage, error) {
+	db := q.db.WithContext(ctx).Where("day BETWEEN ? AND ?", filter.From, filter.To)
+	if filter.Subject != "" {
+		db = db.Where("subject = ?", filter.Subject)
+	}
+	var rows []DailyUsage
+	if err := db.Order("day").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return summarize(rows, filter), nil
+}
+
+func summarize(rows []DailyUsage, filter Filter) []Usage {
+	bySubject := map[string]*Usage{}
+	for _, r := range rows {
+		u, ok := bySubject[r.Subject]
+		if !ok {
+			u = &Usage{
+				Subject: r.Subject,
+				From:    dayKey(filter.From),
+				To:      dayKey(filter.To),
+				Routes:  map[string]Counts{},
+				Days:    []DayCounts{},
+			}
+			bySubject[r.Subject] = u
+		}
+		if r.UpdatedAt.After(u.UpdatedAt) {
+			u.UpdatedAt = r.UpdatedAt
+		}
+		counts := Counts{Requests: r.Requests, Rejected: r.Rejected}
+		if r.Route == Total {
+			u.Requests += r.Requests
+			u.Rejected += r.Rejected
+			u.Days = append(u.Days, DayCounts{Day: dayKey(r.Day), Counts: counts})
+			continue
+		}
+		sum := u.Routes[r.Route]
+		sum.Requests += counts.Requests
+		sum.Rejected += counts.Rejected
+		u.Routes[r.Route] = sum
+	}
+
+	out := make([]Usage, 0, len(bySubject))
+	for _, u := range bySubject {
+		slices.SortFunc(u.Days, func(a, b DayCounts) int { return cmp.Compare(a.Day, b.Day) })
+		out = append(out, *u)
+	}
+	slices.SortFunc(out, func(a, b Usage) int { return cmp.Compare(a.Subject, b.Subject) })
+	return out
+}
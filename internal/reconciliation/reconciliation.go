@@ -0,0 +1,117 @@
+// Package reconciliation stores the mismatches found between the payment
+// status of orders and payment-service's records of their payments.
+package reconciliation
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kinds of discrepancies.
+const (
+	// KindMissingIntent: payment-service does not know the order's intent.
+	KindMissingIntent = "missing_intent"
+	// KindCapturedNotConfirmed: the payment was captured but the order is
+	// still awaiting payment.
+	KindCapturedNotConfirmed = "captured_not_confirmed"
+	// KindNotCaptured: the order was confirmed but its payment was not
+	// captured.
+	KindNotCaptured = "not_captured"
+	// KindCapturedForClosedOrder: the payment was captured for an order
+	// that expired, was rejected or cancelled.
+	KindCapturedForClosedOrder = "captured_for_closed_order"
+	// KindAmountMismatch: the intent is for another amount or currency
+	// than the order.
+	KindAmountMismatch = "amount_mismatch"
+)
+
+// Discrepancy is one mismatch of an order. It is found again on every run
+// until it is fixed, when it is marked resolved.
+type Discrepancy struct {
+	ID              string     `gorm:"primaryKey" json:"id"`
+	OrderID         string     `gorm:"not null;uniqueIndex:idx_payment_discrepancies_order_kind" json:"orderId"`
+	Kind            string     `gorm:"not null;uniqueIndex:idx_payment_discrepancies_order_kind;index" json:"kind"`
+	PaymentIntentID string     `json:"paymentIntentId"`
+	OrderStatus     string     `json:"orderStatus"`
+	PaymentStatus   string     `json:"paymentStatus,omitempty"`
+	OrderAmount     float64    `json:"orderAmount"`
+	PaymentAmount   float64    `json:"paymentAmount,omitempty"`
+	Currency        string     `json:"currency"`
+	DetectedAt      time.Time  `json:"detectedAt"`
+	LastSeenAt      time.Time  `json:"lastSeenAt"`
+	ResolvedAt      *time.Time `gorm:"index" json:"resolvedAt,omitempty"`
+}
+
+func (Discrepancy) TableName() string { return "payment_discrepancies" }
+
+// Filter narrows List. Without Resolved only open discrepancies are
+// returned.
+type Filter struct {
+	Kind     string
+	OrderID  string
+	Resolved bool
+}
+
+// Store keeps discrepancies in Postgres.
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Record stores what a run found for an order: found discrepancies are
+// added or seen again, and the order's other open ones are resolved.
+func (s *Store) Record(ctx context.Context, orderID string, found []Discrepancy, now time.Time) error {
+	now = now.UTC()
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		kinds := make([]string, 0, len(found))
+		for i := range found {
+			d := &found[i]
+			d.ID = uuid.New().String()
+			d.OrderID = orderID
+			d.DetectedAt, d.LastSeenAt, d.ResolvedAt = now, now, nil
+			kinds = append(kinds, d.Kind)
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "order_id"}, {Name: "kind"}},
+				DoUpdates: clause.AssignmentColumns([]string{
+					"payment_intent_id", "order_status", "payment_status", "order_amount",
+					"payment_amount", "currency", "last_seen_at", "resolved_at",
+				}),
+			}).Create(d).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		q := tx.Model(&Discrepancy{}).Where("order_id = ? AND resolved_at IS NULL", orderID)
+		if len(kinds) > 0 {
+			q = q.Where("kind NOT IN ?", kinds)
+		}
+		return q.Update("resolved_at", now).Error
+	})
+}
+
+// List returns discrepancies, most recently seen first.
+func (s *Store) List(ctx context.Context, filter Filter, limit, offset int) ([]Discrepancy, error) {
+	q := s.db.WithContext(ctx).Model(&Discrepancy{})
+	if filter.Resolved {
+		q = q.Where("resolved_at IS NOT NULL")
+	} else {
+		q = q.Where("resolved_at IS NULL")
+	}
+	if filter.Kind != "" {
+		q = q.Where("kind = ?", filter.Kind)
+	}
+	if filter.OrderID != "" {
+		q = q.Where("order_id = ?", filter.OrderID)
+	}
+	var discrepancies []Discrepancy
+	err := q.Order("last_seen_at DESC, id").Limit(limit).Offset(offset).Find(&discrepancies).Error
+	return discrepancies, err
+}
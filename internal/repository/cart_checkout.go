@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrCartCheckedOut = errors.New("cart already checked out")
+
+// CartCheckout marks a cart as checked out. Its primary key stops two
+// concurrent checkouts of the same cart from both storing orders.
+type CartCheckout struct {
+	CartID    string `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,32 @@
+package repository
+
+import "gorm.io/gorm"
+
+// Changes reported to hooks.
+const (
+	ChangeCreated   = "created"
+	ChangeValidated = "validated"
+	ChangeActivated = "activated"
+)
+
+// Hook is called inside the transaction that stores a change of order, so
+// what it writes through tx is committed if and only if the change is. An
+// error rolls the change back.
+type Hook func(tx *gorm.DB, change string, order *Order) error
+
+// OnChange adds a hook. Hooks are added while the app is wired, before any
+// order is written.
+func (r *OrderRepository) OnChange(hook Hook) {
+	r.hooks = append(r.hooks, hook)
+}
+
+func (r *OrderRepository) runHooks(tx *gorm.DB, change string, orders ...*Order) error {
+	for _, hook := range r.hooks {
+		for _, order := range orders {
+			if err := hook(tx, change, order); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+// spoolHook writes a row in the transaction it is given, standing in for
+// hooks such as the analytics spool.
+func spoolHook(seen *[]string) Hook {
+	return func(tx *gorm.DB, change string, order *Order) error {
+		*seen = append(*seen, change+" "+order.ID)
+		return tx.Exec("INSERT INTO spooled (order_id) VALUES (?)", order.ID).Error
+	}
+}
+
+func TestHooks(t *testing.T) {
+	t.Run("create runs hooks in the order's transaction", func(t *testing.T) {
+		db, mock := mockDB(t)
+		repo := NewOrderRepository(db, 0)
+		var seen []string
+		repo.OnChange(spoolHook(&seen))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`INSERT INTO "orders"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(quoted(`INSERT INTO "order_revisions"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(quoted(`INSERT INTO spooled`)).WithArgs("o1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := repo.Create(&Order{ID: "o1", Status: StatusPending}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(seen) != 1 || seen[0] != "created o1" {
+			t.Errorf("Expected the hook to see created o1, got %v", seen)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("a failing hook rolls the order back", func(t *testing.T) {
+		db, mock := mockDB(t)
+		repo := NewOrderRepository(db, 0)
+		var seen []string
+		repo.OnChange(spoolHook(&seen))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`INSERT INTO "orders"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(quoted(`INSERT INTO "order_revisions"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec(quoted(`INSERT INTO spooled`)).WillReturnError(errors.New("disk full"))
+		mock.ExpectRollback()
+
+		if err := repo.Create(&Order{ID: "o1", Status: StatusPending}); err == nil {
+			t.Fatal("Expected the hook's error")
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("create batch runs hooks for every order", func(t *testing.T) {
+		db, mock := mockDB(t)
+		repo := NewOrderRepository(db, 0)
+		var seen []string
+		repo.OnChange(spoolHook(&seen))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`INSERT INTO "orders"`)).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(quoted(`INSERT INTO "order_revisions"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		mock.ExpectExec(quoted(`INSERT INTO spooled`)).WithArgs("o1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`INSERT INTO spooled`)).WithArgs("o2").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := repo.CreateBatch([]Order{{ID: "o1"}, {ID: "o2"}}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(seen) != 2 || seen[1] != "created o2" {
+			t.Errorf("Expected the hook to see both orders, got %v", seen)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("validation is reported in the reprice transaction", func(t *testing.T) {
+		db, mock := mockDB(t)
+		repo := NewOrderRepository(db, 0)
+		var seen []string
+		repo.OnChange(spoolHook(&seen))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`UPDATE "orders" SET`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`UPDATE "orders" SET`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(quoted(`SELECT`)).WillReturnRows(sqlmock.NewRows([]string{"id", "status"}).AddRow("o1", StatusPending))
+		mock.ExpectQuery(quoted(`SELECT`)).WillReturnRows(sqlmock.NewRows([]string{"number"}).AddRow(1))
+		mock.ExpectQuery(quoted(`INSERT INTO "order_revisions"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectExec(quoted(`INSERT INTO spooled`)).WithArgs("o1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if err := repo.CompleteValidation(&Order{ID: "o1", Status: StatusPending}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(seen) != 1 || seen[0] != "validated o1" {
+			t.Errorf("Expected the hook to see validated o1, got %v", seen)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"errors"
+	"order-service/internal/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInstallmentNotFound = errors.New("installment not found")
+	ErrInstallmentPaid     = errors.New("installment already paid")
+)
+
+// Installment is one scheduled payment of an order paid in parts. The
+// amounts of an order's installments add up to its TotalPrice.
+type Installment struct {
+	ID        string    `gorm:"type:uuid;primary_key;"`
+	OrderID   string    `gorm:"type:uuid;not null;uniqueIndex:idx_installment_order_sequence"`
+	Sequence  int       `gorm:"not null;uniqueIndex:idx_installment_order_sequence"`
+	Amount    float64   `gorm:"not null"`
+	DueAt     time.Time `gorm:"not null"`
+	PaidAt    *time.Time
+	PaymentID string
+}
+
+// GetInstallments returns the order's payment schedule in sequence order.
+func (r *OrderRepository) GetInstallments(orderID string) ([]Installment, error) {
+	var installments []Installment
+	err := r.db.Where("order_id = ?", orderID).Order("sequence").Find(&installments).Error
+	return installments, err
+}
+
+// MarkInstallmentPaid records the payment of one installment and adds its
+// amount to the order's PaidAmount. Once no installment is left unpaid a
+// PENDING order moves to PAID. The order row is locked for the duration so
+// concurrent payments of the same order cannot miss the last installment.
+func (r *OrderRepository) MarkInstallmentPaid(orderID string, sequence int, paymentID string) (*Order, error) {
+	var order Order
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID).First(&order).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrOrderNotFound
+		} else if err != nil {
+			return err
+		}
+
+		var inst Installment
+		err = tx.Where("order_id = ? AND sequence = ?", orderID, sequence).First(&inst).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInstallmentNotFound
+		} else if err != nil {
+			return err
+		}
+		if inst.PaidAt != nil {
+			return ErrInstallmentPaid
+		}
+
+		err = tx.Model(&inst).Updates(map[string]interface{}{"paid_at": time.Now(), "payment_id": paymentID}).Error
+		if err != nil {
+			return err
+		}
+		order.PaidAmount += inst.Amount
+
+		var unpaid int64
+		if err := tx.Model(&Installment{}).Where("order_id = ? AND paid_at IS NULL", orderID).Count(&unpaid).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"paid_amount": order.PaidAmount}
+		if unpaid == 0 && domain.CanTransition(order.Status, StatusPaid) {
+			order.Status = StatusPaid
+			for column, v := range statusChange(StatusPaid) {
+				updates[column] = v
+			}
+		}
+		err = tx.Model(&Order{}).Where("id = ?", orderID).Updates(updates).Error
+		if err != nil {
+			return err
+		}
+		return recordRevision(tx, orderID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
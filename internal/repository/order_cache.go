@@ -1,9 +1,14 @@
 package repository
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"order-service/internal/jsonenc"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -12,44 +17,220 @@ import (
 type IOrderCache interface {
 	Get(key string) ([]Order, error)
 	Set(key string, orders []Order) error
+	// Append adds order to the list cached at key, if there is one.
+	Append(key string, order Order) error
+	Delete(key string) error
 	GetCacheKeyForProduct(productID string) string
 }
 
+// DefaultCacheTTL is how long product order lists stay cached unless
+// SetTTL changes it.
+const DefaultCacheTTL = 60 * time.Second
+
+// DefaultCompressionThreshold is the encoded size above which cached
+// values are compressed unless SetCompressionThreshold changes it.
+const DefaultCompressionThreshold = 16 << 10
+
+// Cached values start with a byte naming their encoding. Values written
+// before the header existed are plain JSON and start with '[' or 'n'.
+const (
+	encodingJSON byte = 0x00
+	encodingGzip byte = 0x01
+)
+
 type OrderCache struct {
-	client *redis.Client
-	ctx    context.Context
+	client               redis.UniversalClient
+	ctx                  context.Context
+	ttl                  atomic.Int64
+	compressionThreshold atomic.Int64
+	encoder              jsonenc.Encoder
 }
 
 var _ IOrderCache = &OrderCache{}
 
-func NewOrderCache(client *redis.Client) *OrderCache {
-	return &OrderCache{
-		client: client,
-		ctx:    context.Background(),
+// NewOrderCache caches on client, which may be a single Redis or a ring of
+// shards.
+func NewOrderCache(client redis.UniversalClient) *OrderCache {
+	c := &OrderCache{
+		client:  client,
+		ctx:     context.Background(),
+		encoder: jsonenc.Fast{},
 	}
+	c.SetTTL(DefaultCacheTTL)
+	c.SetCompressionThreshold(DefaultCompressionThreshold)
+	return c
+}
+
+// SetTTL changes the expiry of entries written from now on.
+func (c *OrderCache) SetTTL(ttl time.Duration) {
+	c.ttl.Store(int64(ttl))
+}
+
+// SetEncoder changes how values are encoded. Call it before the cache is
+// used.
+func (c *OrderCache) SetEncoder(enc jsonenc.Encoder) {
+	c.encoder = enc
+}
+
+// SetCompressionThreshold changes the size in bytes above which values
+// written from now on are gzipped. Zero or less turns compression off.
+func (c *OrderCache) SetCompressionThreshold(n int) {
+	c.compressionThreshold.Store(int64(n))
 }
 
 func (c *OrderCache) Get(key string) ([]Order, error) {
-	val, err := c.client.Get(c.ctx, key).Result()
+	val, err := c.client.Get(c.ctx, key).Bytes()
 	if err == redis.Nil {
-		return nil, nil 
+		return nil, nil
 	} else if err != nil {
 		return nil, err
 	}
+	return decodeOrders(val)
+}
+
+func (c *OrderCache) Set(key string, orders []Order) error {
+	val, err := encodeOrders(c.encoder, orders, int(c.compressionThreshold.Load()))
+	if err != nil {
+		return err
+	}
+	return c.client.Set(c.ctx, key, val, time.Duration(c.ttl.Load())).Err()
+}
+
+// orderSizeHint is roughly the encoded size of an order.
+const orderSizeHint = 512
+
+func encodeOrders(enc jsonenc.Encoder, orders []Order, threshold int) ([]byte, error) {
+	val := make([]byte, 1, 1+len(orders)*orderSizeHint)
+	val[0] = encodingJSON
+	val, err := enc.Append(val, OrderList(orders))
+	if err != nil {
+		return nil, err
+	}
+	raw := val[1:]
+	if threshold <= 0 || len(raw) <= threshold {
+		return val, nil
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(encodingGzip)
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
+func decodeOrders(val []byte) ([]Order, error) {
+	if len(val) == 0 {
+		return nil, fmt.Errorf("empty cache value")
+	}
+	switch val[0] {
+	case encodingJSON:
+		val = val[1:]
+	case encodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(val[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress cache value: %w", err)
+		}
+		if val, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("failed to decompress cache value: %w", err)
+		}
+	case '[', 'n':
+		// Written before values had an encoding header.
+	default:
+		return nil, fmt.Errorf("unknown cache value encoding 0x%02x", val[0])
+	}
 	var orders []Order
-	err = json.Unmarshal([]byte(val), &orders)
+	err := json.Unmarshal(val, &orders)
 	return orders, err
 }
 
-func (c *OrderCache) Set(key string, orders []Order) error {
-	val, err := json.Marshal(orders)
+// appendScript appends ARGV[1], an encoded order, to the plain JSON list
+// at KEYS[1] and keeps its expiry. Lists that are compressed or would grow
+// past ARGV[2] bytes are deleted instead, so the next read reloads them.
+var appendScript = redis.NewScript(`
+local v = redis.call('GET', KEYS[1])
+if not v then
+  return 0
+end
+local body = string.sub(v, 2)
+local threshold = tonumber(ARGV[2])
+if string.byte(v, 1) ~= 0 or (string.sub(body, -1) ~= ']' and body ~= 'null') or
+    (threshold > 0 and #body + #ARGV[1] + 1 > threshold) then
+  redis.call('DEL', KEYS[1])
+  return 0
+end
+local list
+if body == '[]' or body == 'null' then
+  list = '[' .. ARGV[1] .. ']'
+else
+  list = string.sub(body, 1, -2) .. ',' .. ARGV[1] .. ']'
+end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+  redis.call('SET', KEYS[1], string.char(0) .. list, 'PX', ttl)
+else
+  redis.call('SET', KEYS[1], string.char(0) .. list)
+end
+return 1
+`)
+
+func (c *OrderCache) Append(key string, order Order) error {
+	val, err := c.encoder.Append(nil, &order)
 	if err != nil {
 		return err
 	}
-	return c.client.Set(c.ctx, key, val, 60*time.Second).Err()
+	return appendScript.Run(c.ctx, c.client, []string{key}, val, c.compressionThreshold.Load()).Err()
+}
+
+func (c *OrderCache) Delete(key string) error {
+	return c.client.Del(c.ctx, key).Err()
 }
 
 func (c *OrderCache) GetCacheKeyForProduct(productID string) string {
 	return fmt.Sprintf("orders:product:%s", productID)
-}
\ No newline at end of file
+}
+
+// BypassableCache skips the wrapped cache while bypass reports true: reads
+// miss and writes are dropped, so requests go to the database when Redis
+// is down instead of waiting on it.
+type BypassableCache struct {
+	IOrderCache
+	bypass func() bool
+}
+
+var _ IOrderCache = &BypassableCache{}
+
+func NewBypassableCache(cache IOrderCache, bypass func() bool) *BypassableCache {
+	return &BypassableCache{IOrderCache: cache, bypass: bypass}
+}
+
+func (c *BypassableCache) Get(key string) ([]Order, error) {
+	if c.bypass() {
+		return nil, nil
+	}
+	return c.IOrderCache.Get(key)
+}
+
+func (c *BypassableCache) Set(key string, orders []Order) error {
+	if c.bypass() {
+		return nil
+	}
+	return c.IOrderCache.Set(key, orders)
+}
+
+func (c *BypassableCache) Append(key string, order Order) error {
+	if c.bypass() {
+		return nil
+	}
+	return c.IOrderCache.Append(key, order)
+}
+
+func (c *BypassableCache) Delete(key string) error {
+	if c.bypass() {
+		return nil
+	}
+	return c.IOrderCache.Delete(key)
+}
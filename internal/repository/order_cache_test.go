@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"order-service/internal/jsonenc"
+	"testing"
+)
+
+func TestOrderCacheEncoding(t *testing.T) {
+	orders := []Order{{ID: "o1", ProductID: "p1", Quantity: 2}, {ID: "o2", ProductID: "p1", Quantity: 3}}
+
+	for _, tc := range []struct {
+		name      string
+		threshold int
+		encoding  byte
+	}{
+		{"below threshold", 1 << 20, encodingJSON},
+		{"above threshold", 1, encodingGzip},
+		{"compression off", 0, encodingJSON},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			val, err := encodeOrders(jsonenc.Fast{}, orders, tc.threshold)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if val[0] != tc.encoding {
+				t.Errorf("Expected encoding 0x%02x, got 0x%02x", tc.encoding, val[0])
+			}
+			decoded, err := decodeOrders(val)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(decoded) != 2 || decoded[1].ID != "o2" || decoded[1].Quantity != 3 {
+				t.Errorf("Expected the orders back, got %+v", decoded)
+			}
+		})
+	}
+
+	decoded, err := decodeOrders([]byte(`[{"id":"o1"}]`))
+	if err != nil || len(decoded) != 1 {
+		t.Errorf("Expected values without a header to decode as JSON, got %+v, %v", decoded, err)
+	}
+	if _, err := decodeOrders([]byte{0x7f}); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultCountTTL is how long product order counts stay cached unless
+// SetTTL changes it.
+const DefaultCountTTL = 5 * time.Minute
+
+// countTotal is the hash field holding a product's count over all
+// statuses.
+const countTotal = "total"
+
+// incrementScript adds one order in status ARGV[1] to the counts at
+// KEYS[1], if they are cached. Counts that are not cached are left for the
+// next read to load whole.
+var incrementScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HINCRBY", KEYS[1], ARGV[1], 1)
+redis.call("HINCRBY", KEYS[1], "total", 1)
+return 1
+`)
+
+// OrderCounts caches how many orders each product has per status, in a
+// Redis hash per product, so counting does not load the orders.
+type OrderCounts struct {
+	client redis.UniversalClient
+	ttl    atomic.Int64
+	bypass func() bool
+}
+
+func NewOrderCounts(client redis.UniversalClient) *OrderCounts {
+	c := &OrderCounts{client: client, bypass: func() bool { return false }}
+	c.SetTTL(DefaultCountTTL)
+	return c
+}
+
+// SetTTL changes the expiry of counts loaded from now on. Increments keep
+// the expiry of the counts they change, so counts are reloaded from the
+// database at least this often.
+func (c *OrderCounts) SetTTL(ttl time.Duration) {
+	c.ttl.Store(int64(ttl))
+}
+
+// SetBypass skips Redis while bypass reports true, like BypassableCache:
+// reads miss and writes are dropped. Call it before the counts are used.
+func (c *OrderCounts) SetBypass(bypass func() bool) {
+	c.bypass = bypass
+}
+
+func (c *OrderCounts) key(productID string) string {
+	return "orders:count:product:" + productID
+}
+
+// Get returns the product's counts per status, and false if they are not
+// cached.
+func (c *OrderCounts) Get(ctx context.Context, productID string) (map[string]int64, bool, error) {
+	if c.bypass() {
+		return nil, false, nil
+	}
+	fields, err := c.client.HGetAll(ctx, c.key(productID)).Result()
+	if err != nil || len(fields) == 0 {
+		return nil, false, err
+	}
+	counts := make(map[string]int64, len(fields))
+	for status, v := range fields {
+		if status == countTotal {
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, false, err
+		}
+		counts[status] = n
+	}
+	return counts, true, nil
+}
+
+// Set caches the product's counts per status.
+func (c *OrderCounts) Set(ctx context.Context, productID string, counts map[string]int64) error {
+	if c.bypass() {
+		return nil
+	}
+	key := c.key(productID)
+	var total int64
+	fields := make([]interface{}, 0, 2*len(counts)+2)
+	for status, n := range counts {
+		fields = append(fields, status, n)
+		total += n
+	}
+	// The total is always written, so a product without orders is cached
+	// too.
+	fields = append(fields, countTotal, total)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, fields...)
+		pipe.PExpire(ctx, key, time.Duration(c.ttl.Load()))
+		return nil
+	})
+	return err
+}
+
+// Increment counts a new order of the product in status, if the product's
+// counts are cached.
+func (c *OrderCounts) Increment(ctx context.Context, productID, status string) error {
+	if c.bypass() {
+		return nil
+	}
+	return incrementScript.Run(ctx, c.client, []string{c.key(productID)}, status).Err()
+}
+
+// Delete drops the product's counts, for when an order changed status.
+func (c *OrderCounts) Delete(ctx context.Context, productID string) error {
+	if c.bypass() {
+		return nil
+	}
+	return c.client.Del(ctx, c.key(productID)).Err()
+}
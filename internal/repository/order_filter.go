@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderFilter narrows down which orders a query returns. Zero values are
+// ignored.
+type OrderFilter struct {
+	ProductID   string
+	CustomerID  string
+	CartID      string
+	Status      string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Tags only matches orders that have all of them.
+	Tags []string
+}
+
+func (f OrderFilter) apply(tx *gorm.DB) *gorm.DB {
+	if f.ProductID != "" {
+		tx = tx.Where("product_id = ?", f.ProductID)
+	}
+	if f.CustomerID != "" {
+		tx = tx.Where("customer_id = ?", f.CustomerID)
+	}
+	if f.CartID != "" {
+		tx = tx.Where("cart_id = ?", f.CartID)
+	}
+	if f.Status != "" {
+		tx = tx.Where("status = ?", f.Status)
+	}
+	if !f.CreatedFrom.IsZero() {
+		tx = tx.Where("created_at >= ?", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		tx = tx.Where("created_at < ?", f.CreatedTo)
+	}
+	for _, tag := range f.Tags {
+		tx = tx.Where("EXISTS (SELECT 1 FROM order_tags WHERE order_tags.order_id = orders.id AND order_tags.tag = ?)", tag)
+	}
+	return tx
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"encoding/json"
+	"order-service/internal/jsonenc"
+	"strconv"
+	"time"
+)
+
+var (
+	_ jsonenc.Appender = &Order{}
+	_ jsonenc.Appender = OrderList(nil)
+)
+
+// AppendJSON appends the encoding/json encoding of the order without
+// reflection. It has to list every field of Order; TestOrderJSON fails
+// when one is missing.
+func (o *Order) AppendJSON(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, `{"ID":`...)
+	dst = jsonenc.AppendString(dst, o.ID)
+	dst = append(dst, `,"ProductID":`...)
+	dst = jsonenc.AppendString(dst, o.ProductID)
+	dst = append(dst, `,"CustomerID":`...)
+	dst = jsonenc.AppendString(dst, o.CustomerID)
+	dst = append(dst, `,"TenantID":`...)
+	dst = jsonenc.AppendString(dst, o.TenantID)
+	dst = append(dst, `,"CartID":`...)
+	dst = jsonenc.AppendString(dst, o.CartID)
+	if dst, err = appendFloatField(dst, `,"Subtotal":`, o.Subtotal); err != nil {
+		return nil, err
+	}
+	dst = append(dst, `,"DiscountCode":`...)
+	dst = jsonenc.AppendString(dst, o.DiscountCode)
+	if dst, err = appendFloatField(dst, `,"DiscountAmount":`, o.DiscountAmount); err != nil {
+		return nil, err
+	}
+	if dst, err = appendFloatField(dst, `,"TaxAmount":`, o.TaxAmount); err != nil {
+		return nil, err
+	}
+	if dst, err = appendFloatField(dst, `,"ShippingFee":`, o.ShippingFee); err != nil {
+		return nil, err
+	}
+	if dst, err = appendFloatField(dst, `,"TotalPrice":`, o.TotalPrice); err != nil {
+		return nil, err
+	}
+	dst = append(dst, `,"Quantity":`...)
+	dst = strconv.AppendInt(dst, int64(o.Quantity), 10)
+	if o.Region != "" {
+		dst = append(dst, `,"Region":`...)
+		dst = jsonenc.AppendString(dst, o.Region)
+	}
+	if o.Country != "" {
+		dst = append(dst, `,"Country":`...)
+		dst = jsonenc.AppendString(dst, o.Country)
+	}
+	dst = append(dst, `,"Status":`...)
+	dst = jsonenc.AppendString(dst, o.Status)
+	dst = append(dst, `,"Experiment":`...)
+	dst = jsonenc.AppendString(dst, o.Experiment)
+	dst = append(dst, `,"Variant":`...)
+	dst = jsonenc.AppendString(dst, o.Variant)
+	dst = append(dst, `,"HoldReason":`...)
+	dst = jsonenc.AppendString(dst, o.HoldReason)
+	dst = append(dst, `,"PaymentIntentID":`...)
+	dst = jsonenc.AppendString(dst, o.PaymentIntentID)
+	dst = append(dst, `,"PaymentExpiresAt":`...)
+	if dst, err = jsonenc.AppendTime(dst, o.PaymentExpiresAt); err != nil {
+		return nil, err
+	}
+	if o.PaymentClientSecret != "" {
+		dst = append(dst, `,"PaymentClientSecret":`...)
+		dst = jsonenc.AppendString(dst, o.PaymentClientSecret)
+	}
+	if dst, err = appendFloatField(dst, `,"PaidAmount":`, o.PaidAmount); err != nil {
+		return nil, err
+	}
+	// Installments, tenders and tags are rare in lists; encoding/json does
+	// them.
+	if len(o.Installments) > 0 {
+		if dst, err = appendStdField(dst, `,"Installments":`, o.Installments); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.Tenders) > 0 {
+		if dst, err = appendStdField(dst, `,"Tenders":`, o.Tenders); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.Tags) > 0 {
+		if dst, err = appendStdField(dst, `,"Tags":`, o.Tags); err != nil {
+			return nil, err
+		}
+	}
+	dst = append(dst, `,"Currency":`...)
+	dst = jsonenc.AppendString(dst, o.Currency)
+	if o.ConvertedCurrency != "" {
+		dst = append(dst, `,"ConvertedCurrency":`...)
+		dst = jsonenc.AppendString(dst, o.ConvertedCurrency)
+	}
+	if o.ExchangeRate != 0 {
+		if dst, err = appendFloatField(dst, `,"ExchangeRate":`, o.ExchangeRate); err != nil {
+			return nil, err
+		}
+	}
+	if o.ConvertedTotal != 0 {
+		if dst, err = appendFloatField(dst, `,"ConvertedTotal":`, o.ConvertedTotal); err != nil {
+			return nil, err
+		}
+	}
+	if dst, err = appendTimeField(dst, `,"ProcessAt":`, o.ProcessAt); err != nil {
+		return nil, err
+	}
+	if dst, err = appendTimeField(dst, `,"DeliverAt":`, o.DeliverAt); err != nil {
+		return nil, err
+	}
+	if dst, err = appendTimeField(dst, `,"ReservedUntil":`, o.ReservedUntil); err != nil {
+		return nil, err
+	}
+	dst = append(dst, `,"CreatedAt":`...)
+	if dst, err = jsonenc.AppendTime(dst, o.CreatedAt); err != nil {
+		return nil, err
+	}
+	if dst, err = appendTimeField(dst, `,"StatusChangedAt":`, o.StatusChangedAt); err != nil {
+		return nil, err
+	}
+	if dst, err = appendTimeField(dst, `,"SLABreachedAt":`, o.SLABreachedAt); err != nil {
+		return nil, err
+	}
+	return append(dst, '}'), nil
+}
+
+// OrderList is a list of orders that encodes without reflection.
+type OrderList []Order
+
+func (l OrderList) AppendJSON(dst []byte) ([]byte, error) {
+	if l == nil {
+		return append(dst, "null"...), nil
+	}
+	dst = append(dst, '[')
+	for i := range l {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		var err error
+		if dst, err = l[i].AppendJSON(dst); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, ']'), nil
+}
+
+func appendFloatField(dst []byte, name string, f float64) ([]byte, error) {
+	return jsonenc.AppendFloat(append(dst, name...), f)
+}
+
+// appendTimeField appends an omitempty time pointer field if it is set.
+func appendTimeField(dst []byte, name string, t *time.Time) ([]byte, error) {
+	if t == nil {
+		return dst, nil
+	}
+	return jsonenc.AppendTime(append(dst, name...), *t)
+}
+
+func appendStdField(dst []byte, name string, v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(dst, name...), raw...), nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"order-service/internal/jsonenc"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// randomOrder sets every exported field of an order, most of them to
+// random values, so a field missing from AppendJSON shows up.
+func randomOrder(r *rand.Rand) Order {
+	var o Order
+	v := reflect.ValueOf(&o).Elem()
+	randomTime := func() time.Time {
+		zone := time.FixedZone("", (r.Intn(27)-12)*3600)
+		return time.Unix(r.Int63n(1<<32), r.Int63n(1e9)).In(zone)
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanSet() || r.Intn(8) == 0 {
+			continue
+		}
+		switch f.Interface().(type) {
+		case string:
+			runes := []rune("ab<>&\"\\\n é日")
+			s := make([]rune, r.Intn(12))
+			for j := range s {
+				s[j] = runes[r.Intn(len(runes))]
+			}
+			f.SetString(string(s))
+		case float64:
+			f.SetFloat(float64(r.Int63n(1e9)) / 100)
+		case int:
+			f.SetInt(r.Int63n(1000))
+		case time.Time:
+			f.Set(reflect.ValueOf(randomTime()))
+		case *time.Time:
+			t := randomTime()
+			f.Set(reflect.ValueOf(&t))
+		case []Installment:
+			f.Set(reflect.ValueOf([]Installment{{ID: "i1", Sequence: 1, Amount: 10, DueAt: randomTime()}}))
+		case []Tender:
+			f.Set(reflect.ValueOf([]Tender{{Type: "gift_card", Amount: 5}}))
+		case []OrderTag:
+			f.Set(reflect.ValueOf([]OrderTag{{Tag: "flash-sale"}, {Tag: "incident-42"}}))
+		default:
+			panic(fmt.Sprintf("randomOrder does not know how to set %s", v.Type().Field(i).Name))
+		}
+	}
+	return o
+}
+
+func TestOrderJSON(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for range 500 {
+		orders := []Order{randomOrder(r), randomOrder(r), {}}
+		want, err := json.Marshal(orders)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		got, err := jsonenc.Fast{}.Append(nil, OrderList(orders))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Expected\n%s\ngot\n%s", want, got)
+		}
+	}
+
+	if got, _ := (jsonenc.Fast{}).Append(nil, OrderList(nil)); string(got) != "null" {
+		t.Errorf("Expected null for no orders, got %s", got)
+	}
+}
+
+// BenchmarkEncodeOrders encodes a product's order list for the cache.
+func BenchmarkEncodeOrders(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	orders := make([]Order, 100)
+	for i := range orders {
+		changed := time.Now()
+		orders[i] = Order{
+			ID: fmt.Sprintf("3f1c9a52-7d0b-4d1e-9a57-%012d", i), ProductID: "p1", CustomerID: fmt.Sprintf("customer-%d", r.Intn(50)),
+			Subtotal: 100, TaxAmount: 11, ShippingFee: 5, TotalPrice: 116, Quantity: 2, Status: StatusPending,
+			Currency: "IDR", CreatedAt: time.Now(), StatusChangedAt: &changed,
+		}
+	}
+	for _, enc := range []jsonenc.Encoder{jsonenc.Std{}, jsonenc.Fast{}} {
+		name := jsonenc.NameFast
+		if _, ok := enc.(jsonenc.Std); ok {
+			name = jsonenc.NameStd
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := encodeOrders(enc, orders, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
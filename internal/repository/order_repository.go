@@ -1,30 +1,501 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"order-service/internal/domain"
 	"time"
+
 	"gorm.io/gorm"
 )
+
 type IOrderRepository interface {
 	Create(order *Order) error
+	CreateBatch(orders []Order) error
+	GetByID(id string) (*Order, error)
+	Exists(id string) (bool, error)
+	UpdateStatus(id, from, to string) error
 	GetByProductID(productID string) ([]Order, error)
+	CountByProduct(productID string) (map[string]int64, error)
+	GetBackorders(productID string) ([]Order, error)
+	BackorderPosition(order *Order) (int, error)
+	GetExpiredPayments(before time.Time, limit int) ([]Order, error)
+	GetPendingValidation(limit int) ([]Order, error)
+	GetPaymentOrders(since time.Time, afterID string, limit int) ([]Order, error)
+	CompleteValidation(order *Order) error
+	GetDueScheduled(before time.Time, limit int) ([]Order, error)
+	ActivateScheduled(order *Order) error
+	Reschedule(id string, processAt time.Time, deliverAt *time.Time) error
+	GetExpiredReservations(before time.Time, limit int) ([]Order, error)
+	CompleteReservation(order *Order) error
+	GetInstallments(orderID string) ([]Installment, error)
+	MarkInstallmentPaid(orderID string, sequence int, paymentID string) (*Order, error)
+	Stream(ctx context.Context, filter OrderFilter, fn func(Order) error) error
+	GetRevisions(orderID string) ([]OrderRevision, error)
+	AddTags(orderID string, tags []string) error
+	RemoveTag(orderID, tag string) error
+	IOrderSearcher
 }
+
+// IOrderSearcher is the read side used by /orders/search. Postgres is the
+// default implementation; search.Repository serves it from OpenSearch.
+type IOrderSearcher interface {
+	Search(ctx context.Context, filter OrderFilter, limit, offset int) ([]Order, error)
+}
+
+// Order statuses; the lifecycle between them is defined in package domain.
+const (
+	StatusPending            = domain.StatusPending
+	StatusOnHold             = domain.StatusOnHold
+	StatusRejected           = domain.StatusRejected
+	StatusBackordered        = domain.StatusBackordered
+	StatusAwaitingPayment    = domain.StatusAwaitingPayment
+	StatusPaymentExpired     = domain.StatusPaymentExpired
+	StatusPaid               = domain.StatusPaid
+	StatusPendingValidation  = domain.StatusPendingValidation
+	StatusScheduled          = domain.StatusScheduled
+	StatusCancelled          = domain.StatusCancelled
+	StatusReserved           = domain.StatusReserved
+	StatusReservationExpired = domain.StatusReservationExpired
+)
+
+var (
+	ErrOrderNotFound  = errors.New("order not found")
+	ErrStatusConflict = errors.New("order status changed concurrently")
+	ErrOrderExists    = errors.New("order already exists")
+)
+
 type Order struct {
-	ID         string    `gorm:"type:uuid;primary_key;"`
-	ProductID  string    `gorm:"not null"`
-	TotalPrice float64   `gorm:"not null"`
-	Quantity   int       `gorm:"not null"`
-	Status     string    `gorm:"not null"`
-	CreatedAt  time.Time
+	ID             string  `gorm:"type:uuid;primary_key;"`
+	ProductID      string  `gorm:"not null"`
+	CustomerID     string  `gorm:"index"`
+	TenantID       string  `gorm:"index"`
+	CartID         string  `gorm:"index"`
+	Subtotal       float64 `gorm:"not null;default:0"`
+	DiscountCode   string
+	DiscountAmount float64 `gorm:"not null;default:0"`
+	TaxAmount      float64 `gorm:"not null;default:0"`
+	ShippingFee    float64 `gorm:"not null;default:0"`
+	TotalPrice     float64 `gorm:"not null"`
+	Quantity       int     `gorm:"not null"`
+	Region         string  `json:",omitempty"`
+	Country        string  `json:",omitempty"`
+	Status         string  `gorm:"not null"`
+	Experiment     string
+	Variant        string
+	HoldReason     string
+	// PaymentIntentID and PaymentExpiresAt are set for orders created
+	// while payment intents are enabled.
+	PaymentIntentID  string `gorm:"index"`
+	PaymentExpiresAt time.Time
+	// PaymentClientSecret is only returned in the create response, it is
+	// never stored.
+	PaymentClientSecret string `gorm:"-" json:",omitempty"`
+	// PaidAmount is the sum of paid installments. Installments is only
+	// loaded for the order detail view; it is stored together with a new
+	// order.
+	PaidAmount   float64       `gorm:"not null;default:0"`
+	Installments []Installment `gorm:"foreignKey:OrderID" json:",omitempty"`
+	// Tenders is the split of TotalPrice across gift cards, store credit
+	// and payment. It is empty when the order is paid in one tender.
+	Tenders []Tender `gorm:"foreignKey:OrderID" json:",omitempty"`
+	// Tags are the order's labels, sorted. They are changed through
+	// AddTags and RemoveTag only.
+	Tags []OrderTag `gorm:"foreignKey:OrderID" json:",omitempty"`
+	// Amounts above are in Currency, the product's currency. Orders paid
+	// in another currency also keep the converted total and the rate used.
+	Currency          string
+	ConvertedCurrency string  `json:",omitempty"`
+	ExchangeRate      float64 `json:",omitempty"`
+	ConvertedTotal    float64 `json:",omitempty"`
+	// ProcessAt is when a SCHEDULED order is activated; DeliverAt is the
+	// delivery date the customer asked for, if any.
+	ProcessAt *time.Time `gorm:"index" json:",omitempty"`
+	DeliverAt *time.Time `json:",omitempty"`
+	// ReservedUntil is when a RESERVED order's stock is released.
+	ReservedUntil *time.Time `gorm:"index" json:",omitempty"`
+	CreatedAt     time.Time
+	// StatusChangedAt is when the order entered its current status. It is
+	// null for orders stored before it was tracked; their CreatedAt
+	// stands in. SLABreachedAt is set once the order has stayed in the
+	// status longer than its SLA and cleared by the next status change.
+	StatusChangedAt *time.Time `gorm:"index" json:",omitempty"`
+	SLABreachedAt   *time.Time `json:",omitempty"`
+	// RequestHash fingerprints the request of an order whose ID the
+	// client chose, so a resubmission can be told from a clash.
+	RequestHash string `json:"-"`
+}
+
+// BeforeCreate stores timestamps in UTC whatever zone the caller used.
+func (o *Order) BeforeCreate(tx *gorm.DB) error {
+	if o.CreatedAt.IsZero() {
+		o.CreatedAt = time.Now()
+	}
+	o.CreatedAt = o.CreatedAt.UTC()
+	o.PaymentExpiresAt = o.PaymentExpiresAt.UTC()
+	for _, t := range []*time.Time{o.ProcessAt, o.DeliverAt, o.ReservedUntil} {
+		if t != nil {
+			*t = t.UTC()
+		}
+	}
+	if o.StatusChangedAt == nil {
+		changed := o.CreatedAt
+		o.StatusChangedAt = &changed
+	}
+	return nil
 }
 
-type OrderRepository struct { db *gorm.DB }
+// statusChange are the columns reset along with a new status.
+func statusChange(status string) map[string]interface{} {
+	return map[string]interface{}{"status": status, "status_changed_at": time.Now().UTC(), "sla_breached_at": nil}
+}
+
+type OrderRepository struct {
+	db        *gorm.DB
+	batchSize int
+	hooks     []Hook
+}
 
 var _ IOrderRepository = &OrderRepository{}
 
-func NewOrderRepository(db *gorm.DB) *OrderRepository { return &OrderRepository{db: db} }
-func (r *OrderRepository) Create(order *Order) error { return r.db.Create(order).Error }
+const defaultBatchSize = 100
+
+func NewOrderRepository(db *gorm.DB, batchSize int) *OrderRepository {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &OrderRepository{db: db, batchSize: batchSize}
+}
+
+// Create stores the order together with its first revision. It fails with
+// ErrOrderExists if an order with the same ID is stored.
+func (r *OrderRepository) Create(order *Order) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrOrderExists
+			}
+			return err
+		}
+		rev := newRevision(*order, 1)
+		if err := tx.Create(&rev).Error; err != nil {
+			return err
+		}
+		return r.runHooks(tx, ChangeCreated, order)
+	})
+}
+
+// CreateBatch inserts orders in chunks of batchSize inside a single
+// transaction, so either every order is stored or none is. Orders of a
+// cart also mark it checked out, failing with ErrCartCheckedOut if it
+// already was.
+func (r *OrderRepository) CreateBatch(orders []Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if cartID := orders[0].CartID; cartID != "" {
+			if err := tx.Create(&CartCheckout{CartID: cartID}).Error; err != nil {
+				if errors.Is(err, gorm.ErrDuplicatedKey) {
+					return ErrCartCheckedOut
+				}
+				return err
+			}
+		}
+		if err := tx.CreateInBatches(orders, r.batchSize).Error; err != nil {
+			return err
+		}
+		revisions := make([]OrderRevision, len(orders))
+		created := make([]*Order, len(orders))
+		for i := range orders {
+			revisions[i] = newRevision(orders[i], 1)
+			created[i] = &orders[i]
+		}
+		if err := tx.CreateInBatches(revisions, r.batchSize).Error; err != nil {
+			return err
+		}
+		return r.runHooks(tx, ChangeCreated, created...)
+	})
+}
+
+func (r *OrderRepository) GetByID(id string) (*Order, error) {
+	var order Order
+	err := r.db.Scopes(withTags).Preload("Tenders").Where("id = ?", id).First(&order).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// Exists reports whether the order exists without loading it.
+func (r *OrderRepository) Exists(id string) (bool, error) {
+	var n int64
+	err := r.db.Model(&Order{}).Where("id = ?", id).Limit(1).Count(&n).Error
+	return n > 0, err
+}
+
+// UpdateStatus moves an order from one status to another. It fails with
+// ErrStatusConflict if the order is no longer in the from status.
+func (r *OrderRepository) UpdateStatus(id, from, to string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Order{}).Where("id = ? AND status = ?", id, from).Updates(statusChange(to))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrStatusConflict
+		}
+		return recordRevision(tx, id)
+	})
+}
+
 func (r *OrderRepository) GetByProductID(productID string) ([]Order, error) {
 	var orders []Order
-	err := r.db.Where("product_id = ?", productID).Find(&orders).Error
+	err := r.db.Scopes(withTags).Where("product_id = ?", productID).Find(&orders).Error
+	return orders, err
+}
+
+func (r *OrderRepository) Search(ctx context.Context, filter OrderFilter, limit, offset int) ([]Order, error) {
+	var orders []Order
+	err := filter.apply(r.db.WithContext(ctx)).Scopes(withTags).
+		Order("created_at DESC, id").
+		Limit(limit).
+		Offset(offset).
+		Find(&orders).Error
+	return orders, err
+}
+
+// CountByStatus counts the orders created since since, per status.
+func (r *OrderRepository) CountByStatus(ctx context.Context, since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.WithContext(ctx).Model(&Order{}).
+		Select("status, COUNT(*) AS count").
+		Where("created_at >= ?", since).
+		Group("status").
+		Scan(&rows).Error
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, err
+}
+
+// CountByProduct counts the product's orders per status.
+func (r *OrderRepository) CountByProduct(productID string) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := r.db.Model(&Order{}).
+		Select("status, COUNT(*) AS count").
+		Where("product_id = ?", productID).
+		Group("status").
+		Scan(&rows).Error
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, err
+}
+
+// GetBackorders returns the product's backordered orders, oldest first.
+func (r *OrderRepository) GetBackorders(productID string) ([]Order, error) {
+	var orders []Order
+	err := r.db.Scopes(withTags).Where("product_id = ? AND status = ?", productID, StatusBackordered).
+		Order("created_at, id").
+		Find(&orders).Error
 	return orders, err
-}
\ No newline at end of file
+}
+
+// BackorderPosition returns the 1-based place of order in its product's
+// backorder queue.
+func (r *OrderRepository) BackorderPosition(order *Order) (int, error) {
+	var ahead int64
+	err := r.db.Model(&Order{}).
+		Where("product_id = ? AND status = ?", order.ProductID, StatusBackordered).
+		Where("(created_at, id) < (?, ?)", order.CreatedAt, order.ID).
+		Count(&ahead).Error
+	return int(ahead) + 1, err
+}
+
+// GetExpiredPayments returns up to limit orders still awaiting payment
+// whose intent expired before the given time, oldest first.
+func (r *OrderRepository) GetExpiredPayments(before time.Time, limit int) ([]Order, error) {
+	var orders []Order
+	err := r.db.Scopes(withTags).Preload("Tenders").
+		Where("status = ? AND payment_expires_at < ?", StatusAwaitingPayment, before).
+		Order("payment_expires_at, id").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
+// GetPaymentOrders returns up to limit orders created since since that
+// have a payment intent, by ID after afterID. Of an installment order's
+// installments only the first, which the intent is for, is loaded.
+func (r *OrderRepository) GetPaymentOrders(since time.Time, afterID string, limit int) ([]Order, error) {
+	var orders []Order
+	err := r.db.Scopes(withTags).Preload("Tenders").Preload("Installments", "sequence = 1").
+		Where("payment_intent_id <> '' AND created_at >= ? AND id > ?", since, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
+// GetPendingValidation returns up to limit orders waiting for
+// product-service, oldest first.
+func (r *OrderRepository) GetPendingValidation(limit int) ([]Order, error) {
+	var orders []Order
+	err := r.db.Scopes(withTags).Where("status = ?", StatusPendingValidation).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
+// CompleteValidation stores the prices and status of a validated order. It
+// fails with ErrStatusConflict if the order is no longer pending
+// validation.
+func (r *OrderRepository) CompleteValidation(order *Order) error {
+	return r.reprice(order, StatusPendingValidation, ChangeValidated)
+}
+
+// GetDueScheduled returns scheduled orders whose processing time is before
+// before, earliest first.
+func (r *OrderRepository) GetDueScheduled(before time.Time, limit int) ([]Order, error) {
+	var orders []Order
+	err := r.db.Scopes(withTags).Where("status = ? AND process_at <= ?", StatusScheduled, before).
+		Order("process_at, id").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
+// ActivateScheduled stores the prices, status and payment intent of an
+// activated order. It fails with ErrStatusConflict if the order is no
+// longer scheduled.
+func (r *OrderRepository) ActivateScheduled(order *Order) error {
+	return r.reprice(order, StatusScheduled, ChangeActivated, "PaymentIntentID", "PaymentExpiresAt")
+}
+
+// Reschedule changes the processing and delivery time of a scheduled
+// order. It fails with ErrStatusConflict if the order is no longer
+// scheduled.
+func (r *OrderRepository) Reschedule(id string, processAt time.Time, deliverAt *time.Time) error {
+	if deliverAt != nil {
+		utc := deliverAt.UTC()
+		deliverAt = &utc
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Order{}).
+			Where("id = ? AND status = ?", id, StatusScheduled).
+			Updates(map[string]interface{}{"process_at": processAt.UTC(), "deliver_at": deliverAt})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrStatusConflict
+		}
+		return recordRevision(tx, id)
+	})
+}
+
+// GetExpiredReservations returns up to limit reserved orders whose
+// reservation ran out before before, oldest first.
+func (r *OrderRepository) GetExpiredReservations(before time.Time, limit int) ([]Order, error) {
+	var orders []Order
+	err := r.db.Scopes(withTags).Where("status = ? AND reserved_until < ?", StatusReserved, before).
+		Order("reserved_until, id").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
+
+// CompleteReservation stores the status and payment intent of a confirmed
+// reservation. It fails with ErrStatusConflict if the order is no longer
+// reserved.
+func (r *OrderRepository) CompleteReservation(order *Order) error {
+	updates := statusChange(order.Status)
+	updates["payment_intent_id"] = order.PaymentIntentID
+	updates["payment_expires_at"] = order.PaymentExpiresAt.UTC()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Order{}).Where("id = ? AND status = ?", order.ID, StatusReserved).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrStatusConflict
+		}
+		return recordRevision(tx, order.ID)
+	})
+}
+
+// reprice stores the prices and new status of an order that is still in
+// status from, along with columns, and reports it to hooks as change.
+func (r *OrderRepository) reprice(order *Order, from, change string, columns ...string) error {
+	columns = append([]string{"Subtotal", "DiscountCode", "DiscountAmount", "TaxAmount", "ShippingFee", "TotalPrice",
+		"Status", "HoldReason", "Experiment", "Variant", "Currency", "ConvertedCurrency", "ExchangeRate", "ConvertedTotal"}, columns...)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Order{}).
+			Where("id = ? AND status = ?", order.ID, from).
+			Select(columns).
+			Updates(order)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrStatusConflict
+		}
+		if err := tx.Model(&Order{}).Where("id = ?", order.ID).Updates(statusChange(order.Status)).Error; err != nil {
+			return err
+		}
+		if err := recordRevision(tx, order.ID); err != nil {
+			return err
+		}
+		return r.runHooks(tx, change, order)
+	})
+}
+
+// Stream walks all orders matching filter in (created_at, id) order, loading
+// batchSize rows at a time and calling fn for each one. Iteration stops at
+// the first error returned by fn or when ctx is cancelled.
+func (r *OrderRepository) Stream(ctx context.Context, filter OrderFilter, fn func(Order) error) error {
+	var (
+		lastCreatedAt time.Time
+		lastID        string
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		tx := filter.apply(r.db.WithContext(ctx).Model(&Order{})).Scopes(withTags)
+		if lastID != "" {
+			tx = tx.Where("(created_at, id) > (?, ?)", lastCreatedAt, lastID)
+		}
+
+		var batch []Order
+		if err := tx.Order("created_at, id").Limit(r.batchSize).Find(&batch).Error; err != nil {
+			return err
+		}
+
+		for _, order := range batch {
+			if err := fn(order); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < r.batchSize {
+			return nil
+		}
+		last := batch[len(batch)-1]
+		lastCreatedAt, lastID = last.CreatedAt, last.ID
+	}
+}
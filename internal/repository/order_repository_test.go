@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestStream(t *testing.T) {
+	at := func(m int) time.Time { return time.Date(2026, 10, 1, 12, m, 0, 0, time.UTC) }
+	orderRows := func(ids ...string) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"id", "product_id", "status", "created_at"})
+		for i, id := range ids {
+			rows.AddRow(id, "p1", StatusPending, at(i))
+		}
+		return rows
+	}
+	noTags := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"order_id", "tag"}) }
+	filter := OrderFilter{ProductID: "p1"}
+
+	t.Run("reads in batches after the last order", func(t *testing.T) {
+		db, mock := mockDB(t)
+		repo := NewOrderRepository(db, 2)
+
+		mock.ExpectQuery(quoted(`SELECT * FROM "orders" WHERE product_id = $1 ORDER BY created_at, id LIMIT $2`)).
+			WithArgs("p1", 2).
+			WillReturnRows(orderRows("o1", "o2"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "order_tags" WHERE "order_tags"."order_id" IN ($1,$2) ORDER BY tag`)).
+			WillReturnRows(sqlmock.NewRows([]string{"order_id", "tag"}).AddRow("o2", "vip"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "orders" WHERE product_id = $1 AND (created_at, id) > ($2, $3) ORDER BY created_at, id LIMIT $4`)).
+			WithArgs("p1", at(1), "o2", 2).
+			WillReturnRows(orderRows("o3"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "order_tags"`)).WillReturnRows(noTags())
+
+		var got []string
+		err := repo.Stream(context.Background(), filter, func(o Order) error {
+			got = append(got, o.ID)
+			if o.ID == "o2" && !o.HasTag("vip") {
+				t.Errorf("Expected o2 to come with its tags, got %v", o.Tags)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(got) != 3 || got[0] != "o1" || got[2] != "o3" {
+			t.Errorf("Expected o1, o2, o3 in order, got %v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("a full last batch is followed by an empty one", func(t *testing.T) {
+		db, mock := mockDB(t)
+		repo := NewOrderRepository(db, 2)
+
+		mock.ExpectQuery(quoted(`SELECT * FROM "orders"`)).WillReturnRows(orderRows("o1", "o2"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "order_tags"`)).WillReturnRows(noTags())
+		mock.ExpectQuery(quoted(`SELECT * FROM "orders"`)).WillReturnRows(orderRows())
+
+		n := 0
+		if err := repo.Stream(context.Background(), filter, func(Order) error { n++; return nil }); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 orders, got %d", n)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		db, mock := mockDB(t)
+		repo := NewOrderRepository(db, 2)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mock.ExpectQuery(quoted(`SELECT * FROM "orders"`)).WillReturnRows(orderRows("o1", "o2"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "order_tags"`)).WillReturnRows(noTags())
+
+		err := repo.Stream(ctx, filter, func(Order) error {
+			cancel()
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("does not query with a cancelled context", func(t *testing.T) {
+		db, mock := mockDB(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		called := false
+		err := NewOrderRepository(db, 2).Stream(ctx, filter, func(Order) error { called = true; return nil })
+		if !errors.Is(err, context.Canceled) || called {
+			t.Errorf("Expected context.Canceled before any order, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("stops at the first error of fn", func(t *testing.T) {
+		db, mock := mockDB(t)
+		repo := NewOrderRepository(db, 2)
+		mock.ExpectQuery(quoted(`SELECT * FROM "orders"`)).WillReturnRows(orderRows("o1", "o2"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "order_tags"`)).WillReturnRows(noTags())
+
+		failed := errors.New("client gone")
+		var got []string
+		err := repo.Stream(context.Background(), filter, func(o Order) error {
+			got = append(got, o.ID)
+			return failed
+		})
+		if !errors.Is(err, failed) || len(got) != 1 {
+			t.Errorf("Expected to stop after o1 with fn's error, got %v after %v", err, got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
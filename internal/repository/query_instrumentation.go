@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"order-service/internal/metrics"
+
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "instrumentation:start"
+
+// QueryInstrumentation is a GORM plugin that records duration, rows and
+// errors of every statement. Slow and failed queries are always logged,
+// other queries are logged at debug level for a sampled fraction.
+type QueryInstrumentation struct {
+	slowThreshold time.Duration
+	sampleRate    float64
+	logger        *slog.Logger
+	observers     []func(time.Duration)
+}
+
+var _ gorm.Plugin = &QueryInstrumentation{}
+
+func NewQueryInstrumentation(slowThreshold time.Duration, sampleRate float64, logger *slog.Logger) *QueryInstrumentation {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &QueryInstrumentation{
+		slowThreshold: slowThreshold,
+		sampleRate:    sampleRate,
+		logger:        logger,
+	}
+}
+
+// OnQuery registers fn to receive the duration of every statement. It must
+// be called before the plugin is registered.
+func (p *QueryInstrumentation) OnQuery(fn func(time.Duration)) {
+	p.observers = append(p.observers, fn)
+}
+
+func (p *QueryInstrumentation) Name() string {
+	return "order-service:query-instrumentation"
+}
+
+func (p *QueryInstrumentation) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before("instrumentation:before_"+h.operation, p.before); err != nil {
+			return err
+		}
+		if err := h.after("instrumentation:after_"+h.operation, p.after(h.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *QueryInstrumentation) before(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func (p *QueryInstrumentation) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(start)
+		table := db.Statement.Table
+
+		metrics.DBQueryDuration.WithLabelValues(operation, table).Observe(elapsed.Seconds())
+		metrics.DBQueryRows.WithLabelValues(operation, table).Observe(float64(db.Statement.RowsAffected))
+		for _, fn := range p.observers {
+			fn(elapsed)
+		}
+
+		attrs := []any{
+			slog.String("operation", operation),
+			slog.String("table", table),
+			slog.Duration("duration", elapsed),
+			slog.Int64("rows", db.Statement.RowsAffected),
+			slog.String("sql", db.Statement.SQL.String()),
+		}
+
+		switch {
+		case db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound):
+			metrics.DBQueryErrors.WithLabelValues(operation, table).Inc()
+			p.logger.Error("query failed", append(attrs, slog.Any("error", db.Error))...)
+		case p.slowThreshold > 0 && elapsed >= p.slowThreshold:
+			metrics.DBSlowQueries.WithLabelValues(operation, table).Inc()
+			p.logger.Warn("slow query", attrs...)
+		case p.sampleRate > 0 && rand.Float64() < p.sampleRate:
+			p.logger.Debug("query", attrs...)
+		}
+	}
+}
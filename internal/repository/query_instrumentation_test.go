@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"order-service/internal/metrics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID   string
+	Name string
+}
+
+// instrumentedDB is a mock database with the plugin registered. It
+// returns the plugin's log and the durations its observer received.
+func instrumentedDB(t *testing.T, slow time.Duration, sampleRate float64) (*gorm.DB, sqlmock.Sqlmock, *bytes.Buffer, *[]time.Duration) {
+	t.Helper()
+	db, mock := mockDB(t)
+	var log bytes.Buffer
+	p := NewQueryInstrumentation(slow, sampleRate, slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	var observed []time.Duration
+	p.OnQuery(func(d time.Duration) { observed = append(observed, d) })
+	if err := db.Use(p); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock, &log, &observed
+}
+
+func TestQueryInstrumentation(t *testing.T) {
+	t.Run("instruments all six operations", func(t *testing.T) {
+		db, mock, log, observed := instrumentedDB(t, 0, 1)
+
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`INSERT INTO "widgets"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(quoted(`SELECT * FROM "widgets"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("w1"))
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`UPDATE "widgets"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`DELETE FROM "widgets"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(quoted(`SELECT count(*) FROM "widgets"`)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectExec(quoted(`VACUUM widgets`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+		var widgets []widget
+		var n int64
+		db.Create(&widget{ID: "w1"})
+		db.Find(&widgets)
+		db.Model(&widget{ID: "w1"}).Update("name", "gear")
+		db.Delete(&widget{ID: "w1"})
+		db.Table("widgets").Select("count(*)").Row().Scan(&n)
+		db.Exec("VACUUM widgets")
+
+		if len(*observed) != 6 {
+			t.Errorf("Expected the observer to see 6 statements, got %d", len(*observed))
+		}
+		for _, op := range []string{"create", "query", "update", "delete", "row", "raw"} {
+			if !strings.Contains(log.String(), "operation="+op+" ") {
+				t.Errorf("Expected a sampled %s statement in the log, got %s", op, log)
+			}
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("callbacks are registered around gorm's own", func(t *testing.T) {
+		db, _, _, _ := instrumentedDB(t, 0, 0)
+		cb := db.Callback()
+		for op, processor := range map[string]interface {
+			Get(string) func(*gorm.DB)
+		}{
+			"create": cb.Create(), "query": cb.Query(), "update": cb.Update(),
+			"delete": cb.Delete(), "row": cb.Row(), "raw": cb.Raw(),
+		} {
+			if processor.Get("instrumentation:before_"+op) == nil || processor.Get("instrumentation:after_"+op) == nil {
+				t.Errorf("Expected callbacks around %s", op)
+			}
+		}
+	})
+
+	t.Run("failed queries are logged and counted", func(t *testing.T) {
+		db, mock, log, observed := instrumentedDB(t, 0, 0)
+		failures := testutil.ToFloat64(metrics.DBQueryErrors.WithLabelValues("query", "widgets"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "widgets"`)).WillReturnError(errors.New("connection reset"))
+
+		var widgets []widget
+		db.Find(&widgets)
+
+		if got := testutil.ToFloat64(metrics.DBQueryErrors.WithLabelValues("query", "widgets")); got != failures+1 {
+			t.Errorf("Expected the error to be counted, got %v after %v", got, failures)
+		}
+		if !strings.Contains(log.String(), `level=ERROR msg="query failed"`) || !strings.Contains(log.String(), "connection reset") {
+			t.Errorf("Expected the failure to be logged, got %s", log)
+		}
+		if len(*observed) != 1 {
+			t.Errorf("Expected failed statements to be observed too, got %d", len(*observed))
+		}
+	})
+
+	t.Run("a missing record is not a failure", func(t *testing.T) {
+		db, mock, log, _ := instrumentedDB(t, 0, 0)
+		failures := testutil.ToFloat64(metrics.DBQueryErrors.WithLabelValues("query", "widgets"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "widgets"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		if err := db.First(&widget{}).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("Expected ErrRecordNotFound, got %v", err)
+		}
+		if got := testutil.ToFloat64(metrics.DBQueryErrors.WithLabelValues("query", "widgets")); got != failures {
+			t.Errorf("Expected ErrRecordNotFound not to be counted, got %v after %v", got, failures)
+		}
+		if log.Len() != 0 {
+			t.Errorf("Expected nothing logged, got %s", log)
+		}
+	})
+
+	t.Run("slow queries are logged and counted", func(t *testing.T) {
+		db, mock, log, _ := instrumentedDB(t, 10*time.Millisecond, 0)
+		slow := testutil.ToFloat64(metrics.DBSlowQueries.WithLabelValues("query", "widgets"))
+		mock.ExpectQuery(quoted(`SELECT * FROM "widgets"`)).WillDelayFor(20 * time.Millisecond).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery(quoted(`SELECT * FROM "widgets"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		var widgets []widget
+		db.Find(&widgets)
+		db.Find(&widgets)
+
+		if got := testutil.ToFloat64(metrics.DBSlowQueries.WithLabelValues("query", "widgets")); got != slow+1 {
+			t.Errorf("Expected one slow query to be counted, got %v after %v", got, slow)
+		}
+		if n := strings.Count(log.String(), `level=WARN msg="slow query"`); n != 1 || strings.Count(log.String(), "\n") != 1 {
+			t.Errorf("Expected only the slow query to be logged, got %s", log)
+		}
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		for _, tt := range []struct {
+			rate float64
+			want int
+		}{
+			{0, 0},
+			{1, 3},
+		} {
+			db, mock, log, observed := instrumentedDB(t, time.Hour, tt.rate)
+			var widgets []widget
+			for i := 0; i < 3; i++ {
+				mock.ExpectQuery(quoted(`SELECT * FROM "widgets"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				db.Find(&widgets)
+			}
+			if got := strings.Count(log.String(), "level=DEBUG msg=query "); got != tt.want {
+				t.Errorf("Expected %d sampled queries at rate %v, got %d", tt.want, tt.rate, got)
+			}
+			if len(*observed) != 3 {
+				t.Errorf("Expected every query to be observed at rate %v, got %d", tt.rate, len(*observed))
+			}
+		}
+	})
+}
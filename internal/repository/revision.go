@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OrderRevision is a snapshot of an order after one change. Number 1 is
+// the order as created; every later status or price change adds the next
+// number. Snapshots leave out installments and tenders.
+type OrderRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	OrderID   string    `gorm:"type:uuid;not null;uniqueIndex:idx_order_revision_number" json:"orderId"`
+	Number    int       `gorm:"not null;uniqueIndex:idx_order_revision_number" json:"number"`
+	Snapshot  Order     `gorm:"type:jsonb;serializer:json;not null" json:"snapshot"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (OrderRevision) TableName() string { return "order_revisions" }
+
+func newRevision(order Order, number int) OrderRevision {
+	order.Installments = nil
+	order.Tenders = nil
+	order.Tags = nil
+	return OrderRevision{OrderID: order.ID, Number: number, Snapshot: order}
+}
+
+// recordRevision stores the order as it now is in tx. It must run after
+// the update in the same transaction: the updated row stays locked until
+// commit, so concurrent changes of one order cannot take the same number.
+func recordRevision(tx *gorm.DB, orderID string) error {
+	var order Order
+	if err := tx.Where("id = ?", orderID).First(&order).Error; err != nil {
+		return err
+	}
+	var last int
+	err := tx.Model(&OrderRevision{}).Where("order_id = ?", orderID).
+		Select("COALESCE(MAX(number), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	rev := newRevision(order, last+1)
+	return tx.Create(&rev).Error
+}
+
+// GetRevisions returns the order's revisions, oldest first. Orders created
+// before revisions were recorded start with their first change after that.
+func (r *OrderRepository) GetRevisions(orderID string) ([]OrderRevision, error) {
+	var revisions []OrderRevision
+	err := r.db.Where("order_id = ?", orderID).Order("number").Find(&revisions).Error
+	return revisions, err
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderTag is a free-form label on an order, e.g. to group the orders of a
+// campaign or an incident. It encodes as its name.
+type OrderTag struct {
+	OrderID   string `gorm:"type:uuid;primaryKey"`
+	Tag       string `gorm:"primaryKey;index"`
+	CreatedAt time.Time
+}
+
+func (OrderTag) TableName() string { return "order_tags" }
+
+func (t OrderTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Tag)
+}
+
+func (t *OrderTag) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &t.Tag)
+}
+
+// TagNames returns the names of the order's tags, sorted.
+func (o *Order) TagNames() []string {
+	if len(o.Tags) == 0 {
+		return nil
+	}
+	names := make([]string, len(o.Tags))
+	for i, t := range o.Tags {
+		names[i] = t.Tag
+	}
+	slices.Sort(names)
+	return names
+}
+
+// HasTag reports whether the order has tag.
+func (o *Order) HasTag(tag string) bool {
+	return slices.ContainsFunc(o.Tags, func(t OrderTag) bool { return t.Tag == tag })
+}
+
+// HasTags reports whether the order has every one of tags.
+func (o *Order) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !o.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// withTags loads the tags of the orders a query finds.
+func withTags(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("Tags", func(tx *gorm.DB) *gorm.DB { return tx.Order("tag") })
+}
+
+// AddTags adds tags to an order; tags it already has are kept as they
+// are.
+func (r *OrderRepository) AddTags(orderID string, tags []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := orderExists(tx, orderID); err != nil {
+			return err
+		}
+		rows := make([]OrderTag, len(tags))
+		for i, tag := range tags {
+			rows[i] = OrderTag{OrderID: orderID, Tag: tag}
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+	})
+}
+
+// RemoveTag removes a tag from an order. Removing a tag the order does not
+// have is not an error.
+func (r *OrderRepository) RemoveTag(orderID, tag string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := orderExists(tx, orderID); err != nil {
+			return err
+		}
+		return tx.Where("order_id = ? AND tag = ?", orderID, tag).Delete(&OrderTag{}).Error
+	})
+}
+
+func orderExists(tx *gorm.DB, orderID string) error {
+	var n int64
+	if err := tx.Model(&Order{}).Where("id = ?", orderID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrOrderNotFound
+	}
+	return nil
+}
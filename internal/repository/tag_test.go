@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestOrderTags(t *testing.T) {
+	order := Order{Tags: []OrderTag{{Tag: "vip"}, {Tag: "flash-sale"}}}
+
+	if got, want := order.TagNames(), []string{"flash-sale", "vip"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected tag names %v, got %v", want, got)
+	}
+	if names := (&Order{}).TagNames(); names != nil {
+		t.Errorf("Expected no tag names, got %v", names)
+	}
+	if !order.HasTags([]string{"vip", "flash-sale"}) || !order.HasTags(nil) {
+		t.Error("Expected the order to have its tags")
+	}
+	if order.HasTags([]string{"vip", "incident"}) {
+		t.Error("Expected the order not to have incident")
+	}
+
+	data, err := json.Marshal(order.Tags)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(data) != `["vip","flash-sale"]` {
+		t.Errorf("Expected tags to encode as names, got %s", data)
+	}
+	var decoded []OrderTag
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(decoded, order.Tags) {
+		t.Errorf("Expected %v, got %v", order.Tags, decoded)
+	}
+}
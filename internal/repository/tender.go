@@ -0,0 +1,22 @@
+package repository
+
+const (
+	TenderGiftCard    = "GIFT_CARD"
+	TenderStoreCredit = "STORE_CREDIT"
+	// TenderPayment is the part of the total left for the payment gateway
+	// or installments.
+	TenderPayment = "PAYMENT"
+)
+
+// Tender is one part of how an order's total is paid. The amounts of an
+// order's tenders add up to its TotalPrice.
+type Tender struct {
+	ID           uint    `gorm:"primaryKey"`
+	OrderID      string  `gorm:"type:uuid;not null;index"`
+	Type         string  `gorm:"not null"`
+	Reference    string  `json:",omitempty"`
+	RedemptionID string  `json:",omitempty"`
+	Amount       float64 `gorm:"not null"`
+}
+
+func (Tender) TableName() string { return "order_tenders" }
@@ -0,0 +1,231 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"order-service/internal/audit"
+	"order-service/internal/repository"
+	"order-service/internal/stats"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ActionPurge     = "purge"
+	ActionAnonymize = "anonymize"
+)
+
+// auditActor is recorded as the actor of retention runs.
+const auditActor = "retention"
+
+// Rule purges or anonymizes orders of a tenant that are in one of Statuses
+// and older than AfterDays. A rule without Tenant applies to every tenant
+// that has no rules of its own; without Statuses it matches every status.
+type Rule struct {
+	Tenant    string   `json:"tenant,omitempty"`
+	Statuses  []string `json:"statuses,omitempty"`
+	Action    string   `json:"action"`
+	AfterDays int      `json:"afterDays"`
+}
+
+// ParseRules reads a JSON array of rules, e.g.
+// [{"statuses":["REJECTED"],"action":"purge","afterDays":730}].
+func ParseRules(raw string) ([]Rule, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var rules []Rule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse retention rules: %w", err)
+	}
+	for i, r := range rules {
+		if r.Action != ActionPurge && r.Action != ActionAnonymize {
+			return nil, fmt.Errorf("retention rule %d: unknown action %q", i, r.Action)
+		}
+		if r.AfterDays <= 0 {
+			return nil, fmt.Errorf("retention rule %d: afterDays must be positive", i)
+		}
+	}
+	return rules, nil
+}
+
+// Result is what one rule matched in a run. In a dry run nothing is
+// changed and Affected stays zero.
+type Result struct {
+	Rule     Rule      `json:"rule"`
+	Cutoff   time.Time `json:"cutoff"`
+	Matched  int64     `json:"matched"`
+	Affected int64     `json:"affected"`
+}
+
+// Report is the outcome of one run.
+type Report struct {
+	DryRun  bool      `json:"dryRun"`
+	RanAt   time.Time `json:"ranAt"`
+	Results []Result  `json:"results"`
+}
+
+// IAuditLog records what a run did.
+type IAuditLog interface {
+	Record(ctx context.Context, e *audit.Entry) error
+}
+
+// Enforcer applies retention rules to the orders table.
+type Enforcer struct {
+	db        *gorm.DB
+	rules     []Rule
+	auditLog  IAuditLog
+	batchSize int
+	now       func() time.Time
+}
+
+func NewEnforcer(db *gorm.DB, rules []Rule, auditLog IAuditLog, batchSize int) *Enforcer {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &Enforcer{db: db, rules: rules, auditLog: auditLog, batchSize: batchSize, now: time.Now}
+}
+
+// Run applies every rule, or with dryRun only counts the orders each rule
+// would change. Every rule that matched something gets an audit entry.
+func (e *Enforcer) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, RanAt: e.now().UTC()}
+	for _, rule := range e.rules {
+		res := Result{Rule: rule, Cutoff: report.RanAt.AddDate(0, 0, -rule.AfterDays)}
+		if err := e.scope(ctx, rule, res.Cutoff).Count(&res.Matched).Error; err != nil {
+			return report, err
+		}
+		var err error
+		if !dryRun && res.Matched > 0 {
+			res.Affected, err = e.apply(ctx, rule, res.Cutoff)
+		}
+		report.Results = append(report.Results, res)
+		if res.Matched > 0 {
+			e.record(ctx, res, dryRun)
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to %s orders: %w", rule.Action, err)
+		}
+	}
+	return report, nil
+}
+
+// scope selects the orders rule applies to. Orders already anonymized are
+// left out of anonymize rules so they are not counted again.
+func (e *Enforcer) scope(ctx context.Context, rule Rule, cutoff time.Time) *gorm.DB {
+	tx := e.db.WithContext(ctx).Model(&repository.Order{}).Where("created_at < ?", cutoff)
+	if rule.Tenant != "" {
+		tx = tx.Where("tenant_id = ?", rule.Tenant)
+	} else if own := e.tenantsWithRules(); len(own) > 0 {
+		tx = tx.Where("tenant_id NOT IN ?", own)
+	}
+	if len(rule.Statuses) > 0 {
+		tx = tx.Where("status IN ?", rule.Statuses)
+	}
+	if rule.Action == ActionAnonymize {
+		tx = tx.Where("(customer_id <> '' OR cart_id <> '')")
+	}
+	return tx
+}
+
+func (e *Enforcer) tenantsWithRules() []string {
+	var tenants []string
+	seen := map[string]bool{}
+	for _, r := range e.rules {
+		if r.Tenant != "" && !seen[r.Tenant] {
+			seen[r.Tenant] = true
+			tenants = append(tenants, r.Tenant)
+		}
+	}
+	return tenants
+}
+
+// apply changes the matching orders and their revisions batchSize at a
+// time, each batch in its own transaction, so a long run does not hold
+// locks on the whole set.
+func (e *Enforcer) apply(ctx context.Context, rule Rule, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		var ids []string
+		if err := e.scope(ctx, rule, cutoff).Order("created_at, id").Limit(e.batchSize).Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if rule.Action == ActionAnonymize {
+				var customers []string
+				err := tx.Model(&repository.Order{}).Where("id IN ? AND customer_id <> ''", ids).Distinct().Pluck("customer_id", &customers).Error
+				if err != nil {
+					return err
+				}
+				err = tx.Model(&repository.Order{}).Where("id IN ?", ids).
+					Updates(map[string]interface{}{"customer_id": "", "cart_id": ""}).Error
+				if err != nil {
+					return err
+				}
+				err = tx.Model(&repository.OrderRevision{}).Where("order_id IN ?", ids).
+					Update("snapshot", gorm.Expr(`snapshot || '{"CustomerID": "", "CartID": ""}'::jsonb`)).Error
+				if err != nil {
+					return err
+				}
+				// The customers' lifetime values are read from the daily
+				// aggregates, which still count the anonymized orders.
+				return stats.RebuildCustomers(ctx, tx, customers)
+			}
+			if err := tx.Where("order_id IN ?", ids).Delete(&repository.OrderRevision{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("order_id IN ?", ids).Delete(&repository.Installment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("order_id IN ?", ids).Delete(&repository.Tender{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("order_id IN ?", ids).Delete(&repository.OrderTag{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("id IN ?", ids).Delete(&repository.Order{}).Error
+		})
+		if err != nil {
+			return total, err
+		}
+		total += int64(len(ids))
+		if len(ids) < e.batchSize {
+			return total, nil
+		}
+	}
+}
+
+func (e *Enforcer) record(ctx context.Context, res Result, dryRun bool) {
+	if e.auditLog == nil {
+		return
+	}
+	action := audit.ActionRetentionPurge
+	if res.Rule.Action == ActionAnonymize {
+		action = audit.ActionRetentionAnonymize
+	}
+	target := res.Rule.Tenant
+	if target == "" {
+		target = "*"
+	}
+	err := e.auditLog.Record(ctx, &audit.Entry{
+		Actor:  auditActor,
+		Action: action,
+		Target: target,
+		After: map[string]interface{}{
+			"rule":     res.Rule,
+			"cutoff":   res.Cutoff,
+			"matched":  res.Matched,
+			"affected": res.Affected,
+			"dryRun":   dryRun,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to record retention audit entry: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package retention
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(`[
+		{"statuses":["REJECTED","PAYMENT_EXPIRED"],"action":"purge","afterDays":730},
+		{"tenant":"acme","action":"anonymize","afterDays":2555}
+	]`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []Rule{
+		{Statuses: []string{"REJECTED", "PAYMENT_EXPIRED"}, Action: ActionPurge, AfterDays: 730},
+		{Tenant: "acme", Action: ActionAnonymize, AfterDays: 2555},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("Expected %+v, got %+v", want, rules)
+	}
+
+	for _, raw := range []string{
+		`[{"action":"delete","afterDays":30}]`,
+		`[{"action":"purge"}]`,
+		`{"action":"purge"}`,
+	} {
+		if _, err := ParseRules(raw); err == nil {
+			t.Errorf("Expected an error for %s", raw)
+		}
+	}
+}
+
+func TestTenantsWithRules(t *testing.T) {
+	e := NewEnforcer(nil, []Rule{
+		{Action: ActionPurge, AfterDays: 1},
+		{Tenant: "acme", Action: ActionPurge, AfterDays: 1},
+		{Tenant: "acme", Action: ActionAnonymize, AfterDays: 2},
+		{Tenant: "globex", Action: ActionPurge, AfterDays: 1},
+	}, nil, 0)
+	if got, want := e.tenantsWithRules(), []string{"acme", "globex"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+func TestPurgeTaggedOrder(t *testing.T) {
+	db, mock := mockDB(t)
+	e := NewEnforcer(db, []Rule{{Statuses: []string{"REJECTED"}, Action: ActionPurge, AfterDays: 30}}, nil, 10)
+	e.now = func() time.Time { return time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC) }
+
+	mock.ExpectQuery(quoted(`SELECT count(*) FROM "orders"`)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(quoted(`SELECT "id" FROM "orders"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("o1"))
+	mock.ExpectBegin()
+	for _, table := range []string{"order_revisions", "installments", "order_tenders", "order_tags"} {
+		mock.ExpectExec(quoted(`DELETE FROM "` + table + `" WHERE order_id IN ($1)`)).WithArgs("o1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	// With the order's tags gone the orders delete passes the foreign key.
+	mock.ExpectExec(quoted(`DELETE FROM "orders" WHERE id IN ($1)`)).WithArgs("o1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	report, err := e.Run(context.Background(), false)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(report.Results) != 1 || report.Results[0].Affected != 1 {
+		t.Errorf("Expected one purged order, got %+v", report.Results)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAnonymizeRebuildsCustomerStats(t *testing.T) {
+	db, mock := mockDB(t)
+	e := NewEnforcer(db, []Rule{{Action: ActionAnonymize, AfterDays: 365}}, nil, 10)
+	e.now = func() time.Time { return time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC) }
+
+	mock.ExpectQuery(quoted(`SELECT count(*) FROM "orders"`)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(quoted(`SELECT "id" FROM "orders"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("o1").AddRow("o2"))
+	mock.ExpectBegin()
+	mock.ExpectQuery(quoted(`SELECT DISTINCT "customer_id" FROM "orders" WHERE id IN ($1,$2) AND customer_id <> ''`)).
+		WithArgs("o1", "o2").WillReturnRows(sqlmock.NewRows([]string{"customer_id"}).AddRow("c1"))
+	mock.ExpectExec(quoted(`UPDATE "orders" SET "cart_id"=$1,"customer_id"=$2`)).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(quoted(`UPDATE "order_revisions" SET "snapshot"=`)).WillReturnResult(sqlmock.NewResult(0, 2))
+	// The customer's aggregates are rebuilt from the orders still carrying
+	// their ID, so the anonymized ones no longer count.
+	day := time.Date(2026, 2, 20, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(quoted(`FROM "orders" WHERE (status IN ($1,$2,$3) AND customer_id <> '') AND customer_id IN ($4)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_id", "day", "currency", "orders", "revenue", "first_order_at", "last_order_at"}).
+			AddRow("c1", day, "IDR", 1, 50.0, day, day))
+	mock.ExpectExec(quoted(`DELETE FROM "customer_order_stats_daily" WHERE customer_id IN ($1)`)).WithArgs("c1").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(quoted(`INSERT INTO "customer_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := e.Run(context.Background(), false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,315 @@
+package revenue
+
+import (
+	"context"
+	"errors"
+	"order-service/internal/repository"
+	"order-service/internal/rounding"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// periodLayout names accounting periods: calendar months in UTC.
+const periodLayout = "2006-01"
+
+var (
+	ErrInvalidPeriod = errors.New("period must be a month in YYYY-MM format")
+	ErrPeriodNotOver = errors.New("period has not ended yet")
+	ErrPeriodClosed  = errors.New("period is already closed")
+)
+
+// Figures are the revenue of the orders created in a period, in one
+// currency. Fully paid orders are recognized; backorders and the unpaid
+// part of installment orders are deferred; rejected and expired orders
+// are cancelled. Orders on hold or waiting for payment or validation are
+// not counted.
+type Figures struct {
+	Currency   string  `json:"currency"`
+	Orders     int64   `json:"orders"`
+	Recognized float64 `json:"recognized"`
+	Deferred   float64 `json:"deferred"`
+	Cancelled  float64 `json:"cancelled"`
+}
+
+// ClosedPeriod locks a period's figures in one currency. Later changes to
+// its orders are posted as adjustments instead.
+type ClosedPeriod struct {
+	Period     string    `gorm:"primaryKey" json:"period"`
+	Currency   string    `gorm:"primaryKey" json:"currency"`
+	Orders     int64     `gorm:"not null" json:"orders"`
+	Recognized float64   `gorm:"not null" json:"recognized"`
+	Deferred   float64   `gorm:"not null" json:"deferred"`
+	Cancelled  float64   `gorm:"not null" json:"cancelled"`
+	ClosedAt   time.Time `json:"closedAt"`
+	ClosedBy   string    `json:"closedBy"`
+}
+
+func (ClosedPeriod) TableName() string { return "revenue_closed_periods" }
+
+func (c ClosedPeriod) Figures() Figures {
+	return Figures{Currency: c.Currency, Orders: c.Orders, Recognized: c.Recognized, Deferred: c.Deferred, Cancelled: c.Cancelled}
+}
+
+// Adjustment is a change to a closed period's figures found later. It is
+// posted in the period that was open when it was found; the amounts are
+// differences.
+type Adjustment struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Period     string    `gorm:"not null;index" json:"period"`
+	PostedIn   string    `gorm:"not null;index" json:"postedIn"`
+	Currency   string    `json:"currency"`
+	Orders     int64     `gorm:"not null" json:"orders"`
+	Recognized float64   `gorm:"not null" json:"recognized"`
+	Deferred   float64   `gorm:"not null" json:"deferred"`
+	Cancelled  float64   `gorm:"not null" json:"cancelled"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (Adjustment) TableName() string { return "revenue_adjustments" }
+
+func newAdjustment(period, postedIn string, delta Figures) *Adjustment {
+	return &Adjustment{
+		Period: period, PostedIn: postedIn, Currency: delta.Currency,
+		Orders: delta.Orders, Recognized: delta.Recognized, Deferred: delta.Deferred, Cancelled: delta.Cancelled,
+	}
+}
+
+func (a Adjustment) Delta() Figures {
+	return Figures{Currency: a.Currency, Orders: a.Orders, Recognized: a.Recognized, Deferred: a.Deferred, Cancelled: a.Cancelled}
+}
+
+// Report is a period's revenue and the adjustments posted in it.
+type Report struct {
+	Period      string       `json:"period"`
+	Closed      bool         `json:"closed"`
+	ClosedAt    *time.Time   `json:"closedAt,omitempty"`
+	Figures     []Figures    `json:"figures"`
+	Adjustments []Adjustment `json:"adjustments"`
+}
+
+type Store struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// ParsePeriod returns the first instant of period and of the next one.
+func ParsePeriod(period string) (time.Time, time.Time, error) {
+	start, err := time.Parse(periodLayout, period)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidPeriod
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// Report returns the period's figures: the locked ones for a closed
+// period, computed from the orders otherwise. Adjustments to closed
+// periods are posted first so they show up in the current period.
+func (s *Store) Report(ctx context.Context, period string) (*Report, error) {
+	if _, _, err := ParsePeriod(period); err != nil {
+		return nil, err
+	}
+	if _, err := s.PostAdjustments(ctx); err != nil {
+		return nil, err
+	}
+
+	report := &Report{Period: period, Figures: []Figures{}}
+	var closed []ClosedPeriod
+	if err := s.db.WithContext(ctx).Where("period = ?", period).Order("currency").Find(&closed).Error; err != nil {
+		return nil, err
+	}
+	if len(closed) > 0 {
+		report.Closed = true
+		report.ClosedAt = &closed[0].ClosedAt
+		for _, c := range closed {
+			if f := c.Figures(); f != (Figures{}) {
+				report.Figures = append(report.Figures, f)
+			}
+		}
+	} else {
+		figures, err := s.compute(s.db.WithContext(ctx), period)
+		if err != nil {
+			return nil, err
+		}
+		report.Figures = figures
+	}
+
+	err := s.db.WithContext(ctx).Where("posted_in = ?", period).Order("id").Find(&report.Adjustments).Error
+	return report, err
+}
+
+// Close locks the figures of a period that has ended.
+func (s *Store) Close(ctx context.Context, period, actor string) ([]ClosedPeriod, error) {
+	_, end, err := ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	if s.now().Before(end) {
+		return nil, ErrPeriodNotOver
+	}
+
+	var closed []ClosedPeriod
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx); err != nil {
+			return err
+		}
+		var n int64
+		if err := tx.Model(&ClosedPeriod{}).Where("period = ?", period).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrPeriodClosed
+		}
+		figures, err := s.compute(tx, period)
+		if err != nil {
+			return err
+		}
+		if len(figures) == 0 {
+			// Keep an empty line so the period still reads as closed.
+			figures = []Figures{{}}
+		}
+		now := s.now().UTC()
+		for _, f := range figures {
+			closed = append(closed, ClosedPeriod{
+				Period: period, Currency: f.Currency, Orders: f.Orders,
+				Recognized: f.Recognized, Deferred: f.Deferred, Cancelled: f.Cancelled,
+				ClosedAt: now, ClosedBy: actor,
+			})
+		}
+		return tx.Create(&closed).Error
+	})
+	return closed, err
+}
+
+// PostAdjustments compares every closed period with its orders as they
+// are now and posts the differences not yet adjusted in the current
+// period. It returns how many adjustments were posted.
+func (s *Store) PostAdjustments(ctx context.Context) (int, error) {
+	posted := 0
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lock(tx); err != nil {
+			return err
+		}
+		var periods []string
+		if err := tx.Model(&ClosedPeriod{}).Distinct("period").Pluck("period", &periods).Error; err != nil {
+			return err
+		}
+		current := s.now().UTC().Format(periodLayout)
+		for _, period := range periods {
+			booked, err := s.booked(tx, period)
+			if err != nil {
+				return err
+			}
+			figures, err := s.compute(tx, period)
+			if err != nil {
+				return err
+			}
+			for _, f := range figures {
+				delta := subtract(f, booked[f.Currency])
+				delete(booked, f.Currency)
+				if !delta.isZero() {
+					if err := tx.Create(newAdjustment(period, current, delta)).Error; err != nil {
+						return err
+					}
+					posted++
+				}
+			}
+			// Currencies whose orders are all gone, e.g. purged.
+			for currency, b := range booked {
+				delta := subtract(Figures{Currency: currency}, b)
+				if !delta.isZero() {
+					if err := tx.Create(newAdjustment(period, current, delta)).Error; err != nil {
+						return err
+					}
+					posted++
+				}
+			}
+		}
+		return nil
+	})
+	return posted, err
+}
+
+// booked returns the locked figures of a closed period plus the
+// adjustments posted for it, per currency.
+func (s *Store) booked(tx *gorm.DB, period string) (map[string]Figures, error) {
+	var closed []ClosedPeriod
+	if err := tx.Where("period = ?", period).Find(&closed).Error; err != nil {
+		return nil, err
+	}
+	var adjustments []Adjustment
+	if err := tx.Where("period = ?", period).Find(&adjustments).Error; err != nil {
+		return nil, err
+	}
+	booked := map[string]Figures{}
+	for _, c := range closed {
+		booked[c.Currency] = add(booked[c.Currency], c.Figures())
+	}
+	for _, a := range adjustments {
+		booked[a.Currency] = add(booked[a.Currency], a.Delta())
+	}
+	return booked, nil
+}
+
+func (s *Store) compute(tx *gorm.DB, period string) ([]Figures, error) {
+	start, end, err := ParsePeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	paid, pending, backordered := repository.StatusPaid, repository.StatusPending, repository.StatusBackordered
+	cancelled := []string{repository.StatusRejected, repository.StatusPaymentExpired}
+	hasInstallments := "EXISTS (SELECT 1 FROM installments i WHERE i.order_id = orders.id)"
+	var figures []Figures
+	err = tx.Model(&repository.Order{}).
+		Select("currency, COUNT(*) AS orders, "+
+			"COALESCE(SUM(CASE WHEN status = ? OR (status = ? AND NOT "+hasInstallments+") THEN total_price "+
+			"WHEN status = ? THEN paid_amount ELSE 0 END), 0) AS recognized, "+
+			"COALESCE(SUM(CASE WHEN status = ? THEN total_price "+
+			"WHEN status = ? AND "+hasInstallments+" THEN total_price - paid_amount ELSE 0 END), 0) AS deferred, "+
+			"COALESCE(SUM(CASE WHEN status IN ? THEN total_price ELSE 0 END), 0) AS cancelled",
+			paid, pending, pending, backordered, pending, cancelled).
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Where("status IN ?", append([]string{paid, pending, backordered}, cancelled...)).
+		Group("currency").
+		Order("currency").
+		Scan(&figures).Error
+	for i := range figures {
+		figures[i] = roundFigures(figures[i])
+	}
+	return figures, err
+}
+
+// lock serializes closing and adjusting for the rest of tx.
+func lock(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext('revenue-periods'))").Error
+}
+
+func add(a, b Figures) Figures {
+	return roundFigures(Figures{
+		Currency:   b.Currency,
+		Orders:     a.Orders + b.Orders,
+		Recognized: a.Recognized + b.Recognized,
+		Deferred:   a.Deferred + b.Deferred,
+		Cancelled:  a.Cancelled + b.Cancelled,
+	})
+}
+
+func subtract(a, b Figures) Figures {
+	return add(a, Figures{Currency: a.Currency, Orders: -b.Orders, Recognized: -b.Recognized, Deferred: -b.Deferred, Cancelled: -b.Cancelled})
+}
+
+func (f Figures) isZero() bool {
+	return f.Orders == 0 && f.Recognized == 0 && f.Deferred == 0 && f.Cancelled == 0
+}
+
+func roundFigures(f Figures) Figures {
+	round := rounding.HalfUp{Places: 2}.Round
+	f.Recognized = round(f.Recognized)
+	f.Deferred = round(f.Deferred)
+	f.Cancelled = round(f.Cancelled)
+	return f
+}
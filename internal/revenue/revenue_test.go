@@ -0,0 +1,36 @@
+package revenue
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	start, end, err := ParsePeriod("2024-12")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !start.Equal(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected range %s - %s", start, end)
+	}
+	for _, period := range []string{"2024-13", "2024-1-01", "december"} {
+		if _, _, err := ParsePeriod(period); !errors.Is(err, ErrInvalidPeriod) {
+			t.Errorf("Expected ErrInvalidPeriod for %q, got %v", period, err)
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	now := Figures{Currency: "IDR", Orders: 10, Recognized: 100.1, Deferred: 20.2, Cancelled: 30}
+	booked := Figures{Currency: "IDR", Orders: 10, Recognized: 110.1, Deferred: 20.2, Cancelled: 20}
+
+	delta := subtract(now, booked)
+	want := Figures{Currency: "IDR", Recognized: -10, Cancelled: 10}
+	if delta != want {
+		t.Errorf("Expected %+v, got %+v", want, delta)
+	}
+	if !subtract(now, now).isZero() {
+		t.Error("Expected no difference between equal figures")
+	}
+}
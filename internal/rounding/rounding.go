@@ -0,0 +1,157 @@
+package rounding
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"order-service/internal/currency"
+)
+
+const (
+	ModeHalfUp   = "half_up"
+	ModeHalfEven = "half_even"
+	ModeCash     = "cash"
+)
+
+// Strategy rounds a monetary amount.
+type Strategy interface {
+	Round(v float64) float64
+}
+
+// HalfUp rounds to Places decimals, halves away from zero.
+type HalfUp struct{ Places int }
+
+func (r HalfUp) Round(v float64) float64 {
+	p := math.Pow10(r.Places)
+	return math.Round(clean(v*p)) / p
+}
+
+// HalfEven rounds to Places decimals, halves to the nearest even digit
+// (banker's rounding).
+type HalfEven struct{ Places int }
+
+func (r HalfEven) Round(v float64) float64 {
+	p := math.Pow10(r.Places)
+	return math.RoundToEven(clean(v*p)) / p
+}
+
+// Cash rounds to the nearest multiple of Increment, e.g. 0.05 where the
+// smallest coin is five cents.
+type Cash struct{ Increment float64 }
+
+func (r Cash) Round(v float64) float64 {
+	return HalfUp{Places: 2}.Round(math.Round(clean(v/r.Increment)) * r.Increment)
+}
+
+// Unit is the smallest amount s rounds to: 0.01 for two places, 1 for
+// none, the increment for cash rounding. Strategies of other types are
+// taken to round to cents.
+func Unit(s Strategy) float64 {
+	switch r := s.(type) {
+	case HalfUp:
+		return math.Pow10(-r.Places)
+	case HalfEven:
+		return math.Pow10(-r.Places)
+	case Cash:
+		return r.Increment
+	default:
+		return 0.01
+	}
+}
+
+// clean drops float noise below the ninth decimal of a scaled amount, so
+// 1.005 * 100 rounds as 100.5 rather than 100.49999999999999.
+func clean(v float64) float64 {
+	return math.Round(v*1e9) / 1e9
+}
+
+// Default is used when nothing else is configured.
+var Default Strategy = HalfUp{Places: 2}
+
+// Rule is the JSON form of a strategy.
+type Rule struct {
+	Mode      string  `json:"mode"`
+	Places    *int    `json:"places,omitempty"`
+	Increment float64 `json:"increment,omitempty"`
+}
+
+func (r Rule) strategy() (Strategy, error) {
+	places := 2
+	if r.Places != nil {
+		places = *r.Places
+	}
+	switch r.Mode {
+	case ModeHalfUp, "":
+		return HalfUp{Places: places}, nil
+	case ModeHalfEven:
+		return HalfEven{Places: places}, nil
+	case ModeCash:
+		if r.Increment <= 0 {
+			return nil, fmt.Errorf("cash rounding needs a positive increment")
+		}
+		return Cash{Increment: r.Increment}, nil
+	default:
+		return nil, fmt.Errorf("unknown rounding mode %q", r.Mode)
+	}
+}
+
+// Policy picks the strategy for an amount. A tenant rule wins over a
+// currency rule, which wins over the default.
+type Policy struct {
+	def        Strategy
+	currencies map[string]Strategy
+	tenants    map[string]Strategy
+}
+
+// Parse reads a policy such as
+// {"default":{"mode":"half_up"},"currencies":{"IDR":{"places":0}},"tenants":{"acme":{"mode":"half_even"}}}.
+// An empty string yields half-up to two decimals everywhere.
+func Parse(data string) (*Policy, error) {
+	p := &Policy{def: Default, currencies: map[string]Strategy{}, tenants: map[string]Strategy{}}
+	if data == "" {
+		return p, nil
+	}
+
+	var raw struct {
+		Default    *Rule           `json:"default"`
+		Currencies map[string]Rule `json:"currencies"`
+		Tenants    map[string]Rule `json:"tenants"`
+	}
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse rounding policy: %w", err)
+	}
+
+	var err error
+	if raw.Default != nil {
+		if p.def, err = raw.Default.strategy(); err != nil {
+			return nil, fmt.Errorf("default rounding: %w", err)
+		}
+	}
+	for code, rule := range raw.Currencies {
+		if p.currencies[currency.Normalize(code)], err = rule.strategy(); err != nil {
+			return nil, fmt.Errorf("rounding for %s: %w", code, err)
+		}
+	}
+	for id, rule := range raw.Tenants {
+		if p.tenants[id], err = rule.strategy(); err != nil {
+			return nil, fmt.Errorf("rounding for tenant %s: %w", id, err)
+		}
+	}
+	return p, nil
+}
+
+// For returns the strategy for amounts in currency charged by tenantID. A
+// nil Policy always returns Default.
+func (p *Policy) For(tenantID, code string) Strategy {
+	if p == nil {
+		return Default
+	}
+	if s, ok := p.tenants[tenantID]; ok && tenantID != "" {
+		return s
+	}
+	if s, ok := p.currencies[currency.Normalize(code)]; ok {
+		return s
+	}
+	return p.def
+}
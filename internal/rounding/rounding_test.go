@@ -0,0 +1,63 @@
+package rounding
+
+import "testing"
+
+func TestStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy Strategy
+		in, want float64
+	}{
+		{"half up", HalfUp{Places: 2}, 1.005, 1.01},
+		{"half up, no decimals", HalfUp{Places: 0}, 2.5, 3},
+		{"half even rounds down to even", HalfEven{Places: 2}, 1.125, 1.12},
+		{"half even rounds up to even", HalfEven{Places: 2}, 1.135, 1.14},
+		{"cash down", Cash{Increment: 0.05}, 1.02, 1.00},
+		{"cash up", Cash{Increment: 0.05}, 1.03, 1.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.strategy.Round(tt.in); got != tt.want {
+				t.Errorf("Round(%v) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnit(t *testing.T) {
+	for _, tt := range []struct {
+		strategy Strategy
+		want     float64
+	}{
+		{HalfUp{Places: 2}, 0.01},
+		{HalfEven{Places: 0}, 1},
+		{Cash{Increment: 0.05}, 0.05},
+	} {
+		if got := Unit(tt.strategy); got != tt.want {
+			t.Errorf("Unit(%#v) = %v, want %v", tt.strategy, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := Parse(`{"currencies":{"chf":{"mode":"cash","increment":0.05},"IDR":{"places":0}},"tenants":{"acme":{"mode":"half_even"}}}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := p.For("", "CHF").Round(1.03); got != 1.05 {
+		t.Errorf("Expected CHF cash rounding, got %v", got)
+	}
+	if got := p.For("", "IDR").Round(1500.5); got != 1501 {
+		t.Errorf("Expected IDR rounding to whole units, got %v", got)
+	}
+	if got := p.For("acme", "CHF").Round(1.125); got != 1.12 {
+		t.Errorf("Expected tenant rule to win, got %v", got)
+	}
+	if got := p.For("other", "USD").Round(1.125); got != 1.13 {
+		t.Errorf("Expected default half-up, got %v", got)
+	}
+
+	if _, err := Parse(`{"default":{"mode":"cash"}}`); err == nil {
+		t.Error("Expected an error for cash rounding without increment")
+	}
+}
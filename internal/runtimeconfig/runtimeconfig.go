@@ -0,0 +1,262 @@
+package runtimeconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"maps"
+	"order-service/internal/acceptance"
+	"order-service/internal/errreport"
+	"order-service/internal/featureflags"
+	"order-service/internal/limits"
+	"order-service/internal/quota"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Config is the part of the configuration that can change without a
+// restart.
+type Config struct {
+	LogLevel       string                       `json:"logLevel"`
+	PurchaseLimits limits.Config                `json:"purchaseLimits"`
+	CacheTTL       Duration                     `json:"cacheTTL"`
+	FeatureFlags   map[string]featureflags.Rule `json:"featureFlags,omitempty"`
+	// AcceptanceRules replace the rules from the environment as a whole.
+	AcceptanceRules []acceptance.Rule `json:"acceptanceRules,omitempty"`
+	RequestQuotas   quota.Config      `json:"requestQuotas"`
+	// ErrorReportClasses are the classes of errors sent to the error
+	// tracker; an empty list sends none.
+	ErrorReportClasses []string `json:"errorReportClasses"`
+}
+
+// Duration is a time.Duration written as a string like "60s".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (c Config) clone() Config {
+	c.PurchaseLimits.Products = maps.Clone(c.PurchaseLimits.Products)
+	c.RequestQuotas.Tenants = maps.Clone(c.RequestQuotas.Tenants)
+	c.RequestQuotas.Keys = maps.Clone(c.RequestQuotas.Keys)
+	c.FeatureFlags = maps.Clone(c.FeatureFlags)
+	c.AcceptanceRules = slices.Clone(c.AcceptanceRules)
+	c.ErrorReportClasses = slices.Clone(c.ErrorReportClasses)
+	return c
+}
+
+func (c Config) validate() error {
+	if _, err := ParseLevel(c.LogLevel); err != nil {
+		return err
+	}
+	if c.CacheTTL <= 0 {
+		return fmt.Errorf("cacheTTL must be positive")
+	}
+	if err := quota.Validate(c.RequestQuotas); err != nil {
+		return err
+	}
+	if err := errreport.ValidateClasses(c.ErrorReportClasses); err != nil {
+		return err
+	}
+	return acceptance.Validate(c.AcceptanceRules)
+}
+
+// ParseLevel accepts debug, info, warn and error; empty means info.
+func ParseLevel(v string) (slog.Level, error) {
+	switch strings.ToLower(v) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q", v)
+}
+
+// Manager holds the effective configuration: the base from the environment
+// with the config file, if any, applied on top. Fields missing from the
+// file keep their base value.
+type Manager struct {
+	base func() (Config, error)
+	path string
+
+	mu        sync.RWMutex
+	current   Config
+	loadedAt  time.Time
+	listeners []func(Config)
+}
+
+func New(base func() (Config, error), path string) *Manager {
+	return &Manager{base: base, path: path}
+}
+
+// OnChange registers fn to be called with the new configuration after
+// every successful reload.
+func (m *Manager) OnChange(fn func(Config)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// Reload rebuilds the configuration. An invalid configuration is rejected
+// and the previous one stays in effect.
+func (m *Manager) Reload() error {
+	cfg, err := m.load()
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.current = cfg
+	m.loadedAt = time.Now()
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(cfg.clone())
+	}
+	return nil
+}
+
+func (m *Manager) load() (Config, error) {
+	cfg, err := m.base()
+	if err != nil {
+		return Config{}, err
+	}
+	if m.path != "" {
+		data, err := os.ReadFile(m.path)
+		if err != nil {
+			return Config{}, err
+		}
+		cfg = cfg.clone()
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("failed to parse %s: %w", m.path, err)
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Current returns the effective configuration.
+func (m *Manager) Current() Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current.clone()
+}
+
+// Snapshot is the effective configuration as shown to admins.
+type Snapshot struct {
+	Config   Config    `json:"config"`
+	File     string    `json:"file,omitempty"`
+	LoadedAt time.Time `json:"loadedAt"`
+}
+
+func (m *Manager) Snapshot() Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return Snapshot{Config: m.current.clone(), File: m.path, LoadedAt: m.loadedAt}
+}
+
+func (m *Manager) reload(trigger string) {
+	if err := m.Reload(); err != nil {
+		log.Printf("Failed to reload configuration on %s, keeping the previous one: %v", trigger, err)
+		return
+	}
+	log.Printf("Reloaded configuration on %s", trigger)
+}
+
+// WatchSignal reloads on SIGHUP until ctx is cancelled.
+func (m *Manager) WatchSignal(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			m.reload("SIGHUP")
+		}
+	}
+}
+
+// WatchFile reloads when the config file's modification time changes,
+// checking every interval.
+func (m *Manager) WatchFile(ctx context.Context, interval time.Duration) {
+	if m.path == "" {
+		return
+	}
+	var last time.Time
+	if info, err := os.Stat(m.path); err == nil {
+		last = info.ModTime()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		info, err := os.Stat(m.path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Printf("Failed to check %s: %v", m.path, err)
+			}
+			continue
+		}
+		if info.ModTime().Equal(last) {
+			continue
+		}
+		last = info.ModTime()
+		m.reload("change of " + m.path)
+	}
+}
+
+// Subscribe reloads whenever a message is published on the Redis channel,
+// so every instance can be told to reload at once.
+func (m *Manager) Subscribe(ctx context.Context, client *redis.Client, channel string) {
+	sub := client.Subscribe(ctx, channel)
+	defer sub.Close()
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-messages:
+			if !ok {
+				return
+			}
+			m.reload("message on " + channel)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package runtimeconfig
+
+import (
+	"order-service/internal/featureflags"
+	"order-service/internal/limits"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func base() (Config, error) {
+	return Config{
+		LogLevel:       "info",
+		CacheTTL:       Duration(time.Minute),
+		PurchaseLimits: limits.Config{Default: limits.Rule{MinQuantity: 1}},
+		FeatureFlags:   map[string]featureflags.Rule{"a": {Enabled: true}},
+	}, nil
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"logLevel": "debug", "featureFlags": {"b": {"enabled": true}}}`)
+
+	m := New(base, path)
+	var applied []Config
+	m.OnChange(func(c Config) { applied = append(applied, c) })
+	if err := m.Reload(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cfg := m.Current()
+	if cfg.LogLevel != "debug" {
+		t.Errorf("Expected file to override log level, got %q", cfg.LogLevel)
+	}
+	if cfg.CacheTTL != Duration(time.Minute) {
+		t.Errorf("Expected cache TTL from base, got %s", time.Duration(cfg.CacheTTL))
+	}
+	if !cfg.FeatureFlags["a"].Enabled || !cfg.FeatureFlags["b"].Enabled {
+		t.Errorf("Expected flags to be merged, got %v", cfg.FeatureFlags)
+	}
+	if len(applied) != 1 {
+		t.Errorf("Expected listener to be called once, got %d", len(applied))
+	}
+
+	t.Run("current is a copy", func(t *testing.T) {
+		cfg := m.Current()
+		cfg.FeatureFlags["c"] = featureflags.Rule{Enabled: true}
+		if _, ok := m.Current().FeatureFlags["c"]; ok {
+			t.Error("Expected changes to the returned config not to leak")
+		}
+	})
+
+	t.Run("invalid config keeps the previous one", func(t *testing.T) {
+		before := m.Current()
+		write(`{"logLevel": "loud"}`)
+		if err := m.Reload(); err == nil {
+			t.Fatal("Expected an error")
+		}
+		write(`{"cacheTTL": "0s"}`)
+		if err := m.Reload(); err == nil {
+			t.Fatal("Expected an error")
+		}
+		if m.Current().LogLevel != before.LogLevel || len(applied) != 1 {
+			t.Errorf("Expected previous config to stay in effect")
+		}
+	})
+}
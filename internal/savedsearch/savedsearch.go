@@ -0,0 +1,137 @@
+// Package savedsearch stores named order searches of admin users, so a
+// complicated filter is built once and run by ID afterwards.
+package savedsearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidSearch = errors.New("invalid saved search")
+	ErrNotFound      = errors.New("saved search not found")
+	ErrDuplicate     = errors.New("saved search name already in use")
+	ErrNotOwner      = errors.New("saved search belongs to another user")
+)
+
+// Params are the parameters of the admin order search a saved search may
+// hold.
+var Params = []string{"productId", "status", "tag", "from", "to", "limit", "offset"}
+
+// maxNameLength caps the length of a saved search's name.
+const maxNameLength = 100
+
+// Search is a named order search. It is visible to its owner only, unless
+// it is shared with the whole team.
+type Search struct {
+	ID    string `gorm:"primaryKey" json:"id"`
+	Owner string `gorm:"not null;uniqueIndex:idx_saved_searches_owner_name" json:"owner"`
+	Name  string `gorm:"not null;uniqueIndex:idx_saved_searches_owner_name" json:"name"`
+	// Query is the search's query string, e.g. "status=PENDING&tag=vip".
+	Query     string    `gorm:"not null" json:"query"`
+	Shared    bool      `gorm:"not null;default:false;index" json:"shared"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func (Search) TableName() string { return "saved_searches" }
+
+// Values returns the search's parameters.
+func (s Search) Values() url.Values {
+	values, _ := url.ParseQuery(s.Query)
+	return values
+}
+
+// VisibleTo reports whether user may see and run the search.
+func (s Search) VisibleTo(user string) bool {
+	return s.Shared || s.Owner == user
+}
+
+// normalize trims the name and query and checks the query only holds
+// search parameters.
+func (s *Search) normalize() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Owner == "" || s.Name == "" || len(s.Name) > maxNameLength {
+		return fmt.Errorf("%w: a name of at most %d characters is required", ErrInvalidSearch, maxNameLength)
+	}
+	values, err := url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(s.Query), "?"))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSearch, err)
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("%w: query is required", ErrInvalidSearch)
+	}
+	for key := range values {
+		if !slices.Contains(Params, key) {
+			return fmt.Errorf("%w: unknown parameter %q, expected one of %s", ErrInvalidSearch, key, strings.Join(Params, ", "))
+		}
+	}
+	s.Query = values.Encode()
+	return nil
+}
+
+// Store keeps saved searches in Postgres.
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (st *Store) Create(ctx context.Context, s *Search) error {
+	if err := s.normalize(); err != nil {
+		return err
+	}
+	s.ID = uuid.New().String()
+	if err := st.db.WithContext(ctx).Create(s).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrDuplicate
+		}
+		return err
+	}
+	return nil
+}
+
+// Get returns a search user may see.
+func (st *Store) Get(ctx context.Context, id, user string) (*Search, error) {
+	var s Search
+	err := st.db.WithContext(ctx).First(&s, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && !s.VisibleTo(user) {
+		return nil, ErrNotFound
+	}
+	return &s, err
+}
+
+// List returns the searches user may see, their own first, by name.
+func (st *Store) List(ctx context.Context, user string) ([]Search, error) {
+	var searches []Search
+	err := st.db.WithContext(ctx).
+		Where("owner = ? OR shared", user).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "owner <> ?, name, id", Vars: []any{user}}}).
+		Find(&searches).Error
+	return searches, err
+}
+
+// Delete removes a search. Only its owner may delete it.
+func (st *Store) Delete(ctx context.Context, id, user string) (*Search, error) {
+	s, err := st.Get(ctx, id, user)
+	if err != nil {
+		return nil, err
+	}
+	if s.Owner != user {
+		return nil, ErrNotOwner
+	}
+	if err := st.db.WithContext(ctx).Delete(s).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
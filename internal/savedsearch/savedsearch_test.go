@@ -0,0 +1,42 @@
+package savedsearch
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	s := Search{Owner: "alice", Name: "  stuck flash sale  ", Query: "?tag=flash-sale&status=PENDING&tag=vip"}
+	if err := s.normalize(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if s.Name != "stuck flash sale" || s.Query != "status=PENDING&tag=flash-sale&tag=vip" {
+		t.Errorf("Unexpected normalized search %+v", s)
+	}
+	if tags := s.Values()["tag"]; len(tags) != 2 {
+		t.Errorf("Expected two tags, got %v", tags)
+	}
+
+	for name, invalid := range map[string]Search{
+		"no name":       {Owner: "alice", Query: "status=PENDING"},
+		"no owner":      {Name: "n", Query: "status=PENDING"},
+		"no query":      {Owner: "alice", Name: "n"},
+		"bad query":     {Owner: "alice", Name: "n", Query: "status=%zz"},
+		"unknown param": {Owner: "alice", Name: "n", Query: "customerId=c1"},
+	} {
+		if err := invalid.normalize(); !errors.Is(err, ErrInvalidSearch) {
+			t.Errorf("%s: expected ErrInvalidSearch, got %v", name, err)
+		}
+	}
+}
+
+func TestVisibleTo(t *testing.T) {
+	private := Search{Owner: "alice"}
+	shared := Search{Owner: "alice", Shared: true}
+	if !private.VisibleTo("alice") || private.VisibleTo("bob") {
+		t.Error("Expected a private search to be visible to its owner only")
+	}
+	if !shared.VisibleTo("bob") {
+		t.Error("Expected a shared search to be visible to everyone")
+	}
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs jobs on fixed intervals, each in its own goroutine. A run
+// that overlaps the next tick delays it instead of running concurrently.
+type Scheduler struct {
+	jobs []Job
+}
+
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+func (s *Scheduler) Add(name string, interval time.Duration, run func(ctx context.Context) error) {
+	s.jobs = append(s.jobs, Job{Name: name, Interval: interval, Run: run})
+}
+
+// Start launches every job until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go Loop(ctx, job)
+	}
+}
+
+// Jobs returns the jobs added so far, for callers that run the loops
+// themselves.
+func (s *Scheduler) Jobs() []Job {
+	return s.jobs
+}
+
+// Loop runs job every Interval until ctx is cancelled. Failed runs are
+// logged and do not stop the loop.
+func Loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job.Run(ctx); err != nil {
+				log.Printf("Scheduled job %s failed: %v", job.Name, err)
+			}
+		}
+	}
+}
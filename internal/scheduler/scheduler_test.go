@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan int, 10)
+	n := 0
+	done := make(chan struct{})
+	go func() {
+		Loop(ctx, Job{Name: "test", Interval: time.Millisecond, Run: func(ctx context.Context) error {
+			n++
+			runs <- n
+			if n == 1 {
+				// A failed run, e.g. one that lost a status race, does
+				// not stop the loop.
+				return errors.New("status conflict")
+			}
+			return nil
+		}})
+		close(done)
+	}()
+
+	for want := 1; want <= 3; want++ {
+		select {
+		case got := <-runs:
+			if got != want {
+				t.Fatalf("Expected run %d, got %d", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected run %d", want)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the loop to stop once ctx is cancelled")
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ran := make(chan string, 10)
+	s := New()
+	for _, name := range []string{"a", "b"} {
+		name := name
+		s.Add(name, time.Millisecond, func(ctx context.Context) error {
+			ran <- name
+			return nil
+		})
+	}
+	if len(s.Jobs()) != 2 {
+		t.Fatalf("Expected 2 jobs, got %d", len(s.Jobs()))
+	}
+
+	s.Start(ctx)
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		select {
+		case name := <-ran:
+			seen[name] = true
+		case <-time.After(time.Second):
+			t.Fatalf("Expected every job to run, got %v", seen)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Package schema validates event payloads against the JSON Schemas of
+// their patterns. The schemas are embedded from schemas/<pattern>.json and
+// served at /schemas for the teams that consume our events.
+//
+// Only the part of JSON Schema our schemas use is supported: type, enum,
+// properties, required, additionalProperties (as a boolean), items,
+// minimum, minLength and the date-time format.
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidEvent is returned for payloads that do not match their schema.
+var ErrInvalidEvent = errors.New("event does not match its schema")
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// Schema is the supported subset of a JSON Schema.
+type Schema struct {
+	Type                 typeList           `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	Format               string             `json:"format,omitempty"`
+}
+
+// typeList is "type" given as one type or a list of them.
+type typeList []string
+
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = typeList{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = many
+	return nil
+}
+
+// Registry holds the schema of every pattern that has one.
+type Registry struct {
+	raw      map[string]json.RawMessage
+	compiled map[string]*Schema
+}
+
+// Load reads the embedded schemas.
+func Load() (*Registry, error) {
+	entries, err := schemaFS.ReadDir("schemas")
+	if err != nil {
+		return nil, err
+	}
+	r := &Registry{raw: map[string]json.RawMessage{}, compiled: map[string]*Schema{}}
+	for _, e := range entries {
+		data, err := schemaFS.ReadFile(path.Join("schemas", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var s Schema
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("invalid schema %s: %w", e.Name(), err)
+		}
+		pattern := strings.TrimSuffix(e.Name(), ".json")
+		r.raw[pattern] = data
+		r.compiled[pattern] = &s
+	}
+	return r, nil
+}
+
+// Patterns returns the patterns that have a schema, sorted.
+func (r *Registry) Patterns() []string {
+	patterns := make([]string, 0, len(r.raw))
+	for p := range r.raw {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// Raw returns the schema document of a pattern as it is published.
+func (r *Registry) Raw(pattern string) (json.RawMessage, bool) {
+	raw, ok := r.raw[pattern]
+	return raw, ok
+}
+
+// Validate checks the JSON payload of an event. Patterns without a schema
+// are not checked.
+func (r *Registry) Validate(pattern string, data []byte) error {
+	s, ok := r.compiled[pattern]
+	if !ok {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidEvent, pattern, err)
+	}
+	if err := s.validate("data", v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidEvent, pattern, err)
+	}
+	return nil
+}
+
+// ValidateValue is Validate for a payload that is not encoded yet.
+func (r *Registry) ValidateValue(pattern string, data interface{}) error {
+	if _, ok := r.compiled[pattern]; !ok {
+		return nil
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidEvent, pattern, err)
+	}
+	return r.Validate(pattern, encoded)
+}
+
+func (s *Schema) validate(at string, v interface{}) error {
+	if len(s.Type) > 0 && !s.hasType(v) {
+		return fmt.Errorf("%s must be %s", at, strings.Join(s.Type, " or "))
+	}
+	if len(s.Enum) > 0 && !s.inEnum(v) {
+		return fmt.Errorf("%s must be one of %v", at, s.Enum)
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s.%s is required", at, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s is not allowed", at, name)
+				}
+				continue
+			}
+			if err := prop.validate(at+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", at, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fmt.Errorf("%s must be at least %v", at, *s.Minimum)
+		}
+	case string:
+		if s.MinLength != nil && len([]rune(v)) < *s.MinLength {
+			return fmt.Errorf("%s must be at least %d characters", at, *s.MinLength)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return fmt.Errorf("%s must be an RFC 3339 date-time", at)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) hasType(v interface{}) bool {
+	for _, t := range s.Type {
+		switch t {
+		case "object":
+			if _, ok := v.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]interface{}); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "number":
+			if _, ok := v.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := v.(float64); ok && f == float64(int64(f)) {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "null":
+			if v == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Schema) inEnum(v interface{}) bool {
+	for _, e := range s.Enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
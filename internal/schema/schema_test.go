@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	r, err := Load()
+	if err != nil {
+		t.Fatalf("Expected the embedded schemas to load, got %v", err)
+	}
+	until := time.Now().Add(15 * time.Minute)
+
+	valid := map[string]interface{}{
+		"order.created":             map[string]interface{}{"orderId": "o1", "productId": "p1", "quantity": 2, "customerId": "c1"},
+		"order.reserved":            map[string]interface{}{"orderId": "o1", "productId": "p1", "quantity": 2, "reservedUntil": &until},
+		"product.stock_replenished": map[string]interface{}{"productId": "p1", "qty": 10},
+		"order.unknown":             map[string]interface{}{"anything": true},
+	}
+	for pattern, data := range valid {
+		if err := r.ValidateValue(pattern, data); err != nil {
+			t.Errorf("%s: expected no error, got %v", pattern, err)
+		}
+	}
+
+	invalid := map[string]string{
+		"order.created":            `{"orderId":"o1","productId":"p1"}`,
+		"order.paid":               `{"orderId":"","productId":"p1"}`,
+		"order.backordered":        `{"orderId":"o1","productId":"p1","quantity":1.5}`,
+		"order.scheduled":          `{"orderId":"o1","productId":"p1","processAt":"tomorrow"}`,
+		"payment.installment_paid": `["o1"]`,
+	}
+	for pattern, data := range invalid {
+		if err := r.Validate(pattern, []byte(data)); !errors.Is(err, ErrInvalidEvent) {
+			t.Errorf("%s: expected ErrInvalidEvent for %s, got %v", pattern, data, err)
+		}
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	r, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := r.Patterns()
+	if len(patterns) == 0 || patterns[0] != "order.anomaly_detected" {
+		t.Errorf("Expected sorted patterns, got %v", patterns)
+	}
+	if _, ok := r.Raw("order.created"); !ok {
+		t.Errorf("Expected the order.created schema")
+	}
+}
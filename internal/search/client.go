@@ -0,0 +1,202 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"order-service/internal/repository"
+)
+
+// Client is a minimal OpenSearch/Elasticsearch REST client covering the
+// handful of endpoints the order index needs.
+type Client struct {
+	baseURL    string
+	index      string
+	httpClient *http.Client
+}
+
+func NewClient(baseURL, index string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		index:      index,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// document is the indexed representation of an order.
+type document struct {
+	ID         string    `json:"id"`
+	ProductID  string    `json:"product_id"`
+	CustomerID string    `json:"customer_id,omitempty"`
+	CartID     string    `json:"cart_id,omitempty"`
+	TotalPrice float64   `json:"total_price"`
+	Quantity   int       `json:"quantity"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func toDocument(o repository.Order) document {
+	return document{
+		ID:         o.ID,
+		ProductID:  o.ProductID,
+		CustomerID: o.CustomerID,
+		CartID:     o.CartID,
+		TotalPrice: o.TotalPrice,
+		Quantity:   o.Quantity,
+		Status:     o.Status,
+		CreatedAt:  o.CreatedAt,
+	}
+}
+
+func (d document) toOrder() repository.Order {
+	return repository.Order{
+		ID:         d.ID,
+		ProductID:  d.ProductID,
+		CustomerID: d.CustomerID,
+		CartID:     d.CartID,
+		TotalPrice: d.TotalPrice,
+		Quantity:   d.Quantity,
+		Status:     d.Status,
+		CreatedAt:  d.CreatedAt,
+	}
+}
+
+var indexMapping = map[string]interface{}{
+	"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"id":          map[string]string{"type": "keyword"},
+			"product_id":  map[string]string{"type": "keyword"},
+			"customer_id": map[string]string{"type": "keyword"},
+			"cart_id":     map[string]string{"type": "keyword"},
+			"total_price": map[string]string{"type": "double"},
+			"quantity":    map[string]string{"type": "integer"},
+			"status":      map[string]string{"type": "keyword"},
+			"created_at":  map[string]string{"type": "date"},
+		},
+	},
+}
+
+// EnsureIndex creates the order index with its mapping if it is missing.
+func (c *Client) EnsureIndex(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodHead, "/"+c.index, "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	body, err := json.Marshal(indexMapping)
+	if err != nil {
+		return err
+	}
+	resp, err = c.do(ctx, http.MethodPut, "/"+c.index, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStatus(resp)
+}
+
+// Bulk indexes the given orders with a single _bulk request.
+func (c *Client) Bulk(ctx context.Context, orders []repository.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, o := range orders {
+		action := map[string]interface{}{
+			"index": map[string]string{"_index": c.index, "_id": o.ID},
+		}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(toDocument(o)); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", buf.Bytes())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+	if result.Errors {
+		return fmt.Errorf("bulk request reported item errors")
+	}
+	return nil
+}
+
+func (c *Client) search(ctx context.Context, query map[string]interface{}) ([]repository.Order, error) {
+	body, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/"+c.index+"/_search", "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode search response: %w", err)
+	}
+
+	orders := make([]repository.Order, 0, len(result.Hits.Hits))
+	for _, hit := range result.Hits.Hits {
+		orders = append(orders, hit.Source.toOrder())
+	}
+	return orders, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call opensearch: %w", err)
+	}
+	return resp, nil
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("opensearch returned status %s: %s", resp.Status, msg)
+}
@@ -0,0 +1,108 @@
+package search
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"order-service/internal/events"
+	"order-service/internal/repository"
+)
+
+const (
+	indexBatchSize     = 200
+	indexFlushInterval = time.Second
+)
+
+// Indexer mirrors orders into OpenSearch. Orders are queued as the events
+// bus reports them written and flushed in bulk by Run; anything dropped
+// because the queue was full is picked up by the next Reindex.
+type Indexer struct {
+	client *Client
+	queue  chan repository.Order
+}
+
+func NewIndexer(client *Client, queueSize int) *Indexer {
+	return &Indexer{
+		client: client,
+		queue:  make(chan repository.Order, queueSize),
+	}
+}
+
+// Index queues an order for indexing without blocking the caller.
+func (i *Indexer) Index(order repository.Order) {
+	select {
+	case i.queue <- order:
+	default:
+		log.Printf("Search index queue full, dropping order %s", order.ID)
+	}
+}
+
+// Subscribe queues every order bus reports as stored or changed.
+func (i *Indexer) Subscribe(bus *events.Bus) {
+	bus.Subscribe("search-indexer", func(_ context.Context, ev events.Event) error {
+		i.Index(ev.Order)
+		return nil
+	}, events.OrderCreated, events.OrderUpdated, events.OrderValidated, events.OrderActivated)
+}
+
+// Run flushes queued orders until ctx is cancelled. Orders still queued
+// then are flushed before it returns.
+func (i *Indexer) Run(ctx context.Context) {
+	ticker := time.NewTicker(indexFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]repository.Order, 0, indexBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := i.client.Bulk(context.Background(), batch); err != nil {
+			log.Printf("Failed to index %d orders: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case order := <-i.queue:
+					batch = append(batch, order)
+					if len(batch) >= indexBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		case order := <-i.queue:
+			batch = append(batch, order)
+			if len(batch) >= indexBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Reindex streams every order from the repository into the index.
+func (i *Indexer) Reindex(ctx context.Context, repo repository.IOrderRepository) error {
+	batch := make([]repository.Order, 0, indexBatchSize)
+	err := repo.Stream(ctx, repository.OrderFilter{}, func(order repository.Order) error {
+		batch = append(batch, order)
+		if len(batch) < indexBatchSize {
+			return nil
+		}
+		err := i.client.Bulk(ctx, batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return i.client.Bulk(ctx, batch)
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"order-service/internal/repository"
+)
+
+// Repository serves order searches from OpenSearch.
+type Repository struct {
+	client *Client
+}
+
+var _ repository.IOrderSearcher = &Repository{}
+
+func NewRepository(client *Client) *Repository {
+	return &Repository{client: client}
+}
+
+func (r *Repository) Search(ctx context.Context, filter repository.OrderFilter, limit, offset int) ([]repository.Order, error) {
+	var must []interface{}
+	if filter.ProductID != "" {
+		must = append(must, term("product_id", filter.ProductID))
+	}
+	if filter.CustomerID != "" {
+		must = append(must, term("customer_id", filter.CustomerID))
+	}
+	if filter.CartID != "" {
+		must = append(must, term("cart_id", filter.CartID))
+	}
+	if filter.Status != "" {
+		must = append(must, term("status", filter.Status))
+	}
+	if !filter.CreatedFrom.IsZero() || !filter.CreatedTo.IsZero() {
+		rng := map[string]interface{}{}
+		if !filter.CreatedFrom.IsZero() {
+			rng["gte"] = filter.CreatedFrom.Format(time.RFC3339Nano)
+		}
+		if !filter.CreatedTo.IsZero() {
+			rng["lt"] = filter.CreatedTo.Format(time.RFC3339Nano)
+		}
+		must = append(must, map[string]interface{}{
+			"range": map[string]interface{}{"created_at": rng},
+		})
+	}
+
+	query := map[string]interface{}{
+		"from": offset,
+		"size": limit,
+		"sort": []interface{}{
+			map[string]string{"created_at": "desc"},
+			map[string]string{"id": "asc"},
+		},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{"filter": must},
+		},
+	}
+	return r.client.search(ctx, query)
+}
+
+func term(field, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"term": map[string]string{field: value},
+	}
+}
+
+// FallbackSearcher queries primary and falls back to secondary when the
+// primary fails, so an OpenSearch outage degrades to Postgres instead of
+// failing the request.
+type FallbackSearcher struct {
+	primary   repository.IOrderSearcher
+	secondary repository.IOrderSearcher
+}
+
+var _ repository.IOrderSearcher = &FallbackSearcher{}
+
+func NewFallbackSearcher(primary, secondary repository.IOrderSearcher) *FallbackSearcher {
+	return &FallbackSearcher{primary: primary, secondary: secondary}
+}
+
+func (f *FallbackSearcher) Search(ctx context.Context, filter repository.OrderFilter, limit, offset int) ([]repository.Order, error) {
+	orders, err := f.primary.Search(ctx, filter, limit, offset)
+	if err == nil {
+		return orders, nil
+	}
+	log.Printf("Primary search failed, falling back: %v", err)
+	return f.secondary.Search(ctx, filter, limit, offset)
+}
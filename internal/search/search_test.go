@@ -0,0 +1,201 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOpenSearch records the documents sent to _bulk and answers _search
+// with hits.
+type fakeOpenSearch struct {
+	mu    sync.Mutex
+	docs  []document
+	query map[string]interface{}
+	hits  []document
+	fail  bool
+}
+
+func (f *fakeOpenSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	switch {
+	case r.URL.Path == "/_bulk":
+		scanner := bufio.NewScanner(r.Body)
+		for line := 0; scanner.Scan(); line++ {
+			if line%2 == 0 {
+				continue
+			}
+			var doc document
+			if err := json.Unmarshal(scanner.Bytes(), &doc); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.docs = append(f.docs, doc)
+		}
+		w.Write([]byte(`{"errors":false}`))
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		json.NewDecoder(r.Body).Decode(&f.query)
+		hits := make([]map[string]interface{}, len(f.hits))
+		for i, doc := range f.hits {
+			hits[i] = map[string]interface{}{"_source": doc}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeOpenSearch) indexed() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	for _, doc := range f.docs {
+		ids = append(ids, doc.ID+" "+doc.Status)
+	}
+	return ids
+}
+
+func newFake(t *testing.T) (*fakeOpenSearch, *Client) {
+	t.Helper()
+	fake := &fakeOpenSearch{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, NewClient(server.URL+"/", "orders")
+}
+
+func TestIndexer(t *testing.T) {
+	t.Run("indexes the orders the bus reports", func(t *testing.T) {
+		fake, client := newFake(t)
+		indexer := NewIndexer(client, 10)
+		bus := events.NewBus()
+		indexer.Subscribe(bus)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			indexer.Run(ctx)
+			close(done)
+		}()
+
+		bus.Emit(ctx, events.OrderCreated, &repository.Order{ID: "o1", Status: repository.StatusPending})
+		bus.Emit(ctx, events.OrderUpdated, &repository.Order{ID: "o1", Status: repository.StatusPaid})
+		bus.Emit(ctx, events.OrderActivated, &repository.Order{ID: "o2", Status: repository.StatusPending})
+		bus.Emit(ctx, events.OrderPlaced, &repository.Order{ID: "o3", Status: repository.StatusPending})
+		cancel()
+		<-done
+
+		got := fake.indexed()
+		want := []string{"o1 PENDING", "o1 PAID", "o2 PENDING"}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %v indexed, got %v", want, got)
+		}
+	})
+
+	t.Run("drops orders when the queue is full", func(t *testing.T) {
+		_, client := newFake(t)
+		indexer := NewIndexer(client, 1)
+
+		indexed := make(chan struct{})
+		go func() {
+			indexer.Index(repository.Order{ID: "o1"})
+			indexer.Index(repository.Order{ID: "o2"})
+			close(indexed)
+		}()
+		select {
+		case <-indexed:
+		case <-time.After(time.Second):
+			t.Fatal("Expected Index not to block on a full queue")
+		}
+		if len(indexer.queue) != 1 {
+			t.Errorf("Expected one queued order, got %d", len(indexer.queue))
+		}
+	})
+}
+
+func TestRepositorySearch(t *testing.T) {
+	fake, client := newFake(t)
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	fake.hits = []document{{ID: "o1", ProductID: "p1", Status: repository.StatusPaid, Quantity: 2, CreatedAt: created}}
+
+	orders, err := NewRepository(client).Search(context.Background(), repository.OrderFilter{
+		ProductID:   "p1",
+		Status:      repository.StatusPaid,
+		CreatedFrom: created.Add(-time.Hour),
+	}, 20, 40)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(orders) != 1 || orders[0].ID != "o1" || orders[0].Quantity != 2 || !orders[0].CreatedAt.Equal(created) {
+		t.Errorf("Expected the hit as an order, got %+v", orders)
+	}
+
+	query, _ := json.Marshal(fake.query)
+	for _, want := range []string{
+		`"from":40`,
+		`"size":20`,
+		`{"term":{"product_id":"p1"}}`,
+		`{"term":{"status":"PAID"}}`,
+		`{"range":{"created_at":{"gte":"2026-10-01T11:00:00Z"}}}`,
+	} {
+		if !strings.Contains(string(query), want) {
+			t.Errorf("Expected the query to contain %s, got %s", want, query)
+		}
+	}
+	if strings.Contains(string(query), "customer_id") {
+		t.Errorf("Expected no filter on unset fields, got %s", query)
+	}
+}
+
+type stubSearcher struct {
+	orders []repository.Order
+	err    error
+	calls  int
+}
+
+func (s *stubSearcher) Search(context.Context, repository.OrderFilter, int, int) ([]repository.Order, error) {
+	s.calls++
+	return s.orders, s.err
+}
+
+func TestFallbackSearcher(t *testing.T) {
+	t.Run("uses the primary", func(t *testing.T) {
+		primary := &stubSearcher{orders: []repository.Order{{ID: "o1"}}}
+		secondary := &stubSearcher{}
+		orders, err := NewFallbackSearcher(primary, secondary).Search(context.Background(), repository.OrderFilter{}, 10, 0)
+		if err != nil || len(orders) != 1 || secondary.calls != 0 {
+			t.Errorf("Expected the primary's orders only, got %v, %v, %d secondary calls", orders, err, secondary.calls)
+		}
+	})
+
+	t.Run("falls back when OpenSearch fails", func(t *testing.T) {
+		fake, client := newFake(t)
+		fake.fail = true
+		secondary := &stubSearcher{orders: []repository.Order{{ID: "o2"}}}
+		orders, err := NewFallbackSearcher(NewRepository(client), secondary).Search(context.Background(), repository.OrderFilter{}, 10, 0)
+		if err != nil || len(orders) != 1 || orders[0].ID != "o2" {
+			t.Errorf("Expected the secondary's orders, got %v, %v", orders, err)
+		}
+	})
+
+	t.Run("returns the secondary's error", func(t *testing.T) {
+		primary := &stubSearcher{err: errors.New("down")}
+		secondary := &stubSearcher{err: errors.New("also down")}
+		if _, err := NewFallbackSearcher(primary, secondary).Search(context.Background(), repository.OrderFilter{}, 10, 0); err == nil || err.Error() != "also down" {
+			t.Errorf("Expected the secondary's error, got %v", err)
+		}
+	})
+}
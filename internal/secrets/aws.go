@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// AWSProvider reads secrets from AWS Secrets Manager. The secret string
+// must be a JSON object. Credentials and region come from the default AWS
+// configuration chain.
+type AWSProvider struct {
+	cfg        aws.Config
+	endpoint   string
+	signer     *v4.Signer
+	httpClient *http.Client
+}
+
+var _ Provider = &AWSProvider{}
+
+// NewAWSProvider uses endpoint instead of the regional one if set, e.g.
+// for a VPC endpoint or LocalStack.
+func NewAWSProvider(ctx context.Context, endpoint string) (*AWSProvider, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", cfg.Region)
+	}
+	return &AWSProvider{
+		cfg:        cfg,
+		endpoint:   endpoint,
+		signer:     v4.NewSigner(),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+func (p *AWSProvider) Get(ctx context.Context, name string) (map[string]string, error) {
+	payload, err := json.Marshal(map[string]string{"SecretId": name})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	creds, err := p.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	hash := sha256.Sum256(payload)
+	if err := p.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "secretsmanager", p.cfg.Region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Secrets Manager: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Type == "ResourceNotFoundException" {
+			return nil, fmt.Errorf("%w: %s", ErrSecretNotFound, name)
+		}
+		return nil, fmt.Errorf("secrets manager returned status %s: %s %s", resp.Status, apiErr.Type, apiErr.Message)
+	}
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode Secrets Manager response: %w", err)
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(body.SecretString), &value); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object: %w", name, err)
+	}
+	return stringValues(value), nil
+}
@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"time"
+)
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Provider reads a secret as key/value pairs, e.g. {"username": ...,
+// "password": ...}.
+type Provider interface {
+	Get(ctx context.Context, name string) (map[string]string, error)
+}
+
+// stringValues flattens a decoded JSON object, e.g. the numeric port of an
+// RDS secret, to strings.
+func stringValues(m map[string]interface{}) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if s, ok := v.(string); ok {
+			out[k] = s
+		} else {
+			out[k] = fmt.Sprint(v)
+		}
+	}
+	return out
+}
+
+// Watch re-reads the secret every interval and calls onChange with the new
+// value whenever it differs from last, until ctx is cancelled. Read
+// failures are logged and the previous value stays in use.
+func Watch(ctx context.Context, p Provider, name string, interval time.Duration, last map[string]string, onChange func(map[string]string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		value, err := p.Get(ctx, name)
+		if err != nil {
+			log.Printf("Failed to refresh secret %s: %v", name, err)
+			continue
+		}
+		if maps.Equal(value, last) {
+			continue
+		}
+		log.Printf("Secret %s was rotated", name)
+		last = value
+		onChange(value)
+	}
+}
@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/order-service/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"username": "app", "password": "s3cret", "port": 5432}}}`))
+	}))
+	defer server.Close()
+
+	p := NewVaultProvider(server.URL, "token", "secret")
+	secret, err := p.Get(context.Background(), "order-service/db")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if secret["username"] != "app" || secret["password"] != "s3cret" || secret["port"] != "5432" {
+		t.Errorf("Unexpected secret %v", secret)
+	}
+
+	if _, err := p.Get(context.Background(), "missing"); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("Expected %v, got %v", ErrSecretNotFound, err)
+	}
+}
+
+func TestAWSProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "ap-southeast-1")
+	t.Setenv("AWS_CONFIG_FILE", "/nonexistent")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/nonexistent")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.Contains(r.Header.Get("Authorization"), "ap-southeast-1/secretsmanager") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"Name": "db", "SecretString": "{\"username\":\"app\",\"password\":\"s3cret\"}"}`))
+	}))
+	defer server.Close()
+
+	p, err := NewAWSProvider(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	secret, err := p.Get(context.Background(), "db")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if secret["username"] != "app" || secret["password"] != "s3cret" {
+		t.Errorf("Unexpected secret %v", secret)
+	}
+}
+
+type rotatingProvider struct {
+	mu       sync.Mutex
+	password string
+}
+
+func (p *rotatingProvider) Get(ctx context.Context, name string) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return map[string]string{"password": p.password}, nil
+}
+
+func TestWatch(t *testing.T) {
+	p := &rotatingProvider{password: "old"}
+	changes := make(chan map[string]string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, p, "db", time.Millisecond, map[string]string{"password": "old"}, func(v map[string]string) {
+		changes <- v
+	})
+
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case v := <-changes:
+		t.Fatalf("Expected no change before rotation, got %v", v)
+	default:
+	}
+
+	p.mu.Lock()
+	p.password = "new"
+	p.mu.Unlock()
+	select {
+	case v := <-changes:
+		if v["password"] != "new" {
+			t.Errorf("Expected the new password, got %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a change after rotation")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if len(changes) != 0 {
+		t.Errorf("Expected a single change, got %d more", len(changes))
+	}
+}
@@ -0,0 +1,203 @@
+// Package seed generates demo orders for dashboards, reports and load
+// tests. The orders are priced like real ones and spread over customers,
+// regions, days and statuses, but they are written straight to the
+// database: no events are published and no other service is called.
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"order-service/internal/domain"
+	"order-service/internal/productstub"
+	"order-service/internal/repository"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CustomerPrefix starts the ID of every seeded customer, so seeded orders
+// are easy to find and remove.
+const CustomerPrefix = "seed-customer-"
+
+var ErrInvalidConfig = errors.New("invalid seed configuration")
+
+// Config describes the orders to generate.
+type Config struct {
+	Orders    int
+	Customers int
+	// Days is how far back orders are created.
+	Days     int
+	Products []productstub.Product
+	TenantID string
+	// ShippingFee, TaxRate and DiscountCodes price the orders; a tenth of
+	// the orders use one of the discount codes.
+	ShippingFee   float64
+	TaxRate       float64
+	DiscountCodes map[string]domain.Discount
+	// Seed makes the generated orders reproducible, apart from their IDs.
+	Seed int64
+}
+
+func (c Config) validate() error {
+	if c.Orders <= 0 || c.Customers <= 0 || c.Days <= 0 {
+		return fmt.Errorf("%w: orders, customers and days must be positive", ErrInvalidConfig)
+	}
+	if len(c.Products) == 0 {
+		return fmt.Errorf("%w: no products", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// statusWeights is roughly the status mix of a production day.
+var statusWeights = []struct {
+	status string
+	weight int
+}{
+	{repository.StatusPaid, 45},
+	{repository.StatusPending, 25},
+	{repository.StatusPaymentExpired, 6},
+	{repository.StatusAwaitingPayment, 5},
+	{repository.StatusBackordered, 5},
+	{repository.StatusRejected, 4},
+	{repository.StatusOnHold, 4},
+	{repository.StatusScheduled, 3},
+	{repository.StatusCancelled, 3},
+}
+
+var (
+	regions     = []string{"jakarta", "bandung", "surabaya", "medan", "makassar", "denpasar"}
+	holdReasons = []string{"velocity check", "billing and shipping country differ", "high value first order"}
+)
+
+// Generate returns cfg.Orders orders created before now, oldest first.
+func Generate(cfg Config, now time.Time) ([]repository.Order, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	codes := make([]string, 0, len(cfg.DiscountCodes))
+	for code := range cfg.DiscountCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	pipeline := domain.NewPipeline(domain.PricingRules{
+		ShippingFee:   cfg.ShippingFee,
+		TaxRate:       cfg.TaxRate,
+		DiscountCodes: cfg.DiscountCodes,
+	})
+
+	window := time.Duration(cfg.Days) * 24 * time.Hour
+	orders := make([]repository.Order, 0, cfg.Orders)
+	for i := 0; i < cfg.Orders; i++ {
+		product := cfg.Products[rng.Intn(len(cfg.Products))]
+		calc := &domain.Calculation{
+			TenantID:   cfg.TenantID,
+			CustomerID: fmt.Sprintf("%s%04d", CustomerPrefix, rng.Intn(cfg.Customers)+1),
+			ProductID:  product.ID,
+			Quantity:   quantity(rng),
+			UnitPrice:  product.Price,
+			Round:      func(v float64) float64 { return math.Round(v*100) / 100 },
+		}
+		if len(codes) > 0 && rng.Intn(10) == 0 {
+			calc.DiscountCode = codes[rng.Intn(len(codes))]
+		}
+		if err := pipeline.Price(calc); err != nil {
+			return nil, err
+		}
+
+		createdAt := now.Add(-time.Duration(rng.Int63n(int64(window)))).UTC()
+		order := repository.Order{
+			ID:             uuid.New().String(),
+			ProductID:      product.ID,
+			CustomerID:     calc.CustomerID,
+			TenantID:       cfg.TenantID,
+			Quantity:       calc.Quantity,
+			Region:         regions[rng.Intn(len(regions))],
+			Currency:       product.Currency,
+			Subtotal:       calc.Subtotal,
+			DiscountCode:   calc.DiscountCode,
+			DiscountAmount: calc.Discount,
+			TaxAmount:      calc.Tax,
+			ShippingFee:    calc.ShippingFee,
+			TotalPrice:     calc.Total,
+			CreatedAt:      createdAt,
+		}
+		setStatus(&order, pick(rng), now, rng)
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].CreatedAt.Before(orders[j].CreatedAt) })
+	return orders, nil
+}
+
+// quantity is mostly one or two units, sometimes a bulk order.
+func quantity(rng *rand.Rand) int {
+	switch n := rng.Intn(100); {
+	case n < 60:
+		return 1
+	case n < 85:
+		return 2
+	case n < 97:
+		return 3 + rng.Intn(3)
+	default:
+		return 10 + rng.Intn(20)
+	}
+}
+
+func pick(rng *rand.Rand) string {
+	total := 0
+	for _, w := range statusWeights {
+		total += w.weight
+	}
+	n := rng.Intn(total)
+	for _, w := range statusWeights {
+		if n < w.weight {
+			return w.status
+		}
+		n -= w.weight
+	}
+	return repository.StatusPending
+}
+
+// setStatus moves an order to status with the fields that status implies.
+// Orders that only stay in a status briefly are made recent.
+func setStatus(o *repository.Order, status string, now time.Time, rng *rand.Rand) {
+	o.Status = status
+	changed := o.CreatedAt.Add(time.Duration(rng.Int63n(int64(2 * time.Hour))))
+	switch status {
+	case repository.StatusPaid:
+		o.PaidAmount = o.TotalPrice
+	case repository.StatusAwaitingPayment:
+		o.CreatedAt = now.Add(-time.Duration(rng.Int63n(int64(10 * time.Minute)))).UTC()
+		o.PaymentExpiresAt = o.CreatedAt.Add(30 * time.Minute)
+		changed = o.CreatedAt
+	case repository.StatusPaymentExpired:
+		o.PaymentExpiresAt = o.CreatedAt.Add(30 * time.Minute)
+		changed = o.PaymentExpiresAt
+	case repository.StatusOnHold, repository.StatusRejected:
+		o.HoldReason = holdReasons[rng.Intn(len(holdReasons))]
+	case repository.StatusScheduled:
+		processAt := now.Add(time.Duration(1+rng.Intn(7*24)) * time.Hour).UTC()
+		o.ProcessAt = &processAt
+		changed = o.CreatedAt
+	case repository.StatusCancelled:
+		processAt := o.CreatedAt.Add(time.Duration(1+rng.Intn(7*24)) * time.Hour)
+		o.ProcessAt = &processAt
+	}
+	if changed.After(now) {
+		changed = now
+	}
+	changed = changed.UTC()
+	o.StatusChangedAt = &changed
+}
+
+// Count returns how many orders have each status.
+func Count(orders []repository.Order) map[string]int {
+	counts := map[string]int{}
+	for _, o := range orders {
+		counts[o.Status]++
+	}
+	return counts
+}
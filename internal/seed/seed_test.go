@@ -0,0 +1,102 @@
+package seed
+
+import (
+	"errors"
+	"math"
+	"order-service/internal/domain"
+	"order-service/internal/productstub"
+	"order-service/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cfg := Config{
+		Orders:    500,
+		Customers: 20,
+		Days:      14,
+		Products: []productstub.Product{
+			{ID: "keyboard", Price: 750000, Qty: 40},
+			{ID: "coffee", Price: 180000, Qty: 200},
+		},
+		TaxRate:       0.11,
+		ShippingFee:   15000,
+		DiscountCodes: map[string]domain.Discount{"HEMAT10": {Percent: 10}},
+		Seed:          42,
+	}
+
+	orders, err := Generate(cfg, now)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(orders) != cfg.Orders {
+		t.Fatalf("Expected %d orders, got %d", cfg.Orders, len(orders))
+	}
+
+	discounted := 0
+	for i, o := range orders {
+		if i > 0 && o.CreatedAt.Before(orders[i-1].CreatedAt) {
+			t.Fatalf("Expected orders oldest first")
+		}
+		if o.CreatedAt.After(now) || o.CreatedAt.Before(now.Add(-14*24*time.Hour)) {
+			t.Errorf("Order created outside the window at %v", o.CreatedAt)
+		}
+		if !strings.HasPrefix(o.CustomerID, CustomerPrefix) {
+			t.Errorf("Unexpected customer %s", o.CustomerID)
+		}
+		total := o.Subtotal - o.DiscountAmount + o.TaxAmount + o.ShippingFee
+		if math.Abs(total-o.TotalPrice) > 0.01 || o.TotalPrice <= 0 {
+			t.Errorf("Inconsistent pricing %+v", o)
+		}
+		if o.DiscountCode != "" {
+			discounted++
+		}
+		switch o.Status {
+		case repository.StatusPaid:
+			if o.PaidAmount != o.TotalPrice {
+				t.Errorf("Expected a paid order to be fully paid, got %+v", o)
+			}
+		case repository.StatusAwaitingPayment:
+			if !o.PaymentExpiresAt.After(now) {
+				t.Errorf("Expected an order awaiting payment to expire in the future, got %v", o.PaymentExpiresAt)
+			}
+		case repository.StatusScheduled:
+			if o.ProcessAt == nil || !o.ProcessAt.After(now) {
+				t.Errorf("Expected a scheduled order to be processed in the future, got %v", o.ProcessAt)
+			}
+		case repository.StatusOnHold, repository.StatusRejected:
+			if o.HoldReason == "" {
+				t.Errorf("Expected a hold reason for %s", o.Status)
+			}
+		}
+		if o.StatusChangedAt == nil || o.StatusChangedAt.After(now) {
+			t.Errorf("Unexpected status change time %v", o.StatusChangedAt)
+		}
+	}
+	if discounted == 0 {
+		t.Error("Expected some orders to use a discount code")
+	}
+
+	counts := Count(orders)
+	for _, w := range statusWeights {
+		if counts[w.status] == 0 {
+			t.Errorf("Expected some %s orders, got %v", w.status, counts)
+		}
+	}
+
+	again, _ := Generate(cfg, now)
+	for i := range orders {
+		if again[i].Status != orders[i].Status || again[i].TotalPrice != orders[i].TotalPrice || !again[i].CreatedAt.Equal(orders[i].CreatedAt) {
+			t.Fatalf("Expected the same seed to generate the same orders")
+		}
+	}
+}
+
+func TestGenerateRejectsInvalidConfig(t *testing.T) {
+	_, err := Generate(Config{Orders: 10, Customers: 1, Days: 1}, time.Now())
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig without products, got %v", err)
+	}
+}
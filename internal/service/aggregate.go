@@ -0,0 +1,59 @@
+package service
+
+import (
+	"order-service/internal/domain"
+	"order-service/internal/repository"
+)
+
+// aggregate returns the domain view of a stored order.
+func aggregate(o *repository.Order) *domain.Order {
+	agg := &domain.Order{
+		ID:         o.ID,
+		ProductID:  o.ProductID,
+		CustomerID: o.CustomerID,
+		Quantity:   o.Quantity,
+		Status:     o.Status,
+		HoldReason: o.HoldReason,
+		Pricing: domain.Pricing{
+			Subtotal:    o.Subtotal,
+			Discount:    o.DiscountAmount,
+			Tax:         o.TaxAmount,
+			ShippingFee: o.ShippingFee,
+			Total:       o.TotalPrice,
+		},
+		PaymentExpiresAt: o.PaymentExpiresAt,
+	}
+	if o.ProcessAt != nil {
+		agg.ProcessAt = *o.ProcessAt
+	}
+	if o.ReservedUntil != nil {
+		agg.ReservedUntil = *o.ReservedUntil
+	}
+	return agg
+}
+
+// mutate runs a domain operation on order and copies the outcome back. The
+// order is left as it was when the operation refuses.
+func mutate(order *repository.Order, op func(*domain.Order) error) error {
+	agg := aggregate(order)
+	if err := op(agg); err != nil {
+		return err
+	}
+	order.Status = agg.Status
+	order.HoldReason = agg.HoldReason
+	order.Subtotal = agg.Pricing.Subtotal
+	order.DiscountAmount = agg.Pricing.Discount
+	order.TaxAmount = agg.Pricing.Tax
+	order.ShippingFee = agg.Pricing.ShippingFee
+	order.TotalPrice = agg.Pricing.Total
+	order.PaymentExpiresAt = agg.PaymentExpiresAt
+	if !agg.ProcessAt.IsZero() {
+		processAt := agg.ProcessAt
+		order.ProcessAt = &processAt
+	}
+	if !agg.ReservedUntil.IsZero() {
+		reservedUntil := agg.ReservedUntil
+		order.ReservedUntil = &reservedUntil
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"order-service/internal/repository"
+	"sync"
+	"time"
+)
+
+// Event is a message as handed to a publisher.
+type Event struct {
+	Pattern string
+	Data    interface{}
+	// ID is the event's EventID, when already known.
+	ID string
+}
+
+// IBatchPublisher sends several events at once, in order.
+type IBatchPublisher interface {
+	PublishBatch(events []Event) error
+}
+
+// BatchingPublisher collects events and sends them through next once size
+// events are pending or linger has passed since the first of them, which
+// raises throughput during bulk imports. Events whose pattern is listed as
+// synchronous flush the batch and only return once it was sent. Batches
+// that fail to send are spooled to the outbox.
+type BatchingPublisher struct {
+	next   IBatchPublisher
+	outbox IOutbox
+	size   int
+	linger time.Duration
+	sync   map[string]bool
+
+	// sendMu is held from taking a batch until it is sent, so batches go
+	// out in order. It is taken before mu.
+	sendMu  sync.Mutex
+	mu      sync.Mutex
+	pending []Event
+	timer   *time.Timer
+}
+
+var _ IPublisher = &BatchingPublisher{}
+
+func NewBatchingPublisher(next IBatchPublisher, outbox IOutbox, size int, linger time.Duration, syncPatterns []string) *BatchingPublisher {
+	p := &BatchingPublisher{next: next, outbox: outbox, size: size, linger: linger, sync: make(map[string]bool)}
+	for _, pattern := range syncPatterns {
+		p.sync[pattern] = true
+	}
+	return p
+}
+
+func (p *BatchingPublisher) PublishOrderCreated(order *repository.Order) error {
+	return p.Publish("order.created", orderCreatedData(order))
+}
+
+// Publish adds the event to the batch. It only fails when a synchronous
+// event could neither be sent nor spooled.
+func (p *BatchingPublisher) Publish(pattern string, data interface{}) error {
+	p.mu.Lock()
+	p.pending = append(p.pending, Event{Pattern: pattern, Data: data})
+	full := len(p.pending) >= p.size
+	if !full && !p.sync[pattern] && p.timer == nil {
+		p.timer = time.AfterFunc(p.linger, p.Flush)
+	}
+	p.mu.Unlock()
+
+	if p.sync[pattern] {
+		return p.flush()
+	}
+	if full {
+		p.flush()
+	}
+	return nil
+}
+
+// Flush sends the pending events now.
+func (p *BatchingPublisher) Flush() {
+	p.flush()
+}
+
+func (p *BatchingPublisher) flush() error {
+	p.sendMu.Lock()
+	defer p.sendMu.Unlock()
+
+	p.mu.Lock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	batch := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	err := p.next.PublishBatch(batch)
+	if err == nil {
+		return nil
+	}
+	log.Printf("Failed to publish batch of %d events, spooling to outbox: %v", len(batch), err)
+	var errs []error
+	for _, e := range batch {
+		if err := p.outbox.Add(e.Pattern, e.Data); err != nil {
+			log.Printf("Failed to spool %s event to outbox, event lost: %v", e.Pattern, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
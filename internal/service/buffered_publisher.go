@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"log"
+	"order-service/internal/metrics"
+	"order-service/internal/repository"
+	"sync"
+)
+
+// BufferedPublisher queues events in a bounded buffer that a single Run
+// goroutine publishes through next, so a slow broker does not hold up
+// requests. When the buffer is full events go straight to the outbox.
+// Until Run is running, and after it returns, events are published
+// synchronously.
+type BufferedPublisher struct {
+	next   IPublisher
+	outbox IOutbox
+	queue  chan Event
+
+	mu      sync.RWMutex
+	running bool
+}
+
+var _ IPublisher = &BufferedPublisher{}
+
+func NewBufferedPublisher(next IPublisher, outbox IOutbox, capacity int) *BufferedPublisher {
+	return &BufferedPublisher{next: next, outbox: outbox, queue: make(chan Event, capacity)}
+}
+
+func (p *BufferedPublisher) PublishOrderCreated(order *repository.Order) error {
+	return p.Publish("order.created", orderCreatedData(order))
+}
+
+// Publish queues the event. It only fails when the event could neither be
+// queued nor spooled to the outbox.
+func (p *BufferedPublisher) Publish(pattern string, data interface{}) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.running {
+		return p.next.Publish(pattern, data)
+	}
+	select {
+	case p.queue <- Event{Pattern: pattern, Data: data}:
+		metrics.EventQueueDepth.Set(float64(len(p.queue)))
+		return nil
+	default:
+		metrics.EventQueueOverflows.Inc()
+		log.Printf("Event buffer full, spooling %s event to outbox", pattern)
+		return p.outbox.Add(pattern, data)
+	}
+}
+
+// Run publishes queued events until ctx is done, then moves what is left
+// in the buffer to the outbox.
+func (p *BufferedPublisher) Run(ctx context.Context) {
+	p.mu.Lock()
+	p.running = true
+	p.mu.Unlock()
+
+	for {
+		select {
+		case e := <-p.queue:
+			metrics.EventQueueDepth.Set(float64(len(p.queue)))
+			if err := p.next.Publish(e.Pattern, e.Data); err != nil {
+				log.Printf("Failed to publish %s event: %v", e.Pattern, err)
+			}
+		case <-ctx.Done():
+			p.stop()
+			return
+		}
+	}
+}
+
+func (p *BufferedPublisher) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = false
+	for {
+		select {
+		case e := <-p.queue:
+			if err := p.outbox.Add(e.Pattern, e.Data); err != nil {
+				log.Printf("Failed to spool %s event to outbox, event lost: %v", e.Pattern, err)
+			}
+		default:
+			metrics.EventQueueDepth.Set(0)
+			return
+		}
+	}
+}
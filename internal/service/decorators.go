@@ -0,0 +1,374 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"order-service/internal/audit"
+	"order-service/internal/logging"
+	"order-service/internal/metrics"
+	"order-service/internal/repository"
+	"slices"
+	"time"
+)
+
+// IOrderService is what the HTTP handlers and consumers need from the
+// order service. *OrderService implements it; Decorate wraps it with
+// cross-cutting concerns.
+type IOrderService interface {
+	CreateOrder(ctx context.Context, req CreateOrderRequest) (*repository.Order, error)
+	CreateOrders(ctx context.Context, reqs []CreateOrderRequest) ([]repository.Order, error)
+	QuoteOrder(ctx context.Context, req CreateOrderRequest) (*Quote, error)
+	ExplainOrder(ctx context.Context, req CreateOrderRequest) (*OrderExplanation, error)
+	CheckoutCart(ctx context.Context, req CheckoutCartRequest) (*CheckoutResult, error)
+	ReorderOrder(ctx context.Context, id string, req ReorderRequest) (*repository.Order, error)
+	ConfirmOrder(ctx context.Context, id string) (*repository.Order, error)
+	RescheduleOrder(ctx context.Context, id string, req ScheduleRequest) (*repository.Order, error)
+	CancelOrder(ctx context.Context, id string) (*repository.Order, error)
+	GetOrder(ctx context.Context, id string) (*OrderDetail, error)
+	GetOrderSnapshot(ctx context.Context, id string) (*OrderSnapshot, error)
+	GetOrdersByProductID(ctx context.Context, productID string) ([]repository.Order, error)
+	CountOrdersByProduct(ctx context.Context, productID, status string) (int64, error)
+	OrderExists(ctx context.Context, id string) (bool, error)
+	SearchOrders(ctx context.Context, filter repository.OrderFilter, limit, offset int) ([]repository.Order, error)
+	StreamOrders(ctx context.Context, filter repository.OrderFilter, fn func(repository.Order) error) error
+	GetRevisions(ctx context.Context, id string) ([]repository.OrderRevision, error)
+	GetRevisionDiff(ctx context.Context, id string, n int) (*RevisionDiff, error)
+	ApproveOrder(ctx context.Context, id string) (*repository.Order, error)
+	RejectOrder(ctx context.Context, id string) (*repository.Order, error)
+	ForceOrderStatus(ctx context.Context, id string, req ForceStatusRequest) (*repository.Order, error)
+	AddOrderTags(ctx context.Context, id string, tags []string) (*repository.Order, error)
+	RemoveOrderTag(ctx context.Context, id, tag string) (*repository.Order, error)
+	ReplayEvent(ctx context.Context, id string) (string, error)
+	ConfirmBackorders(ctx context.Context, productID string) (int, error)
+	RecordInstallmentPayment(ctx context.Context, orderID string, sequence int, paymentID string) error
+}
+
+var _ IOrderService = &OrderService{}
+
+// Interceptor runs around every IOrderService call. op is the method name;
+// call runs the rest of the chain and must be called with the context the
+// service should see.
+type Interceptor func(ctx context.Context, op string, call func(ctx context.Context) error) error
+
+// Decorate wraps next in interceptors; the first one runs outermost.
+func Decorate(next IOrderService, interceptors ...Interceptor) IOrderService {
+	if len(interceptors) == 0 {
+		return next
+	}
+	return &decoratedService{next: next, interceptors: interceptors}
+}
+
+type decoratedService struct {
+	next         IOrderService
+	interceptors []Interceptor
+}
+
+func (d *decoratedService) run(ctx context.Context, op string, call func(ctx context.Context) error) error {
+	for i := len(d.interceptors) - 1; i >= 0; i-- {
+		intercept, inner := d.interceptors[i], call
+		call = func(ctx context.Context) error { return intercept(ctx, op, inner) }
+	}
+	return call(ctx)
+}
+
+func (d *decoratedService) CreateOrder(ctx context.Context, req CreateOrderRequest) (order *repository.Order, err error) {
+	err = d.run(ctx, "CreateOrder", func(ctx context.Context) (err error) {
+		order, err = d.next.CreateOrder(ctx, req)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) CreateOrders(ctx context.Context, reqs []CreateOrderRequest) (orders []repository.Order, err error) {
+	err = d.run(ctx, "CreateOrders", func(ctx context.Context) (err error) {
+		orders, err = d.next.CreateOrders(ctx, reqs)
+		return err
+	})
+	return orders, err
+}
+
+func (d *decoratedService) QuoteOrder(ctx context.Context, req CreateOrderRequest) (quote *Quote, err error) {
+	err = d.run(ctx, "QuoteOrder", func(ctx context.Context) (err error) {
+		quote, err = d.next.QuoteOrder(ctx, req)
+		return err
+	})
+	return quote, err
+}
+
+func (d *decoratedService) ExplainOrder(ctx context.Context, req CreateOrderRequest) (explanation *OrderExplanation, err error) {
+	err = d.run(ctx, "ExplainOrder", func(ctx context.Context) (err error) {
+		explanation, err = d.next.ExplainOrder(ctx, req)
+		return err
+	})
+	return explanation, err
+}
+
+func (d *decoratedService) CheckoutCart(ctx context.Context, req CheckoutCartRequest) (result *CheckoutResult, err error) {
+	err = d.run(ctx, "CheckoutCart", func(ctx context.Context) (err error) {
+		result, err = d.next.CheckoutCart(ctx, req)
+		return err
+	})
+	return result, err
+}
+
+func (d *decoratedService) ConfirmOrder(ctx context.Context, id string) (order *repository.Order, err error) {
+	err = d.run(ctx, "ConfirmOrder", func(ctx context.Context) (err error) {
+		order, err = d.next.ConfirmOrder(ctx, id)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) GetOrder(ctx context.Context, id string) (detail *OrderDetail, err error) {
+	err = d.run(ctx, "GetOrder", func(ctx context.Context) (err error) {
+		detail, err = d.next.GetOrder(ctx, id)
+		return err
+	})
+	return detail, err
+}
+
+func (d *decoratedService) GetOrderSnapshot(ctx context.Context, id string) (snapshot *OrderSnapshot, err error) {
+	err = d.run(ctx, "GetOrderSnapshot", func(ctx context.Context) (err error) {
+		snapshot, err = d.next.GetOrderSnapshot(ctx, id)
+		return err
+	})
+	return snapshot, err
+}
+
+func (d *decoratedService) GetOrdersByProductID(ctx context.Context, productID string) (orders []repository.Order, err error) {
+	err = d.run(ctx, "GetOrdersByProductID", func(ctx context.Context) (err error) {
+		orders, err = d.next.GetOrdersByProductID(ctx, productID)
+		return err
+	})
+	return orders, err
+}
+
+func (d *decoratedService) CountOrdersByProduct(ctx context.Context, productID, status string) (count int64, err error) {
+	err = d.run(ctx, "CountOrdersByProduct", func(ctx context.Context) (err error) {
+		count, err = d.next.CountOrdersByProduct(ctx, productID, status)
+		return err
+	})
+	return count, err
+}
+
+func (d *decoratedService) OrderExists(ctx context.Context, id string) (exists bool, err error) {
+	err = d.run(ctx, "OrderExists", func(ctx context.Context) (err error) {
+		exists, err = d.next.OrderExists(ctx, id)
+		return err
+	})
+	return exists, err
+}
+
+func (d *decoratedService) SearchOrders(ctx context.Context, filter repository.OrderFilter, limit, offset int) (orders []repository.Order, err error) {
+	err = d.run(ctx, "SearchOrders", func(ctx context.Context) (err error) {
+		orders, err = d.next.SearchOrders(ctx, filter, limit, offset)
+		return err
+	})
+	return orders, err
+}
+
+func (d *decoratedService) StreamOrders(ctx context.Context, filter repository.OrderFilter, fn func(repository.Order) error) error {
+	return d.run(ctx, "StreamOrders", func(ctx context.Context) error {
+		return d.next.StreamOrders(ctx, filter, fn)
+	})
+}
+
+func (d *decoratedService) GetRevisions(ctx context.Context, id string) (revisions []repository.OrderRevision, err error) {
+	err = d.run(ctx, "GetRevisions", func(ctx context.Context) (err error) {
+		revisions, err = d.next.GetRevisions(ctx, id)
+		return err
+	})
+	return revisions, err
+}
+
+func (d *decoratedService) GetRevisionDiff(ctx context.Context, id string, n int) (diff *RevisionDiff, err error) {
+	err = d.run(ctx, "GetRevisionDiff", func(ctx context.Context) (err error) {
+		diff, err = d.next.GetRevisionDiff(ctx, id, n)
+		return err
+	})
+	return diff, err
+}
+
+func (d *decoratedService) ReorderOrder(ctx context.Context, id string, req ReorderRequest) (order *repository.Order, err error) {
+	err = d.run(ctx, "ReorderOrder", func(ctx context.Context) (err error) {
+		order, err = d.next.ReorderOrder(ctx, id, req)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) RescheduleOrder(ctx context.Context, id string, req ScheduleRequest) (order *repository.Order, err error) {
+	err = d.run(ctx, "RescheduleOrder", func(ctx context.Context) (err error) {
+		order, err = d.next.RescheduleOrder(ctx, id, req)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) CancelOrder(ctx context.Context, id string) (order *repository.Order, err error) {
+	err = d.run(ctx, "CancelOrder", func(ctx context.Context) (err error) {
+		order, err = d.next.CancelOrder(ctx, id)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) ApproveOrder(ctx context.Context, id string) (order *repository.Order, err error) {
+	err = d.run(ctx, "ApproveOrder", func(ctx context.Context) (err error) {
+		order, err = d.next.ApproveOrder(ctx, id)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) RejectOrder(ctx context.Context, id string) (order *repository.Order, err error) {
+	err = d.run(ctx, "RejectOrder", func(ctx context.Context) (err error) {
+		order, err = d.next.RejectOrder(ctx, id)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) ForceOrderStatus(ctx context.Context, id string, req ForceStatusRequest) (order *repository.Order, err error) {
+	err = d.run(ctx, "ForceOrderStatus", func(ctx context.Context) (err error) {
+		order, err = d.next.ForceOrderStatus(ctx, id, req)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) AddOrderTags(ctx context.Context, id string, tags []string) (order *repository.Order, err error) {
+	err = d.run(ctx, "AddOrderTags", func(ctx context.Context) (err error) {
+		order, err = d.next.AddOrderTags(ctx, id, tags)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) RemoveOrderTag(ctx context.Context, id, tag string) (order *repository.Order, err error) {
+	err = d.run(ctx, "RemoveOrderTag", func(ctx context.Context) (err error) {
+		order, err = d.next.RemoveOrderTag(ctx, id, tag)
+		return err
+	})
+	return order, err
+}
+
+func (d *decoratedService) ReplayEvent(ctx context.Context, id string) (pattern string, err error) {
+	err = d.run(ctx, "ReplayEvent", func(ctx context.Context) (err error) {
+		pattern, err = d.next.ReplayEvent(ctx, id)
+		return err
+	})
+	return pattern, err
+}
+
+func (d *decoratedService) ConfirmBackorders(ctx context.Context, productID string) (confirmed int, err error) {
+	err = d.run(ctx, "ConfirmBackorders", func(ctx context.Context) (err error) {
+		confirmed, err = d.next.ConfirmBackorders(ctx, productID)
+		return err
+	})
+	return confirmed, err
+}
+
+func (d *decoratedService) RecordInstallmentPayment(ctx context.Context, orderID string, sequence int, paymentID string) error {
+	return d.run(ctx, "RecordInstallmentPayment", func(ctx context.Context) error {
+		return d.next.RecordInstallmentPayment(ctx, orderID, sequence, paymentID)
+	})
+}
+
+// outcome labels a call's result: "ok", the code of a business rule
+// violation, or "error".
+func outcome(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var svcErr *Error
+	if errors.As(err, &svcErr) {
+		return svcErr.Code
+	}
+	return "error"
+}
+
+// LoggingInterceptor logs failed calls, and every call at debug level.
+// Business rule violations are expected and logged at info level.
+func LoggingInterceptor(logger *logging.Logger) Interceptor {
+	return func(ctx context.Context, op string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		if err == nil {
+			logger.Debug(ctx).Str("operation", op).Dur("duration", time.Since(start)).Msg("order service call")
+			return err
+		}
+		var svcErr *Error
+		if errors.As(err, &svcErr) {
+			logger.Info(ctx).Str("operation", op).Dur("duration", time.Since(start)).Str("code", svcErr.Code).Msg("order service call rejected")
+		} else {
+			logger.Warn(ctx).Str("operation", op).Dur("duration", time.Since(start)).Err(err).Msg("order service call failed")
+		}
+		return err
+	}
+}
+
+// MetricsInterceptor records the duration and outcome of every call.
+func MetricsInterceptor() Interceptor {
+	return func(ctx context.Context, op string, call func(ctx context.Context) error) error {
+		start := time.Now()
+		err := call(ctx)
+		metrics.OrderServiceCalls.WithLabelValues(op, outcome(err)).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// TracingInterceptor gives every call a span. Spans share the trace ID of
+// the call they are nested in. The IDs are logging fields, so every line
+// logged during the call carries them, and the span is logged when it ends.
+func TracingInterceptor(logger *logging.Logger) Interceptor {
+	return func(ctx context.Context, op string, call func(ctx context.Context) error) error {
+		traceID, spanID := newSpanIDs()
+		if v, ok := logging.Field(ctx, "trace_id"); ok {
+			traceID = v.String()
+		}
+		var parentSpanID string
+		if v, ok := logging.Field(ctx, "span_id"); ok {
+			parentSpanID = v.String()
+		}
+		ctx = logging.WithFields(ctx, slog.String("trace_id", traceID), slog.String("span_id", spanID))
+		start := time.Now()
+		err := call(ctx)
+		logger.Debug(ctx).
+			Str("parent_span_id", parentSpanID).
+			Str("operation", op).
+			Dur("duration", time.Since(start)).
+			Str("outcome", outcome(err)).
+			Msg("span")
+		return err
+	}
+}
+
+// newSpanIDs returns a random trace ID and span ID, hex encoded. They share
+// one allocation.
+func newSpanIDs() (traceID, spanID string) {
+	var raw [16 + 8]byte
+	rand.Read(raw[:])
+	var buf [2 * len(raw)]byte
+	hex.Encode(buf[:], raw[:])
+	ids := string(buf[:])
+	return ids[:32], ids[32:]
+}
+
+// AuthorizationInterceptor refuses adminOps unless the context carries the
+// admin performing them, so a route wired to the wrong group cannot run
+// them anonymously.
+func AuthorizationInterceptor(adminOps ...string) Interceptor {
+	return func(ctx context.Context, op string, call func(ctx context.Context) error) error {
+		if slices.Contains(adminOps, op) && audit.ActorFromContext(ctx) == "" {
+			return &Error{Code: CodeForbidden, Message: op + " requires an admin"}
+		}
+		return call(ctx)
+	}
+}
+
+// AdminOperations are the calls AuthorizationInterceptor should guard.
+var AdminOperations = []string{"ApproveOrder", "RejectOrder", "ForceOrderStatus", "AddOrderTags", "RemoveOrderTag", "ReplayEvent", "ExplainOrder"}
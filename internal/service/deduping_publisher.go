@@ -0,0 +1,141 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"order-service/internal/dedupe"
+	"order-service/internal/metrics"
+	"order-service/internal/repository"
+	"time"
+)
+
+// EventID identifies an event by its pattern and payload: the same logical
+// event, e.g. order.created for one order relayed again from the outbox,
+// always gets the same ID. It is sent as the message ID and in the
+// envelope, so webhook and notification consumers can drop duplicates.
+func EventID(pattern string, data interface{}) (string, error) {
+	// Payloads are re-encoded with sorted keys, so one spooled to the
+	// outbox and read back gets the ID it had before.
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.New()
+	sum.Write([]byte(pattern))
+	sum.Write([]byte{0})
+	sum.Write(canonical)
+	return hex.EncodeToString(sum.Sum(nil)[:16]), nil
+}
+
+// DedupingPublisher publishes through next only the events that were not
+// published within ttl, so an event relayed twice from the outbox, or
+// spooled after it was sent, reaches consumers once. Events it cannot
+// check are published anyway; a duplicate is better than a lost event.
+type DedupingPublisher struct {
+	next  IBatchPublisher
+	store dedupe.Claimer
+	ttl   time.Duration
+}
+
+var (
+	_ IPublisher      = &DedupingPublisher{}
+	_ IBatchPublisher = &DedupingPublisher{}
+)
+
+func NewDedupingPublisher(next IBatchPublisher, store dedupe.Claimer, ttl time.Duration) *DedupingPublisher {
+	return &DedupingPublisher{next: next, store: store, ttl: ttl}
+}
+
+func (p *DedupingPublisher) PublishOrderCreated(order *repository.Order) error {
+	return p.Publish("order.created", orderCreatedData(order))
+}
+
+func (p *DedupingPublisher) Publish(pattern string, data interface{}) error {
+	return p.PublishBatch([]Event{{Pattern: pattern, Data: data}})
+}
+
+// PublishBatch publishes the events not seen before. When the batch fails
+// they are all forgotten again, including any the broker took before the
+// failure, so retrying it may repeat those.
+func (p *DedupingPublisher) PublishBatch(events []Event) error {
+	ctx := context.Background()
+	send := make([]Event, 0, len(events))
+	var claimed []string
+	for _, e := range events {
+		id, err := EventID(e.Pattern, e.Data)
+		if err != nil {
+			p.release(ctx, claimed)
+			return fmt.Errorf("failed to identify %s event: %w", e.Pattern, err)
+		}
+		e.ID = id
+		ok, err := p.store.Claim(ctx, dedupeKey(id), p.ttl)
+		switch {
+		case err != nil:
+			log.Printf("Failed to check %s event %s for duplicates, publishing it: %v", e.Pattern, id, err)
+		case !ok:
+			log.Printf("Skipping duplicate %s event %s", e.Pattern, id)
+			metrics.EventsSuppressed.WithLabelValues(e.Pattern).Inc()
+			continue
+		default:
+			claimed = append(claimed, id)
+		}
+		send = append(send, e)
+	}
+	if len(send) == 0 {
+		return nil
+	}
+	if err := p.next.PublishBatch(send); err != nil {
+		p.release(ctx, claimed)
+		return err
+	}
+	return nil
+}
+
+// Forget lets the event be published once more, e.g. when an admin
+// replays it for a consumer that lost it.
+func (p *DedupingPublisher) Forget(pattern string, data interface{}) error {
+	id, err := EventID(pattern, data)
+	if err != nil {
+		return err
+	}
+	return p.store.Release(context.Background(), dedupeKey(id))
+}
+
+func (p *DedupingPublisher) release(ctx context.Context, ids []string) {
+	for _, id := range ids {
+		if err := p.store.Release(ctx, dedupeKey(id)); err != nil {
+			log.Printf("Failed to forget event %s: %v", id, err)
+		}
+	}
+}
+
+func dedupeKey(id string) string {
+	return "event:" + id
+}
+
+// IEventDeduper lets events already published be published again.
+type IEventDeduper interface {
+	Forget(pattern string, data interface{}) error
+}
+
+// WithEventDeduper makes ReplayEvent forget the replayed event in d first,
+// so it is not dropped as a duplicate. Consumers still see its original
+// ID and can tell whether they handled it.
+func WithEventDeduper(d IEventDeduper) Option {
+	return func(s *OrderService) { s.deduper = d }
+}
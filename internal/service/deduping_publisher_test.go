@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"order-service/internal/repository"
+	"slices"
+	"testing"
+	"time"
+)
+
+// memoryDedupe claims keys in memory, ignoring their ttl.
+type memoryDedupe struct {
+	keys map[string]bool
+	err  error
+}
+
+func newMemoryDedupe() *memoryDedupe {
+	return &memoryDedupe{keys: map[string]bool{}}
+}
+
+func (m *memoryDedupe) Claim(_ context.Context, key string, _ time.Duration) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.keys[key] {
+		return false, nil
+	}
+	m.keys[key] = true
+	return true, nil
+}
+
+func (m *memoryDedupe) Release(_ context.Context, key string) error {
+	delete(m.keys, key)
+	return m.err
+}
+
+func TestEventID(t *testing.T) {
+	data := map[string]interface{}{"orderId": "o1", "quantity": 2, "tags": []string{"vip"}}
+	id, err := EventID("order.created", data)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(id) != 32 {
+		t.Errorf("Expected a 32 digit ID, got %q", id)
+	}
+
+	// The outbox hands payloads back as Postgres formats jsonb.
+	spooled := json.RawMessage(`{"tags": ["vip"], "orderId": "o1", "quantity": 2}`)
+	if got, _ := EventID("order.created", spooled); got != id {
+		t.Errorf("Expected the spooled event to keep ID %s, got %s", id, got)
+	}
+	if got, _ := EventID("order.paid", data); got == id {
+		t.Error("Expected another pattern to get another ID")
+	}
+	if got, _ := EventID("order.created", map[string]interface{}{"orderId": "o2", "quantity": 2}); got == id {
+		t.Error("Expected another payload to get another ID")
+	}
+}
+
+func TestDedupingPublisher(t *testing.T) {
+	broker := &mockBatchPublisher{}
+	store := newMemoryDedupe()
+	publisher := NewDedupingPublisher(broker, store, time.Hour)
+
+	order := &repository.Order{ID: "o1", ProductID: "p1", Quantity: 1}
+	if err := publisher.PublishOrderCreated(order); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Relayed again from the outbox after it was sent.
+	spooled, _ := json.Marshal(orderCreatedData(order))
+	if err := publisher.Publish("order.created", json.RawMessage(spooled)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := publisher.PublishBatch([]Event{
+		{Pattern: "order.created", Data: orderCreatedData(order)},
+		{Pattern: "order.paid", Data: orderRefData(order)},
+	}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if sizes := broker.sizes(); !slices.Equal(sizes, []int{1, 1}) {
+		t.Fatalf("Expected batches of 1 and 1, got %v", sizes)
+	}
+	if sent := broker.batches[1][0]; sent.Pattern != "order.paid" || sent.ID == "" {
+		t.Errorf("Expected order.paid with its ID, got %+v", sent)
+	}
+
+	// A failed publish is forgotten, so the retry goes out.
+	broker.shouldFail = true
+	if err := publisher.Publish("order.cancelled", orderRefData(order)); err == nil {
+		t.Fatal("Expected the publish error")
+	}
+	broker.shouldFail = false
+	if err := publisher.Publish("order.cancelled", orderRefData(order)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(broker.batches) != 3 {
+		t.Errorf("Expected the retry to be published, got %d batches", len(broker.batches))
+	}
+
+	// A replay is forgotten first.
+	if err := publisher.Forget("order.paid", orderRefData(order)); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := publisher.Publish("order.paid", orderRefData(order)); err != nil || len(broker.batches) != 4 {
+		t.Errorf("Expected the replay to be published, got %v and %d batches", err, len(broker.batches))
+	}
+
+	// Events that cannot be checked are published anyway.
+	store.err = errors.New("redis down")
+	if err := publisher.Publish("order.paid", orderRefData(order)); err != nil || len(broker.batches) != 5 {
+		t.Errorf("Expected the event to be published, got %v and %d batches", err, len(broker.batches))
+	}
+}
+
+type forgettingDeduper struct {
+	forgotten []string
+}
+
+func (f *forgettingDeduper) Forget(pattern string, data interface{}) error {
+	f.forgotten = append(f.forgotten, pattern)
+	return nil
+}
+
+func TestReplayEventForgetsDuplicate(t *testing.T) {
+	repo := &paymentRepository{orders: map[string]*repository.Order{
+		"paid": {ID: "paid", ProductID: "p1", Status: repository.StatusPaid},
+	}}
+	deduper := &forgettingDeduper{}
+	service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, "", WithEventDeduper(deduper))
+
+	if _, err := service.ReplayEvent(context.Background(), "paid"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !slices.Equal(deduper.forgotten, []string{"order.paid"}) {
+		t.Errorf("Expected order.paid to be forgotten, got %v", deduper.forgotten)
+	}
+}
@@ -0,0 +1,47 @@
+package service
+
+// Error is a business rule violation with a stable, machine-readable code.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+const (
+	CodeQuantityOutOfRange      = "QUANTITY_OUT_OF_RANGE"
+	CodePurchaseLimitExceeded   = "PURCHASE_LIMIT_EXCEEDED"
+	CodeOrderNotFound           = "ORDER_NOT_FOUND"
+	CodeOrderNotOnHold          = "ORDER_NOT_ON_HOLD"
+	CodeCustomerBlocked         = "CUSTOMER_BLOCKED"
+	CodeIPBlocked               = "IP_BLOCKED"
+	CodeProductBlocked          = "PRODUCT_BLOCKED"
+	CodeInvalidDiscountCode     = "INVALID_DISCOUNT_CODE"
+	CodeCartNotFound            = "CART_NOT_FOUND"
+	CodeCartEmpty               = "CART_EMPTY"
+	CodeCartCheckedOut          = "CART_ALREADY_CHECKED_OUT"
+	CodeOrderNotAwaitingPayment = "ORDER_NOT_AWAITING_PAYMENT"
+	CodePaymentDeclined         = "PAYMENT_DECLINED"
+	CodePaymentExpired          = "PAYMENT_EXPIRED"
+	CodeInvalidInstallments     = "INVALID_INSTALLMENTS"
+	CodeInvalidGiftCard         = "INVALID_GIFT_CARD"
+	CodeTenderNotAvailable      = "TENDER_NOT_AVAILABLE"
+	CodeUnsupportedCurrency     = "UNSUPPORTED_CURRENCY"
+	CodeNothingToReplay         = "NOTHING_TO_REPLAY"
+	CodeRevisionNotFound        = "REVISION_NOT_FOUND"
+	CodeForbidden               = "FORBIDDEN"
+	CodeOrderNotAccepted        = "ORDER_NOT_ACCEPTED"
+	CodeInvalidSchedule         = "INVALID_SCHEDULE"
+	CodeOrderNotScheduled       = "ORDER_NOT_SCHEDULED"
+	CodeReservationNotAvailable = "RESERVATION_NOT_AVAILABLE"
+	CodeReservationExpired      = "RESERVATION_EXPIRED"
+	CodeInvalidOrderID          = "INVALID_ORDER_ID"
+	CodeOrderIDConflict         = "ORDER_ID_CONFLICT"
+	CodeReasonRequired          = "REASON_REQUIRED"
+	CodeUnknownStatus           = "UNKNOWN_STATUS"
+	CodeOrderStatusConflict     = "ORDER_STATUS_CONFLICT"
+	CodeInvalidTag              = "INVALID_TAG"
+	CodeAddressRequired         = "SHIPPING_ADDRESS_REQUIRED"
+	CodeCountryNotAllowed       = "SHIPPING_COUNTRY_NOT_ALLOWED"
+	CodeRegionNotAllowed        = "SHIPPING_REGION_NOT_ALLOWED"
+)
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"order-service/internal/golden"
+	"order-service/internal/repository"
+	"order-service/internal/rounding"
+	"order-service/internal/schema"
+	"order-service/internal/subscription"
+	"testing"
+	"time"
+)
+
+// contractOrder is an order with every field an event may carry set to a
+// fixed value.
+func contractOrder(status string) *repository.Order {
+	at := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	later := at.Add(48 * time.Hour)
+	return &repository.Order{
+		ID:            "order-1",
+		ProductID:     "p1",
+		CustomerID:    "customer-1",
+		Quantity:      2,
+		Status:        status,
+		Experiment:    "checkout-copy",
+		Variant:       "b",
+		HoldReason:    "velocity check",
+		TotalPrice:    115,
+		PaidAmount:    40,
+		ProcessAt:     &at,
+		DeliverAt:     &later,
+		ReservedUntil: &at,
+		Tags:          []repository.OrderTag{{Tag: "flash-sale"}, {Tag: "incident-42"}},
+		CreatedAt:     at,
+	}
+}
+
+// TestEventPayloads pins the payload of every event the service publishes.
+// A failure here is a change to a contract other teams consume.
+func TestEventPayloads(t *testing.T) {
+	announced := func(status string) interface{} {
+		_, data := announcement(contractOrder(status))
+		return data
+	}
+	cycle := time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)
+	retryAt := cycle.Add(6 * time.Hour)
+	sub := &subscription.Subscription{
+		ID:             "sub-1",
+		CustomerID:     "customer-1",
+		ProductID:      "p1",
+		Quantity:       2,
+		FailedAttempts: 2,
+		RetryAt:        &retryAt,
+		LastError:      "payment declined",
+	}
+	minimal := &repository.Order{ID: "order-1", ProductID: "p1", Quantity: 2}
+
+	cases := []struct {
+		name, pattern string
+		data          interface{}
+	}{
+		{"order.created", "order.created", orderCreatedData(contractOrder(repository.StatusPending))},
+		{"order.created.minimal", "order.created", orderCreatedData(minimal)},
+		{"order.reserved", "order.reserved", announced(repository.StatusReserved)},
+		{"order.scheduled", "order.scheduled", announced(repository.StatusScheduled)},
+		{"order.backordered", "order.backordered", announced(repository.StatusBackordered)},
+		{"order.flagged", "order.flagged", announced(repository.StatusOnHold)},
+		{"order.reservation_released", "order.reservation_released", reservationReleasedData(contractOrder(repository.StatusReservationExpired))},
+		{"order.payment_expired", "order.payment_expired", orderRefData(contractOrder(repository.StatusPaymentExpired))},
+		{"order.paid", "order.paid", orderRefData(contractOrder(repository.StatusPaid))},
+		{"order.cancelled", "order.cancelled", orderRefData(contractOrder(repository.StatusCancelled))},
+		{"order.rejected", "order.rejected", orderRefData(contractOrder(repository.StatusRejected))},
+		{"order.rejected.with_reason", "order.rejected", orderRejectedData(contractOrder(repository.StatusRejected))},
+		{"order.status_forced", "order.status_forced", statusForcedData(contractOrder(repository.StatusPaid), repository.StatusPaymentExpired, "paid by bank transfer", "alice")},
+		{"order.rescheduled", "order.rescheduled", rescheduledData(contractOrder(repository.StatusScheduled))},
+		{"order.reordered", "order.reordered", reorderedData(contractOrder(repository.StatusPending), &repository.Order{ID: "order-0"})},
+		{"order.installment_paid", "order.installment_paid", installmentPaidData(contractOrder(repository.StatusPending), 2, rounding.Default)},
+		{"cart.checked_out", "cart.checked_out", cartCheckedOutData(&CheckoutResult{CartID: "cart-1", Total: 230}, "customer-1", []string{"order-1", "order-2"})},
+		{"subscription.order_generated", "subscription.order_generated", orderGeneratedData(sub, contractOrder(repository.StatusPending), cycle)},
+		{"subscription.suspended", "subscription.suspended", subscriptionSuspendedData(sub)},
+		{"subscription.payment_failed", "subscription.payment_failed", paymentFailedData(sub)},
+		{"subscription.cycle_skipped", "subscription.cycle_skipped", cycleSkippedData(sub, cycle)},
+	}
+
+	schemas, err := schema.Load()
+	if err != nil {
+		t.Fatalf("Expected schemas to load, got %v", err)
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			golden.JSON(t, "events/"+tc.name, tc.data)
+			if err := schemas.ValidateValue(tc.pattern, tc.data); err != nil {
+				t.Errorf("Expected the payload to match its schema, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"order-service/internal/audit"
+	"order-service/internal/domain"
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"slices"
+	"strings"
+)
+
+// maxForceReasonLength caps the reason given for a forced status.
+const maxForceReasonLength = 500
+
+// ForceStatusRequest moves an order to any status, for data fixes.
+type ForceStatusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// ForceOrderStatus moves an order to a status regardless of the status
+// lifecycle. Nothing the regular transition would do happens: stock,
+// payments and purchase limits are left as they are. The change is
+// published as order.status_forced, flagged as a manual override, with
+// the admin from the context and the reason.
+func (s *OrderService) ForceOrderStatus(ctx context.Context, id string, req ForceStatusRequest) (*repository.Order, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" || len(reason) > maxForceReasonLength {
+		return nil, &Error{Code: CodeReasonRequired, Message: fmt.Sprintf("a reason of at most %d characters is required", maxForceReasonLength)}
+	}
+	if !slices.Contains(domain.Statuses, req.Status) {
+		return nil, &Error{Code: CodeUnknownStatus, Message: fmt.Sprintf("unknown status %q", req.Status)}
+	}
+	order, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	from := order.Status
+	if from == req.Status {
+		return nil, &Error{Code: CodeOrderStatusConflict, Message: "order is already " + from}
+	}
+
+	err = s.repo.UpdateStatus(id, from, req.Status)
+	if errors.Is(err, repository.ErrStatusConflict) {
+		return nil, &Error{Code: CodeOrderStatusConflict, Message: "order status changed concurrently; check it and try again"}
+	} else if err != nil {
+		return nil, err
+	}
+	order.Status = req.Status
+
+	s.publish(ctx, "order.status_forced", statusForcedData(order, from, reason, audit.ActorFromContext(ctx)))
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.emit(events.OrderUpdated, order)
+	return order, nil
+}
+
+func statusForcedData(order *repository.Order, from, reason, actor string) map[string]interface{} {
+	return withTags(map[string]interface{}{
+		"orderId":        order.ID,
+		"productId":      order.ProductID,
+		"fromStatus":     from,
+		"toStatus":       order.Status,
+		"reason":         reason,
+		"actor":          actor,
+		"manualOverride": true,
+	}, order)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"order-service/internal/audit"
+	"order-service/internal/repository"
+)
+
+// statusRepository applies status changes to the stored orders.
+type statusRepository struct {
+	idRepository
+}
+
+func (m *statusRepository) UpdateStatus(id, from, to string) error {
+	order, ok := m.orders[id]
+	if !ok || order.Status != from {
+		return repository.ErrStatusConflict
+	}
+	order.Status = to
+	m.orders[id] = order
+	return nil
+}
+
+func TestForceOrderStatus(t *testing.T) {
+	repo := &statusRepository{idRepository{orders: map[string]repository.Order{
+		"order-1": {ID: "order-1", ProductID: "p1", Status: repository.StatusPaymentExpired},
+	}}}
+	publisher := &mockPublisher{}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, "")
+	ctx := audit.WithActor(context.Background(), "alice")
+
+	errorCases := []struct {
+		name string
+		id   string
+		req  ForceStatusRequest
+		code string
+	}{
+		{"no reason", "order-1", ForceStatusRequest{Status: repository.StatusPaid, Reason: "  "}, CodeReasonRequired},
+		{"unknown status", "order-1", ForceStatusRequest{Status: "SHIPPED", Reason: "fix"}, CodeUnknownStatus},
+		{"same status", "order-1", ForceStatusRequest{Status: repository.StatusPaymentExpired, Reason: "fix"}, CodeOrderStatusConflict},
+		{"missing order", "order-2", ForceStatusRequest{Status: repository.StatusPaid, Reason: "fix"}, CodeOrderNotFound},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.ForceOrderStatus(ctx, tc.id, tc.req)
+			var svcErr *Error
+			if !errors.As(err, &svcErr) || svcErr.Code != tc.code {
+				t.Errorf("Expected %s, got %v", tc.code, err)
+			}
+		})
+	}
+	if len(publisher.patterns) != 0 {
+		t.Fatalf("Expected nothing published for refused changes, got %v", publisher.patterns)
+	}
+
+	// PAYMENT_EXPIRED is final; only a forced change leaves it.
+	order, err := service.ForceOrderStatus(ctx, "order-1", ForceStatusRequest{Status: repository.StatusPaid, Reason: "paid by bank transfer"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.Status != repository.StatusPaid || repo.orders["order-1"].Status != repository.StatusPaid {
+		t.Errorf("Expected the order to be PAID, got %s", order.Status)
+	}
+	if len(publisher.patterns) != 1 || publisher.patterns[0] != "order.status_forced" {
+		t.Errorf("Expected order.status_forced, got %v", publisher.patterns)
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"sync"
+)
+
+type productMemoKey struct{}
+
+type productLookup struct {
+	product *ProductResponse
+	err     error
+}
+
+// productMemo caches product-service lookups for the lifetime of a
+// context, so a batch with many rows for the same product validates it
+// once.
+type productMemo struct {
+	mu      sync.Mutex
+	lookups map[string]productLookup
+}
+
+func withProductMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, productMemoKey{}, &productMemo{lookups: make(map[string]productLookup)})
+}
+
+func memoFromContext(ctx context.Context) *productMemo {
+	memo, _ := ctx.Value(productMemoKey{}).(*productMemo)
+	return memo
+}
+
+// ImportOrders validates and stores a batch of imported orders. Unlike
+// CreateOrders, a row that fails validation does not fail the batch: its
+// error is returned at the same index and the valid rows are stored
+// together. Imported orders are not sent through payment.
+func (s *OrderService) ImportOrders(ctx context.Context, reqs []CreateOrderRequest) ([]repository.Order, []error) {
+	ctx = withProductMemo(ctx)
+	errs := make([]error, len(reqs))
+	orders := make([]repository.Order, 0, len(reqs))
+	rows := make([]int, 0, len(reqs))
+	for i, req := range reqs {
+		order, err := s.buildOrder(ctx, req)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		orders = append(orders, *order)
+		rows = append(rows, i)
+	}
+	if len(orders) == 0 {
+		return nil, errs
+	}
+
+	if err := s.repo.CreateBatch(orders); err != nil {
+		s.abandon(orders...)
+		for _, i := range rows {
+			errs[i] = err
+		}
+		return nil, errs
+	}
+
+	s.appendToCache(orders...)
+	for i := range orders {
+		s.announce(ctx, &orders[i])
+		s.emit(events.OrderCreated, &orders[i])
+	}
+	return orders, errs
+}
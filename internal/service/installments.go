@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"order-service/internal/rounding"
+
+	"github.com/google/uuid"
+)
+
+// checkInstallments validates the number of installments requested. Zero
+// and one both mean a single payment.
+func (s *OrderService) checkInstallments(n int) error {
+	if n == 0 || n == 1 {
+		return nil
+	}
+	if n < 0 || n > s.maxInstallments {
+		msg := "installments are not available"
+		if s.maxInstallments > 1 {
+			msg = fmt.Sprintf("installments must be between 2 and %d", s.maxInstallments)
+		}
+		return &Error{Code: CodeInvalidInstallments, Message: msg}
+	}
+	return nil
+}
+
+// splitInstallments spreads total over n monthly installments, the first
+// due at start. It divides whole units of round, e.g. cents, yen or cash
+// increments; the units that do not divide evenly go to the last one.
+func splitInstallments(orderID string, total float64, n int, start time.Time, round rounding.Strategy) []repository.Installment {
+	unit := rounding.Unit(round)
+	units := int64(math.Round(total / unit))
+	share := round.Round(float64(units/int64(n)) * unit)
+	last := round.Round(float64(units/int64(n)+units%int64(n)) * unit)
+	installments := make([]repository.Installment, n)
+	for i := range installments {
+		installments[i] = repository.Installment{
+			ID:       uuid.New().String(),
+			OrderID:  orderID,
+			Sequence: i + 1,
+			Amount:   share,
+			DueAt:    start.AddDate(0, i, 0),
+		}
+	}
+	installments[n-1].Amount = last
+	return installments
+}
+
+// firstInstallment is the installment captured when an installment order
+// is confirmed, or nil for an order paid at once.
+func firstInstallment(order *repository.Order) *repository.Installment {
+	for i := range order.Installments {
+		if order.Installments[i].Sequence == 1 {
+			return &order.Installments[i]
+		}
+	}
+	return nil
+}
+
+// payFirstInstallment records the capture of a confirmed installment
+// order as the payment of its first installment. Orders paid at once have
+// no installments and are left alone.
+func (s *OrderService) payFirstInstallment(ctx context.Context, order *repository.Order) {
+	paid, err := s.repo.MarkInstallmentPaid(order.ID, 1, order.PaymentIntentID)
+	if errors.Is(err, repository.ErrInstallmentNotFound) {
+		return
+	} else if err != nil {
+		log.Printf("Failed to record the first installment of order %s as paid: %v", order.ID, err)
+		return
+	}
+	order.PaidAmount = paid.PaidAmount
+	s.publish(ctx, "order.installment_paid", installmentPaidData(paid, 1, s.roundingFor(paid)))
+}
+
+// RecordInstallmentPayment marks one installment as paid. Redelivered
+// events for an installment that is already paid are ignored.
+func (s *OrderService) RecordInstallmentPayment(ctx context.Context, orderID string, sequence int, paymentID string) error {
+	order, err := s.repo.MarkInstallmentPaid(orderID, sequence, paymentID)
+	if errors.Is(err, repository.ErrInstallmentPaid) {
+		log.Printf("Installment %d of order %s was already paid", sequence, orderID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.publish(ctx, "order.installment_paid", installmentPaidData(order, sequence, s.roundingFor(order)))
+	if order.Status == repository.StatusPaid {
+		s.publish(ctx, "order.paid", orderRefData(order))
+	}
+	s.emit(events.OrderUpdated, order)
+	return nil
+}
+
+func installmentPaidData(order *repository.Order, sequence int, round rounding.Strategy) map[string]interface{} {
+	return withTags(map[string]interface{}{
+		"orderId":         order.ID,
+		"sequence":        sequence,
+		"paidAmount":      round.Round(order.PaidAmount),
+		"remainingAmount": round.Round(order.TotalPrice - order.PaidAmount),
+	}, order)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"order-service/internal/inventory"
+	"order-service/internal/repository"
+)
+
+// IInventoryLedger holds the stock of reserved orders locally and tells how
+// much of a product is held.
+type IInventoryLedger interface {
+	Reserve(ctx context.Context, hold *inventory.Hold) error
+	Release(ctx context.Context, orderID string) (*inventory.Hold, error)
+	Committed(ctx context.Context, productID string) (int, error)
+}
+
+// WithInventoryLedger records the stock reserved orders hold in ledger and
+// leaves it out of the stock new orders may take.
+func WithInventoryLedger(ledger IInventoryLedger) Option {
+	return func(s *OrderService) { s.ledger = ledger }
+}
+
+// availableStock is the product's stock less what the ledger holds. The
+// ledger failing does not stop orders.
+func (s *OrderService) availableStock(ctx context.Context, product *ProductResponse) int {
+	if s.ledger == nil {
+		return product.Qty
+	}
+	committed, err := s.ledger.Committed(ctx, product.ID)
+	if err != nil {
+		s.logger.Warn(ctx).Str("product_id", product.ID).Err(err).Msg("failed to read committed stock")
+		return product.Qty
+	}
+	return product.Qty - committed
+}
+
+// holdStock records the stock of a reserved order in the ledger.
+func (s *OrderService) holdStock(ctx context.Context, order *repository.Order) {
+	if s.ledger == nil || order.Status != repository.StatusReserved || order.ReservedUntil == nil {
+		return
+	}
+	err := s.ledger.Reserve(ctx, &inventory.Hold{
+		OrderID:   order.ID,
+		ProductID: order.ProductID,
+		Quantity:  order.Quantity,
+		ExpiresAt: *order.ReservedUntil,
+	})
+	if err != nil {
+		s.logger.Error(ctx).Str("order_id", order.ID).Err(err).Msg("failed to hold stock")
+	}
+}
+
+// releaseStock gives up the ledger hold of an order that no longer reserves
+// stock.
+func (s *OrderService) releaseStock(ctx context.Context, orderID string) {
+	if s.ledger == nil {
+		return
+	}
+	if _, err := s.ledger.Release(ctx, orderID); err != nil && !errors.Is(err, inventory.ErrNotFound) {
+		s.logger.Error(ctx).Str("order_id", orderID).Err(err).Msg("failed to release held stock")
+	}
+}
+
+// ProductStock returns the stock product-service reports for a product. It
+// is what the inventory ledger is reconciled against.
+func (s *OrderService) ProductStock(ctx context.Context, productID string) (int, error) {
+	product, err := s.requestProductInfo(ctx, productID)
+	if err != nil {
+		return 0, err
+	}
+	return product.Qty, nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"order-service/internal/domain"
+	"slices"
+)
+
+// IOrderCounts caches how many orders each product has per status.
+type IOrderCounts interface {
+	Get(ctx context.Context, productID string) (map[string]int64, bool, error)
+	Set(ctx context.Context, productID string, counts map[string]int64) error
+}
+
+// WithOrderCounts serves CountOrdersByProduct from counts, loading them
+// from the database on a miss. Keeping them current as orders change is
+// up to the caller.
+func WithOrderCounts(counts IOrderCounts) Option {
+	return func(s *OrderService) { s.counts = counts }
+}
+
+// CountOrdersByProduct counts the product's orders in status, or all of
+// them when status is empty, without loading the orders.
+func (s *OrderService) CountOrdersByProduct(ctx context.Context, productID, status string) (int64, error) {
+	if status != "" && !slices.Contains(domain.Statuses, status) {
+		return 0, &Error{Code: CodeUnknownStatus, Message: fmt.Sprintf("unknown status %q", status)}
+	}
+	counts, err := s.productCounts(ctx, productID)
+	if err != nil {
+		return 0, err
+	}
+	if status != "" {
+		return counts[status], nil
+	}
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	return total, nil
+}
+
+func (s *OrderService) productCounts(ctx context.Context, productID string) (map[string]int64, error) {
+	if s.counts == nil {
+		return s.repo.CountByProduct(productID)
+	}
+	counts, ok, err := s.counts.Get(ctx, productID)
+	if err != nil {
+		s.logger.Warn(ctx).Str("product_id", productID).Err(err).Msg("failed to read cached order counts")
+	}
+	if ok {
+		return counts, nil
+	}
+	if counts, err = s.repo.CountByProduct(productID); err != nil {
+		return nil, err
+	}
+	if err := s.counts.Set(ctx, productID, counts); err != nil {
+		s.logger.Warn(ctx).Str("product_id", productID).Err(err).Msg("failed to cache order counts")
+	}
+	return counts, nil
+}
+
+// OrderExists reports whether the order exists without loading it.
+func (s *OrderService) OrderExists(ctx context.Context, id string) (bool, error) {
+	return s.repo.Exists(id)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"order-service/internal/repository"
+)
+
+// countRepository counts orders per status from counts.
+type countRepository struct {
+	mockOrderRepository
+	counts map[string]int64
+	loads  int
+}
+
+func (m *countRepository) CountByProduct(productID string) (map[string]int64, error) {
+	m.loads++
+	return m.counts, nil
+}
+
+// fakeCounts caches counts in memory.
+type fakeCounts map[string]map[string]int64
+
+func (f fakeCounts) Get(_ context.Context, productID string) (map[string]int64, bool, error) {
+	counts, ok := f[productID]
+	return counts, ok, nil
+}
+
+func (f fakeCounts) Set(_ context.Context, productID string, counts map[string]int64) error {
+	f[productID] = counts
+	return nil
+}
+
+func TestCountOrdersByProduct(t *testing.T) {
+	repo := &countRepository{counts: map[string]int64{repository.StatusPending: 2, repository.StatusPaid: 3}}
+	counts := fakeCounts{}
+	service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, "", WithOrderCounts(counts))
+	ctx := context.Background()
+
+	if n, err := service.CountOrdersByProduct(ctx, "p1", ""); err != nil || n != 5 {
+		t.Errorf("Expected 5 orders, got %d, %v", n, err)
+	}
+	if n, err := service.CountOrdersByProduct(ctx, "p1", repository.StatusPaid); err != nil || n != 3 {
+		t.Errorf("Expected 3 paid orders, got %d, %v", n, err)
+	}
+	if n, err := service.CountOrdersByProduct(ctx, "p1", repository.StatusCancelled); err != nil || n != 0 {
+		t.Errorf("Expected no cancelled orders, got %d, %v", n, err)
+	}
+	if repo.loads != 1 || counts["p1"] == nil {
+		t.Errorf("Expected the counts to be loaded once and cached, got %d loads", repo.loads)
+	}
+
+	_, err := service.CountOrdersByProduct(ctx, "p1", "SHIPPED")
+	var serr *Error
+	if !errors.As(err, &serr) || serr.Code != CodeUnknownStatus {
+		t.Errorf("Expected %s, got %v", CodeUnknownStatus, err)
+	}
+}
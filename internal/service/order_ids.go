@@ -0,0 +1,58 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"order-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// parseOrderID accepts a client-chosen order ID in the canonical UUID form
+// and returns it in lower case.
+func parseOrderID(v string) (string, error) {
+	id, err := uuid.Parse(v)
+	if err != nil || len(v) != 36 || id == uuid.Nil {
+		return "", &Error{Code: CodeInvalidOrderID, Message: fmt.Sprintf("order ID %q must be a UUID like 0f8fad5b-d9cb-469f-a165-70867728950e", v)}
+	}
+	return id.String(), nil
+}
+
+// newOrderID is the ID the client chose for the order, or a new one.
+func newOrderID(req CreateOrderRequest) string {
+	if req.ID != "" {
+		return req.ID
+	}
+	return uuid.New().String()
+}
+
+// requestHash fingerprints everything the client sent to create an order,
+// and its tenant.
+func requestHash(req CreateOrderRequest) string {
+	body, _ := json.Marshal(req)
+	sum := sha256.Sum256(append([]byte(req.TenantID+"\n"), body...))
+	return hex.EncodeToString(sum[:])
+}
+
+// resubmitted looks up the order with the ID of req. An order created by
+// the same request is returned as it is now, so a client retrying a
+// submission gets the order instead of an error; an order created by a
+// different request is a conflict. It returns neither if there is no
+// order with the ID.
+func (s *OrderService) resubmitted(req CreateOrderRequest) (*repository.Order, error) {
+	existing, err := s.repo.GetByID(req.ID)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing.RequestHash == "" || existing.RequestHash != requestHash(req) {
+		return nil, &Error{Code: CodeOrderIDConflict, Message: fmt.Sprintf("order ID %s is already used by a different order", req.ID)}
+	}
+	return existing, nil
+}
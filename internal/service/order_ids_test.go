@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/repository"
+)
+
+// idRepository stores created orders by ID like the database does.
+type idRepository struct {
+	mockOrderRepository
+	orders  map[string]repository.Order
+	creates int
+	// race stores the next order as if a concurrent request had just
+	// created it.
+	race *repository.Order
+}
+
+func (m *idRepository) Create(order *repository.Order) error {
+	m.creates++
+	if m.race != nil {
+		m.orders[m.race.ID], m.race = *m.race, nil
+	}
+	if _, ok := m.orders[order.ID]; ok {
+		return repository.ErrOrderExists
+	}
+	m.orders[order.ID] = *order
+	return nil
+}
+
+func (m *idRepository) GetByID(id string) (*repository.Order, error) {
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, repository.ErrOrderNotFound
+	}
+	return &order, nil
+}
+
+func TestCreateOrderWithClientID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p1", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+	repo := &idRepository{orders: map[string]repository.Order{}}
+	service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, server.URL)
+	ctx := context.Background()
+	const id = "0F8FAD5B-D9CB-469F-A165-70867728950E"
+	req := CreateOrderRequest{ID: id, ProductID: "p1", Quantity: 2, TenantID: "acme"}
+
+	order, err := service.CreateOrder(ctx, req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.ID != "0f8fad5b-d9cb-469f-a165-70867728950e" || order.RequestHash == "" {
+		t.Errorf("Expected the chosen ID in lower case with a request hash, got %q %q", order.ID, order.RequestHash)
+	}
+
+	t.Run("resubmission", func(t *testing.T) {
+		again, err := service.CreateOrder(ctx, req)
+		if err != nil || again.ID != order.ID || repo.creates != 1 {
+			t.Errorf("Expected the stored order without creating another, got %+v, %v after %d creates", again, err, repo.creates)
+		}
+	})
+
+	conflicts := map[string]CreateOrderRequest{
+		"other payload": {ID: id, ProductID: "p1", Quantity: 3, TenantID: "acme"},
+		"other tenant":  {ID: id, ProductID: "p1", Quantity: 2, TenantID: "globex"},
+	}
+	for name, other := range conflicts {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.CreateOrder(ctx, other)
+			var svcErr *Error
+			if !errors.As(err, &svcErr) || svcErr.Code != CodeOrderIDConflict {
+				t.Errorf("Expected %s, got %v", CodeOrderIDConflict, err)
+			}
+		})
+	}
+
+	t.Run("generated ID", func(t *testing.T) {
+		order, err := service.CreateOrder(ctx, CreateOrderRequest{ProductID: "p1", Quantity: 1})
+		if err != nil || order.ID == "" || order.RequestHash != "" {
+			t.Errorf("Expected a generated ID without a request hash, got %+v, %v", order, err)
+		}
+	})
+
+	t.Run("concurrent submission", func(t *testing.T) {
+		const raced = "7c9e6679-7425-40de-944b-e07fc1f90ae7"
+		req := CreateOrderRequest{ID: raced, ProductID: "p1", Quantity: 1}
+		winner := repository.Order{ID: raced, Status: repository.StatusPending, RequestHash: requestHash(req)}
+		repo.race = &winner
+		order, err := service.CreateOrder(ctx, req)
+		if err != nil || order.ID != raced || order.Status != repository.StatusPending {
+			t.Errorf("Expected the winner's order, got %+v, %v", order, err)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, id := range []string{"order-1", "{0f8fad5b-d9cb-469f-a165-70867728950e}", "0f8fad5bd9cb469fa16570867728950e", "00000000-0000-0000-0000-000000000000"} {
+			_, err := service.CreateOrder(ctx, CreateOrderRequest{ID: id, ProductID: "p1", Quantity: 1})
+			var svcErr *Error
+			if !errors.As(err, &svcErr) || svcErr.Code != CodeInvalidOrderID {
+				t.Errorf("Expected %s for %q, got %v", CodeInvalidOrderID, id, err)
+			}
+		}
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		_, err := service.CreateOrders(ctx, []CreateOrderRequest{{ID: "7c9e6679-7425-40de-944b-e07fc1f90ae8", ProductID: "p1", Quantity: 1}})
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeInvalidOrderID {
+			t.Errorf("Expected %s, got %v", CodeInvalidOrderID, err)
+		}
+	})
+}
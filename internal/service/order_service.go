@@ -1,109 +1,612 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"order-service/internal/acceptance"
+	"order-service/internal/backoff"
+	"order-service/internal/balance"
+	"order-service/internal/blocklist"
+	"order-service/internal/cart"
+	"order-service/internal/currency"
+	"order-service/internal/degrade"
+	"order-service/internal/domain"
+	"order-service/internal/errreport"
+	"order-service/internal/eventorder"
+	"order-service/internal/events"
+	"order-service/internal/experiment"
+	"order-service/internal/featureflags"
+	"order-service/internal/fraud"
+	"order-service/internal/georestrict"
+	"order-service/internal/jsonenc"
+	"order-service/internal/limits"
+	"order-service/internal/links"
+	"order-service/internal/logging"
+	"order-service/internal/payment"
 	"order-service/internal/repository"
+	"order-service/internal/rounding"
+	"order-service/pkg/cache"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/streadway/amqp"
 )
 
 // DTOs for external communication
 type CreateOrderRequest struct {
-	ProductID string `json:"productId"`
-	Quantity  int    `json:"quantity"`
+	// ID is the order's ID if the client chose it, so it can refer to the
+	// order before submitting it. It must be a UUID.
+	ID         string `json:"id,omitempty"`
+	ProductID  string `json:"productId"`
+	Quantity   int    `json:"quantity"`
+	CustomerID string `json:"customerId,omitempty"`
+	// AllowBackorder accepts the order as BACKORDERED when stock is short,
+	// if backorders are enabled.
+	AllowBackorder bool   `json:"allowBackorder,omitempty"`
+	DiscountCode   string `json:"discountCode,omitempty"`
+	// Installments splits the total into that many monthly payments.
+	Installments int `json:"installments,omitempty"`
+	// GiftCardCode and UseStoreCredit pay part or all of the total from
+	// balances held in balance-service.
+	GiftCardCode   string `json:"giftCardCode,omitempty"`
+	UseStoreCredit bool   `json:"useStoreCredit,omitempty"`
+	// Currency is the customer's currency. Prices are converted into it
+	// when it differs from the product's.
+	Currency string `json:"currency,omitempty"`
+	// Region is where the order ships to, checked by acceptance rules.
+	Region string `json:"region,omitempty"`
+	// Country is the ISO 3166-1 alpha-2 code of the shipping address,
+	// checked against the geo restrictions of the product's category and
+	// the tenant.
+	Country string `json:"country,omitempty"`
+	// ProcessAt schedules the order: it is stored as SCHEDULED and
+	// activated at that time. DeliverAt is the requested delivery date; on
+	// its own it schedules the order the configured lead time before it.
+	ProcessAt *time.Time `json:"processAt,omitempty"`
+	DeliverAt *time.Time `json:"deliverAt,omitempty"`
+	// Reserve holds the stock for the reservation window without payment;
+	// the order is placed once it is confirmed.
+	Reserve bool `json:"reserve,omitempty"`
+	// ClientIP and TenantID are filled in by the handler, not by the
+	// client.
+	ClientIP string `json:"-"`
+	TenantID string `json:"-"`
+	// cartLine is set by CheckoutCart, which prices a cart's lines
+	// together.
+	cartLine *domain.CartLine
 }
 
 type ProductResponse struct {
-	ID    string  `json:"id"`
-	Name  string  `json:"name"`
-	Price float64 `json:"price,string"` // Handle JSON string for number
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Price is sent as a string by older product-service versions and as
+	// a number by newer ones; see UnmarshalJSON.
+	Price float64 `json:"price"`
 	Qty   int     `json:"qty"`
+	// Currency is empty for products priced in the default currency.
+	Currency string `json:"currency"`
+	Category string `json:"category"`
+
+	// priceFormat is the JSON type the price was sent as.
+	priceFormat string
 }
 
 type IPublisher interface {
-	PublishOrderCreated(productId string, quantity int) error
+	PublishOrderCreated(order *repository.Order) error
+	Publish(pattern string, data interface{}) error
+}
+
+// IChannelSource hands out the channel to publish on. It may change when
+// the broker connection is re-established.
+type IChannelSource interface {
+	Channel() (*amqp.Channel, error)
 }
 
 // RabbitMQ Event Publisher
 type RabbitMQPublisher struct {
-	channel *amqp.Channel
+	channels IChannelSource
+	encoder  jsonenc.Encoder
+	instance string
 }
+
 var _ IPublisher = &RabbitMQPublisher{}
 
-func NewRabbitMQPublisher(ch *amqp.Channel) *RabbitMQPublisher {
-	return &RabbitMQPublisher{channel: ch}
+func NewRabbitMQPublisher(channels IChannelSource) *RabbitMQPublisher {
+	return &RabbitMQPublisher{channels: channels, encoder: jsonenc.Fast{}}
 }
 
-func (p *RabbitMQPublisher) PublishOrderCreated(productId string, quantity int) error {
-	q, err := p.channel.QueueDeclare(
-		"order.created", 
-		false,           
-		false,          
-		false,        
-		false,       
-		nil,            
-	)
-	if err != nil {
-		return fmt.Errorf("failed to declare a queue: %w", err)
-	}
+// SetEncoder changes how events are encoded. Call it before publishing.
+func (p *RabbitMQPublisher) SetEncoder(enc jsonenc.Encoder) {
+	p.encoder = enc
+}
 
+// SetInstance names the instance publishing, in the envelope's "instance"
+// field and the message's AppId. Call it before publishing.
+func (p *RabbitMQPublisher) SetInstance(id string) {
+	p.instance = id
+}
+
+func (p *RabbitMQPublisher) PublishOrderCreated(order *repository.Order) error {
+	return p.Publish("order.created", orderCreatedData(order))
+}
+
+func orderCreatedData(order *repository.Order) map[string]interface{} {
 	data := map[string]interface{}{
-		"productId": productId,
-		"quantity":  quantity,
+		"orderId":   order.ID,
+		"productId": order.ProductID,
+		"quantity":  order.Quantity,
+	}
+	if order.CustomerID != "" {
+		data["customerId"] = order.CustomerID
+	}
+	if order.Experiment != "" {
+		data["experiment"] = order.Experiment
+		data["variant"] = order.Variant
 	}
+	return withTags(data, order)
+}
 
+// Publish sends an event to the queue named after its pattern, wrapped in
+// the {id, pattern, data} envelope our consumers expect. The ID is the
+// event's EventID and also its message ID. Sequenced events add their key
+// and sequence, and SetInstance the instance that published them.
+func (p *RabbitMQPublisher) Publish(pattern string, data interface{}) error {
+	return p.PublishBatch([]Event{{Pattern: pattern, Data: data}})
+}
 
-	event := map[string]interface{}{
-		"pattern": "order.created", 
-		"data":    data,
-	}
-	body, err := json.Marshal(event)
+// PublishBatch sends events in order on one channel, declaring each queue
+// once.
+func (p *RabbitMQPublisher) PublishBatch(events []Event) error {
+	ch, err := p.channels.Channel()
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return err
 	}
+	declared := make(map[string]bool)
+	for _, e := range events {
+		if !declared[e.Pattern] {
+			if _, err := ch.QueueDeclare(
+				e.Pattern,
+				false,
+				false,
+				false,
+				false,
+				nil,
+			); err != nil {
+				return fmt.Errorf("failed to declare a queue: %w", err)
+			}
+			declared[e.Pattern] = true
+		}
 
-	return p.channel.Publish(
-		"",   
-		q.Name, 
-		false,  
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
-		})
+		id := e.ID
+		if id == "" {
+			if id, err = EventID(e.Pattern, e.Data); err != nil {
+				return fmt.Errorf("failed to marshal event: %w", err)
+			}
+		}
+		key, sequence, data := eventorder.Unwrap(e.Data)
+		event := map[string]interface{}{
+			"id":      id,
+			"pattern": e.Pattern,
+			"data":    data,
+		}
+		if key != "" {
+			event["key"] = key
+			event["sequence"] = sequence
+		}
+		if p.instance != "" {
+			event["instance"] = p.instance
+		}
+		body, err := p.encoder.Append(nil, event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+
+		err = ch.Publish(
+			"",
+			e.Pattern,
+			false,
+			false,
+			amqp.Publishing{
+				ContentType: "application/json",
+				MessageId:   id,
+				AppId:       p.instance,
+				Body:        body,
+			})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+var (
+	errProductNotFound    = errors.New("product not found")
+	errProductUnavailable = errors.New("product service unavailable")
+	errInsufficientStock  = domain.ErrInsufficientStock
+)
+
+// MaxBulkOrders caps the number of orders accepted by CreateOrders.
+const MaxBulkOrders = 500
+
+// IPurchaseLimiter enforces per-order quantity rules and per-customer
+// purchase caps.
+type IPurchaseLimiter interface {
+	CheckQuantity(productID string, quantity int) error
+	Reserve(ctx context.Context, productID, customerID, orderID string, quantity int) error
+	Release(ctx context.Context, productID, customerID, orderID string, quantity int) error
+}
+
+// IFraudChecker screens an order before it is confirmed.
+type IFraudChecker interface {
+	Check(ctx context.Context, order *repository.Order) (fraud.Verdict, error)
+}
+
+// IBlocklist decides whether an order's customer, IP or product is blocked.
+type IBlocklist interface {
+	Check(ctx context.Context, customerID, ip, productID string) (blocklist.Decision, error)
+}
+
+// IGeoRestrictions decides whether an order may be shipped to its address.
+type IGeoRestrictions interface {
+	Check(order georestrict.Order) *georestrict.Violation
+}
+
+// IBalanceClient redeems gift card and store credit balances.
+type IBalanceClient interface {
+	Redeem(ctx context.Context, kind, reference, orderID string, maxAmount float64) (*balance.Redemption, error)
+	Reverse(ctx context.Context, redemptionID string) error
+}
+
+// ICartClient reads carts from cart-service.
+type ICartClient interface {
+	GetCart(ctx context.Context, cartID string) (*cart.Cart, error)
+}
+
+// IPaymentGateway authorizes order payments up front and captures them
+// once the customer confirms the order.
+type IPaymentGateway interface {
+	CreateIntent(ctx context.Context, orderID string, amount float64, currency string) (*payment.Intent, error)
+	Capture(ctx context.Context, intentID string) error
+	Cancel(ctx context.Context, intentID string) error
+	Refund(ctx context.Context, intentID string) error
+	GetIntent(ctx context.Context, intentID string) (*payment.Intent, error)
+}
+
+// IAcceptanceRules decides whether an order may be accepted.
+type IAcceptanceRules interface {
+	Evaluate(order acceptance.Order) acceptance.Evaluation
+}
+
+// IDegradation reports which degraded modes are active.
+type IDegradation interface {
+	Active(mode string) bool
+}
 
 type OrderService struct {
-	repo              repository.IOrderRepository 
-	cache             repository.IOrderCache      
-	publisher         IPublisher                  
-	productServiceURL string
+	repo                 repository.IOrderRepository
+	cache                repository.IOrderCache
+	publisher            IPublisher
+	productServiceURL    string
+	productEndpoints     IEndpoints
+	productClient        *http.Client
+	products             *cache.ReadThrough[*ProductResponse]
+	productCacheTTL      time.Duration
+	productMissTTL       time.Duration
+	shadow               *productShadow
+	orderLists           *cache.ReadThrough[[]repository.Order]
+	counts               IOrderCounts
+	searchIndex          repository.IOrderSearcher
+	bus                  *events.Bus
+	flags                *featureflags.Client
+	shippingFee          float64
+	experiment           *experiment.Experiment
+	limiter              IPurchaseLimiter
+	fraud                IFraudChecker
+	fraudTimeout         time.Duration
+	fraudFailOpen        bool
+	blocklist            IBlocklist
+	geo                  IGeoRestrictions
+	backorders           bool
+	taxRate              float64
+	discounts            map[string]Discount
+	tenantPricing        map[string]TenantPricing
+	engines              map[string]domain.PricingEngine
+	carts                ICartClient
+	payments             IPaymentGateway
+	paymentTTL           time.Duration
+	maxInstallments      int
+	balances             IBalanceClient
+	rates                currency.RateProvider
+	defaultCurrency      string
+	rounding             *rounding.Policy
+	degradation          IDegradation
+	acceptance           IAcceptanceRules
+	scheduleLeadTime     time.Duration
+	scheduleMaxAhead     time.Duration
+	reservationTTL       time.Duration
+	ledger               IInventoryLedger
+	discrepancies        IDiscrepancyStore
+	reconcileLookback    time.Duration
+	subscriptions        ISubscriptionStore
+	subscriptionRetry    backoff.Policy
+	subscriptionAttempts int
+	deduper              IEventDeduper
+	priceFormat          string
+	logger               *logging.Logger
+	reporter             errreport.Reporter
+}
+
+// Option configures optional OrderService collaborators.
+type Option func(*OrderService)
+
+// WithSearchIndex sets the search backend SearchOrders uses when the
+// opensearch-search flag is on. Otherwise searches go to the repository.
+func WithSearchIndex(searcher repository.IOrderSearcher) Option {
+	return func(s *OrderService) { s.searchIndex = searcher }
+}
+
+// WithLogger sets the logger of the order path. Without it the service
+// logs to slog.Default() and keeps every debug line.
+func WithLogger(logger *logging.Logger) Option {
+	return func(s *OrderService) { s.logger = logger }
+}
+
+// WithReporter reports failed upstream calls and lost events to r.
+func WithReporter(r errreport.Reporter) Option {
+	return func(s *OrderService) { s.reporter = r }
+}
+
+func WithFeatureFlags(flags *featureflags.Client) Option {
+	return func(s *OrderService) { s.flags = flags }
+}
+
+// WithEventBus sets the bus domain events are emitted on, for modules to
+// subscribe to. Without it events go to a bus nobody listens to.
+func WithEventBus(bus *events.Bus) Option {
+	return func(s *OrderService) { s.bus = bus }
+}
+
+// WithShippingFee sets the flat shipping fee added to every order.
+func WithShippingFee(fee float64) Option {
+	return func(s *OrderService) { s.shippingFee = fee }
+}
+
+// WithPricingExperiment enrolls customers into exp. Orders without a
+// customer ID are not enrolled.
+func WithPricingExperiment(exp *experiment.Experiment) Option {
+	return func(s *OrderService) { s.experiment = exp }
+}
+
+func WithPurchaseLimiter(limiter IPurchaseLimiter) Option {
+	return func(s *OrderService) { s.limiter = limiter }
+}
+
+// WithFraudChecker screens every order with checker. Suspicious orders are
+// stored ON_HOLD. When the checker errors or exceeds timeout, failOpen
+// decides whether the order goes through or is held for review.
+func WithFraudChecker(checker IFraudChecker, timeout time.Duration, failOpen bool) Option {
+	return func(s *OrderService) {
+		s.fraud = checker
+		s.fraudTimeout = timeout
+		s.fraudFailOpen = failOpen
+	}
+}
+
+func WithBlocklist(list IBlocklist) Option {
+	return func(s *OrderService) { s.blocklist = list }
+}
+
+// WithGeoRestrictions refuses orders shipped outside the countries and
+// regions their product's category or tenant ships to.
+func WithGeoRestrictions(geo IGeoRestrictions) Option {
+	return func(s *OrderService) { s.geo = geo }
+}
+
+// WithBackorders lets clients opt into backordering when stock is short.
+func WithBackorders(enabled bool) Option {
+	return func(s *OrderService) { s.backorders = enabled }
+}
+
+// WithTaxRate sets the tax rate applied to the discounted subtotal, e.g.
+// 0.11 for 11%.
+func WithTaxRate(rate float64) Option {
+	return func(s *OrderService) { s.taxRate = rate }
+}
+
+func WithDiscountCodes(codes map[string]Discount) Option {
+	return func(s *OrderService) { s.discounts = codes }
+}
+
+// WithTenantPricing sets per-tenant pricing rules on top of the tax rate,
+// shipping fee and discount codes every tenant gets.
+func WithTenantPricing(rules map[string]TenantPricing) Option {
+	return func(s *OrderService) { s.tenantPricing = rules }
 }
 
-func NewOrderService(repo repository.IOrderRepository, cache repository.IOrderCache, pub IPublisher, productURL string) *OrderService {
-	return &OrderService{
+// WithPricingEngine prices the orders of tenantID with engine instead of
+// the standard pipeline. An empty tenantID replaces the default engine.
+func WithPricingEngine(tenantID string, engine domain.PricingEngine) Option {
+	return func(s *OrderService) {
+		if s.engines == nil {
+			s.engines = map[string]domain.PricingEngine{}
+		}
+		s.engines[tenantID] = engine
+	}
+}
+
+// WithAcceptanceRules refuses orders that fail a business rule.
+func WithAcceptanceRules(rules IAcceptanceRules) Option {
+	return func(s *OrderService) { s.acceptance = rules }
+}
+
+func WithCartClient(client ICartClient) Option {
+	return func(s *OrderService) { s.carts = client }
+}
+
+// WithPaymentGateway makes new orders wait for payment: each order gets a
+// payment intent and stays AWAITING_PAYMENT until it is confirmed. ttl is
+// used when the gateway does not report when the intent expires.
+func WithPaymentGateway(gateway IPaymentGateway, ttl time.Duration) Option {
+	return func(s *OrderService) {
+		s.payments = gateway
+		s.paymentTTL = ttl
+	}
+}
+
+// WithInstallments lets customers split an order into up to n monthly
+// installments. Installment orders are paid through installment events
+// rather than a payment intent.
+func WithInstallments(n int) Option {
+	return func(s *OrderService) { s.maxInstallments = n }
+}
+
+func WithBalanceClient(client IBalanceClient) Option {
+	return func(s *OrderService) { s.balances = client }
+}
+
+// WithCurrency sets the currency of products that do not declare one and
+// the exchange rates used to convert prices into the customer's currency.
+// rates may be nil when conversion is not offered.
+func WithCurrency(defaultCurrency string, rates currency.RateProvider) Option {
+	return func(s *OrderService) {
+		s.defaultCurrency = currency.Normalize(defaultCurrency)
+		s.rates = rates
+	}
+}
+
+// WithRoundingPolicy sets how subtotals, discounts, tax and totals are
+// rounded per tenant and currency. Without it amounts are rounded half-up
+// to two decimals.
+func WithRoundingPolicy(policy *rounding.Policy) Option {
+	return func(s *OrderService) { s.rounding = policy }
+}
+
+// WithDegradation lets the service react to degraded modes, e.g. accepting
+// orders as PENDING_VALIDATION while product-service is down.
+func WithDegradation(d IDegradation) Option {
+	return func(s *OrderService) { s.degradation = d }
+}
+
+// WithProductClient sets the HTTP client for product-service calls, e.g.
+// one presenting a client certificate.
+func WithProductClient(client *http.Client) Option {
+	return func(s *OrderService) { s.productClient = client }
+}
+
+// IEndpoints hands out the instances of an upstream service and learns
+// which ones fail.
+type IEndpoints interface {
+	Pick() (string, error)
+	Report(endpoint string, err error)
+}
+
+// WithProductEndpoints spreads product-service calls across the endpoints
+// e picks instead of the URL given to NewOrderService.
+func WithProductEndpoints(e IEndpoints) Option {
+	return func(s *OrderService) { s.productEndpoints = e }
+}
+
+// WithProductCache keeps product-service answers in memory for ttl, and
+// unknown products for missTTL. Stock levels are then up to ttl old, so
+// order creation may accept an order product-service would refuse.
+func WithProductCache(ttl, missTTL time.Duration) Option {
+	return func(s *OrderService) {
+		s.productCacheTTL = ttl
+		s.productMissTTL = missTTL
+	}
+}
+
+func NewOrderService(repo repository.IOrderRepository, cache repository.IOrderCache, pub IPublisher, productURL string, opts ...Option) *OrderService {
+	s := &OrderService{
 		repo:              repo,
 		cache:             cache,
 		publisher:         pub,
 		productServiceURL: productURL,
+		productClient:     http.DefaultClient,
+		bus:               events.NewBus(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	if s.logger == nil {
+		s.logger = logging.New(nil, 1)
+	}
+	s.initCaches()
+	s.initPricing()
+	return s
+}
+
+// initCaches puts read-through caches in front of product-service and the
+// orders of a product.
+func (s *OrderService) initCaches() {
+	var productStore cache.Store[*ProductResponse]
+	if s.productCacheTTL > 0 {
+		productStore = cache.NewMemoryStore[*ProductResponse]()
+	}
+	s.products = cache.NewReadThrough(productStore, s.lookupProduct, cache.Options{
+		TTL:         s.productCacheTTL,
+		NegativeTTL: s.productMissTTL,
+		NotFound:    func(err error) bool { return errors.Is(err, errProductNotFound) },
+	})
+	s.orderLists = cache.NewReadThrough[[]repository.Order](orderListStore{s.cache}, func(ctx context.Context, productID string) ([]repository.Order, error) {
+		s.logger.Debug(ctx).Str("product_id", productID).Msg("fetching orders from DB")
+		return s.repo.GetByProductID(productID)
+	}, cache.Options{})
 }
 
+func (s *OrderService) fetchProductInfo(ctx context.Context, productID string) (*ProductResponse, error) {
+	memo := memoFromContext(ctx)
+	if memo == nil {
+		return s.requestProductInfo(ctx, productID)
+	}
+	memo.mu.Lock()
+	defer memo.mu.Unlock()
+	if l, ok := memo.lookups[productID]; ok {
+		return l.product, l.err
+	}
+	product, err := s.requestProductInfo(ctx, productID)
+	memo.lookups[productID] = productLookup{product: product, err: err}
+	return product, err
+}
 
-func (s *OrderService) fetchProductInfo(productID string) (*ProductResponse, error) {
-	url := fmt.Sprintf("%s/products/%s", s.productServiceURL, productID)
-	resp, err := http.Get(url)
+// requestProductInfo shares one product-service call between concurrent
+// lookups of the same product and, with WithProductCache, serves recent
+// answers from memory.
+func (s *OrderService) requestProductInfo(ctx context.Context, productID string) (*ProductResponse, error) {
+	return s.products.Get(ctx, productID)
+}
+
+func (s *OrderService) callProductService(ctx context.Context, productID string) (*ProductResponse, error) {
+	base := s.productServiceURL
+	if s.productEndpoints != nil {
+		var err error
+		if base, err = s.productEndpoints.Pick(); err != nil {
+			return nil, fmt.Errorf("failed to call product service: %w", err)
+		}
+	}
+	url := fmt.Sprintf("%s/products/%s", base, productID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.productClient.Do(req)
 	if err != nil {
+		s.reportProductEndpoint(ctx, base, err)
 		return nil, fmt.Errorf("failed to call product service: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		s.reportProductEndpoint(ctx, base, fmt.Errorf("status %s", resp.Status))
+	} else {
+		s.reportProductEndpoint(ctx, base, nil)
+	}
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errProductNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("product service returned status: %s", resp.Status)
 	}
@@ -112,66 +615,982 @@ func (s *OrderService) fetchProductInfo(productID string) (*ProductResponse, err
 	if err := json.NewDecoder(resp.Body).Decode(&product); err != nil {
 		return nil, fmt.Errorf("failed to decode product response: %w", err)
 	}
+	if err := s.checkPriceFormat(&product); err != nil {
+		return nil, fmt.Errorf("failed to decode product response: %w", err)
+	}
 	return &product, nil
 }
 
-func (s *OrderService) CreateOrder(req CreateOrderRequest) (*repository.Order, error) {
+// reportProductEndpoint tells the endpoints how base answered. Calls the
+// caller gave up on say nothing about the endpoint.
+func (s *OrderService) reportProductEndpoint(ctx context.Context, base string, err error) {
+	if s.productEndpoints == nil || ctx.Err() != nil {
+		return
+	}
+	s.productEndpoints.Report(base, err)
+}
+
+func (s *OrderService) CreateOrder(ctx context.Context, req CreateOrderRequest) (*repository.Order, error) {
+	if req.ID != "" {
+		id, err := parseOrderID(req.ID)
+		if err != nil {
+			return nil, err
+		}
+		req.ID = id
+		if order, err := s.resubmitted(req); order != nil || err != nil {
+			return order, err
+		}
+	}
+	order, err := s.buildOrder(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.ID != "" {
+		order.RequestHash = requestHash(req)
+	}
+
+	if err := s.requestPayment(ctx, order); err != nil {
+		s.abandon(*order)
+		return nil, err
+	}
+
+	if err := s.repo.Create(order); err != nil {
+		s.abandon(*order)
+		if errors.Is(err, repository.ErrOrderExists) && req.ID != "" {
+			// A concurrent submission of the same ID won.
+			if existing, err := s.resubmitted(req); existing != nil || err != nil {
+				return existing, err
+			}
+		}
+		return nil, err
+	}
+
+	s.appendToCache(*order)
+	s.holdStock(ctx, order)
+	s.announce(ctx, order)
+	s.emit(events.OrderCreated, order)
+	return order, nil
+}
+
+// CreateOrders validates every request before inserting anything and stores
+// the resulting orders in a single batch.
+func (s *OrderService) CreateOrders(ctx context.Context, reqs []CreateOrderRequest) ([]repository.Order, error) {
+	if len(reqs) == 0 {
+		return nil, errors.New("no orders given")
+	}
+	if len(reqs) > MaxBulkOrders {
+		return nil, fmt.Errorf("too many orders in one request, max is %d", MaxBulkOrders)
+	}
+	for i, req := range reqs {
+		if req.ID != "" {
+			return nil, &Error{Code: CodeInvalidOrderID, Message: fmt.Sprintf("order %d: order IDs can only be chosen when creating a single order", i)}
+		}
+	}
+	return s.createOrders(ctx, reqs, "")
+}
+
+type CheckoutCartRequest struct {
+	CartID         string `json:"cartId" binding:"required"`
+	DiscountCode   string `json:"discountCode,omitempty"`
+	GiftCardCode   string `json:"giftCardCode,omitempty"`
+	UseStoreCredit bool   `json:"useStoreCredit,omitempty"`
+	Currency       string `json:"currency,omitempty"`
+	Region         string `json:"region,omitempty"`
+	Country        string `json:"country,omitempty"`
+	ClientIP       string `json:"-"`
+	TenantID       string `json:"-"`
+}
+
+type CheckoutResult struct {
+	CartID string             `json:"cartId"`
+	Orders []repository.Order `json:"orders"`
+	Total  float64            `json:"total"`
+}
+
+// CheckoutCart turns every line of a cart into an order. All lines are
+// validated and priced first and stored in one transaction, so a cart is
+// either fully checked out or not at all. Shipping and a discount code's
+// fixed amount are charged once for the cart, split across its lines.
+func (s *OrderService) CheckoutCart(ctx context.Context, req CheckoutCartRequest) (*CheckoutResult, error) {
+	if s.carts == nil {
+		return nil, errors.New("cart checkout is not configured")
+	}
+	ctx = withProductMemo(ctx)
+
+	c, err := s.carts.GetCart(ctx, req.CartID)
+	if errors.Is(err, cart.ErrCartNotFound) {
+		return nil, &Error{Code: CodeCartNotFound, Message: err.Error()}
+	} else if err != nil {
+		s.reportUpstream(ctx, "cart", err, map[string]string{"cart_id": req.CartID})
+		return nil, err
+	}
+	if len(c.Items) == 0 {
+		return nil, &Error{Code: CodeCartEmpty, Message: "cart is empty"}
+	}
+	if len(c.Items) > MaxBulkOrders {
+		return nil, fmt.Errorf("too many items in cart, max is %d", MaxBulkOrders)
+	}
+
+	// The repository refuses a second checkout of the cart; looking first
+	// saves pricing a cart that was checked out long ago.
+	existing, err := s.repo.Search(ctx, repository.OrderFilter{CartID: req.CartID}, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, cartCheckedOut()
+	}
 
-	product, err := s.fetchProductInfo(req.ProductID)
+	line := s.cartSubtotals(ctx, c.Items)
+	reqs := make([]CreateOrderRequest, 0, len(c.Items))
+	for i, item := range c.Items {
+		reqs = append(reqs, CreateOrderRequest{
+			ProductID:      item.ProductID,
+			Quantity:       item.Quantity,
+			CustomerID:     c.CustomerID,
+			DiscountCode:   req.DiscountCode,
+			GiftCardCode:   req.GiftCardCode,
+			UseStoreCredit: req.UseStoreCredit,
+			Currency:       req.Currency,
+			Region:         req.Region,
+			Country:        req.Country,
+			ClientIP:       req.ClientIP,
+			TenantID:       req.TenantID,
+			cartLine:       &domain.CartLine{Subtotals: line, Index: i},
+		})
+	}
+
+	orders, err := s.createOrders(ctx, reqs, req.CartID)
 	if err != nil {
-		log.Printf("Error fetching product %s: %v", req.ProductID, err)
-		return nil, errors.New("product not found or service unavailable")
+		return nil, err
+	}
+
+	result := &CheckoutResult{CartID: req.CartID, Orders: orders}
+	orderIDs := make([]string, 0, len(orders))
+	for _, o := range orders {
+		result.Total += o.TotalPrice
+		orderIDs = append(orderIDs, o.ID)
+	}
+	if len(orders) > 0 {
+		result.Total = s.roundingFor(&orders[0]).Round(result.Total)
 	}
 
+	s.publish(ctx, "cart.checked_out", cartCheckedOutData(result, c.CustomerID, orderIDs))
+	return result, nil
+}
+
+func cartCheckedOut() error {
+	return &Error{Code: CodeCartCheckedOut, Message: "cart was already checked out"}
+}
 
-	if product.Qty < req.Quantity {
-		return nil, errors.New("insufficient stock")
+// cartSubtotals weighs the lines of a cart by list price. A line whose
+// product cannot be fetched weighs nothing; pricing it fails anyway.
+func (s *OrderService) cartSubtotals(ctx context.Context, items []cart.Item) []float64 {
+	subtotals := make([]float64, len(items))
+	for i, item := range items {
+		if product, err := s.fetchProductInfo(ctx, item.ProductID); err == nil {
+			subtotals[i] = product.Price * float64(item.Quantity)
+		}
+	}
+	return subtotals
+}
+
+func cartCheckedOutData(result *CheckoutResult, customerID string, orderIDs []string) map[string]interface{} {
+	return map[string]interface{}{
+		"cartId":     result.CartID,
+		"customerId": customerID,
+		"orderIds":   orderIDs,
+		"total":      result.Total,
+	}
+}
+
+func (s *OrderService) createOrders(ctx context.Context, reqs []CreateOrderRequest, cartID string) ([]repository.Order, error) {
+	orders := make([]repository.Order, 0, len(reqs))
+	for i, req := range reqs {
+		order, err := s.buildOrder(ctx, req)
+		if err != nil {
+			s.abandon(orders...)
+			return nil, fmt.Errorf("order %d: %w", i, err)
+		}
+		order.CartID = cartID
+		orders = append(orders, *order)
+	}
+
+	for i := range orders {
+		if err := s.requestPayment(ctx, &orders[i]); err != nil {
+			s.abandon(orders...)
+			return nil, fmt.Errorf("order %d: %w", i, err)
+		}
+	}
+
+	if err := s.repo.CreateBatch(orders); err != nil {
+		s.abandon(orders...)
+		if errors.Is(err, repository.ErrCartCheckedOut) {
+			return nil, cartCheckedOut()
+		}
+		return nil, err
+	}
+
+	s.appendToCache(orders...)
+	for i := range orders {
+		s.holdStock(ctx, &orders[i])
+		s.announce(ctx, &orders[i])
+		s.emit(events.OrderCreated, &orders[i])
+	}
+	return orders, nil
+}
+
+func (s *OrderService) buildOrder(ctx context.Context, req CreateOrderRequest) (*repository.Order, error) {
+	if err := s.checkReservation(req); err != nil {
+		return nil, err
+	}
+	processAt, err := s.processTime(req.ProcessAt, req.DeliverAt)
+	if err != nil {
+		return nil, err
+	}
+	if processAt != nil {
+		return s.buildScheduledOrder(ctx, req, *processAt)
+	}
+	if s.degraded(degrade.ModePendingValidation) {
+		return s.buildUnvalidatedOrder(ctx, req)
+	}
+	quote, decision, err := s.quote(ctx, req)
+	if err != nil {
+		return nil, err
 	}
 
 	order := &repository.Order{
-		ID:         uuid.New().String(),
+		ID:         newOrderID(req),
 		ProductID:  req.ProductID,
-		TotalPrice: product.Price * float64(req.Quantity),
+		CustomerID: req.CustomerID,
+		TenantID:   req.TenantID,
 		Quantity:   req.Quantity,
-		Status:     "PENDING",
-		CreatedAt:  time.Now(),
+		Region:     req.Region,
+		Country:    req.Country,
+		Status:     repository.StatusPending,
+		DeliverAt:  req.DeliverAt,
+		CreatedAt:  time.Now().UTC(),
+	}
+	applyQuote(order, quote)
+	if quote.Backorder {
+		if req.Reserve {
+			return nil, errInsufficientStock
+		}
+		if err := mutate(order, (*domain.Order).Backorder); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := s.repo.Create(order); err != nil {
+	if !decision.CustomerAllowed {
+		s.screen(ctx, order)
+	}
+	if req.Reserve {
+		if err := s.reserve(order); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.reserveLimits(ctx, order); err != nil {
+		return nil, err
+	}
+
+	if err := s.redeemTenders(ctx, order, req); err != nil {
+		s.releaseLimits(*order)
+		return nil, err
+	}
+	if due := amountDue(order); req.Installments > 1 && due > 0 {
+		order.Installments = splitInstallments(order.ID, due, req.Installments, order.CreatedAt, s.roundingFor(order))
+	}
+
+	return order, nil
+}
+
+// QuoteOrder runs every check and price calculation of CreateOrder without
+// storing anything or reserving purchase limits.
+func (s *OrderService) QuoteOrder(ctx context.Context, req CreateOrderRequest) (*Quote, error) {
+	quote, _, err := s.quote(ctx, req)
+	return quote, err
+}
+
+func (s *OrderService) quote(ctx context.Context, req CreateOrderRequest) (*Quote, blocklist.Decision, error) {
+	quote, decision, product, err := s.priceOrder(ctx, req)
+	if err != nil {
+		return nil, decision, err
+	}
+	if failed := s.evaluate(req, product, quote).Failed(); failed != nil {
+		return nil, decision, &Error{Code: CodeOrderNotAccepted, Message: failed.Rule + ": " + failed.Reason}
+	}
+	return quote, decision, nil
+}
+
+// OrderExplanation is how the acceptance rules judge an order.
+type OrderExplanation struct {
+	Quote *Quote `json:"quote"`
+	acceptance.Evaluation
+}
+
+// ExplainOrder prices the order like QuoteOrder and reports the outcome of
+// every acceptance rule instead of stopping at the first that fails.
+func (s *OrderService) ExplainOrder(ctx context.Context, req CreateOrderRequest) (*OrderExplanation, error) {
+	quote, _, product, err := s.priceOrder(ctx, req)
+	if err != nil {
 		return nil, err
 	}
+	return &OrderExplanation{Quote: quote, Evaluation: s.evaluate(req, product, quote)}, nil
+}
+
+func (s *OrderService) evaluate(req CreateOrderRequest, product *ProductResponse, quote *Quote) acceptance.Evaluation {
+	if s.acceptance == nil {
+		return acceptance.Evaluation{Accepted: true, Results: []acceptance.Result{}}
+	}
+	return s.acceptance.Evaluate(acceptance.Order{
+		TenantID:  req.TenantID,
+		ProductID: req.ProductID,
+		Category:  product.Category,
+		Region:    req.Region,
+		Total:     quote.Total,
+		Currency:  quote.Currency,
+	})
+}
+
+// priceOrder runs every check of quote except the acceptance rules.
+func (s *OrderService) priceOrder(ctx context.Context, req CreateOrderRequest) (*Quote, blocklist.Decision, *ProductResponse, error) {
+	if s.limiter != nil {
+		if err := s.limiter.CheckQuantity(req.ProductID, req.Quantity); err != nil {
+			return nil, blocklist.Decision{}, nil, &Error{Code: CodeQuantityOutOfRange, Message: err.Error()}
+		}
+	}
+	if err := s.checkInstallments(req.Installments); err != nil {
+		return nil, blocklist.Decision{}, nil, err
+	}
+	if err := s.checkTenders(req); err != nil {
+		return nil, blocklist.Decision{}, nil, err
+	}
+
+	decision, err := s.checkBlocklist(ctx, req)
+	if err != nil {
+		return nil, decision, nil, err
+	}
+
+	product, err := s.fetchProductInfo(ctx, req.ProductID)
+	if err != nil {
+		s.logger.Warn(ctx).Str("product_id", req.ProductID).Err(err).Msg("failed to fetch product")
+		if ctx.Err() != nil {
+			return nil, decision, nil, ctx.Err()
+		}
+		if errors.Is(err, errProductNotFound) {
+			return nil, decision, nil, err
+		}
+		s.reportUpstream(ctx, "product-service", err, map[string]string{"product_id": req.ProductID})
+		return nil, decision, nil, errProductUnavailable
+	}
+	if err := s.checkShipping(req, product); err != nil {
+		return nil, decision, nil, err
+	}
+
+	backorder, err := domain.CheckStock(s.availableStock(ctx, product), req.Quantity, s.backorders && req.AllowBackorder)
+	if err != nil {
+		return nil, decision, nil, err
+	}
+
+	quote, err := s.price(product, req)
+	if err != nil {
+		return nil, decision, nil, err
+	}
+	quote.Backorder = backorder
+
+	if err := s.convert(ctx, quote, req.Currency, req.TenantID); err != nil {
+		return nil, decision, nil, err
+	}
+	return quote, decision, product, nil
+}
+
+var blockedCodes = map[string]string{
+	blocklist.KindCustomer: CodeCustomerBlocked,
+	blocklist.KindIP:       CodeIPBlocked,
+	blocklist.KindProduct:  CodeProductBlocked,
+}
+
+// checkBlocklist rejects blocked orders. Lookup failures are logged and the
+// order is let through.
+func (s *OrderService) checkBlocklist(ctx context.Context, req CreateOrderRequest) (blocklist.Decision, error) {
+	if s.blocklist == nil {
+		return blocklist.Decision{}, nil
+	}
+	decision, err := s.blocklist.Check(ctx, req.CustomerID, req.ClientIP, req.ProductID)
+	if err != nil {
+		s.logger.Warn(ctx).Err(err).Msg("blocklist check failed, allowing order")
+		return blocklist.Decision{}, nil
+	}
+	if decision.BlockedBy != "" {
+		return decision, &Error{Code: blockedCodes[decision.BlockedBy], Message: decision.BlockedBy + " is blocked"}
+	}
+	return decision, nil
+}
+
+var shippingCodes = map[string]string{
+	georestrict.ReasonAddressRequired: CodeAddressRequired,
+	georestrict.ReasonCountry:         CodeCountryNotAllowed,
+	georestrict.ReasonRegion:          CodeRegionNotAllowed,
+}
+
+// checkShipping rejects orders shipped where their product's category or
+// tenant does not ship to.
+func (s *OrderService) checkShipping(req CreateOrderRequest, product *ProductResponse) error {
+	if s.geo == nil {
+		return nil
+	}
+	v := s.geo.Check(georestrict.Order{TenantID: req.TenantID, Category: product.Category, Country: req.Country, Region: req.Region})
+	if v != nil {
+		return &Error{Code: shippingCodes[v.Reason], Message: v.Error()}
+	}
+	return nil
+}
+
+func (s *OrderService) screen(ctx context.Context, order *repository.Order) {
+	if s.fraud == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.fraudTimeout)
+	defer cancel()
+
+	verdict, err := s.fraud.Check(ctx, order)
+	if err != nil {
+		s.logger.Warn(ctx).Str("order_id", order.ID).Err(err).Msg("fraud check failed")
+		if !s.fraudFailOpen {
+			s.hold(ctx, order, "fraud check unavailable")
+		}
+		return
+	}
+	if verdict.Suspicious {
+		s.hold(ctx, order, verdict.Reason)
+	}
+}
+
+func (s *OrderService) hold(ctx context.Context, order *repository.Order, reason string) {
+	err := mutate(order, func(o *domain.Order) error { return o.Hold(reason) })
+	if err != nil {
+		s.logger.Warn(ctx).Str("order_id", order.ID).Err(err).Msg("cannot hold order")
+	}
+}
+
+// requestPayment creates a payment intent for an order that would otherwise
+// go straight to PENDING; for an installment order it is for the first
+// installment. Held and backordered orders are not charged up front, and
+// neither are orders fully paid by gift card or store credit.
+func (s *OrderService) requestPayment(ctx context.Context, order *repository.Order) error {
+	due := amountDue(order)
+	if s.payments == nil || order.Status != repository.StatusPending || due <= 0 {
+		return nil
+	}
+	amount, cur := s.intentAmount(order)
+	intent, err := s.payments.CreateIntent(ctx, order.ID, amount, cur)
+	if errors.Is(err, payment.ErrPaymentDeclined) {
+		return &Error{Code: CodePaymentDeclined, Message: err.Error()}
+	} else if err != nil {
+		s.logger.Error(ctx).Str("order_id", order.ID).Err(err).Msg("failed to create payment intent")
+		s.reportUpstream(ctx, "payment", err, map[string]string{"order_id": order.ID})
+		return errors.New("payment service unavailable")
+	}
+
+	expiresAt := intent.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(s.paymentTTL)
+	}
+	if err := mutate(order, func(o *domain.Order) error { return o.AwaitPayment(expiresAt) }); err != nil {
+		return err
+	}
+	order.PaymentIntentID = intent.ID
+	order.PaymentClientSecret = intent.ClientSecret
+	return nil
+}
+
+// intentAmount is what an order's payment intent is for: the amount due,
+// or the first installment, converted to the currency the customer pays
+// in and rounded the way the quote's converted total was.
+func (s *OrderService) intentAmount(order *repository.Order) (float64, string) {
+	due := amountDue(order)
+	if first := firstInstallment(order); first != nil {
+		due = first.Amount
+	}
+	if order.ConvertedCurrency != "" {
+		round := s.rounding.For(order.TenantID, order.ConvertedCurrency).Round
+		return round(due * order.ExchangeRate), order.ConvertedCurrency
+	}
+	return due, order.Currency
+}
+
+// abandon undoes the side effects of building orders that end up not
+// being stored. Like the other compensating calls it does not use the
+// request context, so cleanup still runs after a request times out.
+func (s *OrderService) abandon(orders ...repository.Order) {
+	s.releaseLimits(orders...)
+	s.reverseRedemptions(orders...)
+	s.cancelPayments(orders...)
+}
+
+func (s *OrderService) cancelPayments(orders ...repository.Order) {
+	if s.payments == nil {
+		return
+	}
+	for _, o := range orders {
+		if o.PaymentIntentID == "" {
+			continue
+		}
+		if err := s.payments.Cancel(context.Background(), o.PaymentIntentID); err != nil {
+			s.logger.Error(context.Background()).Str("order_id", o.ID).Err(err).Msg("failed to cancel payment intent")
+		}
+	}
+}
+
+// reserveLimits counts the order against its customer's purchase limit.
+// Limiter failures are logged and the order is let through.
+func (s *OrderService) reserveLimits(ctx context.Context, order *repository.Order) error {
+	if s.limiter == nil {
+		return nil
+	}
+	err := s.limiter.Reserve(ctx, order.ProductID, order.CustomerID, order.ID, order.Quantity)
+	if errors.Is(err, limits.ErrCustomerLimitExceeded) {
+		return &Error{Code: CodePurchaseLimitExceeded, Message: err.Error()}
+	} else if err != nil {
+		s.logger.Warn(ctx).Str("order_id", order.ID).Err(err).Msg("purchase limit check failed, allowing order")
+	}
+	return nil
+}
+
+func (s *OrderService) releaseLimits(orders ...repository.Order) {
+	if s.limiter == nil {
+		return
+	}
+	for _, o := range orders {
+		if err := s.limiter.Release(context.Background(), o.ProductID, o.CustomerID, o.ID, o.Quantity); err != nil {
+			s.logger.Error(context.Background()).Str("order_id", o.ID).Err(err).Msg("failed to release purchase limit")
+		}
+	}
+}
+
+// announce publishes order.created, or order.flagged / order.backordered /
+// order.reserved / order.scheduled for orders that must not consume stock
+// yet. Orders awaiting payment or validation are announced once confirmed
+// or validated.
+func (s *OrderService) announce(ctx context.Context, order *repository.Order) {
+	switch pattern, data := announcement(order); pattern {
+	case "":
+	case "order.created":
+		s.publishOrderCreated(ctx, order)
+	default:
+		s.publish(ctx, pattern, data)
+	}
+}
+
+// announcement is the event announce publishes for order, or an empty
+// pattern if there is none yet.
+func announcement(order *repository.Order) (string, interface{}) {
+	switch order.Status {
+	case repository.StatusAwaitingPayment, repository.StatusPendingValidation:
+		return "", nil
+	case repository.StatusReserved:
+		return "order.reserved", withTags(map[string]interface{}{
+			"orderId":       order.ID,
+			"productId":     order.ProductID,
+			"quantity":      order.Quantity,
+			"reservedUntil": order.ReservedUntil,
+		}, order)
+	case repository.StatusScheduled:
+		return "order.scheduled", withTags(map[string]interface{}{
+			"orderId":   order.ID,
+			"productId": order.ProductID,
+			"processAt": order.ProcessAt,
+		}, order)
+	case repository.StatusBackordered:
+		return "order.backordered", withTags(map[string]interface{}{
+			"orderId":   order.ID,
+			"productId": order.ProductID,
+			"quantity":  order.Quantity,
+		}, order)
+	case repository.StatusOnHold:
+		return "order.flagged", withTags(map[string]interface{}{
+			"orderId":    order.ID,
+			"productId":  order.ProductID,
+			"customerId": order.CustomerID,
+			"reason":     order.HoldReason,
+		}, order)
+	}
+	return "order.created", orderCreatedData(order)
+}
+
+// orderRefData is the payload of events that only identify the order:
+// order.payment_expired, order.paid, order.cancelled and order.rejected
+// after review.
+func orderRefData(order *repository.Order) map[string]interface{} {
+	return withTags(map[string]interface{}{
+		"orderId":   order.ID,
+		"productId": order.ProductID,
+	}, order)
+}
+
+// orderRejectedData is order.rejected for orders that failed validation
+// or activation.
+func orderRejectedData(order *repository.Order) map[string]interface{} {
+	return withTags(map[string]interface{}{
+		"orderId":   order.ID,
+		"productId": order.ProductID,
+		"reason":    order.HoldReason,
+	}, order)
+}
+
+// withTags adds the order's tags to an event payload, so consumers can
+// pick out the orders of a campaign or incident.
+func withTags(data map[string]interface{}, order *repository.Order) map[string]interface{} {
+	if tags := order.TagNames(); tags != nil {
+		data["tags"] = tags
+	}
+	return data
+}
 
+func (s *OrderService) publish(ctx context.Context, pattern string, data interface{}) {
+	if err := s.publisher.Publish(pattern, data); err != nil {
+		s.logger.Error(ctx).Str("pattern", pattern).Err(err).Msg("failed to publish event")
+		errreport.Capture(ctx, s.reporter, err, "service", errreport.ClassPublish, map[string]string{"pattern": pattern})
+	}
+}
 
-	if err := s.publisher.PublishOrderCreated(order.ProductID, order.Quantity); err != nil {
-		log.Printf("Failed to publish order.created event: %v", err)
+func (s *OrderService) publishOrderCreated(ctx context.Context, order *repository.Order) {
+	if err := s.publisher.PublishOrderCreated(order); err != nil {
+		s.logger.Error(ctx).Str("order_id", order.ID).Err(err).Msg("failed to publish order.created event")
+		errreport.Capture(ctx, s.reporter, err, "service", errreport.ClassPublish, map[string]string{
+			"pattern":  "order.created",
+			"order_id": order.ID,
+		})
 	} else {
-		log.Printf("Published order.created event for product %s", order.ProductID)
+		s.logger.Debug(ctx).Str("order_id", order.ID).Str("product_id", order.ProductID).Msg("published order.created event")
+	}
+	s.emit(events.OrderPlaced, order)
+}
+
+// reportUpstream reports a failed call to an upstream service. Calls
+// cancelled by the caller are not failures of the upstream.
+func (s *OrderService) reportUpstream(ctx context.Context, upstream string, err error, tags map[string]string) {
+	if ctx.Err() != nil {
+		return
+	}
+	tags["upstream"] = upstream
+	errreport.Capture(ctx, s.reporter, err, "service", errreport.ClassUpstream, tags)
+}
+
+// OrderDetail is an order plus derived information for the detail view.
+type OrderDetail struct {
+	repository.Order
+	BackorderPosition int     `json:"backorderPosition,omitempty"`
+	RemainingAmount   float64 `json:"remainingAmount,omitempty"`
+	// StatusLabel is the localized status, filled in by the handler.
+	StatusLabel string `json:"statusLabel,omitempty"`
+	// Links are the actions open to the caller, filled in by the handler.
+	Links links.Links `json:"_links,omitempty"`
+}
+
+func (s *OrderService) GetOrder(ctx context.Context, id string) (*OrderDetail, error) {
+	order, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+
+	detail := &OrderDetail{Order: *order}
+	if order.Status == repository.StatusBackordered {
+		if detail.BackorderPosition, err = s.repo.BackorderPosition(order); err != nil {
+			return nil, err
+		}
+	}
+	if detail.Installments, err = s.repo.GetInstallments(order.ID); err != nil {
+		return nil, err
+	}
+	if len(detail.Installments) > 0 {
+		detail.RemainingAmount = s.roundingFor(order).Round(order.TotalPrice - order.PaidAmount)
+	}
+	return detail, nil
+}
+
+// ConfirmBackorders moves the product's backorders to PENDING in FIFO order
+// for as long as current stock covers them. It stops at the first order
+// that does not fit so later, smaller orders cannot jump the queue.
+func (s *OrderService) ConfirmBackorders(ctx context.Context, productID string) (int, error) {
+	backorders, err := s.repo.GetBackorders(productID)
+	if err != nil || len(backorders) == 0 {
+		return 0, err
+	}
+
+	product, err := s.fetchProductInfo(ctx, productID)
+	if err != nil {
+		return 0, err
+	}
+
+	available := s.availableStock(ctx, product)
+	confirmed := 0
+	for i := range backorders {
+		order := &backorders[i]
+		if order.Quantity > available {
+			break
+		}
+		from := order.Status
+		if err := mutate(order, (*domain.Order).FulfilBackorder); err != nil {
+			continue
+		}
+		err := s.repo.UpdateStatus(order.ID, from, order.Status)
+		if errors.Is(err, repository.ErrStatusConflict) {
+			continue
+		} else if err != nil {
+			return confirmed, err
+		}
+		available -= order.Quantity
+		confirmed++
+		s.publishOrderCreated(ctx, order)
+		s.emit(events.OrderUpdated, order)
 	}
 
+	if confirmed > 0 {
+		if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(productID)); err != nil {
+			log.Printf("Redis error on delete: %v", err)
+		}
+		log.Printf("Confirmed %d backorders for product %s", confirmed, productID)
+	}
+	return confirmed, nil
+}
+
+// ConfirmOrder captures the payment of an order awaiting payment and
+// releases it into the normal flow. For an installment order the capture
+// pays the first installment.
+func (s *OrderService) ConfirmOrder(ctx context.Context, id string) (*repository.Order, error) {
+	order, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	if order.Status == repository.StatusReserved {
+		return s.confirmReservation(ctx, order)
+	}
+	if s.payments == nil {
+		return nil, errors.New("payment intents are not configured")
+	}
+	err = aggregate(order).CanConfirmPayment(time.Now())
+	if errors.Is(err, domain.ErrInvalidTransition) {
+		return nil, &Error{Code: CodeOrderNotAwaitingPayment, Message: "order is not awaiting payment"}
+	} else if errors.Is(err, domain.ErrPaymentExpired) {
+		return nil, &Error{Code: CodePaymentExpired, Message: err.Error()}
+	}
+
+	err = s.payments.Capture(ctx, order.PaymentIntentID)
+	if errors.Is(err, payment.ErrPaymentDeclined) {
+		return nil, &Error{Code: CodePaymentDeclined, Message: err.Error()}
+	} else if err != nil {
+		s.reportUpstream(ctx, "payment", err, map[string]string{"order_id": order.ID})
+		return nil, err
+	}
+
+	from := order.Status
+	if err := mutate(order, (*domain.Order).ConfirmPayment); err != nil {
+		return nil, err
+	}
+	err = s.repo.UpdateStatus(id, from, order.Status)
+	if errors.Is(err, repository.ErrStatusConflict) {
+		s.refundLostCapture(ctx, order)
+		return nil, &Error{Code: CodeOrderNotAwaitingPayment, Message: "order is not awaiting payment"}
+	} else if err != nil {
+		return nil, err
+	}
+
+	s.payFirstInstallment(ctx, order)
+
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.publishOrderCreated(ctx, order)
+	s.emit(events.OrderUpdated, order)
 	return order, nil
 }
 
-func (s *OrderService) GetOrdersByProductID(productID string) ([]repository.Order, error) {
-	cacheKey := s.cache.GetCacheKeyForProduct(productID)
+// refundLostCapture refunds a payment captured for an order that left
+// AWAITING_PAYMENT before the capture could be recorded, e.g. because it
+// expired meanwhile. An order another confirmation moved on is paid by
+// that capture and keeps it. Failures are reported and left to payment
+// reconciliation.
+func (s *OrderService) refundLostCapture(ctx context.Context, order *repository.Order) {
+	current, err := s.repo.GetByID(order.ID)
+	if err != nil {
+		log.Printf("Failed to look up order %s after its payment was captured: %v", order.ID, err)
+		return
+	}
+	if current.Status == repository.StatusPending || current.Status == repository.StatusPaid {
+		return
+	}
+	if err := s.payments.Refund(context.WithoutCancel(ctx), order.PaymentIntentID); err != nil {
+		s.reportUpstream(ctx, "payment", err, map[string]string{"order_id": order.ID})
+		log.Printf("Failed to refund the payment captured for %s order %s: %v", current.Status, order.ID, err)
+		return
+	}
+	log.Printf("Refunded the payment captured for %s order %s", current.Status, order.ID)
+}
+
+// expirePaymentsBatch caps how many orders one ExpirePayments run handles.
+const expirePaymentsBatch = 100
 
+// ExpirePayments cancels the intents of orders that were not confirmed in
+// time and marks them PAYMENT_EXPIRED. It is run by the scheduler.
+func (s *OrderService) ExpirePayments(ctx context.Context) (int, error) {
+	orders, err := s.repo.GetExpiredPayments(time.Now(), expirePaymentsBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range orders {
+		ok, err := s.expirePayment(ctx, &orders[i])
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	if expired > 0 {
+		log.Printf("Expired %d unpaid orders", expired)
+	}
+	return expired, nil
+}
+
+// expirePayment marks an order awaiting payment PAYMENT_EXPIRED and
+// cancels its intent. It reports false for an order that is no longer
+// awaiting payment.
+func (s *OrderService) expirePayment(ctx context.Context, order *repository.Order) (bool, error) {
+	from := order.Status
+	if err := mutate(order, (*domain.Order).ExpirePayment); err != nil {
+		return false, nil
+	}
+	err := s.repo.UpdateStatus(order.ID, from, order.Status)
+	if errors.Is(err, repository.ErrStatusConflict) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := s.payments.Cancel(ctx, order.PaymentIntentID); err != nil {
+		log.Printf("Failed to cancel payment intent for order %s: %v", order.ID, err)
+	}
+	s.releaseLimits(*order)
+	s.reverseRedemptions(*order)
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.publish(ctx, "order.payment_expired", orderRefData(order))
+	s.emit(events.OrderUpdated, order)
+	return true, nil
+}
 
-	cachedOrders, err := s.cache.Get(cacheKey)
+// ApproveOrder releases a held order into the normal flow.
+func (s *OrderService) ApproveOrder(ctx context.Context, id string) (*repository.Order, error) {
+	order, err := s.reviewHeldOrder(id, (*domain.Order).Approve)
 	if err != nil {
-		log.Printf("Redis error on get: %v", err) 
-	if cachedOrders != nil {
-		log.Println("Returning cached orders")
-		return cachedOrders, nil
+		return nil, err
 	}
+	s.publishOrderCreated(ctx, order)
+	return order, nil
+}
 
-	log.Println("Fetching orders from DB")
-	orders, err := s.repo.GetByProductID(productID)
+func (s *OrderService) RejectOrder(ctx context.Context, id string) (*repository.Order, error) {
+	order, err := s.reviewHeldOrder(id, func(o *domain.Order) error { return o.Reject("") })
 	if err != nil {
 		return nil, err
 	}
+	s.releaseLimits(*order)
+	s.reverseRedemptions(*order)
+	s.publish(ctx, "order.rejected", orderRefData(order))
+	return order, nil
+}
 
-	if err := s.cache.Set(cacheKey, orders); err != nil {
-		log.Printf("Redis error on set: %v", err)
+func (s *OrderService) reviewHeldOrder(id string, review func(*domain.Order) error) (*repository.Order, error) {
+	order, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	if order.Status != repository.StatusOnHold {
+		return nil, &Error{Code: CodeOrderNotOnHold, Message: "order is not on hold"}
+	}
+	if err := mutate(order, review); err != nil {
+		return nil, err
 	}
 
-	return orders, nil
-}
\ No newline at end of file
+	err = s.repo.UpdateStatus(id, repository.StatusOnHold, order.Status)
+	if errors.Is(err, repository.ErrStatusConflict) {
+		return nil, &Error{Code: CodeOrderNotOnHold, Message: "order is not on hold"}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.emit(events.OrderUpdated, order)
+	return order, nil
+}
+
+// emit tells the modules subscribed to the bus what happened to order.
+// Like the compensating calls it does not use the request context.
+func (s *OrderService) emit(name string, order *repository.Order) {
+	s.bus.Emit(context.Background(), name, order)
+}
+
+func (s *OrderService) SearchOrders(ctx context.Context, filter repository.OrderFilter, limit, offset int) ([]repository.Order, error) {
+	var searcher repository.IOrderSearcher = s.repo
+	// Tags are not indexed in OpenSearch; tag filters always use Postgres.
+	if s.searchIndex != nil && len(filter.Tags) == 0 && s.flags.Enabled(ctx, featureflags.OpenSearchSearch) {
+		searcher = s.searchIndex
+	}
+	return searcher.Search(ctx, filter, limit, offset)
+}
+
+// StreamOrders calls fn for every order matching filter, oldest first,
+// reading them from the database in batches rather than all at once.
+// Iteration stops at the first error fn returns.
+func (s *OrderService) StreamOrders(ctx context.Context, filter repository.OrderFilter, fn func(repository.Order) error) error {
+	return s.repo.Stream(ctx, filter, fn)
+}
+
+// appendToCache adds new orders to the cached lists of their products, so
+// hot products are not reloaded from the database after every order.
+func (s *OrderService) appendToCache(orders ...repository.Order) {
+	for _, o := range orders {
+		if err := s.cache.Append(s.cache.GetCacheKeyForProduct(o.ProductID), o); err != nil {
+			s.logger.Warn(context.Background()).Str("product_id", o.ProductID).Err(err).Msg("failed to append order to cache")
+		}
+	}
+}
+
+func (s *OrderService) GetOrdersByProductID(ctx context.Context, productID string) ([]repository.Order, error) {
+	return s.orderLists.Get(ctx, productID)
+}
+
+// orderListStore stores a product's orders in the order cache, which owns
+// their expiry.
+type orderListStore struct {
+	cache repository.IOrderCache
+}
+
+var _ cache.Store[[]repository.Order] = orderListStore{}
+
+func (s orderListStore) Get(_ context.Context, productID string) ([]repository.Order, bool, error) {
+	orders, err := s.cache.Get(s.cache.GetCacheKeyForProduct(productID))
+	return orders, orders != nil, err
+}
+
+func (s orderListStore) Set(_ context.Context, productID string, orders []repository.Order, _ time.Duration) error {
+	return s.cache.Set(s.cache.GetCacheKeyForProduct(productID), orders)
+}
+
+func (s orderListStore) Delete(_ context.Context, productID string) error {
+	return s.cache.Delete(s.cache.GetCacheKeyForProduct(productID))
+}
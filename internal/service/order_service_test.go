@@ -1,29 +1,123 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"order-service/internal/acceptance"
+	"order-service/internal/audit"
+	"order-service/internal/backoff"
+	"order-service/internal/balance"
+	"order-service/internal/cart"
+	"order-service/internal/currency"
+	"order-service/internal/degrade"
+	"order-service/internal/domain"
+	"order-service/internal/errreport"
+	"order-service/internal/events"
+	"order-service/internal/featureflags"
+	"order-service/internal/fraud"
+	"order-service/internal/georestrict"
+	"order-service/internal/inventory"
+	"order-service/internal/limits"
+	"order-service/internal/logging"
+	"order-service/internal/payment"
+	"order-service/internal/reconciliation"
 	"order-service/internal/repository"
+	"order-service/internal/rounding"
+	"order-service/internal/subscription"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type mockOrderRepository struct{}
-func (m *mockOrderRepository) Create(order *repository.Order) error { return nil }
-func (m *mockOrderRepository) GetByProductID(productID string) ([]repository.Order, error) { return nil, nil }
+
+func (m *mockOrderRepository) Create(order *repository.Order) error        { return nil }
+func (m *mockOrderRepository) CreateBatch(orders []repository.Order) error { return nil }
+func (m *mockOrderRepository) GetByID(id string) (*repository.Order, error) {
+	return nil, repository.ErrOrderNotFound
+}
+func (m *mockOrderRepository) Exists(id string) (bool, error)         { return false, nil }
+func (m *mockOrderRepository) UpdateStatus(id, from, to string) error { return nil }
+func (m *mockOrderRepository) CountByProduct(productID string) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+func (m *mockOrderRepository) GetByProductID(productID string) ([]repository.Order, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) GetBackorders(productID string) ([]repository.Order, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) BackorderPosition(order *repository.Order) (int, error) { return 1, nil }
+func (m *mockOrderRepository) GetExpiredPayments(before time.Time, limit int) ([]repository.Order, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) GetPaymentOrders(since time.Time, afterID string, limit int) ([]repository.Order, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) GetPendingValidation(limit int) ([]repository.Order, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) CompleteValidation(order *repository.Order) error { return nil }
+func (m *mockOrderRepository) GetExpiredReservations(before time.Time, limit int) ([]repository.Order, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) CompleteReservation(order *repository.Order) error { return nil }
+func (m *mockOrderRepository) GetDueScheduled(before time.Time, limit int) ([]repository.Order, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) ActivateScheduled(order *repository.Order) error { return nil }
+func (m *mockOrderRepository) Reschedule(id string, processAt time.Time, deliverAt *time.Time) error {
+	return nil
+}
+func (m *mockOrderRepository) GetInstallments(orderID string) ([]repository.Installment, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) MarkInstallmentPaid(orderID string, sequence int, paymentID string) (*repository.Order, error) {
+	return nil, repository.ErrOrderNotFound
+}
+func (m *mockOrderRepository) Search(ctx context.Context, filter repository.OrderFilter, limit, offset int) ([]repository.Order, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) GetRevisions(orderID string) ([]repository.OrderRevision, error) {
+	return nil, nil
+}
+func (m *mockOrderRepository) AddTags(orderID string, tags []string) error { return nil }
+func (m *mockOrderRepository) RemoveTag(orderID, tag string) error         { return nil }
+func (m *mockOrderRepository) Stream(ctx context.Context, filter repository.OrderFilter, fn func(repository.Order) error) error {
+	return nil
+}
 
 type mockOrderCache struct{}
-func (m *mockOrderCache) Get(key string) ([]repository.Order, error) { return nil, nil }
+
+func (m *mockOrderCache) Get(key string) ([]repository.Order, error)      { return nil, nil }
 func (m *mockOrderCache) Set(key string, orders []repository.Order) error { return nil }
-func (m *mockOrderCache) GetCacheKeyForProduct(productID string) string { return "key" }
+func (m *mockOrderCache) Append(key string, order repository.Order) error { return nil }
+func (m *mockOrderCache) Delete(key string) error                         { return nil }
+func (m *mockOrderCache) GetCacheKeyForProduct(productID string) string   { return "key" }
 
-type mockPublisher struct{
+type mockPublisher struct {
 	shouldFail bool
+	patterns   []string
+}
+
+func (m *mockPublisher) PublishOrderCreated(order *repository.Order) error {
+	return m.Publish("order.created", order)
 }
-func (m *mockPublisher) PublishOrderCreated(productId string, quantity int) error {
+func (m *mockPublisher) Publish(pattern string, data interface{}) error {
 	if m.shouldFail {
 		return errors.New("publish failed")
 	}
+	m.patterns = append(m.patterns, pattern)
 	return nil
 }
 
@@ -41,7 +135,6 @@ func TestCreateOrder(t *testing.T) {
 	}))
 	defer server.Close()
 
-
 	service := NewOrderService(
 		&mockOrderRepository{},
 		&mockOrderCache{},
@@ -51,7 +144,7 @@ func TestCreateOrder(t *testing.T) {
 
 	t.Run("successful order creation", func(t *testing.T) {
 		req := CreateOrderRequest{ProductID: "valid-product", Quantity: 5}
-		order, err := service.CreateOrder(req)
+		order, err := service.CreateOrder(context.Background(), req)
 
 		if err != nil {
 			t.Errorf("Expected no error, got %v", err)
@@ -66,7 +159,7 @@ func TestCreateOrder(t *testing.T) {
 
 	t.Run("insufficient stock", func(t *testing.T) {
 		req := CreateOrderRequest{ProductID: "no-stock", Quantity: 5}
-		_, err := service.CreateOrder(req)
+		_, err := service.CreateOrder(context.Background(), req)
 
 		if err == nil {
 			t.Error("Expected an error for insufficient stock, got nil")
@@ -75,4 +168,2165 @@ func TestCreateOrder(t *testing.T) {
 			t.Errorf("Expected 'insufficient stock' error, got '%v'", err)
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestCreateOrders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/products/valid-product" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL)
+
+	t.Run("all orders valid", func(t *testing.T) {
+		orders, err := service.CreateOrders(context.Background(), []CreateOrderRequest{
+			{ProductID: "valid-product", Quantity: 1},
+			{ProductID: "valid-product", Quantity: 2},
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(orders) != 2 {
+			t.Errorf("Expected 2 orders, got %d", len(orders))
+		}
+	})
+
+	t.Run("one invalid order rejects the batch", func(t *testing.T) {
+		_, err := service.CreateOrders(context.Background(), []CreateOrderRequest{
+			{ProductID: "valid-product", Quantity: 1},
+			{ProductID: "missing", Quantity: 1},
+		})
+		if err == nil {
+			t.Error("Expected an error, got nil")
+		}
+	})
+}
+
+type mockLimiter struct {
+	reserveErr error
+	released   int
+}
+
+func (m *mockLimiter) CheckQuantity(productID string, quantity int) error {
+	if quantity < 1 {
+		return limits.ErrQuantityOutOfRange
+	}
+	return nil
+}
+func (m *mockLimiter) Reserve(ctx context.Context, productID, customerID, orderID string, quantity int) error {
+	return m.reserveErr
+}
+func (m *mockLimiter) Release(ctx context.Context, productID, customerID, orderID string, quantity int) error {
+	m.released++
+	return nil
+}
+
+func TestCreateOrderPurchaseLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	t.Run("quantity out of range", func(t *testing.T) {
+		service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithPurchaseLimiter(&mockLimiter{}))
+		_, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 0})
+
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeQuantityOutOfRange {
+			t.Errorf("Expected %s error, got %v", CodeQuantityOutOfRange, err)
+		}
+	})
+
+	t.Run("customer limit exceeded", func(t *testing.T) {
+		limiter := &mockLimiter{reserveErr: limits.ErrCustomerLimitExceeded}
+		service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithPurchaseLimiter(limiter))
+		_, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, CustomerID: "c-1"})
+
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodePurchaseLimitExceeded {
+			t.Errorf("Expected %s error, got %v", CodePurchaseLimitExceeded, err)
+		}
+	})
+
+	t.Run("redis failure fails open", func(t *testing.T) {
+		limiter := &mockLimiter{reserveErr: errors.New("connection refused")}
+		service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithPurchaseLimiter(limiter))
+		if _, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, CustomerID: "c-1"}); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+}
+
+type mockFraudChecker struct {
+	verdict fraud.Verdict
+	err     error
+}
+
+func (m *mockFraudChecker) Check(ctx context.Context, order *repository.Order) (fraud.Verdict, error) {
+	return m.verdict, m.err
+}
+
+func TestCreateOrderFraudScreening(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		checker  *mockFraudChecker
+		failOpen bool
+		want     string
+	}{
+		{"clean order", &mockFraudChecker{}, true, repository.StatusPending},
+		{"suspicious order", &mockFraudChecker{verdict: fraud.Verdict{Suspicious: true, Reason: "velocity"}}, true, repository.StatusOnHold},
+		{"checker down, fail open", &mockFraudChecker{err: errors.New("timeout")}, true, repository.StatusPending},
+		{"checker down, fail closed", &mockFraudChecker{err: errors.New("timeout")}, false, repository.StatusOnHold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+				WithFraudChecker(tt.checker, time.Second, tt.failOpen))
+			order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if order.Status != tt.want {
+				t.Errorf("Expected status %s, got %s", tt.want, order.Status)
+			}
+		})
+	}
+}
+
+type backorderRepository struct {
+	mockOrderRepository
+	backorders []repository.Order
+	confirmed  []string
+}
+
+func (m *backorderRepository) GetBackorders(productID string) ([]repository.Order, error) {
+	return m.backorders, nil
+}
+func (m *backorderRepository) UpdateStatus(id, from, to string) error {
+	m.confirmed = append(m.confirmed, id)
+	return nil
+}
+
+func TestConfirmBackordersIsFIFO(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"p", "name":"Test", "price":"10.0", "qty":5}`))
+	}))
+	defer server.Close()
+
+	repo := &backorderRepository{backorders: []repository.Order{
+		{ID: "first", ProductID: "p", Quantity: 3, Status: repository.StatusBackordered},
+		{ID: "second", ProductID: "p", Quantity: 4, Status: repository.StatusBackordered},
+		{ID: "third", ProductID: "p", Quantity: 1, Status: repository.StatusBackordered},
+	}}
+	service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, server.URL)
+
+	n, err := service.ConfirmBackorders(context.Background(), "p")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != 1 || len(repo.confirmed) != 1 || repo.confirmed[0] != "first" {
+		t.Errorf("Expected only the first backorder to be confirmed, got %v", repo.confirmed)
+	}
+}
+
+func TestQuoteOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	discounts, _ := ParseDiscountCodes(`{"welcome10":{"percent":10}}`)
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+		WithTaxRate(0.11), WithShippingFee(5), WithDiscountCodes(discounts))
+
+	t.Run("applies discount, tax and shipping", func(t *testing.T) {
+		quote, err := service.QuoteOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 10, DiscountCode: "WELCOME10"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// 100 - 10 discount + 9.9 tax + 5 shipping
+		if quote.Subtotal != 100 || quote.Discount != 10 || quote.Tax != 9.9 || quote.Total != 104.9 {
+			t.Errorf("Unexpected quote: %+v", quote)
+		}
+	})
+
+	t.Run("unknown discount code", func(t *testing.T) {
+		_, err := service.QuoteOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, DiscountCode: "NOPE"})
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeInvalidDiscountCode {
+			t.Errorf("Expected %s error, got %v", CodeInvalidDiscountCode, err)
+		}
+	})
+}
+
+func TestTenantPricing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	rules, err := ParseTenantPricing(`{"acme":{"taxRate":0,"bulkDiscounts":[{"minQuantity":10,"percent":10}],"freeShippingOver":50}}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	flat := domain.StepFunc(func(c *domain.Calculation) error {
+		c.Subtotal, c.Total = 1, 1
+		return nil
+	})
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+		WithTaxRate(0.1), WithShippingFee(5), WithTenantPricing(rules), WithPricingEngine("flat", domain.Pipeline{flat}))
+
+	tests := []struct {
+		tenant string
+		want   float64
+	}{
+		{"", 115},    // 100 + 10 tax + 5 shipping
+		{"acme", 90}, // 10% bulk discount, no tax, free shipping
+		{"other", 115},
+		{"flat", 1},
+	}
+	for _, tt := range tests {
+		quote, err := service.QuoteOrder(context.Background(), CreateOrderRequest{ProductID: "p", Quantity: 10, TenantID: tt.tenant})
+		if err != nil {
+			t.Fatalf("Expected no error for tenant %q, got %v", tt.tenant, err)
+		}
+		if quote.Total != tt.want {
+			t.Errorf("Expected a total of %v for tenant %q, got %+v", tt.want, tt.tenant, quote)
+		}
+	}
+
+	if _, err := ParseTenantPricing(`{"acme":{"bulkDiscounts":[{"minQuantity":0,"percent":10}]}}`); err == nil {
+		t.Error("Expected an error for a bulk discount without minimum quantity")
+	}
+}
+
+func TestAcceptanceRules(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p", "name":"Test", "price":"10.0", "qty":100, "category":"food"}`))
+	}))
+	defer server.Close()
+
+	rules, err := acceptance.Parse(`[{"name":"cap","kind":"max_order_value","max":50},{"name":"food","kind":"allowed_categories","values":["food"]}]`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+		WithAcceptanceRules(acceptance.NewEngine(rules)))
+
+	if _, err := service.QuoteOrder(context.Background(), CreateOrderRequest{ProductID: "p", Quantity: 5}); err != nil {
+		t.Errorf("Expected the order to be accepted, got %v", err)
+	}
+
+	var svcErr *Error
+	_, err = service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "p", Quantity: 6})
+	if !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotAccepted {
+		t.Errorf("Expected %s, got %v", CodeOrderNotAccepted, err)
+	}
+
+	explanation, err := service.ExplainOrder(context.Background(), CreateOrderRequest{ProductID: "p", Quantity: 6})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if explanation.Accepted || explanation.Quote.Total != 60 || len(explanation.Results) != 2 ||
+		explanation.Results[0].Passed || !explanation.Results[1].Passed {
+		t.Errorf("Expected the cap to fail and the category to pass, got %+v", explanation)
+	}
+}
+
+// fakeGeoRestrictions only ships category "alcohol" to Indonesia.
+type fakeGeoRestrictions struct{}
+
+func (fakeGeoRestrictions) Check(o georestrict.Order) *georestrict.Violation {
+	rule := georestrict.Rule{Scope: georestrict.ScopeCategory, Target: "alcohol", Countries: []string{"ID"}}
+	switch {
+	case o.Category != "alcohol":
+		return nil
+	case o.Country == "":
+		return &georestrict.Violation{Reason: georestrict.ReasonAddressRequired, Rule: rule}
+	case o.Country != "ID":
+		return &georestrict.Violation{Reason: georestrict.ReasonCountry, Rule: rule}
+	}
+	return nil
+}
+
+func TestGeoRestrictions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p", "name":"Test", "price":"10.0", "qty":100, "category":"alcohol"}`))
+	}))
+	defer server.Close()
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+		WithGeoRestrictions(fakeGeoRestrictions{}))
+
+	if _, err := service.QuoteOrder(context.Background(), CreateOrderRequest{ProductID: "p", Quantity: 1, Country: "ID"}); err != nil {
+		t.Errorf("Expected the order to be accepted, got %v", err)
+	}
+	tests := []struct {
+		country string
+		code    string
+	}{
+		{"", CodeAddressRequired},
+		{"US", CodeCountryNotAllowed},
+	}
+	for _, tt := range tests {
+		var svcErr *Error
+		_, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "p", Quantity: 1, Country: tt.country})
+		if !errors.As(err, &svcErr) || svcErr.Code != tt.code {
+			t.Errorf("Expected %s for country %q, got %v", tt.code, tt.country, err)
+		}
+	}
+}
+
+type mockPaymentGateway struct {
+	intentErr  error
+	captureErr error
+	captured   []string
+	cancelled  []string
+	refunded   []string
+	amounts    []float64
+}
+
+func (m *mockPaymentGateway) CreateIntent(ctx context.Context, orderID string, amount float64, currency string) (*payment.Intent, error) {
+	if m.intentErr != nil {
+		return nil, m.intentErr
+	}
+	m.amounts = append(m.amounts, amount)
+	return &payment.Intent{ID: "pi-" + orderID, ClientSecret: "secret"}, nil
+}
+func (m *mockPaymentGateway) Capture(ctx context.Context, intentID string) error {
+	if m.captureErr != nil {
+		return m.captureErr
+	}
+	m.captured = append(m.captured, intentID)
+	return nil
+}
+func (m *mockPaymentGateway) Cancel(ctx context.Context, intentID string) error {
+	m.cancelled = append(m.cancelled, intentID)
+	return nil
+}
+func (m *mockPaymentGateway) Refund(ctx context.Context, intentID string) error {
+	m.refunded = append(m.refunded, intentID)
+	return nil
+}
+func (m *mockPaymentGateway) GetIntent(ctx context.Context, intentID string) (*payment.Intent, error) {
+	return nil, payment.ErrIntentNotFound
+}
+
+type paymentRepository struct {
+	mockOrderRepository
+	orders map[string]*repository.Order
+}
+
+func (m *paymentRepository) Create(order *repository.Order) error {
+	stored := *order
+	m.orders[order.ID] = &stored
+	return nil
+}
+func (m *paymentRepository) GetByID(id string) (*repository.Order, error) {
+	if o, ok := m.orders[id]; ok {
+		stored := *o
+		return &stored, nil
+	}
+	return nil, repository.ErrOrderNotFound
+}
+func (m *paymentRepository) UpdateStatus(id, from, to string) error {
+	o, ok := m.orders[id]
+	if !ok || o.Status != from {
+		return repository.ErrStatusConflict
+	}
+	o.Status = to
+	return nil
+}
+func (m *paymentRepository) MarkInstallmentPaid(orderID string, sequence int, paymentID string) (*repository.Order, error) {
+	o, ok := m.orders[orderID]
+	if !ok {
+		return nil, repository.ErrOrderNotFound
+	}
+	for i := range o.Installments {
+		inst := &o.Installments[i]
+		if inst.Sequence != sequence {
+			continue
+		}
+		if inst.PaidAt != nil {
+			return nil, repository.ErrInstallmentPaid
+		}
+		now := time.Now()
+		inst.PaidAt, inst.PaymentID = &now, paymentID
+		o.PaidAmount = rounding.Default.Round(o.PaidAmount + inst.Amount)
+		stored := *o
+		return &stored, nil
+	}
+	return nil, repository.ErrInstallmentNotFound
+}
+func (m *paymentRepository) GetExpiredPayments(before time.Time, limit int) ([]repository.Order, error) {
+	var expired []repository.Order
+	for _, o := range m.orders {
+		if o.Status == repository.StatusAwaitingPayment && o.PaymentExpiresAt.Before(before) {
+			expired = append(expired, *o)
+		}
+	}
+	return expired, nil
+}
+
+type racingRepository struct {
+	paymentRepository
+	status string
+}
+
+func (m *racingRepository) UpdateStatus(id, from, to string) error {
+	if from == repository.StatusAwaitingPayment && to == repository.StatusPending {
+		m.orders[id].Status = m.status
+	}
+	return m.paymentRepository.UpdateStatus(id, from, to)
+}
+
+func TestPaymentIntentFlow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	t.Run("order waits for confirmation", func(t *testing.T) {
+		repo := &paymentRepository{orders: map[string]*repository.Order{}}
+		gateway := &mockPaymentGateway{}
+		service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithPaymentGateway(gateway, time.Minute))
+
+		order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order.Status != repository.StatusAwaitingPayment || order.PaymentClientSecret != "secret" {
+			t.Fatalf("Expected order awaiting payment with a client secret, got %+v", order)
+		}
+
+		confirmed, err := service.ConfirmOrder(context.Background(), order.ID)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if confirmed.Status != repository.StatusPending || len(gateway.captured) != 1 {
+			t.Errorf("Expected captured PENDING order, got %s with %d captures", confirmed.Status, len(gateway.captured))
+		}
+
+		_, err = service.ConfirmOrder(context.Background(), order.ID)
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotAwaitingPayment {
+			t.Errorf("Expected %s error, got %v", CodeOrderNotAwaitingPayment, err)
+		}
+	})
+
+	t.Run("declined capture", func(t *testing.T) {
+		repo := &paymentRepository{orders: map[string]*repository.Order{}}
+		gateway := &mockPaymentGateway{captureErr: payment.ErrPaymentDeclined}
+		service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithPaymentGateway(gateway, time.Minute))
+
+		order, _ := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1})
+		_, err := service.ConfirmOrder(context.Background(), order.ID)
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodePaymentDeclined {
+			t.Errorf("Expected %s error, got %v", CodePaymentDeclined, err)
+		}
+	})
+
+	// racingRepository changes the order to status between the capture
+	// and the confirmation being stored, as a concurrent expiry or
+	// confirmation would.
+	for _, tt := range []struct {
+		status string
+		refund bool
+	}{
+		{repository.StatusPaymentExpired, true},
+		{repository.StatusPending, false},
+	} {
+		t.Run("capture loses to "+tt.status, func(t *testing.T) {
+			repo := &racingRepository{paymentRepository: paymentRepository{orders: map[string]*repository.Order{}}, status: tt.status}
+			gateway := &mockPaymentGateway{}
+			service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, server.URL,
+				WithPaymentGateway(gateway, time.Minute))
+
+			order, _ := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1})
+			_, err := service.ConfirmOrder(context.Background(), order.ID)
+			var svcErr *Error
+			if !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotAwaitingPayment {
+				t.Errorf("Expected %s error, got %v", CodeOrderNotAwaitingPayment, err)
+			}
+			if refunded := len(gateway.refunded) == 1; refunded != tt.refund || len(gateway.captured) != 1 {
+				t.Errorf("Expected refund %v after one capture, got captures %v and refunds %v", tt.refund, gateway.captured, gateway.refunded)
+			}
+		})
+	}
+
+	t.Run("unconfirmed intents expire", func(t *testing.T) {
+		repo := &paymentRepository{orders: map[string]*repository.Order{}}
+		gateway := &mockPaymentGateway{}
+		service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithPaymentGateway(gateway, -time.Minute))
+
+		order, _ := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1})
+		n, err := service.ExpirePayments(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if n != 1 || repo.orders[order.ID].Status != repository.StatusPaymentExpired || len(gateway.cancelled) != 1 {
+			t.Errorf("Expected the order to expire and its intent to be cancelled, got %d expired", n)
+		}
+	})
+}
+
+func TestCreateOrderInstallments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	repo := &paymentRepository{orders: map[string]*repository.Order{}}
+	gateway := &mockPaymentGateway{}
+	publisher := &mockPublisher{}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, server.URL,
+		WithInstallments(6), WithPaymentGateway(gateway, time.Minute))
+
+	t.Run("total is split across installments", func(t *testing.T) {
+		order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 10, Installments: 3})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(order.Installments) != 3 {
+			t.Fatalf("Expected 3 installments, got %d", len(order.Installments))
+		}
+		var sum float64
+		for _, inst := range order.Installments {
+			sum += inst.Amount
+		}
+		// 100 / 3 = 33.33 + 33.33 + 33.34
+		if rounding.Default.Round(sum) != order.TotalPrice || order.Installments[2].Amount != 33.34 {
+			t.Errorf("Unexpected schedule: %+v", order.Installments)
+		}
+		if order.Status != repository.StatusAwaitingPayment || order.PaymentIntentID == "" {
+			t.Fatalf("Expected an order awaiting payment, got %s / %q", order.Status, order.PaymentIntentID)
+		}
+		if len(gateway.amounts) != 1 || gateway.amounts[0] != 33.33 {
+			t.Errorf("Expected an intent for the first installment of 33.33, got %v", gateway.amounts)
+		}
+
+		confirmed, err := service.ConfirmOrder(context.Background(), order.ID)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if confirmed.Status != repository.StatusPending || confirmed.PaidAmount != 33.33 {
+			t.Errorf("Expected the first installment to be paid, got %s with %v paid", confirmed.Status, confirmed.PaidAmount)
+		}
+		if !slices.Contains(publisher.patterns, "order.installment_paid") {
+			t.Errorf("Expected order.installment_paid, got %v", publisher.patterns)
+		}
+	})
+
+	t.Run("split in whole units of the rounding", func(t *testing.T) {
+		for _, tt := range []struct {
+			total float64
+			n     int
+			round rounding.Strategy
+			want  []float64
+		}{
+			{100, 3, rounding.Default, []float64{33.33, 33.33, 33.34}},
+			{0.1 + 0.2, 2, rounding.Default, []float64{0.15, 0.15}},
+			{10.05, 4, rounding.Default, []float64{2.51, 2.51, 2.51, 2.52}},
+			{100000, 3, rounding.HalfUp{Places: 0}, []float64{33333, 33333, 33334}},
+			{1000, 3, rounding.HalfEven{Places: 0}, []float64{333, 333, 334}},
+			{10, 3, rounding.Cash{Increment: 0.05}, []float64{3.3, 3.3, 3.4}},
+		} {
+			var got []float64
+			for _, inst := range splitInstallments("o1", tt.total, tt.n, time.Now(), tt.round) {
+				got = append(got, inst.Amount)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v to split into %v, got %v", tt.total, tt.want, got)
+			}
+		}
+	})
+
+	t.Run("too many installments", func(t *testing.T) {
+		_, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, Installments: 12})
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeInvalidInstallments {
+			t.Errorf("Expected %s error, got %v", CodeInvalidInstallments, err)
+		}
+	})
+}
+
+type mockBalanceClient struct {
+	balances map[string]float64
+	reversed []string
+}
+
+func (m *mockBalanceClient) Redeem(ctx context.Context, kind, reference, orderID string, maxAmount float64) (*balance.Redemption, error) {
+	available, ok := m.balances[reference]
+	if !ok {
+		return nil, balance.ErrBalanceNotFound
+	}
+	amount := min(available, maxAmount)
+	m.balances[reference] -= amount
+	return &balance.Redemption{ID: "r-" + reference, Amount: amount}, nil
+}
+func (m *mockBalanceClient) Reverse(ctx context.Context, redemptionID string) error {
+	m.reversed = append(m.reversed, redemptionID)
+	return nil
+}
+
+func TestCreateOrderTenders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	t.Run("gift card and store credit, rest by payment", func(t *testing.T) {
+		balances := &mockBalanceClient{balances: map[string]float64{"GIFT-1234": 30, "c-1": 50}}
+		gateway := &amountRecordingGateway{}
+		service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithBalanceClient(balances), WithPaymentGateway(gateway, time.Minute))
+
+		order, err := service.CreateOrder(context.Background(), CreateOrderRequest{
+			ProductID: "valid-product", Quantity: 10, CustomerID: "c-1", GiftCardCode: "GIFT-1234", UseStoreCredit: true,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(order.Tenders) != 3 || order.Tenders[0].Amount != 30 || order.Tenders[1].Amount != 50 || order.Tenders[2].Amount != 20 {
+			t.Fatalf("Unexpected tenders: %+v", order.Tenders)
+		}
+		if order.Tenders[0].Reference != "****1234" {
+			t.Errorf("Expected masked gift card code, got %q", order.Tenders[0].Reference)
+		}
+		if gateway.amount != 20 {
+			t.Errorf("Expected payment intent for 20, got %v", gateway.amount)
+		}
+	})
+
+	t.Run("unknown gift card", func(t *testing.T) {
+		balances := &mockBalanceClient{balances: map[string]float64{}}
+		service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithBalanceClient(balances))
+
+		_, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, GiftCardCode: "NOPE"})
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeInvalidGiftCard {
+			t.Errorf("Expected %s error, got %v", CodeInvalidGiftCard, err)
+		}
+	})
+}
+
+type amountRecordingGateway struct {
+	mockPaymentGateway
+	amount   float64
+	currency string
+}
+
+func (m *amountRecordingGateway) CreateIntent(ctx context.Context, orderID string, amount float64, currency string) (*payment.Intent, error) {
+	m.amount, m.currency = amount, currency
+	return m.mockPaymentGateway.CreateIntent(ctx, orderID, amount, currency)
+}
+
+func TestCreateOrderCurrencyConversion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100, "currency":"USD"}`))
+	}))
+	defer server.Close()
+
+	rates, _ := currency.ParseRates(`{"USD/IDR": 16000}`)
+	gateway := &amountRecordingGateway{}
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+		WithCurrency("IDR", rates), WithPaymentGateway(gateway, time.Minute))
+
+	t.Run("converts into the customer's currency", func(t *testing.T) {
+		order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 2, Currency: "idr"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order.TotalPrice != 20 || order.Currency != "USD" {
+			t.Errorf("Expected original total 20 USD, got %v %s", order.TotalPrice, order.Currency)
+		}
+		if order.ConvertedTotal != 320000 || order.ConvertedCurrency != "IDR" || order.ExchangeRate != 16000 {
+			t.Errorf("Unexpected conversion: %v %s at %v", order.ConvertedTotal, order.ConvertedCurrency, order.ExchangeRate)
+		}
+		if gateway.amount != 320000 || gateway.currency != "IDR" {
+			t.Errorf("Expected payment of 320000 IDR, got %v %s", gateway.amount, gateway.currency)
+		}
+	})
+
+	t.Run("unsupported currency", func(t *testing.T) {
+		_, err := service.QuoteOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, Currency: "EUR"})
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeUnsupportedCurrency {
+			t.Errorf("Expected %s error, got %v", CodeUnsupportedCurrency, err)
+		}
+	})
+}
+
+func TestConvertedIntentRounding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p1", "name":"Test", "price":"10.0", "qty":100, "currency":"USD"}`))
+	}))
+	defer server.Close()
+
+	rates, _ := currency.ParseRates(`{"USD/JPY": 151.237, "USD/CHF": 0.8843}`)
+	policy, err := rounding.Parse(`{"currencies":{"JPY":{"places":0},"CHF":{"mode":"cash","increment":0.05}}}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	cases := []struct {
+		currency string
+		want     float64
+	}{
+		{"JPY", 1512},
+		{"CHF", 8.85},
+	}
+	for _, tc := range cases {
+		t.Run(tc.currency, func(t *testing.T) {
+			gateway := &amountRecordingGateway{}
+			s := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+				WithCurrency("USD", rates), WithRoundingPolicy(policy), WithPaymentGateway(gateway, time.Minute))
+
+			order, err := s.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "p1", Quantity: 1, Currency: tc.currency})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if order.ConvertedTotal != tc.want {
+				t.Errorf("Expected a converted total of %v, got %v", tc.want, order.ConvertedTotal)
+			}
+			if gateway.amount != order.ConvertedTotal || gateway.currency != tc.currency {
+				t.Errorf("Expected the intent for the converted total %v %s, got %v %s", order.ConvertedTotal, tc.currency, gateway.amount, gateway.currency)
+			}
+			if d := s.paymentDiscrepancies(order, &payment.Intent{Status: payment.IntentAuthorized, Amount: tc.want}); len(d) != 0 {
+				t.Errorf("Expected reconciliation to accept the charged amount, got %+v", d)
+			}
+		})
+	}
+}
+
+func TestImportOrders(t *testing.T) {
+	lookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		if r.URL.Path == "/products/valid-product" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL)
+
+	orders, errs := service.ImportOrders(context.Background(), []CreateOrderRequest{
+		{ProductID: "valid-product", Quantity: 1},
+		{ProductID: "missing", Quantity: 1},
+		{ProductID: "valid-product", Quantity: 2},
+	})
+	if len(orders) != 2 {
+		t.Errorf("Expected 2 orders, got %d", len(orders))
+	}
+	if errs[0] != nil || errs[2] != nil {
+		t.Errorf("Expected valid rows to succeed, got %v", errs)
+	}
+	if errs[1] == nil {
+		t.Error("Expected an error for the missing product, got nil")
+	}
+	if lookups != 2 {
+		t.Errorf("Expected 2 product lookups, got %d", lookups)
+	}
+}
+
+func TestProductLookupCoalescing(t *testing.T) {
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		<-release
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL)
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.fetchProductInfo(context.Background(), "valid-product")
+			errs <- err
+		}()
+	}
+	// Give every caller time to join the lookup in flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("Expected 1 product lookup, got %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := service.fetchProductInfo(ctx, "valid-product"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled caller to give up, got %v", err)
+	}
+}
+
+func TestProductCacheConcurrency(t *testing.T) {
+	var lookups atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		<-release
+		id := strings.TrimPrefix(r.URL.Path, "/products/")
+		if id == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id":"` + id + `", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+		WithProductCache(time.Minute, time.Minute))
+	products := []string{"p1", "p2", "p3", "missing"}
+	hammer := func(rounds int) {
+		var wg sync.WaitGroup
+		for i := range 40 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range rounds {
+					id := products[(i+j)%len(products)]
+					product, err := service.fetchProductInfo(context.Background(), id)
+					switch {
+					case id == "missing":
+						if !errors.Is(err, errProductNotFound) {
+							t.Errorf("Expected product not found, got %v", err)
+						}
+					case err != nil || product.ID != id:
+						t.Errorf("Expected product %s, got %+v, %v", id, product, err)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		hammer(1)
+		close(done)
+	}()
+	// Give every caller time to join the lookup of its product.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	<-done
+	if n := lookups.Load(); n != int32(len(products)) {
+		t.Errorf("Expected 1 lookup per product, got %d", n)
+	}
+
+	hammer(50)
+	if n := lookups.Load(); n != int32(len(products)) {
+		t.Errorf("Expected cached products and misses to be served from memory, got %d extra lookups", n-int32(len(products)))
+	}
+}
+
+// memoryOrderCache is an IOrderCache safe for concurrent use that, like
+// Redis, hands out copies.
+type memoryOrderCache struct {
+	mu     sync.Mutex
+	orders map[string][]repository.Order
+}
+
+func (m *memoryOrderCache) Get(key string) ([]repository.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.orders[key]), nil
+}
+
+func (m *memoryOrderCache) Set(key string, orders []repository.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orders[key] = slices.Clone(orders)
+	return nil
+}
+
+func (m *memoryOrderCache) Append(key string, order repository.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if orders, ok := m.orders[key]; ok {
+		m.orders[key] = append(slices.Clone(orders), order)
+	}
+	return nil
+}
+
+func (m *memoryOrderCache) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.orders, key)
+	return nil
+}
+
+func (m *memoryOrderCache) GetCacheKeyForProduct(productID string) string {
+	return "orders:" + productID
+}
+
+type productOrdersRepository struct {
+	mockOrderRepository
+	queries atomic.Int32
+	release chan struct{}
+}
+
+func (m *productOrdersRepository) GetByProductID(productID string) ([]repository.Order, error) {
+	m.queries.Add(1)
+	<-m.release
+	return []repository.Order{{ID: productID + "-1", ProductID: productID}, {ID: productID + "-2", ProductID: productID}}, nil
+}
+
+func TestGetOrdersByProductIDStampede(t *testing.T) {
+	repo := &productOrdersRepository{release: make(chan struct{})}
+	cache := &memoryOrderCache{orders: map[string][]repository.Order{}}
+	service := NewOrderService(repo, cache, &mockPublisher{}, "")
+	products := []string{"p1", "p2", "p3"}
+
+	hammer := func(rounds int, minOrders int) {
+		var wg sync.WaitGroup
+		for i := range 60 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range rounds {
+					id := products[(i+j)%len(products)]
+					orders, err := service.GetOrdersByProductID(context.Background(), id)
+					if err != nil || len(orders) < minOrders {
+						t.Errorf("Expected at least %d orders of %s, got %d, %v", minOrders, id, len(orders), err)
+						return
+					}
+					for _, o := range orders {
+						if o.ProductID != id {
+							t.Errorf("Expected orders of %s, got one of %s", id, o.ProductID)
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		hammer(1, 2)
+		close(done)
+	}()
+	// Give every caller time to join the query of its product.
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	<-done
+	if n := repo.queries.Load(); n != int32(len(products)) {
+		t.Errorf("Expected 1 database query per product, got %d", n)
+	}
+
+	// New orders are appended to the cached lists while they are read.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 300 {
+			id := products[i%len(products)]
+			service.appendToCache(repository.Order{ID: fmt.Sprintf("%s-new-%d", id, i), ProductID: id})
+		}
+	}()
+	hammer(50, 2)
+	wg.Wait()
+	if n := repo.queries.Load(); n != int32(len(products)) {
+		t.Errorf("Expected cached lists to be served without querying, got %d extra queries", n-int32(len(products)))
+	}
+	orders, _ := service.GetOrdersByProductID(context.Background(), "p1")
+	if len(orders) != 2+100 {
+		t.Errorf("Expected every appended order in the cached list, got %d orders", len(orders))
+	}
+}
+
+type mockDegradation struct {
+	active map[string]bool
+}
+
+func (m *mockDegradation) Active(mode string) bool { return m.active[mode] }
+
+type validationRepository struct {
+	mockOrderRepository
+	pending   []repository.Order
+	completed []repository.Order
+}
+
+func (m *validationRepository) GetPendingValidation(limit int) ([]repository.Order, error) {
+	return m.pending, nil
+}
+func (m *validationRepository) CompleteValidation(order *repository.Order) error {
+	m.completed = append(m.completed, *order)
+	return nil
+}
+
+func TestPendingValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/products/valid-product" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":5}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	degradation := &mockDegradation{active: map[string]bool{degrade.ModePendingValidation: true}}
+	repo := &validationRepository{}
+	publisher := &mockPublisher{}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, server.URL, WithDegradation(degradation))
+
+	t.Run("orders are accepted unpriced while degraded", func(t *testing.T) {
+		order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "anything", Quantity: 1})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order.Status != repository.StatusPendingValidation {
+			t.Errorf("Expected status %s, got %s", repository.StatusPendingValidation, order.Status)
+		}
+		if len(publisher.patterns) != 0 {
+			t.Errorf("Expected no events before validation, got %v", publisher.patterns)
+		}
+	})
+
+	t.Run("orders with tenders are refused while degraded", func(t *testing.T) {
+		_, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "anything", Quantity: 1, UseStoreCredit: true})
+		if !errors.Is(err, errProductUnavailable) {
+			t.Errorf("Expected %v, got %v", errProductUnavailable, err)
+		}
+	})
+
+	t.Run("validation waits for product-service", func(t *testing.T) {
+		repo.pending = []repository.Order{{ID: "o1", ProductID: "valid-product", Quantity: 1, Status: repository.StatusPendingValidation}}
+		n, err := service.ValidatePendingOrders(context.Background())
+		if err != nil || n != 0 || len(repo.completed) != 0 {
+			t.Errorf("Expected nothing validated while degraded, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("pending orders are priced or rejected once it is back", func(t *testing.T) {
+		degradation.active[degrade.ModePendingValidation] = false
+		repo.pending = []repository.Order{
+			{ID: "o1", ProductID: "valid-product", Quantity: 2, Status: repository.StatusPendingValidation},
+			{ID: "o2", ProductID: "missing", Quantity: 1, Status: repository.StatusPendingValidation},
+			{ID: "o3", ProductID: "valid-product", Quantity: 10, Status: repository.StatusPendingValidation},
+		}
+		n, err := service.ValidatePendingOrders(context.Background())
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if n != 3 {
+			t.Fatalf("Expected 3 validated orders, got %d", n)
+		}
+		if o := repo.completed[0]; o.Status != repository.StatusPending || o.TotalPrice != 20 {
+			t.Errorf("Expected PENDING order priced at 20, got %s at %v", o.Status, o.TotalPrice)
+		}
+		for _, o := range repo.completed[1:] {
+			if o.Status != repository.StatusRejected {
+				t.Errorf("Expected order %s to be REJECTED, got %s", o.ID, o.Status)
+			}
+		}
+		if !slices.Contains(publisher.patterns, "order.created") {
+			t.Errorf("Expected order.created to be published, got %v", publisher.patterns)
+		}
+	})
+}
+
+type scheduledRepository struct {
+	mockOrderRepository
+	due       []repository.Order
+	activated []repository.Order
+	stored    map[string]repository.Order
+}
+
+func (m *scheduledRepository) GetByID(id string) (*repository.Order, error) {
+	o, ok := m.stored[id]
+	if !ok {
+		return nil, repository.ErrOrderNotFound
+	}
+	return &o, nil
+}
+func (m *scheduledRepository) GetDueScheduled(before time.Time, limit int) ([]repository.Order, error) {
+	return m.due, nil
+}
+func (m *scheduledRepository) ActivateScheduled(order *repository.Order) error {
+	m.activated = append(m.activated, *order)
+	return nil
+}
+
+func TestScheduledOrders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/products/valid-product" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":5}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repo := &scheduledRepository{stored: map[string]repository.Order{}}
+	publisher := &mockPublisher{}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, server.URL, WithScheduling(24*time.Hour, 30*24*time.Hour))
+	at := func(d time.Duration) *time.Time {
+		t := time.Now().Add(d)
+		return &t
+	}
+
+	t.Run("orders with a processing time are stored as SCHEDULED", func(t *testing.T) {
+		order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 2, ProcessAt: at(time.Hour)})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order.Status != repository.StatusScheduled || order.TotalPrice != 20 {
+			t.Errorf("Expected SCHEDULED order priced at 20, got %s at %v", order.Status, order.TotalPrice)
+		}
+		if slices.Contains(publisher.patterns, "order.created") || !slices.Contains(publisher.patterns, "order.scheduled") {
+			t.Errorf("Expected only order.scheduled to be published, got %v", publisher.patterns)
+		}
+	})
+
+	t.Run("a delivery date schedules the order the lead time before it", func(t *testing.T) {
+		deliverAt := at(72 * time.Hour)
+		order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, DeliverAt: deliverAt})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order.Status != repository.StatusScheduled || !order.ProcessAt.Equal(deliverAt.Add(-24*time.Hour)) {
+			t.Errorf("Expected order scheduled a day before delivery, got %s at %v", order.Status, order.ProcessAt)
+		}
+
+		order, err = service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, DeliverAt: at(time.Hour)})
+		if err != nil || order.Status != repository.StatusPending {
+			t.Errorf("Expected a delivery within the lead time to be processed now, got %v, %v", order, err)
+		}
+	})
+
+	t.Run("invalid schedules are refused", func(t *testing.T) {
+		for name, req := range map[string]CreateOrderRequest{
+			"past":          {ProductID: "valid-product", Quantity: 1, ProcessAt: at(-time.Hour)},
+			"after deliver": {ProductID: "valid-product", Quantity: 1, ProcessAt: at(48 * time.Hour), DeliverAt: at(24 * time.Hour)},
+			"too far ahead": {ProductID: "valid-product", Quantity: 1, ProcessAt: at(60 * 24 * time.Hour)},
+			"store credit":  {ProductID: "valid-product", Quantity: 1, ProcessAt: at(time.Hour), UseStoreCredit: true},
+		} {
+			_, err := service.CreateOrder(context.Background(), req)
+			var svcErr *Error
+			if !errors.As(err, &svcErr) || svcErr.Code != CodeInvalidSchedule {
+				t.Errorf("%s: expected %s, got %v", name, CodeInvalidSchedule, err)
+			}
+		}
+	})
+
+	t.Run("due orders are priced again and activated or rejected", func(t *testing.T) {
+		repo.due = []repository.Order{
+			{ID: "o1", ProductID: "valid-product", Quantity: 3, Status: repository.StatusScheduled, ProcessAt: at(-time.Minute)},
+			{ID: "o2", ProductID: "valid-product", Quantity: 10, Status: repository.StatusScheduled, ProcessAt: at(-time.Minute)},
+		}
+		n, err := service.ActivateScheduledOrders(context.Background())
+		if err != nil || n != 2 {
+			t.Fatalf("Expected 2 handled orders, got %d, %v", n, err)
+		}
+		if o := repo.activated[0]; o.Status != repository.StatusPending || o.TotalPrice != 30 {
+			t.Errorf("Expected PENDING order priced at 30, got %s at %v", o.Status, o.TotalPrice)
+		}
+		if o := repo.activated[1]; o.Status != repository.StatusRejected {
+			t.Errorf("Expected order without stock to be REJECTED, got %s", o.Status)
+		}
+	})
+
+	t.Run("only scheduled orders can be rescheduled or cancelled", func(t *testing.T) {
+		repo.stored["s1"] = repository.Order{ID: "s1", ProductID: "valid-product", Status: repository.StatusScheduled, ProcessAt: at(time.Hour)}
+		repo.stored["p1"] = repository.Order{ID: "p1", ProductID: "valid-product", Status: repository.StatusPending}
+
+		processAt := at(2 * time.Hour)
+		order, err := service.RescheduleOrder(context.Background(), "s1", ScheduleRequest{ProcessAt: processAt})
+		if err != nil || !order.ProcessAt.Equal(*processAt) {
+			t.Errorf("Expected order rescheduled to %v, got %v, %v", processAt, order, err)
+		}
+		order, err = service.CancelOrder(context.Background(), "s1")
+		if err != nil || order.Status != repository.StatusCancelled {
+			t.Errorf("Expected CANCELLED order, got %v, %v", order, err)
+		}
+
+		_, err = service.CancelOrder(context.Background(), "p1")
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotScheduled {
+			t.Errorf("Expected %s, got %v", CodeOrderNotScheduled, err)
+		}
+	})
+}
+
+type reservationRepository struct {
+	mockOrderRepository
+	stored    map[string]repository.Order
+	expired   []repository.Order
+	completed []repository.Order
+	updated   []string
+}
+
+func (m *reservationRepository) GetByID(id string) (*repository.Order, error) {
+	o, ok := m.stored[id]
+	if !ok {
+		return nil, repository.ErrOrderNotFound
+	}
+	return &o, nil
+}
+func (m *reservationRepository) GetExpiredReservations(before time.Time, limit int) ([]repository.Order, error) {
+	return m.expired, nil
+}
+func (m *reservationRepository) CompleteReservation(order *repository.Order) error {
+	m.completed = append(m.completed, *order)
+	return nil
+}
+func (m *reservationRepository) UpdateStatus(id, from, to string) error {
+	m.updated = append(m.updated, id+":"+to)
+	return nil
+}
+
+func TestReservations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":5}`))
+	}))
+	defer server.Close()
+
+	repo := &reservationRepository{stored: map[string]repository.Order{}}
+	publisher := &mockPublisher{}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, server.URL, WithReservations(15*time.Minute))
+
+	t.Run("reserved orders hold stock without being created", func(t *testing.T) {
+		order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 2, Reserve: true})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if order.Status != repository.StatusReserved || order.ReservedUntil == nil {
+			t.Errorf("Expected RESERVED order with an expiry, got %s until %v", order.Status, order.ReservedUntil)
+		}
+		if slices.Contains(publisher.patterns, "order.created") || !slices.Contains(publisher.patterns, "order.reserved") {
+			t.Errorf("Expected only order.reserved to be published, got %v", publisher.patterns)
+		}
+	})
+
+	t.Run("reservations cannot exceed the stock or be paid up front", func(t *testing.T) {
+		_, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 10, Reserve: true})
+		if !errors.Is(err, errInsufficientStock) {
+			t.Errorf("Expected errInsufficientStock, got %v", err)
+		}
+		_, err = service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 1, Reserve: true, UseStoreCredit: true})
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeReservationNotAvailable {
+			t.Errorf("Expected %s, got %v", CodeReservationNotAvailable, err)
+		}
+	})
+
+	t.Run("confirming a reservation creates the order", func(t *testing.T) {
+		until := time.Now().Add(time.Minute)
+		repo.stored["r1"] = repository.Order{ID: "r1", ProductID: "valid-product", Quantity: 1, Status: repository.StatusReserved, ReservedUntil: &until}
+		publisher.patterns = nil
+		order, err := service.ConfirmOrder(context.Background(), "r1")
+		if err != nil || order.Status != repository.StatusPending {
+			t.Fatalf("Expected PENDING order, got %v, %v", order, err)
+		}
+		if len(repo.completed) != 1 || !slices.Contains(publisher.patterns, "order.created") {
+			t.Errorf("Expected the reservation completed and order.created published, got %v", publisher.patterns)
+		}
+
+		past := time.Now().Add(-time.Minute)
+		repo.stored["r2"] = repository.Order{ID: "r2", ProductID: "valid-product", Quantity: 1, Status: repository.StatusReserved, ReservedUntil: &past}
+		_, err = service.ConfirmOrder(context.Background(), "r2")
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeReservationExpired {
+			t.Errorf("Expected %s, got %v", CodeReservationExpired, err)
+		}
+	})
+
+	t.Run("expired reservations release their stock", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		repo.expired = []repository.Order{{ID: "r3", ProductID: "valid-product", Quantity: 1, Status: repository.StatusReserved, ReservedUntil: &past}}
+		publisher.patterns = nil
+		n, err := service.ExpireReservations(context.Background())
+		if err != nil || n != 1 {
+			t.Fatalf("Expected 1 expired reservation, got %d, %v", n, err)
+		}
+		if !slices.Contains(repo.updated, "r3:"+repository.StatusReservationExpired) || !slices.Contains(publisher.patterns, "order.reservation_released") {
+			t.Errorf("Expected r3 expired and order.reservation_released published, got %v, %v", repo.updated, publisher.patterns)
+		}
+	})
+}
+
+type mockLedger struct {
+	holds map[string]inventory.Hold
+}
+
+func (m *mockLedger) Reserve(ctx context.Context, hold *inventory.Hold) error {
+	m.holds[hold.OrderID] = *hold
+	return nil
+}
+func (m *mockLedger) Release(ctx context.Context, orderID string) (*inventory.Hold, error) {
+	hold, ok := m.holds[orderID]
+	if !ok {
+		return nil, inventory.ErrNotFound
+	}
+	delete(m.holds, orderID)
+	return &hold, nil
+}
+func (m *mockLedger) Committed(ctx context.Context, productID string) (int, error) {
+	committed := 0
+	for _, hold := range m.holds {
+		if hold.ProductID == productID {
+			committed += hold.Quantity
+		}
+	}
+	return committed, nil
+}
+
+func TestInventoryLedger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":5}`))
+	}))
+	defer server.Close()
+
+	repo := &reservationRepository{stored: map[string]repository.Order{}}
+	ledger := &mockLedger{holds: map[string]inventory.Hold{}}
+	service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, server.URL,
+		WithReservations(15*time.Minute), WithInventoryLedger(ledger))
+
+	order, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 4, Reserve: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if hold, ok := ledger.holds[order.ID]; !ok || hold.Quantity != 4 || !hold.ExpiresAt.Equal(*order.ReservedUntil) {
+		t.Errorf("Expected a hold of 4 until %v, got %+v", order.ReservedUntil, hold)
+	}
+
+	if _, err := service.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "valid-product", Quantity: 2}); !errors.Is(err, errInsufficientStock) {
+		t.Errorf("Expected held stock to be unavailable, got %v", err)
+	}
+
+	repo.stored[order.ID] = *order
+	if _, err := service.ConfirmOrder(context.Background(), order.ID); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, ok := ledger.holds[order.ID]; ok {
+		t.Errorf("Expected the hold to be released once the reservation is confirmed")
+	}
+}
+
+func TestReorderOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"12.0", "qty":5}`))
+	}))
+	defer server.Close()
+
+	repo := &paymentRepository{orders: map[string]*repository.Order{
+		"o1": {ID: "o1", ProductID: "valid-product", CustomerID: "c1", Quantity: 2, TotalPrice: 16, DiscountCode: "SAVE", Status: repository.StatusPending},
+	}}
+	publisher := &mockPublisher{}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, server.URL)
+
+	order, err := service.ReorderOrder(context.Background(), "o1", ReorderRequest{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if order.ID == "o1" || order.CustomerID != "c1" || order.Quantity != 2 || order.TotalPrice != 24 || order.DiscountCode != "" {
+		t.Errorf("Expected a fresh order for c1 at today's price without the discount, got %+v", order)
+	}
+	if !slices.Contains(publisher.patterns, "order.reordered") {
+		t.Errorf("Expected order.reordered, got %v", publisher.patterns)
+	}
+
+	if order, err := service.ReorderOrder(context.Background(), "o1", ReorderRequest{Quantity: 1}); err != nil || order.Quantity != 1 {
+		t.Errorf("Expected the quantity to be overridden, got %v, %v", order, err)
+	}
+
+	_, err = service.ReorderOrder(context.Background(), "missing", ReorderRequest{})
+	var svcErr *Error
+	if !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotFound {
+		t.Errorf("Expected %s, got %v", CodeOrderNotFound, err)
+	}
+}
+
+type mockSubscriptionStore struct {
+	due       []subscription.Subscription
+	recorded  map[string]subscription.Subscription
+	suspended []string
+}
+
+func (m *mockSubscriptionStore) Due(ctx context.Context, now time.Time, limit int) ([]subscription.Subscription, error) {
+	return m.due, nil
+}
+func (m *mockSubscriptionStore) Claim(ctx context.Context, sub *subscription.Subscription, now time.Time) (bool, error) {
+	if !sub.Retrying(now) {
+		sub.NextRunAt = subscription.Next(sub.Interval, sub.NextRunAt)
+	}
+	sub.RetryAt = nil
+	return true, nil
+}
+func (m *mockSubscriptionStore) Record(ctx context.Context, sub *subscription.Subscription) error {
+	m.recorded[sub.ID] = *sub
+	return nil
+}
+func (m *mockSubscriptionStore) Suspend(ctx context.Context, id string) error {
+	m.suspended = append(m.suspended, id)
+	return nil
+}
+
+func TestSubscriptionOrders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/products/valid-product" {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":5}`))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	store := &mockSubscriptionStore{recorded: map[string]subscription.Subscription{}}
+	gateway := &mockPaymentGateway{}
+	publisher := &mockPublisher{}
+	retry := backoff.Policy{Initial: time.Hour, Max: 4 * time.Hour}
+	repo := &paymentRepository{orders: map[string]*repository.Order{}}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, server.URL,
+		WithPaymentGateway(gateway, time.Minute), WithSubscriptions(store, retry, 2))
+	now := time.Now()
+
+	t.Run("due cycles place an order", func(t *testing.T) {
+		store.due = []subscription.Subscription{
+			{ID: "s1", CustomerID: "c1", ProductID: "valid-product", Quantity: 2, Interval: subscription.IntervalWeekly, NextRunAt: now.Add(-time.Minute)},
+			{ID: "s2", CustomerID: "c1", ProductID: "missing", Quantity: 1, Interval: subscription.IntervalWeekly, NextRunAt: now.Add(-time.Minute)},
+		}
+		n, err := service.GenerateSubscriptionOrders(context.Background())
+		if err != nil || n != 1 {
+			t.Fatalf("Expected 1 generated order, got %d, %v", n, err)
+		}
+		s := store.recorded["s1"]
+		if s.LastOrderID == "" || s.RetryAt != nil {
+			t.Errorf("Expected the order to be recorded without a retry, got %+v", s)
+		}
+		if o := repo.orders[s.LastOrderID]; o == nil || o.Status != repository.StatusPending || len(gateway.captured) != 1 {
+			t.Errorf("Expected the order's payment to be captured, got %+v, captures %v", o, gateway.captured)
+		}
+		if s := store.recorded["s2"]; s.LastError == "" || s.RetryAt != nil {
+			t.Errorf("Expected the cycle to be skipped, got %+v", s)
+		}
+		if !slices.Contains(publisher.patterns, "subscription.order_generated") || !slices.Contains(publisher.patterns, "subscription.cycle_skipped") {
+			t.Errorf("Expected order_generated and cycle_skipped, got %v", publisher.patterns)
+		}
+	})
+
+	t.Run("declined payments are retried, then the subscription is suspended", func(t *testing.T) {
+		gateway.intentErr = payment.ErrPaymentDeclined
+		store.due = []subscription.Subscription{
+			{ID: "s3", CustomerID: "c1", ProductID: "valid-product", Quantity: 1, Interval: subscription.IntervalMonthly, NextRunAt: now.Add(-time.Minute)},
+		}
+		if _, err := service.GenerateSubscriptionOrders(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		s := store.recorded["s3"]
+		if s.FailedAttempts != 1 || s.RetryAt == nil || s.RetryAt.Before(now.Add(time.Hour)) {
+			t.Fatalf("Expected a retry in an hour, got %+v", s)
+		}
+
+		past := now.Add(-time.Second)
+		s.RetryAt = &past
+		store.due = []subscription.Subscription{s}
+		if _, err := service.GenerateSubscriptionOrders(context.Background()); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !slices.Equal(store.suspended, []string{"s3"}) || !slices.Contains(publisher.patterns, "subscription.suspended") {
+			t.Errorf("Expected s3 to be suspended, got %v, %v", store.suspended, publisher.patterns)
+		}
+		if got := store.recorded["s3"].NextRunAt; !got.Equal(s.NextRunAt) {
+			t.Errorf("Expected a retry not to advance the cycle, got %v", got)
+		}
+	})
+
+	t.Run("declined captures are retried and the order expired", func(t *testing.T) {
+		gateway.intentErr = nil
+		gateway.captureErr = payment.ErrPaymentDeclined
+		defer func() { gateway.captureErr = nil }()
+		store.due = []subscription.Subscription{
+			{ID: "s4", CustomerID: "c1", ProductID: "valid-product", Quantity: 1, Interval: subscription.IntervalMonthly, NextRunAt: now.Add(-time.Minute)},
+		}
+		n, err := service.GenerateSubscriptionOrders(context.Background())
+		if err != nil || n != 0 {
+			t.Fatalf("Expected no generated order, got %d, %v", n, err)
+		}
+		s := store.recorded["s4"]
+		if s.FailedAttempts != 1 || s.RetryAt == nil || s.LastOrderID != "" {
+			t.Errorf("Expected a payment retry, got %+v", s)
+		}
+		for _, o := range repo.orders {
+			if o.Status == repository.StatusAwaitingPayment {
+				t.Errorf("Expected the unpaid order %s to be expired", o.ID)
+			}
+		}
+	})
+}
+
+type mockOutbox struct {
+	patterns []string
+}
+
+func (m *mockOutbox) Add(pattern string, data interface{}) error {
+	m.patterns = append(m.patterns, pattern)
+	return nil
+}
+
+func TestOutboxPublisher(t *testing.T) {
+	broker := &mockPublisher{}
+	box := &mockOutbox{}
+	outboxOnly := false
+	publisher := NewOutboxPublisher(broker, box, func() bool { return outboxOnly })
+
+	publisher.Publish("order.rejected", nil)
+	if len(broker.patterns) != 1 || len(box.patterns) != 0 {
+		t.Errorf("Expected the event to go to the broker, got broker %v, outbox %v", broker.patterns, box.patterns)
+	}
+
+	broker.shouldFail = true
+	publisher.Publish("order.rejected", nil)
+	if len(box.patterns) != 1 {
+		t.Errorf("Expected a failed publish to be spooled, got %v", box.patterns)
+	}
+
+	broker.shouldFail = false
+	outboxOnly = true
+	publisher.PublishOrderCreated(&repository.Order{ID: "o1"})
+	if len(broker.patterns) != 1 || len(box.patterns) != 2 || box.patterns[1] != "order.created" {
+		t.Errorf("Expected the broker to be skipped in outbox-only mode, got broker %v, outbox %v", broker.patterns, box.patterns)
+	}
+}
+
+type mockBatchPublisher struct {
+	mu         sync.Mutex
+	shouldFail bool
+	batches    [][]Event
+}
+
+func (m *mockBatchPublisher) PublishBatch(events []Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shouldFail {
+		return errors.New("publish failed")
+	}
+	m.batches = append(m.batches, events)
+	return nil
+}
+
+func (m *mockBatchPublisher) sizes() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sizes []int
+	for _, b := range m.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func TestBatchingPublisher(t *testing.T) {
+	broker := &mockBatchPublisher{}
+	box := &mockOutbox{}
+	publisher := NewBatchingPublisher(broker, box, 3, time.Hour, []string{"order.paid"})
+
+	publisher.Publish("order.created", nil)
+	publisher.Publish("order.created", nil)
+	if sizes := broker.sizes(); len(sizes) != 0 {
+		t.Fatalf("Expected events to wait for a full batch, got %v", sizes)
+	}
+	publisher.Publish("order.created", nil)
+	if sizes := broker.sizes(); !slices.Equal(sizes, []int{3}) {
+		t.Errorf("Expected a full batch to be sent, got %v", sizes)
+	}
+
+	publisher.Publish("order.created", nil)
+	publisher.Publish("order.paid", nil)
+	if sizes := broker.sizes(); !slices.Equal(sizes, []int{3, 2}) {
+		t.Errorf("Expected a synchronous event to flush the batch, got %v", sizes)
+	}
+
+	broker.shouldFail = true
+	publisher.Publish("order.created", nil)
+	publisher.Flush()
+	if !slices.Equal(box.patterns, []string{"order.created"}) {
+		t.Errorf("Expected a failed batch to be spooled, got %v", box.patterns)
+	}
+
+	broker.shouldFail = false
+	lingering := NewBatchingPublisher(broker, box, 100, 10*time.Millisecond, nil)
+	lingering.Publish("order.created", nil)
+	deadline := time.Now().Add(time.Second)
+	for len(broker.sizes()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sizes := broker.sizes(); !slices.Equal(sizes, []int{3, 2, 1}) {
+		t.Errorf("Expected the batch to be sent after linger, got %v", sizes)
+	}
+}
+
+type blockingPublisher struct {
+	mockPublisher
+	started chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingPublisher) Publish(pattern string, data interface{}) error {
+	m.started <- struct{}{}
+	<-m.release
+	return m.mockPublisher.Publish(pattern, data)
+}
+
+func TestBufferedPublisher(t *testing.T) {
+	broker := &blockingPublisher{started: make(chan struct{}, 10), release: make(chan struct{})}
+	box := &mockOutbox{}
+	publisher := NewBufferedPublisher(broker, box, 1)
+
+	close(broker.release)
+	publisher.Publish("order.rejected", nil)
+	<-broker.started
+	if len(broker.patterns) != 1 {
+		t.Fatalf("Expected a synchronous publish before Run, got %v", broker.patterns)
+	}
+	broker.release = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		publisher.Run(ctx)
+		close(done)
+	}()
+	for running := false; !running; {
+		publisher.mu.RLock()
+		running = publisher.running
+		publisher.mu.RUnlock()
+	}
+
+	publisher.Publish("order.created", nil)
+	<-broker.started
+	publisher.Publish("order.paid", nil)
+	publisher.Publish("order.rejected", nil)
+	if !slices.Equal(box.patterns, []string{"order.rejected"}) {
+		t.Errorf("Expected the overflowing event in the outbox, got %v", box.patterns)
+	}
+
+	cancel()
+	close(broker.release)
+	<-done
+	if total := len(broker.patterns) + len(box.patterns); total != 4 {
+		t.Errorf("Expected every event to be published or spooled, got broker %v, outbox %v", broker.patterns, box.patterns)
+	}
+	if len(publisher.queue) != 0 {
+		t.Errorf("Expected an empty buffer after Run, got %d events", len(publisher.queue))
+	}
+}
+
+func TestReplayEvent(t *testing.T) {
+	repo := &paymentRepository{orders: map[string]*repository.Order{
+		"pending":  {ID: "pending", ProductID: "p1", Status: repository.StatusPending},
+		"rejected": {ID: "rejected", ProductID: "p1", Status: repository.StatusRejected},
+		"awaiting": {ID: "awaiting", ProductID: "p1", Status: repository.StatusAwaitingPayment},
+	}}
+	publisher := &mockPublisher{}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, "")
+
+	for _, id := range []string{"pending", "rejected"} {
+		if _, err := service.ReplayEvent(context.Background(), id); err != nil {
+			t.Fatalf("Expected no error for %s, got %v", id, err)
+		}
+	}
+	if want := []string{"order.created", "order.rejected"}; !slices.Equal(publisher.patterns, want) {
+		t.Errorf("Expected %v, got %v", want, publisher.patterns)
+	}
+
+	var svcErr *Error
+	if _, err := service.ReplayEvent(context.Background(), "awaiting"); !errors.As(err, &svcErr) || svcErr.Code != CodeNothingToReplay {
+		t.Errorf("Expected %s, got %v", CodeNothingToReplay, err)
+	}
+	if _, err := service.ReplayEvent(context.Background(), "missing"); !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotFound {
+		t.Errorf("Expected %s, got %v", CodeOrderNotFound, err)
+	}
+}
+
+type revisionRepository struct {
+	mockOrderRepository
+	revisions []repository.OrderRevision
+}
+
+func (m *revisionRepository) GetRevisions(orderID string) ([]repository.OrderRevision, error) {
+	return m.revisions, nil
+}
+
+func TestGetRevisionDiff(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	first := repository.Order{ID: "o1", ProductID: "p1", Quantity: 2, TotalPrice: 20, Status: repository.StatusOnHold, HoldReason: "fraud", CreatedAt: created}
+	first.StatusChangedAt = &created
+	second := first
+	second.Status = repository.StatusPending
+	second.CreatedAt = created.In(time.FixedZone("WIB", 7*3600))
+	sameChange := created
+	second.StatusChangedAt = &sameChange
+	repo := &revisionRepository{revisions: []repository.OrderRevision{
+		{OrderID: "o1", Number: 1, Snapshot: first},
+		{OrderID: "o1", Number: 2, Snapshot: second},
+	}}
+	service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, "")
+
+	diff, err := service.GetRevisionDiff(context.Background(), "o1", 2)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []FieldChange{{Field: "Status", From: repository.StatusOnHold, To: repository.StatusPending}}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("Expected %+v, got %+v", want, diff.Changes)
+	}
+
+	diff, err = service.GetRevisionDiff(context.Background(), "o1", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, change := range diff.Changes {
+		if change.From != nil {
+			t.Errorf("Expected no previous value in the first revision, got %+v", change)
+		}
+		fields[change.Field] = true
+	}
+	if !fields["ID"] || !fields["HoldReason"] || fields["DiscountCode"] {
+		t.Errorf("Expected the set fields of the first revision, got %+v", diff.Changes)
+	}
+
+	var svcErr *Error
+	if _, err := service.GetRevisionDiff(context.Background(), "o1", 3); !errors.As(err, &svcErr) || svcErr.Code != CodeRevisionNotFound {
+		t.Errorf("Expected %s, got %v", CodeRevisionNotFound, err)
+	}
+}
+
+func TestDecorate(t *testing.T) {
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, "http://products.invalid")
+
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, op string, call func(ctx context.Context) error) error {
+			calls = append(calls, name+":"+op)
+			return call(ctx)
+		}
+	}
+	decorated := Decorate(service, record("outer"), AuthorizationInterceptor(AdminOperations...), record("inner"))
+
+	var svcErr *Error
+	if _, err := decorated.ApproveOrder(context.Background(), "o1"); !errors.As(err, &svcErr) || svcErr.Code != CodeForbidden {
+		t.Errorf("Expected %s without an actor, got %v", CodeForbidden, err)
+	}
+	if want := []string{"outer:ApproveOrder"}; !slices.Equal(calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+
+	calls = nil
+	ctx := audit.WithActor(context.Background(), "alice")
+	if _, err := decorated.ApproveOrder(ctx, "o1"); !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotFound {
+		t.Errorf("Expected %s from the service, got %v", CodeOrderNotFound, err)
+	}
+	if want := []string{"outer:ApproveOrder", "inner:ApproveOrder"}; !slices.Equal(calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, calls)
+	}
+
+	calls = nil
+	if _, err := decorated.GetOrder(context.Background(), "o1"); !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotFound {
+		t.Errorf("Expected %s from the service, got %v", CodeOrderNotFound, err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("Expected non-admin calls to pass authorization, got %v", calls)
+	}
+}
+
+func TestTracingFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})), 1)
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, "http://products.invalid", WithLogger(logger))
+	decorated := Decorate(service, TracingInterceptor(logger), LoggingInterceptor(logger))
+
+	if _, err := decorated.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "p1", Quantity: 1}); err == nil {
+		t.Fatal("Expected an error without product-service")
+	}
+
+	var msgs []string
+	traceIDs := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Expected JSON log lines, got %q", line)
+		}
+		msgs = append(msgs, entry["msg"].(string))
+		traceID, _ := entry["trace_id"].(string)
+		if len(traceID) != 32 || entry["span_id"] == nil {
+			t.Errorf("Expected %q to carry the trace and span ID, got %v", entry["msg"], entry)
+		}
+		traceIDs[traceID] = true
+	}
+	if want := []string{"failed to fetch product", "order service call failed", "span"}; !slices.Equal(msgs, want) {
+		t.Errorf("Expected log lines %v, got %v", want, msgs)
+	}
+	if len(traceIDs) != 1 {
+		t.Errorf("Expected the lines of one call to share a trace ID, got %v", traceIDs)
+	}
+}
+
+// discardPublisher publishes nothing, so benchmarks do not accumulate
+// events.
+type discardPublisher struct{}
+
+func (discardPublisher) PublishOrderCreated(order *repository.Order) error { return nil }
+func (discardPublisher) Publish(pattern string, data interface{}) error    { return nil }
+
+// discardWriter drops log output without being io.Discard, which slog
+// handlers would recognise and skip formatting for.
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+// BenchmarkCreateOrder measures a decorated CreateOrder with the logging of
+// production: info level, debug with sampled lines and debug with every
+// line.
+func BenchmarkCreateOrder(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p1", "name":"Test", "price":"10.0", "qty":1000000}`))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name       string
+		level      slog.Level
+		sampleRate float64
+	}{
+		{"info", slog.LevelInfo, 1},
+		{"debug-sampled", slog.LevelDebug, 0.01},
+		{"debug", slog.LevelDebug, 1},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			logger := logging.New(slog.New(slog.NewJSONHandler(discardWriter{}, &slog.HandlerOptions{Level: tc.level})), tc.sampleRate)
+			service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, discardPublisher{}, server.URL,
+				WithLogger(logger), WithProductCache(time.Hour, 0))
+			decorated := Decorate(service, TracingInterceptor(logger), LoggingInterceptor(logger))
+			req := CreateOrderRequest{ProductID: "p1", CustomerID: "c1", Quantity: 1}
+			ctx := context.Background()
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := decorated.CreateOrder(ctx, req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPaymentDiscrepancies(t *testing.T) {
+	s := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, "")
+	intent := func(status string, amount float64) *payment.Intent {
+		return &payment.Intent{ID: "pi-1", Status: status, Amount: amount, Currency: "USD"}
+	}
+	tests := []struct {
+		name   string
+		status string
+		intent *payment.Intent
+		want   []string
+	}{
+		{"confirmed and captured", repository.StatusPending, intent(payment.IntentCaptured, 20), nil},
+		{"awaiting and authorized", repository.StatusAwaitingPayment, intent(payment.IntentAuthorized, 20), nil},
+		{"expired and cancelled", repository.StatusPaymentExpired, intent(payment.IntentCancelled, 0), nil},
+		{"unknown intent", repository.StatusPending, nil, []string{reconciliation.KindMissingIntent}},
+		{"captured while awaiting", repository.StatusAwaitingPayment, intent(payment.IntentCaptured, 20), []string{reconciliation.KindCapturedNotConfirmed}},
+		{"confirmed not captured", repository.StatusPaid, intent(payment.IntentAuthorized, 20), []string{reconciliation.KindNotCaptured}},
+		{"captured after expiry", repository.StatusPaymentExpired, intent(payment.IntentCaptured, 20), []string{reconciliation.KindCapturedForClosedOrder}},
+		{"other amount", repository.StatusPending, intent(payment.IntentCaptured, 25), []string{reconciliation.KindAmountMismatch}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := &repository.Order{ID: "o1", Status: tt.status, TotalPrice: 20, Currency: "USD", PaymentIntentID: "pi-1"}
+			var kinds []string
+			for _, d := range s.paymentDiscrepancies(order, tt.intent) {
+				kinds = append(kinds, d.Kind)
+			}
+			if !slices.Equal(kinds, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, kinds)
+			}
+		})
+	}
+}
+
+func TestDomainEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p1", "name":"Test", "price":"10.0", "qty":100}`))
+	}))
+	defer server.Close()
+
+	bus := events.NewBus()
+	var got []string
+	bus.Subscribe("test", func(_ context.Context, ev events.Event) error {
+		got = append(got, ev.Name+":"+ev.Order.ID)
+		return nil
+	}, events.OrderCreated, events.OrderPlaced)
+
+	svc := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL, WithEventBus(bus))
+	order, err := svc.CreateOrder(context.Background(), CreateOrderRequest{ProductID: "p1", Quantity: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []string{"order.placed:" + order.ID, "order.created:" + order.ID}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected events %v, got %v", want, got)
+	}
+}
+
+type fakeReporter struct {
+	mu      sync.Mutex
+	reports []errreport.Report
+}
+
+func (f *fakeReporter) Report(_ context.Context, r errreport.Report) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reports = append(f.reports, r)
+}
+
+func TestErrorReports(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/products/valid-product" {
+			w.Write([]byte(`{"id":"valid-product", "name":"Test", "price":"10.0", "qty":100}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	reporter := &fakeReporter{}
+	publisher := &mockPublisher{shouldFail: true}
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, publisher, server.URL, WithReporter(reporter))
+	ctx := logging.WithFields(context.Background(), slog.String("trace_id", "t1"))
+
+	if _, err := service.CreateOrder(ctx, CreateOrderRequest{ProductID: "down", Quantity: 1}); !errors.Is(err, errProductUnavailable) {
+		t.Fatalf("Expected %v, got %v", errProductUnavailable, err)
+	}
+	order, err := service.CreateOrder(ctx, CreateOrderRequest{ProductID: "valid-product", Quantity: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reporter.reports) != 2 {
+		t.Fatalf("Expected 2 reports, got %+v", reporter.reports)
+	}
+	upstream, publish := reporter.reports[0], reporter.reports[1]
+	if upstream.Class != errreport.ClassUpstream || upstream.Tags["upstream"] != "product-service" || upstream.Tags["product_id"] != "down" || upstream.Tags["trace_id"] != "t1" {
+		t.Errorf("Expected an upstream report for product-service with the trace, got %+v", upstream)
+	}
+	if publish.Class != errreport.ClassPublish || publish.Tags["pattern"] != "order.created" || publish.Tags["order_id"] != order.ID {
+		t.Errorf("Expected a publish report for the order, got %+v", publish)
+	}
+}
+
+type mockCartClient struct {
+	carts map[string]*cart.Cart
+}
+
+func (m *mockCartClient) GetCart(ctx context.Context, cartID string) (*cart.Cart, error) {
+	if c, ok := m.carts[cartID]; ok {
+		return c, nil
+	}
+	return nil, cart.ErrCartNotFound
+}
+
+// cartRepository stores batches and refuses a second batch for a cart, as
+// the cart_checkouts primary key does. With hideCarts its Search misses
+// them, like a concurrent checkout that has not committed yet.
+type cartRepository struct {
+	paymentRepository
+	checkedOut map[string]bool
+	hideCarts  bool
+}
+
+func (m *cartRepository) CreateBatch(orders []repository.Order) error {
+	if cartID := orders[0].CartID; cartID != "" {
+		if m.checkedOut[cartID] {
+			return repository.ErrCartCheckedOut
+		}
+		m.checkedOut[cartID] = true
+	}
+	for i := range orders {
+		m.Create(&orders[i])
+	}
+	return nil
+}
+func (m *cartRepository) Search(ctx context.Context, filter repository.OrderFilter, limit, offset int) ([]repository.Order, error) {
+	var found []repository.Order
+	for _, o := range m.orders {
+		if !m.hideCarts && filter.CartID != "" && o.CartID == filter.CartID {
+			found = append(found, *o)
+		}
+	}
+	return found, nil
+}
+
+func TestCheckoutCart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products/a":
+			w.Write([]byte(`{"id":"a", "name":"A", "price":"30.0", "qty":10}`))
+		case "/products/b":
+			w.Write([]byte(`{"id":"b", "name":"B", "price":"10.0", "qty":10}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	carts := &mockCartClient{carts: map[string]*cart.Cart{
+		"cart-1": {ID: "cart-1", CustomerID: "c1", Items: []cart.Item{{ProductID: "a", Quantity: 1}, {ProductID: "b", Quantity: 1}}},
+		"cart-2": {ID: "cart-2", CustomerID: "c1", Items: []cart.Item{{ProductID: "b", Quantity: 2}}},
+		"empty":  {ID: "empty", CustomerID: "c1"},
+	}}
+	repo := &cartRepository{paymentRepository: paymentRepository{orders: map[string]*repository.Order{}}, checkedOut: map[string]bool{}}
+	publisher := &mockPublisher{}
+	service := NewOrderService(repo, &mockOrderCache{}, publisher, server.URL,
+		WithCartClient(carts), WithShippingFee(4), WithDiscountCodes(map[string]Discount{"OFF": {Amount: 2}}))
+
+	t.Run("shipping and fixed discount are split across lines", func(t *testing.T) {
+		result, err := service.CheckoutCart(context.Background(), CheckoutCartRequest{CartID: "cart-1", DiscountCode: "off"})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if result.Total != 42 {
+			t.Errorf("Expected a total of 40 - 2 + 4 = 42, got %v", result.Total)
+		}
+		want := []struct{ discount, shipping float64 }{{1.5, 3}, {0.5, 1}}
+		for i, o := range result.Orders {
+			if o.DiscountAmount != want[i].discount || o.ShippingFee != want[i].shipping || o.CartID != "cart-1" {
+				t.Errorf("Expected line %d to carry discount %v and shipping %v, got %+v", i, want[i].discount, want[i].shipping, o)
+			}
+		}
+		if !slices.Contains(publisher.patterns, "cart.checked_out") {
+			t.Errorf("Expected cart.checked_out, got %v", publisher.patterns)
+		}
+	})
+
+	t.Run("a checked out cart is refused", func(t *testing.T) {
+		_, err := service.CheckoutCart(context.Background(), CheckoutCartRequest{CartID: "cart-1"})
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeCartCheckedOut {
+			t.Errorf("Expected %s, got %v", CodeCartCheckedOut, err)
+		}
+	})
+
+	t.Run("a concurrent checkout is refused by the repository", func(t *testing.T) {
+		repo.hideCarts = true
+		defer func() { repo.hideCarts = false }()
+		if _, err := service.CheckoutCart(context.Background(), CheckoutCartRequest{CartID: "cart-2"}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		_, err := service.CheckoutCart(context.Background(), CheckoutCartRequest{CartID: "cart-2"})
+		var svcErr *Error
+		if !errors.As(err, &svcErr) || svcErr.Code != CodeCartCheckedOut {
+			t.Errorf("Expected %s, got %v", CodeCartCheckedOut, err)
+		}
+	})
+
+	t.Run("missing and empty carts", func(t *testing.T) {
+		for cartID, code := range map[string]string{"missing": CodeCartNotFound, "empty": CodeCartEmpty} {
+			_, err := service.CheckoutCart(context.Background(), CheckoutCartRequest{CartID: cartID})
+			var svcErr *Error
+			if !errors.As(err, &svcErr) || svcErr.Code != code {
+				t.Errorf("Expected %s for cart %s, got %v", code, cartID, err)
+			}
+		}
+	})
+}
+
+// searchRepository answers searches from Postgres with a marker order.
+type searchRepository struct {
+	mockOrderRepository
+}
+
+func (r *searchRepository) Search(context.Context, repository.OrderFilter, int, int) ([]repository.Order, error) {
+	return []repository.Order{{ID: "postgres"}}, nil
+}
+
+type stubSearchIndex struct{}
+
+func (stubSearchIndex) Search(context.Context, repository.OrderFilter, int, int) ([]repository.Order, error) {
+	return []repository.Order{{ID: "opensearch"}}, nil
+}
+
+func TestSearchOrders(t *testing.T) {
+	cases := []struct {
+		name   string
+		index  bool
+		flag   bool
+		filter repository.OrderFilter
+		want   string
+	}{
+		{"without an index", false, true, repository.OrderFilter{}, "postgres"},
+		{"flag off", true, false, repository.OrderFilter{}, "postgres"},
+		{"flag on", true, true, repository.OrderFilter{Status: repository.StatusPending}, "opensearch"},
+		{"tag filters", true, true, repository.OrderFilter{Tags: []string{"vip"}}, "postgres"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			flags := featureflags.New(featureflags.NewStaticProvider(map[string]featureflags.Rule{
+				featureflags.OpenSearchSearch: {Enabled: tc.flag},
+			}))
+			opts := []Option{WithFeatureFlags(flags)}
+			if tc.index {
+				opts = append(opts, WithSearchIndex(stubSearchIndex{}))
+			}
+			s := NewOrderService(&searchRepository{}, &mockOrderCache{}, &mockPublisher{}, "", opts...)
+
+			orders, err := s.SearchOrders(context.Background(), tc.filter, 10, 0)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(orders) != 1 || orders[0].ID != tc.want {
+				t.Errorf("Expected the search to be served by %s, got %v", tc.want, orders)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package service
+
+import (
+	"log"
+	"order-service/internal/repository"
+)
+
+// IOutbox spools events that could not be published.
+type IOutbox interface {
+	Add(pattern string, data interface{}) error
+}
+
+// OutboxPublisher publishes through next and falls back to the outbox when
+// publishing fails. While outboxOnly reports true it does not try the
+// broker at all.
+type OutboxPublisher struct {
+	next       IPublisher
+	outbox     IOutbox
+	outboxOnly func() bool
+}
+
+var _ IPublisher = &OutboxPublisher{}
+
+func NewOutboxPublisher(next IPublisher, outbox IOutbox, outboxOnly func() bool) *OutboxPublisher {
+	return &OutboxPublisher{next: next, outbox: outbox, outboxOnly: outboxOnly}
+}
+
+func (p *OutboxPublisher) PublishOrderCreated(order *repository.Order) error {
+	return p.Publish("order.created", orderCreatedData(order))
+}
+
+func (p *OutboxPublisher) Publish(pattern string, data interface{}) error {
+	if !p.outboxOnly() {
+		err := p.next.Publish(pattern, data)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Failed to publish %s event, spooling to outbox: %v", pattern, err)
+	}
+	return p.outbox.Add(pattern, data)
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"order-service/internal/currency"
+	"order-service/internal/domain"
+	"order-service/internal/experiment"
+	"order-service/internal/repository"
+	"order-service/internal/rounding"
+)
+
+type Discount = domain.Discount
+
+// ParseDiscountCodes reads a JSON object mapping codes to discounts. Codes
+// are matched case-insensitively.
+func ParseDiscountCodes(data string) (map[string]Discount, error) {
+	codes := map[string]Discount{}
+	if data == "" {
+		return codes, nil
+	}
+	var raw map[string]Discount
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse discount codes: %w", err)
+	}
+	for code, d := range raw {
+		codes[strings.ToUpper(code)] = d
+	}
+	return codes, nil
+}
+
+// Quote is the price breakdown of an order, computed the same way for a
+// dry run and for the order that is actually stored.
+type Quote struct {
+	ProductID    string  `json:"productId"`
+	Quantity     int     `json:"quantity"`
+	UnitPrice    float64 `json:"unitPrice"`
+	Subtotal     float64 `json:"subtotal"`
+	DiscountCode string  `json:"discountCode,omitempty"`
+	Discount     float64 `json:"discount"`
+	Tax          float64 `json:"tax"`
+	ShippingFee  float64 `json:"shippingFee"`
+	Total        float64 `json:"total"`
+	Experiment   string  `json:"experiment,omitempty"`
+	Variant      string  `json:"variant,omitempty"`
+	Backorder    bool    `json:"backorder,omitempty"`
+	Currency     string  `json:"currency,omitempty"`
+	// Conversion is set when the customer pays in another currency.
+	Conversion *Conversion `json:"conversion,omitempty"`
+}
+
+// Conversion is a quote's amounts in the customer's currency.
+type Conversion struct {
+	Currency    string  `json:"currency"`
+	Rate        float64 `json:"rate"`
+	Subtotal    float64 `json:"subtotal"`
+	Discount    float64 `json:"discount"`
+	Tax         float64 `json:"tax"`
+	ShippingFee float64 `json:"shippingFee"`
+	Total       float64 `json:"total"`
+}
+
+// TenantPricing are a tenant's pricing rules. TaxRate and ShippingFee
+// replace the service-wide values when set.
+type TenantPricing struct {
+	TaxRate          *float64              `json:"taxRate,omitempty"`
+	ShippingFee      *float64              `json:"shippingFee,omitempty"`
+	BulkDiscounts    []domain.BulkDiscount `json:"bulkDiscounts,omitempty"`
+	FreeShippingOver float64               `json:"freeShippingOver,omitempty"`
+}
+
+// ParseTenantPricing reads a JSON object mapping tenant IDs to their
+// pricing rules.
+func ParseTenantPricing(data string) (map[string]TenantPricing, error) {
+	rules := map[string]TenantPricing{}
+	if data == "" {
+		return rules, nil
+	}
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant pricing: %w", err)
+	}
+	for tenant, r := range rules {
+		for _, b := range r.BulkDiscounts {
+			if b.MinQuantity < 1 || b.Percent <= 0 || b.Percent > 100 {
+				return nil, fmt.Errorf("tenant %s: bulk discounts need a minQuantity of at least 1 and a percent between 0 and 100", tenant)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// initPricing builds the default pricing engine and one per tenant with
+// pricing rules, unless an engine was set for them.
+func (s *OrderService) initPricing() {
+	rules := domain.PricingRules{
+		ShippingFee:   s.shippingFee,
+		TaxRate:       s.taxRate,
+		DiscountCodes: s.discounts,
+		Adjust:        []domain.PricingStep{domain.StepFunc(s.applyExperiment)},
+	}
+	if s.engines == nil {
+		s.engines = map[string]domain.PricingEngine{}
+	}
+	if s.engines[""] == nil {
+		s.engines[""] = domain.NewPipeline(rules)
+	}
+	for tenant, tp := range s.tenantPricing {
+		if s.engines[tenant] != nil {
+			continue
+		}
+		r := rules
+		if tp.TaxRate != nil {
+			r.TaxRate = *tp.TaxRate
+		}
+		if tp.ShippingFee != nil {
+			r.ShippingFee = *tp.ShippingFee
+		}
+		r.BulkDiscounts = tp.BulkDiscounts
+		r.FreeShippingOver = tp.FreeShippingOver
+		s.engines[tenant] = domain.NewPipeline(r)
+	}
+}
+
+func (s *OrderService) pricingEngine(tenantID string) domain.PricingEngine {
+	if engine, ok := s.engines[tenantID]; ok {
+		return engine
+	}
+	return s.engines[""]
+}
+
+// applyExperiment prices the order as its customer's variant of the
+// pricing experiment, if one is running.
+func (s *OrderService) applyExperiment(c *domain.Calculation) error {
+	if s.experiment == nil || c.CustomerID == "" {
+		return nil
+	}
+	pricing := experiment.Pricing{Subtotal: c.Subtotal, ShippingFee: c.ShippingFee}
+	c.Experiment = s.experiment.Name
+	c.Variant = s.experiment.Assign(c.CustomerID)
+	s.experiment.Apply(c.Variant, &pricing)
+	c.Subtotal, c.ShippingFee = pricing.Subtotal, pricing.ShippingFee
+	return nil
+}
+
+func (s *OrderService) price(product *ProductResponse, req CreateOrderRequest) (*Quote, error) {
+	q := &Quote{
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+		UnitPrice: product.Price,
+		Currency:  currency.Normalize(product.Currency),
+	}
+	if q.Currency == "" {
+		q.Currency = s.defaultCurrency
+	}
+
+	c := &domain.Calculation{
+		TenantID:     req.TenantID,
+		CustomerID:   req.CustomerID,
+		ProductID:    req.ProductID,
+		Quantity:     req.Quantity,
+		UnitPrice:    product.Price,
+		DiscountCode: req.DiscountCode,
+		Round:        s.rounding.For(req.TenantID, q.Currency).Round,
+		Cart:         req.cartLine,
+	}
+	err := s.pricingEngine(req.TenantID).Price(c)
+	if errors.Is(err, domain.ErrUnknownDiscountCode) {
+		return nil, &Error{Code: CodeInvalidDiscountCode, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+
+	q.Subtotal, q.Discount, q.Tax, q.ShippingFee, q.Total = c.Subtotal, c.Discount, c.Tax, c.ShippingFee, c.Total
+	q.DiscountCode = c.DiscountCode
+	q.Experiment, q.Variant = c.Experiment, c.Variant
+	return q, nil
+}
+
+// convert adds the quote's amounts in the customer's currency, rounded the
+// way that currency requires. Nothing is converted when the customer did
+// not ask for a currency or already uses the product's.
+func (s *OrderService) convert(ctx context.Context, q *Quote, to, tenantID string) error {
+	to = currency.Normalize(to)
+	if to == "" || to == q.Currency {
+		return nil
+	}
+	if s.rates == nil {
+		return &Error{Code: CodeUnsupportedCurrency, Message: "currency conversion is not available"}
+	}
+
+	rate, err := s.rates.Rate(ctx, q.Currency, to)
+	if errors.Is(err, currency.ErrUnsupportedPair) {
+		return &Error{Code: CodeUnsupportedCurrency, Message: fmt.Sprintf("cannot convert %s to %s", q.Currency, to)}
+	} else if err != nil {
+		s.logger.Warn(ctx).Str("from", q.Currency).Str("to", to).Err(err).Msg("failed to get exchange rate")
+		s.reportUpstream(ctx, "exchange-rates", err, map[string]string{"from": q.Currency, "to": to})
+		return errors.New("exchange rate unavailable")
+	}
+
+	round := s.rounding.For(tenantID, to).Round
+	q.Conversion = &Conversion{
+		Currency:    to,
+		Rate:        rate,
+		Subtotal:    round(q.Subtotal * rate),
+		Discount:    round(q.Discount * rate),
+		Tax:         round(q.Tax * rate),
+		ShippingFee: round(q.ShippingFee * rate),
+		Total:       round(q.Total * rate),
+	}
+	return nil
+}
+
+// roundingFor is the policy's strategy for amounts of order in its own
+// currency. Sums and differences of such amounts are rounded with it
+// again to drop float noise.
+func (s *OrderService) roundingFor(order *repository.Order) rounding.Strategy {
+	return s.rounding.For(order.TenantID, order.Currency)
+}
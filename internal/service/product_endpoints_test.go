@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeEndpoints picks the endpoints in turn and records the reports.
+type fakeEndpoints struct {
+	urls    []string
+	next    int
+	reports map[string][]error
+}
+
+func (f *fakeEndpoints) Pick() (string, error) {
+	u := f.urls[f.next%len(f.urls)]
+	f.next++
+	return u, nil
+}
+
+func (f *fakeEndpoints) Report(endpoint string, err error) {
+	f.reports[endpoint] = append(f.reports[endpoint], err)
+}
+
+func TestProductEndpoints(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"p1", "name":"Test", "price":10, "qty":100}`))
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	endpoints := &fakeEndpoints{urls: []string{healthy.URL, broken.URL}, reports: map[string][]error{}}
+	service := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, "", WithProductEndpoints(endpoints))
+	ctx := context.Background()
+
+	if _, err := service.callProductService(ctx, "p1"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := service.callProductService(ctx, "p1"); err == nil {
+		t.Error("Expected an error from the broken endpoint")
+	}
+	if r := endpoints.reports[healthy.URL]; len(r) != 1 || r[0] != nil {
+		t.Errorf("Expected one success for the healthy endpoint, got %v", r)
+	}
+	if r := endpoints.reports[broken.URL]; len(r) != 1 || r[0] == nil {
+		t.Errorf("Expected one failure for the broken endpoint, got %v", r)
+	}
+}
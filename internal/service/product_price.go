@@ -0,0 +1,86 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"order-service/internal/metrics"
+	"strconv"
+)
+
+// Formats of product prices. Product-service has always sent prices as
+// strings ("10.50") and is moving to JSON numbers.
+const (
+	PriceFormatAny    = "any"
+	PriceFormatString = "string"
+	PriceFormatNumber = "number"
+)
+
+// PriceFormats are the formats WithPriceFormat accepts.
+var PriceFormats = []string{PriceFormatAny, PriceFormatString, PriceFormatNumber}
+
+// WithPriceFormat makes product lookups fail when product-service sends a
+// price in another format than format, e.g. PriceFormatNumber once every
+// product-service instance was upgraded, so a rollback to the old format
+// is noticed. The default, PriceFormatAny, accepts both.
+func WithPriceFormat(format string) Option {
+	return func(s *OrderService) { s.priceFormat = format }
+}
+
+// UnmarshalJSON reads a product, accepting its price both as a string, the
+// legacy form, and as a number.
+func (p *ProductResponse) UnmarshalJSON(data []byte) error {
+	type product ProductResponse
+	var raw struct {
+		*product
+		Price json.RawMessage `json:"price"`
+	}
+	raw.product = (*product)(p)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	price, format, err := parsePrice(raw.Price)
+	if err != nil {
+		return err
+	}
+	p.Price, p.priceFormat = price, format
+	return nil
+}
+
+// parsePrice decodes a price sent as a JSON string or number. A missing or
+// null price is zero and has no format.
+func parsePrice(raw json.RawMessage) (float64, string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, "", nil
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, "", fmt.Errorf("invalid price %s: %w", raw, err)
+		}
+		price, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid price %s: %w", raw, err)
+		}
+		return price, PriceFormatString, nil
+	}
+	var price float64
+	if err := json.Unmarshal(raw, &price); err != nil {
+		return 0, "", fmt.Errorf("invalid price %s: %w", raw, err)
+	}
+	return price, PriceFormatNumber, nil
+}
+
+// checkPriceFormat counts the format of a product's price and refuses it
+// when it is not the one WithPriceFormat requires.
+func (s *OrderService) checkPriceFormat(product *ProductResponse) error {
+	if product.priceFormat == "" {
+		return nil
+	}
+	metrics.ProductPriceFormats.WithLabelValues(product.priceFormat).Inc()
+	if s.priceFormat != "" && s.priceFormat != PriceFormatAny && product.priceFormat != s.priceFormat {
+		return fmt.Errorf("price of product %s is a %s, expected a %s", product.ID, product.priceFormat, s.priceFormat)
+	}
+	return nil
+}
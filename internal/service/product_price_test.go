@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProductPriceFormats(t *testing.T) {
+	for _, tc := range []struct {
+		body   string
+		price  float64
+		format string
+	}{
+		{`{"id":"p1","price":"10.50","qty":1}`, 10.5, PriceFormatString},
+		{`{"id":"p1","price":10.5,"qty":1}`, 10.5, PriceFormatNumber},
+		{`{"id":"p1","price":null,"qty":1}`, 0, ""},
+		{`{"id":"p1","qty":1}`, 0, ""},
+	} {
+		var p ProductResponse
+		if err := json.Unmarshal([]byte(tc.body), &p); err != nil {
+			t.Fatalf("%s: expected no error, got %v", tc.body, err)
+		}
+		if p.ID != "p1" || p.Qty != 1 || p.Price != tc.price || p.priceFormat != tc.format {
+			t.Errorf("%s: unexpected product %+v", tc.body, p)
+		}
+	}
+
+	for _, body := range []string{`{"price":true}`, `{"price":"ten"}`, `{"price":" 7 "}`, `{"price":[1]}`} {
+		var p ProductResponse
+		if err := json.Unmarshal([]byte(body), &p); err == nil {
+			t.Errorf("%s: expected an error", body)
+		}
+	}
+}
+
+func TestStrictPriceFormat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/products/legacy" {
+			w.Write([]byte(`{"id":"legacy","price":"10.0","qty":5}`))
+			return
+		}
+		w.Write([]byte(`{"id":"numeric","price":10,"qty":5}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	tolerant := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL)
+	for _, id := range []string{"legacy", "numeric"} {
+		if p, err := tolerant.callProductService(ctx, id); err != nil || p.Price != 10 {
+			t.Errorf("%s: expected price 10, got %v, %v", id, p, err)
+		}
+	}
+
+	strict := NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL, WithPriceFormat(PriceFormatNumber))
+	if _, err := strict.callProductService(ctx, "numeric"); err != nil {
+		t.Errorf("Expected a number price to be accepted, got %v", err)
+	}
+	if _, err := strict.callProductService(ctx, "legacy"); err == nil {
+		t.Error("Expected a string price to be refused")
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"order-service/internal/featureflags"
+	"order-service/internal/metrics"
+)
+
+// IProductSource looks products up in another way than product-service's
+// HTTP API, such as its gRPC API. It returns nil and no error for a
+// product that does not exist.
+type IProductSource interface {
+	Product(ctx context.Context, productID string) (*ProductResponse, error)
+}
+
+// Results of a shadowed lookup.
+const (
+	shadowMatch    = "match"
+	shadowMismatch = "mismatch"
+	shadowError    = "error"
+)
+
+// productShadow sends product lookups to a candidate source next to
+// product-service and compares the answers.
+type productShadow struct {
+	source     IProductSource
+	sampleRate float64
+	timeout    time.Duration
+	wg         sync.WaitGroup
+}
+
+// WithProductShadow sends every product lookup for which the
+// product-shadow flag is on to source as well, in the background, and
+// compares its answer with product-service's. Lookups are still answered
+// by product-service. Mismatches are counted, and logged with probability
+// sampleRate; the candidate gets timeout to answer.
+func WithProductShadow(source IProductSource, sampleRate float64, timeout time.Duration) Option {
+	return func(s *OrderService) {
+		s.shadow = &productShadow{source: source, sampleRate: sampleRate, timeout: timeout}
+	}
+}
+
+// lookupProduct asks product-service and, in shadow mode, the candidate.
+func (s *OrderService) lookupProduct(ctx context.Context, productID string) (*ProductResponse, error) {
+	product, err := s.callProductService(ctx, productID)
+	if s.shadow != nil && s.flags.Enabled(ctx, featureflags.ProductShadow) {
+		s.shadowLookup(ctx, productID, product, err)
+	}
+	return product, err
+}
+
+// shadowLookup compares the candidate's answer with the primary one. Lookups
+// the primary failed are not compared; there is nothing to compare with.
+func (s *OrderService) shadowLookup(ctx context.Context, productID string, primary *ProductResponse, primaryErr error) {
+	if primaryErr != nil && !errors.Is(primaryErr, errProductNotFound) {
+		return
+	}
+	sh := s.shadow
+	sh.wg.Add(1)
+	go func() {
+		defer sh.wg.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sh.timeout)
+		defer cancel()
+		candidate, err := sh.source.Product(ctx, productID)
+		if err != nil {
+			metrics.ProductShadowComparisons.WithLabelValues(shadowError).Inc()
+			s.logger.Debug(ctx).Str("product_id", productID).Err(err).Msg("product shadow lookup failed")
+			return
+		}
+		diff := productDiff(primary, candidate)
+		if len(diff) == 0 {
+			metrics.ProductShadowComparisons.WithLabelValues(shadowMatch).Inc()
+			return
+		}
+		metrics.ProductShadowComparisons.WithLabelValues(shadowMismatch).Inc()
+		if sh.sampleRate >= 1 || rand.Float64() < sh.sampleRate {
+			s.logger.Warn(ctx).Str("product_id", productID).Str("fields", strings.Join(diff, ",")).Msg("product shadow mismatch")
+		}
+	}()
+}
+
+// productDiff names the fields in which two answers differ, or "found"
+// if only one of them knows the product.
+func productDiff(a, b *ProductResponse) []string {
+	if a == nil || b == nil {
+		if (a == nil) != (b == nil) {
+			return []string{"found"}
+		}
+		return nil
+	}
+	var diff []string
+	for _, f := range []struct {
+		name  string
+		equal bool
+	}{
+		{"id", a.ID == b.ID},
+		{"name", a.Name == b.Name},
+		{"price", a.Price == b.Price},
+		{"qty", a.Qty == b.Qty},
+		{"currency", a.Currency == b.Currency},
+		{"category", a.Category == b.Category},
+	} {
+		if !f.equal {
+			diff = append(diff, f.name)
+		}
+	}
+	return diff
+}
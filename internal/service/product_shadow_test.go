@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"order-service/internal/featureflags"
+	"order-service/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeProductSource answers from a map and records what it was asked.
+type fakeProductSource struct {
+	mu       sync.Mutex
+	products map[string]*ProductResponse
+	err      error
+	asked    []string
+}
+
+func (f *fakeProductSource) Product(_ context.Context, productID string) (*ProductResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.asked = append(f.asked, productID)
+	return f.products[productID], f.err
+}
+
+func TestProductShadow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products/same", "/products/stale":
+			w.Write([]byte(`{"id":"` + r.URL.Path[len("/products/"):] + `", "name":"Test", "price":"10.0", "qty":100}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := &fakeProductSource{products: map[string]*ProductResponse{
+		"same":  {ID: "same", Name: "Test", Price: 10, Qty: 100},
+		"stale": {ID: "stale", Name: "Test", Price: 10, Qty: 90},
+		"extra": {ID: "extra", Name: "Test", Price: 10, Qty: 1},
+	}}
+	newService := func(on bool) *OrderService {
+		flags := featureflags.New(featureflags.NewStaticProvider(map[string]featureflags.Rule{
+			featureflags.ProductShadow: {Enabled: on},
+		}))
+		return NewOrderService(&mockOrderRepository{}, &mockOrderCache{}, &mockPublisher{}, server.URL,
+			WithFeatureFlags(flags), WithProductShadow(source, 1, time.Second))
+	}
+	count := func(result string) float64 {
+		return testutil.ToFloat64(metrics.ProductShadowComparisons.WithLabelValues(result))
+	}
+	match, mismatch, failed := count(shadowMatch), count(shadowMismatch), count(shadowError)
+
+	s := newService(true)
+	ctx := context.Background()
+	product, err := s.fetchProductInfo(ctx, "stale")
+	if err != nil || product.Qty != 100 {
+		t.Fatalf("Expected the primary's answer, got %+v, %v", product, err)
+	}
+	if _, err := s.fetchProductInfo(ctx, "extra"); !errors.Is(err, errProductNotFound) {
+		t.Fatalf("Expected the primary's not found, got %v", err)
+	}
+	s.fetchProductInfo(ctx, "same")
+	s.fetchProductInfo(ctx, "unknown")
+	s.shadow.wg.Wait()
+
+	if got := count(shadowMatch) - match; got != 2 {
+		t.Errorf("Expected 2 matches, got %v", got)
+	}
+	if got := count(shadowMismatch) - mismatch; got != 2 {
+		t.Errorf("Expected 2 mismatches, got %v", got)
+	}
+
+	source.err = errors.New("unavailable")
+	s.fetchProductInfo(ctx, "other")
+	s.shadow.wg.Wait()
+	if got := count(shadowError) - failed; got != 1 {
+		t.Errorf("Expected a failed shadow lookup to be counted, got %v", got)
+	}
+
+	source.asked = nil
+	off := newService(false)
+	if _, err := off.fetchProductInfo(ctx, "same"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	off.shadow.wg.Wait()
+	if len(source.asked) != 0 {
+		t.Errorf("Expected no shadow lookups with the flag off, got %v", source.asked)
+	}
+}
+
+func TestProductDiff(t *testing.T) {
+	a := &ProductResponse{ID: "p", Name: "Test", Price: 10, Qty: 5, Currency: "USD"}
+	b := *a
+	if diff := productDiff(a, &b); len(diff) != 0 {
+		t.Errorf("Expected no difference, got %v", diff)
+	}
+	b.Price, b.Currency = 11, "EUR"
+	if diff := productDiff(a, &b); len(diff) != 2 || diff[0] != "price" || diff[1] != "currency" {
+		t.Errorf("Expected price and currency to differ, got %v", diff)
+	}
+	if diff := productDiff(a, nil); len(diff) != 1 || diff[0] != "found" {
+		t.Errorf("Expected a product only one side knows to differ, got %v", diff)
+	}
+	if diff := productDiff(nil, nil); len(diff) != 0 {
+		t.Errorf("Expected two unknown products to match, got %v", diff)
+	}
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"order-service/internal/payment"
+	"order-service/internal/reconciliation"
+	"order-service/internal/repository"
+	"time"
+)
+
+// reconcileBatch is how many orders ReconcilePayments reads at a time.
+const reconcileBatch = 100
+
+// IDiscrepancyStore stores what payment reconciliation found for an order.
+type IDiscrepancyStore interface {
+	Record(ctx context.Context, orderID string, found []reconciliation.Discrepancy, now time.Time) error
+}
+
+// WithPaymentReconciliation cross-checks the payments of orders created in
+// the last lookback against payment-service and stores mismatches in
+// store.
+func WithPaymentReconciliation(store IDiscrepancyStore, lookback time.Duration) Option {
+	return func(s *OrderService) {
+		s.discrepancies = store
+		s.reconcileLookback = lookback
+	}
+}
+
+// ReconcilePayments compares the payment status of recent orders with
+// their intents at payment-service and records the discrepancies. It
+// returns how many it found and is run by the scheduler, nightly by
+// default.
+func (s *OrderService) ReconcilePayments(ctx context.Context) (int, error) {
+	if s.payments == nil || s.discrepancies == nil {
+		return 0, nil
+	}
+	now := time.Now()
+	since := now.Add(-s.reconcileLookback)
+
+	found, afterID := 0, ""
+	for {
+		orders, err := s.repo.GetPaymentOrders(since, afterID, reconcileBatch)
+		if err != nil {
+			return found, err
+		}
+		for i := range orders {
+			order := &orders[i]
+			intent, err := s.payments.GetIntent(ctx, order.PaymentIntentID)
+			if err != nil && !errors.Is(err, payment.ErrIntentNotFound) {
+				if ctx.Err() != nil {
+					return found, ctx.Err()
+				}
+				log.Printf("Failed to look up payment intent of order %s: %v", order.ID, err)
+				continue
+			}
+			discrepancies := s.paymentDiscrepancies(order, intent)
+			if err := s.discrepancies.Record(ctx, order.ID, discrepancies, now); err != nil {
+				return found, err
+			}
+			found += len(discrepancies)
+		}
+		if len(orders) < reconcileBatch {
+			break
+		}
+		afterID = orders[len(orders)-1].ID
+	}
+
+	if found > 0 {
+		log.Printf("Payment reconciliation found %d discrepancies", found)
+	}
+	return found, nil
+}
+
+// paymentDiscrepancies compares an order with its intent; a nil intent is
+// one payment-service does not know.
+func (s *OrderService) paymentDiscrepancies(order *repository.Order, intent *payment.Intent) []reconciliation.Discrepancy {
+	amount, currency := s.intentAmount(order)
+	discrepancy := func(kind string) reconciliation.Discrepancy {
+		d := reconciliation.Discrepancy{
+			Kind:            kind,
+			PaymentIntentID: order.PaymentIntentID,
+			OrderStatus:     order.Status,
+			OrderAmount:     amount,
+			Currency:        currency,
+		}
+		if intent != nil {
+			d.PaymentStatus, d.PaymentAmount = intent.Status, intent.Amount
+		}
+		return d
+	}
+	if intent == nil {
+		return []reconciliation.Discrepancy{discrepancy(reconciliation.KindMissingIntent)}
+	}
+
+	var found []reconciliation.Discrepancy
+	captured := intent.Status == payment.IntentCaptured
+	switch order.Status {
+	case repository.StatusAwaitingPayment:
+		if captured {
+			found = append(found, discrepancy(reconciliation.KindCapturedNotConfirmed))
+		}
+	case repository.StatusPaymentExpired, repository.StatusRejected, repository.StatusCancelled,
+		repository.StatusReservationExpired:
+		if captured {
+			found = append(found, discrepancy(reconciliation.KindCapturedForClosedOrder))
+		}
+	default:
+		if !captured {
+			found = append(found, discrepancy(reconciliation.KindNotCaptured))
+		}
+	}
+	if intent.Amount != 0 && (math.Abs(intent.Amount-amount) >= 0.005 ||
+		(intent.Currency != "" && currency != "" && intent.Currency != currency)) {
+		found = append(found, discrepancy(reconciliation.KindAmountMismatch))
+	}
+	return found
+}
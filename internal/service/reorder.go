@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"order-service/internal/repository"
+)
+
+// ReorderRequest is what a reorder may change from the original order.
+type ReorderRequest struct {
+	// Quantity defaults to the original order's.
+	Quantity int `json:"quantity,omitempty"`
+	// ClientIP is filled in by the handler, not by the client.
+	ClientIP string `json:"-"`
+}
+
+// ReorderOrder places a fresh order for the product, quantity, region and
+// currency of a previous one, for the same customer. It is priced and
+// checked like any new order; the original's discount code is not reused.
+func (s *OrderService) ReorderOrder(ctx context.Context, id string, req ReorderRequest) (*repository.Order, error) {
+	original, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+
+	create := repriceRequest(original)
+	create.DiscountCode = ""
+	create.ClientIP = req.ClientIP
+	if req.Quantity > 0 {
+		create.Quantity = req.Quantity
+	}
+	order, err := s.CreateOrder(ctx, create)
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "order.reordered", reorderedData(order, original))
+	return order, nil
+}
+
+func reorderedData(order, original *repository.Order) map[string]interface{} {
+	return withTags(map[string]interface{}{
+		"orderId":         order.ID,
+		"originalOrderId": original.ID,
+		"customerId":      order.CustomerID,
+	}, order)
+}
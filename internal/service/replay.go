@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/internal/repository"
+)
+
+// ReplayEvent publishes the event for the order's current status again,
+// for when a consumer lost it. It returns the pattern that was published.
+// The event keeps its ID, so consumers that handled the original can
+// recognize the duplicate.
+func (s *OrderService) ReplayEvent(ctx context.Context, id string) (string, error) {
+	order, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return "", &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return "", err
+	}
+
+	pattern, data := replayableEvent(order)
+	if pattern == "" {
+		return "", &Error{
+			Code:    CodeNothingToReplay,
+			Message: fmt.Sprintf("order %s has no event to replay in status %s", order.ID, order.Status),
+		}
+	}
+	if s.deduper != nil {
+		if err := s.deduper.Forget(pattern, data); err != nil {
+			s.logger.Error(ctx).Str("order_id", order.ID).Err(err).Msg("failed to forget replayed event")
+		}
+	}
+	if err := s.publisher.Publish(pattern, data); err != nil {
+		return "", fmt.Errorf("failed to publish %s event: %w", pattern, err)
+	}
+	return pattern, nil
+}
+
+func replayableEvent(order *repository.Order) (string, interface{}) {
+	switch order.Status {
+	case repository.StatusRejected:
+		data := map[string]interface{}{
+			"orderId":   order.ID,
+			"productId": order.ProductID,
+		}
+		if order.HoldReason != "" {
+			data["reason"] = order.HoldReason
+		}
+		return "order.rejected", data
+	case repository.StatusPaid:
+		return "order.paid", map[string]interface{}{
+			"orderId":   order.ID,
+			"productId": order.ProductID,
+		}
+	case repository.StatusPaymentExpired:
+		return "order.payment_expired", map[string]interface{}{
+			"orderId":   order.ID,
+			"productId": order.ProductID,
+		}
+	case repository.StatusReservationExpired:
+		return "order.reservation_released", reservationReleasedData(order)
+	case repository.StatusCancelled:
+		return "order.cancelled", map[string]interface{}{
+			"orderId":   order.ID,
+			"productId": order.ProductID,
+		}
+	}
+	return announcement(order)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"order-service/internal/domain"
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"time"
+)
+
+// expireReservationsBatch caps how many orders one ExpireReservations run
+// handles.
+const expireReservationsBatch = 100
+
+// WithReservations lets customers reserve stock for ttl without paying,
+// e.g. during a flash sale, by placing an order with reserve set.
+func WithReservations(ttl time.Duration) Option {
+	return func(s *OrderService) { s.reservationTTL = ttl }
+}
+
+// checkReservation refuses reservations that cannot be held: when they are
+// disabled, and for orders that are charged or split up front.
+func (s *OrderService) checkReservation(req CreateOrderRequest) error {
+	if !req.Reserve {
+		return nil
+	}
+	if s.reservationTTL <= 0 {
+		return &Error{Code: CodeReservationNotAvailable, Message: "reservations are not enabled"}
+	}
+	if req.Installments > 1 || req.GiftCardCode != "" || req.UseStoreCredit || req.ProcessAt != nil {
+		return &Error{Code: CodeReservationNotAvailable, Message: "installments, gift cards, store credit and schedules cannot be used for reservations"}
+	}
+	return nil
+}
+
+// reserve holds a new PENDING order's stock. Orders screening put on hold
+// are not reserved.
+func (s *OrderService) reserve(order *repository.Order) error {
+	if order.Status != repository.StatusPending {
+		return nil
+	}
+	until := time.Now().Add(s.reservationTTL)
+	return mutate(order, func(o *domain.Order) error { return o.Reserve(until) })
+}
+
+// confirmReservation releases a reserved order: it becomes PENDING, or
+// AWAITING_PAYMENT when payment intents are enabled, and is announced.
+func (s *OrderService) confirmReservation(ctx context.Context, order *repository.Order) (*repository.Order, error) {
+	err := mutate(order, func(o *domain.Order) error { return o.ConfirmReservation(time.Now()) })
+	if errors.Is(err, domain.ErrReservationExpired) {
+		return nil, &Error{Code: CodeReservationExpired, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	if err := s.requestPayment(ctx, order); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.CompleteReservation(order)
+	if errors.Is(err, repository.ErrStatusConflict) {
+		s.cancelPayments(*order)
+		return nil, &Error{Code: CodeReservationExpired, Message: domain.ErrReservationExpired.Error()}
+	} else if err != nil {
+		s.cancelPayments(*order)
+		return nil, err
+	}
+
+	s.releaseStock(ctx, order.ID)
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.announce(ctx, order)
+	s.emit(events.OrderUpdated, order)
+	return order, nil
+}
+
+// ExpireReservations marks reservations that were not confirmed in time
+// RESERVATION_EXPIRED and releases their stock. It is run by the
+// scheduler.
+func (s *OrderService) ExpireReservations(ctx context.Context) (int, error) {
+	orders, err := s.repo.GetExpiredReservations(time.Now(), expireReservationsBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range orders {
+		order := &orders[i]
+		if err := mutate(order, (*domain.Order).ExpireReservation); err != nil {
+			continue
+		}
+		err := s.repo.UpdateStatus(order.ID, repository.StatusReserved, order.Status)
+		if errors.Is(err, repository.ErrStatusConflict) {
+			continue
+		} else if err != nil {
+			return expired, err
+		}
+		expired++
+
+		s.releaseLimits(*order)
+		s.releaseStock(ctx, order.ID)
+		if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+			log.Printf("Redis error on delete: %v", err)
+		}
+		s.publish(ctx, "order.reservation_released", reservationReleasedData(order))
+		s.emit(events.OrderUpdated, order)
+	}
+
+	if expired > 0 {
+		log.Printf("Released %d expired reservations", expired)
+	}
+	return expired, nil
+}
+
+func reservationReleasedData(order *repository.Order) map[string]interface{} {
+	return withTags(map[string]interface{}{
+		"orderId":   order.ID,
+		"productId": order.ProductID,
+		"quantity":  order.Quantity,
+	}, order)
+}
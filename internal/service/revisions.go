@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/internal/repository"
+	"reflect"
+	"time"
+)
+
+// FieldChange is one order field that differs between two revisions. From
+// is nil in the diff of the first revision.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type RevisionDiff struct {
+	OrderID   string        `json:"orderId"`
+	Number    int           `json:"number"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// GetRevisions returns the order's revision history, oldest first.
+func (s *OrderService) GetRevisions(ctx context.Context, id string) ([]repository.OrderRevision, error) {
+	revisions, err := s.repo.GetRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		if _, err := s.repo.GetByID(id); errors.Is(err, repository.ErrOrderNotFound) {
+			return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return revisions, nil
+}
+
+// GetRevisionDiff compares revision n of the order with the one before it.
+func (s *OrderService) GetRevisionDiff(ctx context.Context, id string, n int) (*RevisionDiff, error) {
+	revisions, err := s.GetRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i, rev := range revisions {
+		if rev.Number != n {
+			continue
+		}
+		diff := &RevisionDiff{OrderID: id, Number: n, CreatedAt: rev.CreatedAt}
+		if i == 0 {
+			diff.Changes = diffOrders(nil, &rev.Snapshot)
+		} else {
+			diff.Changes = diffOrders(&revisions[i-1].Snapshot, &rev.Snapshot)
+		}
+		return diff, nil
+	}
+	return nil, &Error{
+		Code:    CodeRevisionNotFound,
+		Message: fmt.Sprintf("order %s has no revision %d", id, n),
+	}
+}
+
+// diffOrders lists the stored fields that differ between from and to. With
+// a nil from it lists every field of to that is set.
+func diffOrders(from, to *repository.Order) []FieldChange {
+	changes := []FieldChange{}
+	tv := reflect.ValueOf(*to)
+	var fv reflect.Value
+	if from != nil {
+		fv = reflect.ValueOf(*from)
+	}
+	for i := 0; i < tv.NumField(); i++ {
+		field := tv.Type().Field(i)
+		if field.Tag.Get("gorm") == "-" || field.Type.Kind() == reflect.Slice {
+			continue
+		}
+		newValue := tv.Field(i).Interface()
+		if from == nil {
+			if !tv.Field(i).IsZero() {
+				changes = append(changes, FieldChange{Field: field.Name, To: newValue})
+			}
+			continue
+		}
+		oldValue := fv.Field(i).Interface()
+		if !sameValue(oldValue, newValue) {
+			changes = append(changes, FieldChange{Field: field.Name, From: oldValue, To: newValue})
+		}
+	}
+	return changes
+}
+
+func sameValue(a, b interface{}) bool {
+	switch at := a.(type) {
+	case time.Time:
+		return at.Equal(b.(time.Time))
+	case *time.Time:
+		bt := b.(*time.Time)
+		return at == nil && bt == nil || at != nil && bt != nil && at.Equal(*bt)
+	}
+	return a == b
+}
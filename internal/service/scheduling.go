@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"order-service/internal/domain"
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"time"
+)
+
+// activateScheduledBatch caps how many orders one ActivateScheduledOrders
+// run handles.
+const activateScheduledBatch = 100
+
+// ScheduleRequest moves a scheduled order. As on creation, a missing
+// ProcessAt is derived from DeliverAt.
+type ScheduleRequest struct {
+	ProcessAt *time.Time `json:"processAt,omitempty"`
+	DeliverAt *time.Time `json:"deliverAt,omitempty"`
+}
+
+// WithScheduling sets how long before the requested delivery date a
+// scheduled order is processed, and how far ahead orders may be scheduled.
+// A zero maxAhead does not limit it.
+func WithScheduling(leadTime, maxAhead time.Duration) Option {
+	return func(s *OrderService) {
+		s.scheduleLeadTime = leadTime
+		s.scheduleMaxAhead = maxAhead
+	}
+}
+
+func invalidSchedule(msg string) error {
+	return &Error{Code: CodeInvalidSchedule, Message: msg}
+}
+
+// processTime returns when an order asked for with processAt and deliverAt
+// should be processed, or nil to process it now: when neither is given, or
+// the delivery date is closer than the lead time.
+func (s *OrderService) processTime(processAt, deliverAt *time.Time) (*time.Time, error) {
+	now := time.Now()
+	if deliverAt != nil && !deliverAt.After(now) {
+		return nil, invalidSchedule("deliverAt must be in the future")
+	}
+	if processAt == nil {
+		if deliverAt == nil {
+			return nil, nil
+		}
+		t := deliverAt.Add(-s.scheduleLeadTime)
+		if !t.After(now) {
+			return nil, nil
+		}
+		processAt = &t
+	}
+	if !processAt.After(now) {
+		return nil, invalidSchedule(domain.ErrScheduleInPast.Error())
+	}
+	if deliverAt != nil && processAt.After(*deliverAt) {
+		return nil, invalidSchedule("processAt must not be after deliverAt")
+	}
+	if s.scheduleMaxAhead > 0 && processAt.After(now.Add(s.scheduleMaxAhead)) {
+		return nil, invalidSchedule("orders cannot be scheduled that far ahead")
+	}
+	return processAt, nil
+}
+
+// buildScheduledOrder checks and prices the order now, so the customer
+// sees what it is expected to cost, and stores it as SCHEDULED. Orders
+// that are charged or split up front when placed cannot be scheduled.
+func (s *OrderService) buildScheduledOrder(ctx context.Context, req CreateOrderRequest, processAt time.Time) (*repository.Order, error) {
+	if req.Installments > 1 || req.GiftCardCode != "" || req.UseStoreCredit || req.Reserve {
+		return nil, invalidSchedule("installments, gift cards, store credit and reservations cannot be used for scheduled orders")
+	}
+	quote, _, err := s.quote(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &repository.Order{
+		ID:         newOrderID(req),
+		ProductID:  req.ProductID,
+		CustomerID: req.CustomerID,
+		TenantID:   req.TenantID,
+		Quantity:   req.Quantity,
+		Region:     req.Region,
+		Country:    req.Country,
+		Status:     repository.StatusScheduled,
+		ProcessAt:  &processAt,
+		DeliverAt:  req.DeliverAt,
+		CreatedAt:  time.Now().UTC(),
+	}
+	applyQuote(order, quote)
+	if err := s.reserveLimits(ctx, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ActivateScheduledOrders releases the scheduled orders that are due. Each
+// is priced and checked again against current stock and prices: valid
+// orders become PENDING (ON_HOLD after screening, AWAITING_PAYMENT when
+// payment intents are enabled) and are announced; orders that fail a
+// business rule are REJECTED. It stops at the first lookup that fails for
+// another reason and is run by the scheduler.
+func (s *OrderService) ActivateScheduledOrders(ctx context.Context) (int, error) {
+	orders, err := s.repo.GetDueScheduled(time.Now(), activateScheduledBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = withProductMemo(ctx)
+	activated := 0
+	for i := range orders {
+		order := &orders[i]
+		quote, decision, err := s.quote(ctx, repriceRequest(order))
+		if err != nil && !rejectable(err) {
+			return activated, err
+		}
+
+		if err == nil {
+			applyQuote(order, quote)
+			err = mutate(order, func(o *domain.Order) error { return o.Activate(o.Pricing) })
+			if err == nil && !decision.CustomerAllowed {
+				s.screen(ctx, order)
+			}
+			if err == nil {
+				err = s.requestPayment(ctx, order)
+			}
+		}
+		if err != nil {
+			// A declined payment leaves the order PENDING; it is rejected
+			// from where it was stored.
+			reason := err.Error()
+			order.Status = repository.StatusScheduled
+			if err := mutate(order, func(o *domain.Order) error { return o.Reject(reason) }); err != nil {
+				log.Printf("Cannot activate scheduled order %s: %v", order.ID, err)
+				continue
+			}
+		}
+
+		err = s.repo.ActivateScheduled(order)
+		if errors.Is(err, repository.ErrStatusConflict) {
+			s.cancelPayments(*order)
+			continue
+		} else if err != nil {
+			s.cancelPayments(*order)
+			return activated, err
+		}
+		activated++
+
+		if order.Status == repository.StatusRejected {
+			s.releaseLimits(*order)
+			s.publish(ctx, "order.rejected", orderRejectedData(order))
+			s.emit(events.OrderUpdated, order)
+		} else {
+			s.announce(ctx, order)
+			s.emit(events.OrderActivated, order)
+		}
+		if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+			log.Printf("Redis error on delete: %v", err)
+		}
+	}
+
+	if activated > 0 {
+		log.Printf("Activated %d scheduled orders", activated)
+	}
+	return activated, nil
+}
+
+// RescheduleOrder moves a scheduled order to another processing time.
+func (s *OrderService) RescheduleOrder(ctx context.Context, id string, req ScheduleRequest) (*repository.Order, error) {
+	order, err := s.scheduledOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	if req.ProcessAt == nil && req.DeliverAt == nil {
+		return nil, invalidSchedule("processAt or deliverAt is required")
+	}
+	processAt, err := s.processTime(req.ProcessAt, req.DeliverAt)
+	if err != nil {
+		return nil, err
+	}
+	if processAt == nil {
+		return nil, invalidSchedule("deliverAt is too soon to schedule the order")
+	}
+	if err := mutate(order, func(o *domain.Order) error { return o.Reschedule(*processAt, time.Now()) }); err != nil {
+		return nil, invalidSchedule(err.Error())
+	}
+	order.DeliverAt = req.DeliverAt
+
+	err = s.repo.Reschedule(id, *processAt, req.DeliverAt)
+	if errors.Is(err, repository.ErrStatusConflict) {
+		return nil, &Error{Code: CodeOrderNotScheduled, Message: domain.ErrNotScheduled.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "order.rescheduled", rescheduledData(order))
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.emit(events.OrderUpdated, order)
+	return order, nil
+}
+
+// CancelOrder cancels a scheduled order. Orders that have been activated
+// cannot be cancelled this way.
+func (s *OrderService) CancelOrder(ctx context.Context, id string) (*repository.Order, error) {
+	order, err := s.scheduledOrder(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := mutate(order, (*domain.Order).Cancel); err != nil {
+		return nil, &Error{Code: CodeOrderNotScheduled, Message: err.Error()}
+	}
+
+	err = s.repo.UpdateStatus(id, repository.StatusScheduled, order.Status)
+	if errors.Is(err, repository.ErrStatusConflict) {
+		return nil, &Error{Code: CodeOrderNotScheduled, Message: domain.ErrNotScheduled.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	s.releaseLimits(*order)
+	s.publish(ctx, "order.cancelled", orderRefData(order))
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.emit(events.OrderUpdated, order)
+	return order, nil
+}
+
+func rescheduledData(order *repository.Order) map[string]interface{} {
+	return withTags(map[string]interface{}{
+		"orderId":   order.ID,
+		"productId": order.ProductID,
+		"processAt": order.ProcessAt,
+		"deliverAt": order.DeliverAt,
+	}, order)
+}
+
+func (s *OrderService) scheduledOrder(id string) (*repository.Order, error) {
+	order, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	if order.Status != repository.StatusScheduled {
+		return nil, &Error{Code: CodeOrderNotScheduled, Message: domain.ErrNotScheduled.Error()}
+	}
+	return order, nil
+}
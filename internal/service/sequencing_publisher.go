@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"log"
+	"order-service/internal/eventorder"
+	"order-service/internal/repository"
+)
+
+// ISequencer numbers the events of each order.
+type ISequencer interface {
+	Next(ctx context.Context, key string) (int64, error)
+}
+
+// SequencingPublisher numbers every event that has an orderId in its
+// payload within that order, and publishes it through next as
+// eventorder.Sequenced data. The number is taken when the event is
+// published, right after the change it announces, so it survives the
+// buffer, batches and the outbox and lets consumers put events of one
+// order back in order. Events it cannot number are published without.
+type SequencingPublisher struct {
+	next      IPublisher
+	sequencer ISequencer
+}
+
+var _ IPublisher = &SequencingPublisher{}
+
+func NewSequencingPublisher(next IPublisher, sequencer ISequencer) *SequencingPublisher {
+	return &SequencingPublisher{next: next, sequencer: sequencer}
+}
+
+func (p *SequencingPublisher) PublishOrderCreated(order *repository.Order) error {
+	return p.Publish("order.created", orderCreatedData(order))
+}
+
+func (p *SequencingPublisher) Publish(pattern string, data interface{}) error {
+	key := orderKey(data)
+	if key == "" {
+		return p.next.Publish(pattern, data)
+	}
+	n, err := p.sequencer.Next(context.Background(), key)
+	if err != nil {
+		log.Printf("Failed to number %s event of order %s, publishing it without: %v", pattern, key, err)
+		return p.next.Publish(pattern, data)
+	}
+	return p.next.Publish(pattern, eventorder.Sequenced{Key: key, Sequence: n, Data: data})
+}
+
+// orderKey returns the order an event payload is about.
+func orderKey(data interface{}) string {
+	if m, ok := data.(map[string]interface{}); ok {
+		id, _ := m["orderId"].(string)
+		return id
+	}
+	return ""
+}
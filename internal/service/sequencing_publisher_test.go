@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"order-service/internal/eventorder"
+	"order-service/internal/repository"
+	"testing"
+)
+
+// memorySequencer numbers events in memory.
+type memorySequencer struct {
+	next map[string]int64
+	err  error
+}
+
+func (m *memorySequencer) Next(_ context.Context, key string) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.next[key]++
+	return m.next[key], nil
+}
+
+type recordingPublisher struct {
+	discardPublisher
+	data []interface{}
+}
+
+func (r *recordingPublisher) Publish(pattern string, data interface{}) error {
+	r.data = append(r.data, data)
+	return nil
+}
+
+func TestSequencingPublisher(t *testing.T) {
+	next := &recordingPublisher{}
+	sequencer := &memorySequencer{next: map[string]int64{}}
+	publisher := NewSequencingPublisher(next, sequencer)
+
+	order := &repository.Order{ID: "o1", ProductID: "p1", Quantity: 1}
+	publisher.PublishOrderCreated(order)
+	publisher.Publish("order.paid", orderRefData(order))
+	publisher.Publish("order.created", orderCreatedData(&repository.Order{ID: "o2", ProductID: "p1"}))
+	publisher.Publish("cart.checked_out", map[string]interface{}{"cartId": "c1"})
+	sequencer.err = errors.New("redis down")
+	publisher.Publish("order.cancelled", orderRefData(order))
+
+	want := []struct {
+		key      string
+		sequence int64
+	}{{"o1", 1}, {"o1", 2}, {"o2", 1}, {"", 0}, {"", 0}}
+	if len(next.data) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(next.data))
+	}
+	for i, w := range want {
+		key, sequence, _ := eventorder.Unwrap(next.data[i])
+		if key != w.key || sequence != w.sequence {
+			t.Errorf("Event %d: expected %q #%d, got %q #%d", i, w.key, w.sequence, key, sequence)
+		}
+	}
+
+	// The number is not part of the event's identity.
+	plain, _ := EventID("order.paid", orderRefData(order))
+	if id, _ := EventID("order.paid", next.data[1]); id != plain {
+		t.Errorf("Expected the numbered event to keep ID %s, got %s", plain, id)
+	}
+}
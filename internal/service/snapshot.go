@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"order-service/internal/repository"
+	"order-service/internal/rounding"
+	"time"
+)
+
+// OrderSnapshot is an order with everything this service knows about it,
+// for support tools that would otherwise fetch the order, its payments and
+// its history one by one. Shipments, refunds and notes are not handled by
+// this service and are not part of it.
+type OrderSnapshot struct {
+	Order    *OrderDetail    `json:"order"`
+	Items    []OrderItem     `json:"items"`
+	Payment  PaymentSummary  `json:"payment"`
+	Timeline []TimelineEntry `json:"timeline"`
+}
+
+// OrderItem is a line of an order. Orders currently hold one product.
+type OrderItem struct {
+	ProductID string  `json:"productId"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unitPrice"`
+	Subtotal  float64 `json:"subtotal"`
+}
+
+// PaymentSummary is how an order is paid: its payment intent, the tenders
+// the total is split across and its installments.
+type PaymentSummary struct {
+	IntentID        string                   `json:"intentId,omitempty"`
+	ExpiresAt       *time.Time               `json:"expiresAt,omitempty"`
+	TotalPrice      float64                  `json:"totalPrice"`
+	PaidAmount      float64                  `json:"paidAmount"`
+	RemainingAmount float64                  `json:"remainingAmount"`
+	Tenders         []repository.Tender      `json:"tenders"`
+	Installments    []repository.Installment `json:"installments"`
+}
+
+// TimelineEntry is one revision of an order: when it happened, the status
+// it left the order in and the fields it changed.
+type TimelineEntry struct {
+	Number  int           `json:"number"`
+	At      time.Time     `json:"at"`
+	Status  string        `json:"status"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// GetOrderSnapshot returns the order with its items, payment and timeline.
+func (s *OrderService) GetOrderSnapshot(ctx context.Context, id string) (*OrderSnapshot, error) {
+	detail, err := s.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.repo.GetRevisions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &detail.Order
+	snapshot := &OrderSnapshot{
+		Order:    detail,
+		Items:    []OrderItem{orderItem(order, s.roundingFor(order))},
+		Payment:  paymentSummary(order, s.roundingFor(order)),
+		Timeline: make([]TimelineEntry, len(revisions)),
+	}
+	for i, rev := range revisions {
+		var prev *repository.Order
+		if i > 0 {
+			prev = &revisions[i-1].Snapshot
+		}
+		snapshot.Timeline[i] = TimelineEntry{
+			Number:  rev.Number,
+			At:      rev.CreatedAt,
+			Status:  rev.Snapshot.Status,
+			Changes: diffOrders(prev, &rev.Snapshot),
+		}
+	}
+	// Payments are listed once, in the payment summary.
+	order.Tenders, order.Installments = nil, nil
+	return snapshot, nil
+}
+
+func orderItem(order *repository.Order, round rounding.Strategy) OrderItem {
+	item := OrderItem{ProductID: order.ProductID, Quantity: order.Quantity, Subtotal: order.Subtotal}
+	if order.Quantity > 0 {
+		item.UnitPrice = round.Round(order.Subtotal / float64(order.Quantity))
+	}
+	return item
+}
+
+func paymentSummary(order *repository.Order, round rounding.Strategy) PaymentSummary {
+	p := PaymentSummary{
+		IntentID:        order.PaymentIntentID,
+		TotalPrice:      order.TotalPrice,
+		PaidAmount:      order.PaidAmount,
+		RemainingAmount: round.Round(order.TotalPrice - order.PaidAmount),
+		Tenders:         order.Tenders,
+		Installments:    order.Installments,
+	}
+	if order.Status == repository.StatusPaid {
+		p.PaidAmount, p.RemainingAmount = order.TotalPrice, 0
+	}
+	if order.PaymentIntentID != "" && !order.PaymentExpiresAt.IsZero() {
+		expires := order.PaymentExpiresAt
+		p.ExpiresAt = &expires
+	}
+	if p.Tenders == nil {
+		p.Tenders = []repository.Tender{}
+	}
+	if p.Installments == nil {
+		p.Installments = []repository.Installment{}
+	}
+	return p
+}
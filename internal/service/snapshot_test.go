@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"order-service/internal/repository"
+	"testing"
+	"time"
+)
+
+type snapshotRepository struct {
+	paymentRepository
+	revisions []repository.OrderRevision
+}
+
+func (m *snapshotRepository) GetRevisions(orderID string) ([]repository.OrderRevision, error) {
+	return m.revisions, nil
+}
+
+func TestGetOrderSnapshot(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	order := repository.Order{
+		ID: "o1", ProductID: "p1", Quantity: 3, Subtotal: 30, TotalPrice: 33, Status: repository.StatusAwaitingPayment,
+		PaymentIntentID: "pi_1", PaymentExpiresAt: created.Add(time.Hour), CreatedAt: created,
+		Tenders: []repository.Tender{{OrderID: "o1", Type: "gift_card", Amount: 10}},
+	}
+	paid := order
+	paid.Status = repository.StatusPaid
+	repo := &snapshotRepository{
+		paymentRepository: paymentRepository{orders: map[string]*repository.Order{"o1": &paid}},
+		revisions: []repository.OrderRevision{
+			{OrderID: "o1", Number: 1, Snapshot: order, CreatedAt: created},
+			{OrderID: "o1", Number: 2, Snapshot: paid, CreatedAt: created.Add(time.Minute)},
+		},
+	}
+	service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, "")
+
+	snapshot, err := service.GetOrderSnapshot(context.Background(), "o1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if snapshot.Order.ID != "o1" || snapshot.Order.Tenders != nil {
+		t.Errorf("Expected the order without its tenders, got %+v", snapshot.Order.Order)
+	}
+	if len(snapshot.Items) != 1 || snapshot.Items[0].UnitPrice != 10 || snapshot.Items[0].Quantity != 3 {
+		t.Errorf("Unexpected items %+v", snapshot.Items)
+	}
+	p := snapshot.Payment
+	if p.IntentID != "pi_1" || p.PaidAmount != 33 || p.RemainingAmount != 0 || len(p.Tenders) != 1 || p.Installments == nil {
+		t.Errorf("Unexpected payment %+v", p)
+	}
+	if len(snapshot.Timeline) != 2 {
+		t.Fatalf("Expected 2 timeline entries, got %d", len(snapshot.Timeline))
+	}
+	if second := snapshot.Timeline[1]; second.Status != repository.StatusPaid || len(second.Changes) != 1 || second.Changes[0].Field != "Status" {
+		t.Errorf("Expected the second entry to change the status, got %+v", second)
+	}
+
+	var svcErr *Error
+	if _, err := service.GetOrderSnapshot(context.Background(), "missing"); !errors.As(err, &svcErr) || svcErr.Code != CodeOrderNotFound {
+		t.Errorf("Expected %s, got %v", CodeOrderNotFound, err)
+	}
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"order-service/internal/backoff"
+	"order-service/internal/repository"
+	"order-service/internal/subscription"
+	"time"
+)
+
+// subscriptionBatch caps how many subscriptions one
+// GenerateSubscriptionOrders run handles.
+const subscriptionBatch = 100
+
+// ISubscriptionStore hands out due subscriptions and stores the outcome of
+// placing their orders.
+type ISubscriptionStore interface {
+	Due(ctx context.Context, now time.Time, limit int) ([]subscription.Subscription, error)
+	Claim(ctx context.Context, sub *subscription.Subscription, now time.Time) (bool, error)
+	Record(ctx context.Context, sub *subscription.Subscription) error
+	Suspend(ctx context.Context, id string) error
+}
+
+// WithSubscriptions places the orders of store's subscriptions. A cycle
+// whose payment is declined is retried after retry's backoff; after
+// maxAttempts declines the subscription is suspended.
+func WithSubscriptions(store ISubscriptionStore, retry backoff.Policy, maxAttempts int) Option {
+	return func(s *OrderService) {
+		s.subscriptions = store
+		s.subscriptionRetry = retry
+		s.subscriptionAttempts = maxAttempts
+	}
+}
+
+// GenerateSubscriptionOrders places an order for every subscription whose
+// cycle or payment retry is due and publishes
+// subscription.order_generated. The customer is not there to confirm the
+// payment, so it is captured off-session right away and only a captured
+// order counts as generated. Declined payments are retried, other
+// business rule failures skip the cycle (subscription.cycle_skipped). It
+// stops at the first order that fails for another reason, which is tried
+// again after the first retry delay, and is run by the scheduler.
+func (s *OrderService) GenerateSubscriptionOrders(ctx context.Context) (int, error) {
+	if s.subscriptions == nil {
+		return 0, nil
+	}
+	now := time.Now()
+	subs, err := s.subscriptions.Due(ctx, now, subscriptionBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = withProductMemo(ctx)
+	generated := 0
+	for i := range subs {
+		sub := &subs[i]
+		cycle := sub.NextRunAt
+		claimed, err := s.subscriptions.Claim(ctx, sub, now)
+		if err != nil {
+			return generated, err
+		}
+		if !claimed {
+			continue
+		}
+
+		order, err := s.CreateOrder(ctx, CreateOrderRequest{
+			ProductID:    sub.ProductID,
+			Quantity:     sub.Quantity,
+			CustomerID:   sub.CustomerID,
+			DiscountCode: sub.DiscountCode,
+			Currency:     sub.Currency,
+			Region:       sub.Region,
+			Country:      sub.Country,
+			TenantID:     sub.TenantID,
+		})
+		if err == nil && order.Status == repository.StatusAwaitingPayment {
+			order, err = s.captureSubscriptionOrder(ctx, order)
+		}
+		var svcErr *Error
+		switch {
+		case err == nil:
+			generated++
+			sub.LastOrderID, sub.LastError, sub.FailedAttempts = order.ID, "", 0
+			s.publish(ctx, "subscription.order_generated", orderGeneratedData(sub, order, cycle))
+		case errors.As(err, &svcErr) && svcErr.Code == CodePaymentDeclined:
+			sub.LastError = err.Error()
+			sub.FailedAttempts++
+			if sub.FailedAttempts >= s.subscriptionAttempts {
+				if err := s.subscriptions.Suspend(ctx, sub.ID); err != nil {
+					return generated, err
+				}
+				s.publish(ctx, "subscription.suspended", subscriptionSuspendedData(sub))
+				break
+			}
+			retryAt := now.Add(s.subscriptionRetry.Delay(sub.FailedAttempts - 1))
+			sub.RetryAt = &retryAt
+			s.publish(ctx, "subscription.payment_failed", paymentFailedData(sub))
+		case rejectable(err):
+			sub.LastError = err.Error()
+			s.publish(ctx, "subscription.cycle_skipped", cycleSkippedData(sub, cycle))
+		default:
+			sub.LastError = err.Error()
+			retryAt := now.Add(s.subscriptionRetry.Delay(0))
+			sub.RetryAt = &retryAt
+			if err := s.subscriptions.Record(ctx, sub); err != nil {
+				log.Printf("Failed to record run of subscription %s: %v", sub.ID, err)
+			}
+			return generated, err
+		}
+
+		if err := s.subscriptions.Record(ctx, sub); err != nil {
+			return generated, err
+		}
+	}
+
+	if generated > 0 {
+		log.Printf("Generated %d subscription orders", generated)
+	}
+	return generated, nil
+}
+
+// captureSubscriptionOrder confirms a generated order awaiting payment.
+// An order whose capture fails is expired at once rather than left to
+// the payment expiry job, so the retry places a fresh one.
+func (s *OrderService) captureSubscriptionOrder(ctx context.Context, order *repository.Order) (*repository.Order, error) {
+	confirmed, err := s.ConfirmOrder(ctx, order.ID)
+	if err == nil {
+		return confirmed, nil
+	}
+	if _, expireErr := s.expirePayment(context.WithoutCancel(ctx), order); expireErr != nil {
+		log.Printf("Failed to expire unpaid subscription order %s: %v", order.ID, expireErr)
+	}
+	return nil, err
+}
+
+func orderGeneratedData(sub *subscription.Subscription, order *repository.Order, cycle time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"subscriptionId": sub.ID,
+		"orderId":        order.ID,
+		"customerId":     sub.CustomerID,
+		"productId":      sub.ProductID,
+		"quantity":       sub.Quantity,
+		"cycle":          cycle,
+	}
+}
+
+func subscriptionSuspendedData(sub *subscription.Subscription) map[string]interface{} {
+	return map[string]interface{}{
+		"subscriptionId": sub.ID,
+		"customerId":     sub.CustomerID,
+		"reason":         sub.LastError,
+	}
+}
+
+// paymentFailedData is subscription.payment_failed for a subscription
+// whose RetryAt is set.
+func paymentFailedData(sub *subscription.Subscription) map[string]interface{} {
+	return map[string]interface{}{
+		"subscriptionId": sub.ID,
+		"customerId":     sub.CustomerID,
+		"attempt":        sub.FailedAttempts,
+		"retryAt":        *sub.RetryAt,
+	}
+}
+
+func cycleSkippedData(sub *subscription.Subscription, cycle time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"subscriptionId": sub.ID,
+		"customerId":     sub.CustomerID,
+		"cycle":          cycle,
+		"reason":         sub.LastError,
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxTagLength caps the length of a single tag.
+	maxTagLength = 64
+	// maxOrderTags caps how many tags one order can have.
+	maxOrderTags = 20
+)
+
+// TagsRequest adds tags to an order.
+type TagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// ParseTags normalizes tags to lower case without surrounding spaces and
+// drops duplicates. A tag is up to 64 printable characters, without
+// spaces or commas.
+func ParseTags(values []string) ([]string, error) {
+	tags := make([]string, 0, len(values))
+	for _, v := range values {
+		tag := strings.ToLower(strings.TrimSpace(v))
+		if tag == "" || len(tag) > maxTagLength || strings.IndexFunc(tag, invalidTagRune) >= 0 {
+			return nil, &Error{Code: CodeInvalidTag, Message: fmt.Sprintf("invalid tag %q: tags are 1 to %d printable characters without spaces or commas", v, maxTagLength)}
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func invalidTagRune(r rune) bool {
+	return r == ',' || unicode.IsSpace(r) || !unicode.IsPrint(r)
+}
+
+// AddOrderTags adds tags to an order, keeping the ones it already has.
+func (s *OrderService) AddOrderTags(ctx context.Context, id string, values []string) (*repository.Order, error) {
+	tags, err := ParseTags(values)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, &Error{Code: CodeInvalidTag, Message: "at least one tag is required"}
+	}
+	order, err := s.repo.GetByID(id)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	added := slices.DeleteFunc(tags, order.HasTag)
+	if len(order.Tags)+len(added) > maxOrderTags {
+		return nil, &Error{Code: CodeInvalidTag, Message: fmt.Sprintf("an order can have at most %d tags", maxOrderTags)}
+	}
+	if len(added) == 0 {
+		return order, nil
+	}
+	return s.retag(id, func() error { return s.repo.AddTags(id, added) })
+}
+
+// RemoveOrderTag removes a tag from an order. Removing a tag the order
+// does not have changes nothing.
+func (s *OrderService) RemoveOrderTag(ctx context.Context, id, tag string) (*repository.Order, error) {
+	tags, err := ParseTags([]string{tag})
+	if err != nil {
+		return nil, err
+	}
+	return s.retag(id, func() error { return s.repo.RemoveTag(id, tags[0]) })
+}
+
+// retag applies a tag change and returns the order as it now is. Tags are
+// in the cached order lists and in the order.updated event, so both are
+// refreshed.
+func (s *OrderService) retag(id string, change func() error) (*repository.Order, error) {
+	err := change()
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, &Error{Code: CodeOrderNotFound, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	order, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+		log.Printf("Redis error on delete: %v", err)
+	}
+	s.emit(events.OrderUpdated, order)
+	return order, nil
+}
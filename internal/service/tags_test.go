@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"order-service/internal/audit"
+	"order-service/internal/repository"
+)
+
+// tagRepository stores tags on the stored orders.
+type tagRepository struct {
+	idRepository
+}
+
+func (m *tagRepository) AddTags(orderID string, tags []string) error {
+	order, ok := m.orders[orderID]
+	if !ok {
+		return repository.ErrOrderNotFound
+	}
+	for _, tag := range tags {
+		if !order.HasTag(tag) {
+			order.Tags = append(order.Tags, repository.OrderTag{OrderID: orderID, Tag: tag})
+		}
+	}
+	m.orders[orderID] = order
+	return nil
+}
+
+func (m *tagRepository) RemoveTag(orderID, tag string) error {
+	order, ok := m.orders[orderID]
+	if !ok {
+		return repository.ErrOrderNotFound
+	}
+	var kept []repository.OrderTag
+	for _, t := range order.Tags {
+		if t.Tag != tag {
+			kept = append(kept, t)
+		}
+	}
+	order.Tags = kept
+	m.orders[orderID] = order
+	return nil
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags([]string{" Flash-Sale ", "incident:42", "flash-sale"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := []string{"flash-sale", "incident:42"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Expected %v, got %v", want, tags)
+	}
+
+	for _, tag := range []string{"", "  ", "two words", "a,b", "tab\there", strings.Repeat("x", maxTagLength+1)} {
+		var svcErr *Error
+		if _, err := ParseTags([]string{tag}); !errors.As(err, &svcErr) || svcErr.Code != CodeInvalidTag {
+			t.Errorf("Expected %s for %q, got %v", CodeInvalidTag, tag, err)
+		}
+	}
+}
+
+func TestOrderTags(t *testing.T) {
+	repo := &tagRepository{idRepository{orders: map[string]repository.Order{
+		"order-1": {ID: "order-1", ProductID: "p1", Status: repository.StatusPaid},
+	}}}
+	service := NewOrderService(repo, &mockOrderCache{}, &mockPublisher{}, "")
+	ctx := audit.WithActor(context.Background(), "alice")
+
+	order, err := service.AddOrderTags(ctx, "order-1", []string{"VIP", "flash-sale"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got, want := order.TagNames(), []string{"flash-sale", "vip"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected tags %v, got %v", want, got)
+	}
+
+	order, err = service.RemoveOrderTag(ctx, "order-1", "VIP")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got, want := order.TagNames(), []string{"flash-sale"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected tags %v, got %v", want, got)
+	}
+
+	many := make([]string, maxOrderTags)
+	for i := range many {
+		many[i] = "tag-" + strings.Repeat("x", i+1)
+	}
+	errorCases := []struct {
+		name string
+		id   string
+		tags []string
+		code string
+	}{
+		{"no tags", "order-1", nil, CodeInvalidTag},
+		{"invalid tag", "order-1", []string{"two words"}, CodeInvalidTag},
+		{"too many tags", "order-1", many, CodeInvalidTag},
+		{"missing order", "order-2", []string{"vip"}, CodeOrderNotFound},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.AddOrderTags(ctx, tc.id, tc.tags)
+			var svcErr *Error
+			if !errors.As(err, &svcErr) || svcErr.Code != tc.code {
+				t.Errorf("Expected %s, got %v", tc.code, err)
+			}
+		})
+	}
+	if _, err := service.RemoveOrderTag(ctx, "order-2", "vip"); err == nil {
+		t.Error("Expected an error removing a tag from a missing order")
+	}
+}
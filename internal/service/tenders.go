@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"order-service/internal/balance"
+	"order-service/internal/repository"
+)
+
+// checkTenders validates the gift card and store credit options of a
+// request without redeeming anything.
+func (s *OrderService) checkTenders(req CreateOrderRequest) error {
+	if req.GiftCardCode == "" && !req.UseStoreCredit {
+		return nil
+	}
+	if s.balances == nil {
+		return &Error{Code: CodeTenderNotAvailable, Message: "gift cards and store credit are not available"}
+	}
+	if req.UseStoreCredit && req.CustomerID == "" {
+		return &Error{Code: CodeTenderNotAvailable, Message: "store credit requires a customerId"}
+	}
+	return nil
+}
+
+// tenderSource is a balance to redeem from. display is what gets stored as
+// the tender reference.
+type tenderSource struct {
+	kind, tender, reference, display string
+}
+
+// redeemTenders takes as much of the order total as possible from the gift
+// card, then from store credit, and records the rest as a PAYMENT tender.
+// Redemptions made before a failure are reversed.
+func (s *OrderService) redeemTenders(ctx context.Context, order *repository.Order, req CreateOrderRequest) error {
+	if req.GiftCardCode == "" && !req.UseStoreCredit {
+		return nil
+	}
+	var sources []tenderSource
+	if req.GiftCardCode != "" {
+		sources = append(sources, tenderSource{
+			kind:      balance.KindGiftCard,
+			tender:    repository.TenderGiftCard,
+			reference: req.GiftCardCode,
+			display:   maskCode(req.GiftCardCode),
+		})
+	}
+	if req.UseStoreCredit {
+		sources = append(sources, tenderSource{
+			kind:      balance.KindStoreCredit,
+			tender:    repository.TenderStoreCredit,
+			reference: req.CustomerID,
+			display:   req.CustomerID,
+		})
+	}
+
+	round := s.roundingFor(order).Round
+	remaining := order.TotalPrice
+	for _, src := range sources {
+		if remaining <= 0 {
+			break
+		}
+		r, err := s.balances.Redeem(ctx, src.kind, src.reference, order.ID, remaining)
+		if errors.Is(err, balance.ErrBalanceNotFound) {
+			if src.kind == balance.KindStoreCredit {
+				continue
+			}
+			s.reverseRedemptions(*order)
+			return &Error{Code: CodeInvalidGiftCard, Message: "gift card not found"}
+		} else if err != nil {
+			s.logger.Error(ctx).Str("order_id", order.ID).Str("kind", src.kind).Err(err).Msg("failed to redeem balance")
+			s.reportUpstream(ctx, "balance", err, map[string]string{"order_id": order.ID})
+			s.reverseRedemptions(*order)
+			return errors.New("balance service unavailable")
+		}
+		if r.Amount <= 0 {
+			continue
+		}
+		order.Tenders = append(order.Tenders, repository.Tender{
+			OrderID:      order.ID,
+			Type:         src.tender,
+			Reference:    src.display,
+			RedemptionID: r.ID,
+			Amount:       round(r.Amount),
+		})
+		remaining = round(remaining - r.Amount)
+	}
+
+	if remaining > 0 {
+		order.Tenders = append(order.Tenders, repository.Tender{
+			OrderID: order.ID,
+			Type:    repository.TenderPayment,
+			Amount:  remaining,
+		})
+	}
+	return nil
+}
+
+func (s *OrderService) reverseRedemptions(orders ...repository.Order) {
+	if s.balances == nil {
+		return
+	}
+	for _, o := range orders {
+		for _, t := range o.Tenders {
+			if t.RedemptionID == "" {
+				continue
+			}
+			if err := s.balances.Reverse(context.Background(), t.RedemptionID); err != nil {
+				s.logger.Error(context.Background()).Str("order_id", o.ID).Str("kind", t.Type).Err(err).Msg("failed to reverse redemption")
+			}
+		}
+	}
+}
+
+// amountDue is the part of the order total not covered by gift cards or
+// store credit.
+func amountDue(order *repository.Order) float64 {
+	if len(order.Tenders) == 0 {
+		return order.TotalPrice
+	}
+	for _, t := range order.Tenders {
+		if t.Type == repository.TenderPayment {
+			return t.Amount
+		}
+	}
+	return 0
+}
+
+// maskCode keeps only the last four characters of a gift card code.
+func maskCode(code string) string {
+	if len(code) <= 4 {
+		return code
+	}
+	return "****" + code[len(code)-4:]
+}
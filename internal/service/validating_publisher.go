@@ -0,0 +1,50 @@
+package service
+
+import (
+	"log"
+	"order-service/internal/metrics"
+	"order-service/internal/repository"
+)
+
+// IEventValidator checks event payloads against the schema of their
+// pattern.
+type IEventValidator interface {
+	ValidateValue(pattern string, data interface{}) error
+}
+
+// ValidatingPublisher refuses events that do not match their schema, so a
+// malformed payload never reaches the bus, and publishes the others
+// through next.
+type ValidatingPublisher struct {
+	next      IPublisher
+	validator IEventValidator
+}
+
+var _ IPublisher = &ValidatingPublisher{}
+
+func NewValidatingPublisher(next IPublisher, validator IEventValidator) *ValidatingPublisher {
+	return &ValidatingPublisher{next: next, validator: validator}
+}
+
+func (p *ValidatingPublisher) PublishOrderCreated(order *repository.Order) error {
+	if err := p.check("order.created", orderCreatedData(order)); err != nil {
+		return err
+	}
+	return p.next.PublishOrderCreated(order)
+}
+
+func (p *ValidatingPublisher) Publish(pattern string, data interface{}) error {
+	if err := p.check(pattern, data); err != nil {
+		return err
+	}
+	return p.next.Publish(pattern, data)
+}
+
+func (p *ValidatingPublisher) check(pattern string, data interface{}) error {
+	if err := p.validator.ValidateValue(pattern, data); err != nil {
+		log.Printf("Refusing to publish %s event: %v", pattern, err)
+		metrics.EventsRejected.WithLabelValues(pattern, "outgoing").Inc()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"order-service/internal/degrade"
+	"order-service/internal/domain"
+	"order-service/internal/events"
+	"order-service/internal/repository"
+	"time"
+)
+
+// validatePendingBatch caps how many orders one ValidatePendingOrders run
+// handles.
+const validatePendingBatch = 100
+
+func (s *OrderService) degraded(mode string) bool {
+	return s.degradation != nil && s.degradation.Active(mode)
+}
+
+// buildUnvalidatedOrder accepts an order without product-service. Only
+// checks that need no product data run now; pricing and stock are checked
+// by ValidatePendingOrders. Orders that must be charged or split up front,
+// and reservations, cannot be priced and are refused.
+func (s *OrderService) buildUnvalidatedOrder(ctx context.Context, req CreateOrderRequest) (*repository.Order, error) {
+	if s.payments != nil || req.Installments > 1 || req.GiftCardCode != "" || req.UseStoreCredit || req.Reserve {
+		return nil, errProductUnavailable
+	}
+	if s.limiter != nil {
+		if err := s.limiter.CheckQuantity(req.ProductID, req.Quantity); err != nil {
+			return nil, &Error{Code: CodeQuantityOutOfRange, Message: err.Error()}
+		}
+	}
+	if _, err := s.checkBlocklist(ctx, req); err != nil {
+		return nil, err
+	}
+
+	order := &repository.Order{
+		ID:           newOrderID(req),
+		ProductID:    req.ProductID,
+		CustomerID:   req.CustomerID,
+		TenantID:     req.TenantID,
+		Quantity:     req.Quantity,
+		Region:       req.Region,
+		Country:      req.Country,
+		DiscountCode: req.DiscountCode,
+		Status:       repository.StatusPendingValidation,
+		// The requested currency is kept until the order can be priced.
+		ConvertedCurrency: req.Currency,
+		CreatedAt:         time.Now().UTC(),
+	}
+
+	if err := s.reserveLimits(ctx, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ValidatePendingOrders prices the orders accepted while product-service
+// was down. Valid orders become PENDING (or ON_HOLD after screening) and
+// are announced; orders that fail a business rule are REJECTED. It stops at
+// the first lookup that fails for another reason and is run by the
+// scheduler.
+func (s *OrderService) ValidatePendingOrders(ctx context.Context) (int, error) {
+	if s.degraded(degrade.ModePendingValidation) {
+		return 0, nil
+	}
+	orders, err := s.repo.GetPendingValidation(validatePendingBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = withProductMemo(ctx)
+	validated := 0
+	for i := range orders {
+		order := &orders[i]
+		quote, decision, err := s.quote(ctx, repriceRequest(order))
+		if err != nil && !rejectable(err) {
+			return validated, err
+		}
+
+		if err != nil {
+			reason := err.Error()
+			err = mutate(order, func(o *domain.Order) error { return o.Reject(reason) })
+		} else {
+			applyQuote(order, quote)
+			err = mutate(order, func(o *domain.Order) error { return o.Validate(o.Pricing) })
+			if err == nil && !decision.CustomerAllowed {
+				s.screen(ctx, order)
+			}
+		}
+		if err != nil {
+			log.Printf("Cannot validate order %s: %v", order.ID, err)
+			continue
+		}
+
+		err = s.repo.CompleteValidation(order)
+		if errors.Is(err, repository.ErrStatusConflict) {
+			continue
+		} else if err != nil {
+			return validated, err
+		}
+		validated++
+
+		if order.Status == repository.StatusRejected {
+			s.releaseLimits(*order)
+			s.publish(ctx, "order.rejected", orderRejectedData(order))
+			s.emit(events.OrderUpdated, order)
+		} else {
+			s.announce(ctx, order)
+			s.emit(events.OrderValidated, order)
+		}
+		if err := s.cache.Delete(s.cache.GetCacheKeyForProduct(order.ProductID)); err != nil {
+			log.Printf("Redis error on delete: %v", err)
+		}
+	}
+
+	if validated > 0 {
+		log.Printf("Validated %d orders accepted while product-service was down", validated)
+	}
+	return validated, nil
+}
+
+// repriceRequest is the request a stored order is priced and checked
+// again with. Until then ConvertedCurrency holds the customer's currency.
+func repriceRequest(order *repository.Order) CreateOrderRequest {
+	return CreateOrderRequest{
+		ProductID:    order.ProductID,
+		Quantity:     order.Quantity,
+		CustomerID:   order.CustomerID,
+		DiscountCode: order.DiscountCode,
+		Currency:     order.ConvertedCurrency,
+		Region:       order.Region,
+		Country:      order.Country,
+		TenantID:     order.TenantID,
+	}
+}
+
+// rejectable reports whether a quote error is final for the order rather
+// than a dependency that may recover.
+func rejectable(err error) bool {
+	var svcErr *Error
+	return errors.As(err, &svcErr) || errors.Is(err, errProductNotFound) || errors.Is(err, errInsufficientStock)
+}
+
+func applyQuote(order *repository.Order, quote *Quote) {
+	order.Subtotal = quote.Subtotal
+	order.DiscountCode = quote.DiscountCode
+	order.DiscountAmount = quote.Discount
+	order.TaxAmount = quote.Tax
+	order.ShippingFee = quote.ShippingFee
+	order.TotalPrice = quote.Total
+	order.Experiment = quote.Experiment
+	order.Variant = quote.Variant
+	order.Currency = quote.Currency
+	order.ConvertedCurrency, order.ExchangeRate, order.ConvertedTotal = "", 0, 0
+	if c := quote.Conversion; c != nil {
+		order.ConvertedCurrency = c.Currency
+		order.ExchangeRate = c.Rate
+		order.ConvertedTotal = c.Total
+	}
+}
@@ -0,0 +1,245 @@
+// Package signing signs outgoing HTTP requests with HMAC SHA-256, so an
+// upstream can tell they come from us and were not altered or replayed.
+//
+// A signed request carries the key ID, the Unix time it was signed at and
+// the hex SHA-256 of its body in headers, and a signature over
+//
+//	METHOD "\n" path?query "\n" timestamp "\n" body hash
+//
+// as hex HMAC SHA-256 keyed with the secret of that key.
+package signing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of a signed request.
+const (
+	HeaderKeyID     = "X-Signature-Key-Id"
+	HeaderTimestamp = "X-Signature-Timestamp"
+	HeaderBodyHash  = "X-Content-SHA256"
+	HeaderSignature = "X-Signature"
+)
+
+var (
+	ErrNoKey            = errors.New("no signing key is active")
+	ErrUnsigned         = errors.New("request is not signed")
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrBadSignature     = errors.New("invalid request signature")
+	ErrStaleTimestamp   = errors.New("request timestamp outside the allowed skew")
+	ErrBodyHashMismatch = errors.New("request body does not match its hash")
+)
+
+// Key is a signing secret. It signs requests from NotBefore on, so a new
+// key can be handed to the upstream before it is used.
+type Key struct {
+	ID        string
+	Secret    []byte
+	NotBefore time.Time
+}
+
+// ParseKeys reads keys written as "id=secret" or "id=secret@RFC3339 time",
+// separated by commas, e.g. "k2=s3cr3t@2026-11-01T00:00:00Z,k1=0ld".
+func ParseKeys(s string) ([]Key, error) {
+	var keys []Key
+	for i, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(part, "=")
+		if !ok || id == "" || secret == "" {
+			// The entry may hold a secret; it is named by position.
+			return nil, fmt.Errorf("invalid signing key #%d: expected id=secret", i+1)
+		}
+		key := Key{ID: id}
+		if secret, from, ok := strings.Cut(secret, "@"); ok {
+			t, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				return nil, fmt.Errorf("invalid start of signing key %s: %w", id, err)
+			}
+			key.Secret, key.NotBefore = []byte(secret), t
+		} else {
+			key.Secret = []byte(secret)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// active returns the newest key started at now.
+func active(keys []Key, now time.Time) (Key, bool) {
+	var best Key
+	found := false
+	for _, k := range keys {
+		if k.NotBefore.After(now) {
+			continue
+		}
+		if !found || k.NotBefore.After(best.NotBefore) {
+			best, found = k, true
+		}
+	}
+	return best, found
+}
+
+func canonical(method, target string, timestamp int64, bodyHash string) string {
+	return method + "\n" + target + "\n" + strconv.FormatInt(timestamp, 10) + "\n" + bodyHash
+}
+
+func sign(secret []byte, msg string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(msg))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Transport signs the requests it sends with the active key. It follows
+// the upstream's clock: when the Date of a response is further than
+// maxSkew from ours, later requests are stamped with the upstream's time,
+// and a request the upstream refused with 401 because of it is sent once
+// more.
+type Transport struct {
+	base    http.RoundTripper
+	maxSkew time.Duration
+	now     func() time.Time
+
+	mu     sync.RWMutex
+	keys   []Key
+	offset time.Duration
+}
+
+func NewTransport(base http.RoundTripper, keys []Key, maxSkew time.Duration) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base, keys: keys, maxSkew: maxSkew, now: time.Now}
+}
+
+// SetKeys replaces the keys, e.g. after a rotation. Requests in flight
+// keep the key they were signed with.
+func (t *Transport) SetKeys(keys []Key) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys = keys
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	offset := t.clockOffset()
+	resp, err := t.send(req, body, offset)
+	if err != nil {
+		return nil, err
+	}
+	if t.observe(resp) != offset && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		return t.send(req, body, t.clockOffset())
+	}
+	return resp, nil
+}
+
+func (t *Transport) send(req *http.Request, body []byte, offset time.Duration) (*http.Response, error) {
+	now := t.now()
+	t.mu.RLock()
+	key, ok := active(t.keys, now)
+	t.mu.RUnlock()
+	if !ok {
+		return nil, ErrNoKey
+	}
+
+	signed := req.Clone(req.Context())
+	if body != nil {
+		signed.Body = io.NopCloser(bytes.NewReader(body))
+		signed.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+		signed.ContentLength = int64(len(body))
+	}
+	timestamp := now.Add(offset).Unix()
+	bodyHash := hashBody(body)
+	signed.Header.Set(HeaderKeyID, key.ID)
+	signed.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	signed.Header.Set(HeaderBodyHash, bodyHash)
+	signed.Header.Set(HeaderSignature, sign(key.Secret, canonical(req.Method, req.URL.RequestURI(), timestamp, bodyHash)))
+	return t.base.RoundTrip(signed)
+}
+
+func (t *Transport) clockOffset() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.offset
+}
+
+// observe compares the upstream's clock with ours and returns the offset
+// to sign with from now on.
+func (t *Transport) observe(resp *http.Response) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return t.offset
+	}
+	// Date has whole seconds; smaller differences are noise.
+	diff := date.Sub(t.now().Truncate(time.Second))
+	if diff > t.maxSkew || diff < -t.maxSkew {
+		t.offset = diff
+	} else {
+		t.offset = 0
+	}
+	return t.offset
+}
+
+// Verify checks the signature of a request whose body was read into body,
+// for upstreams and tests. The timestamp may be up to maxSkew from now.
+func Verify(r *http.Request, body []byte, keys []Key, maxSkew time.Duration, now time.Time) error {
+	id, ts, sig := r.Header.Get(HeaderKeyID), r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature)
+	if id == "" || ts == "" || sig == "" {
+		return ErrUnsigned
+	}
+	var key *Key
+	for i := range keys {
+		if keys[i].ID == id {
+			key = &keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return ErrUnknownKey
+	}
+	timestamp, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrUnsigned
+	}
+	if d := now.Sub(time.Unix(timestamp, 0)); d > maxSkew || d < -maxSkew {
+		return ErrStaleTimestamp
+	}
+	bodyHash := hashBody(body)
+	if r.Header.Get(HeaderBodyHash) != bodyHash {
+		return ErrBodyHashMismatch
+	}
+	want := sign(key.Secret, canonical(r.Method, r.URL.RequestURI(), timestamp, bodyHash))
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		return ErrBadSignature
+	}
+	return nil
+}
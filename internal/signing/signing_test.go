@@ -0,0 +1,165 @@
+package signing
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys("k2=new@2024-06-01T00:00:00Z, k1=old")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "k2" || string(keys[0].Secret) != "new" || keys[1].ID != "k1" || string(keys[1].Secret) != "old" {
+		t.Fatalf("Unexpected keys %+v", keys)
+	}
+	if !keys[0].NotBefore.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) || !keys[1].NotBefore.IsZero() {
+		t.Errorf("Unexpected start times %v, %v", keys[0].NotBefore, keys[1].NotBefore)
+	}
+
+	for _, spec := range []string{"k1", "=secret", "k1=", "k1=s@tomorrow"} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+	if _, err := ParseKeys("k1=ok,s3cr3t"); err == nil || strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("Expected an error that does not repeat the entry, got %v", err)
+	}
+}
+
+func TestActive(t *testing.T) {
+	rotation := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	keys := []Key{{ID: "k1"}, {ID: "k2", NotBefore: rotation}}
+
+	if k, _ := active(keys, rotation.Add(-time.Second)); k.ID != "k1" {
+		t.Errorf("Expected k1 before the rotation, got %s", k.ID)
+	}
+	if k, _ := active(keys, rotation); k.ID != "k2" {
+		t.Errorf("Expected k2 from the rotation on, got %s", k.ID)
+	}
+	if _, ok := active(keys[1:], rotation.Add(-time.Second)); ok {
+		t.Error("Expected no key before the only one starts")
+	}
+}
+
+// upstream verifies requests like product-service does, with its clock
+// at now.
+func upstream(keys []Key, now func() time.Time, calls *[]*http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*calls = append(*calls, r)
+		w.Header().Set("Date", now().UTC().Format(http.TimeFormat))
+		if err := Verify(r, body, keys, 30*time.Second, now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Write(body)
+	}))
+}
+
+func TestTransport(t *testing.T) {
+	keys := []Key{{ID: "k1", Secret: []byte("secret")}}
+	var calls []*http.Request
+	server := upstream(keys, time.Now, &calls)
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(nil, keys, 30*time.Second)}
+
+	resp, err := client.Post(server.URL+"/products/p1?fields=price", "application/json", strings.NewReader(`{"q":1}`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != `{"q":1}` {
+		t.Fatalf("Expected the signed request to be accepted, got %d %s", resp.StatusCode, body)
+	}
+	if calls[0].Header.Get(HeaderKeyID) != "k1" {
+		t.Errorf("Expected key k1, got %q", calls[0].Header.Get(HeaderKeyID))
+	}
+
+	resp, err = client.Get(server.URL + "/products/p1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a request without body to be accepted, got %d", resp.StatusCode)
+	}
+
+	other := &http.Client{Transport: NewTransport(nil, []Key{{ID: "k1", Secret: []byte("wrong")}}, 30*time.Second)}
+	resp, err = other.Get(server.URL + "/products/p1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong secret to be refused, got %d", resp.StatusCode)
+	}
+}
+
+func TestTransportClockSkew(t *testing.T) {
+	keys := []Key{{ID: "k1", Secret: []byte("secret")}}
+	ahead := func() time.Time { return time.Now().Add(5 * time.Minute) }
+	var calls []*http.Request
+	server := upstream(keys, ahead, &calls)
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(nil, keys, 30*time.Second)}
+
+	resp, err := client.Get(server.URL + "/products/p1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(calls) != 2 {
+		t.Fatalf("Expected the refused request to be sent once more and accepted, got %d after %d calls", resp.StatusCode, len(calls))
+	}
+
+	resp, err = client.Get(server.URL + "/products/p1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(calls) != 3 {
+		t.Errorf("Expected later requests to follow the upstream clock, got %d after %d calls", resp.StatusCode, len(calls))
+	}
+}
+
+func TestVerify(t *testing.T) {
+	keys := []Key{{ID: "k1", Secret: []byte("secret")}}
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"q":1}`)
+	signed := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/products?x=1", nil)
+		bodyHash := hashBody(body)
+		r.Header.Set(HeaderKeyID, "k1")
+		r.Header.Set(HeaderTimestamp, "1700000000")
+		r.Header.Set(HeaderBodyHash, bodyHash)
+		r.Header.Set(HeaderSignature, sign([]byte("secret"), canonical(http.MethodPost, "/products?x=1", now.Unix(), bodyHash)))
+		return r
+	}
+
+	if err := Verify(signed(), body, keys, time.Minute, now); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := Verify(signed(), []byte(`{"q":2}`), keys, time.Minute, now); err != ErrBodyHashMismatch {
+		t.Errorf("Expected ErrBodyHashMismatch, got %v", err)
+	}
+	if err := Verify(signed(), body, keys, time.Minute, now.Add(2*time.Minute)); err != ErrStaleTimestamp {
+		t.Errorf("Expected ErrStaleTimestamp, got %v", err)
+	}
+	if err := Verify(signed(), body, []Key{{ID: "k2"}}, time.Minute, now); err != ErrUnknownKey {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+	r := signed()
+	r.URL.RawQuery = "x=2"
+	if err := Verify(r, body, keys, time.Minute, now); err != ErrBadSignature {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+	if err := Verify(httptest.NewRequest(http.MethodGet, "/", nil), nil, keys, time.Minute, now); err != ErrUnsigned {
+		t.Errorf("Expected ErrUnsigned, got %v", err)
+	}
+}
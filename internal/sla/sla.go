@@ -0,0 +1,176 @@
+package sla
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"order-service/internal/metrics"
+	"order-service/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const BreachedPattern = "order.sla_breached"
+
+// Thresholds maps order statuses to how long an order may stay in them.
+type Thresholds map[string]time.Duration
+
+// ParseThresholds reads a JSON object of statuses and durations, e.g.
+// {"PENDING":"30m","BACKORDERED":"72h"}.
+func ParseThresholds(raw string) (Thresholds, error) {
+	thresholds := Thresholds{}
+	if raw == "" {
+		return thresholds, nil
+	}
+	var durations map[string]string
+	if err := json.Unmarshal([]byte(raw), &durations); err != nil {
+		return nil, fmt.Errorf("failed to parse order SLAs: %w", err)
+	}
+	for status, s := range durations {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SLA for %s: %q", status, s)
+		}
+		thresholds[status] = d
+	}
+	return thresholds, nil
+}
+
+func (t Thresholds) statuses() []string {
+	statuses := make([]string, 0, len(t))
+	for status := range t {
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+type IPublisher interface {
+	Publish(pattern string, data interface{}) error
+}
+
+// Monitor finds orders that stayed in a status longer than its threshold,
+// marks them and publishes order.sla_breached once per status visit.
+type Monitor struct {
+	db         *gorm.DB
+	thresholds Thresholds
+	publisher  IPublisher
+	batchSize  int
+	now        func() time.Time
+}
+
+func NewMonitor(db *gorm.DB, thresholds Thresholds, publisher IPublisher, batchSize int) *Monitor {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Monitor{db: db, thresholds: thresholds, publisher: publisher, batchSize: batchSize, now: time.Now}
+}
+
+// Enabled reports whether any SLA is configured.
+func (m *Monitor) Enabled() bool {
+	return len(m.thresholds) > 0
+}
+
+// enteredStatus is when an order entered its current status.
+const enteredStatus = "COALESCE(status_changed_at, created_at)"
+
+// Check marks and announces new breaches and refreshes the SLA metrics. It
+// returns how many breaches it found.
+func (m *Monitor) Check(ctx context.Context) (int, error) {
+	found := 0
+	now := m.now().UTC()
+	for status, limit := range m.thresholds {
+		for {
+			var orders []repository.Order
+			err := m.db.WithContext(ctx).
+				Where("status = ? AND sla_breached_at IS NULL AND "+enteredStatus+" < ?", status, now.Add(-limit)).
+				Order(enteredStatus).Limit(m.batchSize).Find(&orders).Error
+			if err != nil {
+				return found, err
+			}
+			for _, order := range orders {
+				// The status check skips orders that moved on meanwhile.
+				res := m.db.WithContext(ctx).Model(&repository.Order{}).
+					Where("id = ? AND status = ? AND sla_breached_at IS NULL", order.ID, status).
+					Update("sla_breached_at", now)
+				if res.Error != nil {
+					return found, res.Error
+				}
+				if res.RowsAffected == 0 {
+					continue
+				}
+				found++
+				metrics.SLABreaches.WithLabelValues(status).Inc()
+				m.announce(order, limit)
+			}
+			if len(orders) < m.batchSize {
+				break
+			}
+		}
+	}
+	return found, m.updateMetrics(ctx, now)
+}
+
+func (m *Monitor) announce(order repository.Order, limit time.Duration) {
+	since := order.CreatedAt
+	if order.StatusChangedAt != nil {
+		since = *order.StatusChangedAt
+	}
+	if err := m.publisher.Publish(BreachedPattern, breachedData(order, since, limit)); err != nil {
+		log.Printf("Failed to publish %s event for order %s: %v", BreachedPattern, order.ID, err)
+	}
+}
+
+func breachedData(order repository.Order, since time.Time, limit time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"orderId":   order.ID,
+		"productId": order.ProductID,
+		"status":    order.Status,
+		"since":     since,
+		"threshold": limit.String(),
+	}
+}
+
+func (m *Monitor) updateMetrics(ctx context.Context, now time.Time) error {
+	var rows []struct {
+		Status   string
+		Orders   int64
+		Breached int64
+		Oldest   time.Time
+	}
+	err := m.db.WithContext(ctx).Model(&repository.Order{}).
+		Select("status, COUNT(*) AS orders, COUNT(sla_breached_at) AS breached, MIN("+enteredStatus+") AS oldest").
+		Where("status IN ?", m.thresholds.statuses()).
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for status := range m.thresholds {
+		metrics.OrdersInStatus.WithLabelValues(status).Set(0)
+		metrics.SLABreachedOrders.WithLabelValues(status).Set(0)
+		metrics.OldestInStatus.WithLabelValues(status).Set(0)
+	}
+	for _, row := range rows {
+		metrics.OrdersInStatus.WithLabelValues(row.Status).Set(float64(row.Orders))
+		metrics.SLABreachedOrders.WithLabelValues(row.Status).Set(float64(row.Breached))
+		metrics.OldestInStatus.WithLabelValues(row.Status).Set(now.Sub(row.Oldest).Seconds())
+	}
+	return nil
+}
+
+// Breaches lists orders currently in breach of their status's SLA, longest
+// breached first. An empty status lists every status.
+func (m *Monitor) Breaches(ctx context.Context, status string, limit, offset int) ([]repository.Order, error) {
+	statuses := m.thresholds.statuses()
+	if status != "" {
+		statuses = []string{status}
+	}
+	var orders []repository.Order
+	err := m.db.WithContext(ctx).
+		Where("status IN ? AND sla_breached_at IS NOT NULL", statuses).
+		Order("sla_breached_at, id").Limit(limit).Offset(offset).
+		Find(&orders).Error
+	return orders, err
+}
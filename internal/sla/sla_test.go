@@ -0,0 +1,43 @@
+package sla
+
+import (
+	"order-service/internal/golden"
+	"order-service/internal/repository"
+	"order-service/internal/schema"
+	"testing"
+	"time"
+)
+
+func TestParseThresholds(t *testing.T) {
+	thresholds, err := ParseThresholds(`{"PENDING":"30m","BACKORDERED":"72h"}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if thresholds["PENDING"] != 30*time.Minute || thresholds["BACKORDERED"] != 72*time.Hour {
+		t.Errorf("Unexpected thresholds %v", thresholds)
+	}
+
+	for _, raw := range []string{`{"PENDING":"soon"}`, `{"PENDING":"-1m"}`, `["PENDING"]`} {
+		if _, err := ParseThresholds(raw); err == nil {
+			t.Errorf("Expected an error for %s", raw)
+		}
+	}
+	if thresholds, err := ParseThresholds(""); err != nil || len(thresholds) != 0 {
+		t.Errorf("Expected no SLAs, got %v, %v", thresholds, err)
+	}
+}
+
+func TestBreachedPayload(t *testing.T) {
+	since := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	order := repository.Order{ID: "order-1", ProductID: "p1", Status: repository.StatusBackordered}
+	data := breachedData(order, since, 72*time.Hour)
+	golden.JSON(t, "events/"+BreachedPattern, data)
+
+	schemas, err := schema.Load()
+	if err != nil {
+		t.Fatalf("Expected schemas to load, got %v", err)
+	}
+	if err := schemas.ValidateValue(BreachedPattern, data); err != nil {
+		t.Errorf("Expected the payload to match its schema, got %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package stats
+
+import (
+	"context"
+	"log"
+	"order-service/internal/repository"
+	"order-service/internal/rounding"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DailyCustomerStats aggregates one customer's counted orders created on
+// one UTC day in one currency.
+type DailyCustomerStats struct {
+	CustomerID   string    `gorm:"primaryKey"`
+	Day          time.Time `gorm:"primaryKey;type:date"`
+	Currency     string    `gorm:"primaryKey"`
+	Orders       int64     `gorm:"not null"`
+	Revenue      float64   `gorm:"not null"`
+	FirstOrderAt time.Time `gorm:"not null"`
+	LastOrderAt  time.Time `gorm:"not null"`
+	UpdatedAt    time.Time
+}
+
+func (DailyCustomerStats) TableName() string { return "customer_order_stats_daily" }
+
+// CustomerLTV is a customer's lifetime value: everything they ordered
+// over all time. Amounts in different currencies are not added up, so
+// spend is given per currency.
+type CustomerLTV struct {
+	CustomerID   string          `json:"customerId"`
+	OrderCount   int64           `json:"orderCount"`
+	FirstOrderAt *time.Time      `json:"firstOrderAt,omitempty"`
+	LastOrderAt  *time.Time      `json:"lastOrderAt,omitempty"`
+	Spend        []CurrencySpend `json:"spend"`
+	// UpdatedAt is when the newest aggregate was computed.
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+}
+
+// CurrencySpend is what a customer spent in one currency.
+type CurrencySpend struct {
+	Currency          string  `json:"currency"`
+	TotalSpend        float64 `json:"totalSpend"`
+	OrderCount        int64   `json:"orderCount"`
+	AverageOrderValue float64 `json:"averageOrderValue"`
+}
+
+// customerTotals is a customer's daily stats summed per currency.
+type customerTotals struct {
+	Currency     string
+	Orders       int64
+	Revenue      float64
+	FirstOrderAt time.Time
+	LastOrderAt  time.Time
+	UpdatedAt    time.Time
+}
+
+// rollupCustomers rebuilds the customers' daily stats of the period's
+// days, in tx.
+func rollupCustomers(ctx context.Context, tx *gorm.DB, p period) (int64, error) {
+	var rows []DailyCustomerStats
+	err := customerOrders(ctx, tx).Scopes(p.orders).Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Scopes(p.stats).Delete(&DailyCustomerStats{}).Error; err != nil {
+		return 0, err
+	}
+	return insertCustomerStats(tx, rows)
+}
+
+// RebuildCustomers recomputes every daily stat of the customers from their
+// orders, in tx. Retention calls it after anonymizing orders, which does
+// not change their status and so goes unnoticed by the rollup, so they
+// stop counting towards the customers' lifetime values.
+func RebuildCustomers(ctx context.Context, tx *gorm.DB, customerIDs []string) error {
+	if len(customerIDs) == 0 {
+		return nil
+	}
+	var rows []DailyCustomerStats
+	err := customerOrders(ctx, tx).Where("customer_id IN ?", customerIDs).Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("customer_id IN ?", customerIDs).Delete(&DailyCustomerStats{}).Error; err != nil {
+		return err
+	}
+	_, err = insertCustomerStats(tx, rows)
+	return err
+}
+
+// customerOrders aggregates counted orders per customer, day and currency.
+func customerOrders(ctx context.Context, tx *gorm.DB) *gorm.DB {
+	return tx.WithContext(ctx).Model(&repository.Order{}).
+		Select("customer_id, (created_at AT TIME ZONE 'UTC')::date AS day, COALESCE(currency, '') AS currency, COUNT(*) AS orders, "+
+			"COALESCE(SUM(total_price), 0) AS revenue, MIN(created_at) AS first_order_at, MAX(created_at) AS last_order_at").
+		Where("status IN ? AND customer_id <> ''", CountedStatuses).
+		Group("customer_id, day, currency")
+}
+
+func insertCustomerStats(tx *gorm.DB, rows []DailyCustomerStats) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	now := time.Now().UTC()
+	for i := range rows {
+		rows[i].UpdatedAt = now
+	}
+	return int64(len(rows)), tx.CreateInBatches(rows, 500).Error
+}
+
+// SetLTVCache serves CustomerLTV from cache, loading it from the
+// aggregates on a miss.
+func (s *Store) SetLTVCache(cache *LTVCache) {
+	s.ltv = cache
+}
+
+// CustomerLTV returns the customer's lifetime value, from the cache if one
+// is set.
+func (s *Store) CustomerLTV(ctx context.Context, customerID string) (*CustomerLTV, error) {
+	if s.ltv != nil {
+		ltv, err := s.ltv.Get(ctx, customerID)
+		if err != nil {
+			log.Printf("Failed to read cached LTV of customer %s: %v", customerID, err)
+		}
+		if ltv != nil {
+			return ltv, nil
+		}
+	}
+	ltv, err := s.ForCustomer(ctx, customerID)
+	if err != nil {
+		return nil, err
+	}
+	if s.ltv != nil {
+		if err := s.ltv.Set(ctx, ltv); err != nil {
+			log.Printf("Failed to cache LTV of customer %s: %v", customerID, err)
+		}
+	}
+	return ltv, nil
+}
+
+// ForCustomer sums the customer's daily stats over all time.
+func (s *Store) ForCustomer(ctx context.Context, customerID string) (*CustomerLTV, error) {
+	var rows []customerTotals
+	err := s.db.WithContext(ctx).Model(&DailyCustomerStats{}).
+		Select("currency, SUM(orders) AS orders, SUM(revenue) AS revenue, "+
+			"MIN(first_order_at) AS first_order_at, MAX(last_order_at) AS last_order_at, MAX(updated_at) AS updated_at").
+		Where("customer_id = ?", customerID).
+		Group("currency").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return customerLTV(customerID, rows), nil
+}
+
+// customerLTV combines a customer's per-currency totals.
+func customerLTV(customerID string, rows []customerTotals) *CustomerLTV {
+	ltv := &CustomerLTV{CustomerID: customerID, Spend: make([]CurrencySpend, 0, len(rows))}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Currency < rows[j].Currency })
+	round := rounding.HalfUp{Places: 2}
+	for _, row := range rows {
+		spend := CurrencySpend{Currency: row.Currency, TotalSpend: round.Round(row.Revenue), OrderCount: row.Orders}
+		if row.Orders > 0 {
+			spend.AverageOrderValue = round.Round(row.Revenue / float64(row.Orders))
+		}
+		ltv.Spend = append(ltv.Spend, spend)
+		ltv.OrderCount += row.Orders
+		if first := row.FirstOrderAt; ltv.FirstOrderAt == nil || first.Before(*ltv.FirstOrderAt) {
+			ltv.FirstOrderAt = &first
+		}
+		if last := row.LastOrderAt; ltv.LastOrderAt == nil || last.After(*ltv.LastOrderAt) {
+			ltv.LastOrderAt = &last
+		}
+		if row.UpdatedAt.After(ltv.UpdatedAt) {
+			ltv.UpdatedAt = row.UpdatedAt
+		}
+	}
+	return ltv
+}
@@ -0,0 +1,29 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCustomerLTV(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 5, d, 12, 0, 0, 0, time.UTC) }
+	ltv := customerLTV("c1", []customerTotals{
+		{Currency: "USD", Orders: 3, Revenue: 100, FirstOrderAt: day(3), LastOrderAt: day(9), UpdatedAt: day(10)},
+		{Currency: "IDR", Orders: 1, Revenue: 150000, FirstOrderAt: day(5), LastOrderAt: day(5), UpdatedAt: day(11)},
+	})
+
+	if ltv.OrderCount != 4 || !ltv.FirstOrderAt.Equal(day(3)) || !ltv.LastOrderAt.Equal(day(9)) || !ltv.UpdatedAt.Equal(day(11)) {
+		t.Errorf("Unexpected totals %+v", ltv)
+	}
+	if len(ltv.Spend) != 2 || ltv.Spend[0].Currency != "IDR" || ltv.Spend[1].Currency != "USD" {
+		t.Fatalf("Expected spend per currency in order, got %+v", ltv.Spend)
+	}
+	if usd := ltv.Spend[1]; usd.TotalSpend != 100 || usd.OrderCount != 3 || usd.AverageOrderValue != 33.33 {
+		t.Errorf("Unexpected USD spend %+v", usd)
+	}
+
+	empty := customerLTV("c2", nil)
+	if empty.OrderCount != 0 || empty.FirstOrderAt != nil || empty.Spend == nil {
+		t.Errorf("Expected an empty LTV for a customer without orders, got %+v", empty)
+	}
+}
@@ -0,0 +1,96 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// setIfSettledScript caches ARGV[1] at KEYS[1] for ARGV[2] milliseconds
+// unless KEYS[2] marks the customer's aggregates as behind.
+var setIfSettledScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 1 then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+return 1
+`)
+
+// LTVCache keeps customers' lifetime values in Redis. A new order drops
+// the customer's entry, but the aggregates only count it at the next
+// rollup, so for settle after an order the value is recomputed on every
+// read instead of caching one that misses the order.
+type LTVCache struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+	settle time.Duration
+	bypass func() bool
+}
+
+// NewLTVCache caches values for ttl. settle should cover the rollup
+// interval.
+func NewLTVCache(client redis.UniversalClient, ttl, settle time.Duration) *LTVCache {
+	return &LTVCache{client: client, ttl: ttl, settle: settle, bypass: func() bool { return false }}
+}
+
+// SetBypass skips Redis while bypass reports true: reads miss and writes
+// are dropped. Call it before the cache is used.
+func (c *LTVCache) SetBypass(bypass func() bool) {
+	c.bypass = bypass
+}
+
+func (c *LTVCache) keys(customerID string) (string, string) {
+	// The hash tag keeps both keys on one shard for the script.
+	key := "ltv:customer:{" + customerID + "}"
+	return key, key + ":pending"
+}
+
+// Get returns the customer's cached value, or nil if there is none.
+func (c *LTVCache) Get(ctx context.Context, customerID string) (*CustomerLTV, error) {
+	if c.bypass() {
+		return nil, nil
+	}
+	key, _ := c.keys(customerID)
+	b, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ltv CustomerLTV
+	if err := json.Unmarshal(b, &ltv); err != nil {
+		return nil, err
+	}
+	return &ltv, nil
+}
+
+// Set caches ltv unless the customer ordered within settle.
+func (c *LTVCache) Set(ctx context.Context, ltv *CustomerLTV) error {
+	if c.bypass() {
+		return nil
+	}
+	b, err := json.Marshal(ltv)
+	if err != nil {
+		return err
+	}
+	key, pending := c.keys(ltv.CustomerID)
+	return setIfSettledScript.Run(ctx, c.client, []string{key, pending}, b, c.ttl.Milliseconds()).Err()
+}
+
+// Invalidate drops the customer's cached value after a new order.
+func (c *LTVCache) Invalidate(ctx context.Context, customerID string) error {
+	if c.bypass() {
+		return nil
+	}
+	key, pending := c.keys(customerID)
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, pending, 1, c.settle)
+		pipe.Del(ctx, key)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,225 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/internal/repository"
+	"order-service/internal/rounding"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CountedStatuses are the order statuses included in the statistics:
+// orders that were placed and not rejected, expired or still in review.
+var CountedStatuses = []string{repository.StatusPending, repository.StatusBackordered, repository.StatusPaid}
+
+// DailyProductStats aggregates one product's counted orders created on one
+// UTC day.
+type DailyProductStats struct {
+	ProductID string    `gorm:"primaryKey"`
+	Day       time.Time `gorm:"primaryKey;type:date"`
+	Orders    int64     `gorm:"not null"`
+	Units     int64     `gorm:"not null"`
+	Revenue   float64   `gorm:"not null"`
+	Currency  string
+	UpdatedAt time.Time
+}
+
+func (DailyProductStats) TableName() string { return "product_order_stats_daily" }
+
+// Windows are the periods ProductStats can be asked for, in days. A zero
+// length means all time.
+var Windows = map[string]int{"1d": 1, "7d": 7, "30d": 30, "90d": 90, "365d": 365, "all": 0}
+
+// ProductStats sums a product's daily stats over a window.
+type ProductStats struct {
+	ProductID         string    `json:"productId"`
+	Window            string    `json:"window"`
+	From              time.Time `json:"from,omitempty"`
+	TotalOrders       int64     `json:"totalOrders"`
+	UnitsSold         int64     `json:"unitsSold"`
+	Revenue           float64   `json:"revenue"`
+	AverageOrderValue float64   `json:"averageOrderValue"`
+	Currency          string    `json:"currency,omitempty"`
+	// UpdatedAt is when the newest aggregate in the window was computed.
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+}
+
+type Store struct {
+	db  *gorm.DB
+	ltv *LTVCache
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// rollupName names the rollup's watermark.
+const rollupName = "daily"
+
+// commitLag keeps the watermark behind the newest changes, whose
+// transactions may still be committing with an earlier status_changed_at.
+const commitLag = 5 * time.Second
+
+// Watermark is how far the rollup has caught up: every order change with a
+// status_changed_at before At is counted in the daily stats.
+type Watermark struct {
+	Name      string    `gorm:"primaryKey"`
+	At        time.Time `gorm:"not null"`
+	UpdatedAt time.Time
+}
+
+func (Watermark) TableName() string { return "order_stats_watermarks" }
+
+// period is the days a rollup rebuilds: every day from from on, or only
+// the days in on when it is set.
+type period struct {
+	from time.Time
+	on   []time.Time
+}
+
+// orders restricts a query of orders to those created in the period.
+func (p period) orders(db *gorm.DB) *gorm.DB {
+	if p.on == nil {
+		return db.Where("created_at >= ?", p.from)
+	}
+	conds := make([]string, len(p.on))
+	args := make([]interface{}, 0, 2*len(p.on))
+	for i, day := range p.on {
+		conds[i] = "(created_at >= ? AND created_at < ?)"
+		args = append(args, day, day.AddDate(0, 0, 1))
+	}
+	return db.Where(strings.Join(conds, " OR "), args...)
+}
+
+// stats restricts a query of daily stats to the period's days.
+func (p period) stats(db *gorm.DB) *gorm.DB {
+	if p.on == nil {
+		return db.Where("day >= ?", p.from)
+	}
+	return db.Where("day IN ?", p.on)
+}
+
+// Rollup brings the daily stats of every product and customer up to date
+// with the orders created or changed since the watermark. Only the days
+// those orders were created on are rebuilt, whole, so orders that changed
+// status are moved in or out of the counts. Without a watermark yet it
+// rebuilds every day from since's day.
+func (s *Store) Rollup(ctx context.Context, since time.Time) (int64, error) {
+	upTo := time.Now().UTC().Add(-commitLag)
+	var mark Watermark
+	err := s.db.WithContext(ctx).Where("name = ?", rollupName).First(&mark).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.rebuild(ctx, period{from: since.UTC().Truncate(24 * time.Hour)}, &upTo)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var days []time.Time
+	err = s.db.WithContext(ctx).Model(&repository.Order{}).
+		Select("DISTINCT (created_at AT TIME ZONE 'UTC')::date AS day").
+		Where("status_changed_at >= ? AND status_changed_at < ?", mark.At, upTo).
+		Scan(&days).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(days) == 0 {
+		return 0, saveWatermark(s.db.WithContext(ctx), upTo)
+	}
+	return s.rebuild(ctx, period{on: days}, &upTo)
+}
+
+// Rebuild recomputes the daily stats of every product and customer for the
+// days from since's day up to today, whatever the watermark says. It is
+// used to backfill the stats from old orders.
+func (s *Store) Rebuild(ctx context.Context, since time.Time) (int64, error) {
+	return s.rebuild(ctx, period{from: since.UTC().Truncate(24 * time.Hour)}, nil)
+}
+
+// rebuild recomputes the stats of the period's days and, with mark set,
+// moves the watermark there in the same transaction.
+func (s *Store) rebuild(ctx context.Context, p period, mark *time.Time) (int64, error) {
+	var rows []DailyProductStats
+	err := s.db.WithContext(ctx).Model(&repository.Order{}).
+		Select("product_id, (created_at AT TIME ZONE 'UTC')::date AS day, COUNT(*) AS orders, "+
+			"COALESCE(SUM(quantity), 0) AS units, COALESCE(SUM(total_price), 0) AS revenue, MAX(currency) AS currency").
+		Scopes(p.orders).
+		Where("status IN ?", CountedStatuses).
+		Group("product_id, day").
+		Scan(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	var customers int64
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Days whose orders all dropped out of the counted statuses have no
+		// row in rows.
+		if err := tx.Scopes(p.stats).Delete(&DailyProductStats{}).Error; err != nil {
+			return err
+		}
+		if customers, err = rollupCustomers(ctx, tx, p); err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			for i := range rows {
+				rows[i].UpdatedAt = now
+			}
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(rows, 500).Error; err != nil {
+				return err
+			}
+		}
+		if mark == nil {
+			return nil
+		}
+		return saveWatermark(tx, *mark)
+	})
+	return int64(len(rows)) + customers, err
+}
+
+func saveWatermark(tx *gorm.DB, at time.Time) error {
+	return tx.Clauses(clause.OnConflict{UpdateAll: true}).
+		Create(&Watermark{Name: rollupName, At: at, UpdatedAt: time.Now().UTC()}).Error
+}
+
+// ForProduct sums the product's stats over window, one of Windows.
+func (s *Store) ForProduct(ctx context.Context, productID, window string) (*ProductStats, error) {
+	days, ok := Windows[window]
+	if !ok {
+		return nil, fmt.Errorf("unknown window %q", window)
+	}
+	out := &ProductStats{ProductID: productID, Window: window}
+	q := s.db.WithContext(ctx).Model(&DailyProductStats{}).Where("product_id = ?", productID)
+	if days > 0 {
+		out.From = time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+		q = q.Where("day >= ?", out.From)
+	}
+
+	var row struct {
+		Orders    int64
+		Units     int64
+		Revenue   float64
+		Currency  string
+		UpdatedAt *time.Time
+	}
+	err := q.Select("COALESCE(SUM(orders), 0) AS orders, COALESCE(SUM(units), 0) AS units, " +
+		"COALESCE(SUM(revenue), 0) AS revenue, COALESCE(MAX(currency), '') AS currency, MAX(updated_at) AS updated_at").
+		Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	out.TotalOrders, out.UnitsSold, out.Revenue, out.Currency = row.Orders, row.Units, row.Revenue, row.Currency
+	if row.UpdatedAt != nil {
+		out.UpdatedAt = *row.UpdatedAt
+	}
+	if out.TotalOrders > 0 {
+		out.AverageOrderValue = rounding.HalfUp{Places: 2}.Round(out.Revenue / float64(out.TotalOrders))
+	}
+	return out, nil
+}
@@ -0,0 +1,212 @@
+package stats
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func mockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	sqlDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return db, mock
+}
+
+func quoted(query string) string {
+	return regexp.QuoteMeta(query)
+}
+
+func TestRollup(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	productRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"product_id", "day", "orders", "units", "revenue", "currency"}).
+			AddRow("p1", day(3), 2, 3, 30.0, "USD")
+	}
+	customerRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"customer_id", "day", "currency", "orders", "revenue", "first_order_at", "last_order_at"}).
+			AddRow("c1", day(3), "USD", 2, 30.0, day(3), day(3).Add(time.Hour))
+	}
+	watermark := func(at time.Time) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"name", "at", "updated_at"})
+		if !at.IsZero() {
+			rows.AddRow(rollupName, at, at)
+		}
+		return rows
+	}
+
+	t.Run("the first run rebuilds from since and sets the watermark", func(t *testing.T) {
+		db, mock := mockDB(t)
+		since := time.Date(2026, 10, 1, 15, 0, 0, 0, time.UTC)
+
+		mock.ExpectQuery(quoted(`SELECT * FROM "order_stats_watermarks" WHERE name = $1`)).WillReturnRows(watermark(time.Time{}))
+		mock.ExpectQuery(quoted(`FROM "orders" WHERE status IN ($1,$2,$3) AND created_at >= $4 GROUP BY product_id, day`)).
+			WithArgs("PENDING", "BACKORDERED", "PAID", day(1)).
+			WillReturnRows(productRows())
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`DELETE FROM "product_order_stats_daily" WHERE day >= $1`)).WithArgs(day(1)).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectQuery(quoted(`FROM "orders" WHERE (status IN ($1,$2,$3) AND customer_id <> '') AND created_at >= $4`)).
+			WillReturnRows(customerRows())
+		mock.ExpectExec(quoted(`DELETE FROM "customer_order_stats_daily" WHERE day >= $1`)).WithArgs(day(1)).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectExec(quoted(`INSERT INTO "customer_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`INSERT INTO "product_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`INSERT INTO "order_stats_watermarks"`)).WithArgs(rollupName, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		n, err := NewStore(db).Rollup(context.Background(), since)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if n != 2 {
+			t.Errorf("Expected 2 rows written, got %d", n)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("later runs rebuild only the days of changed orders", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mark := time.Now().UTC().Add(-time.Minute)
+
+		mock.ExpectQuery(quoted(`SELECT * FROM "order_stats_watermarks" WHERE name = $1`)).WillReturnRows(watermark(mark))
+		mock.ExpectQuery(quoted(`SELECT DISTINCT (created_at AT TIME ZONE 'UTC')::date AS day FROM "orders" WHERE status_changed_at >= $1 AND status_changed_at < $2`)).
+			WithArgs(mark, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"day"}).AddRow(day(3)).AddRow(day(9)))
+		mock.ExpectQuery(quoted(`FROM "orders" WHERE status IN ($1,$2,$3) AND ((created_at >= $4 AND created_at < $5) OR (created_at >= $6 AND created_at < $7)) GROUP BY`)).
+			WithArgs("PENDING", "BACKORDERED", "PAID", day(3), day(4), day(9), day(10)).
+			WillReturnRows(productRows())
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`DELETE FROM "product_order_stats_daily" WHERE day IN ($1,$2)`)).WithArgs(day(3), day(9)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(quoted(`FROM "orders" WHERE (status IN ($1,$2,$3) AND customer_id <> '') AND ((created_at >= $4 AND created_at < $5) OR (created_at >= $6 AND created_at < $7))`)).
+			WillReturnRows(customerRows())
+		mock.ExpectExec(quoted(`DELETE FROM "customer_order_stats_daily" WHERE day IN ($1,$2)`)).WithArgs(day(3), day(9)).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(quoted(`INSERT INTO "customer_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`INSERT INTO "product_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`INSERT INTO "order_stats_watermarks"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if _, err := NewStore(db).Rollup(context.Background(), time.Time{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("without changes only the watermark moves", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mark := time.Now().UTC().Add(-time.Minute)
+
+		mock.ExpectQuery(quoted(`SELECT * FROM "order_stats_watermarks"`)).WillReturnRows(watermark(mark))
+		mock.ExpectQuery(quoted(`SELECT DISTINCT`)).WillReturnRows(sqlmock.NewRows([]string{"day"}))
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`INSERT INTO "order_stats_watermarks"`)).
+			WithArgs(rollupName, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		n, err := NewStore(db).Rollup(context.Background(), time.Time{})
+		if err != nil || n != 0 {
+			t.Fatalf("Expected nothing rebuilt, got %d, %v", n, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("rebuild leaves the watermark alone", func(t *testing.T) {
+		db, mock := mockDB(t)
+
+		mock.ExpectQuery(quoted(`FROM "orders" WHERE status IN ($1,$2,$3) AND created_at >= $4`)).WithArgs("PENDING", "BACKORDERED", "PAID", time.Time{}).
+			WillReturnRows(productRows())
+		mock.ExpectBegin()
+		mock.ExpectExec(quoted(`DELETE FROM "product_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(quoted(`FROM "orders"`)).WillReturnRows(customerRows())
+		mock.ExpectExec(quoted(`DELETE FROM "customer_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(quoted(`INSERT INTO "customer_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(quoted(`INSERT INTO "product_order_stats_daily"`)).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		if _, err := NewStore(db).Rebuild(context.Background(), time.Time{}); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestForProduct(t *testing.T) {
+	updated := time.Date(2026, 10, 9, 8, 0, 0, 0, time.UTC)
+	totals := func(orders, units int64, revenue float64) *sqlmock.Rows {
+		rows := sqlmock.NewRows([]string{"orders", "units", "revenue", "currency", "updated_at"})
+		if orders == 0 {
+			return rows.AddRow(0, 0, 0.0, "", nil)
+		}
+		return rows.AddRow(orders, units, revenue, "USD", updated)
+	}
+
+	t.Run("window", func(t *testing.T) {
+		db, mock := mockDB(t)
+		from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -6)
+		mock.ExpectQuery(quoted(`FROM "product_order_stats_daily" WHERE product_id = $1 AND day >= $2`)).
+			WithArgs("p1", from).
+			WillReturnRows(totals(3, 5, 100))
+
+		got, err := NewStore(db).ForProduct(context.Background(), "p1", "7d")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !got.From.Equal(from) || got.TotalOrders != 3 || got.UnitsSold != 5 || got.Revenue != 100 ||
+			got.AverageOrderValue != 33.33 || got.Currency != "USD" || !got.UpdatedAt.Equal(updated) {
+			t.Errorf("Unexpected stats %+v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("all time without orders", func(t *testing.T) {
+		db, mock := mockDB(t)
+		mock.ExpectQuery(quoted(`FROM "product_order_stats_daily" WHERE product_id = $1`)).
+			WithArgs("p1").
+			WillReturnRows(totals(0, 0, 0))
+
+		got, err := NewStore(db).ForProduct(context.Background(), "p1", "all")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !got.From.IsZero() || got.TotalOrders != 0 || got.AverageOrderValue != 0 || !got.UpdatedAt.IsZero() {
+			t.Errorf("Expected empty stats, got %+v", got)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("unknown window", func(t *testing.T) {
+		db, _ := mockDB(t)
+		if _, err := NewStore(db).ForProduct(context.Background(), "p1", "2w"); err == nil {
+			t.Error("Expected an error for an unknown window")
+		}
+	})
+}
@@ -0,0 +1,268 @@
+// Package subscription stores recurring orders: a product and quantity a
+// customer receives on every cycle of an interval. The order service
+// places the order of each cycle.
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	IntervalDaily   = "daily"
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+)
+
+const (
+	StatusActive = "ACTIVE"
+	StatusPaused = "PAUSED"
+	// StatusSuspended subscriptions ran out of payment retries. They are
+	// resumed like paused ones.
+	StatusSuspended = "SUSPENDED"
+	StatusCancelled = "CANCELLED"
+)
+
+var (
+	ErrInvalidSubscription = errors.New("invalid subscription")
+	ErrNotFound            = errors.New("subscription not found")
+	ErrCancelled           = errors.New("subscription is cancelled")
+)
+
+// Subscription places an order for Quantity units of ProductID every
+// Interval, starting at NextRunAt. The region, country, currency and
+// discount code are used for every order.
+type Subscription struct {
+	ID           string `gorm:"primaryKey" json:"id"`
+	CustomerID   string `gorm:"not null;index" json:"customerId"`
+	TenantID     string `json:"tenantId,omitempty"`
+	ProductID    string `gorm:"not null" json:"productId"`
+	Quantity     int    `gorm:"not null" json:"quantity"`
+	Interval     string `gorm:"not null" json:"interval"`
+	Region       string `json:"region,omitempty"`
+	Country      string `json:"country,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	DiscountCode string `json:"discountCode,omitempty"`
+	Status       string `gorm:"not null;index" json:"status"`
+	// NextRunAt is when the next cycle's order is placed.
+	NextRunAt time.Time `gorm:"index" json:"nextRunAt"`
+	// RetryAt is when the current cycle's order is tried again after a
+	// failed payment, and FailedAttempts how often it failed so far.
+	RetryAt        *time.Time `gorm:"index" json:"retryAt,omitempty"`
+	FailedAttempts int        `json:"failedAttempts"`
+	LastOrderID    string     `json:"lastOrderId,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// Next returns the start of the cycle after the one starting at t.
+func Next(interval string, t time.Time) time.Time {
+	switch interval {
+	case IntervalDaily:
+		return t.AddDate(0, 0, 1)
+	case IntervalWeekly:
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 1, 0)
+}
+
+// Retrying reports whether the subscription is due at now for a retry of
+// its current cycle rather than for a new cycle.
+func (s *Subscription) Retrying(now time.Time) bool {
+	return s.RetryAt != nil && !s.RetryAt.After(now) && s.NextRunAt.After(now)
+}
+
+func (s *Subscription) validate() error {
+	if s.CustomerID == "" || s.ProductID == "" {
+		return fmt.Errorf("%w: customerId and productId are required", ErrInvalidSubscription)
+	}
+	if s.Quantity <= 0 {
+		return fmt.Errorf("%w: quantity must be positive", ErrInvalidSubscription)
+	}
+	if !slices.Contains([]string{IntervalDaily, IntervalWeekly, IntervalMonthly}, s.Interval) {
+		return fmt.Errorf("%w: unknown interval %q", ErrInvalidSubscription, s.Interval)
+	}
+	return nil
+}
+
+// Store keeps subscriptions in Postgres.
+type Store struct {
+	db *gorm.DB
+}
+
+func NewStore(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+// Create stores a new active subscription. Without a NextRunAt the first
+// order is placed on the next run of the scheduler.
+func (s *Store) Create(ctx context.Context, sub *Subscription) error {
+	if err := sub.validate(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if sub.NextRunAt.IsZero() {
+		sub.NextRunAt = now
+	} else if sub.NextRunAt.Before(now) {
+		return fmt.Errorf("%w: nextRunAt must not be in the past", ErrInvalidSubscription)
+	}
+	sub.ID = uuid.New().String()
+	sub.Status = StatusActive
+	sub.NextRunAt = sub.NextRunAt.UTC()
+	sub.RetryAt, sub.FailedAttempts, sub.LastOrderID, sub.LastError = nil, 0, "", ""
+	return s.db.WithContext(ctx).Create(sub).Error
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*Subscription, error) {
+	var sub Subscription
+	err := s.db.WithContext(ctx).First(&sub, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	return &sub, err
+}
+
+// List returns the customer's subscriptions, oldest first.
+func (s *Store) List(ctx context.Context, customerID string) ([]Subscription, error) {
+	var subs []Subscription
+	err := s.db.WithContext(ctx).Where("customer_id = ?", customerID).Order("created_at, id").Find(&subs).Error
+	return subs, err
+}
+
+// Changes are the fields Update may change; nil fields are kept.
+type Changes struct {
+	Quantity  *int       `json:"quantity,omitempty"`
+	Interval  *string    `json:"interval,omitempty"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+	// Status pauses (PAUSED) or resumes (ACTIVE) the subscription.
+	Status *string `json:"status,omitempty"`
+}
+
+// Update applies changes to a subscription that is not cancelled.
+// Resuming clears pending payment retries, and a subscription whose next
+// run has passed meanwhile runs on the next scheduler run.
+func (s *Store) Update(ctx context.Context, id string, changes Changes) (*Subscription, error) {
+	var sub *Subscription
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		sub, err = (&Store{db: tx}).Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if sub.Status == StatusCancelled {
+			return ErrCancelled
+		}
+		if changes.Quantity != nil {
+			sub.Quantity = *changes.Quantity
+		}
+		if changes.Interval != nil {
+			sub.Interval = *changes.Interval
+		}
+		if changes.NextRunAt != nil {
+			if changes.NextRunAt.Before(time.Now()) {
+				return fmt.Errorf("%w: nextRunAt must not be in the past", ErrInvalidSubscription)
+			}
+			sub.NextRunAt = changes.NextRunAt.UTC()
+		}
+		if changes.Status != nil && *changes.Status != sub.Status {
+			switch *changes.Status {
+			case StatusPaused:
+			case StatusActive:
+				sub.RetryAt, sub.FailedAttempts = nil, 0
+				if sub.NextRunAt.Before(time.Now()) {
+					sub.NextRunAt = time.Now().UTC()
+				}
+			default:
+				return fmt.Errorf("%w: status can only be set to %s or %s", ErrInvalidSubscription, StatusActive, StatusPaused)
+			}
+			sub.Status = *changes.Status
+		}
+		if err := sub.validate(); err != nil {
+			return err
+		}
+		return tx.Select("Quantity", "Interval", "NextRunAt", "Status", "RetryAt", "FailedAttempts", "UpdatedAt").Save(sub).Error
+	})
+	return sub, err
+}
+
+// Cancel stops a subscription for good.
+func (s *Store) Cancel(ctx context.Context, id string) (*Subscription, error) {
+	sub, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Status == StatusCancelled {
+		return nil, ErrCancelled
+	}
+	sub.Status = StatusCancelled
+	sub.RetryAt = nil
+	err = s.db.WithContext(ctx).Model(sub).Select("Status", "RetryAt", "UpdatedAt").Updates(sub).Error
+	return sub, err
+}
+
+// Due returns active subscriptions whose next cycle or payment retry is
+// due at now, earliest first.
+func (s *Store) Due(ctx context.Context, now time.Time, limit int) ([]Subscription, error) {
+	var subs []Subscription
+	err := s.db.WithContext(ctx).
+		Where("status = ? AND (next_run_at <= ? OR retry_at <= ?)", StatusActive, now, now).
+		Order("LEAST(next_run_at, COALESCE(retry_at, next_run_at)), id").
+		Limit(limit).
+		Find(&subs).Error
+	return subs, err
+}
+
+// Claim takes a due subscription for this run so concurrent workers do not
+// place the same order twice. A retry clears RetryAt; a new cycle moves
+// NextRunAt past now, skipping cycles that were missed, and starts its
+// attempts afresh. It reports false if another worker claimed it first.
+func (s *Store) Claim(ctx context.Context, sub *Subscription, now time.Time) (bool, error) {
+	q := s.db.WithContext(ctx).Model(&Subscription{}).Where("id = ? AND status = ?", sub.ID, StatusActive)
+	var updates map[string]interface{}
+	if sub.Retrying(now) {
+		q = q.Where("retry_at = ?", *sub.RetryAt)
+		updates = map[string]interface{}{"retry_at": nil}
+	} else {
+		q = q.Where("next_run_at = ?", sub.NextRunAt)
+		next := Next(sub.Interval, sub.NextRunAt)
+		for !next.After(now) {
+			next = Next(sub.Interval, next)
+		}
+		sub.NextRunAt = next.UTC()
+		sub.FailedAttempts = 0
+		updates = map[string]interface{}{"next_run_at": sub.NextRunAt, "retry_at": nil, "failed_attempts": 0}
+	}
+	res := q.Updates(updates)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	sub.RetryAt = nil
+	return res.RowsAffected > 0, nil
+}
+
+// Record stores the outcome of a run: the order placed or the error, and
+// any retry scheduled. A subscription cancelled or paused meanwhile keeps
+// its status.
+func (s *Store) Record(ctx context.Context, sub *Subscription) error {
+	return s.db.WithContext(ctx).Model(&Subscription{}).Where("id = ?", sub.ID).Updates(map[string]interface{}{
+		"retry_at":        sub.RetryAt,
+		"failed_attempts": sub.FailedAttempts,
+		"last_order_id":   sub.LastOrderID,
+		"last_error":      sub.LastError,
+		"updated_at":      time.Now().UTC(),
+	}).Error
+}
+
+// Suspend stops an active subscription that ran out of payment retries.
+func (s *Store) Suspend(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Model(&Subscription{}).
+		Where("id = ? AND status = ?", id, StatusActive).
+		Updates(map[string]interface{}{"status": StatusSuspended, "retry_at": nil, "updated_at": time.Now().UTC()}).Error
+}
@@ -0,0 +1,57 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		interval string
+		want     time.Time
+	}{
+		{IntervalDaily, time.Date(2026, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{IntervalWeekly, time.Date(2026, 2, 7, 9, 0, 0, 0, time.UTC)},
+		{IntervalMonthly, time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := Next(tt.interval, start); !got.Equal(tt.want) {
+			t.Errorf("Next(%s) = %v, want %v", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestRetrying(t *testing.T) {
+	now := time.Now()
+	retryAt := now.Add(-time.Minute)
+	sub := &Subscription{NextRunAt: now.Add(time.Hour), RetryAt: &retryAt}
+	if !sub.Retrying(now) {
+		t.Error("Expected a due retry within the cycle to be a retry")
+	}
+	sub.NextRunAt = now.Add(-time.Second)
+	if sub.Retrying(now) {
+		t.Error("Expected a due cycle to take over a pending retry")
+	}
+	sub.RetryAt = nil
+	if sub.Retrying(now) {
+		t.Error("Expected no retry without RetryAt")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Subscription{CustomerID: "c1", ProductID: "p1", Quantity: 1, Interval: IntervalWeekly}
+	if err := valid.validate(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	for name, sub := range map[string]Subscription{
+		"no customer":  {ProductID: "p1", Quantity: 1, Interval: IntervalWeekly},
+		"no quantity":  {CustomerID: "c1", ProductID: "p1", Interval: IntervalWeekly},
+		"bad interval": {CustomerID: "c1", ProductID: "p1", Quantity: 1, Interval: "hourly"},
+	} {
+		if err := sub.validate(); !errors.Is(err, ErrInvalidSubscription) {
+			t.Errorf("%s: expected ErrInvalidSubscription, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Package supervisor runs the long-lived goroutines of the service:
+// consumers, relays, watchers and scheduled job loops. A goroutine that
+// returns before it is told to stop, or panics, is restarted with backoff
+// instead of silently disappearing, and the state of every goroutine is
+// reported for /readyz.
+package supervisor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"order-service/internal/backoff"
+	"order-service/internal/errreport"
+	"order-service/internal/metrics"
+)
+
+// States of a supervised goroutine.
+const (
+	StateRunning    = "running"
+	StateRestarting = "restarting"
+	StateDone       = "done"
+	StateStopped    = "stopped"
+)
+
+// Status is the supervisor's view of one goroutine.
+type Status struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Restarts counts every restart since the supervisor started it.
+	Restarts int `json:"restarts"`
+	// Failures counts the crashes since it last ran long enough to count
+	// as healthy.
+	Failures      int        `json:"failures"`
+	LastError     string     `json:"lastError,omitempty"`
+	LastFailureAt *time.Time `json:"lastFailureAt,omitempty"`
+	StartedAt     time.Time  `json:"startedAt"`
+}
+
+// Supervisor starts goroutines and restarts them when they crash.
+type Supervisor struct {
+	policy      backoff.Policy
+	maxFailures int
+	reporter    errreport.Reporter
+
+	mu    sync.Mutex
+	tasks []*Status
+	wg    sync.WaitGroup
+}
+
+// New returns a supervisor that waits policy.Delay(n) before the nth
+// consecutive restart of a goroutine and reports it unhealthy after
+// maxFailures consecutive crashes. A run longer than policy.Max resets the
+// count.
+func New(policy backoff.Policy, maxFailures int) *Supervisor {
+	if maxFailures <= 0 {
+		maxFailures = 5
+	}
+	return &Supervisor{policy: policy, maxFailures: maxFailures}
+}
+
+// SetReporter reports goroutines that panic to r. Call it before starting
+// any.
+func (s *Supervisor) SetReporter(r errreport.Reporter) {
+	s.reporter = r
+}
+
+// Go runs fn until ctx is done. If fn returns earlier, with or without an
+// error, or panics, it is started again.
+func (s *Supervisor) Go(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	s.start(ctx, name, fn, false)
+}
+
+// GoOnce runs fn until it succeeds or ctx is done. Errors and panics
+// start it again.
+func (s *Supervisor) GoOnce(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	s.start(ctx, name, fn, true)
+}
+
+func (s *Supervisor) start(ctx context.Context, name string, fn func(ctx context.Context) error, once bool) {
+	st := &Status{Name: name}
+	s.mu.Lock()
+	s.tasks = append(s.tasks, st)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			s.update(st, func() {
+				st.State = StateRunning
+				st.StartedAt = time.Now().UTC()
+			})
+			started := time.Now()
+			err := s.run(ctx, name, fn)
+			if ctx.Err() != nil {
+				s.update(st, func() { st.State = StateStopped })
+				return
+			}
+			if once && err == nil {
+				s.update(st, func() { st.State = StateDone })
+				return
+			}
+			if err == nil {
+				err = errExited
+			}
+
+			var delay time.Duration
+			s.update(st, func() {
+				if time.Since(started) > s.healthyAfter() {
+					st.Failures = 0
+				}
+				delay = s.policy.Delay(st.Failures)
+				now := time.Now().UTC()
+				st.State = StateRestarting
+				st.Restarts++
+				st.Failures++
+				st.LastError = err.Error()
+				st.LastFailureAt = &now
+			})
+			metrics.GoroutineRestarts.WithLabelValues(name, reason(err)).Inc()
+			log.Printf("Goroutine %s crashed, restarting in %s: %v", name, delay, err)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				s.update(st, func() { st.State = StateStopped })
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+}
+
+var errExited = errors.New("returned before shutdown")
+
+// run calls fn and turns a panic into an error, logging its stack and
+// reporting it.
+func (s *Supervisor) run(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = errreport.Recovered(ctx, s.reporter, v, "goroutine", map[string]string{"goroutine": name}).Err
+		}
+	}()
+	return fn(ctx)
+}
+
+// reason labels a crash for metrics: "panic", "exit" or "error".
+func reason(err error) string {
+	var p *errreport.PanicError
+	switch {
+	case errors.As(err, &p):
+		return "panic"
+	case errors.Is(err, errExited):
+		return "exit"
+	}
+	return "error"
+}
+
+func (s *Supervisor) update(st *Status, fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+}
+
+// healthyAfter is how long a run must last for its crash not to count as
+// consecutive with the previous one.
+func (s *Supervisor) healthyAfter() time.Duration {
+	return max(s.policy.Max, s.policy.Delay(0))
+}
+
+// Wait blocks until every goroutine has returned. Goroutines return once
+// the context they were started with is done.
+func (s *Supervisor) Wait() {
+	s.wg.Wait()
+}
+
+// Statuses returns the status of every goroutine, sorted by name.
+func (s *Supervisor) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, 0, len(s.tasks))
+	for _, st := range s.tasks {
+		statuses = append(statuses, *st)
+	}
+	slices.SortFunc(statuses, func(a, b Status) int { return cmp.Compare(a.Name, b.Name) })
+	return statuses
+}
+
+// Healthy reports false while a goroutine is crash looping: it has crashed
+// maxFailures times in a row and has not run long enough since to count as
+// recovered.
+func (s *Supervisor) Healthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range s.tasks {
+		if st.Failures < s.maxFailures || st.State == StateDone {
+			continue
+		}
+		if st.State != StateRunning || time.Since(st.StartedAt) < s.healthyAfter() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/internal/backoff"
+
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+var fast = backoff.Policy{Initial: time.Millisecond, Max: 5 * time.Millisecond}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGoRestartsCrashedGoroutines(t *testing.T) {
+	cases := []struct {
+		name  string
+		crash func() error
+		want  string
+	}{
+		{"panic", func() error { panic("boom") }, "panic: boom"},
+		{"error", func() error { return errors.New("channel closed") }, "channel closed"},
+		{"exit", func() error { return nil }, "returned before shutdown"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(fast, 5)
+			ctx, cancel := context.WithCancel(context.Background())
+			var runs atomic.Int32
+			s.Go(ctx, "worker", func(ctx context.Context) error {
+				if runs.Add(1) <= 2 {
+					return tc.crash()
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+			waitFor(t, "the third run", func() bool { return runs.Load() == 3 })
+			waitFor(t, "the worker to run", func() bool { return s.Statuses()[0].State == StateRunning })
+			st := s.Statuses()[0]
+			if st.Restarts != 2 || st.LastError != tc.want || st.LastFailureAt == nil {
+				t.Errorf("Expected 2 restarts after %q, got %+v", tc.want, st)
+			}
+
+			cancel()
+			s.Wait()
+			if st := s.Statuses()[0]; st.State != StateStopped {
+				t.Errorf("Expected the worker to be stopped, got %s", st.State)
+			}
+		})
+	}
+}
+
+func TestGoOnce(t *testing.T) {
+	s := New(fast, 5)
+	var runs atomic.Int32
+	s.GoOnce(context.Background(), "backfill", func(ctx context.Context) error {
+		if runs.Add(1) == 1 {
+			return errors.New("database down")
+		}
+		return nil
+	})
+	s.Wait()
+
+	st := s.Statuses()[0]
+	if runs.Load() != 2 || st.State != StateDone || st.Restarts != 1 {
+		t.Errorf("Expected a retry and then done, got %d runs and %+v", runs.Load(), st)
+	}
+	if !s.Healthy() {
+		t.Error("Expected a finished goroutine to be healthy")
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	s := New(backoff.Policy{Initial: time.Millisecond, Max: 200 * time.Millisecond}, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer s.Wait()
+	defer cancel()
+
+	var failing atomic.Bool
+	failing.Store(true)
+	var runs atomic.Int32
+	s.Go(ctx, "consumer", func(ctx context.Context) error {
+		runs.Add(1)
+		if failing.Load() {
+			return errors.New("channel closed")
+		}
+		<-ctx.Done()
+		return nil
+	})
+	s.Go(ctx, "relay", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	waitFor(t, "three failures", func() bool { return s.Statuses()[0].Failures >= 3 })
+	if s.Healthy() {
+		t.Error("Expected a crash looping goroutine to be unhealthy")
+	}
+
+	failing.Store(false)
+	restarted := runs.Load()
+	waitFor(t, "the next run", func() bool { return runs.Load() > restarted })
+	if s.Healthy() {
+		t.Error("Expected the goroutine to stay unhealthy until it has run for a while")
+	}
+	waitFor(t, "the goroutine to recover", s.Healthy)
+}
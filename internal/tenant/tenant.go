@@ -0,0 +1,37 @@
+package tenant
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+const Header = "X-Tenant-ID"
+
+type contextKey struct{}
+
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant of the current request, or "" if none was
+// set.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Middleware stores the tenant from the X-Tenant-ID header on the request
+// context, falling back to defaultTenant.
+func Middleware(defaultTenant string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if id == "" {
+			id = defaultTenant
+		}
+		if id != "" {
+			c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), id))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,121 @@
+package timezone
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	_ "time/tzdata" // the runtime image has no zoneinfo
+
+	"order-service/internal/tenant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueryParam overrides the tenant's timezone for a single request.
+const QueryParam = "tz"
+
+const dateLayout = "2006-01-02"
+
+// Resolver decides which timezone a request's dates are read in: the tz
+// query parameter, then the tenant's configured zone, then the default.
+type Resolver struct {
+	def     *time.Location
+	tenants map[string]*time.Location
+}
+
+// NewResolver builds a resolver from a default IANA zone name and a JSON
+// object mapping tenant IDs to zone names, e.g. {"acme":"Asia/Jakarta"}.
+func NewResolver(def, tenants string) (*Resolver, error) {
+	r := &Resolver{def: time.UTC, tenants: map[string]*time.Location{}}
+	if def != "" {
+		loc, err := time.LoadLocation(def)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default timezone: %w", err)
+		}
+		r.def = loc
+	}
+	if tenants == "" {
+		return r, nil
+	}
+
+	var names map[string]string
+	if err := json.Unmarshal([]byte(tenants), &names); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant timezones: %w", err)
+	}
+	for id, name := range names {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone for tenant %s: %w", id, err)
+		}
+		r.tenants[id] = loc
+	}
+	return r, nil
+}
+
+// Location returns the zone for tenantID, or for override when it is set.
+func (r *Resolver) Location(tenantID, override string) (*time.Location, error) {
+	if override != "" {
+		loc, err := time.LoadLocation(override)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", override)
+		}
+		return loc, nil
+	}
+	if loc, ok := r.tenants[tenantID]; ok {
+		return loc, nil
+	}
+	return r.def, nil
+}
+
+type contextKey struct{}
+
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, contextKey{}, loc)
+}
+
+// FromContext returns the timezone of the current request, or UTC if none
+// was set.
+func FromContext(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(contextKey{}).(*time.Location); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// Middleware stores the request's timezone on its context. It must run
+// after tenant.Middleware. An unknown tz parameter is rejected with 400.
+func Middleware(r *Resolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		loc, err := r.Location(tenant.FromContext(c.Request.Context()), c.Query(QueryParam))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request = c.Request.WithContext(WithLocation(c.Request.Context(), loc))
+		c.Next()
+	}
+}
+
+// ParseBoundary reads a date-range boundary in loc and returns it in UTC.
+// Timestamps with an offset are taken as is; timestamps without one and
+// plain dates are local to loc. A plain date means the start of that day,
+// or with end set the start of the next day, so "to=2026-03-08" includes
+// the whole of the 8th even when a DST change makes it 23 or 25 hours long.
+func ParseBoundary(v string, loc *time.Location, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", v, loc); err == nil {
+		return t.UTC(), nil
+	}
+	d, err := time.ParseInLocation(dateLayout, v, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		d = time.Date(d.Year(), d.Month(), d.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return d.UTC(), nil
+}
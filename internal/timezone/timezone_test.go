@@ -0,0 +1,81 @@
+package timezone
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBoundaryAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		day      string
+		wantFrom string
+		wantTo   string
+		hours    float64
+	}{
+		{"normal day", "2026-03-07", "2026-03-07T05:00:00Z", "2026-03-08T05:00:00Z", 24},
+		{"spring forward", "2026-03-08", "2026-03-08T05:00:00Z", "2026-03-09T04:00:00Z", 23},
+		{"fall back", "2026-11-01", "2026-11-01T04:00:00Z", "2026-11-02T05:00:00Z", 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, err := ParseBoundary(tt.day, ny, false)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			to, err := ParseBoundary(tt.day, ny, true)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := from.Format(time.RFC3339); got != tt.wantFrom {
+				t.Errorf("from = %s, want %s", got, tt.wantFrom)
+			}
+			if got := to.Format(time.RFC3339); got != tt.wantTo {
+				t.Errorf("to = %s, want %s", got, tt.wantTo)
+			}
+			if h := to.Sub(from).Hours(); h != tt.hours {
+				t.Errorf("Expected a %v hour day, got %v", tt.hours, h)
+			}
+		})
+	}
+}
+
+func TestParseBoundaryTimestamps(t *testing.T) {
+	jakarta, _ := time.LoadLocation("Asia/Jakarta")
+
+	got, err := ParseBoundary("2026-01-01T10:00:00+02:00", jakarta, false)
+	if err != nil || got.Format(time.RFC3339) != "2026-01-01T08:00:00Z" {
+		t.Errorf("Expected explicit offset to win, got %v, %v", got, err)
+	}
+	got, err = ParseBoundary("2026-01-01T10:00:00", jakarta, false)
+	if err != nil || got.Format(time.RFC3339) != "2026-01-01T03:00:00Z" {
+		t.Errorf("Expected local time in Jakarta, got %v, %v", got, err)
+	}
+	if _, err := ParseBoundary("yesterday", jakarta, false); err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+}
+
+func TestResolver(t *testing.T) {
+	r, err := NewResolver("UTC", `{"acme":"Asia/Jakarta"}`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if loc, _ := r.Location("acme", ""); loc.String() != "Asia/Jakarta" {
+		t.Errorf("Expected tenant zone, got %s", loc)
+	}
+	if loc, _ := r.Location("acme", "Europe/Berlin"); loc.String() != "Europe/Berlin" {
+		t.Errorf("Expected tz parameter to win, got %s", loc)
+	}
+	if loc, _ := r.Location("other", ""); loc != time.UTC {
+		t.Errorf("Expected default zone, got %s", loc)
+	}
+	if _, err := r.Location("", "Mars/Olympus"); err == nil {
+		t.Error("Expected an error for an unknown zone")
+	}
+}
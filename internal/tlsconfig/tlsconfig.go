@@ -0,0 +1,150 @@
+package tlsconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Files locates the PEM files for a mutual TLS client. CAFile is optional;
+// without it the system roots verify the server.
+type Files struct {
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+	ServerName string
+}
+
+// ClientCerts holds a client certificate and CA bundle loaded from disk and
+// swaps them in when the files change, so rotated certificates are used
+// for new connections without a restart.
+type ClientCerts struct {
+	files Files
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+	// raw is the content last loaded, to notice changes.
+	raw []byte
+}
+
+func NewClientCerts(files Files) (*ClientCerts, error) {
+	if files.CertFile == "" || files.KeyFile == "" {
+		return nil, errors.New("both a certificate and a key file are required")
+	}
+	c := &ClientCerts{files: files}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload reads the files again and reports whether they changed. On error
+// the previous certificate stays in use.
+func (c *ClientCerts) Reload() (bool, error) {
+	certPEM, err := os.ReadFile(c.files.CertFile)
+	if err != nil {
+		return false, err
+	}
+	keyPEM, err := os.ReadFile(c.files.KeyFile)
+	if err != nil {
+		return false, err
+	}
+	var caPEM []byte
+	if c.files.CAFile != "" {
+		if caPEM, err = os.ReadFile(c.files.CAFile); err != nil {
+			return false, err
+		}
+	}
+	raw := bytes.Join([][]byte{certPEM, keyPEM, caPEM}, nil)
+
+	c.mu.RLock()
+	unchanged := bytes.Equal(raw, c.raw)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if caPEM != nil {
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return false, fmt.Errorf("no certificates found in %s", c.files.CAFile)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert, c.pool, c.raw = &cert, pool, raw
+	return true, nil
+}
+
+// Watch reloads the files every interval until ctx is cancelled.
+func (c *ClientCerts) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := c.Reload()
+		if err != nil {
+			log.Printf("Failed to reload client certificate %s, keeping the previous one: %v", c.files.CertFile, err)
+		} else if changed {
+			log.Printf("Reloaded client certificate %s", c.files.CertFile)
+		}
+	}
+}
+
+// TLSConfig presents the current certificate. With a CA bundle, the server
+// is verified against the bundle loaded at handshake time rather than the
+// one at construction, which is why the standard verification is replaced.
+func (c *ClientCerts) TLSConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.files.ServerName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			return c.cert, nil
+		},
+	}
+	if c.files.CAFile != "" {
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = c.verify
+	}
+	return cfg
+}
+
+func (c *ClientCerts) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("server presented no certificate")
+	}
+	c.mu.RLock()
+	pool := c.pool
+	c.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		Roots:         pool,
+		DNSName:       cs.ServerName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
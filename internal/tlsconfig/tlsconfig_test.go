@@ -0,0 +1,137 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type issued struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func issue(t *testing.T, name string, parent *issued, template x509.Certificate) *issued {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parentCert, parentKey := &template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &issued{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func TestClientCerts(t *testing.T) {
+	ca := issue(t, "ca", nil, x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign})
+	server := issue(t, "product-service", ca, x509.Certificate{
+		DNSNames:    []string{"product-service"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	client := issue(t, "order-service", ca, x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	serverCert, _ := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	files := Files{
+		CertFile: filepath.Join(dir, "client.pem"),
+		KeyFile:  filepath.Join(dir, "client-key.pem"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+	}
+	write := func(path string, data []byte) {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(files.CertFile, client.certPEM)
+	write(files.KeyFile, client.keyPEM)
+	write(files.CAFile, ca.certPEM)
+
+	certs, err := NewClientCerts(files)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: certs.TLSConfig()}}
+
+	t.Run("presents the client certificate", func(t *testing.T) {
+		resp, err := httpClient.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("reload picks up rotated files", func(t *testing.T) {
+		if changed, err := certs.Reload(); err != nil || changed {
+			t.Fatalf("Expected no change, got %v, %v", changed, err)
+		}
+		rotated := issue(t, "order-service-2", ca, x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}})
+		write(files.CertFile, rotated.certPEM)
+		write(files.KeyFile, rotated.keyPEM)
+		if changed, err := certs.Reload(); err != nil || !changed {
+			t.Fatalf("Expected a change, got %v, %v", changed, err)
+		}
+	})
+
+	t.Run("rejects a server outside the CA bundle", func(t *testing.T) {
+		other := issue(t, "other-ca", nil, x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign})
+		write(files.CAFile, other.certPEM)
+		if _, err := certs.Reload(); err != nil {
+			t.Fatal(err)
+		}
+		httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: certs.TLSConfig()}}
+		if _, err := httpClient.Get(srv.URL); err == nil {
+			t.Error("Expected the handshake to fail")
+		}
+	})
+
+	t.Run("broken files keep the previous certificate", func(t *testing.T) {
+		write(files.KeyFile, []byte("not a key"))
+		if _, err := certs.Reload(); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
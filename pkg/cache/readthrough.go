@@ -0,0 +1,184 @@
+// Package cache implements the cache-aside pattern once, for any value
+// type: look the key up in a store, load it on a miss and store the result.
+package cache
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Store holds cached values. Get reports whether the key was found.
+type Store[T any] interface {
+	Get(ctx context.Context, key string) (T, bool, error)
+	Set(ctx context.Context, key string, value T, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Loader fetches the value of key from the source of truth.
+type Loader[T any] func(ctx context.Context, key string) (T, error)
+
+type Options struct {
+	// TTL is passed to Store.Set; stores may treat zero as their own
+	// default.
+	TTL time.Duration
+	// NegativeTTL is how long a loader error for which NotFound reports
+	// true is remembered and returned without loading again. Zero turns
+	// negative caching off.
+	NegativeTTL time.Duration
+	NotFound    func(error) bool
+}
+
+// ReadThrough serves values from a store and loads missing ones. Concurrent
+// misses for the same key share one load, which is detached from the
+// caller that started it so that caller giving up does not fail the
+// others; it keeps that caller's deadline. Store errors are logged and
+// treated as misses. A nil store only coalesces loads.
+type ReadThrough[T any] struct {
+	store Store[T]
+	load  Loader[T]
+	opts  Options
+	group singleflight.Group
+
+	mu      sync.Mutex
+	missing map[string]miss
+}
+
+type miss struct {
+	err   error
+	until time.Time
+}
+
+func NewReadThrough[T any](store Store[T], load Loader[T], opts Options) *ReadThrough[T] {
+	return &ReadThrough[T]{store: store, load: load, opts: opts, missing: make(map[string]miss)}
+}
+
+// Get returns the value of key from the store, or loads and stores it.
+func (c *ReadThrough[T]) Get(ctx context.Context, key string) (T, error) {
+	var zero T
+	if err := c.remembered(key); err != nil {
+		return zero, err
+	}
+	if c.store != nil {
+		v, ok, err := c.store.Get(ctx, key)
+		if err != nil {
+			log.Printf("Cache error on get %s: %v", key, err)
+		} else if ok {
+			return v, nil
+		}
+	}
+
+	ch := c.group.DoChan(key, func() (any, error) {
+		loadCtx := context.WithoutCancel(ctx)
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			loadCtx, cancel = context.WithDeadline(loadCtx, deadline)
+			defer cancel()
+		}
+		v, err := c.load(loadCtx, key)
+		if err != nil {
+			c.remember(key, err)
+			return nil, err
+		}
+		if c.store != nil {
+			if err := c.store.Set(loadCtx, key, v, c.opts.TTL); err != nil {
+				log.Printf("Cache error on set %s: %v", key, err)
+			}
+		}
+		return v, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// Invalidate drops key from the store and forgets a remembered miss.
+func (c *ReadThrough[T]) Invalidate(ctx context.Context, key string) error {
+	c.mu.Lock()
+	delete(c.missing, key)
+	c.mu.Unlock()
+	if c.store == nil {
+		return nil
+	}
+	return c.store.Delete(ctx, key)
+}
+
+func (c *ReadThrough[T]) remember(key string, err error) {
+	if c.opts.NegativeTTL <= 0 || c.opts.NotFound == nil || !c.opts.NotFound(err) {
+		return
+	}
+	c.mu.Lock()
+	c.missing[key] = miss{err: err, until: time.Now().Add(c.opts.NegativeTTL)}
+	c.mu.Unlock()
+}
+
+func (c *ReadThrough[T]) remembered(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.missing[key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(m.until) {
+		delete(c.missing, key)
+		return nil
+	}
+	return m.err
+}
+
+// MemoryStore is an in-process Store whose entries expire after their TTL.
+// Expired entries are dropped when read.
+type MemoryStore[T any] struct {
+	mu      sync.Mutex
+	entries map[string]entry[T]
+}
+
+type entry[T any] struct {
+	value T
+	until time.Time
+}
+
+var _ Store[int] = &MemoryStore[int]{}
+
+func NewMemoryStore[T any]() *MemoryStore[T] {
+	return &MemoryStore[T]{entries: make(map[string]entry[T])}
+}
+
+func (s *MemoryStore[T]) Get(_ context.Context, key string) (T, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.until) {
+		delete(s.entries, key)
+		var zero T
+		return zero, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set stores value; a TTL of zero or less does not store it at all.
+func (s *MemoryStore[T]) Set(_ context.Context, key string, value T, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	s.entries[key] = entry[T]{value: value, until: time.Now().Add(ttl)}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore[T]) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errNotFound = errors.New("not found")
+
+func TestReadThrough(t *testing.T) {
+	var loads atomic.Int32
+	c := NewReadThrough(NewMemoryStore[string](), func(ctx context.Context, key string) (string, error) {
+		loads.Add(1)
+		if key == "missing" {
+			return "", errNotFound
+		}
+		return "value of " + key, nil
+	}, Options{
+		TTL:         time.Minute,
+		NegativeTTL: time.Minute,
+		NotFound:    func(err error) bool { return errors.Is(err, errNotFound) },
+	})
+	ctx := context.Background()
+
+	for range 2 {
+		v, err := c.Get(ctx, "a")
+		if err != nil || v != "value of a" {
+			t.Fatalf("Expected the loaded value, got %q, %v", v, err)
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Expected 1 load for a cached key, got %d", n)
+	}
+
+	for range 2 {
+		if _, err := c.Get(ctx, "missing"); !errors.Is(err, errNotFound) {
+			t.Errorf("Expected not found, got %v", err)
+		}
+	}
+	if n := loads.Load(); n != 2 {
+		t.Errorf("Expected a miss to be remembered, got %d loads", n)
+	}
+
+	if err := c.Invalidate(ctx, "a"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	c.Get(ctx, "a")
+	if n := loads.Load(); n != 3 {
+		t.Errorf("Expected a reload after invalidation, got %d loads", n)
+	}
+}
+
+func TestReadThroughStampede(t *testing.T) {
+	var loads atomic.Int32
+	release := make(chan struct{})
+	c := NewReadThrough[int](nil, func(ctx context.Context, key string) (int, error) {
+		loads.Add(1)
+		<-release
+		return 42, nil
+	}, Options{})
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Get(context.Background(), "k"); err != nil || v != 42 {
+				t.Errorf("Expected 42, got %d, %v", v, err)
+			}
+		}()
+	}
+	// Give every caller time to join the load in flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := loads.Load(); n != 1 {
+		t.Errorf("Expected concurrent misses to share 1 load, got %d", n)
+	}
+}
+
+func TestReadThroughConcurrentKeys(t *testing.T) {
+	keys := []string{"a", "b", "c", "missing"}
+	var loads atomic.Int32
+	release := make(chan struct{})
+	c := NewReadThrough(NewMemoryStore[string](), func(ctx context.Context, key string) (string, error) {
+		loads.Add(1)
+		<-release
+		if key == "missing" {
+			return "", errNotFound
+		}
+		return "value of " + key, nil
+	}, Options{
+		TTL:         time.Minute,
+		NegativeTTL: time.Minute,
+		NotFound:    func(err error) bool { return errors.Is(err, errNotFound) },
+	})
+
+	check := func(key string) {
+		v, err := c.Get(context.Background(), key)
+		if key == "missing" {
+			if !errors.Is(err, errNotFound) {
+				t.Errorf("Expected not found for %s, got %q, %v", key, v, err)
+			}
+			return
+		}
+		if err != nil || v != "value of "+key {
+			t.Errorf("Expected the value of %s, got %q, %v", key, v, err)
+		}
+	}
+	hammer := func(rounds int) {
+		var wg sync.WaitGroup
+		for i := range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range rounds {
+					check(keys[(i+j)%len(keys)])
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	// Cold keys: every caller misses and joins the load of its key.
+	done := make(chan struct{})
+	go func() {
+		hammer(1)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	<-done
+	if n := loads.Load(); n != int32(len(keys)) {
+		t.Errorf("Expected 1 load per key, got %d", n)
+	}
+
+	// Warm keys are served from the store and remembered misses.
+	hammer(100)
+	if n := loads.Load(); n != int32(len(keys)) {
+		t.Errorf("Expected no loads for warm keys, got %d", n-int32(len(keys)))
+	}
+
+	// Invalidations racing with reads still return the right values.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 200 {
+			c.Invalidate(context.Background(), keys[i%len(keys)])
+		}
+	}()
+	hammer(100)
+	wg.Wait()
+}
+
+func TestMemoryStoreConcurrency(t *testing.T) {
+	s := NewMemoryStore[int]()
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 500 {
+				key := string(rune('a' + (i+j)%5))
+				switch j % 3 {
+				case 0:
+					s.Set(ctx, key, j, time.Minute)
+				case 1:
+					if _, _, err := s.Get(ctx, key); err != nil {
+						t.Errorf("Expected no error, got %v", err)
+					}
+				default:
+					s.Delete(ctx, key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}